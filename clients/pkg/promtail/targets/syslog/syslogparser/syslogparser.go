@@ -2,12 +2,16 @@ package syslogparser
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/influxdata/go-syslog/v3"
 	"github.com/influxdata/go-syslog/v3/nontransparent"
 	"github.com/influxdata/go-syslog/v3/octetcounting"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
 )
 
 // ParseStream parses a rfc5424 syslog stream from the given Reader, calling
@@ -33,3 +37,61 @@ func ParseStream(r io.Reader, callback func(res *syslog.Result), maxMessageLengt
 
 	return nil
 }
+
+// ParseStreamRFC3164 parses a rfc3164 syslog stream from the given Reader,
+// calling the callback function with the parsed messages. Messages are either
+// newline delimited or octet counted, which is detected automatically.
+// The year of the timestamps is assumed to be the current one.
+// The function returns on EOF or unrecoverable errors.
+func ParseStreamRFC3164(r io.Reader, callback func(res *syslog.Result), maxMessageLength int) error {
+	buf := bufio.NewReaderSize(r, 1<<10)
+
+	b, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	_ = buf.UnreadByte()
+
+	parser := rfc3164.NewParser(rfc3164.WithBestEffort(), rfc3164.WithYear(rfc3164.CurrentYear{}), rfc3164.WithRFC3339())
+	parse := func(frame []byte) {
+		msg, err := parser.Parse(frame)
+		callback(&syslog.Result{Message: msg, Error: err})
+	}
+
+	if b == '<' {
+		scanner := bufio.NewScanner(buf)
+		scanner.Buffer(make([]byte, 0, 1<<10), maxMessageLength)
+		for scanner.Scan() {
+			if frame := bytes.TrimRight(scanner.Bytes(), "\r"); len(frame) > 0 {
+				parse(frame)
+			}
+		}
+		return scanner.Err()
+	} else if b < '0' || b > '9' {
+		return fmt.Errorf("invalid or unsupported framing. first byte: '%s'", string(b))
+	}
+
+	for {
+		length, err := buf.ReadString(' ')
+		if errors.Is(err, io.EOF) && length == "" {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err := strconv.Atoi(length[:len(length)-1])
+		if err != nil {
+			return fmt.Errorf("invalid message length %q: %w", length, err)
+		}
+		if n > maxMessageLength {
+			return fmt.Errorf("message length %d exceeds the maximum of %d", n, maxMessageLength)
+		}
+
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(buf, frame); err != nil {
+			return err
+		}
+		parse(frame)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/influxdata/go-syslog/v3"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
 	"github.com/influxdata/go-syslog/v3/rfc5424"
 	"github.com/stretchr/testify/require"
 
@@ -97,3 +98,48 @@ func TestParseStream_EmptyStream(t *testing.T) {
 	err := syslogparser.ParseStream(r, func(res *syslog.Result) {}, defaultMaxMessageLength)
 	require.Equal(t, err, io.EOF)
 }
+
+func TestParseStreamRFC3164_NewlineSeparated(t *testing.T) {
+	r := strings.NewReader("<13>Jan  2 15:04:05 host app[123]: First\r\n<14>Jan  2 15:04:06 host app: Second\n")
+
+	results := make([]*syslog.Result, 0)
+	cb := func(res *syslog.Result) {
+		results = append(results, res)
+	}
+
+	err := syslogparser.ParseStreamRFC3164(r, cb, defaultMaxMessageLength)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(results))
+	require.NoError(t, results[0].Error)
+	first := results[0].Message.(*rfc3164.SyslogMessage)
+	require.Equal(t, "First", *first.Message)
+	require.Equal(t, "host", *first.Hostname)
+	require.Equal(t, "app", *first.Appname)
+	require.Equal(t, "123", *first.ProcID)
+	require.NoError(t, results[1].Error)
+	require.Equal(t, "Second", *results[1].Message.(*rfc3164.SyslogMessage).Message)
+}
+
+func TestParseStreamRFC3164_OctetCounting(t *testing.T) {
+	r := strings.NewReader("31 <13>Jan  2 15:04:05 host app: a32 <13>Jan  2 15:04:05 host app: bc")
+
+	results := make([]*syslog.Result, 0)
+	cb := func(res *syslog.Result) {
+		results = append(results, res)
+	}
+
+	err := syslogparser.ParseStreamRFC3164(r, cb, defaultMaxMessageLength)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(results))
+	require.Equal(t, "a", *results[0].Message.(*rfc3164.SyslogMessage).Message)
+	require.Equal(t, "bc", *results[1].Message.(*rfc3164.SyslogMessage).Message)
+}
+
+func TestParseStreamRFC3164_OctetCounting_LongMessage(t *testing.T) {
+	r := strings.NewReader("8193 <13>Jan  2 15:04:05 host app: a")
+
+	err := syslogparser.ParseStreamRFC3164(r, func(*syslog.Result) {}, defaultMaxMessageLength)
+	require.EqualError(t, err, "message length 8193 exceeds the maximum of 8192")
+}
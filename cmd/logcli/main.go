@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/logcli/output"
 	"github.com/grafana/loki/v3/pkg/logcli/query"
 	"github.com/grafana/loki/v3/pkg/logcli/seriesquery"
+	"github.com/grafana/loki/v3/pkg/logcli/streamquery"
 	"github.com/grafana/loki/v3/pkg/logcli/volume"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	_ "github.com/grafana/loki/v3/pkg/util/build"
@@ -182,6 +183,22 @@ This is helpful to find high cardinality labels.
 `)
 	seriesQuery = newSeriesQuery(seriesCmd)
 
+	streamsCmd = app.Command("streams", `List the streams held in memory by the ingesters.
+
+The "streams" command lists the streams of the tenant that the ingesters hold
+in memory, with the number of entries, bytes and chunks they hold, their push
+rate and the time they were last pushed to. Use it to find out which label
+combinations a tenant reaching its stream limit is made of.
+
+Use --group-by to aggregate the streams by some of their labels, the groups
+are sorted by their number of streams by default.
+
+Example:
+
+	logcli streams --group-by=namespace,app '{cluster="prod"}'
+`)
+	streamsQuery = newStreamsQuery(streamsCmd)
+
 	fmtCmd = app.Command("fmt", "Formats a LogQL query.")
 
 	statsCmd = app.Command("stats", `Run a stats query.
@@ -360,6 +377,8 @@ func main() {
 		labelsQuery.DoLabels(queryClient)
 	case seriesCmd.FullCommand():
 		seriesQuery.DoSeries(queryClient)
+	case streamsCmd.FullCommand():
+		streamsQuery.DoStreams(queryClient)
 	case fmtCmd.FullCommand():
 		if err := formatLogQL(os.Stdin, os.Stdout); err != nil {
 			log.Fatalf("unable to format logql: %s", err)
@@ -505,6 +524,29 @@ func newSeriesQuery(cmd *kingpin.CmdClause) *seriesquery.SeriesQuery {
 	return q
 }
 
+func newStreamsQuery(cmd *kingpin.CmdClause) *streamquery.StreamsQuery {
+	q := &streamquery.StreamsQuery{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(_ *kingpin.ParseContext) error {
+		q.Quiet = *quiet
+
+		var groupBy []string
+		for _, group := range q.GroupBy {
+			groupBy = append(groupBy, strings.Split(group, ",")...)
+		}
+		q.GroupBy = groupBy
+		return nil
+	})
+
+	cmd.Arg("selector", "eg '{foo=\"bar\",baz=~\".*blip\"}', all the streams of the tenant if not set").StringVar(&q.Selector)
+	cmd.Flag("sort-by", "Sort by streams, entries, bytes, chunks, rate or last_push, in descending order. Defaults to streams when grouping, bytes otherwise.").EnumVar(&q.SortBy, "streams", "entries", "bytes", "chunks", "rate", "last_push")
+	cmd.Flag("group-by", "Comma separated list of labels to group the streams by.").StringsVar(&q.GroupBy)
+	cmd.Flag("limit", "Limit on number of streams or groups to return.").Default("100").IntVar(&q.Limit)
+
+	return q
+}
+
 func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 	// calculate query range from cli params
 	var now, from, to string
@@ -546,6 +588,9 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 		cmd.Flag("step", "Query resolution step width, for metric queries. Evaluate the query at the specified step over the time range.").DurationVar(&q.Step)
 		cmd.Flag("interval", "Query interval, for log queries. Return entries at the specified interval, ignoring those between. **This parameter is experimental, please see Issue 1779**").DurationVar(&q.Interval)
 		cmd.Flag("batch", "Query batch size to use until 'limit' is reached").Default("1000").IntVar(&q.BatchSize)
+		cmd.Flag("stream", "Execute log queries as a single request streamed by the server instead of in batches. Entries are printed as soon as the server sends them.").Default("false").BoolVar(&q.Stream)
+		cmd.Flag("async", "Execute the query as an asynchronous query job of the server, which is not subject to the query timeout. The results are printed as the job completes its splits.").Default("false").BoolVar(&q.Async)
+		cmd.Flag("async-poll-interval", "Interval at which the status of an asynchronous query job is polled.").Default("5s").DurationVar(&q.AsyncPollInterval)
 		cmd.Flag("parallel-duration", "Split the range into jobs of this length to download the logs in parallel. This will result in the logs being out of order. Use --part-path-prefix to create a file per job to maintain ordering.").Default("1h").DurationVar(&q.ParallelDuration)
 		cmd.Flag("parallel-max-workers", "Max number of workers to start up for parallel jobs. A value of 1 will not create any parallel workers. When using parallel workers, limit is ignored.").Default("1").IntVar(&q.ParallelMaxWorkers)
 		cmd.Flag("part-path-prefix", "When set, each server response will be saved to a file with this prefix. Creates files in the format: 'prefix-utc_start-utc_end.part'. Intended to be used with the parallel-* flags so that you can combine the files to maintain ordering based on the filename. Default is to write to stdout.").StringVar(&q.PartPathPrefix)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -18,6 +19,7 @@ type Config struct {
 	ServerMetricsPort int
 	LogLevel          log.Level
 	ProxyConfig       querytee.ProxyConfig
+	ReplayConfig      querytee.ReplayConfig
 }
 
 func main() {
@@ -26,12 +28,19 @@ func main() {
 	flag.IntVar(&cfg.ServerMetricsPort, "server.metrics-port", 9900, "The port where metrics are exposed.")
 	cfg.LogLevel.RegisterFlags(flag.CommandLine)
 	cfg.ProxyConfig.RegisterFlags(flag.CommandLine)
+	cfg.ReplayConfig.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	util_log.InitLogger(&server.Config{
 		LogLevel: cfg.LogLevel,
 	}, prometheus.DefaultRegisterer, false)
 
+	// Replay the query log instead of running the proxy.
+	if cfg.ReplayConfig.QueryLog != "" {
+		replay(cfg)
+		return
+	}
+
 	// Run the instrumentation server.
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collectors.NewGoCollector())
@@ -57,11 +66,36 @@ func main() {
 	proxy.Await()
 }
 
+func replay(cfg Config) {
+	// The recorded time ranges are in the past, so recent samples are compared as well.
+	samplesComparator := querytee.NewSamplesComparator(querytee.SampleComparisonOptions{
+		Tolerance:        cfg.ProxyConfig.ValueComparisonTolerance,
+		UseRelativeError: cfg.ProxyConfig.UseRelativeError,
+		IgnoreEntryOrder: cfg.ProxyConfig.IgnoreEntryOrder,
+	})
+
+	replayer, err := querytee.NewReplayer(cfg.ReplayConfig, cfg.ProxyConfig, samplesComparator, util_log.Logger)
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "Unable to initialize the replay", "err", err.Error())
+		os.Exit(1)
+	}
+
+	summary, err := replayer.Run(context.Background())
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "Unable to replay the query log", "err", err.Error())
+		os.Exit(1)
+	}
+	if summary.Mismatches > 0 {
+		os.Exit(2)
+	}
+}
+
 func lokiReadRoutes(cfg Config) []querytee.Route {
 	samplesComparator := querytee.NewSamplesComparator(querytee.SampleComparisonOptions{
 		Tolerance:         cfg.ProxyConfig.ValueComparisonTolerance,
 		UseRelativeError:  cfg.ProxyConfig.UseRelativeError,
 		SkipRecentSamples: cfg.ProxyConfig.SkipRecentSamples,
+		IgnoreEntryOrder:  cfg.ProxyConfig.IgnoreEntryOrder,
 	})
 
 	return []querytee.Route{
@@ -140,10 +140,14 @@ type MemChunk struct {
 }
 
 type block struct {
-	// This is compressed bytes.
+	// This is compressed bytes, nil once the block is spilled out of memory.
 	b          []byte
 	numEntries int
 
+	// size and load are set when the block is spilled, see MemChunk.SpillBlocks.
+	size int
+	load BlockLoader
+
 	mint, maxt int64
 
 	offset           int // The offset of the block in the chunk.
@@ -546,7 +550,7 @@ func (c *MemChunk) BytesSize() int {
 
 	// blocks
 	for _, b := range c.blocks {
-		size += len(b.b) + crc32.Size // size + crc
+		size += b.compressedLen() + crc32.Size // size + crc
 
 		size += binary.MaxVarintLen32 // num entries
 		size += binary.MaxVarintLen64 // mint
@@ -645,13 +649,18 @@ func (c *MemChunk) writeTo(w io.Writer, forCheckpoint bool) (int64, error) {
 	for i, b := range c.blocks {
 		c.blocks[i].offset = int(offset)
 
+		data, err := b.data()
+		if err != nil {
+			return offset, errors.Wrap(err, "load block")
+		}
+
 		crc32Hash.Reset()
-		_, err := crc32Hash.Write(b.b)
+		_, err = crc32Hash.Write(data)
 		if err != nil {
 			return offset, errors.Wrap(err, "write block")
 		}
 
-		n, err := w.Write(crc32Hash.Sum(b.b))
+		n, err := w.Write(crc32Hash.Sum(data))
 		if err != nil {
 			return offset, errors.Wrap(err, "write block")
 		}
@@ -672,7 +681,7 @@ func (c *MemChunk) writeTo(w io.Writer, forCheckpoint bool) (int64, error) {
 		if c.format >= ChunkFormatV3 {
 			eb.putUvarint(b.uncompressedSize)
 		}
-		eb.putUvarint(len(b.b))
+		eb.putUvarint(b.compressedLen())
 	}
 	metasLen := len(eb.get())
 	eb.putHash(crc32Hash)
@@ -1150,17 +1159,25 @@ type encBlock struct {
 }
 
 func (b encBlock) Iterator(ctx context.Context, pipeline log.StreamPipeline) iter.EntryIterator {
-	if len(b.b) == 0 {
+	if b.compressedLen() == 0 {
 		return iter.NoopIterator
 	}
-	return newEntryIterator(ctx, GetReaderPool(b.enc), b.b, pipeline, b.format, b.symbolizer)
+	data, err := b.data()
+	if err != nil {
+		return errorIterator{err}
+	}
+	return newEntryIterator(ctx, GetReaderPool(b.enc), data, pipeline, b.format, b.symbolizer)
 }
 
 func (b encBlock) SampleIterator(ctx context.Context, extractor log.StreamSampleExtractor) iter.SampleIterator {
-	if len(b.b) == 0 {
+	if b.compressedLen() == 0 {
 		return iter.NoopIterator
 	}
-	return newSampleIterator(ctx, GetReaderPool(b.enc), b.b, b.format, extractor, b.symbolizer)
+	data, err := b.data()
+	if err != nil {
+		return errorIterator{err}
+	}
+	return newSampleIterator(ctx, GetReaderPool(b.enc), data, b.format, extractor, b.symbolizer)
 }
 
 func (b block) Offset() int {
@@ -0,0 +1,71 @@
+package chunkenc
+
+import (
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// BlockLoader returns the data of a block spilled out of memory.
+type BlockLoader func() ([]byte, error)
+
+// SpillBlocks moves the data of the cut blocks out of memory. spill stores the
+// data of a block and returns the loader reading it back, which is called
+// every time the block is read. The head block is left in memory.
+// It returns the number of bytes released.
+func (c *MemChunk) SpillBlocks(spill func(b []byte) (BlockLoader, error)) (int, error) {
+	released := 0
+	for i := range c.blocks {
+		b := &c.blocks[i]
+		if b.load != nil || len(b.b) == 0 {
+			continue
+		}
+		load, err := spill(b.b)
+		if err != nil {
+			return released, err
+		}
+		released += len(b.b)
+		b.size, b.b, b.load = len(b.b), nil, load
+	}
+	return released, nil
+}
+
+// ResidentBlocksSize returns the size of the data of the cut blocks held in
+// memory.
+func (c *MemChunk) ResidentBlocksSize() int {
+	size := 0
+	for _, b := range c.blocks {
+		size += len(b.b)
+	}
+	return size
+}
+
+// HeadBlockSize returns the uncompressed size of the head block.
+func (c *MemChunk) HeadBlockSize() int {
+	return c.head.UncompressedSize()
+}
+
+func (b block) data() ([]byte, error) {
+	if b.load == nil {
+		return b.b, nil
+	}
+	return b.load()
+}
+
+func (b block) compressedLen() int {
+	if b.load == nil {
+		return len(b.b)
+	}
+	return b.size
+}
+
+// errorIterator is returned for the blocks that failed to load.
+type errorIterator struct {
+	err error
+}
+
+func (errorIterator) Next() bool              { return false }
+func (it errorIterator) Error() error         { return it.err }
+func (errorIterator) Labels() string          { return "" }
+func (errorIterator) StreamHash() uint64      { return 0 }
+func (errorIterator) Entry() logproto.Entry   { return logproto.Entry{} }
+func (errorIterator) Sample() logproto.Sample { return logproto.Sample{} }
+func (errorIterator) Close() error            { return nil }
@@ -0,0 +1,80 @@
+package chunkenc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+)
+
+func TestMemChunk_SpillBlocks(t *testing.T) {
+	from := time.Unix(1, 0)
+	chk := buildTestMemChunk(t, from, from.Add(time.Hour))
+	require.NoError(t, chk.Close())
+
+	want, err := chk.Bytes()
+	require.NoError(t, err)
+	blocksSize := chk.ResidentBlocksSize()
+	require.Greater(t, blocksSize, 0)
+
+	var spilled [][]byte
+	released, err := chk.SpillBlocks(func(b []byte) (BlockLoader, error) {
+		i := len(spilled)
+		spilled = append(spilled, append([]byte(nil), b...))
+		return func() ([]byte, error) { return spilled[i], nil }, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, blocksSize, released)
+	require.Equal(t, 0, chk.ResidentBlocksSize())
+
+	// Spilling again is a no-op.
+	released, err = chk.SpillBlocks(func([]byte) (BlockLoader, error) {
+		return nil, errors.New("spilled twice")
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, released)
+
+	// The chunk encodes and reads the same.
+	got, err := chk.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	it, err := chk.Iterator(context.Background(), from, from.Add(time.Hour), logproto.FORWARD, log.NewNoopPipeline().ForStream(nil))
+	require.NoError(t, err)
+	entries := 0
+	for it.Next() {
+		require.Equal(t, from.Add(time.Duration(entries)*time.Second).String(), it.Entry().Line)
+		entries++
+	}
+	require.NoError(t, it.Error())
+	require.Equal(t, 3600, entries)
+
+	// Appending keeps working on a chunk with spilled blocks.
+	require.NoError(t, chk.Append(&logproto.Entry{Timestamp: from.Add(time.Hour), Line: "appended"}))
+	require.Greater(t, chk.UncompressedSize(), 0)
+}
+
+func TestMemChunk_SpillBlocksLoadError(t *testing.T) {
+	from := time.Unix(1, 0)
+	chk := buildTestMemChunk(t, from, from.Add(time.Minute))
+	require.NoError(t, chk.Close())
+
+	loadErr := errors.New("load failed")
+	_, err := chk.SpillBlocks(func([]byte) (BlockLoader, error) {
+		return func() ([]byte, error) { return nil, loadErr }, nil
+	})
+	require.NoError(t, err)
+
+	it, err := chk.Iterator(context.Background(), from, from.Add(time.Minute), logproto.FORWARD, log.NewNoopPipeline().ForStream(nil))
+	require.NoError(t, err)
+	require.False(t, it.Next())
+	require.ErrorIs(t, it.Error(), loadErr)
+
+	_, err = chk.Bytes()
+	require.ErrorIs(t, err, loadErr)
+}
@@ -18,10 +18,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/loki/v3/pkg/analytics"
 	"github.com/grafana/loki/v3/pkg/compactor/deletion"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compactor/rollup"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
 	chunk_util "github.com/grafana/loki/v3/pkg/storage/chunk/client/util"
@@ -77,6 +79,8 @@ type Config struct {
 	RetentionDeleteDelay        time.Duration       `yaml:"retention_delete_delay"`
 	RetentionDeleteWorkCount    int                 `yaml:"retention_delete_worker_count"`
 	RetentionTableTimeout       time.Duration       `yaml:"retention_table_timeout"`
+	RetentionRollupsEnabled     bool                `yaml:"retention_rollups_enabled"`
+	RetentionRollupsKeyPrefix   string              `yaml:"retention_rollups_key_prefix"`
 	DeleteRequestStore          string              `yaml:"delete_request_store"`
 	DeleteRequestStoreKeyPrefix string              `yaml:"delete_request_store_key_prefix"`
 	DeleteBatchSize             int                 `yaml:"delete_batch_size"`
@@ -104,6 +108,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.DeleteRequestCancelPeriod, "compactor.delete-request-cancel-period", 24*time.Hour, "Allow cancellation of delete request until duration after they are created. Data would be deleted only after delete requests have been older than this duration. Ideally this should be set to at least 24h.")
 	f.DurationVar(&cfg.DeleteMaxInterval, "compactor.delete-max-interval", 24*time.Hour, "Constrain the size of any single delete request with line filters. When a delete request > delete_max_interval is input, the request is sharded into smaller requests of no more than delete_max_interval")
 	f.DurationVar(&cfg.RetentionTableTimeout, "compactor.retention-table-timeout", 0, "The maximum amount of time to spend running retention and deletion on any given table in the index.")
+	f.BoolVar(&cfg.RetentionRollupsEnabled, "compactor.retention-rollups-enabled", false, "Compute the retention_rollups configured for the tenants from the chunks deleted by retention, and store them in the delete request store. The query frontend answers the matching range metric queries from them for the deleted data.")
+	f.StringVar(&cfg.RetentionRollupsKeyPrefix, "compactor.retention-rollups-key-prefix", "rollups/", "Path prefix for storing retention rollups in the delete request store.")
 	f.IntVar(&cfg.MaxCompactionParallelism, "compactor.max-compaction-parallelism", 1, "Maximum number of tables to compact in parallel. While increasing this value, please make sure compactor has enough disk space allocated to be able to store and compact as many tables.")
 	f.IntVar(&cfg.UploadParallelism, "compactor.upload-parallelism", 10, "Number of upload/remove operations to execute in parallel when finalizing a compaction. NOTE: This setting is per compaction operation, which can be executed in parallel. The upper bound on the number of concurrent uploads is upload_parallelism * max_compaction_parallelism.")
 	f.BoolVar(&cfg.RunOnce, "compactor.run-once", false, "Run the compactor one time to cleanup and compact index files only (no retention applied)")
@@ -151,6 +157,12 @@ func (cfg *Config) Validate() error {
 		if err := config.ValidatePathPrefix(cfg.DeleteRequestStoreKeyPrefix); err != nil {
 			return fmt.Errorf("validate delete store path prefix: %w", err)
 		}
+
+		if cfg.RetentionRollupsEnabled {
+			if err := config.ValidatePathPrefix(cfg.RetentionRollupsKeyPrefix); err != nil {
+				return fmt.Errorf("validate retention rollups path prefix: %w", err)
+			}
+		}
 	}
 
 	return nil
@@ -168,6 +180,8 @@ type Compactor struct {
 	DeleteRequestsGRPCHandler *deletion.GRPCRequestHandler
 	deleteRequestsManager     *deletion.DeleteRequestsManager
 	expirationChecker         retention.ExpirationChecker
+	rollupStore               *rollup.Store
+	limits                    Limits
 	metrics                   *metrics
 	running                   bool
 	wg                        sync.WaitGroup
@@ -198,6 +212,7 @@ type Limits interface {
 	deletion.Limits
 	retention.Limits
 	DefaultLimits() *validation.Limits
+	RetentionRollups(userID string) []validation.RetentionRollup
 }
 
 func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.ObjectClient, deleteStoreClient client.ObjectClient, schemaConfig config.SchemaConfig, limits Limits, r prometheus.Registerer, metricsNamespace string) (*Compactor, error) {
@@ -278,6 +293,11 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 		if err := c.initDeletes(deleteStoreClient, r, limits); err != nil {
 			return fmt.Errorf("failed to init delete store: %w", err)
 		}
+
+		if c.cfg.RetentionRollupsEnabled {
+			c.rollupStore = rollup.NewStore(deleteStoreClient, c.cfg.RetentionRollupsKeyPrefix)
+			c.limits = limits
+		}
 	}
 
 	legacyMarkerDirs := make(map[string]struct{})
@@ -328,7 +348,12 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 				return fmt.Errorf("failed to init sweeper: %w", err)
 			}
 
-			sc.tableMarker, err = retention.NewMarker(retentionWorkDir, c.expirationChecker, c.cfg.RetentionTableTimeout, chunkClient, r)
+			var rollups retention.ChunkRollups
+			if c.rollupStore != nil {
+				rollups = rollup.NewBuilder(c.rollupStore, chunkClient, limits, r)
+			}
+
+			sc.tableMarker, err = retention.NewMarker(retentionWorkDir, c.expirationChecker, c.cfg.RetentionTableTimeout, chunkClient, rollups, r)
 			if err != nil {
 				return fmt.Errorf("failed to init table marker: %w", err)
 			}
@@ -356,7 +381,8 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 
 func (c *Compactor) initDeletes(objectClient client.ObjectClient, r prometheus.Registerer, limits Limits) error {
 	deletionWorkDir := filepath.Join(c.cfg.WorkingDirectory, "deletion")
-	store, err := deletion.NewDeleteStore(deletionWorkDir, storage.NewIndexStorageClient(objectClient, c.cfg.DeleteRequestStoreKeyPrefix))
+	indexStorageClient := storage.NewIndexStorageClient(objectClient, c.cfg.DeleteRequestStoreKeyPrefix)
+	store, err := deletion.NewDeleteStore(deletionWorkDir, indexStorageClient)
 	if err != nil {
 		return err
 	}
@@ -378,7 +404,8 @@ func (c *Compactor) initDeletes(objectClient client.ObjectClient, r prometheus.R
 		r,
 	)
 
-	c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits), c.deleteRequestsManager)
+	lineCheckpoints := deletion.NewLineRetentionCheckpoints(indexStorageClient)
+	c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits, lineCheckpoints, r), c.deleteRequestsManager)
 	return nil
 }
 
@@ -805,6 +832,12 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) (err
 		return firstErr
 	}
 
+	if applyRetention && c.rollupStore != nil {
+		if err := c.rollupStore.Sweep(ctx, c.limits, model.Now()); err != nil {
+			return fmt.Errorf("failed to delete expired retention rollups: %w", err)
+		}
+	}
+
 	return ctx.Err()
 }
 
@@ -818,11 +851,22 @@ func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retentio
 }
 
 func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (bool, filter.Func) {
-	if expired, nonDeletedIntervals := e.retentionExpiryChecker.Expired(ref, now); expired {
-		return expired, nonDeletedIntervals
+	expired, retentionFilter := e.retentionExpiryChecker.Expired(ref, now)
+	if expired && retentionFilter == nil {
+		return true, nil
 	}
 
-	return e.deletionExpiryChecker.Expired(ref, now)
+	// the retention only deletes some lines of the chunk, so the delete requests still need to be applied to it.
+	deleted, deletionFilter := e.deletionExpiryChecker.Expired(ref, now)
+	if !deleted {
+		return expired, retentionFilter
+	}
+	if !expired || deletionFilter == nil {
+		return true, deletionFilter
+	}
+	return true, func(ts time.Time, s string, structuredMetadata ...labels.Label) bool {
+		return retentionFilter(ts, s, structuredMetadata...) || deletionFilter(ts, s, structuredMetadata...)
+	}
 }
 
 func (e *expirationChecker) MarkPhaseStarted() {
@@ -845,6 +889,11 @@ func (e *expirationChecker) MarkPhaseTimedOut() {
 	e.deletionExpiryChecker.MarkPhaseTimedOut()
 }
 
+func (e *expirationChecker) MarkChunkRewritten(ref retention.ChunkEntry) {
+	e.retentionExpiryChecker.MarkChunkRewritten(ref)
+	e.deletionExpiryChecker.MarkChunkRewritten(ref)
+}
+
 func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
 	return e.retentionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID) || e.deletionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID)
 }
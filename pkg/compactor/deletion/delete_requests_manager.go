@@ -321,6 +321,10 @@ func (d *DeleteRequestsManager) MarkPhaseTimedOut() {
 	d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
 }
 
+// MarkChunkRewritten is a no-op, the lines deleted by the delete requests are
+// counted as they are filtered.
+func (d *DeleteRequestsManager) MarkChunkRewritten(_ retention.ChunkEntry) {}
+
 func (d *DeleteRequestsManager) markRequestAsProcessed(deleteRequest DeleteRequest) {
 	if err := d.deleteRequestsStore.UpdateStatus(context.Background(), deleteRequest, StatusProcessed); err != nil {
 		level.Error(util_log.Logger).Log(
@@ -0,0 +1,40 @@
+package deletion
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
+)
+
+// lineRetentionCheckpointsFileName is the file of the checkpoints of the
+// retention rules filtering lines, stored in the table of the delete requests.
+const lineRetentionCheckpointsFileName = "line_retention_checkpoints.json"
+
+// LineRetentionCheckpoints stores the checkpoints of the retention rules
+// filtering lines next to the delete requests.
+type LineRetentionCheckpoints struct {
+	indexStorageClient storage.Client
+}
+
+func NewLineRetentionCheckpoints(indexStorageClient storage.Client) *LineRetentionCheckpoints {
+	return &LineRetentionCheckpoints{indexStorageClient: indexStorageClient}
+}
+
+func (c *LineRetentionCheckpoints) Get(ctx context.Context) ([]byte, error) {
+	r, err := c.indexStorageClient.GetFile(ctx, DeleteRequestsTableName, lineRetentionCheckpointsFileName)
+	if err != nil {
+		if c.indexStorageClient.IsFileNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (c *LineRetentionCheckpoints) Put(ctx context.Context, checkpoints []byte) error {
+	return c.indexStorageClient.PutFile(ctx, DeleteRequestsTableName, lineRetentionCheckpointsFileName, bytes.NewReader(checkpoints))
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 
@@ -28,12 +29,16 @@ type ExpirationChecker interface {
 	MarkPhaseFailed()
 	MarkPhaseTimedOut()
 	MarkPhaseFinished()
+	// MarkChunkRewritten is called once the chunk whose lines were filtered was
+	// rewritten and marked for deletion.
+	MarkChunkRewritten(ref ChunkEntry)
 	DropFromIndex(ref ChunkEntry, tableEndTime model.Time, now model.Time) bool
 }
 
 type expirationChecker struct {
 	tenantsRetention         *TenantsRetention
 	latestRetentionStartTime latestRetentionStartTime
+	lineRetention            *lineRetention
 }
 
 type Limits interface {
@@ -43,21 +48,26 @@ type Limits interface {
 	DefaultLimits() *validation.Limits
 }
 
-func NewExpirationChecker(limits Limits) ExpirationChecker {
+// NewExpirationChecker returns the expiration checker of the retention. The
+// checkpoints of the retention rules filtering lines are persisted to
+// lineCheckpoints, if it isn't nil.
+func NewExpirationChecker(limits Limits, lineCheckpoints LineRetentionCheckpoints, r prometheus.Registerer) ExpirationChecker {
 	return &expirationChecker{
 		tenantsRetention: NewTenantsRetention(limits),
+		lineRetention:    newLineRetention(lineCheckpoints, r),
 	}
 }
 
 // Expired tells if a ref chunk is expired based on retention rules.
+// It returns a filter.Func if only the lines of the chunk matching the rules filtering lines are expired.
 func (e *expirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, filter.Func) {
 	userID := unsafeGetString(ref.UserID)
 	period := e.tenantsRetention.RetentionPeriodFor(userID, ref.Labels)
 	// The 0 value should disable retention
-	if period <= 0 {
-		return false, nil
+	if period > 0 && now.Sub(ref.Through) > period {
+		return true, nil
 	}
-	return now.Sub(ref.Through) > period, nil
+	return e.lineRetention.expired(userID, ref, e.tenantsRetention.LineRetentionFor(userID, ref.Labels, period), now)
 }
 
 // DropFromIndex tells if it is okay to drop the chunk entry from index table.
@@ -74,14 +84,18 @@ func (e *expirationChecker) DropFromIndex(ref ChunkEntry, tableEndTime model.Tim
 }
 
 func (e *expirationChecker) MarkPhaseStarted() {
-	e.latestRetentionStartTime = findLatestRetentionStartTime(model.Now(), e.tenantsRetention.limits)
+	now := model.Now()
+	e.latestRetentionStartTime = findLatestRetentionStartTime(now, e.tenantsRetention.limits)
+	e.lineRetention.phaseStarted(now)
 	level.Info(util_log.Logger).Log("msg", fmt.Sprintf("overall smallest retention period %v, default smallest retention period %v",
 		e.latestRetentionStartTime.overall, e.latestRetentionStartTime.defaults))
 }
 
-func (e *expirationChecker) MarkPhaseFailed()   {}
-func (e *expirationChecker) MarkPhaseTimedOut() {}
-func (e *expirationChecker) MarkPhaseFinished() {}
+func (e *expirationChecker) MarkPhaseFailed()   { e.lineRetention.phaseFailed() }
+func (e *expirationChecker) MarkPhaseTimedOut() { e.lineRetention.phaseTimedOut() }
+func (e *expirationChecker) MarkPhaseFinished() { e.lineRetention.phaseFinished() }
+
+func (e *expirationChecker) MarkChunkRewritten(ref ChunkEntry) { e.lineRetention.chunkRewritten(ref) }
 
 func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
 	// when userID is empty, it means we are checking for common index table. In this case we use e.overallLatestRetentionStartTime.
@@ -117,6 +131,9 @@ func (e *neverExpiringExpirationChecker) MarkPhaseStarted()  {}
 func (e *neverExpiringExpirationChecker) MarkPhaseFailed()   {}
 func (e *neverExpiringExpirationChecker) MarkPhaseTimedOut() {}
 func (e *neverExpiringExpirationChecker) MarkPhaseFinished() {}
+
+func (e *neverExpiringExpirationChecker) MarkChunkRewritten(_ ChunkEntry) {}
+
 func (e *neverExpiringExpirationChecker) DropFromIndex(_ ChunkEntry, _ model.Time, _ model.Time) bool {
 	return false
 }
@@ -140,6 +157,10 @@ func (tr *TenantsRetention) RetentionPeriodFor(userID string, lbs labels.Labels)
 	)
 Outer:
 	for _, streamRetention := range streamRetentions {
+		// rules filtering lines don't apply to whole streams.
+		if streamRetention.Filter != nil {
+			continue
+		}
 		for _, m := range streamRetention.Matchers {
 			if !m.Matches(lbs.Get(m.Name)) {
 				continue Outer
@@ -165,6 +186,25 @@ Outer:
 	return globalRetention
 }
 
+// LineRetentionFor returns the retention rules filtering lines which match the
+// stream and are shorter than its retention period.
+func (tr *TenantsRetention) LineRetentionFor(userID string, lbs labels.Labels, streamPeriod time.Duration) []validation.StreamRetention {
+	var rules []validation.StreamRetention
+Outer:
+	for _, streamRetention := range tr.limits.StreamRetention(userID) {
+		if streamRetention.Filter == nil || (streamPeriod > 0 && time.Duration(streamRetention.Period) >= streamPeriod) {
+			continue
+		}
+		for _, m := range streamRetention.Matchers {
+			if !m.Matches(lbs.Get(m.Name)) {
+				continue Outer
+			}
+		}
+		rules = append(rules, streamRetention)
+	}
+	return rules
+}
+
 type latestRetentionStartTime struct {
 	// defaults holds latest retention start time considering only default retention config.
 	// It is used to determine if user index table may have any expired chunks when the user does not have any custom retention config set.
@@ -1,10 +1,12 @@
 package retention
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
@@ -101,7 +103,7 @@ func Test_expirationChecker_Expired(t *testing.T) {
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
 
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -139,7 +141,7 @@ func Test_expirationChecker_Expired_zeroValue(t *testing.T) {
 	}
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -185,7 +187,7 @@ func Test_expirationChecker_Expired_zeroValueOverride(t *testing.T) {
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
 
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -204,6 +206,133 @@ func Test_expirationChecker_Expired_zeroValueOverride(t *testing.T) {
 	}
 }
 
+func Test_expirationChecker_Expired_lineRetention(t *testing.T) {
+	const debugSelector = `{app="foo"} | level="debug"`
+	tl := defaultLimitsTestConfig()
+	tl.RetentionPeriod = model.Duration(30 * 24 * time.Hour)
+	tl.StreamRetention = []validation.StreamRetention{
+		{Selector: debugSelector, Period: model.Duration(72 * time.Hour)},
+		// longer than the retention of the stream so it doesn't apply.
+		{Selector: `{app="foo"} |= "secret"`, Period: model.Duration(60 * 24 * time.Hour)},
+	}
+	require.NoError(t, tl.Validate())
+
+	o, err := overridesTestConfig(defaultLimitsTestConfig(), fakeOverrides{tenantLimits: map[string]*validation.Limits{"1": &tl}})
+	require.NoError(t, err)
+
+	now := model.Now()
+	e := NewExpirationChecker(o, nil, nil)
+	for _, tc := range []struct {
+		name       string
+		ref        ChunkEntry
+		want       bool
+		wantFilter bool
+	}{
+		{"stream expired", newChunkEntry("1", `{app="foo"}`, now.Add(-32*24*time.Hour), now.Add(-31*24*time.Hour)), true, false},
+		{"lines expired", newChunkEntry("1", `{app="foo"}`, now.Add(-5*24*time.Hour), now.Add(-4*24*time.Hour)), true, true},
+		{"lines not expired", newChunkEntry("1", `{app="foo"}`, now.Add(-3*24*time.Hour), now.Add(-2*24*time.Hour)), false, false},
+		{"stream not matching", newChunkEntry("1", `{app="bar"}`, now.Add(-5*24*time.Hour), now.Add(-4*24*time.Hour)), false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, filterFunc := e.Expired(tc.ref, now)
+			require.Equal(t, tc.want, actual)
+			require.Equal(t, tc.wantFilter, filterFunc != nil)
+		})
+	}
+
+	ref := newChunkEntry("1", `{app="foo"}`, now.Add(-5*24*time.Hour), now.Add(-4*24*time.Hour))
+	_, filterFunc := e.Expired(ref, now)
+	require.True(t, filterFunc(now.Time(), "debug line", labels.Label{Name: "level", Value: "debug"}))
+	require.False(t, filterFunc(now.Time(), "secret line", labels.Label{Name: "level", Value: "info"}))
+
+	// the deleted lines are counted only once the chunk is rewritten.
+	deletedLines := e.(*expirationChecker).lineRetention.metrics.deletedLinesTotal.WithLabelValues("1", debugSelector)
+	deletedBytes := e.(*expirationChecker).lineRetention.metrics.deletedBytesTotal.WithLabelValues("1", debugSelector)
+	require.Equal(t, float64(0), testutil.ToFloat64(deletedLines))
+	require.Equal(t, float64(0), testutil.ToFloat64(deletedBytes))
+	e.MarkChunkRewritten(ref)
+	require.Equal(t, float64(1), testutil.ToFloat64(deletedLines))
+	require.Equal(t, float64(len("debug line")+len("level")+len("debug")), testutil.ToFloat64(deletedBytes))
+}
+
+type fakeLineRetentionCheckpoints struct {
+	data []byte
+	puts int
+}
+
+func (f *fakeLineRetentionCheckpoints) Get(_ context.Context) ([]byte, error) {
+	return f.data, nil
+}
+
+func (f *fakeLineRetentionCheckpoints) Put(_ context.Context, checkpoints []byte) error {
+	f.data = checkpoints
+	f.puts++
+	return nil
+}
+
+func Test_expirationChecker_Expired_lineRetentionPersistedCheckpoint(t *testing.T) {
+	tl := defaultLimitsTestConfig()
+	tl.RetentionPeriod = model.Duration(30 * 24 * time.Hour)
+	tl.StreamRetention = []validation.StreamRetention{
+		{Selector: `{app="foo"} |= "debug"`, Period: model.Duration(72 * time.Hour)},
+	}
+	require.NoError(t, tl.Validate())
+	o := fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: time.Duration(tl.RetentionPeriod), streamRetention: tl.StreamRetention}}}
+
+	filtered := newChunkEntry("1", `{app="foo"}`, model.Now().Add(-5*24*time.Hour), model.Now().Add(-4*24*time.Hour))
+	checkpoints := &fakeLineRetentionCheckpoints{}
+
+	e := NewExpirationChecker(o, checkpoints, nil)
+	e.MarkPhaseStarted()
+	expired, _ := e.Expired(filtered, model.Now())
+	require.True(t, expired)
+	e.MarkPhaseFinished()
+	require.Equal(t, 1, checkpoints.puts)
+
+	// a restarted compactor doesn't filter the chunks again.
+	e = NewExpirationChecker(o, checkpoints, nil)
+	e.MarkPhaseStarted()
+	expired, _ = e.Expired(filtered, model.Now())
+	require.False(t, expired)
+	e.MarkPhaseFinished()
+	require.Equal(t, 2, checkpoints.puts)
+}
+
+func Test_expirationChecker_Expired_lineRetentionCheckpoint(t *testing.T) {
+	tl := defaultLimitsTestConfig()
+	tl.RetentionPeriod = model.Duration(30 * 24 * time.Hour)
+	tl.StreamRetention = []validation.StreamRetention{
+		{Selector: `{app="foo"} |= "debug"`, Period: model.Duration(72 * time.Hour)},
+	}
+	require.NoError(t, tl.Validate())
+	o := fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: time.Duration(tl.RetentionPeriod), streamRetention: tl.StreamRetention}}}
+
+	filtered := newChunkEntry("1", `{app="foo"}`, model.Now().Add(-5*24*time.Hour), model.Now().Add(-4*24*time.Hour))
+	notYetExpired := newChunkEntry("1", `{app="foo"}`, model.Now().Add(-72*time.Hour), model.Now().Add(-72*time.Hour+30*time.Minute))
+
+	// a timed out phase doesn't checkpoint the rules.
+	e := NewExpirationChecker(o, nil, nil)
+	e.MarkPhaseStarted()
+	expired, _ := e.Expired(filtered, model.Now())
+	require.True(t, expired)
+	e.MarkPhaseTimedOut()
+	e.MarkPhaseFinished()
+	expired, _ = e.Expired(filtered, model.Now())
+	require.True(t, expired)
+
+	// the chunks filtered by a finished phase are not filtered again.
+	e.MarkPhaseStarted()
+	expired, _ = e.Expired(filtered, model.Now())
+	require.True(t, expired)
+	e.MarkPhaseFinished()
+	expired, _ = e.Expired(filtered, model.Now())
+	require.False(t, expired)
+
+	// the chunks which expired since are filtered.
+	expired, _ = e.Expired(notYetExpired, model.Now().Add(time.Hour))
+	require.True(t, expired)
+}
+
 func Test_expirationChecker_DropFromIndex_zeroValue(t *testing.T) {
 	// Default retention should be zero
 	d := defaultLimitsTestConfig()
@@ -219,7 +348,7 @@ func Test_expirationChecker_DropFromIndex_zeroValue(t *testing.T) {
 	}
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil, nil)
 
 	chunkFrom := model.Now().Add(-3 * time.Hour)
 	chunkThrough := model.Now().Add(-2 * time.Hour)
@@ -0,0 +1,284 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/util/filter"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type lineRetentionMetrics struct {
+	deletedLinesTotal *prometheus.CounterVec
+	deletedBytesTotal *prometheus.CounterVec
+}
+
+func newLineRetentionMetrics(r prometheus.Registerer) *lineRetentionMetrics {
+	return &lineRetentionMetrics{
+		deletedLinesTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "retention_line_rule_deleted_lines_total",
+			Help:      "Number of log lines deleted from their chunks by the retention rules filtering lines.",
+		}, []string{"user", "rule"}),
+		deletedBytesTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "retention_line_rule_deleted_bytes_total",
+			Help:      "Bytes of the log lines and their structured metadata deleted from their chunks by the retention rules filtering lines.",
+		}, []string{"user", "rule"}),
+	}
+}
+
+// LineRetentionCheckpoints persists the checkpoints of the retention rules
+// filtering lines, so that the chunks already filtered aren't filtered again
+// once the compactor restarts.
+type LineRetentionCheckpoints interface {
+	// Get returns the checkpoints last put, nil if there are none.
+	Get(ctx context.Context) ([]byte, error)
+	Put(ctx context.Context, checkpoints []byte) error
+}
+
+// lineRuleKey identifies a retention rule filtering lines of a tenant.
+type lineRuleKey struct {
+	userID   string
+	selector string
+	period   model.Duration
+}
+
+// lineCheckpoint is the persisted checkpoint of a rule.
+type lineCheckpoint struct {
+	UserID     string         `json:"user"`
+	Selector   string         `json:"selector"`
+	Period     model.Duration `json:"period"`
+	Checkpoint model.Time     `json:"checkpoint"`
+}
+
+// lineDeletions counts the lines a rule deleted from a chunk.
+type lineDeletions struct {
+	deletedLines, deletedBytes prometheus.Counter
+	lines, bytes               int
+}
+
+// lineRetention deletes the lines of the chunks matching the retention rules
+// filtering lines once the chunks are older than the period of the rules.
+type lineRetention struct {
+	metrics *lineRetentionMetrics
+	storage LineRetentionCheckpoints
+
+	mtx sync.Mutex
+	// checkpoints holds, for each rule, the start of the last mark phase which
+	// finished with the rule configured. The chunks which were older than the
+	// period of the rule at that time are already filtered.
+	checkpoints map[lineRuleKey]model.Time
+	loaded      bool
+	phaseStart  model.Time
+	phaseRules  map[lineRuleKey]struct{}
+	timedOut    bool
+	// deletions holds the lines deleted by the filters of each chunk, by chunk
+	// ID, until the chunk is rewritten and the deleted lines are counted.
+	deletions map[string][]*lineDeletions
+}
+
+func newLineRetention(storage LineRetentionCheckpoints, r prometheus.Registerer) *lineRetention {
+	return &lineRetention{
+		metrics:     newLineRetentionMetrics(r),
+		storage:     storage,
+		checkpoints: map[lineRuleKey]model.Time{},
+		deletions:   map[string][]*lineDeletions{},
+	}
+}
+
+// expired returns a filter.Func deleting the lines of the chunk matching the
+// rules, if the chunk is older than the period of any of them and wasn't
+// filtered by a previous mark phase.
+func (l *lineRetention) expired(userID string, ref ChunkEntry, rules []validation.StreamRetention, now model.Time) (bool, filter.Func) {
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	var (
+		filters   []filter.Func
+		deletions []*lineDeletions
+	)
+	for _, rule := range rules {
+		key := lineRuleKey{userID: userID, selector: rule.Selector, period: rule.Period}
+		period := time.Duration(rule.Period)
+
+		l.mtx.Lock()
+		if l.phaseRules != nil {
+			l.phaseRules[key] = struct{}{}
+		}
+		checkpoint, ok := l.checkpoints[key]
+		l.mtx.Unlock()
+
+		if now.Sub(ref.Through) <= period || (ok && checkpoint.Sub(ref.Through) > period) {
+			continue
+		}
+
+		pipeline, err := rule.Filter.Pipeline()
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to build the pipeline of the retention rule", "user", userID, "selector", rule.Selector, "err", err)
+			continue
+		}
+		process := pipeline.ForStream(ref.Labels).ProcessString
+		deleted := &lineDeletions{
+			deletedLines: l.metrics.deletedLinesTotal.WithLabelValues(userID, rule.Selector),
+			deletedBytes: l.metrics.deletedBytesTotal.WithLabelValues(userID, rule.Selector),
+		}
+		deletions = append(deletions, deleted)
+		filters = append(filters, func(ts time.Time, s string, structuredMetadata ...labels.Label) bool {
+			if _, _, matches := process(ts.UnixNano(), s, structuredMetadata...); !matches {
+				return false
+			}
+			deleted.lines++
+			deleted.bytes += len(s)
+			for _, lbl := range structuredMetadata {
+				deleted.bytes += len(lbl.Name) + len(lbl.Value)
+			}
+			return true
+		})
+	}
+
+	if len(filters) == 0 {
+		return false, nil
+	}
+
+	l.mtx.Lock()
+	l.deletions[string(ref.ChunkID)] = deletions
+	l.mtx.Unlock()
+
+	return true, func(ts time.Time, s string, structuredMetadata ...labels.Label) bool {
+		for _, f := range filters {
+			if f(ts, s, structuredMetadata...) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// chunkRewritten counts the lines deleted from the chunk by its filters.
+func (l *lineRetention) chunkRewritten(ref ChunkEntry) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, d := range l.deletions[string(ref.ChunkID)] {
+		d.deletedLines.Add(float64(d.lines))
+		d.deletedBytes.Add(float64(d.bytes))
+	}
+	delete(l.deletions, string(ref.ChunkID))
+}
+
+func (l *lineRetention) phaseStarted(now model.Time) {
+	l.load()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.phaseStart = now
+	l.phaseRules = map[lineRuleKey]struct{}{}
+	l.timedOut = false
+}
+
+func (l *lineRetention) phaseFailed() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.phaseRules = nil
+	l.deletions = map[string][]*lineDeletions{}
+}
+
+func (l *lineRetention) phaseTimedOut() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.timedOut = true
+}
+
+// phaseFinished checkpoints the rules applied in the mark phase, unless some
+// tables timed out.
+func (l *lineRetention) phaseFinished() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	checkpointed := !l.timedOut && len(l.phaseRules) > 0
+	if !l.timedOut {
+		for key := range l.phaseRules {
+			l.checkpoints[key] = l.phaseStart
+		}
+	}
+	l.phaseRules = nil
+	l.deletions = map[string][]*lineDeletions{}
+
+	// The persisted checkpoints would be overwritten until they are loaded.
+	if checkpointed && l.loaded {
+		l.save()
+	}
+}
+
+// load reads the persisted checkpoints once. The checkpoints of the phases
+// which finished meanwhile are kept.
+func (l *lineRetention) load() {
+	if l.storage == nil {
+		return
+	}
+	l.mtx.Lock()
+	loaded := l.loaded
+	l.mtx.Unlock()
+	if loaded {
+		return
+	}
+
+	data, err := l.storage.Get(context.Background())
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to load the checkpoints of the retention rules filtering lines", "err", err)
+		return
+	}
+	var checkpoints []lineCheckpoint
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &checkpoints); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to decode the checkpoints of the retention rules filtering lines", "err", err)
+			return
+		}
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, c := range checkpoints {
+		key := lineRuleKey{userID: c.UserID, selector: c.Selector, period: c.Period}
+		if _, ok := l.checkpoints[key]; !ok {
+			l.checkpoints[key] = c.Checkpoint
+		}
+	}
+	l.loaded = true
+}
+
+// save persists the checkpoints, l.mtx must be held. A failure is only logged,
+// the rules are applied again to the chunks filtered since the checkpoints
+// last saved once the compactor restarts.
+func (l *lineRetention) save() {
+	if l.storage == nil {
+		return
+	}
+
+	checkpoints := make([]lineCheckpoint, 0, len(l.checkpoints))
+	for key, checkpoint := range l.checkpoints {
+		checkpoints = append(checkpoints, lineCheckpoint{UserID: key.userID, Selector: key.selector, Period: key.period, Checkpoint: checkpoint})
+	}
+	data, err := json.Marshal(checkpoints)
+	if err == nil {
+		err = l.storage.Put(context.Background(), data)
+	}
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to save the checkpoints of the retention rules filtering lines", "err", err)
+	}
+}
@@ -74,6 +74,20 @@ type IndexProcessor interface {
 	SeriesCleaner
 }
 
+// ChunkRollups computes rollups of the chunks deleted by retention.
+type ChunkRollups interface {
+	// ForTable returns the rollups of the chunks of the table.
+	ForTable(tableName, userID string) TableRollups
+}
+
+type TableRollups interface {
+	// Add adds the chunk to the rollups if it was expired by retention. It is
+	// called for the chunks deleted from the last table indexing them.
+	Add(ctx context.Context, chunk ChunkEntry, now model.Time) error
+	// Flush stores the rollups once all the chunks of the table were added.
+	Flush(ctx context.Context) error
+}
+
 var errNoChunksFound = errors.New("no chunks found in table, please check if there are really no chunks and manually drop the table or " +
 	"see if there is a bug causing us to drop whole index table")
 
@@ -88,15 +102,17 @@ type Marker struct {
 	markerMetrics    *markerMetrics
 	chunkClient      client.Client
 	markTimeout      time.Duration
+	rollups          ChunkRollups
 }
 
-func NewMarker(workingDirectory string, expiration ExpirationChecker, markTimeout time.Duration, chunkClient client.Client, r prometheus.Registerer) (*Marker, error) {
+func NewMarker(workingDirectory string, expiration ExpirationChecker, markTimeout time.Duration, chunkClient client.Client, rollups ChunkRollups, r prometheus.Registerer) (*Marker, error) {
 	return &Marker{
 		workingDirectory: workingDirectory,
 		expiration:       expiration,
 		markerMetrics:    newMarkerMetrics(r),
 		chunkClient:      chunkClient,
 		markTimeout:      markTimeout,
+		rollups:          rollups,
 	}, nil
 }
 
@@ -130,10 +146,23 @@ func (t *Marker) markTable(ctx context.Context, tableName, userID string, indexP
 
 	chunkRewriter := newChunkRewriter(t.chunkClient, tableName, indexProcessor)
 
-	empty, modified, err := markForDelete(ctx, t.markTimeout, tableName, markerWriter, indexProcessor, t.expiration, chunkRewriter, logger)
+	var rollups TableRollups
+	if t.rollups != nil {
+		rollups = t.rollups.ForTable(tableName, userID)
+	}
+
+	empty, modified, err := markForDelete(ctx, t.markTimeout, tableName, markerWriter, indexProcessor, t.expiration, chunkRewriter, rollups, logger)
 	if err != nil {
 		return false, false, err
 	}
+	if rollups != nil {
+		// The rollups are stored before the index without the deleted chunks is uploaded, so that
+		// the chunks are marked again rather than lost if the compaction fails. The store records
+		// the chunks rolled up, so they are not rolled up twice.
+		if err := rollups.Flush(ctx); err != nil {
+			return false, false, fmt.Errorf("failed to store retention rollups: %w", err)
+		}
+	}
 
 	t.markerMetrics.tableMarksCreatedTotal.WithLabelValues(tableName).Add(float64(markerWriter.Count()))
 	if err := markerWriter.Close(); err != nil {
@@ -160,6 +189,7 @@ func markForDelete(
 	indexFile IndexProcessor,
 	expiration ExpirationChecker,
 	chunkRewriter *chunkRewriter,
+	rollups TableRollups,
 	logger log.Logger,
 ) (bool, bool, error) {
 	seriesMap := newUserSeriesMap()
@@ -200,6 +230,14 @@ func markForDelete(
 			if linesDeleted {
 				modified = true
 
+				// Chunks are expired by retention at once in all the tables indexing them, so a deleted chunk is rolled
+				// up only from the table indexing its end, which is the last one to still index it.
+				if rollups != nil && filterFunc == nil && c.Through <= tableInterval.End {
+					if err := rollups.Add(ctx, c, now); err != nil {
+						return false, fmt.Errorf("failed to roll up chunk %s with error %s", c.ChunkID, err)
+					}
+				}
+
 				// Mark the chunk for deletion only if it is completely deleted, or this is the last table that the chunk is index in.
 				// For a partially deleted chunk, if we delete the source chunk before all the tables which index it are processed then
 				// the retention would fail because it would fail to find it in the storage.
@@ -207,6 +245,9 @@ func markForDelete(
 					if err := marker.Put(c.ChunkID); err != nil {
 						return false, err
 					}
+					if filterFunc != nil {
+						expiration.MarkChunkRewritten(c)
+					}
 				}
 				return true, nil
 			}
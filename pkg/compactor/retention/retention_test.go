@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -141,7 +142,7 @@ func Test_Retention(t *testing.T) {
 			store.Stop()
 
 			// marks and sweep
-			expiration := NewExpirationChecker(tt.limits)
+			expiration := NewExpirationChecker(tt.limits, nil, nil)
 			workDir := filepath.Join(t.TempDir(), "retention")
 			chunkClient := &mockChunkClient{deletedChunks: map[string]struct{}{}}
 			sweep, err := NewSweeper(workDir, chunkClient, 10, 0, nil)
@@ -149,7 +150,7 @@ func Test_Retention(t *testing.T) {
 			sweep.Start()
 			defer sweep.Stop()
 
-			marker, err := NewMarker(workDir, expiration, time.Hour, nil, prometheus.NewRegistry())
+			marker, err := NewMarker(workDir, expiration, time.Hour, nil, nil, prometheus.NewRegistry())
 			require.NoError(t, err)
 			for _, table := range store.indexTables() {
 				_, _, err := marker.MarkForDelete(context.Background(), table.name, "", table, util_log.Logger)
@@ -202,11 +203,11 @@ func Test_EmptyTable(t *testing.T) {
 	tables := store.indexTables()
 	require.Len(t, tables, 1)
 	// Set a very low retention to make sure all chunks are marked for deletion which will create an empty table.
-	empty, _, err := markForDelete(context.Background(), 0, tables[0].name, &noopWriter{}, tables[0], NewExpirationChecker(&fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: time.Second}, "2": {retentionPeriod: time.Second}}}), nil, util_log.Logger)
+	empty, _, err := markForDelete(context.Background(), 0, tables[0].name, &noopWriter{}, tables[0], NewExpirationChecker(&fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: time.Second}, "2": {retentionPeriod: time.Second}}}, nil, nil), nil, nil, util_log.Logger)
 	require.NoError(t, err)
 	require.True(t, empty)
 
-	_, _, err = markForDelete(context.Background(), 0, tables[0].name, &noopWriter{}, newTable("test"), NewExpirationChecker(&fakeLimits{}), nil, util_log.Logger)
+	_, _, err = markForDelete(context.Background(), 0, tables[0].name, &noopWriter{}, newTable("test"), NewExpirationChecker(&fakeLimits{}, nil, nil), nil, nil, util_log.Logger)
 	require.Equal(t, err, errNoChunksFound)
 }
 
@@ -624,6 +625,8 @@ func (m *mockExpirationChecker) MarkPhaseTimedOut() {
 	m.timedOut = true
 }
 
+func (m *mockExpirationChecker) MarkChunkRewritten(_ ChunkEntry) {}
+
 func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 	now := model.Now()
 	schema := allSchemas[2]
@@ -878,7 +881,7 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 
 				cr := newChunkRewriter(store.chunkClient, table.name, table)
 				marker := &noopWriter{}
-				empty, isModified, err := markForDelete(context.Background(), 0, table.name, marker, seriesCleanRecorder, expirationChecker, cr, util_log.Logger)
+				empty, isModified, err := markForDelete(context.Background(), 0, table.name, marker, seriesCleanRecorder, expirationChecker, cr, nil, util_log.Logger)
 				require.NoError(t, err)
 				require.Equal(t, tc.expectedEmpty[i], empty)
 				require.Equal(t, tc.expectedModified[i], isModified)
@@ -920,6 +923,7 @@ func TestDeleteTimeout(t *testing.T) {
 			newSeriesCleanRecorder(table),
 			expirationChecker,
 			newChunkRewriter(store.chunkClient, table.name, table),
+			nil,
 			util_log.Logger,
 		)
 
@@ -958,7 +962,7 @@ func TestMarkForDelete_DropChunkFromIndex(t *testing.T) {
 
 	for i, table := range tables {
 		empty, _, err := markForDelete(context.Background(), 0, table.name, &noopWriter{}, table,
-			NewExpirationChecker(fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: retentionPeriod}}}), nil, util_log.Logger)
+			NewExpirationChecker(fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: retentionPeriod}}}, nil, nil), nil, nil, util_log.Logger)
 		require.NoError(t, err)
 		if i == 7 {
 			require.False(t, empty)
@@ -977,6 +981,40 @@ func TestMarkForDelete_DropChunkFromIndex(t *testing.T) {
 	require.False(t, store.HasChunk(c5))
 }
 
+func TestMarkForDelete_LineRetention(t *testing.T) {
+	schema := allSchemas[3]
+	store := newTestStore(t)
+	now := model.Now()
+	from := ExtractIntervalFromTableName(schema.config.IndexTables.TableFor(now.Add(-5 * 24 * time.Hour))).Start.Add(time.Hour)
+	through := from.Add(10 * time.Minute)
+	c := createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "1"}}, from, through)
+	require.NoError(t, store.Put(context.TODO(), []chunk.Chunk{c}))
+	store.Stop()
+
+	// delete the first line, which holds its timestamp.
+	limits := defaultLimitsTestConfig()
+	limits.StreamRetention = []validation.StreamRetention{
+		{Selector: fmt.Sprintf(`{foo="1"} |= "%s"`, from.String()), Period: model.Duration(72 * time.Hour)},
+	}
+	require.NoError(t, limits.Validate())
+	expiration := NewExpirationChecker(fakeLimits{perTenant: map[string]retentionLimit{"1": {retentionPeriod: 30 * 24 * time.Hour, streamRetention: limits.StreamRetention}}}, nil, nil)
+
+	tables := store.indexTables()
+	require.Len(t, tables, 1)
+	rewritten := newTable(tables[0].name)
+	empty, modified, err := markForDelete(context.Background(), 0, tables[0].name, &noopWriter{}, tables[0], expiration,
+		newChunkRewriter(store.chunkClient, tables[0].name, rewritten), nil, util_log.Logger)
+	require.NoError(t, err)
+	require.False(t, empty)
+	require.True(t, modified)
+	require.Empty(t, tables[0].GetChunks("1", from, through, c.Metric))
+
+	chunks := rewritten.GetChunks("1", from, through, c.Metric)
+	require.Len(t, chunks, 1)
+	require.Equal(t, from.Add(time.Minute), chunks[0].From)
+	require.Equal(t, through, chunks[0].Through)
+}
+
 func TestMigrateMarkers(t *testing.T) {
 	t.Run("nothing to migrate", func(t *testing.T) {
 		workDir := t.TempDir()
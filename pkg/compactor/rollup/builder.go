@@ -0,0 +1,274 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type Limits interface {
+	retention.Limits
+	RetentionRollups(userID string) []validation.RetentionRollup
+}
+
+type builderMetrics struct {
+	chunksRolledUp prometheus.Counter
+	linesRolledUp  prometheus.Counter
+	objectsWritten prometheus.Counter
+}
+
+func newBuilderMetrics(r prometheus.Registerer) *builderMetrics {
+	return &builderMetrics{
+		chunksRolledUp: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "retention_rollup_chunks_total",
+			Help:      "Number of chunks deleted by retention whose lines were added to retention rollups.",
+		}),
+		linesRolledUp: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "retention_rollup_lines_total",
+			Help:      "Number of lines deleted by retention which were added to retention rollups.",
+		}),
+		objectsWritten: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "retention_rollup_objects_written_total",
+			Help:      "Number of retention rollup objects written to the store.",
+		}),
+	}
+}
+
+// Builder computes the rollups of the chunks deleted by retention, before the
+// retention deletes them, with the rules configured for their tenant.
+type Builder struct {
+	store       *Store
+	chunkClient client.Client
+	limits      Limits
+	retention   *retention.TenantsRetention
+	metrics     *builderMetrics
+}
+
+// NewBuilder returns a builder of the rollups of the chunks of chunkClient.
+func NewBuilder(store *Store, chunkClient client.Client, limits Limits, r prometheus.Registerer) *Builder {
+	return &Builder{
+		store:       store,
+		chunkClient: chunkClient,
+		limits:      limits,
+		retention:   retention.NewTenantsRetention(limits),
+		metrics:     newBuilderMetrics(r),
+	}
+}
+
+// ForTable implements retention.ChunkRollups.
+func (b *Builder) ForTable(_, _ string) retention.TableRollups {
+	return newTableRollups(b)
+}
+
+// objectRef identifies a rollup object of the store.
+type objectRef struct {
+	tenant, rule string
+	day          int64
+}
+
+// tableRollups accumulates the rollups of the chunks of a table until they are
+// flushed to the store.
+type tableRollups struct {
+	builder *Builder
+	series  map[objectRef]seriesSet
+	// chunks holds the IDs of the chunks rolled up into each object, loaded
+	// from the store, and added holds those not flushed yet.
+	chunks map[objectRef]map[string]struct{}
+	added  map[objectRef][]string
+}
+
+func newTableRollups(b *Builder) *tableRollups {
+	return &tableRollups{
+		builder: b,
+		series:  map[objectRef]seriesSet{},
+		chunks:  map[objectRef]map[string]struct{}{},
+		added:   map[objectRef][]string{},
+	}
+}
+
+// Add implements retention.TableRollups. Chunks deleted by delete requests
+// rather than by retention are not rolled up.
+func (t *tableRollups) Add(ctx context.Context, c retention.ChunkEntry, now model.Time) error {
+	userID := string(c.UserID)
+	rules := MatchingRules(t.builder.limits.RetentionRollups(userID), c.Labels)
+	if len(rules) == 0 {
+		return nil
+	}
+	period := t.builder.retention.RetentionPeriodFor(userID, c.Labels)
+	if period <= 0 || now.Sub(c.Through) <= period {
+		return nil
+	}
+
+	chk, err := chunk.ParseExternalKey(userID, string(c.ChunkID))
+	if err != nil {
+		return err
+	}
+	chks, err := t.builder.chunkClient.GetChunks(ctx, []chunk.Chunk{chk})
+	if err != nil {
+		return err
+	}
+	if len(chks) != 1 {
+		return fmt.Errorf("expected 1 entry for chunk %s but found %d in storage", c.ChunkID, len(chks))
+	}
+	facade, ok := chks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return fmt.Errorf("invalid chunk type")
+	}
+
+	it, err := facade.LokiChunk().Iterator(ctx, c.From.Time(), c.Through.Time().Add(time.Millisecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(c.Labels))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	return t.rollUp(ctx, string(c.ChunkID), userID, rules, c.Labels, it)
+}
+
+// rollUp adds the lines of the chunk to the rollups of the rules, except to
+// the objects the chunk is already rolled up into.
+func (t *tableRollups) rollUp(ctx context.Context, chunkID, userID string, rules []validation.RetentionRollup, stream labels.Labels, it iter.EntryIterator) error {
+	// rolledUp holds whether the lines of the chunk are added to each object.
+	rolledUp := map[objectRef]bool{}
+	var lines int
+	for it.Next() {
+		entry := it.Entry()
+		metadata := logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)
+		added := false
+		for _, rule := range rules {
+			ts := pointTimestamp(rule, entry)
+			ref := objectRef{tenant: userID, rule: rule.Name, day: ts / dayMillis}
+			add, ok := rolledUp[ref]
+			if !ok {
+				chunks, err := t.rolledUpChunks(ctx, ref)
+				if err != nil {
+					return err
+				}
+				_, done := chunks[chunkID]
+				add = !done
+				rolledUp[ref] = add
+			}
+			if add {
+				t.add(ref, ts, rule, stream, metadata, entry)
+				added = true
+			}
+		}
+		if added {
+			lines++
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	for ref, add := range rolledUp {
+		if add {
+			t.chunks[ref][chunkID] = struct{}{}
+			t.added[ref] = append(t.added[ref], chunkID)
+		}
+	}
+	if lines > 0 {
+		t.builder.metrics.chunksRolledUp.Inc()
+		t.builder.metrics.linesRolledUp.Add(float64(lines))
+	}
+	return nil
+}
+
+// rolledUpChunks returns the IDs of the chunks rolled up into the object.
+func (t *tableRollups) rolledUpChunks(ctx context.Context, ref objectRef) (map[string]struct{}, error) {
+	if chunks, ok := t.chunks[ref]; ok {
+		return chunks, nil
+	}
+	ids, err := t.builder.store.Chunks(ctx, ref.tenant, ref.rule, ref.day)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		chunks[id] = struct{}{}
+	}
+	t.chunks[ref] = chunks
+	return chunks, nil
+}
+
+// pointTimestamp returns the timestamp of the point of the rule the entry is
+// rolled up into.
+func pointTimestamp(rule validation.RetentionRollup, entry logproto.Entry) int64 {
+	resolution := time.Duration(rule.Resolution).Milliseconds()
+	ts := entry.Timestamp.UnixMilli()
+	return ts - ts%resolution
+}
+
+func (t *tableRollups) add(ref objectRef, ts int64, rule validation.RetentionRollup, stream, metadata labels.Labels, entry logproto.Entry) {
+	set, ok := t.series[ref]
+	if !ok {
+		set = seriesSet{}
+		t.series[ref] = set
+	}
+
+	lb := labels.NewScratchBuilder(len(rule.By))
+	for _, name := range rule.By {
+		if value := labelValue(name, stream, metadata); value != "" {
+			lb.Add(name, value)
+		}
+	}
+	lb.Sort()
+
+	p := set.point(lb.Labels().String(), ts)
+	p.Count++
+	p.Bytes += int64(len(entry.Line))
+	for _, name := range rule.Unwrap {
+		value, err := strconv.ParseFloat(labelValue(name, stream, metadata), 64)
+		if err != nil {
+			continue
+		}
+		if p.Unwrapped == nil {
+			p.Unwrapped = map[string]*UnwrapStats{}
+		}
+		stats, ok := p.Unwrapped[name]
+		if !ok {
+			stats = &UnwrapStats{}
+			p.Unwrapped[name] = stats
+		}
+		stats.add(value)
+	}
+}
+
+// labelValue returns the value of the label in the stream labels, or else in
+// the structured metadata of the line.
+func labelValue(name string, stream, metadata labels.Labels) string {
+	if value := stream.Get(name); value != "" {
+		return value
+	}
+	return metadata.Get(name)
+}
+
+// Flush implements retention.TableRollups.
+func (t *tableRollups) Flush(ctx context.Context) error {
+	for ref, set := range t.series {
+		if err := t.builder.store.Merge(ctx, ref.tenant, ref.rule, ref.day, set.series(), t.added[ref]); err != nil {
+			return err
+		}
+		t.builder.metrics.objectsWritten.Inc()
+		delete(t.series, ref)
+		delete(t.added, ref)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package rollup
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// Point holds the rollups of the lines of a bucket of the resolution of a rule.
+type Point struct {
+	// Timestamp is the start of the bucket, in milliseconds.
+	Timestamp int64                   `json:"t"`
+	Count     int64                   `json:"c"`
+	Bytes     int64                   `json:"b"`
+	Unwrapped map[string]*UnwrapStats `json:"u,omitempty"`
+}
+
+// UnwrapStats holds the rollups of the values of an unwrapped label.
+type UnwrapStats struct {
+	Count int64   `json:"c"`
+	Sum   float64 `json:"s"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+func (u *UnwrapStats) add(value float64) {
+	if u.Count == 0 {
+		u.Min, u.Max = value, value
+	}
+	u.Count++
+	u.Sum += value
+	u.Min = math.Min(u.Min, value)
+	u.Max = math.Max(u.Max, value)
+}
+
+func (u *UnwrapStats) merge(o *UnwrapStats) {
+	if o.Count == 0 {
+		return
+	}
+	if u.Count == 0 {
+		*u = *o
+		return
+	}
+	u.Count += o.Count
+	u.Sum += o.Sum
+	u.Min = math.Min(u.Min, o.Min)
+	u.Max = math.Max(u.Max, o.Max)
+}
+
+func (p *Point) merge(o *Point) {
+	p.Count += o.Count
+	p.Bytes += o.Bytes
+	for name, stats := range o.Unwrapped {
+		if p.Unwrapped == nil {
+			p.Unwrapped = map[string]*UnwrapStats{}
+		}
+		if _, ok := p.Unwrapped[name]; !ok {
+			p.Unwrapped[name] = &UnwrapStats{}
+		}
+		p.Unwrapped[name].merge(stats)
+	}
+}
+
+// Series holds the points of a group of the by labels of a rule, in order.
+type Series struct {
+	Labels string  `json:"labels"`
+	Points []Point `json:"points"`
+}
+
+// seriesSet accumulates the points of series.
+type seriesSet map[string]map[int64]*Point
+
+func (s seriesSet) point(lbls string, ts int64) *Point {
+	points, ok := s[lbls]
+	if !ok {
+		points = map[int64]*Point{}
+		s[lbls] = points
+	}
+	p, ok := points[ts]
+	if !ok {
+		p = &Point{Timestamp: ts}
+		points[ts] = p
+	}
+	return p
+}
+
+func (s seriesSet) add(series []Series) {
+	for _, ss := range series {
+		for i := range ss.Points {
+			s.point(ss.Labels, ss.Points[i].Timestamp).merge(&ss.Points[i])
+		}
+	}
+}
+
+// series returns the series of the set, sorted by labels and timestamps.
+func (s seriesSet) series() []Series {
+	res := make([]Series, 0, len(s))
+	for lbls, points := range s {
+		ss := Series{Labels: lbls, Points: make([]Point, 0, len(points))}
+		for _, p := range points {
+			ss.Points = append(ss.Points, *p)
+		}
+		sort.Slice(ss.Points, func(i, j int) bool { return ss.Points[i].Timestamp < ss.Points[j].Timestamp })
+		res = append(res, ss)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Labels < res[j].Labels })
+	return res
+}
+
+// MatchingRules returns the rules whose selector matches the stream labels.
+func MatchingRules(rules []validation.RetentionRollup, lbls labels.Labels) []validation.RetentionRollup {
+	var res []validation.RetentionRollup
+Outer:
+	for _, rule := range rules {
+		for _, m := range rule.Matchers {
+			if !m.Matches(lbls.Get(m.Name)) {
+				continue Outer
+			}
+		}
+		res = append(res, rule)
+	}
+	return res
+}
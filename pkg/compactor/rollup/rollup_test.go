@@ -0,0 +1,159 @@
+package rollup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type fakeLimits struct {
+	retention.Limits
+	rules map[string][]validation.RetentionRollup
+}
+
+func (f fakeLimits) RetentionRollups(userID string) []validation.RetentionRollup {
+	return f.rules[userID]
+}
+
+func testRule(t *testing.T) validation.RetentionRollup {
+	rule := validation.RetentionRollup{
+		Name:       "access",
+		Selector:   `{job="nginx"}`,
+		By:         []string{"status"},
+		Unwrap:     []string{"duration"},
+		Resolution: model.Duration(time.Minute),
+		Period:     model.Duration(7 * 24 * time.Hour),
+	}
+	var l validation.Limits
+	flagext.DefaultValues(&l)
+	l.RetentionRollups = []validation.RetentionRollup{rule}
+	require.NoError(t, l.Validate())
+	return l.RetentionRollups[0]
+}
+
+func TestMatchingRules(t *testing.T) {
+	rule := testRule(t)
+
+	require.Len(t, MatchingRules([]validation.RetentionRollup{rule}, labels.FromStrings("job", "nginx", "pod", "a")), 1)
+	require.Empty(t, MatchingRules([]validation.RetentionRollup{rule}, labels.FromStrings("job", "api")))
+}
+
+func testChunkLines(stream labels.Labels, entries ...logproto.Entry) iter.EntryIterator {
+	return iter.NewStreamIterator(logproto.Stream{Labels: stream.String(), Entries: entries})
+}
+
+func TestTableRollups(t *testing.T) {
+	rule := testRule(t)
+	store := NewStore(newTestObjectClient(t), "rollups/")
+	table := newTableRollups(&Builder{store: store, metrics: newBuilderMetrics(nil)})
+	ctx := context.Background()
+
+	stream := labels.FromStrings("job", "nginx", "status", "200")
+	base := time.Unix(0, 0).Add(10 * 24 * time.Hour)
+	require.NoError(t, table.rollUp(ctx, "chunk-1", "user", []validation.RetentionRollup{rule}, stream, testChunkLines(stream,
+		logproto.Entry{Timestamp: base, Line: "a", StructuredMetadata: logproto.FromLabelsToLabelAdapters(labels.FromStrings("duration", "2"))},
+		logproto.Entry{Timestamp: base.Add(30 * time.Second), Line: "aa", StructuredMetadata: logproto.FromLabelsToLabelAdapters(labels.FromStrings("duration", "4"))},
+		logproto.Entry{Timestamp: base.Add(time.Minute), Line: "aaa", StructuredMetadata: logproto.FromLabelsToLabelAdapters(labels.FromStrings("duration", "invalid"))},
+	)))
+	require.NoError(t, table.Flush(ctx))
+	require.Empty(t, table.series)
+
+	series, err := store.Series(ctx, "user", rule.Name, model.TimeFromUnixNano(base.UnixNano()), model.TimeFromUnixNano(base.Add(time.Hour).UnixNano()))
+	require.NoError(t, err)
+	require.Equal(t, []Series{{
+		Labels: `{status="200"}`,
+		Points: []Point{
+			{Timestamp: base.UnixMilli(), Count: 2, Bytes: 3, Unwrapped: map[string]*UnwrapStats{"duration": {Count: 2, Sum: 6, Min: 2, Max: 4}}},
+			{Timestamp: base.Add(time.Minute).UnixMilli(), Count: 1, Bytes: 3},
+		},
+	}}, series)
+
+	// Merging the rollups of another chunk adds them up.
+	require.NoError(t, table.rollUp(ctx, "chunk-2", "user", []validation.RetentionRollup{rule}, stream, testChunkLines(stream,
+		logproto.Entry{Timestamp: base, Line: "a", StructuredMetadata: logproto.FromLabelsToLabelAdapters(labels.FromStrings("duration", "1"))},
+	)))
+	require.NoError(t, table.Flush(ctx))
+	series, err = store.Series(ctx, "user", rule.Name, model.TimeFromUnixNano(base.UnixNano()), model.TimeFromUnixNano(base.UnixNano()))
+	require.NoError(t, err)
+	require.Equal(t, []Series{{
+		Labels: `{status="200"}`,
+		Points: []Point{
+			{Timestamp: base.UnixMilli(), Count: 3, Bytes: 4, Unwrapped: map[string]*UnwrapStats{"duration": {Count: 3, Sum: 7, Min: 1, Max: 4}}},
+		},
+	}}, series)
+}
+
+func TestTableRollups_MarkTableTwice(t *testing.T) {
+	rule := testRule(t)
+	store := NewStore(newTestObjectClient(t), "rollups/")
+	builder := &Builder{store: store, metrics: newBuilderMetrics(nil)}
+	ctx := context.Background()
+
+	stream := labels.FromStrings("job", "nginx", "status", "200")
+	base := time.Unix(0, 0).Add(10 * 24 * time.Hour)
+	// The chunk spans two days, so its lines are rolled up into two objects.
+	lines := []logproto.Entry{
+		{Timestamp: base.Add(-time.Minute), Line: "a"},
+		{Timestamp: base, Line: "b"},
+	}
+
+	// The table is marked again after a compaction which failed once the
+	// rollups were flushed, and the second marking also rolls up a new chunk.
+	for i := 0; i < 2; i++ {
+		table := newTableRollups(builder)
+		require.NoError(t, table.rollUp(ctx, "chunk-1", "user", []validation.RetentionRollup{rule}, stream, testChunkLines(stream, lines...)))
+		if i == 1 {
+			require.NoError(t, table.rollUp(ctx, "chunk-2", "user", []validation.RetentionRollup{rule}, stream, testChunkLines(stream, logproto.Entry{Timestamp: base, Line: "c"})))
+		}
+		require.NoError(t, table.Flush(ctx))
+	}
+
+	series, err := store.Series(ctx, "user", rule.Name, model.TimeFromUnixNano(base.Add(-time.Hour).UnixNano()), model.TimeFromUnixNano(base.Add(time.Hour).UnixNano()))
+	require.NoError(t, err)
+	require.Equal(t, []Series{{
+		Labels: `{status="200"}`,
+		Points: []Point{
+			{Timestamp: base.Add(-time.Minute).UnixMilli(), Count: 1, Bytes: 1},
+			{Timestamp: base.UnixMilli(), Count: 2, Bytes: 2},
+		},
+	}}, series)
+}
+
+func TestStoreSweep(t *testing.T) {
+	rule := testRule(t)
+	store := NewStore(newTestObjectClient(t), "rollups/")
+	ctx := context.Background()
+
+	for _, day := range []int64{1, 9} {
+		require.NoError(t, store.Merge(ctx, "user", rule.Name, day, []Series{{Labels: "{}", Points: []Point{{Timestamp: day * dayMillis, Count: 1}}}}, nil))
+	}
+	// The rollups of rules which are not configured anymore are kept.
+	require.NoError(t, store.Merge(ctx, "user", "removed", 1, []Series{{Labels: "{}", Points: []Point{{Timestamp: dayMillis, Count: 1}}}}, nil))
+
+	limits := fakeLimits{rules: map[string][]validation.RetentionRollup{"user": {rule}}}
+	require.NoError(t, store.Sweep(ctx, limits, model.Time(10*dayMillis)))
+
+	days, err := store.days(ctx, "user", rule.Name)
+	require.NoError(t, err)
+	require.Equal(t, []int64{9}, days)
+	days, err = store.days(ctx, "user", "removed")
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, days)
+}
+
+func newTestObjectClient(t *testing.T) *local.FSObjectClient {
+	c, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	return c
+}
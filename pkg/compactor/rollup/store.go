@@ -0,0 +1,194 @@
+package rollup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+const (
+	dayMillis    = int64(24 * time.Hour / time.Millisecond)
+	objectSuffix = ".json.gz"
+	keyDelimiter = "/"
+)
+
+// Store stores the rollups of each tenant, rule and day in an object of the
+// object store, under <prefix><tenant>/<rule>/<day>.json.gz where day is the
+// number of days since the epoch.
+type Store struct {
+	client client.ObjectClient
+	prefix string
+
+	// mtx serializes the updates of the objects, which are read, merged with
+	// the new rollups and written back.
+	mtx sync.Mutex
+}
+
+// NewStore returns a store of rollups kept under the prefix of the client.
+func NewStore(objectClient client.ObjectClient, prefix string) *Store {
+	return &Store{
+		client: objectClient,
+		prefix: prefix,
+	}
+}
+
+func (s *Store) rulePrefix(tenant, rule string) string {
+	return s.prefix + tenant + keyDelimiter + rule + keyDelimiter
+}
+
+func (s *Store) objectKey(tenant, rule string, day int64) string {
+	return s.rulePrefix(tenant, rule) + strconv.FormatInt(day, 10) + objectSuffix
+}
+
+// object is the content of a rollup object.
+type object struct {
+	Series []Series `json:"series"`
+	// Chunks are the IDs of the chunks rolled up into the object, so that a
+	// chunk is never rolled up twice, e.g. when its table is marked again
+	// after a failed compaction.
+	Chunks []string `json:"chunks"`
+}
+
+// Merge adds the series, whose points all belong to the day, to the rollups of
+// the rule, and records the chunks they are the rollups of.
+func (s *Store) Merge(ctx context.Context, tenant, rule string, day int64, series []Series, chunks []string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	existing, err := s.read(ctx, s.objectKey(tenant, rule, day))
+	if err != nil && !s.client.IsObjectNotFoundErr(err) {
+		return err
+	}
+	set := seriesSet{}
+	set.add(existing.Series)
+	set.add(series)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gw).Encode(object{Series: set.series(), Chunks: append(existing.Chunks, chunks...)}); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return s.client.PutObject(ctx, s.objectKey(tenant, rule, day), bytes.NewReader(buf.Bytes()))
+}
+
+// Chunks returns the IDs of the chunks rolled up into the rollups of the rule
+// for the day.
+func (s *Store) Chunks(ctx context.Context, tenant, rule string, day int64) ([]string, error) {
+	o, err := s.read(ctx, s.objectKey(tenant, rule, day))
+	if err != nil && !s.client.IsObjectNotFoundErr(err) {
+		return nil, err
+	}
+	return o.Chunks, nil
+}
+
+func (s *Store) read(ctx context.Context, key string) (object, error) {
+	r, _, err := s.client.GetObject(ctx, key)
+	if err != nil {
+		return object{}, err
+	}
+	defer r.Close()
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return object{}, fmt.Errorf("read rollups %s: %w", key, err)
+	}
+	defer gr.Close()
+
+	var o object
+	if err := json.NewDecoder(gr).Decode(&o); err != nil {
+		return object{}, fmt.Errorf("decode rollups %s: %w", key, err)
+	}
+	return o, nil
+}
+
+// days returns the days of the rollups of the rule.
+func (s *Store) days(ctx context.Context, tenant, rule string) ([]int64, error) {
+	objects, _, err := s.client.List(ctx, s.rulePrefix(tenant, rule), keyDelimiter)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]int64, 0, len(objects))
+	for _, object := range objects {
+		name := strings.TrimSuffix(strings.TrimPrefix(object.Key, s.rulePrefix(tenant, rule)), objectSuffix)
+		day, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// Series returns the series of the rollups of the rule with points between
+// from and through.
+func (s *Store) Series(ctx context.Context, tenant, rule string, from, through model.Time) ([]Series, error) {
+	days, err := s.days(ctx, tenant, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	set := seriesSet{}
+	for _, day := range days {
+		if (day+1)*dayMillis <= int64(from) || day*dayMillis > int64(through) {
+			continue
+		}
+		o, err := s.read(ctx, s.objectKey(tenant, rule, day))
+		if err != nil {
+			if s.client.IsObjectNotFoundErr(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, ss := range o.Series {
+			for i := range ss.Points {
+				if ts := ss.Points[i].Timestamp; ts >= int64(from) && ts <= int64(through) {
+					set.point(ss.Labels, ts).merge(&ss.Points[i])
+				}
+			}
+		}
+	}
+	return set.series(), nil
+}
+
+// Sweep deletes the rollups of the configured rules of each tenant older than
+// their period. The rollups of rules which are not configured anymore are kept.
+func (s *Store) Sweep(ctx context.Context, limits Limits, now model.Time) error {
+	_, tenants, err := s.client.List(ctx, s.prefix, keyDelimiter)
+	if err != nil {
+		return err
+	}
+	for _, tenantPrefix := range tenants {
+		tenant := strings.TrimSuffix(strings.TrimPrefix(string(tenantPrefix), s.prefix), keyDelimiter)
+		for _, rule := range limits.RetentionRollups(tenant) {
+			days, err := s.days(ctx, tenant, rule.Name)
+			if err != nil {
+				return err
+			}
+			for _, day := range days {
+				if now.Sub(model.Time((day+1)*dayMillis)) <= time.Duration(rule.Period) {
+					continue
+				}
+				if err := s.client.DeleteObject(ctx, s.objectKey(tenant, rule.Name, day)); err != nil && !s.client.IsObjectNotFoundErr(err) {
+					return err
+				}
+				level.Debug(util_log.Logger).Log("msg", "deleted expired retention rollups", "tenant", tenant, "rule", rule.Name, "day", day)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package distributor
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/limiter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+const (
+	// attributionOverflow is the attribution value used once a tenant has reached
+	// the maximum number of tracked attribution values.
+	attributionOverflow = "__overflow__"
+
+	attributionKeySeparator = "\xff"
+
+	// attributionLimitRecheckPeriod is how often the limit and burst of the rate
+	// limiter of an attribution value are updated from the limits.
+	attributionLimitRecheckPeriod = 10 * time.Second
+)
+
+// AttributionConfig configures the tracking of ingested data per attribution value.
+type AttributionConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	MaxValuesPerTenant int           `yaml:"max_values_per_tenant"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by
+// prefix. If prefix is a non-empty string, prefix should end with a period.
+func (cfg *AttributionConfig) RegisterFlagsWithPrefix(prefix string, fs *flag.FlagSet) {
+	fs.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Enable tracking of ingested bytes and lines per attribution value. The attribution labels are configured per tenant with the attribution_labels limit.")
+	fs.IntVar(&cfg.MaxValuesPerTenant, prefix+".max-values-per-tenant", 1000, "Maximum number of attribution values tracked per tenant. Usage of additional values is accounted to the '"+attributionOverflow+"' value.")
+	fs.DurationVar(&cfg.IdleTimeout, prefix+".idle-timeout", time.Hour, "Time after which an attribution value which received no data is forgotten: its usage, rate limiter and metrics are removed, and it no longer counts towards the maximum number of values. 0 to never forget values.")
+}
+
+// AttributionUsage is the usage accounted to a single attribution value of a tenant.
+type AttributionUsage struct {
+	Attribution    string             `json:"attribution"`
+	ReceivedBytes  float64            `json:"receivedBytes"`
+	Bytes          float64            `json:"bytes"`
+	Lines          float64            `json:"lines"`
+	DiscardedBytes map[string]float64 `json:"discardedBytes,omitempty"`
+}
+
+// AttributionTracker accounts the usage of every tenant to the values of the
+// tenant's attribution labels, and enforces the per attribution value rate limit.
+// It implements push.UsageTracker so received and discarded bytes are attributed
+// as well.
+type AttributionTracker struct {
+	cfg      AttributionConfig
+	limits   Limits
+	strategy limiter.RateLimiterStrategy
+
+	mtx    sync.Mutex
+	values map[string]map[string]*attributionValue
+
+	receivedBytes  *prometheus.CounterVec
+	bytes          *prometheus.CounterVec
+	lines          *prometheus.CounterVec
+	discardedBytes *prometheus.CounterVec
+}
+
+// NewAttributionTracker makes a new AttributionTracker. If ring is not nil, the
+// per attribution value rate limit is shared across the healthy distributors.
+func NewAttributionTracker(cfg AttributionConfig, limits Limits, ring ReadLifecycler, registerer prometheus.Registerer) *AttributionTracker {
+	var strategy limiter.RateLimiterStrategy = &attributionLocalStrategy{limits: limits}
+	if ring != nil {
+		strategy = &attributionGlobalStrategy{limits: limits, ring: ring}
+	}
+
+	return &AttributionTracker{
+		cfg:      cfg,
+		limits:   limits,
+		strategy: strategy,
+		values:   make(map[string]map[string]*attributionValue),
+		receivedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_attribution_received_bytes_total",
+			Help:      "The total number of bytes received per tenant and attribution value, before validation.",
+		}, []string{"tenant", "attribution"}),
+		bytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_attribution_bytes_total",
+			Help:      "The total number of validated bytes accepted per tenant and attribution value.",
+		}, []string{"tenant", "attribution"}),
+		lines: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_attribution_lines_total",
+			Help:      "The total number of validated lines accepted per tenant and attribution value.",
+		}, []string{"tenant", "attribution"}),
+		discardedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_attribution_discarded_bytes_total",
+			Help:      "The total number of bytes discarded per tenant, attribution value and reason.",
+		}, []string{"tenant", "attribution", "reason"}),
+	}
+}
+
+// AttributionFor returns the attribution value of the stream with the given
+// labels, or an empty string if attribution is not configured for the tenant.
+// Once the tenant tracks the maximum number of values, new values are mapped
+// to the overflow value.
+func (t *AttributionTracker) AttributionFor(tenant string, lbs labels.Labels) string {
+	names := t.limits.AttributionLabels(tenant)
+	if len(names) == 0 {
+		return ""
+	}
+
+	b := labels.NewScratchBuilder(len(names))
+	for _, name := range names {
+		if v := lbs.Get(name); v != "" {
+			b.Add(name, v)
+		}
+	}
+	b.Sort()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.valueFor(tenant, b.Labels().String()).usage.Attribution
+}
+
+// AllowN reports whether the bytes of every attribution value may be ingested
+// at time now. The bytes are only consumed from the rate limits if all values
+// are allowed, otherwise the first rejected value is returned.
+func (t *AttributionTracker) AllowN(now time.Time, tenant string, bytes map[string]int) (string, bool) {
+	if t.limits.AttributionRateBytes(tenant) <= 0 {
+		return "", true
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	reservations := make([]*rate.Reservation, 0, len(bytes))
+	for attribution, n := range bytes {
+		if attribution == "" {
+			continue
+		}
+		r := t.limiterFor(now, tenant, attribution).ReserveN(now, n)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			// Give back the tokens of the values already reserved, since the
+			// whole request is rejected.
+			for _, reserved := range reservations {
+				reserved.CancelAt(now)
+			}
+			return attribution, false
+		}
+		reservations = append(reservations, r)
+	}
+	return "", true
+}
+
+// Limit returns the current rate limit of the attribution value in bytes per second.
+func (t *AttributionTracker) Limit(now time.Time, tenant, attribution string) float64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return float64(t.limiterFor(now, tenant, attribution).Limit())
+}
+
+// Add accounts validated bytes and lines to an attribution value returned by
+// AttributionFor.
+func (t *AttributionTracker) Add(tenant, attribution string, bytes, lines int) {
+	if attribution == "" {
+		return
+	}
+
+	t.mtx.Lock()
+	u := &t.valueFor(tenant, attribution).usage
+	u.Bytes += float64(bytes)
+	u.Lines += float64(lines)
+	t.mtx.Unlock()
+
+	t.bytes.WithLabelValues(tenant, attribution).Add(float64(bytes))
+	t.lines.WithLabelValues(tenant, attribution).Add(float64(lines))
+}
+
+// ReceivedBytesAdd implements push.UsageTracker.
+func (t *AttributionTracker) ReceivedBytesAdd(_ context.Context, tenant string, _ time.Duration, lbs labels.Labels, value float64) {
+	attribution := t.AttributionFor(tenant, lbs)
+	if attribution == "" {
+		return
+	}
+
+	t.mtx.Lock()
+	u := &t.valueFor(tenant, attribution).usage
+	u.ReceivedBytes += value
+	t.mtx.Unlock()
+
+	t.receivedBytes.WithLabelValues(tenant, attribution).Add(value)
+}
+
+// DiscardedBytesAdd implements push.UsageTracker.
+func (t *AttributionTracker) DiscardedBytesAdd(_ context.Context, tenant, reason string, lbs labels.Labels, value float64) {
+	attribution := t.AttributionFor(tenant, lbs)
+	if attribution == "" {
+		return
+	}
+
+	t.mtx.Lock()
+	u := &t.valueFor(tenant, attribution).usage
+	if u.DiscardedBytes == nil {
+		u.DiscardedBytes = make(map[string]float64)
+	}
+	u.DiscardedBytes[reason] += value
+	t.mtx.Unlock()
+
+	t.discardedBytes.WithLabelValues(tenant, attribution, reason).Add(value)
+}
+
+// Usage returns a copy of the usage of every attribution value of the tenant,
+// ordered by descending accepted bytes.
+func (t *AttributionTracker) Usage(tenant string) []AttributionUsage {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	res := make([]AttributionUsage, 0, len(t.values[tenant]))
+	for _, v := range t.values[tenant] {
+		u := &v.usage
+		cpy := *u
+		if u.DiscardedBytes != nil {
+			cpy.DiscardedBytes = make(map[string]float64, len(u.DiscardedBytes))
+			for reason, v := range u.DiscardedBytes {
+				cpy.DiscardedBytes[reason] = v
+			}
+		}
+		res = append(res, cpy)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Bytes != res[j].Bytes {
+			return res[i].Bytes > res[j].Bytes
+		}
+		return res[i].Attribution < res[j].Attribution
+	})
+	return res
+}
+
+// attributionValue holds the usage and the rate limiter of an attribution value.
+type attributionValue struct {
+	usage     AttributionUsage
+	updatedAt time.Time
+
+	limiter   *rate.Limiter
+	recheckAt time.Time
+}
+
+// valueFor returns the attribution value, creating it if needed. Once the
+// tenant tracks the maximum number of values, the overflow value is returned
+// instead. It must be called with the mutex held.
+func (t *AttributionTracker) valueFor(tenant, attribution string) *attributionValue {
+	byValue, ok := t.values[tenant]
+	if !ok {
+		byValue = make(map[string]*attributionValue)
+		t.values[tenant] = byValue
+	}
+
+	v, ok := byValue[attribution]
+	if !ok && t.cfg.MaxValuesPerTenant > 0 && len(byValue) >= t.cfg.MaxValuesPerTenant {
+		attribution = attributionOverflow
+		v, ok = byValue[attribution]
+	}
+	if !ok {
+		v = &attributionValue{usage: AttributionUsage{Attribution: attribution}}
+		byValue[attribution] = v
+	}
+	v.updatedAt = time.Now()
+	return v
+}
+
+// limiterFor returns the rate limiter of the attribution value, updating its
+// limit and burst every attributionLimitRecheckPeriod. It must be called with
+// the mutex held.
+func (t *AttributionTracker) limiterFor(now time.Time, tenant, attribution string) *rate.Limiter {
+	v := t.valueFor(tenant, attribution)
+	key := attributionKey(tenant, v.usage.Attribution)
+	if v.limiter == nil {
+		v.limiter = rate.NewLimiter(rate.Limit(t.strategy.Limit(key)), t.strategy.Burst(key))
+		v.recheckAt = now.Add(attributionLimitRecheckPeriod)
+	} else if now.After(v.recheckAt) {
+		v.limiter.SetLimitAt(now, rate.Limit(t.strategy.Limit(key)))
+		v.limiter.SetBurstAt(now, t.strategy.Burst(key))
+		v.recheckAt = now.Add(attributionLimitRecheckPeriod)
+	}
+	return v.limiter
+}
+
+func (t *AttributionTracker) expireIdle(_ context.Context) error {
+	if t.cfg.IdleTimeout > 0 {
+		t.expire(time.Now().Add(-t.cfg.IdleTimeout))
+	}
+	return nil
+}
+
+// expire forgets the attribution values which were not updated since before,
+// along with their metrics.
+func (t *AttributionTracker) expire(before time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for tenant, byValue := range t.values {
+		for attribution, v := range byValue {
+			if !v.updatedAt.Before(before) {
+				continue
+			}
+			delete(byValue, attribution)
+
+			lbls := prometheus.Labels{"tenant": tenant, "attribution": attribution}
+			t.receivedBytes.Delete(lbls)
+			t.bytes.Delete(lbls)
+			t.lines.Delete(lbls)
+			t.discardedBytes.DeletePartialMatch(lbls)
+		}
+		if len(byValue) == 0 {
+			delete(t.values, tenant)
+		}
+	}
+}
+
+func attributionKey(tenant, attribution string) string {
+	return tenant + attributionKeySeparator + attribution
+}
+
+func tenantFromAttributionKey(key string) string {
+	tenant, _, _ := strings.Cut(key, attributionKeySeparator)
+	return tenant
+}
+
+type attributionLocalStrategy struct {
+	limits Limits
+}
+
+func (s *attributionLocalStrategy) Limit(key string) float64 {
+	return s.limits.AttributionRateBytes(tenantFromAttributionKey(key))
+}
+
+func (s *attributionLocalStrategy) Burst(key string) int {
+	return s.limits.AttributionBurstSizeBytes(tenantFromAttributionKey(key))
+}
+
+type attributionGlobalStrategy struct {
+	limits Limits
+	ring   ReadLifecycler
+}
+
+func (s *attributionGlobalStrategy) Limit(key string) float64 {
+	limit := s.limits.AttributionRateBytes(tenantFromAttributionKey(key))
+
+	numDistributors := s.ring.HealthyInstancesCount()
+	if numDistributors == 0 {
+		return limit
+	}
+	return limit / float64(numDistributors)
+}
+
+func (s *attributionGlobalStrategy) Burst(key string) int {
+	return s.limits.AttributionBurstSizeBytes(tenantFromAttributionKey(key))
+}
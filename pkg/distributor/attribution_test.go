@@ -0,0 +1,169 @@
+package distributor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func newTestAttributionTracker(t *testing.T, limits *validation.Limits, maxValues int) *AttributionTracker {
+	t.Helper()
+
+	overrides, err := validation.NewOverrides(*limits, nil)
+	require.NoError(t, err)
+
+	return NewAttributionTracker(AttributionConfig{Enabled: true, MaxValuesPerTenant: maxValues}, overrides, nil, prometheus.NewRegistry())
+}
+
+func TestAttributionTracker_AttributionFor(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+
+	tracker := newTestAttributionTracker(t, limits, 0)
+	require.Equal(t, "", tracker.AttributionFor("test", labels.FromStrings("team", "payments")))
+
+	limits.AttributionLabels = []string{"team", "env"}
+	tracker = newTestAttributionTracker(t, limits, 0)
+	require.Equal(t, `{env="prod", team="payments"}`, tracker.AttributionFor("test", labels.FromStrings("app", "api", "env", "prod", "team", "payments")))
+	require.Equal(t, `{team="payments"}`, tracker.AttributionFor("test", labels.FromStrings("app", "api", "team", "payments")))
+	require.Equal(t, `{}`, tracker.AttributionFor("test", labels.FromStrings("app", "api")))
+}
+
+func TestAttributionTracker_Overflow(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AttributionLabels = []string{"team"}
+
+	tracker := newTestAttributionTracker(t, limits, 2)
+	require.Equal(t, `{team="a"}`, tracker.AttributionFor("test", labels.FromStrings("team", "a")))
+	require.Equal(t, `{team="b"}`, tracker.AttributionFor("test", labels.FromStrings("team", "b")))
+	require.Equal(t, attributionOverflow, tracker.AttributionFor("test", labels.FromStrings("team", "c")))
+	require.Equal(t, `{team="a"}`, tracker.AttributionFor("test", labels.FromStrings("team", "a")))
+
+	// Other tenants are not affected.
+	require.Equal(t, `{team="c"}`, tracker.AttributionFor("other", labels.FromStrings("team", "c")))
+}
+
+func TestAttributionTracker_Usage(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AttributionLabels = []string{"team"}
+
+	tracker := newTestAttributionTracker(t, limits, 0)
+	a := tracker.AttributionFor("test", labels.FromStrings("team", "a"))
+	b := tracker.AttributionFor("test", labels.FromStrings("team", "b"))
+
+	tracker.Add("test", a, 10, 1)
+	tracker.Add("test", b, 100, 2)
+	tracker.Add("test", b, 100, 2)
+	tracker.ReceivedBytesAdd(context.Background(), "test", 0, labels.FromStrings("team", "a"), 50)
+	tracker.DiscardedBytesAdd(context.Background(), "test", validation.LineTooLong, labels.FromStrings("team", "a"), 40)
+
+	require.Equal(t, []AttributionUsage{
+		{Attribution: `{team="b"}`, Bytes: 200, Lines: 4},
+		{Attribution: `{team="a"}`, ReceivedBytes: 50, Bytes: 10, Lines: 1, DiscardedBytes: map[string]float64{validation.LineTooLong: 40}},
+	}, tracker.Usage("test"))
+	require.Empty(t, tracker.Usage("other"))
+
+	require.Equal(t, float64(200), testutil.ToFloat64(tracker.bytes.WithLabelValues("test", b)))
+	require.Equal(t, float64(4), testutil.ToFloat64(tracker.lines.WithLabelValues("test", b)))
+	require.Equal(t, float64(40), testutil.ToFloat64(tracker.discardedBytes.WithLabelValues("test", a, validation.LineTooLong)))
+}
+
+func TestAttributionTracker_AllowN(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AttributionLabels = []string{"team"}
+
+	tracker := newTestAttributionTracker(t, limits, 0)
+	now := time.Now()
+	_, ok := tracker.AllowN(now, "test", map[string]int{`{team="a"}`: 10 << 20})
+	require.True(t, ok, "no limit configured")
+
+	limits.AttributionRateMB = 1
+	limits.AttributionBurstSizeMB = 1
+	tracker = newTestAttributionTracker(t, limits, 0)
+	_, ok = tracker.AllowN(now, "test", map[string]int{`{team="a"}`: 1 << 20})
+	require.True(t, ok)
+	rejected, ok := tracker.AllowN(now, "test", map[string]int{`{team="a"}`: 1})
+	require.False(t, ok)
+	require.Equal(t, `{team="a"}`, rejected)
+	_, ok = tracker.AllowN(now, "test", map[string]int{`{team="b"}`: 1 << 19, "": 1 << 20})
+	require.True(t, ok, "each attribution value has its own limit and streams without attribution are not limited")
+
+	// Values which would be allowed don't consume their limit when another
+	// value of the request is rejected.
+	rejected, ok = tracker.AllowN(now, "test", map[string]int{`{team="a"}`: 1, `{team="b"}`: 1 << 19, `{team="c"}`: 1 << 20})
+	require.False(t, ok)
+	require.Equal(t, `{team="a"}`, rejected)
+	_, ok = tracker.AllowN(now, "test", map[string]int{`{team="b"}`: 1 << 19, `{team="c"}`: 1 << 20})
+	require.True(t, ok)
+}
+
+func TestAttributionTracker_Expire(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AttributionLabels = []string{"team"}
+
+	tracker := newTestAttributionTracker(t, limits, 2)
+	a := tracker.AttributionFor("test", labels.FromStrings("team", "a"))
+	tracker.Add("test", a, 10, 1)
+	tracker.DiscardedBytesAdd(context.Background(), "test", validation.LineTooLong, labels.FromStrings("team", "a"), 40)
+	before := time.Now()
+	tracker.Add("test", tracker.AttributionFor("test", labels.FromStrings("team", "b")), 10, 1)
+
+	tracker.expire(before)
+	require.Equal(t, []AttributionUsage{{Attribution: `{team="b"}`, Bytes: 10, Lines: 1}}, tracker.Usage("test"))
+	require.Equal(t, 0, testutil.CollectAndCount(tracker.discardedBytes))
+	require.Equal(t, 1, testutil.CollectAndCount(tracker.bytes))
+
+	// Forgotten values don't count towards the maximum number of values anymore.
+	require.Equal(t, `{team="c"}`, tracker.AttributionFor("test", labels.FromStrings("team", "c")))
+}
+
+func TestDistributor_AttributionRateLimit(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AttributionLabels = []string{"team"}
+	limits.AttributionRateMB = 0.0001
+	limits.AttributionBurstSizeMB = 0.0001
+
+	distributors, _ := prepare(t, 1, 3, limits, nil)
+	d := distributors[0]
+	d.attribution = newTestAttributionTracker(t, limits, 0)
+
+	_, err := d.Push(ctx, makeWriteRequestWithLabels(10, 10, []string{`{foo="bar", team="a"}`}))
+	require.NoError(t, err)
+
+	_, err = d.Push(ctx, makeWriteRequestWithLabels(10, 10, []string{`{foo="bar", team="a"}`}))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	require.Contains(t, string(resp.Body), `attribution {team="a"}`)
+
+	_, err = d.Push(ctx, makeWriteRequestWithLabels(10, 10, []string{`{foo="bar", team="b"}`}))
+	require.NoError(t, err)
+
+	usage := d.attribution.Usage("test")
+	require.Len(t, usage, 2)
+	for _, u := range usage {
+		require.Equal(t, float64(100), u.Bytes)
+		require.Equal(t, float64(10), u.Lines)
+	}
+
+	rec := httptest.NewRecorder()
+	d.AttributionHandler(rec, httptest.NewRequest(http.MethodGet, "/distributor/attribution", nil).WithContext(ctx))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"attribution":"{team=\"a\"}"`)
+}
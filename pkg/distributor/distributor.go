@@ -41,6 +41,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
 	"github.com/grafana/loki/v3/pkg/ingester"
 	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/kafka"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
@@ -88,6 +89,14 @@ type Config struct {
 	WriteFailuresLogging writefailures.Cfg `yaml:"write_failures_logging" doc:"description=Customize the logging of write failures."`
 
 	OTLPConfig push.GlobalOTLPConfig `yaml:"otlp_config"`
+
+	// Attribution customizes the tracking of ingested data per attribution value.
+	Attribution AttributionConfig `yaml:"attribution" doc:"description=Customize the tracking of ingested data per attribution value, for example per team within a tenant."`
+
+	// Syslog configures the syslog listeners.
+	Syslog SyslogConfig `yaml:"syslog" doc:"description=Configures syslog listeners receiving RFC5424 or RFC3164 messages directly in the distributor."`
+
+	KafkaConfig kafka.Config `yaml:"-"`
 }
 
 // RegisterFlags registers distributor-related flags.
@@ -96,11 +105,21 @@ func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
 	cfg.DistributorRing.RegisterFlags(fs)
 	cfg.RateStore.RegisterFlagsWithPrefix("distributor.rate-store", fs)
 	cfg.WriteFailuresLogging.RegisterFlagsWithPrefix("distributor.write-failures-logging", fs)
+	cfg.Attribution.RegisterFlagsWithPrefix("distributor.attribution", fs)
+	cfg.Syslog.RegisterFlagsWithPrefix("distributor.syslog", fs)
+}
+
+// Validate validates the distributor configuration.
+func (cfg *Config) Validate() error {
+	return cfg.Syslog.Validate()
 }
 
 // RateStore manages the ingestion rate of streams, populated by data fetched from ingesters.
 type RateStore interface {
 	RateFor(tenantID string, streamHash uint64) (int64, float64)
+	// IngesterRate returns the mean rate in bytes ingested by the healthy
+	// ingesters.
+	IngesterRate() int64
 }
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -119,6 +138,7 @@ type Distributor struct {
 
 	rateStore    RateStore
 	shardTracker *ShardTracker
+	shardMap     *shardMap
 
 	// The global rate limiter requires a distributors ring to count
 	// the number of healthy instances.
@@ -137,6 +157,12 @@ type Distributor struct {
 	// Push failures rate limiter.
 	writeFailuresManager *writefailures.Manager
 
+	// Per attribution value usage tracking and rate limiting, nil if disabled.
+	attribution *AttributionTracker
+
+	// Writes validated streams to Kafka instead of ingesters, nil if disabled.
+	kafkaWriter streamWriter
+
 	RequestParserWrapper push.RequestParserWrapper
 
 	// metrics
@@ -144,6 +170,7 @@ type Distributor struct {
 	ingesterAppendTimeouts *prometheus.CounterVec
 	replicationFactor      prometheus.Gauge
 	streamShardCount       prometheus.Counter
+	streamShardMergeCount  prometheus.Counter
 
 	usageTracker push.UsageTracker
 }
@@ -203,6 +230,7 @@ func New(
 		pool:                  clientpool.NewPool("ingester", clientCfg.PoolConfig, ingestersRing, factory, logger, metricsNamespace),
 		labelCache:            labelCache,
 		shardTracker:          NewShardTracker(),
+		shardMap:              newShardMap(),
 		healthyInstancesCount: atomic.NewUint32(0),
 		rateLimitStrat:        rateLimitStrat,
 		tee:                   tee,
@@ -227,6 +255,11 @@ func New(
 			Name:      "stream_sharding_count",
 			Help:      "Total number of times the distributor has sharded streams",
 		}),
+		streamShardMergeCount: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "stream_sharding_merges_total",
+			Help:      "Total number of times the distributor has merged the shards of a stream back.",
+		}),
 		writeFailuresManager: writefailures.NewManager(logger, registerer, cfg.WriteFailuresLogging, configs, "distributor"),
 	}
 
@@ -246,6 +279,32 @@ func New(
 	}
 
 	d.ingestionRateLimiter = limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second)
+
+	if cfg.Attribution.Enabled {
+		var ring ReadLifecycler
+		if d.rateLimitStrat == validation.GlobalIngestionRateStrategy {
+			ring = d
+		}
+		d.attribution = NewAttributionTracker(cfg.Attribution, overrides, ring, registerer)
+		d.usageTracker = push.NewMultiUsageTracker(usageTracker, d.attribution)
+		d.validator.usageTracker = d.usageTracker
+		servs = append(servs, services.NewTimerService(time.Minute, nil, d.attribution.expireIdle, nil).WithName("attribution tracker"))
+	}
+	if cfg.KafkaConfig.Enabled {
+		kafkaWriter, err := kafka.NewWriter(cfg.KafkaConfig, registerer, logger)
+		if err != nil {
+			return nil, err
+		}
+		d.kafkaWriter = kafkaWriter
+		servs = append(servs, kafkaWriter)
+	}
+	if len(cfg.Syslog.Listeners) > 0 {
+		syslogServer, err := NewSyslogServer(cfg.Syslog, d.Push, registerer, logger)
+		if err != nil {
+			return nil, err
+		}
+		servs = append(servs, syslogServer)
+	}
 	d.distributorsRing = distributorsRing
 	d.distributorsLifecycler = distributorsLifecycler
 
@@ -268,7 +327,7 @@ func New(
 	)
 	d.rateStore = rs
 
-	servs = append(servs, d.pool, rs)
+	servs = append(servs, d.pool, rs, services.NewTimerService(time.Minute, nil, d.shardMap.expireStale, nil).WithName("stream shard map"))
 	d.subservices, err = services.NewManager(servs...)
 	if err != nil {
 		return nil, errors.Wrap(err, "services manager")
@@ -339,9 +398,20 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	validatedLineSize := 0
 	validatedLineCount := 0
 
+	// Validated usage per attribution value, only populated if attribution is enabled.
+	var attributed map[string]*attributedUsage
+	if d.attribution != nil {
+		attributed = make(map[string]*attributedUsage)
+	}
+
 	var validationErrors util.GroupedErrors
 	validationContext := d.validator.getValidationContextForTime(time.Now(), tenantID)
 
+	var extractor *structuredMetadataExtractor
+	if validationContext.allowStructuredMetadata {
+		extractor = newStructuredMetadataExtractor(validationContext.structuredMetadataExtractionFormat, validationContext.structuredMetadataExtractionFields, validationContext.structuredMetadataExtractionStrip)
+	}
+
 	func() {
 		sp := opentracing.SpanFromContext(ctx)
 		if sp != nil {
@@ -378,6 +448,12 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			prevTs := stream.Entries[0].Timestamp
 			addLogLevel := validationContext.allowStructuredMetadata && validationContext.discoverLogLevels && !lbs.Has(labelLevel)
 			for _, entry := range stream.Entries {
+				// Extract before validating, so the limits apply to the resulting
+				// line and structured metadata.
+				if extractor != nil {
+					extractor.Extract(&entry)
+				}
+
 				if err := d.validator.ValidateEntry(ctx, validationContext, lbs, entry); err != nil {
 					d.writeFailuresManager.Log(tenantID, err)
 					validationErrors.Add(err)
@@ -416,6 +492,18 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			}
 			stream.Entries = stream.Entries[:n]
 
+			if attributed != nil && n > 0 {
+				if attribution := d.attribution.AttributionFor(tenantID, lbs); attribution != "" {
+					usage, ok := attributed[attribution]
+					if !ok {
+						usage = &attributedUsage{}
+						attributed[attribution] = usage
+					}
+					usage.bytes += pushSize
+					usage.lines += n
+				}
+			}
+
 			shardStreamsCfg := d.validator.Limits.ShardStreams(tenantID)
 			if shardStreamsCfg.Enabled {
 				streams = append(streams, d.shardStream(stream, pushSize, tenantID)...)
@@ -443,36 +531,52 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		// Return a 429 to indicate to the client they are being rate limited
 		validation.DiscardedSamples.WithLabelValues(validation.RateLimited, tenantID).Add(float64(validatedLineCount))
 		validation.DiscardedBytes.WithLabelValues(validation.RateLimited, tenantID).Add(float64(validatedLineSize))
-
-		if d.usageTracker != nil {
-			for _, stream := range req.Streams {
-				lbs, _, _, err := d.parseStreamLabels(validationContext, stream.Labels, stream)
-				if err != nil {
-					continue
-				}
-
-				discardedStreamBytes := 0
-				for _, e := range stream.Entries {
-					discardedStreamBytes += len(e.Line)
-				}
-
-				if d.usageTracker != nil {
-					d.usageTracker.DiscardedBytesAdd(ctx, tenantID, validation.RateLimited, lbs, float64(discardedStreamBytes))
-				}
-			}
-		}
+		d.trackDiscardedRequest(ctx, tenantID, validation.RateLimited, validationContext, req)
 
 		err = fmt.Errorf(validation.RateLimitedErrorMsg, tenantID, int(d.ingestionRateLimiter.Limit(now, tenantID)), validatedLineCount, validatedLineSize)
 		d.writeFailuresManager.Log(tenantID, err)
 		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, err.Error())
 	}
 
+	if len(attributed) > 0 {
+		attributedBytes := make(map[string]int, len(attributed))
+		for attribution, usage := range attributed {
+			attributedBytes[attribution] = usage.bytes
+		}
+		if attribution, ok := d.attribution.AllowN(now, tenantID, attributedBytes); !ok {
+			usage := attributed[attribution]
+
+			// Reject the whole request, the same way the tenant rate limit does,
+			// so clients can retry it without partially ingested data.
+			validation.DiscardedSamples.WithLabelValues(validation.AttributionRateLimited, tenantID).Add(float64(validatedLineCount))
+			validation.DiscardedBytes.WithLabelValues(validation.AttributionRateLimited, tenantID).Add(float64(validatedLineSize))
+			d.trackDiscardedRequest(ctx, tenantID, validation.AttributionRateLimited, validationContext, req)
+
+			err = fmt.Errorf(validation.AttributionRateLimitedErrorMsg, tenantID, attribution, int(d.attribution.Limit(now, tenantID, attribution)), usage.lines, usage.bytes)
+			d.writeFailuresManager.Log(tenantID, err)
+			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, err.Error())
+		}
+	}
+
+	for attribution, usage := range attributed {
+		d.attribution.Add(tenantID, attribution, usage.bytes, usage.lines)
+	}
+
 	// Nil check for performance reasons, to avoid dynamic lookup and/or no-op
 	// function calls that cannot be inlined.
 	if d.tee != nil {
 		d.tee.Duplicate(tenantID, streams)
 	}
 
+	// Unlike the tee, the Kafka write is synchronous: the request only succeeds
+	// once the streams are durably stored, and ingesters consume them from there.
+	if d.kafkaWriter != nil {
+		if err := d.writeToKafka(ctx, tenantID, streams); err != nil {
+			return nil, err
+		}
+		return &logproto.PushResponse{}, validationErr
+	}
+
 	const maxExpectedReplicationSet = 5 // typical replication factor 3 plus one for inactive plus one for luck
 	var descs [maxExpectedReplicationSet]ring.InstanceDesc
 
@@ -537,6 +641,50 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	}
 }
 
+// streamWriter writes validated streams to durable storage.
+type streamWriter interface {
+	Write(ctx context.Context, tenant string, streams []kafka.Stream) error
+}
+
+func (d *Distributor) writeToKafka(ctx context.Context, tenantID string, streams []KeyedStream) error {
+	kafkaStreams := make([]kafka.Stream, 0, len(streams))
+	for _, s := range streams {
+		kafkaStreams = append(kafkaStreams, kafka.Stream{HashKey: s.HashKey, Stream: s.Stream})
+	}
+
+	if err := d.kafkaWriter.Write(ctx, tenantID, kafkaStreams); err != nil {
+		level.Error(d.logger).Log("msg", "failed to write to kafka", "org_id", tenantID, "err", err)
+		return httpgrpc.Errorf(http.StatusServiceUnavailable, "failed to write to kafka: %s", err)
+	}
+	return nil
+}
+
+type attributedUsage struct {
+	bytes, lines int
+}
+
+// trackDiscardedRequest reports all the bytes of the request as discarded for
+// the given reason to the usage tracker.
+func (d *Distributor) trackDiscardedRequest(ctx context.Context, tenantID, reason string, vCtx validationContext, req *logproto.PushRequest) {
+	if d.usageTracker == nil {
+		return
+	}
+
+	for _, stream := range req.Streams {
+		lbs, _, _, err := d.parseStreamLabels(vCtx, stream.Labels, stream)
+		if err != nil {
+			continue
+		}
+
+		discardedStreamBytes := 0
+		for _, e := range stream.Entries {
+			discardedStreamBytes += len(e.Line)
+		}
+
+		d.usageTracker.DiscardedBytesAdd(ctx, tenantID, reason, lbs, float64(discardedStreamBytes))
+	}
+}
+
 // shardStream shards (divides) the given stream into N smaller streams, where
 // N is the sharding size for the given stream. shardSteam returns the smaller
 // streams and their associated keys for hashing to ingesters.
@@ -775,35 +923,48 @@ func (d *Distributor) parseStreamLabels(vContext validationContext, key string,
 
 // shardCountFor returns the right number of shards to be used by the given stream.
 //
-// It first checks if the number of shards is present in the shard store. If it isn't it will calculate it
-// based on the rate stored in the rate store and will store the new evaluated number of shards.
+// It calculates the number of shards based on the rate stored in the rate store
+// and the rate each shard is sized for, which is either the desired rate or,
+// with adaptive sharding, the per-stream rate limit of the tenant times the
+// target utilization, lowered to the spare capacity of the ingesters if their
+// capacity is set. The shard map then decides whether the stream grows or
+// keeps its shards until they can be merged back.
 //
 // desiredRate is expected to be given in bytes.
 func (d *Distributor) shardCountFor(logger log.Logger, stream *logproto.Stream, pushSize int, tenantID string, streamShardcfg *shardstreams.Config) int {
-	if streamShardcfg.DesiredRate.Val() <= 0 {
+	shardRate := streamShardcfg.DesiredRate.Val()
+	if streamShardcfg.AdaptiveEnabled {
+		shardRate = streamShardcfg.AdaptiveShardRate(float64(d.validator.Limits.PerStreamRateLimit(tenantID).Limit), d.rateStore.IngesterRate())
+	}
+	if shardRate <= 0 {
 		if streamShardcfg.LoggingEnabled {
-			level.Error(logger).Log("msg", "invalid desired rate", "desired_rate", streamShardcfg.DesiredRate.String())
+			level.Error(logger).Log("msg", "invalid desired rate", "desired_rate", streamShardcfg.DesiredRate.String(), "adaptive", streamShardcfg.AdaptiveEnabled)
 		}
 		return 1
 	}
 
 	rate, pushRate := d.rateStore.RateFor(tenantID, stream.Hash)
-	if pushRate == 0 {
-		// first push, don't shard until the rate is understood
-		return 1
-	}
 
-	if pushRate > 1 {
-		// High throughput. Let stream sharding do its job and
-		// don't attempt to amortize the push size over the
-		// real rate
-		pushRate = 1
+	// If the push rate is 0, it's the first push of the stream: don't shard
+	// until the rate is understood.
+	shards := 1
+	if pushRate > 0 {
+		if pushRate > 1 {
+			// High throughput. Let stream sharding do its job and
+			// don't attempt to amortize the push size over the
+			// real rate
+			pushRate = 1
+		}
+
+		shards = calculateShards(rate, int(float64(pushSize)*pushRate), shardRate)
 	}
 
-	shards := calculateShards(rate, int(float64(pushSize)*pushRate), streamShardcfg.DesiredRate.Val())
-	if shards == 0 {
-		// 1 shard is enough for the given stream.
-		return 1
+	shards, merged := d.shardMap.Update(tenantID, stream.Labels, stream.Hash, shards, rate, streamShardcfg.MergeDelay, time.Now())
+	if merged {
+		d.streamShardMergeCount.Inc()
+		if streamShardcfg.LoggingEnabled {
+			level.Info(logger).Log("msg", "merged stream shards", "shard_count", shards)
+		}
 	}
 
 	return shards
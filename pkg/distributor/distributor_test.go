@@ -2,6 +2,7 @@ package distributor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/ingester"
 	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/kafka"
 	loghttp_push "github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
@@ -43,6 +45,7 @@ import (
 	loki_flagext "github.com/grafana/loki/v3/pkg/util/flagext"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	loki_net "github.com/grafana/loki/v3/pkg/util/net"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
 	"github.com/grafana/loki/v3/pkg/util/test"
 	"github.com/grafana/loki/v3/pkg/validation"
 )
@@ -680,6 +683,7 @@ func TestStreamShard(t *testing.T) {
 				validator:        validator,
 				streamShardCount: prometheus.NewCounter(prometheus.CounterOpts{}),
 				shardTracker:     NewShardTracker(),
+				shardMap:         newShardMap(),
 			}
 
 			derivedStreams := d.shardStream(baseStream, tc.streamSize, "fake")
@@ -725,6 +729,7 @@ func TestStreamShardAcrossCalls(t *testing.T) {
 			validator:        validator,
 			streamShardCount: prometheus.NewCounter(prometheus.CounterOpts{}),
 			shardTracker:     NewShardTracker(),
+			shardMap:         newShardMap(),
 		}
 
 		derivedStreams := d.shardStream(baseStream, streamRate, "fake")
@@ -789,6 +794,7 @@ func BenchmarkShardStream(b *testing.B) {
 			validator:        validator,
 			streamShardCount: prometheus.NewCounter(prometheus.CounterOpts{}),
 			shardTracker:     NewShardTracker(),
+			shardMap:         newShardMap(),
 			// streamSize is always zero, so number of shards will be dictated just by the rate returned from store.
 			rateStore: &fakeRateStore{rate: int64(desiredRate*shards - 1)},
 		}
@@ -1158,7 +1164,8 @@ func TestShardCountFor(t *testing.T) {
 			limits.ShardStreams.DesiredRate = tc.desiredRate
 
 			d := &Distributor{
-				rateStore: &fakeRateStore{tc.rate, tc.pushRate},
+				rateStore: &fakeRateStore{rate: tc.rate, pushRate: tc.pushRate},
+				shardMap:  newShardMap(),
 			}
 			got := d.shardCountFor(util_log.Logger, tc.stream, tc.pushSize, "fake", limits.ShardStreams)
 			require.Equal(t, tc.wantShards, got)
@@ -1439,14 +1446,19 @@ func (i *mockIngester) Close() error {
 }
 
 type fakeRateStore struct {
-	rate     int64
-	pushRate float64
+	rate         int64
+	pushRate     float64
+	ingesterRate int64
 }
 
 func (s *fakeRateStore) RateFor(_ string, _ uint64) (int64, float64) {
 	return s.rate, s.pushRate
 }
 
+func (s *fakeRateStore) IngesterRate() int64 {
+	return s.ingesterRate
+}
+
 type mockTee struct {
 	mu         sync.Mutex
 	duplicated [][]KeyedStream
@@ -1513,6 +1525,48 @@ func TestDistributorTee(t *testing.T) {
 	}
 }
 
+type mockStreamWriter struct {
+	err     error
+	tenant  string
+	written []kafka.Stream
+}
+
+func (w *mockStreamWriter) Write(_ context.Context, tenant string, streams []kafka.Stream) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.tenant = tenant
+	w.written = append(w.written, streams...)
+	return nil
+}
+
+func TestDistributor_KafkaWriter(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	distributors, ingesters := prepare(t, 1, 3, limits, nil)
+
+	writer := &mockStreamWriter{}
+	distributors[0].kafkaWriter = writer
+
+	_, err := distributors[0].Push(ctx, makeWriteRequestWithLabels(10, 10, []string{`{foo="bar"}`, `{foo="baz"}`}))
+	require.NoError(t, err)
+	require.Equal(t, "test", writer.tenant)
+	require.Len(t, writer.written, 2)
+	for _, s := range writer.written {
+		require.Equal(t, lokiring.TokenFor("test", s.Stream.Labels), s.HashKey)
+		require.Len(t, s.Stream.Entries, 10)
+	}
+	for i := range ingesters {
+		require.Empty(t, ingesters[i].pushed, "streams written to kafka must not be pushed to ingesters")
+	}
+
+	writer.err = errors.New("broker unavailable")
+	_, err = distributors[0].Push(ctx, makeWriteRequestWithLabels(10, 10, []string{`{foo="bar"}`}))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusServiceUnavailable), resp.Code)
+}
+
 func Test_DetectLogLevels(t *testing.T) {
 	setup := func(discoverLogLevels bool) (*validation.Limits, *mockIngester) {
 		limits := &validation.Limits{}
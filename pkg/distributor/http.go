@@ -99,6 +99,47 @@ func (d *Distributor) pushHandler(w http.ResponseWriter, r *http.Request, pushRe
 	}
 }
 
+// AttributionResponse is the response of the attribution endpoint.
+type AttributionResponse struct {
+	Tenant       string             `json:"tenant"`
+	Attributions []AttributionUsage `json:"attributions"`
+}
+
+// AttributionHandler returns the usage of the tenant per attribution value
+// accounted by this distributor since it started.
+func (d *Distributor) AttributionHandler(w http.ResponseWriter, r *http.Request) {
+	if d.attribution == nil {
+		http.Error(w, "attribution tracking is disabled", http.StatusNotFound)
+		return
+	}
+
+	tenantID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteJSONResponse(w, AttributionResponse{
+		Tenant:       tenantID,
+		Attributions: d.attribution.Usage(tenantID),
+	})
+}
+
+// ShardMapHandler returns the current shards of the sharded streams of the
+// tenant.
+func (d *Distributor) ShardMapHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteJSONResponse(w, ShardMapResponse{
+		Tenant:  tenantID,
+		Streams: d.shardMap.Streams(tenantID),
+	})
+}
+
 // ServeHTTP implements the distributor ring status page.
 //
 // If the rate limiting strategy is local instead of global, no ring is used by
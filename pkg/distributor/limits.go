@@ -6,6 +6,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
 	"github.com/grafana/loki/v3/pkg/distributor/shardstreams"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
+	"github.com/grafana/loki/v3/pkg/validation"
 )
 
 // Limits is an interface for distributor limits/related configs
@@ -25,7 +26,16 @@ type Limits interface {
 	DiscoverServiceName(userID string) []string
 	DiscoverLogLevels(userID string) bool
 
+	AttributionLabels(userID string) []string
+	AttributionRateBytes(userID string) float64
+	AttributionBurstSizeBytes(userID string) int
+
+	StructuredMetadataExtractionFormat(userID string) string
+	StructuredMetadataExtractionFields(userID string) []string
+	StructuredMetadataExtractionStrip(userID string) bool
+
 	ShardStreams(userID string) *shardstreams.Config
+	PerStreamRateLimit(userID string) validation.RateLimit
 	IngestionRateStrategy() string
 	IngestionRateBytes(userID string) float64
 	IngestionBurstSizeBytes(userID string) int
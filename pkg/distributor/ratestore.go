@@ -63,6 +63,7 @@ type rateStore struct {
 	ring            ring.ReadRing
 	clientPool      poolClientFactory
 	rates           map[string]map[uint64]expiringRate // tenant id -> fingerprint -> rate
+	ingesterRate    int64                              // mean rate of the ingesters
 	rateLock        sync.RWMutex
 	rateKeepAlive   time.Duration
 	ingesterTimeout time.Duration
@@ -311,7 +312,7 @@ func (s *rateStore) getRatesFromIngesters(ctx context.Context, clients chan inge
 }
 
 func (s *rateStore) ratesPerStream(responses chan *logproto.StreamRatesResponse, totalResponses int) map[string]map[uint64]*logproto.StreamRate {
-	var maxRate int64
+	var maxRate, totalRate, responded int64
 	streamRates := map[string]map[uint64]*logproto.StreamRate{}
 	for i := 0; i < totalResponses; i++ {
 		resp := <-responses
@@ -319,8 +320,10 @@ func (s *rateStore) ratesPerStream(responses chan *logproto.StreamRatesResponse,
 			continue
 		}
 
+		responded++
 		for _, rate := range resp.StreamRates {
 			maxRate = max(maxRate, rate.Rate)
+			totalRate += rate.Rate
 
 			if _, ok := streamRates[rate.Tenant]; !ok {
 				streamRates[rate.Tenant] = map[uint64]*logproto.StreamRate{}
@@ -338,6 +341,14 @@ func (s *rateStore) ratesPerStream(responses chan *logproto.StreamRatesResponse,
 	}
 
 	s.metrics.maxUniqueStreamRate.Set(float64(maxRate))
+
+	// Every ingester reports the streams it holds, replicas included, so the
+	// rates it reports add up to what it ingests.
+	if responded > 0 {
+		s.rateLock.Lock()
+		s.ingesterRate = weightedMovingAverage(totalRate/responded, s.ingesterRate)
+		s.rateLock.Unlock()
+	}
 	return streamRates
 }
 
@@ -378,3 +389,10 @@ func (s *rateStore) RateFor(tenant string, streamHash uint64) (int64, float64) {
 
 	return 0, 0
 }
+
+func (s *rateStore) IngesterRate() int64 {
+	s.rateLock.RLock()
+	defer s.rateLock.RUnlock()
+
+	return s.ingesterRate
+}
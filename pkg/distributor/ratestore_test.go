@@ -66,6 +66,29 @@ func TestRateStore(t *testing.T) {
 		requireRatesAndPushesEqual(t, 45, 40, tc.rateStore, "tenant 2", 3)
 	})
 
+	t.Run("it reports the mean rate of the ingesters", func(t *testing.T) {
+		tc := setup(true)
+		tc.ring.replicationSet = ring.ReplicationSet{
+			Instances: []ring.InstanceDesc{
+				{Addr: "ingester0"},
+				{Addr: "ingester1"},
+			},
+		}
+
+		tc.clientPool.clients = map[string]client.PoolClient{
+			"ingester0": newRateClient([]*logproto.StreamRate{
+				{Tenant: "tenant 1", StreamHash: 0, StreamHashNoShard: 0, Rate: 100},
+				{Tenant: "tenant 2", StreamHash: 0, StreamHashNoShard: 0, Rate: 100},
+			}),
+			"ingester1": newRateClient([]*logproto.StreamRate{
+				{Tenant: "tenant 1", StreamHash: 0, StreamHashNoShard: 0, Rate: 100},
+			}),
+		}
+
+		require.NoError(t, tc.rateStore.instrumentedUpdateAllRates(context.Background()))
+		require.EqualValues(t, weightedMovingAverage(150, 0), tc.rateStore.IngesterRate())
+	})
+
 	t.Run("it reports the highest rate from replicas", func(t *testing.T) {
 		tc := setup(true)
 		tc.ring.replicationSet = ring.ReplicationSet{
@@ -333,6 +356,10 @@ func (c *fakeStreamDataClient) GetStreamRates(_ context.Context, _ *logproto.Str
 	return c.resp, c.err
 }
 
+func (c *fakeStreamDataClient) GetStreamChunks(_ context.Context, _ *logproto.StreamChunksRequest, _ ...grpc.CallOption) (*logproto.StreamChunksResponse, error) {
+	return &logproto.StreamChunksResponse{}, nil
+}
+
 type fakeOverrides struct {
 	Limits
 	enabled bool
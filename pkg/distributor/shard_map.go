@@ -0,0 +1,155 @@
+package distributor
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// shardMapKeepAlive is how long the shards of a stream that isn't pushed
+// anymore are remembered.
+const shardMapKeepAlive = 10 * time.Minute
+
+// StreamShards is the current sharding of a stream.
+type StreamShards struct {
+	Stream    string    `json:"stream"`
+	Shards    int       `json:"shards"`
+	Rate      int64     `json:"rate"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// MergingSince is set when the stream needs fewer shards than it has and
+	// they are about to be merged back.
+	MergingSince *time.Time `json:"mergingSince,omitempty"`
+}
+
+// ShardMapResponse is the response of the shard map admin endpoint.
+type ShardMapResponse struct {
+	Tenant  string         `json:"tenant"`
+	Streams []StreamShards `json:"streams"`
+}
+
+type shardMapKey struct {
+	tenant     string
+	streamHash uint64
+}
+
+type shardMapEntry struct {
+	stream    string
+	shards    int
+	rate      int64
+	updatedAt time.Time
+	lowSince  time.Time
+}
+
+// shardMap keeps track of the number of shards of the sharded streams. The
+// shard count of a stream grows as soon as its rate requires it, but it only
+// shrinks once the stream needed fewer shards for the merge delay, so that
+// the shards don't flap when the rate fluctuates. Streams that aren't sharded
+// aren't tracked.
+type shardMap struct {
+	size    int
+	entries []map[shardMapKey]*shardMapEntry
+	locks   []stripeLock
+}
+
+func newShardMap() *shardMap {
+	m := &shardMap{
+		size:    defaultStripeSize,
+		entries: make([]map[shardMapKey]*shardMapEntry, defaultStripeSize),
+		locks:   make([]stripeLock, defaultStripeSize),
+	}
+
+	for i := 0; i < defaultStripeSize; i++ {
+		m.entries[i] = make(map[shardMapKey]*shardMapEntry)
+	}
+
+	return m
+}
+
+// Update records the number of shards the stream needs for its current rate
+// and returns the number of shards to use, along with whether shards were
+// merged back.
+func (m *shardMap) Update(tenant, stream string, streamHash uint64, desired int, rate int64, mergeDelay time.Duration, now time.Time) (int, bool) {
+	i := streamHash & uint64(m.size-1)
+	key := shardMapKey{tenant: tenant, streamHash: streamHash}
+
+	m.locks[i].Lock()
+	defer m.locks[i].Unlock()
+
+	e, ok := m.entries[i][key]
+	if !ok {
+		if desired <= 1 {
+			return 1, false
+		}
+		e = &shardMapEntry{stream: stream}
+		m.entries[i][key] = e
+	}
+	e.rate = rate
+	e.updatedAt = now
+
+	if desired >= e.shards {
+		e.shards = desired
+		e.lowSince = time.Time{}
+		return e.shards, false
+	}
+
+	if e.lowSince.IsZero() {
+		e.lowSince = now
+	}
+	if now.Sub(e.lowSince) < mergeDelay {
+		return e.shards, false
+	}
+
+	if desired <= 1 {
+		delete(m.entries[i], key)
+		return 1, true
+	}
+	e.shards = desired
+	e.lowSince = time.Time{}
+	return e.shards, true
+}
+
+// Streams returns the sharded streams of the tenant, ordered by stream.
+func (m *shardMap) Streams(tenant string) []StreamShards {
+	streams := []StreamShards{}
+	for i := range m.entries {
+		m.locks[i].RLock()
+		for key, e := range m.entries[i] {
+			if key.tenant != tenant {
+				continue
+			}
+			s := StreamShards{
+				Stream:    e.stream,
+				Shards:    e.shards,
+				Rate:      e.rate,
+				UpdatedAt: e.updatedAt,
+			}
+			if !e.lowSince.IsZero() {
+				lowSince := e.lowSince
+				s.MergingSince = &lowSince
+			}
+			streams = append(streams, s)
+		}
+		m.locks[i].RUnlock()
+	}
+
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Stream < streams[j].Stream })
+	return streams
+}
+
+// expire forgets the streams that haven't been pushed since the given time.
+func (m *shardMap) expire(before time.Time) {
+	for i := range m.entries {
+		m.locks[i].Lock()
+		for key, e := range m.entries[i] {
+			if e.updatedAt.Before(before) {
+				delete(m.entries[i], key)
+			}
+		}
+		m.locks[i].Unlock()
+	}
+}
+
+func (m *shardMap) expireStale(_ context.Context) error {
+	m.expire(time.Now().Add(-shardMapKeepAlive))
+	return nil
+}
@@ -0,0 +1,141 @@
+package distributor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func TestShardMap(t *testing.T) {
+	m := newShardMap()
+	now := time.Now()
+	delay := time.Minute
+
+	shards, merged := m.Update("tenant 1", `{app="foo"}`, 1, 1, 100, delay, now)
+	require.Equal(t, 1, shards)
+	require.False(t, merged)
+	require.Empty(t, m.Streams("tenant 1"), "streams that aren't sharded aren't tracked")
+
+	shards, _ = m.Update("tenant 1", `{app="foo"}`, 1, 4, 400, delay, now)
+	require.Equal(t, 4, shards)
+	shards, _ = m.Update("tenant 1", `{app="foo"}`, 1, 6, 600, delay, now)
+	require.Equal(t, 6, shards, "shards grow immediately")
+
+	// The stream cools down, the shards are kept until the merge delay elapsed.
+	shards, merged = m.Update("tenant 1", `{app="foo"}`, 1, 3, 300, delay, now.Add(time.Second))
+	require.Equal(t, 6, shards)
+	require.False(t, merged)
+	streams := m.Streams("tenant 1")
+	require.Len(t, streams, 1)
+	require.NotNil(t, streams[0].MergingSince)
+
+	shards, merged = m.Update("tenant 1", `{app="foo"}`, 1, 3, 300, delay, now.Add(time.Second+delay))
+	require.Equal(t, 3, shards)
+	require.True(t, merged)
+	require.Equal(t, []StreamShards{{Stream: `{app="foo"}`, Shards: 3, Rate: 300, UpdatedAt: now.Add(time.Second + delay)}}, m.Streams("tenant 1"))
+
+	// A spike while merging resets the delay.
+	m.Update("tenant 1", `{app="foo"}`, 1, 2, 200, delay, now.Add(2*delay))
+	m.Update("tenant 1", `{app="foo"}`, 1, 3, 300, delay, now.Add(2*delay+time.Second))
+	shards, merged = m.Update("tenant 1", `{app="foo"}`, 1, 2, 200, delay, now.Add(3*delay))
+	require.Equal(t, 3, shards)
+	require.False(t, merged)
+
+	// Merging back to a single shard forgets the stream.
+	shards, merged = m.Update("tenant 1", `{app="foo"}`, 1, 1, 100, delay, now.Add(4*delay))
+	require.Equal(t, 1, shards)
+	require.True(t, merged)
+	require.Empty(t, m.Streams("tenant 1"))
+
+	m.Update("tenant 1", `{app="foo"}`, 1, 2, 200, delay, now)
+	m.Update("tenant 1", `{app="bar"}`, 2, 2, 200, delay, now.Add(delay))
+	m.Update("tenant 2", `{app="foo"}`, 1, 2, 200, delay, now)
+	require.Len(t, m.Streams("tenant 1"), 2)
+	require.Equal(t, `{app="bar"}`, m.Streams("tenant 1")[0].Stream)
+
+	m.expire(now.Add(time.Second))
+	streams = m.Streams("tenant 1")
+	require.Len(t, streams, 1)
+	require.Equal(t, `{app="bar"}`, streams[0].Stream)
+	require.Empty(t, m.Streams("tenant 2"))
+}
+
+func TestShardCountFor_Adaptive(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ShardStreams.AdaptiveEnabled = true
+	limits.ShardStreams.TargetUtilization = 0.5
+	limits.ShardStreams.MergeDelay = 0
+	require.NoError(t, limits.PerStreamRateLimit.Set("100B"))
+
+	overrides, err := validation.NewOverrides(*limits, nil)
+	require.NoError(t, err)
+	validator, err := NewValidator(overrides, nil)
+	require.NoError(t, err)
+
+	rateStore := &fakeRateStore{rate: 240, pushRate: 1}
+	d := &Distributor{
+		rateStore:             rateStore,
+		validator:             validator,
+		shardMap:              newShardMap(),
+		streamShardMergeCount: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+	stream := &logproto.Stream{Labels: `{app="foo"}`, Hash: 1}
+
+	// Each shard is sized for half of the per-stream rate limit.
+	require.Equal(t, 6, d.shardCountFor(util_log.Logger, stream, 60, "fake", limits.ShardStreams))
+
+	rateStore.rate = 40
+	require.Equal(t, 1, d.shardCountFor(util_log.Logger, stream, 10, "fake", limits.ShardStreams))
+
+	rec := httptest.NewRecorder()
+	d.ShardMapHandler(rec, httptest.NewRequest(http.MethodGet, "/distributor/shard_map", nil).WithContext(ctx))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"tenant":"test","streams":[]}`, rec.Body.String())
+}
+
+func TestShardCountFor_AdaptiveIngesterCapacity(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ShardStreams.AdaptiveEnabled = true
+	limits.ShardStreams.TargetUtilization = 0.5
+	limits.ShardStreams.MergeDelay = 0
+	require.NoError(t, limits.PerStreamRateLimit.Set("100B"))
+	require.NoError(t, limits.ShardStreams.IngesterRateCapacity.Set("120B"))
+
+	overrides, err := validation.NewOverrides(*limits, nil)
+	require.NoError(t, err)
+	validator, err := NewValidator(overrides, nil)
+	require.NoError(t, err)
+
+	// Two ingesters ingest 80B between them, leaving 20B of the 60B they are
+	// sized for.
+	rateStore := &fakeRateStore{rate: 240, pushRate: 1, ingesterRate: 40}
+	d := &Distributor{
+		rateStore:             rateStore,
+		validator:             validator,
+		shardMap:              newShardMap(),
+		streamShardMergeCount: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+	stream := &logproto.Stream{Labels: `{app="foo"}`, Hash: 1}
+
+	require.Equal(t, 13, d.shardCountFor(util_log.Logger, stream, 20, "fake", limits.ShardStreams))
+
+	// Two more ingesters double the capacity and halve the load of each.
+	rateStore.ingesterRate = 20
+	require.Equal(t, 7, d.shardCountFor(util_log.Logger, stream, 20, "fake", limits.ShardStreams))
+
+	// Without spare capacity, shards are sized for a tenth of the rate derived
+	// from the per-stream rate limit.
+	rateStore.ingesterRate = 60
+	require.Equal(t, 52, d.shardCountFor(util_log.Logger, stream, 20, "fake", limits.ShardStreams))
+}
@@ -2,6 +2,8 @@ package shardstreams
 
 import (
 	"flag"
+	"math"
+	"time"
 
 	"github.com/grafana/loki/v3/pkg/util/flagext"
 )
@@ -13,6 +15,21 @@ type Config struct {
 	// DesiredRate is the threshold used to shard the stream into smaller pieces.
 	// Expected to be in bytes.
 	DesiredRate flagext.ByteSize `yaml:"desired_rate" json:"desired_rate"`
+
+	// AdaptiveEnabled derives the threshold from the per-stream rate limit of
+	// the tenant and the load of the ingesters instead of using DesiredRate.
+	AdaptiveEnabled bool `yaml:"adaptive_enabled" json:"adaptive_enabled"`
+	// TargetUtilization is the fraction of the per-stream rate limit and of the
+	// ingester rate capacity each shard is sized for when adaptive sharding is
+	// enabled.
+	TargetUtilization float64 `yaml:"target_utilization" json:"target_utilization"`
+	// IngesterRateCapacity is the rate in bytes each ingester is sized for. The
+	// shards are sized for the spare capacity of the ingesters when it is set.
+	IngesterRateCapacity flagext.ByteSize `yaml:"ingester_rate_capacity" json:"ingester_rate_capacity"`
+
+	// MergeDelay is how long a stream has to need fewer shards before they are
+	// merged back.
+	MergeDelay time.Duration `yaml:"merge_delay" json:"merge_delay"`
 }
 
 func (cfg *Config) RegisterFlagsWithPrefix(prefix string, fs *flag.FlagSet) {
@@ -20,4 +37,29 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, fs *flag.FlagSet) {
 	fs.BoolVar(&cfg.LoggingEnabled, prefix+".logging-enabled", false, "Enable logging when sharding streams")
 	cfg.DesiredRate.Set("1536KB") //nolint:errcheck
 	fs.Var(&cfg.DesiredRate, prefix+".desired-rate", "threshold used to cut a new shard. Default (1536KB) means if a rate is above 1536KB/s, it will be sharded.")
+	fs.BoolVar(&cfg.AdaptiveEnabled, prefix+".adaptive-enabled", false, "Use the per-stream rate limit of the tenant multiplied by the target utilization as the threshold instead of the desired rate, so the shard count is the current rate of the stream divided by it. If the ingester rate capacity is set, the threshold is lowered to the spare capacity of the ingesters.")
+	fs.Float64Var(&cfg.TargetUtilization, prefix+".target-utilization", 0.75, "Fraction of the per-stream rate limit and of the ingester rate capacity each shard is sized for when adaptive sharding is enabled. Lower values leave more headroom for bursts at the cost of more streams.")
+	fs.Var(&cfg.IngesterRateCapacity, prefix+".ingester-rate-capacity", "The rate in bytes per second each ingester is sized for. When set with adaptive sharding, each shard is sized for at most the spare capacity of the ingesters, the capacity multiplied by the target utilization minus the mean rate of the healthy ingesters, so streams are split further as the ingesters get busier and merged back when ingesters are added. Shards are never sized for less than a tenth of the rate derived from the per-stream rate limit. 0 to disable.")
+	fs.DurationVar(&cfg.MergeDelay, prefix+".merge-delay", 5*time.Minute, "How long a stream has to need fewer shards before its shards are merged back. Prevents the shard count from flapping when the rate fluctuates. 0 merges immediately.")
+}
+
+// minSpareShardRateFraction bounds how far the spare capacity of the ingesters
+// lowers the rate each shard is sized for, so streams aren't split in more and
+// more shards when the ingesters are overloaded.
+const minSpareShardRateFraction = 0.1
+
+// AdaptiveShardRate returns the rate in bytes each shard of a stream is sized
+// for when adaptive sharding is enabled, given the per-stream rate limit of the
+// tenant and the mean rate in bytes ingested by the healthy ingesters.
+func (cfg *Config) AdaptiveShardRate(perStreamRateLimit float64, ingesterRate int64) int {
+	utilization := cfg.TargetUtilization
+	if utilization <= 0 || utilization > 1 {
+		utilization = 1
+	}
+	rate := perStreamRateLimit * utilization
+	if capacity := float64(cfg.IngesterRateCapacity.Val()); capacity > 0 {
+		spare := capacity*utilization - float64(ingesterRate)
+		rate = math.Min(rate, math.Max(spare, rate*minSpareShardRateFraction))
+	}
+	return int(rate)
 }
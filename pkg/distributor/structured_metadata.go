@@ -0,0 +1,121 @@
+package distributor
+
+import (
+	"bytes"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/jsonparser"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	logfmtdecoder "github.com/grafana/loki/v3/pkg/logql/log/logfmt"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// structuredMetadataExtractor promotes an allowlist of fields of JSON or logfmt
+// log lines to structured metadata. It reuses the LogQL parsers, so fields are
+// named the same way as by the json and logfmt query stages.
+// It is not safe for concurrent use.
+type structuredMetadataExtractor struct {
+	format string
+	fields map[string]struct{}
+	strip  bool
+
+	stage   log.Stage
+	builder *log.LabelsBuilder
+	decoder *logfmtdecoder.Decoder
+	buf     bytes.Buffer
+}
+
+// newStructuredMetadataExtractor returns an extractor for the given format and
+// fields, or nil if the extraction is disabled.
+func newStructuredMetadataExtractor(format string, fields []string, strip bool) *structuredMetadataExtractor {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var stage log.Stage
+	switch format {
+	case validation.StructuredMetadataExtractionJSON:
+		stage = log.NewJSONParser()
+	case validation.StructuredMetadataExtractionLogfmt:
+		stage = log.NewLogfmtParser(false, false)
+	default:
+		return nil
+	}
+
+	// The hints make the parsers skip the fields that are not in the allowlist
+	// and stop as soon as all of them have been found.
+	hints := log.NewParserHint(fields, fields, false, false, "", nil)
+	allowed := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		allowed[f] = struct{}{}
+	}
+	return &structuredMetadataExtractor{
+		format:  format,
+		fields:  allowed,
+		strip:   strip,
+		stage:   stage,
+		builder: log.NewBaseLabelsBuilderWithGrouping(nil, hints, false, false).ForLabels(labels.EmptyLabels(), 0),
+		decoder: logfmtdecoder.NewDecoder(nil),
+	}
+}
+
+// Extract adds the allowlisted fields of the entry line to its structured
+// metadata, unless they are already present, and strips them from the line if
+// configured. Lines that can't be parsed are left untouched.
+func (e *structuredMetadataExtractor) Extract(entry *logproto.Entry) {
+	e.builder.Reset()
+	line := []byte(entry.Line)
+	e.stage.Process(0, line, e.builder)
+	if e.builder.HasErr() {
+		return
+	}
+
+	parsed := e.builder.LabelsResult().Parsed()
+
+	existing := logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)
+	extracted := make(map[string]struct{}, len(parsed))
+	for _, l := range parsed {
+		if _, ok := e.fields[l.Name]; !ok {
+			continue
+		}
+		extracted[l.Name] = struct{}{}
+		if existing.Has(l.Name) {
+			continue
+		}
+		entry.StructuredMetadata = append(entry.StructuredMetadata, logproto.LabelAdapter{Name: l.Name, Value: l.Value})
+	}
+
+	if e.strip && len(extracted) > 0 {
+		entry.Line = e.stripFields(line, extracted)
+	}
+}
+
+func (e *structuredMetadataExtractor) stripFields(line []byte, fields map[string]struct{}) string {
+	if e.format == validation.StructuredMetadataExtractionJSON {
+		for field := range fields {
+			// Only top-level fields can be removed, nested fields are named
+			// after their path which can't be mapped back to the line.
+			line = jsonparser.Delete(line, field)
+		}
+		return string(line)
+	}
+
+	e.buf.Reset()
+	enc := logfmt.NewEncoder(&e.buf)
+	e.decoder.Reset(line)
+	for !e.decoder.EOL() {
+		if !e.decoder.ScanKeyval() {
+			continue
+		}
+		if _, ok := fields[string(e.decoder.Key())]; ok {
+			continue
+		}
+		if err := enc.EncodeKeyval(e.decoder.Key(), e.decoder.Value()); err != nil {
+			return string(line)
+		}
+	}
+	return e.buf.String()
+}
@@ -0,0 +1,136 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/grafana/dskit/flagext"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func Test_StructuredMetadataExtractor(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		format   string
+		fields   []string
+		strip    bool
+		entry    logproto.Entry
+		expected logproto.Entry
+	}{
+		{
+			name:   "json",
+			format: validation.StructuredMetadataExtractionJSON,
+			fields: []string{"trace_id", "request_status"},
+			entry:  logproto.Entry{Line: `{"msg":"done","trace_id":"abc","request":{"status":200}}`},
+			expected: logproto.Entry{
+				Line: `{"msg":"done","trace_id":"abc","request":{"status":200}}`,
+				StructuredMetadata: push.LabelsAdapter{
+					{Name: "trace_id", Value: "abc"},
+					{Name: "request_status", Value: "200"},
+				},
+			},
+		},
+		{
+			name:   "json strip",
+			format: validation.StructuredMetadataExtractionJSON,
+			fields: []string{"trace_id"},
+			strip:  true,
+			entry:  logproto.Entry{Line: `{"msg":"done","trace_id":"abc"}`},
+			expected: logproto.Entry{
+				Line:               `{"msg":"done"}`,
+				StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}},
+			},
+		},
+		{
+			name:   "logfmt",
+			format: validation.StructuredMetadataExtractionLogfmt,
+			fields: []string{"trace_id"},
+			entry:  logproto.Entry{Line: `msg=done trace_id=abc`},
+			expected: logproto.Entry{
+				Line:               `msg=done trace_id=abc`,
+				StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}},
+			},
+		},
+		{
+			name:   "logfmt strip",
+			format: validation.StructuredMetadataExtractionLogfmt,
+			fields: []string{"trace_id"},
+			strip:  true,
+			entry:  logproto.Entry{Line: `msg="all done" trace_id=abc level=info`},
+			expected: logproto.Entry{
+				Line:               `msg="all done" level=info`,
+				StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}},
+			},
+		},
+		{
+			name:   "existing structured metadata is kept",
+			format: validation.StructuredMetadataExtractionLogfmt,
+			fields: []string{"trace_id"},
+			entry: logproto.Entry{
+				Line:               `msg=done trace_id=abc`,
+				StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "def"}},
+			},
+			expected: logproto.Entry{
+				Line:               `msg=done trace_id=abc`,
+				StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "def"}},
+			},
+		},
+		{
+			name:     "no matching field",
+			format:   validation.StructuredMetadataExtractionJSON,
+			fields:   []string{"trace_id"},
+			strip:    true,
+			entry:    logproto.Entry{Line: `{"msg":"done"}`},
+			expected: logproto.Entry{Line: `{"msg":"done"}`},
+		},
+		{
+			name:     "unparseable line",
+			format:   validation.StructuredMetadataExtractionJSON,
+			fields:   []string{"trace_id"},
+			strip:    true,
+			entry:    logproto.Entry{Line: `trace_id=abc`},
+			expected: logproto.Entry{Line: `trace_id=abc`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			extractor := newStructuredMetadataExtractor(tc.format, tc.fields, tc.strip)
+			require.NotNil(t, extractor)
+
+			entry := tc.entry
+			extractor.Extract(&entry)
+			require.Equal(t, tc.expected.Line, entry.Line)
+			require.ElementsMatch(t, tc.expected.StructuredMetadata, entry.StructuredMetadata)
+		})
+	}
+
+	require.Nil(t, newStructuredMetadataExtractor(validation.StructuredMetadataExtractionJSON, nil, false))
+	require.Nil(t, newStructuredMetadataExtractor("", []string{"trace_id"}, false))
+}
+
+func TestDistributor_StructuredMetadataExtraction(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.DiscoverLogLevels = false
+	limits.DiscoverServiceName = nil
+	limits.AllowStructuredMetadata = true
+	limits.StructuredMetadataExtractionFormat = validation.StructuredMetadataExtractionLogfmt
+	limits.StructuredMetadataExtractionFields = []string{"trace_id"}
+	limits.StructuredMetadataExtractionStrip = true
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	req := makeWriteRequestWithLabels(1, 10, []string{`{foo="bar"}`})
+	req.Streams[0].Entries[0].Line = `msg=done trace_id=abc`
+	_, err := distributors[0].Push(ctx, req)
+	require.NoError(t, err)
+
+	pushed := ingester.Peek()
+	require.Equal(t, `msg=done`, pushed.Streams[0].Entries[0].Line)
+	require.Equal(t, push.LabelsAdapter{{Name: "trace_id", Value: "abc"}}, pushed.Streams[0].Entries[0].StructuredMetadata)
+}
@@ -0,0 +1,555 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/influxdata/go-syslog/v3"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
+	"github.com/influxdata/go-syslog/v3/rfc5424"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/clients/pkg/promtail/targets/syslog/syslogparser"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+const (
+	syslogProtocolTCP = "tcp"
+	syslogProtocolUDP = "udp"
+
+	syslogFormatRFC5424 = "rfc5424"
+	syslogFormatRFC3164 = "rfc3164"
+)
+
+// SyslogConfig configures the syslog listeners of the distributor.
+type SyslogConfig struct {
+	Listeners []SyslogListenerConfig `yaml:"listeners" doc:"description=Syslog listeners. Received messages are pushed to the tenant of the listener through the regular validation and rate limiting of the distributor."`
+	BatchWait time.Duration          `yaml:"batch_wait"`
+	BatchSize int                    `yaml:"batch_size"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by
+// prefix. If prefix is a non-empty string, prefix should end with a period.
+func (cfg *SyslogConfig) RegisterFlagsWithPrefix(prefix string, fs *flag.FlagSet) {
+	fs.DurationVar(&cfg.BatchWait, prefix+".batch-wait", time.Second, "Maximum time syslog messages are buffered before being pushed.")
+	fs.IntVar(&cfg.BatchSize, prefix+".batch-size", 1000, "Maximum number of syslog messages buffered per listener before being pushed.")
+}
+
+// Validate validates the syslog listeners configuration.
+func (cfg *SyslogConfig) Validate() error {
+	names := make(map[string]struct{}, len(cfg.Listeners))
+	for i := range cfg.Listeners {
+		if err := cfg.Listeners[i].Validate(); err != nil {
+			return fmt.Errorf("invalid syslog listener %d: %w", i, err)
+		}
+		if _, ok := names[cfg.Listeners[i].Name]; ok {
+			return fmt.Errorf("duplicate syslog listener name %q", cfg.Listeners[i].Name)
+		}
+		names[cfg.Listeners[i].Name] = struct{}{}
+	}
+	return nil
+}
+
+// SyslogListenerConfig configures a single syslog listener.
+type SyslogListenerConfig struct {
+	Name                 string            `yaml:"name" doc:"description=The unique name of the listener, used as the 'listener' label of its metrics."`
+	ListenAddress        string            `yaml:"listen_address" doc:"description=The address to listen on, for example ':1514'."`
+	Protocol             string            `yaml:"protocol" doc:"description=The transport protocol, either 'tcp' or 'udp'. Defaults to 'tcp'."`
+	Format               string            `yaml:"format" doc:"description=The syslog message format, either 'rfc5424' or 'rfc3164'. Defaults to 'rfc5424'."`
+	Tenant               string            `yaml:"tenant" doc:"description=The tenant the received messages are pushed to."`
+	Labels               map[string]string `yaml:"labels" doc:"description=Static labels added to all the streams of the listener. Defaults to job=syslog."`
+	UseIncomingTimestamp bool              `yaml:"use_incoming_timestamp" doc:"description=Use the timestamp of the messages instead of the time they are received."`
+	IdleTimeout          time.Duration     `yaml:"idle_timeout" doc:"description=Idle timeout of TCP connections. Defaults to 120s."`
+	MaxMessageLength     int               `yaml:"max_message_length" doc:"description=Maximum length of a syslog message in bytes. Defaults to 8192."`
+	TLSCertPath          string            `yaml:"tls_cert_path" doc:"description=Path to the server certificate. Enables TLS on TCP listeners when set."`
+	TLSKeyPath           string            `yaml:"tls_key_path" doc:"description=Path to the server key."`
+	TLSClientCAPath      string            `yaml:"tls_client_ca_path" doc:"description=Path to the CA used to verify client certificates. Client certificates are required when set."`
+}
+
+// Validate validates the listener configuration and sets the defaults.
+func (cfg *SyslogListenerConfig) Validate() error {
+	if cfg.Name == "" {
+		return errors.New("name is required")
+	}
+	if cfg.ListenAddress == "" {
+		return errors.New("listen_address is required")
+	}
+	if cfg.Tenant == "" {
+		return errors.New("tenant is required")
+	}
+
+	switch cfg.Protocol {
+	case "":
+		cfg.Protocol = syslogProtocolTCP
+	case syslogProtocolTCP, syslogProtocolUDP:
+	default:
+		return fmt.Errorf("unsupported protocol %q", cfg.Protocol)
+	}
+
+	switch cfg.Format {
+	case "":
+		cfg.Format = syslogFormatRFC5424
+	case syslogFormatRFC5424, syslogFormatRFC3164:
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+
+	if (cfg.TLSCertPath == "") != (cfg.TLSKeyPath == "") {
+		return errors.New("tls_cert_path and tls_key_path must be set together")
+	}
+	if cfg.TLSCertPath != "" && cfg.Protocol != syslogProtocolTCP {
+		return errors.New("TLS is only supported by tcp listeners")
+	}
+
+	if len(cfg.Labels) == 0 {
+		cfg.Labels = map[string]string{"job": "syslog"}
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 120 * time.Second
+	}
+	if cfg.MaxMessageLength <= 0 {
+		cfg.MaxMessageLength = 8192
+	}
+	return nil
+}
+
+func (cfg *SyslogListenerConfig) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertPath, cfg.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if cfg.TLSClientCAPath != "" {
+		ca, err := os.ReadFile(cfg.TLSClientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in client CA %s", cfg.TLSClientCAPath)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// pushFunc pushes a request to the distributor.
+type pushFunc func(ctx context.Context, req *logproto.PushRequest) (*logproto.PushResponse, error)
+
+type syslogMetrics struct {
+	messages      *prometheus.CounterVec
+	parseErrors   *prometheus.CounterVec
+	pushFailures  *prometheus.CounterVec
+	activeClients *prometheus.GaugeVec
+}
+
+func newSyslogMetrics(registerer prometheus.Registerer) *syslogMetrics {
+	return &syslogMetrics{
+		messages: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_messages_total",
+			Help:      "The total number of syslog messages received per listener.",
+		}, []string{"listener"}),
+		parseErrors: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_parse_errors_total",
+			Help:      "The total number of syslog messages or streams that could not be parsed per listener.",
+		}, []string{"listener"}),
+		pushFailures: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_push_failures_total",
+			Help:      "The total number of syslog messages that could not be pushed per listener.",
+		}, []string{"listener"}),
+		activeClients: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_active_connections",
+			Help:      "The number of open syslog TCP connections per listener.",
+		}, []string{"listener"}),
+	}
+}
+
+// SyslogServer runs the syslog listeners of the distributor.
+type SyslogServer struct {
+	services.Service
+
+	cfg       SyslogConfig
+	listeners []*syslogListener
+}
+
+// NewSyslogServer makes a new SyslogServer pushing the received messages with push.
+func NewSyslogServer(cfg SyslogConfig, push pushFunc, registerer prometheus.Registerer, logger log.Logger) (*SyslogServer, error) {
+	cfg.Listeners = append([]SyslogListenerConfig(nil), cfg.Listeners...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	metrics := newSyslogMetrics(registerer)
+
+	s := &SyslogServer{cfg: cfg}
+	for _, lcfg := range cfg.Listeners {
+		s.listeners = append(s.listeners, &syslogListener{
+			cfg:       lcfg,
+			batchWait: cfg.BatchWait,
+			batchSize: cfg.BatchSize,
+			push:      push,
+			logger:    log.With(logger, "component", "syslog", "listener", lcfg.Name, "address", lcfg.ListenAddress, "protocol", lcfg.Protocol),
+			metrics:   metrics,
+			entries:   make(chan syslogEntry),
+			conns:     make(map[net.Conn]struct{}),
+		})
+	}
+	s.Service = services.NewBasicService(s.starting, s.running, s.stopping)
+	return s, nil
+}
+
+func (s *SyslogServer) starting(_ context.Context) error {
+	for i, l := range s.listeners {
+		if err := l.listen(); err != nil {
+			for _, started := range s.listeners[:i] {
+				started.close()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SyslogServer) running(ctx context.Context) error {
+	for _, l := range s.listeners {
+		l.run()
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (s *SyslogServer) stopping(_ error) error {
+	for _, l := range s.listeners {
+		l.close()
+	}
+	return nil
+}
+
+// Addrs returns the addresses the listeners are listening on.
+func (s *SyslogServer) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		addrs = append(addrs, l.addr())
+	}
+	return addrs
+}
+
+type syslogEntry struct {
+	labels string
+	entry  logproto.Entry
+}
+
+type syslogListener struct {
+	cfg       SyslogListenerConfig
+	batchWait time.Duration
+	batchSize int
+	push      pushFunc
+	logger    log.Logger
+	metrics   *syslogMetrics
+
+	listener   net.Listener
+	packetConn net.PacketConn
+
+	entries   chan syslogEntry
+	readers   sync.WaitGroup
+	batchDone chan struct{}
+
+	mtx    sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func (l *syslogListener) listen() error {
+	var err error
+	if l.cfg.Protocol == syslogProtocolUDP {
+		l.packetConn, err = net.ListenPacket("udp", l.cfg.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("syslog listener %s: %w", l.cfg.ListenAddress, err)
+		}
+		return nil
+	}
+
+	l.listener, err = net.Listen("tcp", l.cfg.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("syslog listener %s: %w", l.cfg.ListenAddress, err)
+	}
+	if l.cfg.TLSCertPath != "" {
+		tlsCfg, err := l.cfg.tlsConfig()
+		if err != nil {
+			_ = l.listener.Close()
+			return fmt.Errorf("syslog listener %s: %w", l.cfg.ListenAddress, err)
+		}
+		l.listener = tls.NewListener(l.listener, tlsCfg)
+	}
+	return nil
+}
+
+func (l *syslogListener) addr() net.Addr {
+	if l.packetConn != nil {
+		return l.packetConn.LocalAddr()
+	}
+	return l.listener.Addr()
+}
+
+func (l *syslogListener) run() {
+	l.batchDone = make(chan struct{})
+	go l.batchLoop()
+
+	l.readers.Add(1)
+	if l.packetConn != nil {
+		go l.readPackets()
+	} else {
+		go l.acceptConnections()
+	}
+}
+
+// close stops accepting messages, waits for the open connections to be
+// drained and pushes the remaining messages.
+func (l *syslogListener) close() {
+	l.mtx.Lock()
+	l.closed = true
+	if l.listener != nil {
+		_ = l.listener.Close()
+	}
+	if l.packetConn != nil {
+		_ = l.packetConn.Close()
+	}
+	for c := range l.conns {
+		_ = c.Close()
+	}
+	l.mtx.Unlock()
+
+	l.readers.Wait()
+	if l.batchDone != nil {
+		close(l.entries)
+		<-l.batchDone
+	}
+}
+
+func (l *syslogListener) acceptConnections() {
+	defer l.readers.Done()
+
+	for {
+		c, err := l.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				level.Warn(l.logger).Log("msg", "failed to accept syslog connection", "err", err)
+			}
+			return
+		}
+
+		l.mtx.Lock()
+		if l.closed {
+			l.mtx.Unlock()
+			_ = c.Close()
+			return
+		}
+		l.conns[c] = struct{}{}
+		l.readers.Add(1)
+		l.mtx.Unlock()
+
+		go l.handleConnection(c)
+	}
+}
+
+func (l *syslogListener) handleConnection(c net.Conn) {
+	defer l.readers.Done()
+	defer func() {
+		l.mtx.Lock()
+		delete(l.conns, c)
+		l.mtx.Unlock()
+		_ = c.Close()
+	}()
+
+	l.metrics.activeClients.WithLabelValues(l.cfg.Name).Inc()
+	defer l.metrics.activeClients.WithLabelValues(l.cfg.Name).Dec()
+
+	host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+	err := l.parse(&idleTimeoutConn{Conn: c, timeout: l.cfg.IdleTimeout}, host)
+	var ne net.Error
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !(errors.As(err, &ne) && ne.Timeout()) {
+		l.metrics.parseErrors.WithLabelValues(l.cfg.Name).Inc()
+		level.Warn(l.logger).Log("msg", "error parsing syslog stream", "remote", c.RemoteAddr(), "err", err)
+	}
+}
+
+func (l *syslogListener) readPackets() {
+	defer l.readers.Done()
+
+	buf := make([]byte, l.cfg.MaxMessageLength)
+	for {
+		n, addr, err := l.packetConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				level.Warn(l.logger).Log("msg", "failed to read syslog packet", "err", err)
+			}
+			return
+		}
+		if n == 0 {
+			continue
+		}
+
+		host, _, _ := net.SplitHostPort(addr.String())
+		if err := l.parse(bytes.NewReader(buf[:n]), host); err != nil && !errors.Is(err, io.EOF) {
+			l.metrics.parseErrors.WithLabelValues(l.cfg.Name).Inc()
+			level.Warn(l.logger).Log("msg", "error parsing syslog packet", "remote", addr, "err", err)
+		}
+	}
+}
+
+// parse parses the syslog messages of r and hands them to the batch loop.
+// remoteHost is used as hostname of the messages without one.
+func (l *syslogListener) parse(r io.Reader, remoteHost string) error {
+	callback := func(res *syslog.Result) {
+		if res.Message == nil || !res.Message.Valid() {
+			l.metrics.parseErrors.WithLabelValues(l.cfg.Name).Inc()
+			if res.Error != nil {
+				level.Debug(l.logger).Log("msg", "failed to parse syslog message", "err", res.Error)
+			}
+			return
+		}
+		if e, ok := l.toEntry(res.Message, remoteHost); ok {
+			l.metrics.messages.WithLabelValues(l.cfg.Name).Inc()
+			l.entries <- e
+		}
+	}
+
+	if l.cfg.Format == syslogFormatRFC3164 {
+		return syslogparser.ParseStreamRFC3164(r, callback, l.cfg.MaxMessageLength)
+	}
+	return syslogparser.ParseStream(r, callback, l.cfg.MaxMessageLength)
+}
+
+// toEntry maps the facility, severity, hostname and app name of the message to
+// labels, next to the static labels of the listener.
+func (l *syslogListener) toEntry(msg syslog.Message, remoteHost string) (syslogEntry, bool) {
+	var base *syslog.Base
+	switch m := msg.(type) {
+	case *rfc5424.SyslogMessage:
+		base = &m.Base
+	case *rfc3164.SyslogMessage:
+		base = &m.Base
+	default:
+		return syslogEntry{}, false
+	}
+	if base.Message == nil || *base.Message == "" {
+		return syslogEntry{}, false
+	}
+
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	for name, value := range l.cfg.Labels {
+		lb.Set(name, value)
+	}
+	if v := base.FacilityLevel(); v != nil {
+		lb.Set("facility", *v)
+	}
+	if v := base.SeverityLevel(); v != nil {
+		lb.Set("severity", *v)
+	}
+	if v := base.Hostname; v != nil && *v != "" && *v != "-" {
+		lb.Set("hostname", *v)
+	} else if remoteHost != "" {
+		lb.Set("hostname", remoteHost)
+	}
+	if v := base.Appname; v != nil && *v != "" && *v != "-" {
+		lb.Set("app_name", *v)
+	}
+
+	ts := time.Now()
+	if l.cfg.UseIncomingTimestamp && base.Timestamp != nil {
+		ts = *base.Timestamp
+	}
+
+	return syslogEntry{
+		labels: lb.Labels().String(),
+		entry:  logproto.Entry{Timestamp: ts, Line: *base.Message},
+	}, true
+}
+
+func (l *syslogListener) batchLoop() {
+	defer close(l.batchDone)
+
+	ticker := time.NewTicker(l.batchWait)
+	defer ticker.Stop()
+
+	batch := map[string]*logproto.Stream{}
+	size := 0
+	flush := func() {
+		if size == 0 {
+			return
+		}
+		l.flush(batch, size)
+		batch = map[string]*logproto.Stream{}
+		size = 0
+	}
+
+	for {
+		select {
+		case e, ok := <-l.entries:
+			if !ok {
+				flush()
+				return
+			}
+			s, ok := batch[e.labels]
+			if !ok {
+				s = &logproto.Stream{Labels: e.labels}
+				batch[e.labels] = s
+			}
+			s.Entries = append(s.Entries, e.entry)
+			size++
+			if size >= l.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush pushes the batch to the tenant of the listener. Syslog has no way to
+// report errors to senders, so rejected messages are dropped.
+func (l *syslogListener) flush(batch map[string]*logproto.Stream, size int) {
+	req := &logproto.PushRequest{Streams: make([]logproto.Stream, 0, len(batch))}
+	for _, s := range batch {
+		req.Streams = append(req.Streams, *s)
+	}
+
+	ctx := user.InjectOrgID(context.Background(), l.cfg.Tenant)
+	if _, err := l.push(ctx, req); err != nil {
+		l.metrics.pushFailures.WithLabelValues(l.cfg.Name).Add(float64(size))
+		level.Warn(l.logger).Log("msg", "failed to push syslog messages", "tenant", l.cfg.Tenant, "entries", size, "err", err)
+	}
+}
+
+// idleTimeoutConn closes connections without any traffic for the timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,119 @@
+package distributor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+type syslogPushRecorder struct {
+	mtx     sync.Mutex
+	tenants []string
+	streams []logproto.Stream
+}
+
+func (r *syslogPushRecorder) push(ctx context.Context, req *logproto.PushRequest) (*logproto.PushResponse, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.tenants = append(r.tenants, tenantID)
+	r.streams = append(r.streams, req.Streams...)
+	return &logproto.PushResponse{}, nil
+}
+
+func TestSyslogServer(t *testing.T) {
+	recorder := &syslogPushRecorder{}
+	cfg := SyslogConfig{
+		BatchWait: time.Hour,
+		BatchSize: 1000,
+		Listeners: []SyslogListenerConfig{
+			{Name: "network", ListenAddress: "127.0.0.1:0", Protocol: syslogProtocolTCP, Format: syslogFormatRFC5424, Tenant: "network"},
+			{Name: "legacy", ListenAddress: "127.0.0.1:0", Protocol: syslogProtocolUDP, Format: syslogFormatRFC3164, Tenant: "legacy", Labels: map[string]string{"job": "switches"}},
+		},
+	}
+
+	s, err := NewSyslogServer(cfg, recorder.push, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), s))
+
+	addrs := s.Addrs()
+	tcp, err := net.Dial("tcp", addrs[0].String())
+	require.NoError(t, err)
+	_, err = fmt.Fprint(tcp, "<165>1 2024-01-02T15:04:05Z router1 bgpd 42 ID47 - peer down\n")
+	require.NoError(t, err)
+	require.NoError(t, tcp.Close())
+
+	udp, err := net.Dial("udp", addrs[1].String())
+	require.NoError(t, err)
+	_, err = fmt.Fprint(udp, "<13>Jan  2 15:04:05 switch1 ifmgr: link up")
+	require.NoError(t, err)
+	require.NoError(t, udp.Close())
+
+	// Wait for both messages to be received before stopping, which pushes the
+	// buffered messages.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.listeners[0].metrics.messages.WithLabelValues("network")) == 1 &&
+			testutil.ToFloat64(s.listeners[0].metrics.messages.WithLabelValues("legacy")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), s))
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+
+	sort.Strings(recorder.tenants)
+	require.Equal(t, []string{"legacy", "network"}, recorder.tenants)
+	require.Len(t, recorder.streams, 2)
+
+	byLabels := map[string]logproto.Stream{}
+	for _, s := range recorder.streams {
+		byLabels[s.Labels] = s
+	}
+
+	rfc5424 := byLabels[`{app_name="bgpd", facility="local4", hostname="router1", job="syslog", severity="notice"}`]
+	require.Len(t, rfc5424.Entries, 1)
+	require.Equal(t, "peer down", rfc5424.Entries[0].Line)
+
+	rfc3164 := byLabels[`{app_name="ifmgr", facility="user", hostname="switch1", job="switches", severity="notice"}`]
+	require.Len(t, rfc3164.Entries, 1)
+	require.Equal(t, "link up", rfc3164.Entries[0].Line)
+}
+
+func TestSyslogListenerConfig_Validate(t *testing.T) {
+	cfg := SyslogListenerConfig{Name: "test", ListenAddress: ":1514", Tenant: "test"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, syslogProtocolTCP, cfg.Protocol)
+	require.Equal(t, syslogFormatRFC5424, cfg.Format)
+	require.Equal(t, map[string]string{"job": "syslog"}, cfg.Labels)
+
+	for _, invalid := range []SyslogListenerConfig{
+		{ListenAddress: ":1514", Tenant: "test"},
+		{Name: "test", Tenant: "test"},
+		{Name: "test", ListenAddress: ":1514"},
+		{Name: "test", ListenAddress: ":1514", Tenant: "test", Protocol: "sctp"},
+		{Name: "test", ListenAddress: ":1514", Tenant: "test", Format: "cef"},
+		{Name: "test", ListenAddress: ":1514", Tenant: "test", TLSCertPath: "cert.pem"},
+		{Name: "test", ListenAddress: ":1514", Tenant: "test", Protocol: syslogProtocolUDP, TLSCertPath: "cert.pem", TLSKeyPath: "key.pem"},
+	} {
+		require.Error(t, invalid.Validate(), "%+v", invalid)
+	}
+
+	listeners := SyslogConfig{Listeners: []SyslogListenerConfig{cfg, cfg}}
+	require.EqualError(t, listeners.Validate(), `duplicate syslog listener name "test"`)
+}
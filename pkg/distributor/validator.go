@@ -50,6 +50,10 @@ type validationContext struct {
 	maxStructuredMetadataSize  int
 	maxStructuredMetadataCount int
 
+	structuredMetadataExtractionFormat string
+	structuredMetadataExtractionFields []string
+	structuredMetadataExtractionStrip  bool
+
 	userID string
 }
 
@@ -70,6 +74,10 @@ func (v Validator) getValidationContextForTime(now time.Time, userID string) val
 		allowStructuredMetadata:      v.AllowStructuredMetadata(userID),
 		maxStructuredMetadataSize:    v.MaxStructuredMetadataSize(userID),
 		maxStructuredMetadataCount:   v.MaxStructuredMetadataCount(userID),
+
+		structuredMetadataExtractionFormat: v.StructuredMetadataExtractionFormat(userID),
+		structuredMetadataExtractionFields: v.StructuredMetadataExtractionFields(userID),
+		structuredMetadataExtractionStrip:  v.StructuredMetadataExtractionStrip(userID),
 	}
 }
 
@@ -2,6 +2,7 @@ package ingester
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -36,6 +37,8 @@ const (
 	flushReasonForced = "forced"
 	flushReasonFull   = "full"
 	flushReasonSynced = "synced"
+
+	flushReasonMemoryLimit = "memory_limit"
 )
 
 // Note: this is called both during the WAL replay (zero or more times)
@@ -56,10 +59,13 @@ func (i *Ingester) Flush() {
 }
 
 // TransferOut implements ring.FlushTransferer
-// Noop implemenetation because ingesters have a WAL now that does not require transferring chunks any more.
-// We return ErrTransferDisabled to indicate that we don't support transfers, and therefore we may flush on shutdown if configured to do so.
-func (i *Ingester) TransferOut(_ context.Context) error {
-	return ring.ErrTransferDisabled
+// Unless hand-off is enabled and the ingester leaves the ring, this is a noop because ingesters have a WAL that does not require transferring chunks.
+// We return ErrTransferDisabled in that case, and therefore we may flush on shutdown if configured to do so.
+func (i *Ingester) TransferOut(ctx context.Context) error {
+	if !i.cfg.HandOff.Enabled || !i.lifecycler.ShouldUnregisterOnShutdown() {
+		return ring.ErrTransferDisabled
+	}
+	return i.transferOut(ctx)
 }
 
 func (i *Ingester) flush(mayRemoveStreams bool) {
@@ -96,8 +102,32 @@ func (o *flushOp) Priority() int64 {
 	return -int64(o.from)
 }
 
+// flushWatermark returns the creation time of the oldest chunk that hasn't
+// been flushed yet, or the current time if every chunk has been flushed. All
+// the data pushed before the returned time is stored.
+func (i *Ingester) flushWatermark() time.Time {
+	watermark := time.Now()
+	for _, instance := range i.getInstances() {
+		_ = instance.streams.ForEach(func(s *stream) (bool, error) {
+			s.chunkMtx.RLock()
+			defer s.chunkMtx.RUnlock()
+			for _, c := range s.chunks {
+				if c.flushed.IsZero() && c.created.Before(watermark) {
+					watermark = c.created
+				}
+			}
+			return true, nil
+		})
+	}
+	return watermark
+}
+
 // sweepUsers periodically schedules series for flushing and garbage collects users with no series
 func (i *Ingester) sweepUsers(immediate, mayRemoveStreams bool) {
+	if i.flushDeduper != nil {
+		i.flushDeduper.expire(time.Now())
+	}
+
 	instances := i.getInstances()
 
 	for _, instance := range instances {
@@ -167,7 +197,7 @@ func (i *Ingester) flushUserSeries(userID string, fp model.Fingerprint, immediat
 		return nil
 	}
 
-	chunks, labels, chunkMtx := i.collectChunksToFlush(instance, fp, immediate)
+	chunks, labels, stream := i.collectChunksToFlush(instance, fp, immediate)
 	if len(chunks) < 1 {
 		return nil
 	}
@@ -178,7 +208,7 @@ func (i *Ingester) flushUserSeries(userID string, fp model.Fingerprint, immediat
 	ctx := user.InjectOrgID(context.Background(), userID)
 	ctx, cancel := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
 	defer cancel()
-	err := i.flushChunks(ctx, fp, labels, chunks, chunkMtx)
+	err := i.flushChunks(ctx, fp, labels, chunks, &stream.chunkMtx, stream)
 	if err != nil {
 		return fmt.Errorf("failed to flush chunks: %w, num_chunks: %d, labels: %s", err, len(chunks), lbs)
 	}
@@ -186,7 +216,7 @@ func (i *Ingester) flushUserSeries(userID string, fp model.Fingerprint, immediat
 	return nil
 }
 
-func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint, immediate bool) ([]*chunkDesc, labels.Labels, *sync.RWMutex) {
+func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint, immediate bool) ([]*chunkDesc, labels.Labels, *stream) {
 	var stream *stream
 	var ok bool
 	stream, ok = instance.streams.LoadByFP(fp)
@@ -217,12 +247,15 @@ func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint
 			}
 		}
 	}
-	return result, stream.labels, &stream.chunkMtx
+	return result, stream.labels, stream
 }
 
 func (i *Ingester) shouldFlushChunk(chunk *chunkDesc) (bool, string) {
 	// Append should close the chunk when the a new one is added.
 	if chunk.closed {
+		if chunk.reason == flushReasonMemoryLimit {
+			return true, flushReasonMemoryLimit
+		}
 		if chunk.synced {
 			return true, flushReasonSynced
 		}
@@ -279,8 +312,10 @@ func (i *Ingester) removeFlushedChunks(instance *instance, stream *stream, mayRe
 //
 // If a chunk fails to be flushed, this operation is reinserted in the queue. Since previously flushed chunks
 // are marked as flushed, they shouldn't be flushed again.
-// It has to close given chunks to have have the head block included.
-func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelPairs labels.Labels, cs []*chunkDesc, chunkMtx sync.Locker) error {
+// It has to close given chunks to have have the head block included. The
+// stream of the chunks, if not nil, lets them be cut where the chunks of the
+// owner replica of the stream end when deduplicating.
+func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelPairs labels.Labels, cs []*chunkDesc, chunkMtx sync.Locker, s *stream) error {
 	userID, err := tenant.TenantID(ctx)
 	if err != nil {
 		return err
@@ -300,10 +335,31 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 			return fmt.Errorf("chunk close for flushing: %w", err)
 		}
 
-		firstTime, lastTime := util.RoundToMilliseconds(c.chunk.Bounds())
+		// Only the part of the chunk that the owner replica of the stream
+		// didn't upload yet is flushed when deduplicating.
+		var data chunkenc.Chunk = c.chunk
+		if i.flushDeduper != nil {
+			var carry func(at model.Time) (bool, error)
+			if s != nil {
+				carry = func(at model.Time) (bool, error) { return s.carryOver(c, at) }
+			}
+			data, err = i.flushDeduper.dedup(ctx, userID, labelPairs.String(), c, chunkMtx, carry)
+			if errors.Is(err, errFlushDeferred) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("chunk flush deduplication: %w", err)
+			}
+			if data == nil {
+				i.markChunkAsFlushed(cs[j], chunkMtx)
+				continue
+			}
+		}
+
+		firstTime, lastTime := util.RoundToMilliseconds(data.Bounds())
 		ch := chunk.NewChunk(
 			userID, fp, metric,
-			chunkenc.NewFacade(c.chunk, i.cfg.BlockSize, i.cfg.TargetChunkSize),
+			chunkenc.NewFacade(data, i.cfg.BlockSize, i.cfg.TargetChunkSize),
 			firstTime,
 			lastTime,
 		)
@@ -317,6 +373,12 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 			return err
 		}
 
+		if i.flushDeduper != nil {
+			if err := i.flushDeduper.record(userID, labelPairs.String(), c, data, time.Now()); err != nil {
+				level.Warn(i.logger).Log("msg", "failed to record flushed chunk for deduplication", "user", userID, "err", err)
+			}
+		}
+
 		reason := func() string {
 			chunkMtx.Lock()
 			defer chunkMtx.Unlock()
@@ -345,7 +407,21 @@ func (i *Ingester) closeChunk(desc *chunkDesc, chunkMtx sync.Locker) error {
 	chunkMtx.Lock()
 	defer chunkMtx.Unlock()
 
-	return desc.chunk.Close()
+	if err := desc.chunk.Close(); err != nil {
+		return err
+	}
+
+	// The chunk doesn't receive entries anymore once it is flushed, so the
+	// checksum served to the other replicas of the stream is computed once.
+	if i.flushDeduper != nil && !desc.checksummed {
+		from, through := util.RoundToMilliseconds(desc.chunk.Bounds())
+		sum, err := chunkChecksum(desc.chunk, from, through)
+		if err != nil {
+			return err
+		}
+		desc.checksum, desc.checksummed = sum, true
+	}
+	return nil
 }
 
 // encodeChunk encodes a chunk.Chunk based on the given chunkDesc.
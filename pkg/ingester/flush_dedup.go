@@ -0,0 +1,489 @@
+package ingester
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/distributor/clientpool"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	lokilog "github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/util"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+)
+
+const (
+	flushDedupResultOwner        = "owner"
+	flushDedupResultDeduplicated = "deduplicated"
+	flushDedupResultPartial      = "partially_deduplicated"
+	flushDedupResultUploaded     = "uploaded"
+	flushDedupResultFallback     = "fallback"
+	flushDedupResultCarried      = "carried_over"
+)
+
+// errFlushDeferred is returned when a chunk isn't flushed yet because the
+// owner replica of its stream is still flushing the same data.
+var errFlushDeferred = errors.New("flush deferred until the owner replica of the stream flushed")
+
+// flushDedupOp selects the replicas a stream owner is picked from. Unhealthy
+// replicas are left out, so that the next healthy replica takes over.
+var flushDedupOp = ring.NewOp([]ring.InstanceState{ring.ACTIVE, ring.LEAVING}, nil)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FlushDedupConfig configures the deduplication of the chunks flushed by the
+// replicas of a stream.
+type FlushDedupConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	WaitTimeout time.Duration `yaml:"wait_timeout"`
+}
+
+func (cfg *FlushDedupConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ingester.flush-dedup.enabled", false, "Experimental: Only let the owner replica of a stream upload its chunks. The other replicas ask the owner which chunks it flushed, cut their own chunks where the owner's end, verify them by checksum and drop their copies instead of uploading them again.")
+	f.DurationVar(&cfg.WaitTimeout, "ingester.flush-dedup.wait-timeout", 5*time.Minute, "How long a replica waits for the owner of a stream to flush chunks overlapping its own before uploading them anyway.")
+}
+
+type flushedChunk struct {
+	from, through model.Time
+	checksum      uint32
+	flushedAt     time.Time
+}
+
+// flushDeduper makes the replicas of a stream agree on the chunks to upload.
+// The owner of a stream, the first healthy replica of its token in the ring,
+// uploads its chunks as usual and remembers their boundaries and checksums.
+// The other replicas ask the owner for the chunks it flushed and drop the
+// parts of their own chunks matching a chunk of the owner by checksum, only
+// uploading the rest.
+//
+// The owner dictates where the chunks of the other replicas are cut. When a
+// chunk of the owner starts within the chunk flushed by a replica but ends
+// after it, the replica moves the entries from the start of the owner's chunk
+// to its next chunk of the stream. The chunks of the replica then end where
+// the owner's do and the next ones start where the owner's do, so they are
+// matched as a whole.
+type flushDeduper struct {
+	cfg     FlushDedupConfig
+	addr    string
+	ring    ring.ReadRing
+	clients func(addr string) (logproto.StreamDataClient, error)
+	metrics *ingesterMetrics
+	logger  log.Logger
+
+	subservices *services.Manager
+
+	mtx sync.Mutex
+	// flushed holds the chunks recently flushed by tenant and stream labels.
+	flushed map[string]map[string][]flushedChunk
+}
+
+// setupFlushDeduper creates the ring client used to find out the owners of
+// the streams, along with the pool of clients used to reach them.
+func (i *Ingester) setupFlushDeduper(registerer prometheus.Registerer, metricsNamespace string) error {
+	// The ring client is registered with its own prefix, not to collide with
+	// the metrics of the ingester ring client used by other components.
+	dedupRing, err := ring.New(i.cfg.LifecyclerConfig.RingConfig, "ingester", RingKey, i.logger, prometheus.WrapRegistererWithPrefix(metricsNamespace+"_flush_dedup_", registerer))
+	if err != nil {
+		return errors.Wrap(err, "failed to create flush dedup ring client")
+	}
+
+	factory := ring_client.PoolAddrFunc(func(addr string) (ring_client.PoolClient, error) {
+		return i.cfg.ingesterClientFactory(i.clientConfig, addr)
+	})
+	pool := clientpool.NewPool("ingester", i.clientConfig.PoolConfig, dedupRing, factory, i.logger, metricsNamespace)
+
+	d := newFlushDeduper(i.cfg.FlushDedup, i.lifecycler.Addr, dedupRing, func(addr string) (logproto.StreamDataClient, error) {
+		c, err := pool.GetClientFor(addr)
+		if err != nil {
+			return nil, err
+		}
+		return c.(logproto.StreamDataClient), nil
+	}, i.metrics, i.logger)
+	d.subservices, err = services.NewManager(dedupRing, pool)
+	if err != nil {
+		return err
+	}
+
+	i.flushDeduper = d
+	return nil
+}
+
+func newFlushDeduper(cfg FlushDedupConfig, addr string, r ring.ReadRing, clients func(addr string) (logproto.StreamDataClient, error), metrics *ingesterMetrics, logger log.Logger) *flushDeduper {
+	return &flushDeduper{
+		cfg:     cfg,
+		addr:    addr,
+		ring:    r,
+		clients: clients,
+		metrics: metrics,
+		logger:  logger,
+		flushed: map[string]map[string][]flushedChunk{},
+	}
+}
+
+// owner returns the address of the replica uploading the chunks of the stream.
+func (d *flushDeduper) owner(tenantID, lbs string) (string, error) {
+	descs, hosts, zones := ring.MakeBuffersForGet()
+	rs, err := d.ring.Get(lokiring.TokenFor(tenantID, lbs), flushDedupOp, descs, hosts, zones)
+	if err != nil {
+		return "", err
+	}
+	if len(rs.Instances) == 0 {
+		return "", ring.ErrEmptyRing
+	}
+	return rs.Instances[0].Addr, nil
+}
+
+// dedup returns the part of the chunk that needs to be uploaded, or nil if the
+// owner of the stream already uploaded all of it. It returns errFlushDeferred
+// when the owner still has to flush chunks overlapping this one. The entries
+// of an owner chunk lying across the end of this one are moved to the next
+// chunk of the stream with carry, if not nil.
+func (d *flushDeduper) dedup(ctx context.Context, tenantID, lbs string, desc *chunkDesc, chunkMtx sync.Locker, carry func(at model.Time) (bool, error)) (chunkenc.Chunk, error) {
+	logger := util_log.WithUserID(tenantID, d.logger)
+
+	owner, err := d.owner(tenantID, lbs)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to find the owner of the stream, uploading chunk", "labels", lbs, "err", err)
+		d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultFallback).Inc()
+		return desc.chunk, nil
+	}
+	if owner == d.addr {
+		d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultOwner).Inc()
+		return desc.chunk, nil
+	}
+
+	from, through := util.RoundToMilliseconds(desc.chunk.Bounds())
+	resp, err := d.streamChunks(ctx, owner, lbs, from, through)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to get the chunks of the stream owner, uploading chunk", "owner", owner, "labels", lbs, "err", err)
+		d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultFallback).Inc()
+		return desc.chunk, nil
+	}
+
+	var (
+		pending bool
+		across  = model.Latest
+	)
+	for _, c := range resp.Chunks {
+		if !c.Flushed {
+			pending = true
+			continue
+		}
+		if c.From >= from && c.From <= through && c.Through > through && c.From < across {
+			across = c.From
+		}
+	}
+
+	if pending && d.mayDefer(desc, chunkMtx) {
+		d.metrics.flushDedupDeferred.Inc()
+		return nil, errFlushDeferred
+	}
+
+	// Cut the chunk where the owner's chunk lying across its end starts, so
+	// that the next chunk of this replica starts with the same entries.
+	if across != model.Latest && desc.reason != flushReasonForced && carry != nil {
+		carried, err := carry(across)
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to cut chunk where the chunk of the stream owner starts", "owner", owner, "labels", lbs, "err", err)
+		}
+		if carried && desc.chunk.Size() == 0 {
+			d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultCarried).Inc()
+			return nil, nil
+		}
+		if carried {
+			from, through = util.RoundToMilliseconds(desc.chunk.Bounds())
+		}
+	}
+
+	var covered []logproto.StreamChunk
+	for _, c := range resp.Chunks {
+		// Only the chunks of the owner fully within this chunk can be matched.
+		if !c.Flushed || c.From < from || c.Through > through {
+			continue
+		}
+		sum, err := chunkChecksum(desc.chunk, c.From, c.Through)
+		if err != nil {
+			return nil, err
+		}
+		if sum == c.Checksum {
+			covered = append(covered, c)
+		}
+	}
+
+	if len(covered) == 0 {
+		d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultUploaded).Inc()
+		return desc.chunk, nil
+	}
+
+	rest, err := desc.chunk.Rebound(from.Time(), through.Time(), func(ts time.Time, _ string, _ ...labels.Label) bool {
+		for _, c := range covered {
+			if inChunkRange(ts, c.From, c.Through) {
+				return true
+			}
+		}
+		return false
+	})
+	if errors.Is(err, chunk.ErrSliceNoDataInRange) {
+		d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultDeduplicated).Inc()
+		d.metrics.flushDedupBytesSaved.Add(float64(desc.chunk.CompressedSize()))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultPartial).Inc()
+	if saved := desc.chunk.CompressedSize() - rest.CompressedSize(); saved > 0 {
+		d.metrics.flushDedupBytesSaved.Add(float64(saved))
+	}
+	return rest, nil
+}
+
+func (d *flushDeduper) streamChunks(ctx context.Context, addr, lbs string, from, through model.Time) (*logproto.StreamChunksResponse, error) {
+	c, err := d.clients(addr)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetStreamChunks(ctx, &logproto.StreamChunksRequest{
+		Labels:  lbs,
+		From:    from,
+		Through: through,
+	})
+}
+
+// mayDefer tells whether the flush of the chunk can be deferred to wait for
+// the owner of the stream. Forced flushes are never deferred.
+func (d *flushDeduper) mayDefer(desc *chunkDesc, chunkMtx sync.Locker) bool {
+	chunkMtx.Lock()
+	defer chunkMtx.Unlock()
+
+	if desc.reason == flushReasonForced {
+		return false
+	}
+	now := time.Now()
+	if desc.dedupDeferredSince.IsZero() {
+		desc.dedupDeferredSince = now
+	}
+	return now.Sub(desc.dedupDeferredSince) < d.cfg.WaitTimeout
+}
+
+// record remembers the data flushed for a chunk so that the other replicas of
+// the stream can verify their copies against it. The checksum of the chunk is
+// reused when all of it was flushed.
+func (d *flushDeduper) record(tenantID, lbs string, desc *chunkDesc, data chunkenc.Chunk, now time.Time) error {
+	from, through := util.RoundToMilliseconds(data.Bounds())
+	sum := desc.checksum
+	if !desc.checksummed || data != chunkenc.Chunk(desc.chunk) {
+		var err error
+		if sum, err = chunkChecksum(data, from, through); err != nil {
+			return err
+		}
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	streams, ok := d.flushed[tenantID]
+	if !ok {
+		streams = map[string][]flushedChunk{}
+		d.flushed[tenantID] = streams
+	}
+	streams[lbs] = append(streams[lbs], flushedChunk{from: from, through: through, checksum: sum, flushedAt: now})
+	return nil
+}
+
+// flushedChunks returns the recently flushed chunks of the stream overlapping
+// the given time range.
+func (d *flushDeduper) flushedChunks(tenantID, lbs string, from, through model.Time) []logproto.StreamChunk {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	var result []logproto.StreamChunk
+	for _, c := range d.flushed[tenantID][lbs] {
+		if c.through < from || c.from > through {
+			continue
+		}
+		result = append(result, logproto.StreamChunk{
+			From:     c.from,
+			Through:  c.through,
+			Checksum: c.checksum,
+			Flushed:  true,
+		})
+	}
+	return result
+}
+
+// expire forgets the chunks flushed long enough ago for the other replicas to
+// have given up waiting on them.
+func (d *flushDeduper) expire(now time.Time) {
+	before := now.Add(-2 * d.cfg.WaitTimeout)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for tenantID, streams := range d.flushed {
+		for lbs, chunks := range streams {
+			kept := chunks[:0]
+			for _, c := range chunks {
+				if c.flushedAt.After(before) {
+					kept = append(kept, c)
+				}
+			}
+			if len(kept) == 0 {
+				delete(streams, lbs)
+				continue
+			}
+			streams[lbs] = kept
+		}
+		if len(streams) == 0 {
+			delete(d.flushed, tenantID)
+		}
+	}
+}
+
+// chunkChecksum returns the checksum of the entries of the chunk between from
+// and through. It doesn't depend on how the chunk is encoded, only on its
+// entries, so that it matches across replicas holding the same entries.
+func chunkChecksum(c chunkenc.Chunk, from, through model.Time) (uint32, error) {
+	it, err := c.Iterator(context.Background(), from.Time(), through.Time().Add(time.Millisecond), logproto.FORWARD, lokilog.NewNoopPipeline().ForStream(labels.EmptyLabels()))
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	h := crc32.New(castagnoliTable)
+	var buf []byte
+	for it.Next() {
+		e := it.Entry()
+		buf = binary.BigEndian.AppendUint64(buf[:0], uint64(e.Timestamp.UnixNano()))
+		buf = appendString(buf, e.Line)
+		for _, l := range e.StructuredMetadata {
+			buf = appendString(buf, l.Name)
+			buf = appendString(buf, l.Value)
+		}
+		_, _ = h.Write(buf)
+	}
+	return h.Sum32(), it.Error()
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// inChunkRange tells whether the timestamp falls within the bounds of a chunk
+// rounded to milliseconds.
+func inChunkRange(ts time.Time, from, through model.Time) bool {
+	return !ts.Before(from.Time()) && ts.Before(through.Time().Add(time.Millisecond))
+}
+
+// carryOver moves the entries of the chunk from the given time onwards to the
+// beginning of the next chunk of the stream, creating it if the chunk is the
+// last one. It returns false if they can't be moved, because the next chunk
+// has already been flushed, or because all the entries of the last chunk
+// would be moved and the chunk would be flushed again and again.
+func (s *stream) carryOver(desc *chunkDesc, at model.Time) (bool, error) {
+	s.chunkMtx.Lock()
+	defer s.chunkMtx.Unlock()
+
+	j := 0
+	for ; j < len(s.chunks) && &s.chunks[j] != desc; j++ {
+	}
+	if j == len(s.chunks) || !desc.flushed.IsZero() {
+		return false, nil
+	}
+	last := j == len(s.chunks)-1
+	if !last && !s.chunks[j+1].flushed.IsZero() {
+		return false, nil
+	}
+
+	from, through := desc.chunk.Bounds()
+	tail, err := desc.chunk.Rebound(from, through, func(ts time.Time, _ string, _ ...labels.Label) bool {
+		return ts.Before(at.Time())
+	})
+	if errors.Is(err, chunk.ErrSliceNoDataInRange) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	head, err := desc.chunk.Rebound(from, through, func(ts time.Time, _ string, _ ...labels.Label) bool {
+		return !ts.Before(at.Time())
+	})
+	if errors.Is(err, chunk.ErrSliceNoDataInRange) {
+		if last {
+			return false, nil
+		}
+		head = s.NewChunk()
+		err = head.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	next := s.NewChunk()
+	if err := appendChunkEntries(next, tail); err != nil {
+		return false, err
+	}
+	if !last {
+		if err := appendChunkEntries(next, s.chunks[j+1].chunk); err != nil {
+			return false, err
+		}
+		if s.chunks[j+1].closed {
+			if err := next.Close(); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	desc.chunk = head.(*chunkenc.MemChunk)
+	desc.checksummed = false
+	if last {
+		s.chunks = append(s.chunks, chunkDesc{chunk: next, created: desc.created, lastUpdated: time.Now()})
+		s.metrics.chunksCreatedTotal.Inc()
+		s.metrics.memoryChunks.Inc()
+		return true, nil
+	}
+
+	n := &s.chunks[j+1]
+	n.chunk = next
+	n.checksummed = false
+	if desc.created.Before(n.created) {
+		n.created = desc.created
+	}
+	return true, nil
+}
+
+// appendChunkEntries appends all the entries of src to dst.
+func appendChunkEntries(dst *chunkenc.MemChunk, src chunkenc.Chunk) error {
+	from, through := src.Bounds()
+	it, err := src.Iterator(context.Background(), from, through.Add(time.Nanosecond), logproto.FORWARD, lokilog.NewNoopPipeline().ForStream(labels.EmptyLabels()))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		e := it.Entry()
+		if err := dst.Append(&e); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
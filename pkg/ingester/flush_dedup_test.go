@@ -0,0 +1,235 @@
+package ingester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type fakeDedupRing struct {
+	ring.ReadRing
+	addrs []string
+	err   error
+}
+
+func (r *fakeDedupRing) Get(_ uint32, _ ring.Operation, _ []ring.InstanceDesc, _, _ []string) (ring.ReplicationSet, error) {
+	if r.err != nil {
+		return ring.ReplicationSet{}, r.err
+	}
+	rs := ring.ReplicationSet{}
+	for _, addr := range r.addrs {
+		rs.Instances = append(rs.Instances, ring.InstanceDesc{Addr: addr})
+	}
+	return rs, nil
+}
+
+type fakeStreamChunksClient struct {
+	logproto.StreamDataClient
+	chunks []logproto.StreamChunk
+	err    error
+}
+
+func (c *fakeStreamChunksClient) GetStreamChunks(_ context.Context, _ *logproto.StreamChunksRequest, _ ...grpc.CallOption) (*logproto.StreamChunksResponse, error) {
+	return &logproto.StreamChunksResponse{Chunks: c.chunks}, c.err
+}
+
+func dedupTestChunk(t *testing.T, from, through int, extra ...logproto.Entry) *chunkDesc {
+	c := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, chunkenc.EncSnappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, dummyConf().BlockSize, dummyConf().TargetChunkSize)
+	for i := from; i < through; i++ {
+		require.NoError(t, c.Append(&logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: fmt.Sprintf("line %d", i)}))
+	}
+	for _, e := range extra {
+		require.NoError(t, c.Append(&e))
+	}
+	require.NoError(t, c.Close())
+	return &chunkDesc{chunk: c, closed: true, reason: flushReasonFull}
+}
+
+func TestFlushDeduper(t *testing.T) {
+	const lbs = `{app="foo"}`
+	cfg := FlushDedupConfig{Enabled: true, WaitTimeout: time.Minute}
+	ctx := user.InjectOrgID(context.Background(), "fake")
+
+	// The owner flushed the first half of the entries as one chunk and the
+	// second half as another one.
+	owner := newFlushDeduper(cfg, "owner", nil, nil, newIngesterMetrics(nil, constants.Loki), gokitlog.NewNopLogger())
+	now := time.Now()
+	for _, desc := range []*chunkDesc{dedupTestChunk(t, 0, 50), dedupTestChunk(t, 50, 100)} {
+		require.NoError(t, owner.record("fake", lbs, desc, desc.chunk, now))
+	}
+	flushed := owner.flushedChunks("fake", lbs, 0, 1000*1000)
+	require.Len(t, flushed, 2)
+
+	newReplica := func(client *fakeStreamChunksClient, addrs ...string) *flushDeduper {
+		return newFlushDeduper(cfg, "replica", &fakeDedupRing{addrs: addrs}, func(string) (logproto.StreamDataClient, error) {
+			return client, nil
+		}, newIngesterMetrics(nil, constants.Loki), gokitlog.NewNopLogger())
+	}
+
+	t.Run("owner uploads", func(t *testing.T) {
+		d := newReplica(&fakeStreamChunksClient{chunks: flushed}, "replica", "owner")
+		desc := dedupTestChunk(t, 0, 100)
+		data, err := d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, desc.chunk, data)
+		require.Equal(t, 1.0, testutil.ToFloat64(d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultOwner)))
+	})
+
+	t.Run("identical chunks are dropped", func(t *testing.T) {
+		d := newReplica(&fakeStreamChunksClient{chunks: flushed}, "owner", "replica")
+		data, err := d.dedup(ctx, "fake", lbs, dedupTestChunk(t, 0, 100), &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Nil(t, data)
+		require.Equal(t, 1.0, testutil.ToFloat64(d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultDeduplicated)))
+		require.Greater(t, testutil.ToFloat64(d.metrics.flushDedupBytesSaved), 0.0)
+	})
+
+	t.Run("entries not flushed by the owner are uploaded", func(t *testing.T) {
+		d := newReplica(&fakeStreamChunksClient{chunks: flushed[:1]}, "owner", "replica")
+		data, err := d.dedup(ctx, "fake", lbs, dedupTestChunk(t, 0, 100), &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 50, data.Size())
+		from, through := data.Bounds()
+		require.Equal(t, time.Unix(50, 0), from)
+		require.Equal(t, time.Unix(99, 0), through)
+		require.Equal(t, 1.0, testutil.ToFloat64(d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultPartial)))
+	})
+
+	t.Run("mismatching chunks are uploaded", func(t *testing.T) {
+		d := newReplica(&fakeStreamChunksClient{chunks: flushed}, "owner", "replica")
+		desc := dedupTestChunk(t, 0, 100, logproto.Entry{Timestamp: time.Unix(10, 1), Line: "missed by the owner"})
+		data, err := d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 51, data.Size())
+	})
+
+	t.Run("waits for the owner to flush", func(t *testing.T) {
+		pending := append([]logproto.StreamChunk{{From: 0, Through: 1000 * 1000}}, flushed...)
+		d := newReplica(&fakeStreamChunksClient{chunks: pending}, "owner", "replica")
+		desc := dedupTestChunk(t, 0, 100)
+		_, err := d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.ErrorIs(t, err, errFlushDeferred)
+		require.False(t, desc.dedupDeferredSince.IsZero())
+
+		// Give up waiting once the timeout elapsed.
+		desc.dedupDeferredSince = time.Now().Add(-cfg.WaitTimeout)
+		data, err := d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Nil(t, data)
+
+		// Forced flushes never wait.
+		desc = dedupTestChunk(t, 0, 100)
+		desc.reason = flushReasonForced
+		data, err = d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Nil(t, data)
+		require.Equal(t, 1.0, testutil.ToFloat64(d.metrics.flushDedupDeferred))
+	})
+
+	t.Run("falls back to uploading when the owner can't be reached", func(t *testing.T) {
+		d := newReplica(&fakeStreamChunksClient{err: errors.New("unavailable")}, "owner", "replica")
+		desc := dedupTestChunk(t, 0, 100)
+		data, err := d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, desc.chunk, data)
+
+		d.ring = &fakeDedupRing{err: ring.ErrTooManyUnhealthyInstances}
+		data, err = d.dedup(ctx, "fake", lbs, desc, &sync.Mutex{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, desc.chunk, data)
+		require.Equal(t, 2.0, testutil.ToFloat64(d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultFallback)))
+	})
+
+	t.Run("chunks are cut where the owner's end", func(t *testing.T) {
+		limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+		require.NoError(t, err)
+		chunkfmt, headfmt := defaultChunkFormat(t)
+		s := newStream(chunkfmt, headfmt, defaultConfig(), NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1), "fake", 0, labels.FromStrings("app", "foo"), true, NewStreamRateCalculator(), NilMetrics, nil)
+
+		// The replica cut its first chunk in the middle of the owner's second.
+		head := s.NewChunk()
+		for i := 70; i < 100; i++ {
+			require.NoError(t, head.Append(&logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: fmt.Sprintf("line %d", i)}))
+		}
+		s.chunks = []chunkDesc{*dedupTestChunk(t, 0, 70), {chunk: head}}
+
+		d := newReplica(&fakeStreamChunksClient{chunks: flushed}, "owner", "replica")
+		carry := func(desc *chunkDesc) func(model.Time) (bool, error) {
+			return func(at model.Time) (bool, error) { return s.carryOver(desc, at) }
+		}
+		data, err := d.dedup(ctx, "fake", lbs, &s.chunks[0], &sync.Mutex{}, carry(&s.chunks[0]))
+		require.NoError(t, err)
+		require.Nil(t, data)
+		require.Equal(t, 50, s.chunks[0].chunk.Size())
+		require.Equal(t, 50, s.chunks[1].chunk.Size())
+		from, _ := s.chunks[1].chunk.Bounds()
+		require.Equal(t, time.Unix(50, 0), from)
+
+		// The next chunk now starts with the owner's, so it is dropped as well.
+		s.chunks[1].closed = true
+		data, err = d.dedup(ctx, "fake", lbs, &s.chunks[1], &sync.Mutex{}, carry(&s.chunks[1]))
+		require.NoError(t, err)
+		require.Nil(t, data)
+		require.Equal(t, 2.0, testutil.ToFloat64(d.metrics.flushDedupChunks.WithLabelValues(flushDedupResultDeduplicated)))
+
+		// Forced flushes upload the entries instead of moving them.
+		s.chunks = []chunkDesc{*dedupTestChunk(t, 0, 70)}
+		s.chunks[0].reason = flushReasonForced
+		data, err = d.dedup(ctx, "fake", lbs, &s.chunks[0], &sync.Mutex{}, carry(&s.chunks[0]))
+		require.NoError(t, err)
+		require.Equal(t, 20, data.Size())
+		require.Len(t, s.chunks, 1)
+	})
+
+	owner.expire(now.Add(2 * cfg.WaitTimeout))
+	require.Empty(t, owner.flushedChunks("fake", lbs, 0, 1000*1000))
+}
+
+func TestGetStreamChunks(t *testing.T) {
+	_, ing := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	ing.flushDeduper = newFlushDeduper(FlushDedupConfig{Enabled: true, WaitTimeout: time.Minute}, "localhost", &fakeDedupRing{addrs: []string{"localhost"}}, nil, ing.metrics, ing.logger)
+	ctx := user.InjectOrgID(context.Background(), "fake")
+
+	_, err := ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{{
+		Labels:  `{app="foo"}`,
+		Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "line"}},
+	}}})
+	require.NoError(t, err)
+
+	resp, err := ing.GetStreamChunks(ctx, &logproto.StreamChunksRequest{Labels: `{app="foo"}`, From: 0, Through: 10 * 1000})
+	require.NoError(t, err)
+	require.Equal(t, []logproto.StreamChunk{{From: 1000, Through: 1000}}, resp.Chunks)
+
+	ing.sweepUsers(true, false)
+	require.Eventually(t, func() bool {
+		resp, err := ing.GetStreamChunks(ctx, &logproto.StreamChunksRequest{Labels: `{app="foo"}`, From: 0, Through: 10 * 1000})
+		require.NoError(t, err)
+		for _, c := range resp.Chunks {
+			if !c.Flushed || c.Checksum == 0 {
+				return false
+			}
+		}
+		return len(resp.Chunks) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	resp, err = ing.GetStreamChunks(ctx, &logproto.StreamChunksRequest{Labels: `{app="foo"}`, From: 2000, Through: 10 * 1000})
+	require.NoError(t, err)
+	require.Empty(t, resp.Chunks)
+}
@@ -67,6 +67,25 @@ func TestChunkFlushingShutdown(t *testing.T) {
 	store.checkData(t, testData)
 }
 
+func TestFlushWatermark(t *testing.T) {
+	_, ing := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	start := time.Now()
+	require.False(t, ing.flushWatermark().Before(start), "no unflushed chunks")
+
+	pushTestSamples(t, ing)
+	pushed := time.Now()
+	watermark := ing.flushWatermark()
+	require.False(t, watermark.Before(start))
+	require.False(t, watermark.After(pushed), "the watermark is the creation time of the oldest unflushed chunk")
+
+	ing.sweepUsers(true, false)
+	require.Eventually(t, func() bool {
+		return ing.flushWatermark().After(pushed)
+	}, time.Second, 10*time.Millisecond, "all chunks are flushed")
+}
+
 type fullWAL struct{}
 
 func (fullWAL) Log(_ *wal.Record) error { return &os.PathError{Err: syscall.ENOSPC} }
@@ -95,7 +114,7 @@ func Benchmark_FlushLoop(b *testing.B) {
 			wg.Add(1)
 			go func(loop int) {
 				defer wg.Done()
-				require.NoError(b, ing.flushChunks(ctx, 0, lbs, descs[loop], &sync.RWMutex{}))
+				require.NoError(b, ing.flushChunks(ctx, 0, lbs, descs[loop], &sync.RWMutex{}, nil))
 			}(i)
 		}
 		wg.Wait()
@@ -118,7 +137,7 @@ func Test_Flush(t *testing.T) {
 		}
 		return nil
 	}
-	require.NoError(t, ing.flushChunks(ctx, 0, lbs, buildChunkDecs(t), &sync.RWMutex{}))
+	require.NoError(t, ing.flushChunks(ctx, 0, lbs, buildChunkDecs(t), &sync.RWMutex{}, nil))
 }
 
 func buildChunkDecs(t testing.TB) []*chunkDesc {
@@ -0,0 +1,256 @@
+package ingester
+
+import (
+	"context"
+	"flag"
+	"io"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	tsdb_record "github.com/prometheus/prometheus/tsdb/record"
+
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+)
+
+const (
+	// handOffOrgID authenticates the hand-off requests, which carry the streams
+	// of every tenant.
+	handOffOrgID = "hand-off"
+
+	handOffSent     = "sent"
+	handOffReceived = "received"
+)
+
+// handOffOp selects the ingesters owning a stream once the leaving ingester
+// is out of the ring: it is left out and replaced by the next ingester.
+var handOffOp = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, func(s ring.InstanceState) bool {
+	return s != ring.ACTIVE
+})
+
+// HandOffConfig configures the transfer of the in-memory streams of a leaving
+// ingester to the ingesters taking over its token ranges.
+type HandOffConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (cfg *HandOffConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ingester.hand-off.enabled", false, "Experimental: When the ingester leaves the ring on shutdown, transfer its in-memory chunks to the ingesters taking over its token ranges instead of flushing them. The streams that couldn't be transferred are flushed if flushing on shutdown is enabled.")
+	f.DurationVar(&cfg.Timeout, "ingester.hand-off.timeout", 5*time.Minute, "Timeout of the transfer of the in-memory chunks on shutdown.")
+}
+
+type transferClientFactory func(cfg client.Config, addr string) (TransferClient, io.Closer, error)
+
+func newTransferClient(cfg client.Config, addr string) (TransferClient, io.Closer, error) {
+	conn, err := client.Dial(cfg, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewTransferClient(conn), conn, nil
+}
+
+type handOffStream struct {
+	instance *instance
+	stream   *stream
+}
+
+// transferOut hands the streams over to the ingesters taking over the token
+// ranges of this one. The streams which were handed off are removed, so that
+// only the remaining ones are flushed on failure.
+func (i *Ingester) transferOut(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, i.cfg.HandOff.Timeout)
+	defer cancel()
+
+	// The ring client is only needed during the hand-off, it is created once
+	// this ingester is already LEAVING the ring. The replication set of the
+	// streams it owned is extended past it, so no quorum is required: it would
+	// count this ingester as a missing replica.
+	kvClient, err := kv.NewClient(i.cfg.LifecyclerConfig.RingConfig.KVStore, ring.GetCodec(), nil, i.logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to create hand-off ring KV client")
+	}
+	r, err := ring.NewWithStoreClientAndStrategy(i.cfg.LifecyclerConfig.RingConfig, "ingester", RingKey, kvClient, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, i.logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to create hand-off ring client")
+	}
+	if err := services.StartAndAwaitRunning(ctx, r); err != nil {
+		return errors.Wrap(err, "failed to start hand-off ring client")
+	}
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	var errs util.MultiError
+	targets := map[string][]handOffStream{}
+	for _, inst := range i.getInstances() {
+		_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+			addr, err := i.handOffTarget(r, inst.instanceID, s.labelsString)
+			if err != nil {
+				errs.Add(errors.Wrapf(err, "failed to find the ingester taking over stream %s", s.labelsString))
+				return true, nil
+			}
+			targets[addr] = append(targets[addr], handOffStream{instance: inst, stream: s})
+			return true, nil
+		})
+	}
+
+	for addr, streams := range targets {
+		start := time.Now()
+		if err := i.transferStreams(ctx, addr, streams); err != nil {
+			errs.Add(errors.Wrapf(err, "failed to transfer streams to %s", addr))
+			continue
+		}
+		level.Info(i.logger).Log("msg", "transferred streams", "to", addr, "streams", len(streams), "duration", time.Since(start))
+	}
+	return errs.Err()
+}
+
+// handOffTarget returns the address of the ingester which becomes a replica
+// of the stream once this ingester left the ring.
+func (i *Ingester) handOffTarget(r ring.ReadRing, tenantID, lbs string) (string, error) {
+	descs, hosts, zones := ring.MakeBuffersForGet()
+	rs, err := r.Get(lokiring.TokenFor(tenantID, lbs), handOffOp, descs, hosts, zones)
+	if err != nil {
+		return "", err
+	}
+	// The replication set is extended past this ingester, the ingester
+	// replacing it comes last.
+	for j := len(rs.Instances) - 1; j >= 0; j-- {
+		if addr := rs.Instances[j].Addr; addr != i.lifecycler.Addr {
+			return addr, nil
+		}
+	}
+	return "", ring.ErrEmptyRing
+}
+
+func (i *Ingester) transferStreams(ctx context.Context, addr string, streams []handOffStream) error {
+	c, closer, err := i.cfg.transferClientFactory(i.clientConfig, addr)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	stream, err := c.TransferStreams(user.InjectOrgID(ctx, handOffOrgID))
+	if err != nil {
+		return err
+	}
+
+	var (
+		series Series
+		buf    []chunkWithBuffer
+	)
+	for _, hs := range streams {
+		s := hs.stream
+		s.chunkMtx.RLock()
+		buf, err = toWireChunks(unflushedChunks(s.chunks), buf)
+		if err != nil {
+			s.chunkMtx.RUnlock()
+			return err
+		}
+		series.Chunks = series.Chunks[:0]
+		for _, c := range buf {
+			series.Chunks = append(series.Chunks, c.Chunk)
+		}
+		series.UserID = hs.instance.instanceID
+		series.Fingerprint = uint64(s.fp)
+		series.Labels = logproto.FromLabelsToLabelAdapters(s.labels)
+		series.To = s.lastLine.ts
+		series.LastLine = s.lastLine.content
+		series.EntryCt = s.entryCt
+		series.HighestTs = s.highestTs
+		s.chunkMtx.RUnlock()
+
+		if len(series.Chunks) == 0 {
+			continue
+		}
+		if err := stream.Send(&series); err != nil {
+			return err
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return err
+	}
+
+	// The streams are held by the receiver now, forget about them so they
+	// don't get flushed.
+	for _, hs := range streams {
+		hs.instance.streams.WithLock(func() {
+			hs.stream.chunkMtx.Lock()
+			defer hs.stream.chunkMtx.Unlock()
+
+			i.metrics.memoryChunks.Sub(float64(len(hs.stream.chunks)))
+			hs.stream.chunks = nil
+			hs.instance.removeStream(hs.stream)
+		})
+	}
+	i.metrics.handOffStreams.WithLabelValues(handOffSent).Add(float64(len(streams)))
+	return nil
+}
+
+// TransferStreams receives the streams of an ingester leaving the ring and
+// takes them over.
+func (i *Ingester) TransferStreams(stream Transfer_TransferStreamsServer) error {
+	if i.readonly {
+		return ErrReadOnly
+	}
+
+	var received int
+	for {
+		series, err := stream.Recv()
+		if err == io.EOF {
+			level.Info(i.logger).Log("msg", "received transferred streams", "streams", received)
+			return stream.SendAndClose(&TransferStreamsResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		if err := i.adoptSeries(stream.Context(), series); err != nil {
+			return err
+		}
+		received++
+		i.metrics.handOffStreams.WithLabelValues(handOffReceived).Inc()
+	}
+}
+
+// adoptSeries adds the transferred chunks to the stream. The stream and the
+// entries of the transferred chunks are recorded in the WAL before the
+// transfer is acknowledged, so that they are replayed if this ingester crashes
+// before the next checkpoint. The replayed entries may be older than the ones
+// the stream received meanwhile, which requires unordered writes.
+func (i *Ingester) adoptSeries(ctx context.Context, series *Series) error {
+	inst, err := i.GetOrCreateInstance(series.UserID)
+	if err != nil {
+		return err
+	}
+
+	// The stream limits aren't enforced, the streams were already accepted by
+	// the leaving ingester.
+	s, err := inst.getOrCreateStream(ctx, logproto.Stream{
+		Labels: logproto.FromLabelAdaptersToLabels(series.Labels).String(),
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	record := recordPool.GetRecord()
+	record.UserID = series.UserID
+	defer recordPool.PutRecord(record)
+	record.Series = append(record.Series, tsdb_record.RefSeries{
+		Ref:    chunks.HeadSeriesRef(s.fp),
+		Labels: s.labels,
+	})
+
+	added, err := s.adoptChunks(series, record)
+	if err != nil {
+		return err
+	}
+	i.metrics.memoryChunks.Add(float64(added))
+	return i.wal.Log(record)
+}
@@ -0,0 +1,235 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+type fakeTransferClient struct {
+	receiver *Ingester
+}
+
+func (c *fakeTransferClient) TransferStreams(ctx context.Context, _ ...grpc.CallOption) (Transfer_TransferStreamsClient, error) {
+	return &fakeTransferStreamsClient{ctx: ctx, receiver: c.receiver}, nil
+}
+
+type fakeTransferStreamsClient struct {
+	grpc.ClientStream
+	ctx      context.Context
+	receiver *Ingester
+}
+
+func (c *fakeTransferStreamsClient) Send(series *Series) error {
+	// The sender reuses its buffers, go through the wire format like gRPC does.
+	data, err := series.Marshal()
+	if err != nil {
+		return err
+	}
+	var received Series
+	if err := received.Unmarshal(data); err != nil {
+		return err
+	}
+	return c.receiver.adoptSeries(c.ctx, &received)
+}
+
+func (c *fakeTransferStreamsClient) CloseAndRecv() (*TransferStreamsResponse, error) {
+	return &TransferStreamsResponse{}, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func TestTransferStreams(t *testing.T) {
+	_, sender := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	_, receiver := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	sender.cfg.transferClientFactory = func(_ client.Config, _ string) (TransferClient, io.Closer, error) {
+		return &fakeTransferClient{receiver: receiver}, nopCloser{}, nil
+	}
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	start := time.Now()
+	push := func(i *Ingester, lbs string, from, through int) {
+		stream := logproto.Stream{Labels: lbs}
+		for j := from; j < through; j++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+		_, err := i.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+	}
+	push(sender, `{bar="baz1", foo="bar"}`, 0, 5)
+	push(sender, `{bar="baz2", foo="bar"}`, 0, 5)
+	// The receiver already got entries of a stream once the sender was LEAVING.
+	push(receiver, `{bar="baz1", foo="bar"}`, 5, 10)
+
+	inst, ok := sender.getInstanceByID("test")
+	require.True(t, ok)
+	var streams []handOffStream
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		streams = append(streams, handOffStream{instance: inst, stream: s})
+		return true, nil
+	})
+	require.NoError(t, sender.transferStreams(context.Background(), "receiver", streams))
+
+	// The sender forgot about the streams, they won't be flushed.
+	require.Equal(t, 0, inst.streams.Len())
+	require.Equal(t, 2.0, testutil.ToFloat64(sender.metrics.handOffStreams.WithLabelValues(handOffSent)))
+
+	// The receiver keeps appending to the transferred head chunk.
+	push(receiver, `{bar="baz2", foo="bar"}`, 5, 10)
+	ensureIngesterData(ctx, t, start, start.Add(10*time.Second), receiver)
+}
+
+func TestTransferStreams_WALReplay(t *testing.T) {
+	walDir := t.TempDir()
+	receiverCfg := defaultIngesterTestConfigWithWAL(t, walDir)
+	// The receiver crashes before checkpointing the transferred chunks.
+	receiverCfg.WAL.CheckpointDuration = time.Hour
+	_, sender := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	_, receiver := newTestStore(t, receiverCfg, nil)
+	sender.cfg.transferClientFactory = func(_ client.Config, _ string) (TransferClient, io.Closer, error) {
+		return &fakeTransferClient{receiver: receiver}, nopCloser{}, nil
+	}
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	start := time.Now()
+	push := func(i *Ingester, lbs string, from, through int) {
+		stream := logproto.Stream{Labels: lbs}
+		for j := from; j < through; j++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+		_, err := i.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+	}
+	push(sender, `{bar="baz1", foo="bar"}`, 0, 5)
+	push(sender, `{bar="baz2", foo="bar"}`, 0, 5)
+	push(receiver, `{bar="baz1", foo="bar"}`, 5, 10)
+
+	inst, ok := sender.getInstanceByID("test")
+	require.True(t, ok)
+	var streams []handOffStream
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		streams = append(streams, handOffStream{instance: inst, stream: s})
+		return true, nil
+	})
+	require.NoError(t, sender.transferStreams(context.Background(), "receiver", streams))
+	push(receiver, `{bar="baz2", foo="bar"}`, 5, 10)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), receiver))
+	expectCheckpoint(t, walDir, false, time.Second)
+
+	// The transferred entries are replayed from the WAL segments.
+	_, restarted := newTestStore(t, receiverCfg, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), restarted) //nolint:errcheck
+	ensureIngesterData(ctx, t, start, start.Add(10*time.Second), restarted)
+}
+
+func TestTransferOut(t *testing.T) {
+	kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { require.NoError(t, closer.Close()) })
+
+	ingesters := map[string]*Ingester{}
+	newIngester := func(id string) (*testStore, *Ingester) {
+		cfg := defaultIngesterTestConfig(t)
+		cfg.LifecyclerConfig.RingConfig.KVStore.Mock = kvStore
+		cfg.LifecyclerConfig.RingConfig.ReplicationFactor = 1
+		cfg.LifecyclerConfig.ID = id
+		cfg.LifecyclerConfig.Addr = id
+		cfg.HandOff.Enabled = true
+		cfg.transferClientFactory = func(_ client.Config, addr string) (TransferClient, io.Closer, error) {
+			return &fakeTransferClient{receiver: ingesters[addr]}, nopCloser{}, nil
+		}
+		store, ing := newTestStore(t, cfg, nil)
+		ingesters[ing.lifecycler.Addr] = ing
+		return store, ing
+	}
+	senderStore, sender := newIngester("ingester-1")
+	_, receiver := newIngester("ingester-2")
+	defer services.StopAndAwaitTerminated(context.Background(), receiver) //nolint:errcheck
+
+	r, err := ring.NewWithStoreClientAndStrategy(sender.cfg.LifecyclerConfig.RingConfig, "ingester", RingKey, kvStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	require.Eventually(t, func() bool {
+		rs, err := r.GetAllHealthy(handOffOp)
+		return err == nil && len(rs.Instances) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	start := time.Now()
+	var streams []string
+	for j := 0; j < 10; j++ {
+		stream := logproto.Stream{Labels: fmt.Sprintf(`{foo="bar", i="%d"}`, j)}
+		for k := 0; k < 5; k++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(k) * time.Second),
+				Line:      fmt.Sprintf("line %d", k),
+			})
+		}
+		_, err := sender.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+		streams = append(streams, stream.Labels)
+	}
+
+	// Once the sender is LEAVING, every stream is handed over to the only other
+	// ingester, whether the sender owned its token or not.
+	require.NoError(t, sender.lifecycler.ChangeState(context.Background(), ring.LEAVING))
+	require.Eventually(t, func() bool {
+		state, err := r.GetInstanceState(sender.lifecycler.ID)
+		return err == nil && state == ring.LEAVING
+	}, 5*time.Second, 10*time.Millisecond)
+	inst, ok := sender.getInstanceByID("test")
+	require.True(t, ok)
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		addr, err := sender.handOffTarget(r, "test", s.labelsString)
+		require.NoError(t, err)
+		require.Equal(t, receiver.lifecycler.Addr, addr)
+		return true, nil
+	})
+
+	// Leaving the ring transfers the streams instead of flushing them.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), sender))
+	require.Empty(t, senderStore.chunks)
+	require.Equal(t, float64(len(streams)), testutil.ToFloat64(sender.metrics.handOffStreams.WithLabelValues(handOffSent)))
+	result := mockQuerierServer{ctx: ctx}
+	require.NoError(t, receiver.Query(&logproto.QueryRequest{
+		Selector: `{foo="bar"}`,
+		Limit:    100,
+		Start:    start,
+		End:      start.Add(5 * time.Second),
+	}, &result))
+	var received []string
+	for _, resp := range result.resps {
+		for _, s := range resp.Streams {
+			require.Len(t, s.Entries, 5)
+			received = append(received, s.Labels)
+		}
+	}
+	require.ElementsMatch(t, streams, received)
+}
+
+func TestTransferOutDisabled(t *testing.T) {
+	_, ing := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	require.ErrorIs(t, ing.TransferOut(context.Background()), ring.ErrTransferDisabled)
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/ingester/client"
 	"github.com/grafana/loki/v3/pkg/ingester/index"
 	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/kafka"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
@@ -96,8 +97,11 @@ type Config struct {
 
 	MaxReturnedErrors int `yaml:"max_returned_stream_errors"`
 
+	TenantMemoryCheckPeriod time.Duration `yaml:"tenant_memory_check_period"`
+
 	// For testing, you can override the address and ID of this ingester.
 	ingesterClientFactory func(cfg client.Config, addr string) (client.HealthAndIngesterClient, error)
+	transferClientFactory transferClientFactory
 
 	QueryStore                  bool          `yaml:"-"`
 	QueryStoreMaxLookBackPeriod time.Duration `yaml:"query_store_max_look_back_period"`
@@ -116,12 +120,23 @@ type Config struct {
 	MaxDroppedStreams int `yaml:"max_dropped_streams"`
 
 	ShutdownMarkerPath string `yaml:"shutdown_marker_path"`
+
+	KafkaConfig kafka.Config `yaml:"-"`
+
+	FlushDedup FlushDedupConfig `yaml:"flush_dedup" category:"experimental" doc:"description=Configures the deduplication of the chunks flushed by the replicas of a stream."`
+
+	HandOff HandOffConfig `yaml:"hand_off" category:"experimental" doc:"description=Configures the transfer of the in-memory chunks to the ingesters taking over the token ranges of a leaving ingester."`
+
+	Spill SpillConfig `yaml:"spill" category:"experimental" doc:"description=Configures the spilling of the chunk blocks held in memory to the local disk under memory pressure."`
 }
 
 // RegisterFlags registers the flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.LifecyclerConfig.RegisterFlags(f, util_log.Logger)
 	cfg.WAL.RegisterFlags(f)
+	cfg.FlushDedup.RegisterFlags(f)
+	cfg.HandOff.RegisterFlags(f)
+	cfg.Spill.RegisterFlags(f)
 
 	f.IntVar(&cfg.ConcurrentFlushes, "ingester.concurrent-flushes", 32, "How many flushes can happen concurrently from each stream.")
 	f.DurationVar(&cfg.FlushCheckPeriod, "ingester.flush-check-period", 30*time.Second, "How often should the ingester see if there are any blocks to flush. The first flush check is delayed by a random time up to 0.8x the flush check period. Additionally, there is +/- 1% jitter added to the interval.")
@@ -134,6 +149,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.SyncPeriod, "ingester.sync-period", 1*time.Hour, "Parameters used to synchronize ingesters to cut chunks at the same moment. Sync period is used to roll over incoming entry to a new chunk. If chunk's utilization isn't high enough (eg. less than 50% when sync_min_utilization is set to 0.5), then this chunk rollover doesn't happen.")
 	f.Float64Var(&cfg.SyncMinUtilization, "ingester.sync-min-utilization", 0.1, "Minimum utilization of chunk when doing synchronization.")
 	f.IntVar(&cfg.MaxReturnedErrors, "ingester.max-ignored-stream-errors", 10, "The maximum number of errors a stream will report to the user when a push fails. 0 to make unlimited.")
+	f.DurationVar(&cfg.TenantMemoryCheckPeriod, "ingester.tenant-memory-check-period", 10*time.Second, "How often the memory held by each tenant is measured, to update the per-tenant memory metrics and enforce the `max_memory_bytes_per_user` limit. 0 to only measure it when a tenant is above its limit, at most once per second.")
 	f.DurationVar(&cfg.MaxChunkAge, "ingester.max-chunk-age", 2*time.Hour, "The maximum duration of a timeseries chunk in memory. If a timeseries runs for longer than this, the current chunk will be flushed to the store and a new chunk created.")
 	f.DurationVar(&cfg.QueryStoreMaxLookBackPeriod, "ingester.query-store-max-look-back-period", 0, "How far back should an ingester be allowed to query the store for data, for use only with boltdb-shipper/tsdb index and filesystem object store. -1 for infinite.")
 	f.BoolVar(&cfg.AutoForgetUnhealthy, "ingester.autoforget-unhealthy", false, "Forget about ingesters having heartbeat timestamps older than `ring.kvstore.heartbeat_timeout`. This is equivalent to clicking on the `/ring` `forget` button in the UI: the ingester is removed from the ring. This is a useful setting when you are sure that an unhealthy node won't return. An example is when not using stateful sets or the equivalent. Use `memberlist.rejoin_interval` > 0 to handle network partition cases when using a memberlist.")
@@ -153,6 +169,10 @@ func (cfg *Config) Validate() error {
 		return err
 	}
 
+	if err = cfg.Spill.Validate(); err != nil {
+		return err
+	}
+
 	if cfg.IndexShards <= 0 {
 		return fmt.Errorf("invalid ingester index shard factor: %d", cfg.IndexShards)
 	}
@@ -180,12 +200,15 @@ type Interface interface {
 	logproto.PusherServer
 	logproto.QuerierServer
 	logproto.StreamDataServer
+	TransferServer
 
 	CheckReady(ctx context.Context) error
 	FlushHandler(w http.ResponseWriter, _ *http.Request)
 	GetOrCreateInstance(instanceID string) (*instance, error)
 	ShutdownHandler(w http.ResponseWriter, r *http.Request)
 	PrepareShutdown(w http.ResponseWriter, r *http.Request)
+	TenantMemoryHandler(w http.ResponseWriter, r *http.Request)
+	StreamsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // Ingester builds chunks for incoming log streams.
@@ -213,6 +236,10 @@ type Ingester struct {
 	loopQuit    chan struct{}
 	tailersQuit chan struct{}
 
+	// tenantMemoryTrigger wakes up the tenant memory loop when a push exceeds
+	// the memory limit of its tenant.
+	tenantMemoryTrigger chan struct{}
+
 	// One queue per flush thread.  Fingerprint is used to
 	// pick a queue.
 	flushQueues     []*util.PriorityQueue
@@ -242,6 +269,17 @@ type Ingester struct {
 	streamRateCalculator *StreamRateCalculator
 
 	writeLogManager *writefailures.Manager
+
+	// Consumes the partitions owned by the ingester when the Kafka write path
+	// is enabled, nil otherwise.
+	kafkaSubservices *services.Manager
+
+	// Deduplicates the chunks flushed by the replicas of a stream when
+	// enabled, nil otherwise.
+	flushDeduper *flushDeduper
+
+	// spiller moves the blocks of cold chunks to disk, if enabled.
+	spiller *spiller
 }
 
 // New makes a new Ingester.
@@ -249,6 +287,9 @@ func New(cfg Config, clientConfig client.Config, store Store, limits Limits, con
 	if cfg.ingesterClientFactory == nil {
 		cfg.ingesterClientFactory = client.New
 	}
+	if cfg.transferClientFactory == nil {
+		cfg.transferClientFactory = newTransferClient
+	}
 	compressionStats.Set(cfg.ChunkEncoding)
 	targetSizeStats.Set(int64(cfg.TargetChunkSize))
 	walStats.Set("disabled")
@@ -266,6 +307,7 @@ func New(cfg Config, clientConfig client.Config, store Store, limits Limits, con
 		store:                 store,
 		periodicConfigs:       store.GetSchemaConfigs(),
 		loopQuit:              make(chan struct{}),
+		tenantMemoryTrigger:   make(chan struct{}, 1),
 		flushQueues:           make([]*util.PriorityQueue, cfg.ConcurrentFlushes),
 		tailersQuit:           make(chan struct{}),
 		metrics:               metrics,
@@ -288,7 +330,17 @@ func New(cfg Config, clientConfig client.Config, store Store, limits Limits, con
 		}
 	}
 
-	wal, err := newWAL(cfg.WAL, registerer, metrics, newIngesterSeriesIter(i))
+	// The WAL is restored from the remote store before it is opened, which
+	// creates a new segment.
+	var shipper *walShipper
+	if cfg.WAL.Enabled && cfg.WAL.RemoteClient != nil {
+		shipper = newWALShipper(cfg.WAL, cfg.LifecyclerConfig.ID, metrics, logger)
+		if err := shipper.init(context.Background()); err != nil {
+			return nil, fmt.Errorf("restoring WAL from the remote store: %w", err)
+		}
+	}
+
+	wal, err := newWAL(cfg.WAL, registerer, metrics, newIngesterSeriesIter(i), shipper)
 	if err != nil {
 		return nil, err
 	}
@@ -306,6 +358,22 @@ func New(cfg Config, clientConfig client.Config, store Store, limits Limits, con
 	// which depends on it.
 	i.limiter = NewLimiter(limits, metrics, i.lifecycler, cfg.LifecyclerConfig.RingConfig.ReplicationFactor)
 
+	if cfg.KafkaConfig.Enabled {
+		if err := i.setupKafkaReader(registerer, metricsNamespace); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.FlushDedup.Enabled {
+		if err := i.setupFlushDeduper(registerer, metricsNamespace); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Spill.Enabled {
+		i.spiller = newSpiller(cfg.Spill, i.getInstances, i.metrics, i.logger)
+	}
+
 	i.Service = services.NewBasicService(i.starting, i.running, i.stopping)
 
 	i.setupAutoForget()
@@ -409,6 +477,21 @@ func (i *Ingester) setupAutoForget() {
 }
 
 func (i *Ingester) starting(ctx context.Context) error {
+	// The owners of the streams must be resolvable before the first flush,
+	// which can happen during the WAL replay.
+	if i.flushDeduper != nil {
+		if err := services.StartManagerAndAwaitHealthy(ctx, i.flushDeduper.subservices); err != nil {
+			return errors.Wrap(err, "failed to start flush deduper")
+		}
+	}
+
+	// Spilling also bounds the memory used by the chunks replayed from the WAL.
+	if i.spiller != nil {
+		if err := services.StartAndAwaitRunning(ctx, i.spiller); err != nil {
+			return errors.Wrap(err, "failed to start spiller")
+		}
+	}
+
 	if i.cfg.WAL.Enabled {
 		start := time.Now()
 
@@ -515,12 +598,36 @@ func (i *Ingester) starting(ctx context.Context) error {
 		i.setPrepareShutdown()
 	}
 
-	// start our loop
-	i.loopDone.Add(1)
+	// start our loops
+	i.loopDone.Add(2)
 	go i.loop()
+	go i.tenantMemoryLoop()
+
+	// Consume from Kafka once the WAL has been replayed and the ingester is in
+	// the ring, so owned partitions can be resolved.
+	if i.kafkaSubservices != nil {
+		if err := services.StartManagerAndAwaitHealthy(ctx, i.kafkaSubservices); err != nil {
+			return errors.Wrap(err, "failed to start kafka reader")
+		}
+	}
 	return nil
 }
 
+// setupKafkaReader creates the reader consuming the partitions owned by the
+// ingester, along with the ring client used to resolve the ownership.
+func (i *Ingester) setupKafkaReader(registerer prometheus.Registerer, metricsNamespace string) error {
+	// The ring client is registered with its own prefix, not to collide with
+	// the metrics of the ingester ring client used by other components.
+	kafkaRing, err := ring.New(i.cfg.LifecyclerConfig.RingConfig, "ingester", RingKey, i.logger, prometheus.WrapRegistererWithPrefix(metricsNamespace+"_kafka_", registerer))
+	if err != nil {
+		return errors.Wrap(err, "failed to create kafka ring client")
+	}
+
+	reader := kafka.NewReader(i.cfg.KafkaConfig, i.lifecycler.Addr, kafkaRing, i, i.flushWatermark, registerer, i.logger)
+	i.kafkaSubservices, err = services.NewManager(kafkaRing, reader)
+	return err
+}
+
 func (i *Ingester) running(ctx context.Context) error {
 	var serviceError error
 	select {
@@ -546,8 +653,14 @@ func (i *Ingester) running(ctx context.Context) error {
 //
 // At this point, loop no longer runs, but flushers are still running.
 func (i *Ingester) stopping(_ error) error {
-	i.stopIncomingRequests()
 	var errs util.MultiError
+	// Stop consuming before rejecting pushes, the offsets of everything that
+	// isn't flushed on shutdown are left uncommitted.
+	if i.kafkaSubservices != nil {
+		errs.Add(services.StopManagerAndAwaitStopped(context.Background(), i.kafkaSubservices))
+	}
+
+	i.stopIncomingRequests()
 	errs.Add(i.wal.Stop())
 
 	if i.flushOnShutdownSwitch.Get() {
@@ -560,6 +673,15 @@ func (i *Ingester) stopping(_ error) error {
 	}
 	i.flushQueuesDone.Wait()
 
+	if i.flushDeduper != nil {
+		errs.Add(services.StopManagerAndAwaitStopped(context.Background(), i.flushDeduper.subservices))
+	}
+
+	// The spilled blocks are read until the last flush is done.
+	if i.spiller != nil {
+		errs.Add(services.StopAndAwaitTerminated(context.Background(), i.spiller))
+	}
+
 	i.streamRateCalculator.Stop()
 
 	// In case the flag to terminate on shutdown is set or this instance is marked to release its resources,
@@ -852,6 +974,50 @@ func (i *Ingester) GetStreamRates(ctx context.Context, _ *logproto.StreamRatesRe
 	return &logproto.StreamRatesResponse{StreamRates: rates}, nil
 }
 
+// GetStreamChunks returns the chunks the ingester holds for a stream, so that
+// its other replicas can deduplicate their flushes.
+func (i *Ingester) GetStreamChunks(ctx context.Context, req *logproto.StreamChunksRequest) (*logproto.StreamChunksResponse, error) {
+	instanceID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logproto.StreamChunksResponse{}
+	if i.flushDeduper != nil {
+		resp.Chunks = i.flushDeduper.flushedChunks(instanceID, req.Labels, req.From, req.Through)
+	}
+
+	instance, ok := i.getInstanceByID(instanceID)
+	if !ok {
+		return resp, nil
+	}
+	s, ok := instance.streams.Load(req.Labels)
+	if !ok {
+		return resp, nil
+	}
+
+	s.chunkMtx.RLock()
+	defer s.chunkMtx.RUnlock()
+	for _, c := range s.chunks {
+		from, through := util.RoundToMilliseconds(c.chunk.Bounds())
+		if through < req.From || from > req.Through {
+			continue
+		}
+		sc := logproto.StreamChunk{From: from, Through: through}
+		if !c.flushed.IsZero() {
+			// Chunks recovered as flushed from a checkpoint have no checksum,
+			// so the other replicas upload their own copies.
+			if !c.checksummed {
+				continue
+			}
+			sc.Flushed = true
+			sc.Checksum = c.checksum
+		}
+		resp.Chunks = append(resp.Chunks, sc)
+	}
+	return resp, nil
+}
+
 func (i *Ingester) GetOrCreateInstance(instanceID string) (*instance, error) { //nolint:revive
 	inst, ok := i.getInstanceByID(instanceID)
 	if ok {
@@ -867,6 +1033,7 @@ func (i *Ingester) GetOrCreateInstance(instanceID string) (*instance, error) { /
 		if err != nil {
 			return nil, err
 		}
+		inst.memoryLimitExceeded = i.triggerTenantMemoryCheck
 		i.instances[instanceID] = inst
 		activeTenantsStats.Set(int64(len(i.instances)))
 	}
@@ -1306,12 +1473,15 @@ func (i *Ingester) Tail(req *logproto.TailRequest, queryServer logproto.Querier_
 		return err
 	}
 
-	expr, ok := req.Plan.AST.(syntax.LogSelectorExpr)
-	if !ok {
-		return fmt.Errorf("unsupported query expression: want (LogSelectorExpr), got (%T)", req.Plan.AST)
+	var tailer *tailer
+	switch expr := req.Plan.AST.(type) {
+	case syntax.LogSelectorExpr:
+		tailer, err = newTailer(instanceID, expr, queryServer, i.cfg.MaxDroppedStreams, req.SampleRatio)
+	case syntax.SampleExpr:
+		tailer, err = newMetricTailer(instanceID, expr, queryServer, i.cfg.MaxDroppedStreams, time.Duration(req.Step)*time.Millisecond)
+	default:
+		return fmt.Errorf("unsupported query expression: want (LogSelectorExpr) or (SampleExpr), got (%T)", req.Plan.AST)
 	}
-
-	tailer, err := newTailer(instanceID, expr, queryServer, i.cfg.MaxDroppedStreams)
 	if err != nil {
 		return err
 	}
@@ -125,6 +125,15 @@ type instance struct {
 	schemaconfig *config.SchemaConfig
 
 	customStreamsTracker push.UsageTracker
+
+	memoryMtx sync.Mutex
+	// memoryUsage is the memory held as of the last measurement, memoryPushed
+	// the bytes pushed since.
+	memoryUsage  MemoryUsage
+	memoryPushed int
+	// memoryLimitExceeded is called when a push is rejected because of the
+	// memory limit.
+	memoryLimitExceeded func()
 }
 
 func newInstance(
@@ -219,6 +228,10 @@ func (i *instance) Push(ctx context.Context, req *logproto.PushRequest) error {
 	defer recordPool.PutRecord(record)
 	rateLimitWholeStream := i.limiter.limits.ShardStreams(i.instanceID).Enabled
 
+	if err := i.checkMemoryLimit(req); err != nil {
+		return err
+	}
+
 	var appendErr error
 	for _, reqStream := range req.Streams {
 
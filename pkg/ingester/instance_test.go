@@ -548,7 +548,7 @@ func Benchmark_instance_addNewTailer(b *testing.B) {
 	inst, _ := newInstance(&Config{}, defaultPeriodConfigs, "test", limiter, loki_runtime.DefaultTenantConfigs(), noopWAL{}, NilMetrics, &OnceSwitch{}, nil, nil, nil, NewStreamRateCalculator(), nil)
 	expr, err := syntax.ParseLogSelector(`{namespace="foo",pod="bar",instance=~"10.*"}`, true)
 	require.NoError(b, err)
-	t, err := newTailer("foo", expr, nil, 10)
+	t, err := newTailer("foo", expr, nil, 10, 0)
 	require.NoError(b, err)
 	for i := 0; i < 10000; i++ {
 		require.NoError(b, inst.Push(ctx, &logproto.PushRequest{
@@ -987,6 +987,55 @@ func Test_QueryWithDelete(t *testing.T) {
 	require.Equal(t, logs, []string{`msg="dispatcher_7"`})
 }
 
+func Test_QueryShardedStreamsAfterShardCountChange(t *testing.T) {
+	instance := defaultInstance(t)
+
+	// The stream is first sharded in 4, then in 2 once its rate drops: the
+	// shards written before keep their __stream_shard__ values.
+	var expected []string
+	for i, shards := range []int{4, 4, 4, 4, 2, 2} {
+		shard := i % shards
+		line := fmt.Sprintf("line %d of %d shards", i, shards)
+		expected = append(expected, line)
+		require.NoError(t, instance.Push(context.TODO(), &logproto.PushRequest{
+			Streams: []logproto.Stream{{
+				Labels:  fmt.Sprintf(`{app="sharded", %s="%d"}`, ShardLbName, shard),
+				Entries: []logproto.Entry{{Timestamp: time.Unix(0, int64(i+1)*1e6), Line: line}},
+			}},
+		}))
+	}
+
+	query := func(selector string) []string {
+		it, err := instance.Query(context.TODO(),
+			logql.SelectLogParams{
+				QueryRequest: &logproto.QueryRequest{
+					Selector:  selector,
+					Limit:     100,
+					Start:     time.Unix(0, 0),
+					End:       time.Unix(0, 100000000),
+					Direction: logproto.FORWARD,
+					Plan: &plan.QueryPlan{
+						AST: syntax.MustParseExpr(selector),
+					},
+				},
+			},
+		)
+		require.NoError(t, err)
+		defer it.Close()
+
+		var lines []string
+		for it.Next() {
+			lines = append(lines, it.Entry().Line)
+		}
+		return lines
+	}
+
+	require.Equal(t, expected, query(`{app="sharded"}`))
+	// Shards which are not written anymore are still matched.
+	require.Equal(t, []string{"line 3 of 4 shards"}, query(`{app="sharded", __stream_shard__="3"}`))
+	require.Equal(t, []string{"line 0 of 4 shards", "line 4 of 2 shards"}, query(`{app="sharded", __stream_shard__="0"}`))
+}
+
 func Test_QuerySampleWithDelete(t *testing.T) {
 	instance := defaultInstance(t)
 
@@ -28,6 +28,7 @@ type Limits interface {
 	MaxGlobalStreamsPerUser(userID string) int
 	PerStreamRateLimit(userID string) validation.RateLimit
 	ShardStreams(userID string) *shardstreams.Config
+	MaxMemoryBytesPerUser(userID string) int
 }
 
 // Limiter implements primitives to get the maximum number of streams
@@ -111,6 +112,17 @@ func (l *Limiter) AssertMaxStreamsPerUser(userID string, streams int) error {
 	return fmt.Errorf(errMaxStreamsPerUserLimitExceeded, userID, streams, calculatedLimit, localLimit, globalLimit, adjustedGlobalLimit)
 }
 
+// MaxMemoryBytes returns the maximum size of the data held in memory for a
+// tenant, 0 when unlimited.
+func (l *Limiter) MaxMemoryBytes(userID string) int {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	if l.disabled {
+		return 0
+	}
+	return l.limits.MaxMemoryBytesPerUser(userID)
+}
+
 func (l *Limiter) convertGlobalToLocalLimit(globalLimit int) int {
 	if globalLimit == 0 {
 		return 0
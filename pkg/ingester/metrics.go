@@ -25,6 +25,9 @@ type ingesterMetrics struct {
 	walCorruptionsTotal     *prometheus.CounterVec
 	walLoggedBytesTotal     prometheus.Counter
 	walRecordsLogged        prometheus.Counter
+	walUploadedBytesTotal   prometheus.Counter
+	walUploadFailures       prometheus.Counter
+	walRestoredBytesTotal   prometheus.Counter
 
 	recoveredStreamsTotal prometheus.Counter
 	recoveredChunksTotal  prometheus.Counter
@@ -65,6 +68,19 @@ type ingesterMetrics struct {
 	shutdownMarker prometheus.Gauge
 
 	flushQueueLength prometheus.Gauge
+
+	flushDedupChunks     *prometheus.CounterVec
+	flushDedupBytesSaved prometheus.Counter
+	flushDedupDeferred   prometheus.Counter
+
+	handOffStreams *prometheus.CounterVec
+
+	spillBlocks        *prometheus.CounterVec
+	spillBytes         *prometheus.CounterVec
+	spillResidentBytes prometheus.Gauge
+	spillDiskBytes     prometheus.Gauge
+
+	tenantMemoryBytes *prometheus.GaugeVec
 }
 
 // setRecoveryBytesInUse bounds the bytes reports to >= 0.
@@ -142,6 +158,18 @@ func newIngesterMetrics(r prometheus.Registerer, metricsNamespace string) *inges
 			Name: "loki_ingester_wal_logged_bytes_total",
 			Help: "Total number of bytes written to disk for WAL records.",
 		}),
+		walUploadedBytesTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "loki_ingester_wal_uploaded_bytes_total",
+			Help: "Total number of bytes of WAL segments and checkpoints uploaded to the remote store.",
+		}),
+		walUploadFailures: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "loki_ingester_wal_upload_failures_total",
+			Help: "Total number of failed syncs of the WAL to the remote store.",
+		}),
+		walRestoredBytesTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "loki_ingester_wal_restored_bytes_total",
+			Help: "Total number of bytes of WAL segments and checkpoints downloaded from the remote store.",
+		}),
 		recoveredStreamsTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
 			Name: "loki_ingester_wal_recovered_streams_total",
 			Help: "Total number of streams recovered from the WAL.",
@@ -287,5 +315,54 @@ func newIngesterMetrics(r prometheus.Registerer, metricsNamespace string) *inges
 			Name:      "flush_queue_length",
 			Help:      "The total number of series pending in the flush queue.",
 		}),
+
+		flushDedupChunks: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_flush_dedup_chunks_total",
+			Help:      "Total chunks checked against the owner replica of their stream before flushing, by result.",
+		}, []string{"result"}),
+		flushDedupBytesSaved: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_flush_dedup_bytes_saved_total",
+			Help:      "Total compressed bytes not uploaded because the owner replica of the stream already flushed them.",
+		}),
+		flushDedupDeferred: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_flush_dedup_deferred_total",
+			Help:      "Total chunk flushes deferred while waiting for the owner replica of the stream to flush.",
+		}),
+
+		handOffStreams: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_hand_off_streams_total",
+			Help:      "Total streams transferred between ingesters when leaving the ring, by direction.",
+		}, []string{"direction"}),
+
+		spillBlocks: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_spill_blocks_total",
+			Help:      "Total chunk blocks spilled to disk and read back from it, by operation.",
+		}, []string{"operation"}),
+		spillBytes: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_spill_bytes_total",
+			Help:      "Total compressed bytes of the chunk blocks spilled to disk and read back from it, by operation.",
+		}, []string{"operation"}),
+		spillResidentBytes: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_spill_resident_bytes",
+			Help:      "Bytes of chunk data held in memory, compressed blocks and uncompressed head blocks, as of the last spill check.",
+		}),
+		spillDiskBytes: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_spill_disk_bytes",
+			Help:      "Compressed bytes of the chunk blocks spilled to disk and still referenced.",
+		}),
+
+		tenantMemoryBytes: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "ingester_tenant_memory_bytes",
+			Help:      "Bytes held in memory per tenant, by type: the compressed chunk blocks, the uncompressed head blocks and an estimate of the index.",
+		}, []string{"tenant", "type"}),
 	}
 }
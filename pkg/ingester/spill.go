@@ -0,0 +1,392 @@
+package ingester
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/fileutil"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/util/flagext"
+)
+
+const (
+	spillOpSpill  = "spill"
+	spillOpReload = "reload"
+)
+
+var errSpillSegmentRemoved = errors.New("spill segment removed")
+
+// SpillConfig configures the spilling of the chunk blocks held in memory to
+// the local disk.
+type SpillConfig struct {
+	Enabled             bool             `yaml:"enabled"`
+	Dir                 string           `yaml:"dir"`
+	MemoryBudget        flagext.ByteSize `yaml:"memory_budget"`
+	IdleHeadBlockPeriod time.Duration    `yaml:"idle_head_block_period"`
+	CheckPeriod         time.Duration    `yaml:"check_period"`
+	SegmentSize         flagext.ByteSize `yaml:"segment_size"`
+}
+
+func (cfg *SpillConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ingester.spill.enabled", false, "Experimental: Spill the compressed blocks of the closed chunks waiting to be flushed to the local disk when the chunk data held in memory exceeds the memory budget, least recently used chunks first. Queries read the spilled blocks back from disk.")
+	f.StringVar(&cfg.Dir, "ingester.spill.dir", "spill", "Directory the spilled blocks are written to. Its content is deleted on startup.")
+	cfg.MemoryBudget = 1 << 30
+	f.Var(&cfg.MemoryBudget, "ingester.spill.memory-budget", "Size of the chunk data held in memory, compressed blocks and uncompressed head blocks, above which blocks are spilled to disk. A unit suffix (KB, MB, GB) may be applied.")
+	f.DurationVar(&cfg.IdleHeadBlockPeriod, "ingester.spill.idle-head-block-period", 0, "Also spill the open chunks of the streams which received no data for this long, cutting their head block first. 0 to only spill closed chunks.")
+	f.DurationVar(&cfg.CheckPeriod, "ingester.spill.check-period", 10*time.Second, "How often the chunk data held in memory is checked against the memory budget.")
+	cfg.SegmentSize = 128 << 20
+	f.Var(&cfg.SegmentSize, "ingester.spill.segment-size", "Size of the files the spilled blocks are appended to. A file is deleted once all its blocks were dropped from memory. A unit suffix (KB, MB, GB) may be applied.")
+}
+
+func (cfg *SpillConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Dir == "" {
+		return errors.New("the spill directory must be set")
+	}
+	if cfg.MemoryBudget <= 0 {
+		return errors.Errorf("invalid spill memory budget: %v", cfg.MemoryBudget)
+	}
+	if cfg.CheckPeriod <= 0 {
+		return errors.Errorf("invalid spill check period: %v", cfg.CheckPeriod)
+	}
+	if cfg.SegmentSize <= 0 {
+		return errors.Errorf("invalid spill segment size: %v", cfg.SegmentSize)
+	}
+	return nil
+}
+
+// spillSegment is a file the spilled blocks are appended to. It is memory
+// mapped once full.
+type spillSegment struct {
+	mtx     sync.RWMutex
+	path    string
+	f       *os.File
+	mmap    *fileutil.MmapFile
+	size    int
+	live    int
+	removed bool
+}
+
+func (s *spillSegment) read(offset, length int) ([]byte, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if s.removed {
+		return nil, errSpillSegmentRemoved
+	}
+	b := make([]byte, length)
+	if s.mmap != nil {
+		copy(b, s.mmap.Bytes()[offset:offset+length])
+		return b, nil
+	}
+	if _, err := s.f.ReadAt(b, int64(offset)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// seal memory maps the segment, it isn't written to anymore.
+func (s *spillSegment) seal() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	s.f = nil
+	if s.size == 0 {
+		return nil
+	}
+	mmap, err := fileutil.OpenMmapFile(s.path)
+	if err != nil {
+		return err
+	}
+	s.mmap = mmap
+	return nil
+}
+
+func (s *spillSegment) remove() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.removed = true
+	if s.f != nil {
+		if err := s.f.Close(); err != nil {
+			return err
+		}
+	}
+	if s.mmap != nil {
+		if err := s.mmap.Close(); err != nil {
+			return err
+		}
+	}
+	return os.Remove(s.path)
+}
+
+type spillRef struct {
+	segment *spillSegment
+	length  int
+}
+
+// spillCandidate is a chunk whose blocks can be spilled.
+type spillCandidate struct {
+	stream   *stream
+	chunk    *chunkenc.MemChunk
+	lastUsed time.Time
+	// head is set for the open chunks of idle streams, updated is when they
+	// were last appended to.
+	head    bool
+	updated time.Time
+}
+
+// spiller keeps the size of the chunk data held in memory under the budget, by
+// moving the blocks of the least recently used chunks to segment files on the
+// local disk.
+type spiller struct {
+	services.Service
+
+	cfg       SpillConfig
+	instances func() []*instance
+	metrics   *ingesterMetrics
+	logger    log.Logger
+
+	// The fields below are only accessed by the spill loop.
+	active  *spillSegment
+	nextID  int
+	spilled map[*chunkenc.MemChunk][]spillRef
+}
+
+func newSpiller(cfg SpillConfig, instances func() []*instance, metrics *ingesterMetrics, logger log.Logger) *spiller {
+	s := &spiller{
+		cfg:       cfg,
+		instances: instances,
+		metrics:   metrics,
+		logger:    log.With(logger, "component", "spiller"),
+		spilled:   map[*chunkenc.MemChunk][]spillRef{},
+	}
+	s.Service = services.NewTimerService(cfg.CheckPeriod, s.starting, s.iteration, s.stopping)
+	return s
+}
+
+func (s *spiller) starting(_ context.Context) error {
+	// The spilled blocks are recovered from the WAL along with the rest of
+	// the chunks, the previous segments aren't needed.
+	if err := os.RemoveAll(s.cfg.Dir); err != nil {
+		return errors.Wrap(err, "failed to clean up the spill directory")
+	}
+	return os.MkdirAll(s.cfg.Dir, 0o750)
+}
+
+func (s *spiller) iteration(_ context.Context) error {
+	s.check(time.Now())
+	return nil
+}
+
+func (s *spiller) stopping(_ error) error {
+	var firstErr error
+	for seg := range s.segments() {
+		if err := seg.remove(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.active = nil
+	s.spilled = map[*chunkenc.MemChunk][]spillRef{}
+	return firstErr
+}
+
+func (s *spiller) segments() map[*spillSegment]struct{} {
+	segments := map[*spillSegment]struct{}{}
+	if s.active != nil {
+		segments[s.active] = struct{}{}
+	}
+	for _, refs := range s.spilled {
+		for _, ref := range refs {
+			segments[ref.segment] = struct{}{}
+		}
+	}
+	return segments
+}
+
+// check releases the spilled blocks of the chunks dropped from memory, then
+// spills the least recently used chunks until the blocks held in memory fit
+// in the budget.
+func (s *spiller) check(now time.Time) {
+	resident, candidates, seen := s.collect(now)
+	s.release(seen)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+	})
+	for _, c := range candidates {
+		if resident <= int(s.cfg.MemoryBudget) {
+			break
+		}
+		released, err := s.spillChunk(c)
+		resident -= released
+		if err != nil {
+			level.Error(s.logger).Log("msg", "failed to spill chunk", "tenant", c.stream.tenant, "stream", c.stream.labelsString, "err", err)
+			break
+		}
+	}
+	s.metrics.spillResidentBytes.Set(float64(resident))
+}
+
+// collect returns the size of the chunk data held in memory, compressed blocks
+// and uncompressed head blocks, along with the chunks which can be spilled and
+// the chunks still held by the streams.
+func (s *spiller) collect(now time.Time) (int, []spillCandidate, map[*chunkenc.MemChunk]struct{}) {
+	var (
+		resident   int
+		candidates []spillCandidate
+		seen       = map[*chunkenc.MemChunk]struct{}{}
+	)
+	for _, inst := range s.instances() {
+		_ = inst.streams.ForEach(func(st *stream) (bool, error) {
+			lastQueried := time.Unix(0, st.lastQueried.Load())
+
+			st.chunkMtx.RLock()
+			defer st.chunkMtx.RUnlock()
+			for j, c := range st.chunks {
+				seen[c.chunk] = struct{}{}
+				size := c.chunk.ResidentBlocksSize()
+				resident += size + c.chunk.HeadBlockSize()
+
+				lastUsed := c.lastUpdated
+				if lastQueried.After(lastUsed) {
+					lastUsed = lastQueried
+				}
+				head := j == len(st.chunks)-1 && !c.closed
+				if head && (s.cfg.IdleHeadBlockPeriod <= 0 || now.Sub(c.lastUpdated) < s.cfg.IdleHeadBlockPeriod) {
+					continue
+				}
+				if size == 0 && (!head || c.chunk.HeadBlockSize() == 0) {
+					continue
+				}
+				candidates = append(candidates, spillCandidate{stream: st, chunk: c.chunk, lastUsed: lastUsed, head: head, updated: c.lastUpdated})
+			}
+			return true, nil
+		})
+	}
+	return resident, candidates, seen
+}
+
+// release drops the references to the spilled blocks of the chunks which
+// aren't held in memory anymore, and removes the segments without blocks.
+func (s *spiller) release(seen map[*chunkenc.MemChunk]struct{}) {
+	for chk, refs := range s.spilled {
+		if _, ok := seen[chk]; ok {
+			continue
+		}
+		delete(s.spilled, chk)
+		for _, ref := range refs {
+			ref.segment.live -= ref.length
+			s.metrics.spillDiskBytes.Sub(float64(ref.length))
+			if ref.segment.live > 0 {
+				continue
+			}
+			if ref.segment == s.active {
+				s.active = nil
+			}
+			if err := ref.segment.remove(); err != nil {
+				level.Warn(s.logger).Log("msg", "failed to remove spill segment", "path", ref.segment.path, "err", err)
+			}
+		}
+	}
+}
+
+// spillChunk spills the blocks of a chunk and returns the number of bytes
+// released.
+func (s *spiller) spillChunk(c spillCandidate) (released int, err error) {
+	c.stream.chunkMtx.Lock()
+	defer c.stream.chunkMtx.Unlock()
+
+	// The chunk may have been flushed, or the stream may have received data
+	// since it was collected.
+	idx := -1
+	for j := range c.stream.chunks {
+		if c.stream.chunks[j].chunk == c.chunk {
+			idx = j
+			break
+		}
+	}
+	if idx < 0 {
+		return 0, nil
+	}
+	before := c.chunk.ResidentBlocksSize() + c.chunk.HeadBlockSize()
+	defer func() {
+		released = before - c.chunk.ResidentBlocksSize() - c.chunk.HeadBlockSize()
+	}()
+	if c.head {
+		desc := c.stream.chunks[idx]
+		if idx != len(c.stream.chunks)-1 || desc.closed || desc.lastUpdated.After(c.updated) {
+			return 0, nil
+		}
+		if err := c.chunk.Close(); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = c.chunk.SpillBlocks(func(b []byte) (chunkenc.BlockLoader, error) {
+		ref, offset, err := s.write(b)
+		if err != nil {
+			return nil, err
+		}
+		s.spilled[c.chunk] = append(s.spilled[c.chunk], ref)
+		return func() ([]byte, error) {
+			data, err := ref.segment.read(offset, ref.length)
+			if err != nil {
+				return nil, err
+			}
+			s.metrics.spillBlocks.WithLabelValues(spillOpReload).Inc()
+			s.metrics.spillBytes.WithLabelValues(spillOpReload).Add(float64(len(data)))
+			return data, nil
+		}, nil
+	})
+	return 0, err
+}
+
+// write appends the data of a block to the active segment, cutting a new
+// segment when it is full.
+func (s *spiller) write(b []byte) (spillRef, int, error) {
+	if s.active != nil && s.active.size > 0 && s.active.size+len(b) > int(s.cfg.SegmentSize) {
+		if err := s.active.seal(); err != nil {
+			return spillRef{}, 0, errors.Wrap(err, "failed to seal spill segment")
+		}
+		s.active = nil
+	}
+	if s.active == nil {
+		path := filepath.Join(s.cfg.Dir, fmt.Sprintf("%08d", s.nextID))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o640)
+		if err != nil {
+			return spillRef{}, 0, errors.Wrap(err, "failed to create spill segment")
+		}
+		s.nextID++
+		s.active = &spillSegment{path: path, f: f}
+	}
+
+	seg := s.active
+	offset := seg.size
+	if _, err := seg.f.WriteAt(b, int64(offset)); err != nil {
+		return spillRef{}, 0, errors.Wrap(err, "failed to write spill segment")
+	}
+	seg.size += len(b)
+	seg.live += len(b)
+
+	s.metrics.spillBlocks.WithLabelValues(spillOpSpill).Inc()
+	s.metrics.spillBytes.WithLabelValues(spillOpSpill).Add(float64(len(b)))
+	s.metrics.spillDiskBytes.Add(float64(len(b)))
+	return spillRef{segment: seg, length: len(b)}, offset, nil
+}
@@ -0,0 +1,90 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestSpiller(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.Spill = SpillConfig{
+		Enabled:             true,
+		Dir:                 t.TempDir(),
+		MemoryBudget:        1,
+		IdleHeadBlockPeriod: time.Minute,
+		CheckPeriod:         99999 * time.Hour,
+		SegmentSize:         1024,
+	}
+	_, ing := newTestStore(t, cfg, nil)
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	start := time.Now()
+	for _, lbs := range []string{`{bar="baz1", foo="bar"}`, `{bar="baz2", foo="bar"}`} {
+		stream := logproto.Stream{Labels: lbs}
+		for j := 0; j < 10; j++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+		_, err := ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+	}
+
+	// The streams aren't idle yet, their head chunks are kept in memory.
+	ing.spiller.check(time.Now())
+	require.Equal(t, 0.0, testutil.ToFloat64(ing.metrics.spillBlocks.WithLabelValues(spillOpSpill)))
+
+	ing.spiller.check(time.Now().Add(2 * time.Minute))
+	require.Equal(t, 2.0, testutil.ToFloat64(ing.metrics.spillBlocks.WithLabelValues(spillOpSpill)))
+	require.Equal(t, 0.0, testutil.ToFloat64(ing.metrics.spillResidentBytes))
+	require.Greater(t, testutil.ToFloat64(ing.metrics.spillDiskBytes), 0.0)
+
+	inst, ok := ing.getInstanceByID("test")
+	require.True(t, ok)
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		require.Equal(t, 0, s.chunks[0].chunk.ResidentBlocksSize())
+		return true, nil
+	})
+
+	// Queries read the spilled blocks back.
+	ensureIngesterData(ctx, t, start, start.Add(10*time.Second), ing)
+	require.Equal(t, 2.0, testutil.ToFloat64(ing.metrics.spillBlocks.WithLabelValues(spillOpReload)))
+
+	// The segments are removed once the chunks are dropped from memory.
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		s.chunkMtx.Lock()
+		defer s.chunkMtx.Unlock()
+		s.chunks = nil
+		return true, nil
+	})
+	ing.spiller.check(time.Now())
+	require.Equal(t, 0.0, testutil.ToFloat64(ing.metrics.spillDiskBytes))
+	entries, err := os.ReadDir(cfg.Spill.Dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSpillSegmentRemoved(t *testing.T) {
+	s := newSpiller(SpillConfig{Dir: t.TempDir(), SegmentSize: 1024, CheckPeriod: time.Hour}, nil, newIngesterMetrics(nil, "loki"), nil)
+	ref, offset, err := s.write([]byte("block"))
+	require.NoError(t, err)
+
+	b, err := ref.segment.read(offset, ref.length)
+	require.NoError(t, err)
+	require.Equal(t, "block", string(b))
+
+	// In-flight queries may still read the blocks of a dropped chunk.
+	require.NoError(t, ref.segment.remove())
+	_, err = ref.segment.read(offset, ref.length)
+	require.ErrorIs(t, err, errSpillSegmentRemoved)
+}
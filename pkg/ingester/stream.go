@@ -14,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/v3/pkg/chunkenc"
 	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
@@ -53,6 +54,10 @@ type stream struct {
 	// It also determines chunk synchronization when unordered writes are disabled.
 	lastLine line
 
+	// lastQueried is the time in nanoseconds of the last query of the stream,
+	// used to spill the least recently used chunks.
+	lastQueried atomic.Int64
+
 	// keeps track of the highest timestamp accepted by the stream.
 	// This is used when unordered writes are enabled to cap the validity window
 	// of accepted writes and for chunk synchronization.
@@ -86,7 +91,18 @@ type chunkDesc struct {
 	flushed time.Time
 	reason  string
 
+	// created is when the chunk was created in this ingester, used to find out
+	// up to when all the pushed data has been flushed. It is zero for chunks
+	// recovered from a checkpoint.
+	created     time.Time
 	lastUpdated time.Time
+	// dedupDeferredSince is when the flush of the chunk was first deferred to
+	// wait for the owner replica of the stream.
+	dedupDeferredSince time.Time
+	// checksum is the checksum of the entries of the chunk, computed when it
+	// is closed for flushing if flush deduplication is enabled.
+	checksum    uint32
+	checksummed bool
 }
 
 type entryWithError struct {
@@ -139,7 +155,8 @@ func (s *stream) consumeChunk(_ context.Context, chunk *logproto.Chunk) error {
 	}
 
 	s.chunks = append(s.chunks, chunkDesc{
-		chunk: c,
+		chunk:   c,
+		created: time.Now(),
 	})
 	s.metrics.chunksCreatedTotal.Inc()
 	return nil
@@ -161,6 +178,63 @@ func (s *stream) setChunks(chunks []Chunk) (bytesAdded, entriesAdded int, err er
 	return bytesAdded, entriesAdded, nil
 }
 
+// adoptChunks takes over the chunks of the stream transferred by a leaving
+// ingester and returns how many were added. The transferred chunks are older
+// than the ones the stream may have received meanwhile, they go first and are
+// closed in that case. Otherwise the transferred head chunk keeps being
+// appended to. The entries of the transferred chunks are added to the record,
+// so that they are replayed from the WAL until the next checkpoint holds them.
+func (s *stream) adoptChunks(series *Series, record *wal.Record) (int, error) {
+	chks, err := fromWireChunks(s.cfg, s.chunkHeadBlockFormat, series.Chunks)
+	if err != nil {
+		return 0, err
+	}
+	var entries []logproto.Entry
+	if record != nil {
+		for _, c := range chks {
+			if entries, err = appendEntries(entries, c.chunk); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	s.chunkMtx.Lock()
+	defer s.chunkMtx.Unlock()
+
+	if len(s.chunks) > 0 {
+		for j := range chks {
+			chks[j].closed = true
+		}
+	} else {
+		s.lastLine.ts = series.To
+		s.lastLine.content = series.LastLine
+	}
+	if series.HighestTs.After(s.highestTs) {
+		s.highestTs = series.HighestTs
+	}
+	s.chunks = append(chks, s.chunks...)
+	if len(entries) > 0 {
+		s.entryCt += int64(len(entries))
+		record.AddEntries(uint64(s.fp), s.entryCt, entries...)
+	}
+	return len(chks), nil
+}
+
+// appendEntries appends all the entries of the chunk to entries.
+func appendEntries(entries []logproto.Entry, c *chunkenc.MemChunk) ([]logproto.Entry, error) {
+	from, through := c.Bounds()
+	it, err := c.Iterator(context.Background(), from, through.Add(time.Nanosecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.EmptyLabels()))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+	return entries, it.Error()
+}
+
 func (s *stream) NewChunk() *chunkenc.MemChunk {
 	return chunkenc.NewMemChunk(s.chunkFormat, s.cfg.parsedEncoding, s.chunkHeadBlockFormat, s.cfg.BlockSize, s.cfg.TargetChunkSize)
 }
@@ -207,7 +281,8 @@ func (s *stream) Push(
 	prevNumChunks := len(s.chunks)
 	if prevNumChunks == 0 {
 		s.chunks = append(s.chunks, chunkDesc{
-			chunk: s.NewChunk(),
+			chunk:   s.NewChunk(),
+			created: time.Now(),
 		})
 		s.metrics.chunksCreatedTotal.Inc()
 		s.metrics.chunkCreatedStats.Inc(1)
@@ -467,7 +542,8 @@ func (s *stream) cutChunk(ctx context.Context) *chunkDesc {
 	s.metrics.chunkCreatedStats.Inc(1)
 
 	s.chunks = append(s.chunks, chunkDesc{
-		chunk: s.NewChunk(),
+		chunk:   s.NewChunk(),
+		created: time.Now(),
 	})
 	return &s.chunks[len(s.chunks)-1]
 }
@@ -514,6 +590,7 @@ func (s *stream) Bounds() (from, to time.Time) {
 
 // Returns an iterator.
 func (s *stream) Iterator(ctx context.Context, statsCtx *stats.Context, from, through time.Time, direction logproto.Direction, pipeline log.StreamPipeline) (iter.EntryIterator, error) {
+	s.lastQueried.Store(time.Now().UnixNano())
 	s.chunkMtx.RLock()
 	defer s.chunkMtx.RUnlock()
 	iterators := make([]iter.EntryIterator, 0, len(s.chunks))
@@ -561,6 +638,7 @@ func (s *stream) Iterator(ctx context.Context, statsCtx *stats.Context, from, th
 
 // Returns an SampleIterator.
 func (s *stream) SampleIterator(ctx context.Context, statsCtx *stats.Context, from, through time.Time, extractor log.StreamSampleExtractor) (iter.SampleIterator, error) {
+	s.lastQueried.Store(time.Now().UnixNano())
 	s.chunkMtx.RLock()
 	defer s.chunkMtx.RUnlock()
 	iterators := make([]iter.SampleIterator, 0, len(s.chunks))
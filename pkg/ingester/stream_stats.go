@@ -0,0 +1,100 @@
+package ingester
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// GetStreamStats returns the streams of the tenant held in memory, with the
+// size of their chunks and their push rate.
+func (i *Ingester) GetStreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error) {
+	instanceID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchers []*labels.Matcher
+	if req.Matchers != "" {
+		matchers, err = syntax.ParseMatchers(req.Matchers, true)
+		if err != nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+		}
+	}
+
+	resp := &logproto.StreamStatsResponse{}
+	instance, ok := i.getInstanceByID(instanceID)
+	if !ok {
+		return resp, nil
+	}
+
+	rates := map[uint64]int64{}
+	for _, rate := range i.streamRateCalculator.Rates() {
+		if rate.Tenant == instanceID {
+			rates[rate.StreamHash] = rate.Rate
+		}
+	}
+
+	err = instance.forAllStreams(ctx, func(s *stream) error {
+		if !isMatching(s.labels, matchers) {
+			return nil
+		}
+		resp.Streams = append(resp.Streams, s.stats(rates[s.labelHash]))
+		return nil
+	})
+	return resp, err
+}
+
+func (s *stream) stats(rate int64) *logproto.StreamStats {
+	stats := &logproto.StreamStats{
+		Labels: s.labelsString,
+		Rate:   rate,
+	}
+
+	s.chunkMtx.RLock()
+	defer s.chunkMtx.RUnlock()
+	for _, c := range s.chunks {
+		stats.Entries += uint64(c.chunk.Size())
+		stats.Bytes += uint64(c.chunk.UncompressedSize())
+		if c.lastUpdated.After(stats.LastPush) {
+			stats.LastPush = c.lastUpdated
+		}
+	}
+	stats.Chunks = uint32(len(s.chunks))
+	return stats
+}
+
+// StreamsHandler lists the streams of the tenant held in memory by the
+// ingester, optionally grouped by labels.
+func (i *Ingester) StreamsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	query, err := loghttp.ParseStreamsQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	resp, err := i.GetStreamStats(r.Context(), &logproto.StreamStatsRequest{Matchers: query.Selector})
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	streams, err := query.Aggregate(resp.Streams)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, loghttp.StreamsResponse{Streams: streams})
+}
@@ -0,0 +1,68 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestStreamsHandler(t *testing.T) {
+	_, ing := newTestStore(t, defaultIngesterTestConfig(t), nil)
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	start := time.Now()
+	for i, lbs := range []string{`{app="a", pod="1"}`, `{app="a", pod="2"}`, `{app="b", pod="3"}`} {
+		stream := logproto.Stream{Labels: lbs}
+		for j := 0; j <= i; j++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+		_, err := ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+	}
+
+	resp, err := ing.GetStreamStats(ctx, &logproto.StreamStatsRequest{Matchers: `{app="b"}`})
+	require.NoError(t, err)
+	require.Len(t, resp.Streams, 1)
+	require.Equal(t, `{app="b", pod="3"}`, resp.Streams[0].Labels)
+	require.Equal(t, uint64(3), resp.Streams[0].Entries)
+	require.Equal(t, uint64(len("line 0line 1line 2")), resp.Streams[0].Bytes)
+	require.Equal(t, uint32(1), resp.Streams[0].Chunks)
+	require.False(t, resp.Streams[0].LastPush.IsZero())
+
+	// Other tenants don't see the streams.
+	resp, err = ing.GetStreamStats(user.InjectOrgID(context.Background(), "other"), &logproto.StreamStatsRequest{})
+	require.NoError(t, err)
+	require.Empty(t, resp.Streams)
+
+	req := httptest.NewRequest(http.MethodGet, "/ingester/streams?group_by=app", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	ing.StreamsHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got loghttp.StreamsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got.Streams, 2)
+	require.Equal(t, loghttp.LabelSet{"app": "a"}, got.Streams[0].Labels)
+	require.Equal(t, 2, got.Streams[0].Streams)
+	require.Equal(t, uint64(3), got.Streams[0].Entries)
+	require.Equal(t, loghttp.LabelSet{"app": "b"}, got.Streams[1].Labels)
+	require.Equal(t, 1, got.Streams[1].Streams)
+
+	req = httptest.NewRequest(http.MethodGet, "/ingester/streams?sort_by=labels", nil).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	ing.StreamsHandler(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -3,16 +3,20 @@ package ingester
 import (
 	"encoding/binary"
 	"hash/fnv"
+	"math"
 	"sync"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/prometheus/model/labels"
 	"golang.org/x/net/context"
 
 	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/logql/log"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
 	"github.com/grafana/loki/v3/pkg/util"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
@@ -20,6 +24,9 @@ import (
 const (
 	bufferSizeForTailResponse = 5
 	bufferSizeForTailStream   = 100
+	// maxPendingLinesForTail bounds the entries held while the client is slow,
+	// the entries over it are dropped and counted by stream.
+	maxPendingLinesForTail = 1000
 )
 
 type TailServer interface {
@@ -39,6 +46,15 @@ type tailer struct {
 	pipeline    syntax.Pipeline
 	pipelineMtx sync.Mutex
 
+	// sampleRatio is the share of the entries sent, 0 sends all of them.
+	sampleRatio float64
+
+	// For metric tails, the samples extracted from the entries are aggregated
+	// by liveVector, whose vector is sent every step.
+	extractor  log.SampleExtractor
+	liveVector *logql.LiveRangeVector
+	step       time.Duration
+
 	queue    chan tailRequest
 	sendChan chan *logproto.Stream
 
@@ -51,11 +67,18 @@ type tailer struct {
 	blockedMtx        sync.RWMutex
 	droppedStreams    []*logproto.DroppedStream
 	maxDroppedStreams int
+	// pendingStreams hold the entries which couldn't be sent while the client
+	// was slow, by stream. They are sent once it catches up.
+	pendingStreams map[string]*logproto.Stream
+	pendingLines   int
 
 	conn TailServer
 }
 
-func newTailer(orgID string, expr syntax.LogSelectorExpr, conn TailServer, maxDroppedStreams int) (*tailer, error) {
+// newTailer returns a tailer of a log query. The lines per second budget of the
+// tail isn't enforced here but by the querier, after merging the entries of all
+// the ingesters, so that every replica of a line is either sent or dropped.
+func newTailer(orgID string, expr syntax.LogSelectorExpr, conn TailServer, maxDroppedStreams int, sampleRatio float64) (*tailer, error) {
 	// Make sure we can build a pipeline. The stream processing code doesn't have a place to handle
 	// this error so make sure we handle it here.
 	pipeline, err := expr.Pipeline()
@@ -75,17 +98,60 @@ func newTailer(orgID string, expr syntax.LogSelectorExpr, conn TailServer, maxDr
 		id:                generateUniqueID(orgID, expr.String()),
 		closeChan:         make(chan struct{}),
 		pipeline:          pipeline,
+		sampleRatio:       sampleRatio,
+	}, nil
+}
+
+// newMetricTailer returns a tailer of a metric query. It aggregates the
+// entries it receives into the range aggregation of the query, and sends its
+// vector every step.
+func newMetricTailer(orgID string, expr syntax.SampleExpr, conn TailServer, maxDroppedStreams int, step time.Duration) (*tailer, error) {
+	rangeExpr, err := logql.MetricTailRangeAggregation(expr)
+	if err != nil {
+		return nil, err
+	}
+	extractor, err := rangeExpr.Extractor()
+	if err != nil {
+		return nil, err
+	}
+	liveVector, err := logql.NewLiveRangeVector(rangeExpr)
+	if err != nil {
+		return nil, err
+	}
+	if step <= 0 {
+		step = time.Second
+	}
+
+	return &tailer{
+		orgID:             orgID,
+		matchers:          rangeExpr.Left.Left.Matchers(),
+		sendChan:          make(chan *logproto.Stream, bufferSizeForTailResponse),
+		queue:             make(chan tailRequest, bufferSizeForTailStream),
+		conn:              conn,
+		droppedStreams:    make([]*logproto.DroppedStream, 0, maxDroppedStreams),
+		maxDroppedStreams: maxDroppedStreams,
+		id:                generateUniqueID(orgID, expr.String()),
+		closeChan:         make(chan struct{}),
+		extractor:         extractor,
+		liveVector:        liveVector,
+		step:              step,
 	}, nil
 }
 
 func (t *tailer) loop() {
 	var stream *logproto.Stream
-	var err error
 	var ok bool
 
 	// Launch a go routine to receive streams sent with t.send
 	go t.receiveStreamsLoop()
 
+	var stepC <-chan time.Time
+	if t.liveVector != nil {
+		ticker := time.NewTicker(t.step)
+		defer ticker.Stop()
+		stepC = ticker.C
+	}
+
 	for {
 		select {
 		case <-t.conn.Context().Done():
@@ -93,6 +159,10 @@ func (t *tailer) loop() {
 			return
 		case <-t.closeChan:
 			return
+		case now := <-stepC:
+			if !t.sendResponse(&logproto.TailResponse{Series: t.seriesAt(now), DroppedStreams: t.popDroppedStreams()}) {
+				return
+			}
 		case stream, ok = <-t.sendChan:
 			if !ok {
 				return
@@ -101,20 +171,49 @@ func (t *tailer) loop() {
 			}
 
 			// while sending new stream pop lined up dropped streams metadata for sending to querier
-			tailResponse := logproto.TailResponse{Stream: stream, DroppedStreams: t.popDroppedStreams()}
-			err = t.conn.Send(&tailResponse)
-			if err != nil {
-				// Don't log any error due to tail client closing the connection
-				if !util.IsConnCanceled(err) {
-					level.Error(util_log.WithContext(t.conn.Context(), util_log.Logger)).Log("msg", "Error writing to tail client", "err", err)
-				}
-				t.close()
+			if !t.sendResponse(&logproto.TailResponse{Stream: stream, DroppedStreams: t.popDroppedStreams()}) {
 				return
 			}
+			// The client caught up, send the entries held while it was slow.
+			if len(t.sendChan) == 0 {
+				for _, pending := range t.popPendingStreams() {
+					if !t.sendResponse(&logproto.TailResponse{Stream: pending}) {
+						return
+					}
+				}
+			}
 		}
 	}
 }
 
+// sendResponse sends the response to the tail client, and closes the tailer on
+// failure.
+func (t *tailer) sendResponse(resp *logproto.TailResponse) bool {
+	if err := t.conn.Send(resp); err != nil {
+		// Don't log any error due to tail client closing the connection
+		if !util.IsConnCanceled(err) {
+			level.Error(util_log.WithContext(t.conn.Context(), util_log.Logger)).Log("msg", "Error writing to tail client", "err", err)
+		}
+		t.close()
+		return false
+	}
+	return true
+}
+
+// seriesAt returns the vector of the range aggregation of a metric tail.
+func (t *tailer) seriesAt(now time.Time) []logproto.Series {
+	vec := t.liveVector.At(now.UnixNano())
+	series := make([]logproto.Series, 0, len(vec))
+	for _, s := range vec {
+		series = append(series, logproto.Series{
+			Labels:     s.Metric.String(),
+			Samples:    []logproto.Sample{{Timestamp: now.UnixNano(), Value: s.F}},
+			StreamHash: s.Metric.Hash(),
+		})
+	}
+	return series
+}
+
 func (t *tailer) receiveStreamsLoop() {
 	defer t.close()
 	for {
@@ -137,7 +236,7 @@ func (t *tailer) receiveStreamsLoop() {
 				select {
 				case t.sendChan <- s:
 				default:
-					t.dropStream(*s)
+					t.holdStream(*s)
 				}
 			}
 		}
@@ -175,8 +274,13 @@ func (t *tailer) send(stream logproto.Stream, lbs labels.Labels) {
 }
 
 func (t *tailer) processStream(stream logproto.Stream, lbs labels.Labels) []*logproto.Stream {
-	// Optimization: skip filtering entirely, if no filter is set
-	if log.IsNoopPipeline(t.pipeline) {
+	if t.liveVector != nil {
+		t.aggregateStream(stream, lbs)
+		return nil
+	}
+
+	// Optimization: skip filtering entirely, if no filter or sampling is set
+	if log.IsNoopPipeline(t.pipeline) && t.sampleRatio == 0 {
 		return []*logproto.Stream{&stream}
 	}
 
@@ -190,7 +294,11 @@ func (t *tailer) processStream(stream logproto.Stream, lbs labels.Labels) []*log
 	streams := map[uint64]*logproto.Stream{}
 
 	sp := t.pipeline.ForStream(lbs)
+	lbsHash := lbs.Hash()
 	for _, e := range stream.Entries {
+		if !isSampled(t.sampleRatio, lbsHash, e) {
+			continue
+		}
 		newLine, parsedLbs, ok := sp.ProcessString(e.Timestamp.UnixNano(), e.Line, logproto.FromLabelAdaptersToLabels(e.StructuredMetadata)...)
 		if !ok {
 			continue
@@ -216,6 +324,44 @@ func (t *tailer) processStream(stream logproto.Stream, lbs labels.Labels) []*log
 	return streamsResult
 }
 
+// aggregateStream adds the samples extracted from the entries of the stream to
+// the range aggregation of a metric tail.
+func (t *tailer) aggregateStream(stream logproto.Stream, lbs labels.Labels) {
+	// extractors are not thread safe and tailer can process multiple stream at once.
+	t.pipelineMtx.Lock()
+	defer t.pipelineMtx.Unlock()
+
+	sp := t.extractor.ForStream(lbs)
+	for _, e := range stream.Entries {
+		value, parsedLbs, ok := sp.ProcessString(e.Timestamp.UnixNano(), e.Line, logproto.FromLabelAdaptersToLabels(e.StructuredMetadata)...)
+		if !ok {
+			continue
+		}
+		sampleLbs := parsedLbs.Labels()
+		// Samples with errors fail metric queries, they are left out of tails.
+		if sampleLbs.Has(logqlmodel.ErrorLabel) && sampleLbs.Get(logqlmodel.PreserveErrorLabel) != "true" {
+			continue
+		}
+		t.liveVector.Append(sampleLbs, e.Timestamp.UnixNano(), value)
+	}
+}
+
+// isSampled returns true if the entry is part of the sample of the given
+// ratio. The decision only depends on the stream and the entry, so that every
+// ingester holding a replica of the entry takes the same one.
+func isSampled(ratio float64, lbsHash uint64, e logproto.Entry) bool {
+	if ratio <= 0 || ratio >= 1 {
+		return true
+	}
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], lbsHash)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(e.Timestamp.UnixNano()))
+	h := xxhash.New()
+	_, _ = h.Write(buf[:])
+	_, _ = h.WriteString(e.Line)
+	return float64(h.Sum64()) < ratio*math.MaxUint64
+}
+
 // isMatching returns true if lbs matches all matchers.
 func isMatching(lbs labels.Labels, matchers []*labels.Matcher) bool {
 	for _, matcher := range matchers {
@@ -267,15 +413,78 @@ func (t *tailer) dropStream(stream logproto.Stream) {
 		t.blockedAt = &blockedAt
 	}
 
+	t.recordDroppedLines(stream.Labels, stream.Entries[0].Timestamp, stream.Entries[len(stream.Entries)-1].Timestamp, len(stream.Entries))
+}
+
+// holdStream keeps the entries of a stream which couldn't be sent to the slow
+// client until it catches up. Only the entries over maxPendingLinesForTail are
+// dropped, and counted.
+func (t *tailer) holdStream(stream logproto.Stream) {
+	t.blockedMtx.Lock()
+	held := min(len(stream.Entries), maxPendingLinesForTail-t.pendingLines)
+	if held > 0 {
+		pending, ok := t.pendingStreams[stream.Labels]
+		if !ok {
+			if t.pendingStreams == nil {
+				t.pendingStreams = map[string]*logproto.Stream{}
+			}
+			pending = &logproto.Stream{Labels: stream.Labels, Hash: stream.Hash}
+			t.pendingStreams[stream.Labels] = pending
+		}
+		pending.Entries = append(pending.Entries, stream.Entries[:held]...)
+		t.pendingLines += held
+		stream.Entries = stream.Entries[held:]
+	}
+	t.blockedMtx.Unlock()
+
+	t.dropStream(stream)
+}
+
+func (t *tailer) popPendingStreams() []*logproto.Stream {
+	t.blockedMtx.Lock()
+	defer t.blockedMtx.Unlock()
+
+	if len(t.pendingStreams) == 0 {
+		return nil
+	}
+
+	pendingStreams := make([]*logproto.Stream, 0, len(t.pendingStreams))
+	for _, stream := range t.pendingStreams {
+		pendingStreams = append(pendingStreams, stream)
+	}
+	t.pendingStreams = nil
+	t.pendingLines = 0
+
+	return pendingStreams
+}
+
+// recordDroppedLines counts the lines dropped for a stream, they are reported
+// with the next response. blockedMtx must be held.
+func (t *tailer) recordDroppedLines(labels string, from, to time.Time, lines int) {
+	for _, ds := range t.droppedStreams {
+		if ds.Labels != labels {
+			continue
+		}
+		if from.Before(ds.From) {
+			ds.From = from
+		}
+		if to.After(ds.To) {
+			ds.To = to
+		}
+		ds.DroppedLines += uint64(lines)
+		return
+	}
+
 	if len(t.droppedStreams) >= t.maxDroppedStreams {
 		level.Info(util_log.Logger).Log("msg", "tailer dropped streams is reset", "length", len(t.droppedStreams))
 		t.droppedStreams = nil
 	}
 
 	t.droppedStreams = append(t.droppedStreams, &logproto.DroppedStream{
-		From:   stream.Entries[0].Timestamp,
-		To:     stream.Entries[len(stream.Entries)-1].Timestamp,
-		Labels: stream.Labels,
+		From:         from,
+		To:           to,
+		Labels:       labels,
+		DroppedLines: uint64(lines),
 	})
 }
 
@@ -283,7 +492,7 @@ func (t *tailer) popDroppedStreams() []*logproto.DroppedStream {
 	t.blockedMtx.Lock()
 	defer t.blockedMtx.Unlock()
 
-	if t.blockedAt == nil {
+	if len(t.droppedStreams) == 0 {
 		return nil
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -22,7 +23,7 @@ func TestTailer_RoundTrip(t *testing.T) {
 	lbs := makeRandomLabels()
 	expr, err := syntax.ParseLogSelector(lbs.String(), true)
 	require.NoError(t, err)
-	tail, err := newTailer("org-id", expr, server, 10)
+	tail, err := newTailer("org-id", expr, server, 10, 0)
 	require.NoError(t, err)
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -79,7 +80,7 @@ func TestTailer_sendRaceConditionOnSendWhileClosing(t *testing.T) {
 	for run := 0; run < runs; run++ {
 		expr, err := syntax.ParseLogSelector(stream.Labels, true)
 		require.NoError(t, err)
-		tailer, err := newTailer("org-id", expr, nil, 10)
+		tailer, err := newTailer("org-id", expr, nil, 10, 0)
 		require.NoError(t, err)
 		require.NotNil(t, tailer)
 
@@ -133,11 +134,12 @@ func Test_dropstream(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			expr, err := syntax.ParseLogSelector(`{app="foo"} |= "foo"`, true)
 			require.NoError(t, err)
-			tail, err := newTailer("foo", expr, &fakeTailServer{}, maxDroppedStreams)
+			tail, err := newTailer("foo", expr, &fakeTailServer{}, maxDroppedStreams, 0)
 			require.NoError(t, err)
 
 			for i := 0; i < c.drop; i++ {
 				tail.dropStream(logproto.Stream{
+					Labels: fmt.Sprintf(`{app="foo", i="%d"}`, i),
 					Entries: []logproto.Entry{
 						entry,
 					},
@@ -148,6 +150,116 @@ func Test_dropstream(t *testing.T) {
 	}
 }
 
+func Test_dropstreamCountsLines(t *testing.T) {
+	expr, err := syntax.ParseLogSelector(`{app="foo"}`, true)
+	require.NoError(t, err)
+	tail, err := newTailer("foo", expr, &fakeTailServer{}, 10, 0)
+	require.NoError(t, err)
+
+	start := time.Unix(0, 0)
+	for i := 0; i < 3; i++ {
+		tail.dropStream(logproto.Stream{
+			Labels: `{app="foo"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: start.Add(time.Duration(2*i) * time.Second), Line: "foo"},
+				{Timestamp: start.Add(time.Duration(2*i+1) * time.Second), Line: "foo"},
+			},
+		})
+	}
+
+	require.Equal(t, []*logproto.DroppedStream{{
+		From:         start,
+		To:           start.Add(5 * time.Second),
+		Labels:       `{app="foo"}`,
+		DroppedLines: 6,
+	}}, tail.popDroppedStreams())
+	require.Nil(t, tail.blockedSince())
+}
+
+func TestTailer_holdStream(t *testing.T) {
+	expr, err := syntax.ParseLogSelector(`{app="foo"}`, true)
+	require.NoError(t, err)
+	tail, err := newTailer("foo", expr, &fakeTailServer{}, 10, 0)
+	require.NoError(t, err)
+
+	start := time.Unix(0, 0)
+	stream := func(labels string, from, through int) logproto.Stream {
+		s := logproto.Stream{Labels: labels}
+		for i := from; i < through; i++ {
+			s.Entries = append(s.Entries, logproto.Entry{Timestamp: start.Add(time.Duration(i) * time.Second), Line: "foo"})
+		}
+		return s
+	}
+
+	// The entries are held while the client is slow, only the ones over the
+	// bound are dropped.
+	tail.holdStream(stream(`{app="foo", i="1"}`, 0, maxPendingLinesForTail-10))
+	require.Nil(t, tail.blockedSince())
+	tail.holdStream(stream(`{app="foo", i="2"}`, 0, 15))
+
+	require.Equal(t, []*logproto.DroppedStream{{
+		From:         start.Add(10 * time.Second),
+		To:           start.Add(14 * time.Second),
+		Labels:       `{app="foo", i="2"}`,
+		DroppedLines: 5,
+	}}, tail.popDroppedStreams())
+
+	pending := tail.popPendingStreams()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Labels < pending[j].Labels })
+	require.Len(t, pending, 2)
+	require.Len(t, pending[0].Entries, maxPendingLinesForTail-10)
+	require.Equal(t, stream(`{app="foo", i="2"}`, 0, 10), *pending[1])
+	require.Nil(t, tail.popPendingStreams())
+}
+
+func TestTailer_SampleRatio(t *testing.T) {
+	expr, err := syntax.ParseLogSelector(`{app="foo"} |= "foo"`, true)
+	require.NoError(t, err)
+
+	lbs := labels.FromStrings("app", "foo")
+	stream := logproto.Stream{Labels: lbs.String()}
+	for i := 0; i < 1000; i++ {
+		stream.Entries = append(stream.Entries, logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: fmt.Sprintf("foo %d", i)})
+	}
+
+	sample := func() []logproto.Entry {
+		tail, err := newTailer("foo", expr, &fakeTailServer{}, 10, 0.1)
+		require.NoError(t, err)
+		streams := tail.processStream(stream, lbs)
+		require.Len(t, streams, 1)
+		return streams[0].Entries
+	}
+
+	// Every ingester receiving a replica of the stream keeps the same entries.
+	sampled := sample()
+	require.InDelta(t, 100, len(sampled), 30)
+	require.Equal(t, sampled, sample())
+}
+
+func TestTailer_Pipeline(t *testing.T) {
+	expr, err := syntax.ParseLogSelector(`{app="foo"} | logfmt | level="error"`, true)
+	require.NoError(t, err)
+	tail, err := newTailer("foo", expr, &fakeTailServer{}, 10, 0)
+	require.NoError(t, err)
+
+	lbs := labels.FromStrings("app", "foo")
+	stream := logproto.Stream{Labels: lbs.String()}
+	for i := 0; i < 20; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "error"
+		}
+		stream.Entries = append(stream.Entries, logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: "level=" + level})
+	}
+
+	// Every line matching the pipeline is sent, the lines per second budget
+	// is enforced by the querier.
+	streams := tail.processStream(stream, lbs)
+	require.Len(t, streams, 1)
+	require.Len(t, streams[0].Entries, 10)
+	require.Empty(t, tail.popDroppedStreams())
+}
+
 type fakeTailServer struct {
 	responses   []logproto.TailResponse
 	responsesMu sync.Mutex
@@ -201,7 +313,7 @@ func (f *fakeTailServer) Reset() {
 func Test_TailerSendRace(t *testing.T) {
 	expr, err := syntax.ParseLogSelector(`{app="foo"} |= "foo"`, true)
 	require.NoError(t, err)
-	tail, err := newTailer("foo", expr, &fakeTailServer{}, 10)
+	tail, err := newTailer("foo", expr, &fakeTailServer{}, 10, 0)
 	require.NoError(t, err)
 
 	var wg sync.WaitGroup
@@ -339,7 +451,7 @@ func Test_StructuredMetadata(t *testing.T) {
 			var server fakeTailServer
 			expr, err := syntax.ParseLogSelector(tc.query, true)
 			require.NoError(t, err)
-			tail, err := newTailer("foo", expr, &server, 10)
+			tail, err := newTailer("foo", expr, &server, 10, 0)
 			require.NoError(t, err)
 
 			var wg sync.WaitGroup
@@ -364,3 +476,36 @@ func Test_StructuredMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricTailer(t *testing.T) {
+	expr, err := syntax.ParseSampleExpr(`sum by (status) (count_over_time({app="foo"} | logfmt | status=~"[45].." [10s]))`)
+	require.NoError(t, err)
+	tail, err := newMetricTailer("org-id", expr, &fakeTailServer{}, 10, time.Second)
+	require.NoError(t, err)
+	require.True(t, isMatching(labels.FromStrings("app", "foo", "pod", "a"), tail.matchers))
+
+	now := time.Now()
+	lbs := labels.FromStrings("app", "foo", "pod", "a")
+	stream := logproto.Stream{Labels: lbs.String()}
+	for i := 0; i < 30; i++ {
+		stream.Entries = append(stream.Entries, logproto.Entry{
+			Timestamp: now.Add(-time.Duration(i) * time.Second),
+			Line:      fmt.Sprintf("status=%d", 200+100*(i%4)),
+		})
+	}
+	require.Empty(t, tail.processStream(stream, lbs))
+
+	// The range aggregation is computed by the ingester, the vector
+	// aggregation is left to the querier.
+	series := tail.seriesAt(now)
+	require.Len(t, series, 2)
+	for _, s := range series {
+		require.Len(t, s.Samples, 1)
+		require.Equal(t, now.UnixNano(), s.Samples[0].Timestamp)
+		require.Equal(t, 2.0, s.Samples[0].Value, s.Labels)
+	}
+	require.ElementsMatch(t, []string{
+		`{app="foo", pod="a", status="400"}`,
+		`{app="foo", pod="a", status="500"}`,
+	}, []string{series[0].Labels, series[1].Labels})
+}
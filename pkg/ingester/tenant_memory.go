@@ -0,0 +1,296 @@
+package ingester
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+const (
+	memoryTypeChunks     = "chunks"
+	memoryTypeHeadBlocks = "head_blocks"
+	memoryTypeIndex      = "index"
+
+	// minTenantMemoryCheckInterval is the minimum time between two checks
+	// triggered by rejected pushes, each one measures every tenant.
+	minTenantMemoryCheckInterval = time.Second
+)
+
+// MemoryUsage is the size of the data an ingester holds in memory for a
+// tenant.
+type MemoryUsage struct {
+	// Chunks is the compressed size of the chunk blocks held in memory.
+	Chunks int `json:"chunks"`
+	// HeadBlocks is the uncompressed size of the head blocks.
+	HeadBlocks int `json:"head_blocks"`
+	// Index is an estimate of the size of the index, based on the size of the
+	// labels of the streams.
+	Index int `json:"index"`
+}
+
+func (u MemoryUsage) Total() int {
+	return u.Chunks + u.HeadBlocks + u.Index
+}
+
+// measureMemory measures the memory held by the instance and resets the bytes
+// pushed since the last measurement.
+func (i *instance) measureMemory() MemoryUsage {
+	var usage MemoryUsage
+	_ = i.streams.ForEach(func(s *stream) (bool, error) {
+		usage.Index += len(s.labelsString)
+
+		s.chunkMtx.RLock()
+		defer s.chunkMtx.RUnlock()
+		for _, c := range s.chunks {
+			usage.Chunks += c.chunk.ResidentBlocksSize()
+			usage.HeadBlocks += c.chunk.HeadBlockSize()
+		}
+		return true, nil
+	})
+
+	i.memoryMtx.Lock()
+	defer i.memoryMtx.Unlock()
+	i.memoryUsage = usage
+	i.memoryPushed = 0
+	return usage
+}
+
+// checkMemoryLimit rejects the push if the instance holds more data in memory
+// than allowed, and asks the ingester to flush its oldest chunks. The bytes
+// pushed since the last measurement are counted uncompressed.
+func (i *instance) checkMemoryLimit(req *logproto.PushRequest) error {
+	limit := i.limiter.MaxMemoryBytes(i.instanceID)
+	if limit <= 0 {
+		return nil
+	}
+
+	i.memoryMtx.Lock()
+	held := i.memoryUsage.Total() + i.memoryPushed
+	if held <= limit {
+		for _, s := range req.Streams {
+			for _, e := range s.Entries {
+				i.memoryPushed += len(e.Line)
+			}
+		}
+		i.memoryMtx.Unlock()
+		return nil
+	}
+	i.memoryMtx.Unlock()
+
+	if i.memoryLimitExceeded != nil {
+		i.memoryLimitExceeded()
+	}
+
+	entries, bytes := 0, 0
+	for _, s := range req.Streams {
+		entries += len(s.Entries)
+		for _, e := range s.Entries {
+			bytes += len(e.Line)
+		}
+	}
+	validation.DiscardedSamples.WithLabelValues(validation.TenantMemoryLimit, i.instanceID).Add(float64(entries))
+	validation.DiscardedBytes.WithLabelValues(validation.TenantMemoryLimit, i.instanceID).Add(float64(bytes))
+	return httpgrpc.Errorf(http.StatusTooManyRequests, validation.TenantMemoryLimitErrorMsg, i.instanceID, held, limit)
+}
+
+// triggerTenantMemoryCheck makes the tenant memory loop check the tenants
+// right away.
+func (i *Ingester) triggerTenantMemoryCheck() {
+	select {
+	case i.tenantMemoryTrigger <- struct{}{}:
+	default:
+	}
+}
+
+func (i *Ingester) tenantMemoryLoop() {
+	defer i.loopDone.Done()
+
+	var tick <-chan time.Time
+	if i.cfg.TenantMemoryCheckPeriod > 0 {
+		ticker := time.NewTicker(i.cfg.TenantMemoryCheckPeriod)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var (
+		lastCheck time.Time
+		// deferred fires once the checks triggered too soon after the last
+		// one can run, they are coalesced into a single one.
+		deferred <-chan time.Time
+	)
+	check := func() {
+		i.checkTenantMemory()
+		lastCheck = time.Now()
+		deferred = nil
+	}
+	for {
+		select {
+		case <-tick:
+			check()
+		case <-i.tenantMemoryTrigger:
+			if wait := minTenantMemoryCheckInterval - time.Since(lastCheck); wait > 0 {
+				if deferred == nil {
+					deferred = time.After(wait)
+				}
+				continue
+			}
+			check()
+		case <-deferred:
+			check()
+		case <-i.loopQuit:
+			return
+		}
+	}
+}
+
+// checkTenantMemory measures the memory held by every tenant. The oldest
+// chunks of the tenants above their limit are flushed, until enough data is
+// being flushed to go back under the limit.
+func (i *Ingester) checkTenantMemory() {
+	for _, inst := range i.getInstances() {
+		limit := i.limiter.MaxMemoryBytes(inst.instanceID)
+		if limit > 0 {
+			// Drop the flushed chunks past their retain period first, rather
+			// than on the next flush sweep. The chunks still retained count
+			// against the limit.
+			_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+				i.removeFlushedChunks(inst, s, false)
+				return true, nil
+			})
+		}
+
+		usage := inst.measureMemory()
+		if inst.streams.Len() == 0 {
+			// The tenant has no data on this ingester anymore.
+			for _, typ := range []string{memoryTypeChunks, memoryTypeHeadBlocks, memoryTypeIndex} {
+				i.metrics.tenantMemoryBytes.DeleteLabelValues(inst.instanceID, typ)
+			}
+			continue
+		}
+		i.metrics.tenantMemoryBytes.WithLabelValues(inst.instanceID, memoryTypeChunks).Set(float64(usage.Chunks))
+		i.metrics.tenantMemoryBytes.WithLabelValues(inst.instanceID, memoryTypeHeadBlocks).Set(float64(usage.HeadBlocks))
+		i.metrics.tenantMemoryBytes.WithLabelValues(inst.instanceID, memoryTypeIndex).Set(float64(usage.Index))
+
+		if limit > 0 && usage.Total() > limit {
+			i.flushOldestChunks(inst, usage.Total()-limit)
+		}
+	}
+}
+
+type memoryFlushCandidate struct {
+	stream *stream
+	desc   *chunkDesc
+	from   time.Time
+	size   int
+}
+
+// flushOldestChunks closes and flushes the oldest chunks of the instance not
+// flushed yet, until their size adds up to the given number of bytes.
+func (i *Ingester) flushOldestChunks(inst *instance, bytes int) {
+	var candidates []memoryFlushCandidate
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		s.chunkMtx.RLock()
+		defer s.chunkMtx.RUnlock()
+		for j := range s.chunks {
+			c := &s.chunks[j]
+			if !c.flushed.IsZero() {
+				continue
+			}
+			from, _ := c.chunk.Bounds()
+			candidates = append(candidates, memoryFlushCandidate{
+				stream: s,
+				desc:   c,
+				from:   from,
+				size:   c.chunk.ResidentBlocksSize() + c.chunk.HeadBlockSize(),
+			})
+		}
+		return true, nil
+	})
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].from.Before(candidates[b].from)
+	})
+
+	flushed := 0
+	enqueued := map[*stream]struct{}{}
+	for _, c := range candidates {
+		if flushed >= bytes {
+			break
+		}
+		if !i.closeChunkForMemoryLimit(c.stream, c.desc) {
+			continue
+		}
+		flushed += c.size
+
+		if _, ok := enqueued[c.stream]; ok {
+			continue
+		}
+		enqueued[c.stream] = struct{}{}
+		flushQueueIndex := int(uint64(c.stream.fp) % uint64(i.cfg.ConcurrentFlushes))
+		i.flushQueues[flushQueueIndex].Enqueue(&flushOp{
+			model.TimeFromUnixNano(c.from.UnixNano()), inst.instanceID,
+			c.stream.fp, false,
+		})
+	}
+	level.Info(i.logger).Log("msg", "flushing oldest chunks of tenant above its memory limit", "user", inst.instanceID, "bytes", flushed, "streams", len(enqueued))
+}
+
+// closeChunkForMemoryLimit closes a chunk so that it is flushed, unless it was
+// flushed or dropped meanwhile.
+func (i *Ingester) closeChunkForMemoryLimit(s *stream, desc *chunkDesc) bool {
+	s.chunkMtx.Lock()
+	defer s.chunkMtx.Unlock()
+
+	for j := range s.chunks {
+		if &s.chunks[j] != desc {
+			continue
+		}
+		if !desc.flushed.IsZero() {
+			return false
+		}
+		if !desc.closed {
+			desc.closed = true
+			desc.reason = flushReasonMemoryLimit
+		}
+		return true
+	}
+	return false
+}
+
+// TenantMemoryHandler returns the memory held by every tenant as of the last
+// check, along with their limit.
+func (i *Ingester) TenantMemoryHandler(w http.ResponseWriter, _ *http.Request) {
+	type tenantMemory struct {
+		Tenant string `json:"tenant"`
+		MemoryUsage
+		Total int `json:"total"`
+		Limit int `json:"limit"`
+	}
+
+	tenants := []tenantMemory{}
+	for _, inst := range i.getInstances() {
+		inst.memoryMtx.Lock()
+		usage := inst.memoryUsage
+		inst.memoryMtx.Unlock()
+
+		tenants = append(tenants, tenantMemory{
+			Tenant:      inst.instanceID,
+			MemoryUsage: usage,
+			Total:       usage.Total(),
+			Limit:       i.limiter.MaxMemoryBytes(inst.instanceID),
+		})
+	}
+	sort.Slice(tenants, func(a, b int) bool {
+		return tenants[a].Total > tenants[b].Total
+	})
+	util.WriteJSONResponse(w, struct {
+		Tenants []tenantMemory `json:"tenants"`
+	}{tenants})
+}
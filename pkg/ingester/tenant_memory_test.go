@@ -0,0 +1,115 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/runtime"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func TestTenantMemoryLimit(t *testing.T) {
+	limits := defaultLimitsTestConfig()
+	limits.MaxMemoryBytesPerUser = 100
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	cfg := defaultIngesterTestConfig(t)
+	cfg.TenantMemoryCheckPeriod = 99999 * time.Hour
+	store := &testStore{chunks: map[string][]chunk.Chunk{}}
+	ing, err := New(cfg, client.Config{}, store, overrides, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokitlog.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), ing)
+	})
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	start := time.Now()
+	push := func(from int) error {
+		stream := logproto.Stream{Labels: `{foo="bar"}`}
+		for j := from; j < from+10; j++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+		_, err := ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		return err
+	}
+
+	// The tenant goes above its limit before the next measurement.
+	require.NoError(t, push(0))
+	require.NoError(t, push(10))
+
+	// The next push is rejected and the oldest chunks of the tenant are flushed.
+	err = push(20)
+	require.Error(t, err)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, http.StatusTooManyRequests, int(resp.Code))
+	require.Contains(t, string(resp.Body), "Maximum in-memory bytes per tenant exceeded")
+
+	require.Eventually(t, func() bool {
+		return len(store.getChunksForUser("test")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1.0, testutil.ToFloat64(ing.metrics.chunksFlushedPerReason.WithLabelValues(flushReasonMemoryLimit)))
+
+	// Once the flushed chunks are dropped, the tenant is back under its limit.
+	ing.checkTenantMemory()
+	require.NoError(t, push(20))
+
+	rec := httptest.NewRecorder()
+	ing.TenantMemoryHandler(rec, httptest.NewRequest(http.MethodGet, "/ingester/tenant_memory", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got struct {
+		Tenants []struct {
+			Tenant string `json:"tenant"`
+			MemoryUsage
+			Total int `json:"total"`
+			Limit int `json:"limit"`
+		} `json:"tenants"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got.Tenants, 1)
+	require.Equal(t, "test", got.Tenants[0].Tenant)
+	require.Equal(t, 0, got.Tenants[0].Chunks)
+	require.Equal(t, 0, got.Tenants[0].HeadBlocks)
+	require.Equal(t, len(`{foo="bar"}`), got.Tenants[0].Index)
+	require.Equal(t, got.Tenants[0].Index, got.Tenants[0].Total)
+	require.Equal(t, 100, got.Tenants[0].Limit)
+
+	// The series of the tenant are deleted once it holds no data anymore.
+	require.Equal(t, 3, testutil.CollectAndCount(ing.metrics.tenantMemoryBytes))
+	inst, ok := ing.getInstanceByID("test")
+	require.True(t, ok)
+	var streams []*stream
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		streams = append(streams, s)
+		return true, nil
+	})
+	inst.streams.WithLock(func() {
+		for _, s := range streams {
+			inst.removeStream(s)
+		}
+	})
+	ing.checkTenantMemory()
+	require.Equal(t, 0, testutil.CollectAndCount(ing.metrics.tenantMemoryBytes))
+}
@@ -1,6 +1,7 @@
 package ingester
 
 import (
+	"context"
 	"flag"
 	"sync"
 	"time"
@@ -9,8 +10,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/tsdb/wlog"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/v3/pkg/ingester/wal"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/util/flagext"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
@@ -29,12 +33,28 @@ type WALConfig struct {
 	CheckpointDuration  time.Duration    `yaml:"checkpoint_duration"`
 	FlushOnShutdown     bool             `yaml:"flush_on_shutdown"`
 	ReplayMemoryCeiling flagext.ByteSize `yaml:"replay_memory_ceiling"`
+
+	RemoteStore          string        `yaml:"remote_store"`
+	RemoteStoreKeyPrefix string        `yaml:"remote_store_key_prefix"`
+	RemoteSyncPeriod     time.Duration `yaml:"remote_sync_period"`
+	RemoteRestoreFrom    string        `yaml:"remote_restore_from"`
+
+	// RemoteClient is the client of the remote store, set when RemoteStore is.
+	RemoteClient client.ObjectClient `yaml:"-"`
 }
 
 func (cfg *WALConfig) Validate() error {
 	if cfg.Enabled && cfg.CheckpointDuration < 1 {
 		return errors.Errorf("invalid checkpoint duration: %v", cfg.CheckpointDuration)
 	}
+	if cfg.Enabled && cfg.RemoteStore != "" {
+		if cfg.RemoteSyncPeriod <= 0 {
+			return errors.Errorf("invalid WAL remote sync period: %v", cfg.RemoteSyncPeriod)
+		}
+		if err := config.ValidatePathPrefix(cfg.RemoteStoreKeyPrefix); err != nil {
+			return errors.Wrap(err, "validate WAL remote store key prefix")
+		}
+	}
 	return nil
 }
 
@@ -48,6 +68,11 @@ func (cfg *WALConfig) RegisterFlags(f *flag.FlagSet) {
 	// Need to set default here
 	cfg.ReplayMemoryCeiling = flagext.ByteSize(defaultCeiling)
 	f.Var(&cfg.ReplayMemoryCeiling, "ingester.wal-replay-memory-ceiling", "Maximum memory size the WAL may use during replay. After hitting this, it will flush data to storage before continuing. A unit suffix (KB, MB, GB) may be applied.")
+
+	f.StringVar(&cfg.RemoteStore, "ingester.wal-remote-store", "", "Experimental: Object store the WAL segments and checkpoints are continuously uploaded to, so that an ingester replacing this one with the same ID, and therefore the same ring tokens, can replay them without the local disk. Empty to keep the WAL on the local disk only.")
+	f.StringVar(&cfg.RemoteStoreKeyPrefix, "ingester.wal-remote-store.key-prefix", "wal/", "Path prefix of the WAL in the remote store. The WAL of each ingester is stored under its ID.")
+	f.DurationVar(&cfg.RemoteSyncPeriod, "ingester.wal-remote-store.sync-period", time.Minute, "Interval at which the WAL is synced to the remote store. The segment being written is closed and uploaded at every sync, so data written since the last sync can be lost if the ingester loses its disk.")
+	f.StringVar(&cfg.RemoteRestoreFrom, "ingester.wal-remote-store.restore-from", "", "ID of the ingester whose WAL is restored from the remote store when the local WAL is empty, for an ingester replacing one with a different ID. Its remote WAL is deleted once the restored WAL is uploaded under the ID of this ingester. Defaults to the ID of this ingester.")
 }
 
 // WAL interface allows us to have a no-op WAL when the WAL is disabled.
@@ -70,13 +95,17 @@ type walWrapper struct {
 	wal        *wlog.WL
 	metrics    *ingesterMetrics
 	seriesIter SeriesIter
+	// shipper uploads the WAL to the remote store, nil if there is none.
+	shipper *walShipper
+	// logged tells whether records were logged since the last remote sync.
+	logged atomic.Bool
 
 	wait sync.WaitGroup
 	quit chan struct{}
 }
 
 // newWAL creates a WAL object. If the WAL is disabled, then the returned WAL is a no-op WAL.
-func newWAL(cfg WALConfig, registerer prometheus.Registerer, metrics *ingesterMetrics, seriesIter SeriesIter) (WAL, error) {
+func newWAL(cfg WALConfig, registerer prometheus.Registerer, metrics *ingesterMetrics, seriesIter SeriesIter, shipper *walShipper) (WAL, error) {
 	if !cfg.Enabled {
 		return noopWAL{}, nil
 	}
@@ -92,6 +121,7 @@ func newWAL(cfg WALConfig, registerer prometheus.Registerer, metrics *ingesterMe
 		wal:        tsdbWAL,
 		metrics:    metrics,
 		seriesIter: seriesIter,
+		shipper:    shipper,
 	}
 
 	return w, nil
@@ -100,6 +130,11 @@ func newWAL(cfg WALConfig, registerer prometheus.Registerer, metrics *ingesterMe
 func (w *walWrapper) Start() {
 	w.wait.Add(1)
 	go w.run()
+
+	if w.shipper != nil {
+		w.wait.Add(1)
+		go w.runShipper()
+	}
 }
 
 func (w *walWrapper) Log(record *wal.Record) error {
@@ -133,6 +168,7 @@ func (w *walWrapper) Log(record *wal.Record) error {
 			w.metrics.walRecordsLogged.Inc()
 			w.metrics.walLoggedBytesTotal.Add(float64(len(*buf)))
 		}
+		w.logged.Store(true)
 		return nil
 	}
 }
@@ -141,6 +177,14 @@ func (w *walWrapper) Stop() error {
 	close(w.quit)
 	w.wait.Wait()
 	err := w.wal.Close()
+	// Ship what was written since the last sync, the last segment is closed
+	// along with the WAL.
+	if w.shipper != nil {
+		if err := w.shipper.sync(context.Background(), -1); err != nil {
+			w.metrics.walUploadFailures.Inc()
+			level.Error(util_log.Logger).Log("msg", "failed to sync WAL to the remote store", "err", err)
+		}
+	}
 	level.Info(util_log.Logger).Log("msg", "stopped", "component", "wal")
 	return err
 }
@@ -166,3 +210,40 @@ func (w *walWrapper) run() {
 	checkpointer.Run()
 
 }
+
+func (w *walWrapper) runShipper() {
+	defer w.wait.Done()
+
+	ticker := time.NewTicker(w.cfg.RemoteSyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.syncRemote()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// syncRemote closes the segment being written if records were logged to it,
+// so that the shipper uploads every segment once, when it is complete.
+func (w *walWrapper) syncRemote() {
+	var (
+		active int
+		err    error
+	)
+	if w.logged.Swap(false) {
+		active, err = w.wal.NextSegmentSync()
+	} else {
+		active, _, err = w.wal.LastSegmentAndOffset()
+	}
+	if err == nil {
+		err = w.shipper.sync(context.Background(), active)
+	}
+	if err != nil {
+		w.metrics.walUploadFailures.Inc()
+		level.Error(util_log.Logger).Log("msg", "failed to sync WAL to the remote store", "err", err)
+	}
+}
@@ -0,0 +1,222 @@
+package ingester
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+)
+
+// walShipper mirrors the local WAL directory, closed segments and finished
+// checkpoints, to the remote store under the ID of the ingester. It lets an
+// ingester that lost its disk replay the WAL of the ingester it replaces.
+type walShipper struct {
+	dir     string
+	prefix  string
+	client  client.ObjectClient
+	metrics *ingesterMetrics
+	logger  log.Logger
+
+	// restorePrefix is where the WAL is restored from when the local WAL is
+	// empty. It is the prefix of the predecessor of the ingester, if any.
+	restorePrefix string
+	// restoredPredecessor is set once the WAL of the predecessor is restored,
+	// until it is uploaded under the ID of the ingester and deleted.
+	restoredPredecessor bool
+
+	// uploaded is the size of the files in the remote store, by path relative
+	// to the WAL directory.
+	uploaded map[string]int64
+}
+
+func newWALShipper(cfg WALConfig, instanceID string, metrics *ingesterMetrics, logger log.Logger) *walShipper {
+	restoreFrom := instanceID
+	if cfg.RemoteRestoreFrom != "" {
+		restoreFrom = cfg.RemoteRestoreFrom
+	}
+	return &walShipper{
+		dir:           cfg.Dir,
+		prefix:        cfg.RemoteStoreKeyPrefix + instanceID + "/",
+		restorePrefix: cfg.RemoteStoreKeyPrefix + restoreFrom + "/",
+		client:        cfg.RemoteClient,
+		metrics:       metrics,
+		logger:        log.With(logger, "component", "wal-shipper"),
+		uploaded:      map[string]int64{},
+	}
+}
+
+// init loads the list of files of the remote WAL, and downloads the WAL of
+// the ingester, or of its predecessor, when the local WAL directory is empty,
+// as it is on a new disk. It must be called before the local WAL is opened.
+func (s *walShipper) init(ctx context.Context) error {
+	objects, _, err := s.client.List(ctx, s.prefix, "")
+	if err != nil {
+		return errors.Wrap(err, "list remote WAL")
+	}
+	for _, o := range objects {
+		s.uploaded[strings.TrimPrefix(o.Key, s.prefix)] = -1
+	}
+
+	local, err := s.localFiles()
+	if err != nil {
+		return err
+	}
+	if len(local) > 0 {
+		return nil
+	}
+
+	if s.restorePrefix != s.prefix {
+		if objects, _, err = s.client.List(ctx, s.restorePrefix, ""); err != nil {
+			return errors.Wrap(err, "list remote WAL of the predecessor")
+		}
+		s.restoredPredecessor = len(objects) > 0
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	level.Info(s.logger).Log("msg", "local WAL is empty, restoring it from the remote store", "prefix", s.restorePrefix, "files", len(objects))
+	for _, o := range objects {
+		rel := strings.TrimPrefix(o.Key, s.restorePrefix)
+		n, err := s.download(ctx, o.Key, filepath.Join(s.dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return errors.Wrapf(err, "restore WAL file %s", rel)
+		}
+		if !s.restoredPredecessor {
+			s.uploaded[rel] = n
+		}
+		s.metrics.walRestoredBytesTotal.Add(float64(n))
+	}
+	return nil
+}
+
+func (s *walShipper) download(ctx context.Context, key, dst string) (int64, error) {
+	r, _, err := s.client.GetObject(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	return n, f.Close()
+}
+
+// sync uploads the local files that are new since the last sync, then deletes
+// the remote files that were removed locally by checkpointing. The segments
+// from active onwards are still being written and are skipped, -1 uploads all
+// of them once the WAL is closed.
+func (s *walShipper) sync(ctx context.Context, active int) error {
+	local, err := s.localFiles()
+	if err != nil {
+		return err
+	}
+
+	for rel, size := range local {
+		if s.uploaded[rel] == size {
+			continue
+		}
+		if segment, err := strconv.Atoi(rel); err == nil && active >= 0 && segment >= active {
+			continue
+		}
+		if err := s.upload(ctx, rel, size); err != nil {
+			return errors.Wrapf(err, "upload WAL file %s", rel)
+		}
+		s.uploaded[rel] = size
+		s.metrics.walUploadedBytesTotal.Add(float64(size))
+	}
+
+	// Files are only deleted once everything newer is uploaded, so that the
+	// remote WAL can always be replayed.
+	for rel := range s.uploaded {
+		if _, ok := local[rel]; ok {
+			continue
+		}
+		if err := s.client.DeleteObject(ctx, s.prefix+rel); err != nil && !s.client.IsObjectNotFoundErr(err) {
+			return errors.Wrapf(err, "delete remote WAL file %s", rel)
+		}
+		delete(s.uploaded, rel)
+	}
+
+	// The WAL restored from the predecessor is now uploaded under the ID of
+	// this ingester.
+	if s.restoredPredecessor {
+		objects, _, err := s.client.List(ctx, s.restorePrefix, "")
+		if err != nil {
+			return errors.Wrap(err, "list remote WAL of the predecessor")
+		}
+		for _, o := range objects {
+			if err := s.client.DeleteObject(ctx, o.Key); err != nil && !s.client.IsObjectNotFoundErr(err) {
+				return errors.Wrapf(err, "delete remote WAL file %s of the predecessor", o.Key)
+			}
+		}
+		s.restoredPredecessor = false
+	}
+	return nil
+}
+
+func (s *walShipper) upload(ctx context.Context, rel string, size int64) error {
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.client.PutObject(ctx, s.prefix+rel, io.NewSectionReader(f, 0, size))
+}
+
+// localFiles returns the size of the WAL segments and finished checkpoints,
+// by path relative to the WAL directory.
+func (s *walShipper) localFiles() (map[string]int64, error) {
+	files := map[string]int64{}
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			// Checkpoints being written are skipped, they are uploaded once
+			// renamed to their final name.
+			if strings.HasSuffix(d.Name(), ".tmp") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		files[path.Clean(filepath.ToSlash(rel))] = info.Size()
+		return nil
+	})
+	return files, err
+}
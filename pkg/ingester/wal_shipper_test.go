@@ -0,0 +1,178 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gokit_log "github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/runtime"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func TestWALShipper(t *testing.T) {
+	ctx := context.Background()
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	cfg := WALConfig{Dir: t.TempDir(), RemoteStoreKeyPrefix: "wal/", RemoteClient: objectClient}
+	write := func(dir, name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write(cfg.Dir, "00000000", "segment 0")
+	write(cfg.Dir, "00000001", "segment 1")
+	write(cfg.Dir, "checkpoint.000000.tmp/00000000", "checkpoint being written")
+
+	s := newWALShipper(cfg, "ingester-1", newIngesterMetrics(nil, constants.Loki), gokit_log.NewNopLogger())
+	require.NoError(t, s.init(ctx))
+	require.NoError(t, s.sync(ctx, 1))
+
+	list := func(prefix string) []string {
+		objects, _, err := objectClient.List(ctx, prefix, "")
+		require.NoError(t, err)
+		var keys []string
+		for _, o := range objects {
+			keys = append(keys, o.Key)
+		}
+		return keys
+	}
+	// The segment being written isn't uploaded.
+	require.ElementsMatch(t, []string{"wal/ingester-1/00000000"}, list("wal/ingester-1/"))
+
+	// A checkpoint is finished, the segments it covers are deleted and the
+	// last one is closed.
+	require.NoError(t, os.Rename(filepath.Join(cfg.Dir, "checkpoint.000000.tmp"), filepath.Join(cfg.Dir, "checkpoint.000000")))
+	require.NoError(t, os.Remove(filepath.Join(cfg.Dir, "00000000")))
+	write(cfg.Dir, "00000001", "segment 1 with more records")
+	write(cfg.Dir, "00000002", "segment 2")
+	require.NoError(t, s.sync(ctx, 2))
+	require.ElementsMatch(t, []string{"wal/ingester-1/00000001", "wal/ingester-1/checkpoint.000000/00000000"}, list("wal/ingester-1/"))
+	uploaded := testutil.ToFloat64(s.metrics.walUploadedBytesTotal)
+
+	// Closed segments are uploaded once.
+	write(cfg.Dir, "00000002", "segment 2 with more records")
+	require.NoError(t, s.sync(ctx, 2))
+	require.Equal(t, uploaded, testutil.ToFloat64(s.metrics.walUploadedBytesTotal))
+
+	// An ingester with the same ID and an empty disk restores the WAL.
+	restored := cfg
+	restored.Dir = t.TempDir()
+	s = newWALShipper(restored, "ingester-1", newIngesterMetrics(nil, constants.Loki), gokit_log.NewNopLogger())
+	require.NoError(t, s.init(ctx))
+	content, err := os.ReadFile(filepath.Join(restored.Dir, "00000001"))
+	require.NoError(t, err)
+	require.Equal(t, "segment 1 with more records", string(content))
+	content, err = os.ReadFile(filepath.Join(restored.Dir, "checkpoint.000000", "00000000"))
+	require.NoError(t, err)
+	require.Equal(t, "checkpoint being written", string(content))
+
+	// Nothing is uploaded again as long as nothing changed.
+	require.NoError(t, s.sync(ctx, 2))
+	require.Zero(t, testutil.ToFloat64(s.metrics.walUploadedBytesTotal))
+
+	// An ingester with another ID restores the WAL of the ingester it
+	// replaces, which is deleted once uploaded under the new ID.
+	replacement := cfg
+	replacement.Dir = t.TempDir()
+	replacement.RemoteRestoreFrom = "ingester-1"
+	s = newWALShipper(replacement, "ingester-2", newIngesterMetrics(nil, constants.Loki), gokit_log.NewNopLogger())
+	require.NoError(t, s.init(ctx))
+	content, err = os.ReadFile(filepath.Join(replacement.Dir, "00000001"))
+	require.NoError(t, err)
+	require.Equal(t, "segment 1 with more records", string(content))
+	require.NoError(t, s.sync(ctx, 2))
+	require.ElementsMatch(t, []string{"wal/ingester-2/00000001", "wal/ingester-2/checkpoint.000000/00000000"}, list("wal/ingester-2/"))
+	require.Empty(t, list("wal/ingester-1/"))
+}
+
+func TestIngesterWALRemoteStore(t *testing.T) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	ingesterConfig := defaultIngesterTestConfigWithWAL(t, t.TempDir())
+	ingesterConfig.WAL.RemoteStore = "filesystem"
+	ingesterConfig.WAL.RemoteStoreKeyPrefix = "wal/"
+	ingesterConfig.WAL.RemoteSyncPeriod = time.Hour
+	ingesterConfig.WAL.RemoteClient = objectClient
+
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	newStore := func() *mockStore {
+		return &mockStore{
+			chunks: map[string][]chunk.Chunk{},
+		}
+	}
+
+	i, err := New(ingesterConfig, client.Config{}, newStore(), limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokit_log.NewNopLogger())
+	require.NoError(t, err)
+	require.Nil(t, services.StartAndAwaitRunning(context.Background(), i))
+
+	req := logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{
+				Labels: `{foo="bar",bar="baz1"}`,
+			},
+			{
+				Labels: `{foo="bar",bar="baz2"}`,
+			},
+		},
+	}
+
+	start := time.Now()
+	steps := 10
+	end := start.Add(time.Second * time.Duration(steps))
+
+	for i := 0; i < steps; i++ {
+		req.Streams[0].Entries = append(req.Streams[0].Entries, logproto.Entry{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Line:      fmt.Sprintf("line %d", i),
+		})
+		req.Streams[1].Entries = append(req.Streams[1].Entries, logproto.Entry{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Line:      fmt.Sprintf("line %d", i),
+		})
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = i.Push(ctx, &req)
+	require.NoError(t, err)
+
+	require.Nil(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+	// replace the ingester with one which lost the local WAL
+	ingesterConfig.WAL.Dir = t.TempDir()
+	i, err = New(ingesterConfig, client.Config{}, newStore(), limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokit_log.NewNopLogger())
+	require.NoError(t, err)
+	require.Nil(t, services.StartAndAwaitRunning(context.Background(), i))
+
+	// ensure we've recovered data from the remote wal segments
+	ensureIngesterData(ctx, t, start, end, i)
+	require.Nil(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+	// replace the ingester with one with another ID naming it as predecessor
+	ingesterConfig.WAL.RemoteRestoreFrom = ingesterConfig.LifecyclerConfig.ID
+	ingesterConfig.LifecyclerConfig.ID = "replacement"
+	ingesterConfig.WAL.Dir = t.TempDir()
+	i, err = New(ingesterConfig, client.Config{}, newStore(), limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokit_log.NewNopLogger())
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+	require.Nil(t, services.StartAndAwaitRunning(context.Background(), i))
+
+	ensureIngesterData(ctx, t, start, end, i)
+}
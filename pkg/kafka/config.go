@@ -0,0 +1,94 @@
+package kafka
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/grafana/dskit/flagext"
+)
+
+// Config configures the optional Kafka write path between distributors and
+// ingesters. When enabled, distributors write validated streams to the topic
+// and ingesters consume the partitions they own instead of receiving pushes.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	Address  flagext.StringSliceCSV `yaml:"address"`
+	Topic    string                 `yaml:"topic"`
+	ClientID string                 `yaml:"client_id"`
+
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+
+	ConsumerGroup          string        `yaml:"consumer_group"`
+	CommitInterval         time.Duration `yaml:"commit_interval"`
+	PartitionRecheckPeriod time.Duration `yaml:"partition_recheck_period"`
+}
+
+// RegisterFlags registers the Kafka flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("kafka", f)
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by
+// prefix. If prefix is a non-empty string, prefix should end with a period.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Enable the Kafka write path. Distributors write validated streams to Kafka instead of pushing them to ingesters, and ingesters consume the partitions they own.")
+	f.Var(&cfg.Address, prefix+".address", "Comma-separated list of Kafka broker addresses.")
+	f.StringVar(&cfg.Topic, prefix+".topic", "loki", "The Kafka topic streams are written to. Streams are assigned to partitions by their hash.")
+	f.StringVar(&cfg.ClientID, prefix+".client-id", "loki", "The client ID sent to the Kafka brokers.")
+	f.DurationVar(&cfg.DialTimeout, prefix+".dial-timeout", 2*time.Second, "The maximum time allowed to open a connection to a Kafka broker.")
+	f.DurationVar(&cfg.WriteTimeout, prefix+".write-timeout", 10*time.Second, "How long to wait for the Kafka brokers to acknowledge a write.")
+	f.StringVar(&cfg.ConsumerGroup, prefix+".consumer-group", "loki-ingester", "The consumer group all ingesters commit their offsets to. Offsets are committed per partition, so the next owner of a partition resumes from the last offset flushed by any of its replicas.")
+	f.DurationVar(&cfg.CommitInterval, prefix+".commit-interval", 15*time.Second, "How often ingesters commit the offsets of records whose data has been flushed to the store.")
+	f.DurationVar(&cfg.PartitionRecheckPeriod, prefix+".partition-recheck-period", 10*time.Second, "How often ingesters check which partitions they own based on the ingester ring.")
+}
+
+// Validate validates the Kafka config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if len(cfg.Address) == 0 {
+		return errors.New("the Kafka broker address must be set when the Kafka write path is enabled")
+	}
+	if cfg.Topic == "" {
+		return errors.New("the Kafka topic must be set when the Kafka write path is enabled")
+	}
+	if cfg.ConsumerGroup == "" {
+		return errors.New("the Kafka consumer group must be set when the Kafka write path is enabled")
+	}
+	if cfg.CommitInterval <= 0 {
+		return errors.New("the Kafka commit interval must be greater than 0")
+	}
+	if cfg.PartitionRecheckPeriod <= 0 {
+		return errors.New("the Kafka partition recheck period must be greater than 0")
+	}
+	return nil
+}
+
+// saramaConfig returns the sarama client configuration shared by the writer
+// and the reader.
+func (cfg *Config) saramaConfig() *sarama.Config {
+	c := sarama.NewConfig()
+	c.ClientID = cfg.ClientID
+	c.Version = sarama.V2_1_0_0
+	c.Net.DialTimeout = cfg.DialTimeout
+	c.Net.WriteTimeout = cfg.WriteTimeout
+	c.Metadata.Full = false
+
+	// Partitions are picked explicitly from the stream hash.
+	c.Producer.Partitioner = sarama.NewManualPartitioner
+	c.Producer.RequiredAcks = sarama.WaitForAll
+	c.Producer.Timeout = cfg.WriteTimeout
+	c.Producer.Return.Successes = true
+	c.Producer.Return.Errors = true
+
+	// Offsets are committed explicitly once the consumed data has been flushed.
+	c.Consumer.Offsets.AutoCommit.Enable = false
+	c.Consumer.Offsets.Initial = sarama.OffsetOldest
+	c.Consumer.Return.Errors = true
+	return c
+}
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+const tenantHeader = "tenant"
+
+// Stream is a validated stream along with the ring token used to pick its partition.
+type Stream struct {
+	HashKey uint32
+	Stream  logproto.Stream
+}
+
+// PartitionFor returns the partition the stream with the given hash key is
+// written to.
+func PartitionFor(hashKey uint32, numPartitions int32) int32 {
+	return int32(hashKey % uint32(numPartitions))
+}
+
+// encode builds the record of a single stream. The record is keyed by the
+// stream hash and carries the tenant in a header.
+func encode(tenant string, numPartitions int32, s Stream) (*sarama.ProducerMessage, error) {
+	req := logproto.PushRequest{Streams: []logproto.Stream{s.Stream}}
+	value, err := req.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("marshal stream: %w", err)
+	}
+
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, s.HashKey)
+
+	return &sarama.ProducerMessage{
+		Key:       sarama.ByteEncoder(key),
+		Value:     sarama.ByteEncoder(value),
+		Partition: PartitionFor(s.HashKey, numPartitions),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(tenantHeader), Value: []byte(tenant)},
+		},
+	}, nil
+}
+
+// decode returns the tenant and the push request of a consumed record.
+func decode(msg *sarama.ConsumerMessage) (string, *logproto.PushRequest, error) {
+	var tenant string
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == tenantHeader {
+			tenant = string(h.Value)
+			break
+		}
+	}
+	if tenant == "" {
+		return "", nil, errors.New("record has no tenant header")
+	}
+
+	var req logproto.PushRequest
+	if err := req.Unmarshal(msg.Value); err != nil {
+		return "", nil, fmt.Errorf("unmarshal stream: %w", err)
+	}
+	return tenant, &req, nil
+}
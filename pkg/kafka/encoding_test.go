@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	s := Stream{
+		HashKey: 42,
+		Stream: logproto.Stream{
+			Labels: `{foo="bar"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: time.Unix(1, 0).UTC(), Line: "line 1"},
+				{Timestamp: time.Unix(2, 0).UTC(), Line: "line 2"},
+			},
+		},
+	}
+
+	msg, err := encode("tenant-a", 4, s)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), msg.Partition)
+
+	key, err := msg.Key.Encode()
+	require.NoError(t, err)
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+
+	tenant, req, err := decode(&sarama.ConsumerMessage{
+		Key:     key,
+		Value:   value,
+		Headers: []*sarama.RecordHeader{{Key: []byte(tenantHeader), Value: []byte("tenant-a")}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "tenant-a", tenant)
+	require.Equal(t, []logproto.Stream{s.Stream}, req.Streams)
+
+	_, _, err = decode(&sarama.ConsumerMessage{Value: value})
+	require.Error(t, err)
+}
+
+func TestPartitionFor(t *testing.T) {
+	require.Equal(t, int32(0), PartitionFor(0, 3))
+	require.Equal(t, int32(1), PartitionFor(4, 3))
+	require.Equal(t, int32(2), PartitionFor(5, 3))
+}
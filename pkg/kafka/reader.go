@@ -0,0 +1,421 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+// checkpointResolution bounds the number of checkpoints kept per partition by
+// merging the offsets consumed within the same interval.
+const checkpointResolution = time.Second
+
+// Pusher is the interface used by the reader to hand consumed streams to the
+// ingester.
+type Pusher interface {
+	Push(ctx context.Context, req *logproto.PushRequest) (*logproto.PushResponse, error)
+}
+
+// FlushWatermark returns the time before which all the data pushed to the
+// ingester has been flushed to the store.
+type FlushWatermark func() time.Time
+
+// Reader consumes the partitions owned by an ingester and pushes the streams
+// to it. Offsets are only committed once the ingester has flushed the data
+// consumed up to them, and they are shared by all the ingesters, so whichever
+// ingester owns a partition next replays what had not been flushed.
+type Reader struct {
+	services.Service
+
+	cfg       Config
+	addr      string
+	ring      ring.ReadRing
+	pusher    Pusher
+	watermark FlushWatermark
+	logger    log.Logger
+
+	client   sarama.Client
+	consumer sarama.Consumer
+	offsets  sarama.OffsetManager
+
+	mtx        sync.Mutex
+	partitions map[int32]*partitionReader
+
+	metrics *readerMetrics
+}
+
+type readerMetrics struct {
+	recordsConsumed  prometheus.Counter
+	pushFailures     *prometheus.CounterVec
+	ownedPartitions  prometheus.Gauge
+	committedOffsets *prometheus.GaugeVec
+}
+
+func newReaderMetrics(registerer prometheus.Registerer) *readerMetrics {
+	return &readerMetrics{
+		recordsConsumed: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "kafka_reader_records_total",
+			Help:      "The total number of records consumed from Kafka.",
+		}),
+		pushFailures: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "kafka_reader_push_failures_total",
+			Help:      "The total number of consumed records that could not be pushed to the ingester.",
+		}, []string{"reason"}),
+		ownedPartitions: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "kafka_reader_owned_partitions",
+			Help:      "The number of partitions consumed by this ingester.",
+		}),
+		committedOffsets: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "kafka_reader_committed_offset",
+			Help:      "The last offset committed per partition.",
+		}, []string{"partition"}),
+	}
+}
+
+// NewReader makes a new Reader for the ingester with the given ring address.
+func NewReader(cfg Config, addr string, ingesterRing ring.ReadRing, pusher Pusher, watermark FlushWatermark, registerer prometheus.Registerer, logger log.Logger) *Reader {
+	r := &Reader{
+		cfg:        cfg,
+		addr:       addr,
+		ring:       ingesterRing,
+		pusher:     pusher,
+		watermark:  watermark,
+		logger:     log.With(logger, "component", "kafka-reader"),
+		partitions: make(map[int32]*partitionReader),
+		metrics:    newReaderMetrics(registerer),
+	}
+	r.Service = services.NewBasicService(r.starting, r.running, r.stopping)
+	return r
+}
+
+func (r *Reader) starting(_ context.Context) error {
+	var err error
+	r.client, err = sarama.NewClient(r.cfg.Address, r.cfg.saramaConfig())
+	if err != nil {
+		return fmt.Errorf("creating kafka client: %w", err)
+	}
+
+	r.consumer, err = sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return fmt.Errorf("creating kafka consumer: %w", err)
+	}
+
+	r.offsets, err = sarama.NewOffsetManagerFromClient(r.cfg.ConsumerGroup, r.client)
+	if err != nil {
+		return fmt.Errorf("creating kafka offset manager: %w", err)
+	}
+	return nil
+}
+
+func (r *Reader) running(ctx context.Context) error {
+	recheck := time.NewTicker(r.cfg.PartitionRecheckPeriod)
+	defer recheck.Stop()
+	commit := time.NewTicker(r.cfg.CommitInterval)
+	defer commit.Stop()
+
+	r.syncPartitions(ctx)
+	for {
+		select {
+		case <-recheck.C:
+			r.syncPartitions(ctx)
+		case <-commit.C:
+			r.commit()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (r *Reader) stopping(_ error) error {
+	r.mtx.Lock()
+	for _, pr := range r.partitions {
+		pr.stop()
+	}
+	r.mtx.Unlock()
+
+	// Offsets of data that hasn't been flushed yet are not committed, so the
+	// next owner of the partition resumes from the last flushed offset.
+	if r.offsets != nil {
+		r.commit()
+		if err := r.offsets.Close(); err != nil {
+			level.Warn(r.logger).Log("msg", "failed to close offset manager", "err", err)
+		}
+	}
+	if r.consumer != nil {
+		if err := r.consumer.Close(); err != nil {
+			level.Warn(r.logger).Log("msg", "failed to close consumer", "err", err)
+		}
+	}
+	if r.client != nil {
+		return r.client.Close()
+	}
+	return nil
+}
+
+// syncPartitions starts consuming the partitions newly owned by this ingester
+// and stops consuming the ones it doesn't own anymore.
+func (r *Reader) syncPartitions(ctx context.Context) {
+	partitions, err := r.client.Partitions(r.cfg.Topic)
+	if err != nil {
+		level.Error(r.logger).Log("msg", "failed to list partitions", "topic", r.cfg.Topic, "err", err)
+		return
+	}
+
+	owned, err := ownedPartitions(r.ring, r.addr, partitions)
+	if err != nil {
+		level.Error(r.logger).Log("msg", "failed to compute owned partitions", "err", err)
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for p, pr := range r.partitions {
+		if _, ok := owned[p]; ok {
+			continue
+		}
+		level.Info(r.logger).Log("msg", "stopping to consume partition", "partition", p)
+		pr.stop()
+		// The offset is committed along with the others by the next commit.
+		r.mark(pr, r.watermark())
+		delete(r.partitions, p)
+		r.metrics.committedOffsets.DeleteLabelValues(strconv.Itoa(int(p)))
+	}
+
+	for p := range owned {
+		if _, ok := r.partitions[p]; ok {
+			continue
+		}
+		pr, err := r.startPartition(ctx, p)
+		if err != nil {
+			level.Error(r.logger).Log("msg", "failed to consume partition", "partition", p, "err", err)
+			continue
+		}
+		r.partitions[p] = pr
+	}
+
+	r.metrics.ownedPartitions.Set(float64(len(r.partitions)))
+}
+
+func (r *Reader) startPartition(ctx context.Context, partition int32) (*partitionReader, error) {
+	pom, err := r.offsets.ManagePartition(r.cfg.Topic, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, _ := pom.NextOffset()
+	pc, err := r.consumer.ConsumePartition(r.cfg.Topic, partition, offset)
+	if err != nil {
+		_ = pom.Close()
+		return nil, err
+	}
+
+	level.Info(r.logger).Log("msg", "starting to consume partition", "partition", partition, "offset", offset)
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr := &partitionReader{
+		partition: partition,
+		pc:        pc,
+		pom:       pom,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go r.consume(ctx, pr)
+	return pr, nil
+}
+
+func (r *Reader) consume(ctx context.Context, pr *partitionReader) {
+	defer close(pr.done)
+
+	logger := log.With(r.logger, "partition", pr.partition)
+	for {
+		select {
+		case msg, ok := <-pr.pc.Messages():
+			if !ok {
+				return
+			}
+			r.metrics.recordsConsumed.Inc()
+			if err := r.push(ctx, logger, msg); err != nil {
+				// The record is neither pushed nor dropped, so no offset from it
+				// onwards is tracked or committed, and the next owner of the
+				// partition resumes from it at the latest.
+				level.Info(logger).Log("msg", "stopping to consume partition before the record was pushed", "offset", msg.Offset, "err", err)
+				return
+			}
+			pr.tracker.consumed(msg.Offset, time.Now())
+		case err, ok := <-pr.pc.Errors():
+			if ok {
+				level.Warn(logger).Log("msg", "error consuming partition", "err", err)
+			}
+		case err, ok := <-pr.pom.Errors():
+			if ok {
+				level.Warn(logger).Log("msg", "error committing offset", "err", err)
+			}
+		}
+	}
+}
+
+// push hands the record to the ingester. Records which can't be decoded or
+// are rejected because of the tenant limits are dropped, other failures are
+// retried until ctx is done. It returns an error only if the record was
+// neither pushed nor dropped.
+func (r *Reader) push(ctx context.Context, logger log.Logger, msg *sarama.ConsumerMessage) error {
+	tenant, req, err := decode(msg)
+	if err != nil {
+		r.metrics.pushFailures.WithLabelValues("decode").Inc()
+		level.Error(logger).Log("msg", "dropping undecodable record", "offset", msg.Offset, "err", err)
+		return nil
+	}
+
+	pushCtx := user.InjectOrgID(ctx, tenant)
+	b := backoff.New(ctx, backoff.Config{MinBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second})
+	for b.Ongoing() {
+		_, err = r.pusher.Push(pushCtx, req)
+		if err == nil {
+			return nil
+		}
+		if resp, ok := httpgrpc.HTTPResponseFromError(err); ok && resp.Code/100 == 4 && resp.Code != http.StatusTooManyRequests {
+			r.metrics.pushFailures.WithLabelValues("rejected").Inc()
+			level.Debug(logger).Log("msg", "dropping rejected record", "offset", msg.Offset, "tenant", tenant, "err", err)
+			return nil
+		}
+		r.metrics.pushFailures.WithLabelValues("retry").Inc()
+		level.Warn(logger).Log("msg", "failed to push record, retrying", "offset", msg.Offset, "tenant", tenant, "err", err)
+		b.Wait()
+	}
+	return fmt.Errorf("pushing record at offset %d: %w", msg.Offset, b.Err())
+}
+
+// commit marks the offsets of all the data flushed by the ingester and
+// commits them to Kafka. Every replica of a partition commits to the same
+// group, so a lagging replica may move the committed offset back, which only
+// makes the next owner replay more.
+func (r *Reader) commit() {
+	watermark := r.watermark()
+
+	r.mtx.Lock()
+	for _, pr := range r.partitions {
+		r.mark(pr, watermark)
+	}
+	r.mtx.Unlock()
+
+	r.offsets.Commit()
+}
+
+// mark marks the offset of the data of the partition flushed before the
+// watermark, to be committed by the next commit.
+func (r *Reader) mark(pr *partitionReader, watermark time.Time) {
+	if offset := pr.tracker.committable(watermark); offset >= 0 {
+		// The committed offset is the next one to consume.
+		pr.pom.MarkOffset(offset+1, "")
+		r.metrics.committedOffsets.WithLabelValues(strconv.Itoa(int(pr.partition))).Set(float64(offset))
+	}
+}
+
+type partitionReader struct {
+	partition int32
+	pc        sarama.PartitionConsumer
+	pom       sarama.PartitionOffsetManager
+	tracker   offsetTracker
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// stop stops consuming the partition, giving up on the record being pushed if
+// any, so it doesn't wait for an unavailable ingester. Offsets marked after it
+// are still committed by the next commit of the offset manager.
+func (pr *partitionReader) stop() {
+	pr.cancel()
+	pr.pc.AsyncClose()
+	<-pr.done
+	pr.pom.AsyncClose()
+}
+
+type checkpoint struct {
+	offset     int64
+	createdAt  time.Time
+	consumedAt time.Time
+}
+
+// offsetTracker remembers when offsets have been consumed, to find out which
+// ones can be committed.
+type offsetTracker struct {
+	mtx         sync.Mutex
+	checkpoints []checkpoint
+}
+
+// consumed records that the record at offset has been pushed at time now.
+func (t *offsetTracker) consumed(offset int64, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if n := len(t.checkpoints); n > 0 && now.Sub(t.checkpoints[n-1].createdAt) < checkpointResolution {
+		t.checkpoints[n-1].offset = offset
+		t.checkpoints[n-1].consumedAt = now
+		return
+	}
+	t.checkpoints = append(t.checkpoints, checkpoint{offset: offset, createdAt: now, consumedAt: now})
+}
+
+// committable returns the highest offset consumed before the watermark and
+// forgets about it and all the previous ones. It returns -1 if there is none.
+func (t *offsetTracker) committable(watermark time.Time) int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	i := 0
+	for ; i < len(t.checkpoints) && t.checkpoints[i].consumedAt.Before(watermark); i++ {
+	}
+	if i == 0 {
+		return -1
+	}
+
+	offset := t.checkpoints[i-1].offset
+	t.checkpoints = append(t.checkpoints[:0], t.checkpoints[i:]...)
+	return offset
+}
+
+// ownedPartitions returns the partitions whose token is owned by the instance
+// with the given address. Partition tokens are evenly spread over the ring, and
+// every replica of a token consumes the partition.
+func ownedPartitions(r ring.ReadRing, addr string, partitions []int32) (map[int32]struct{}, error) {
+	var descs [5]ring.InstanceDesc
+
+	owned := make(map[int32]struct{})
+	for _, p := range partitions {
+		rs, err := r.Get(partitionToken(p, len(partitions)), ring.WriteNoExtend, descs[:0], nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if rs.Includes(addr) {
+			owned[p] = struct{}{}
+		}
+	}
+	return owned, nil
+}
+
+func partitionToken(partition int32, numPartitions int) uint32 {
+	return uint32(uint64(partition) * (math.MaxUint32 + 1) / uint64(numPartitions))
+}
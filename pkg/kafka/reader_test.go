@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+type fakePusher func() error
+
+func (f fakePusher) Push(_ context.Context, _ *logproto.PushRequest) (*logproto.PushResponse, error) {
+	return &logproto.PushResponse{}, f()
+}
+
+func TestOffsetTracker(t *testing.T) {
+	var tracker offsetTracker
+	now := time.Now()
+
+	require.Equal(t, int64(-1), tracker.committable(now))
+
+	// Offsets consumed within the same second share a checkpoint.
+	tracker.consumed(0, now)
+	tracker.consumed(1, now.Add(500*time.Millisecond))
+	tracker.consumed(2, now.Add(2*time.Second))
+	tracker.consumed(3, now.Add(4*time.Second))
+	require.Len(t, tracker.checkpoints, 3)
+
+	require.Equal(t, int64(-1), tracker.committable(now))
+	require.Equal(t, int64(1), tracker.committable(now.Add(time.Second)))
+	require.Equal(t, int64(-1), tracker.committable(now.Add(time.Second)))
+	require.Equal(t, int64(3), tracker.committable(now.Add(time.Minute)))
+	require.Empty(t, tracker.checkpoints)
+}
+
+func TestPartitionToken(t *testing.T) {
+	require.Equal(t, uint32(0), partitionToken(0, 4))
+	require.Equal(t, uint32(1<<30), partitionToken(1, 4))
+	require.Equal(t, uint32(3<<30), partitionToken(3, 4))
+	require.Equal(t, uint32(math.MaxUint32/3), partitionToken(1, 3))
+}
+
+func TestReader_Push(t *testing.T) {
+	msg, err := encode("tenant", 1, Stream{Stream: logproto.Stream{Labels: `{foo="bar"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "line"}}}})
+	require.NoError(t, err)
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+	record := &sarama.ConsumerMessage{
+		Value:   value,
+		Headers: []*sarama.RecordHeader{{Key: []byte(tenantHeader), Value: []byte("tenant")}},
+	}
+
+	newReader := func(pusher fakePusher) *Reader {
+		return &Reader{pusher: pusher, metrics: newReaderMetrics(nil)}
+	}
+
+	// Records are retried until they are pushed.
+	calls := 0
+	r := newReader(func() error {
+		if calls++; calls < 3 {
+			return httpgrpc.Errorf(http.StatusServiceUnavailable, "unavailable")
+		}
+		return nil
+	})
+	require.NoError(t, r.push(context.Background(), log.NewNopLogger(), record))
+	require.Equal(t, 3, calls)
+
+	// Records rejected by the limits or which can't be decoded are dropped.
+	r = newReader(func() error { return httpgrpc.Errorf(http.StatusBadRequest, "rejected") })
+	require.NoError(t, r.push(context.Background(), log.NewNopLogger(), record))
+	require.Equal(t, float64(1), testutil.ToFloat64(r.metrics.pushFailures.WithLabelValues("rejected")))
+	require.NoError(t, r.push(context.Background(), log.NewNopLogger(), &sarama.ConsumerMessage{Value: value}))
+	require.Equal(t, float64(1), testutil.ToFloat64(r.metrics.pushFailures.WithLabelValues("decode")))
+
+	// Records which couldn't be pushed before the reader stops are neither.
+	ctx, cancel := context.WithCancel(context.Background())
+	r = newReader(func() error {
+		cancel()
+		return errors.New("unavailable")
+	})
+	require.ErrorIs(t, r.push(ctx, log.NewNopLogger(), record), context.Canceled)
+}
+
+func TestReader_PartitionHandoff(t *testing.T) {
+	const topic = "loki"
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// records returns a fetch response for a partition holding n records.
+	records := func(n int64) *sarama.MockFetchResponse {
+		fetch := sarama.NewMockFetchResponse(t, 1)
+		for offset := int64(0); offset < n; offset++ {
+			fetch.SetMessage(topic, 0, offset, sarama.StringEncoder("record"))
+		}
+		return fetch.SetHighWaterMark(topic, 0, n)
+	}
+
+	cfg := Config{
+		Address:        []string{broker.Addr()},
+		Topic:          topic,
+		ClientID:       "loki",
+		DialTimeout:    time.Second,
+		WriteTimeout:   time.Second,
+		ConsumerGroup:  "loki-ingester",
+		CommitInterval: time.Hour,
+	}
+	handlers := func(n int64, committed *sarama.MockOffsetFetchResponse) map[string]sarama.MockResponse {
+		return map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetLeader(topic, 0, broker.BrokerID()),
+			"OffsetRequest": sarama.NewMockOffsetResponse(t).
+				SetOffset(topic, 0, sarama.OffsetOldest, 0).
+				SetOffset(topic, 0, sarama.OffsetNewest, n),
+			"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+				SetCoordinator(sarama.CoordinatorGroup, cfg.ConsumerGroup, broker),
+			"OffsetFetchRequest":  committed,
+			"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+			"FetchRequest":        records(n),
+		}
+	}
+
+	// consumeUntil starts a reader for the partition with the given address,
+	// waits until it has consumed up to offset and stops it.
+	consumeUntil := func(addr string, offset int64) *Reader {
+		r := NewReader(cfg, addr, nil, fakePusher(func() error { return nil }), time.Now, nil, log.NewNopLogger())
+		require.NoError(t, r.starting(context.Background()))
+		pr, err := r.startPartition(context.Background(), 0)
+		require.NoError(t, err)
+		r.partitions[0] = pr
+		require.Eventually(t, func() bool {
+			pr.tracker.mtx.Lock()
+			defer pr.tracker.mtx.Unlock()
+			n := len(pr.tracker.checkpoints)
+			return n > 0 && pr.tracker.checkpoints[n-1].offset >= offset
+		}, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, r.stopping(nil))
+		return r
+	}
+
+	// The first owner starts from the oldest offset and commits what it has
+	// flushed when it stops.
+	broker.SetHandlerByMap(handlers(5, sarama.NewMockOffsetFetchResponse(t).
+		SetOffset(cfg.ConsumerGroup, topic, 0, -1, "", sarama.ErrNoError)))
+	first := consumeUntil("ingester-1:9095", 4)
+	require.Equal(t, float64(5), testutil.ToFloat64(first.metrics.recordsConsumed))
+
+	var committed int64 = -1
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.OffsetCommitRequest); ok {
+			require.Equal(t, cfg.ConsumerGroup, req.ConsumerGroup)
+			offset, _, err := req.Offset(topic, 0)
+			require.NoError(t, err)
+			committed = offset
+		}
+	}
+	require.Equal(t, int64(5), committed)
+
+	// The next owner, whatever its address, resumes from the committed offset
+	// instead of replaying the partition.
+	broker.SetHandlerByMap(handlers(10, sarama.NewMockOffsetFetchResponse(t).
+		SetOffset(cfg.ConsumerGroup, topic, 0, committed, "", sarama.ErrNoError)))
+	second := consumeUntil("ingester-2:9095", 9)
+	require.Equal(t, float64(5), testutil.ToFloat64(second.metrics.recordsConsumed))
+
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.OffsetFetchRequest); ok {
+			require.Equal(t, cfg.ConsumerGroup, req.ConsumerGroup)
+		}
+	}
+}
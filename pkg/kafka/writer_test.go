@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestWriter_Write(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Enabled = true
+	cfg.Address = []string{broker.Addr()}
+
+	// The mock responses must match the protocol version of the configured Kafka version.
+	produce := sarama.NewMockProduceResponse(t).SetVersion(3)
+	for p := int32(0); p < 2; p++ {
+		produce.SetError(cfg.Topic, p, sarama.ErrNoError)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(cfg.Topic, 0, broker.BrokerID()).
+			SetLeader(cfg.Topic, 1, broker.BrokerID()),
+		"ProduceRequest": produce,
+	})
+
+	reg := prometheus.NewRegistry()
+	w, err := NewWriter(cfg, reg, log.NewNopLogger())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, w.stopping(nil)) }()
+
+	err = w.Write(context.Background(), "test", []Stream{
+		{HashKey: 1, Stream: logproto.Stream{Labels: `{foo="bar"}`, Entries: []logproto.Entry{{Line: "a"}}}},
+		{HashKey: 2, Stream: logproto.Stream{Labels: `{foo="baz"}`, Entries: []logproto.Entry{{Line: "b"}}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, float64(2), testutil.ToFloat64(w.recordsWritten))
+	require.Equal(t, float64(0), testutil.ToFloat64(w.writeFailures))
+
+	require.NoError(t, w.Write(context.Background(), "test", nil))
+}
@@ -37,6 +37,8 @@ const (
 	statsPath         = "/loki/api/v1/index/stats"
 	volumePath        = "/loki/api/v1/index/volume"
 	volumeRangePath   = "/loki/api/v1/index/volume_range"
+	streamsPath       = "/loki/api/v1/ingester/streams"
+	queryJobsPath     = "/loki/api/v1/query_jobs"
 	defaultAuthHeader = "Authorization"
 )
 
@@ -46,6 +48,7 @@ var userAgent = fmt.Sprintf("loki-logcli/%s", build.Version)
 type Client interface {
 	Query(queryStr string, limit int, time time.Time, direction logproto.Direction, quiet bool) (*loghttp.QueryResponse, error)
 	QueryRange(queryStr string, limit int, start, end time.Time, direction logproto.Direction, step, interval time.Duration, quiet bool) (*loghttp.QueryResponse, error)
+	QueryRangeStream(queryStr string, limit int, start, end time.Time, direction logproto.Direction, quiet bool, fn func(loghttp.Streams) error) (*loghttp.QueryResponseFrame, error)
 	ListLabelNames(quiet bool, start, end time.Time) (*loghttp.LabelResponse, error)
 	ListLabelValues(name string, quiet bool, start, end time.Time) (*loghttp.LabelResponse, error)
 	Series(matchers []string, start, end time.Time, quiet bool) (*loghttp.SeriesResponse, error)
@@ -54,6 +57,10 @@ type Client interface {
 	GetStats(queryStr string, start, end time.Time, quiet bool) (*logproto.IndexStatsResponse, error)
 	GetVolume(query *volume.Query) (*loghttp.QueryResponse, error)
 	GetVolumeRange(query *volume.Query) (*loghttp.QueryResponse, error)
+	GetStreams(selector, sortBy string, groupBy []string, limit int, quiet bool) (*loghttp.StreamsResponse, error)
+	CreateQueryJob(queryStr string, limit int, start, end time.Time, direction logproto.Direction, step, interval time.Duration, quiet bool) (*loghttp.QueryJob, error)
+	GetQueryJob(id string, quiet bool) (*loghttp.QueryJob, error)
+	GetQueryJobResults(id string, page int, quiet bool) (*loghttp.QueryResponse, error)
 }
 
 // Tripperware can wrap a roundtripper.
@@ -117,6 +124,76 @@ func (c *DefaultClient) QueryRange(queryStr string, limit int, start, end time.T
 	return c.doQuery(queryRangePath, params.Encode(), quiet)
 }
 
+// QueryRangeStream uses the /api/v1/query_range endpoint to execute a log range
+// query, passing the streams to fn in the order they are streamed by the server.
+// The returned frame holds the warnings and statistics of the query.
+// excluding interfacer b/c it suggests taking the interface promql.Node instead of logproto.Direction b/c it happens to have a String() method
+// nolint:interfacer
+func (c *DefaultClient) QueryRangeStream(queryStr string, limit int, start, end time.Time, direction logproto.Direction, quiet bool, fn func(loghttp.Streams) error) (*loghttp.QueryResponseFrame, error) {
+	params := util.NewQueryStringBuilder()
+	params.SetString("query", queryStr)
+	params.SetInt32("limit", limit)
+	params.SetInt("start", start.UnixNano())
+	params.SetInt("end", end.UnixNano())
+	params.SetString("direction", direction.String())
+
+	resp, err := c.sendRequest(http.MethodGet, queryRangePath, params.Encode(), quiet, loghttp.NDJSONContentType)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Println("error closing body", err)
+		}
+	}()
+
+	// Servers which don't stream responses answer with a regular response.
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), loghttp.NDJSONContentType) {
+		var r loghttp.QueryResponse
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			return nil, err
+		}
+		return streamQueryResponse(&r, fn)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var frame loghttp.QueryResponseFrame
+		if err := dec.Decode(&frame); err != nil {
+			return nil, err
+		}
+		switch frame.Status {
+		case "":
+			if err := fn(frame.Streams); err != nil {
+				return nil, err
+			}
+		case loghttp.QueryStatusSuccess:
+			return &frame, nil
+		default:
+			return nil, fmt.Errorf("query failed: %s", frame.Error)
+		}
+	}
+	return nil, fmt.Errorf("streamed response ended before the query finished")
+}
+
+// streamQueryResponse passes the streams of a log query response to fn at once.
+func streamQueryResponse(resp *loghttp.QueryResponse, fn func(loghttp.Streams) error) (*loghttp.QueryResponseFrame, error) {
+	streams, ok := resp.Data.Result.(loghttp.Streams)
+	if !ok {
+		return nil, fmt.Errorf("only log queries can be streamed, got a %s result", resp.Data.ResultType)
+	}
+	if len(streams) > 0 {
+		if err := fn(streams); err != nil {
+			return nil, err
+		}
+	}
+	return &loghttp.QueryResponseFrame{
+		Status:   resp.Status,
+		Warnings: resp.Warnings,
+		Stats:    resp.Data.Statistics,
+	}, nil
+}
+
 // ListLabelNames uses the /api/v1/label endpoint to list label names
 func (c *DefaultClient) ListLabelNames(quiet bool, start, end time.Time) (*loghttp.LabelResponse, error) {
 	var labelResponse loghttp.LabelResponse
@@ -194,6 +271,67 @@ func (c *DefaultClient) GetVolumeRange(query *volume.Query) (*loghttp.QueryRespo
 	return c.getVolume(volumeRangePath, query)
 }
 
+// GetStreams returns the streams held in memory by the ingesters.
+func (c *DefaultClient) GetStreams(selector, sortBy string, groupBy []string, limit int, quiet bool) (*loghttp.StreamsResponse, error) {
+	params := util.NewQueryStringBuilder()
+	if selector != "" {
+		params.SetString("selector", selector)
+	}
+	if sortBy != "" {
+		params.SetString("sort_by", sortBy)
+	}
+	if len(groupBy) > 0 {
+		params.SetString("group_by", strings.Join(groupBy, ","))
+	}
+	params.SetInt("limit", int64(limit))
+
+	var resp loghttp.StreamsResponse
+	if err := c.doRequest(streamsPath, params.Encode(), quiet, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateQueryJob uses the /api/v1/query_jobs endpoint to execute a range query
+// asynchronously, returning the created query job.
+// nolint:interfacer
+func (c *DefaultClient) CreateQueryJob(queryStr string, limit int, start, end time.Time, direction logproto.Direction, step, interval time.Duration, quiet bool) (*loghttp.QueryJob, error) {
+	params := util.NewQueryStringBuilder()
+	params.SetString("query", queryStr)
+	params.SetInt32("limit", limit)
+	params.SetInt("start", start.UnixNano())
+	params.SetInt("end", end.UnixNano())
+	params.SetString("direction", direction.String())
+	if step != 0 {
+		params.SetFloat("step", step.Seconds())
+	}
+	if interval != 0 {
+		params.SetFloat("interval", interval.Seconds())
+	}
+
+	var job loghttp.QueryJob
+	if err := c.doMethodRequest(http.MethodPost, queryJobsPath, params.Encode(), quiet, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetQueryJob returns the status of a query job.
+func (c *DefaultClient) GetQueryJob(id string, quiet bool) (*loghttp.QueryJob, error) {
+	var job loghttp.QueryJob
+	if err := c.doRequest(path.Join(queryJobsPath, url.PathEscape(id)), "", quiet, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetQueryJobResults returns a page of the results of a query job.
+func (c *DefaultClient) GetQueryJobResults(id string, page int, quiet bool) (*loghttp.QueryResponse, error) {
+	params := util.NewQueryStringBuilder()
+	params.SetInt("page", int64(page))
+	return c.doQuery(path.Join(queryJobsPath, url.PathEscape(id), "results"), params.Encode(), quiet)
+}
+
 func (c *DefaultClient) getVolume(path string, query *volume.Query) (*loghttp.QueryResponse, error) {
 	queryStr, start, end, limit, step, targetLabels, aggregateByLabels, quiet :=
 		query.QueryString, query.Start, query.End, query.Limit, query.Step,
@@ -236,22 +374,45 @@ func (c *DefaultClient) doQuery(path string, query string, quiet bool) (*loghttp
 }
 
 func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{}) error {
-	us, err := buildURL(c.Address, path, query)
+	return c.doMethodRequest(http.MethodGet, path, query, quiet, out)
+}
+
+func (c *DefaultClient) doMethodRequest(method, path, query string, quiet bool, out interface{}) error {
+	resp, err := c.sendRequest(method, path, query, quiet, "")
 	if err != nil {
 		return err
 	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Println("error closing body", err)
+		}
+	}()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sendRequest sends the request, retrying it until the server answers with a
+// successful response. The caller must close the body of the response.
+func (c *DefaultClient) sendRequest(method, path, query string, quiet bool, accept string) (*http.Response, error) {
+	us, err := buildURL(c.Address, path, query)
+	if err != nil {
+		return nil, err
+	}
 	if !quiet {
 		log.Print(us)
 	}
 
-	req, err := http.NewRequest("GET", us, nil)
+	req, err := http.NewRequest(method, us, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	h, err := c.getHTTPRequestHeader()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if accept != "" {
+		h.Set("Accept", accept)
 	}
 	req.Header = h
 
@@ -263,14 +424,14 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 	if c.ProxyURL != "" {
 		prox, err := url.Parse(c.ProxyURL)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		clientConfig.ProxyURL = config.URL{URL: prox}
 	}
 
 	client, err := config.NewClientFromConfig(clientConfig, "promtail", config.WithHTTP2Disabled())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if c.Tripperware != nil {
 		client.Transport = c.Tripperware(client.Transport)
@@ -313,15 +474,10 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 
 	}
 	if !success {
-		return fmt.Errorf("run out of attempts while querying the server")
+		return nil, fmt.Errorf("run out of attempts while querying the server")
 	}
 
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Println("error closing body", err)
-		}
-	}()
-	return json.NewDecoder(resp.Body).Decode(out)
+	return resp, nil
 }
 
 // nolint:goconst
@@ -2,10 +2,17 @@ package client
 
 import (
 	"encoding/base64"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
 )
 
 func Test_buildURL(t *testing.T) {
@@ -78,3 +85,108 @@ func Test_getHTTPRequestHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultClient_QueryRangeStream(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		expected    []string
+		err         string
+	}{
+		{
+			name:        "frames",
+			contentType: loghttp.NDJSONContentType,
+			body: `{"streams":[{"stream":{"foo":"bar"},"values":[["2","b"]]}]}
+{"streams":[{"stream":{"foo":"bar"},"values":[["1","a"]]}]}
+{"status":"success","warnings":["slow"],"stats":{"summary":{"splits":2}}}
+`,
+			expected: []string{"b", "a"},
+		},
+		{
+			name:        "error frame",
+			contentType: loghttp.NDJSONContentType,
+			body: `{"streams":[{"stream":{"foo":"bar"},"values":[["2","b"]]}]}
+{"status":"error","error":"querier unavailable"}
+`,
+			err: "query failed: querier unavailable",
+		},
+		{
+			name:        "missing final frame",
+			contentType: loghttp.NDJSONContentType,
+			body: `{"streams":[{"stream":{"foo":"bar"},"values":[["2","b"]]}]}
+`,
+			err: "streamed response ended before the query finished",
+		},
+		{
+			name:        "regular response",
+			contentType: "application/json; charset=UTF-8",
+			body:        `{"status":"success","warnings":["slow"],"data":{"resultType":"streams","result":[{"stream":{"foo":"bar"},"values":[["2","b"],["1","a"]]}],"stats":{"summary":{"splits":2}}}}`,
+			expected:    []string{"b", "a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, loghttp.NDJSONContentType, r.Header.Get("Accept"))
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = io.WriteString(w, tc.body)
+			}))
+			defer server.Close()
+
+			client := &DefaultClient{Address: server.URL}
+			var lines []string
+			frame, err := client.QueryRangeStream(`{foo="bar"}`, 10, time.Unix(0, 0), time.Unix(0, 3), logproto.BACKWARD, true, func(streams loghttp.Streams) error {
+				for _, s := range streams {
+					for _, e := range s.Entries {
+						lines = append(lines, e.Line)
+					}
+				}
+				return nil
+			})
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, lines)
+			require.Equal(t, []string{"slow"}, frame.Warnings)
+			require.Equal(t, int64(2), frame.Stats.Summary.Splits)
+		})
+	}
+}
+
+func TestDefaultClient_QueryJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == queryJobsPath:
+			require.Equal(t, `{foo="bar"}`, r.URL.Query().Get("query"))
+			require.Equal(t, "BACKWARD", r.URL.Query().Get("direction"))
+			_, _ = io.WriteString(w, `{"id":"01J","state":"queued","splits":3}`)
+		case r.Method == http.MethodGet && r.URL.Path == queryJobsPath+"/01J":
+			_, _ = io.WriteString(w, `{"id":"01J","state":"succeeded","splits":3,"completed_splits":3,"pages":1}`)
+		case r.Method == http.MethodGet && r.URL.Path == queryJobsPath+"/01J/results":
+			require.Equal(t, "0", r.URL.Query().Get("page"))
+			_, _ = io.WriteString(w, `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"foo":"bar"},"values":[["1","a"]]}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &DefaultClient{Address: server.URL}
+	job, err := client.CreateQueryJob(`{foo="bar"}`, 10, time.Unix(0, 0), time.Unix(0, 3), logproto.BACKWARD, 0, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, loghttp.QueryJobQueued, job.State)
+	require.Equal(t, 3, job.Splits)
+
+	job, err = client.GetQueryJob(job.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, loghttp.QueryJobSucceeded, job.State)
+	require.Equal(t, 1, job.Pages)
+
+	resp, err := client.GetQueryJobResults(job.ID, 0, true)
+	require.NoError(t, err)
+	streams := resp.Data.Result.(loghttp.Streams)
+	require.Len(t, streams, 1)
+	require.Equal(t, "a", streams[0].Entries[0].Line)
+}
@@ -145,6 +145,16 @@ func (f *FileClient) QueryRange(queryStr string, limit int, start, end time.Time
 	}, nil
 }
 
+// QueryRangeStream executes the log query on the file and passes all its
+// streams to fn at once, as the file is read by a single query.
+func (f *FileClient) QueryRangeStream(queryStr string, limit int, start, end time.Time, direction logproto.Direction, quiet bool, fn func(loghttp.Streams) error) (*loghttp.QueryResponseFrame, error) {
+	resp, err := f.QueryRange(queryStr, limit, start, end, direction, 0, 0, quiet)
+	if err != nil {
+		return nil, err
+	}
+	return streamQueryResponse(resp, fn)
+}
+
 func (f *FileClient) ListLabelNames(_ bool, _, _ time.Time) (*loghttp.LabelResponse, error) {
 	return &loghttp.LabelResponse{
 		Status: loghttp.QueryStatusSuccess,
@@ -204,6 +214,22 @@ func (f *FileClient) GetVolumeRange(_ *volume.Query) (*loghttp.QueryResponse, er
 	return nil, ErrNotSupported
 }
 
+func (f *FileClient) GetStreams(_, _ string, _ []string, _ int, _ bool) (*loghttp.StreamsResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (f *FileClient) CreateQueryJob(_ string, _ int, _, _ time.Time, _ logproto.Direction, _, _ time.Duration, _ bool) (*loghttp.QueryJob, error) {
+	return nil, ErrNotSupported
+}
+
+func (f *FileClient) GetQueryJob(_ string, _ bool) (*loghttp.QueryJob, error) {
+	return nil, ErrNotSupported
+}
+
+func (f *FileClient) GetQueryJobResults(_ string, _ int, _ bool) (*loghttp.QueryResponse, error) {
+	return nil, ErrNotSupported
+}
+
 type limiter struct {
 	n int
 }
@@ -88,6 +88,29 @@ func TestFileClient_QueryRangeLogQueries(t *testing.T) {
 	}
 }
 
+func TestFileClient_QueryRangeStream(t *testing.T) {
+	input := []string{
+		`level=info event="loki started" caller=main.go ts=1625995076`,
+		`level=info event="loki ready" caller=main.go ts=1625996095`,
+	}
+	now := time.Now()
+
+	client := NewFileClient(io.NopCloser(strings.NewReader(strings.Join(input, "\n"))))
+	var streamed loghttp.Streams
+	frame, err := client.QueryRangeStream(`{foo="bar"}`, 10, now.Add(-1*time.Hour), now, logproto.FORWARD, true, func(streams loghttp.Streams) error {
+		streamed = append(streamed, streams...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, loghttp.QueryStatusSuccess, frame.Status)
+	assertStreams(t, streamed, input)
+
+	_, err = client.QueryRangeStream(`rate({foo="bar"}[1m])`, 10, now.Add(-1*time.Hour), now, logproto.FORWARD, true, func(loghttp.Streams) error {
+		return nil
+	})
+	require.Error(t, err)
+}
+
 func TestFileClient_Query(t *testing.T) {
 	input := []string{
 		`level=info event="loki started" caller=main.go ts=1625995076`,
@@ -45,6 +45,9 @@ type Query struct {
 	BatchSize              int
 	Forward                bool
 	Step                   time.Duration
+	Stream                 bool
+	Async                  bool
+	AsyncPollInterval      time.Duration
 	Interval               time.Duration
 	Quiet                  bool
 	NoLabels               bool
@@ -129,6 +132,10 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 			result.PrintStats(resp.Data.Statistics)
 		}
 		_, _ = result.PrintResult(resp.Data.Result, out, nil)
+	} else if q.Stream {
+		q.doStreamQuery(c, out, result, statistics)
+	} else if q.Async {
+		q.doAsyncQuery(c, out, result, statistics)
 	} else {
 		unlimited := q.Limit == 0
 
@@ -208,6 +215,64 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 	}
 }
 
+// doStreamQuery executes the range query as a single request streamed by the
+// server, printing the entries of every frame as soon as it is received.
+func (q *Query) doStreamQuery(c client.Client, out output.LogOutput, result *print.QueryResultPrinter, statistics bool) {
+	frame, err := c.QueryRangeStream(q.QueryString, q.Limit, q.Start, q.End, q.resultsDirection(), q.Quiet, func(streams loghttp.Streams) error {
+		_, _ = result.PrintResult(streams, out, nil)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Query failed: %+v", err)
+	}
+
+	if statistics {
+		result.PrintStats(frame.Stats)
+	}
+}
+
+// doAsyncQuery executes the range query as a query job of the server, polling
+// its status and printing the pages of its results as they become available.
+func (q *Query) doAsyncQuery(c client.Client, out output.LogOutput, result *print.QueryResultPrinter, statistics bool) {
+	job, err := c.CreateQueryJob(q.QueryString, q.Limit, q.Start, q.End, q.resultsDirection(), q.Step, q.Interval, q.Quiet)
+	if err != nil {
+		log.Fatalf("Query failed: %+v", err)
+	}
+	if !q.Quiet {
+		log.Printf("Created query job %s with %d splits", job.ID, job.Splits)
+	}
+
+	for page := 0; ; {
+		for ; page < job.Pages; page++ {
+			resp, err := c.GetQueryJobResults(job.ID, page, q.Quiet)
+			if err != nil {
+				log.Fatalf("Query job %s failed: %+v", job.ID, err)
+			}
+			if statistics {
+				result.PrintStats(resp.Data.Statistics)
+			}
+			_, _ = result.PrintResult(resp.Data.Result, out, nil)
+		}
+		if job.State.Done() {
+			break
+		}
+
+		time.Sleep(q.AsyncPollInterval)
+		status, err := c.GetQueryJob(job.ID, true)
+		if err != nil {
+			log.Fatalf("Query job %s failed: %+v", job.ID, err)
+		}
+		job = status
+		if !q.Quiet {
+			log.Printf("Query job %s is %s, %d/%d splits completed", job.ID, job.State, job.CompletedSplits, job.Splits)
+		}
+	}
+
+	if job.State != loghttp.QueryJobSucceeded {
+		log.Fatalf("Query job %s %s: %s", job.ID, job.State, job.Error)
+	}
+}
+
 func (q *Query) outputFilename() string {
 	return fmt.Sprintf(
 		"%s_%s_%s.part",
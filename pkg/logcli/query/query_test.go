@@ -453,6 +453,10 @@ func (t *testQueryClient) QueryRange(queryStr string, limit int, from, through t
 	return q, nil
 }
 
+func (t *testQueryClient) QueryRangeStream(_ string, _ int, _, _ time.Time, _ logproto.Direction, _ bool, _ func(loghttp.Streams) error) (*loghttp.QueryResponseFrame, error) {
+	panic("implement me")
+}
+
 func (t *testQueryClient) ListLabelNames(_ bool, _, _ time.Time) (*loghttp.LabelResponse, error) {
 	panic("implement me")
 }
@@ -485,6 +489,22 @@ func (t *testQueryClient) GetVolumeRange(_ *volume.Query) (*loghttp.QueryRespons
 	panic("not implemented")
 }
 
+func (t *testQueryClient) GetStreams(_, _ string, _ []string, _ int, _ bool) (*loghttp.StreamsResponse, error) {
+	panic("not implemented")
+}
+
+func (t *testQueryClient) CreateQueryJob(_ string, _ int, _, _ time.Time, _ logproto.Direction, _, _ time.Duration, _ bool) (*loghttp.QueryJob, error) {
+	panic("not implemented")
+}
+
+func (t *testQueryClient) GetQueryJob(_ string, _ bool) (*loghttp.QueryJob, error) {
+	panic("not implemented")
+}
+
+func (t *testQueryClient) GetQueryJobResults(_ string, _ int, _ bool) (*loghttp.QueryResponse, error) {
+	panic("not implemented")
+}
+
 var legacySchemaConfigContents = `schema_config:
   configs:
   - from: 2020-05-15
@@ -15,6 +15,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/logcli/client"
 	"github.com/grafana/loki/v3/pkg/logcli/output"
+	"github.com/grafana/loki/v3/pkg/logcli/print"
 	"github.com/grafana/loki/v3/pkg/logcli/util"
 	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/util/unmarshal"
@@ -46,6 +47,7 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 	}
 
 	tailResponse := new(loghttp.TailResponse)
+	resPrinter := print.NewQueryResultPrinter(q.ShowLabelsKey, q.IgnoreLabelsKey, q.Quiet, q.FixedLabelsLen, q.Forward)
 	lastReceivedTimestamp := q.Start
 
 	for {
@@ -92,6 +94,12 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 			return
 		}
 
+		if tailResponse.ResultType == loghttp.ResultTypeVector {
+			// Metric tails send the vector of every step instead of entries.
+			resPrinter.PrintResult(tailResponse.Result, out, nil)
+			continue
+		}
+
 		labels := loghttp.LabelSet{}
 		for _, stream := range tailResponse.Streams {
 			if !q.NoLabels {
@@ -121,8 +129,12 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 
 		}
 		if len(tailResponse.DroppedStreams) != 0 {
-			log.Println("Server dropped following entries due to slow client")
+			log.Println("Server dropped following entries due to slow client or tail budget")
 			for _, d := range tailResponse.DroppedStreams {
+				if d.Count > 0 {
+					log.Println(d.Timestamp, d.Labels, d.Count, "lines")
+					continue
+				}
 				log.Println(d.Timestamp, d.Labels)
 			}
 		}
@@ -0,0 +1,62 @@
+package streamquery
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/grafana/loki/v3/pkg/logcli/client"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+)
+
+// StreamsQuery contains all necessary fields to list the streams held in
+// memory by the ingesters and print out the results
+type StreamsQuery struct {
+	Selector string
+	SortBy   string
+	GroupBy  []string
+	Limit    int
+	Quiet    bool
+}
+
+// DoStreams prints out the streams, or groups of streams, as a table
+func (q *StreamsQuery) DoStreams(c client.Client) {
+	resp, err := c.GetStreams(q.Selector, q.SortBy, q.GroupBy, q.Limit, q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Labels\tStreams\tEntries\tBytes\tChunks\tRate\tLast Push\n")
+	for _, s := range resp.Streams {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%s/s\t%s\n",
+			q.labels(s.Labels), s.Streams, s.Entries, humanize.Bytes(s.Bytes), s.Chunks,
+			humanize.Bytes(uint64(s.Rate)), lastPush(s.LastPush))
+	}
+	w.Flush()
+}
+
+// labels prints the grouping labels in the order they were given, and the
+// labels of a stream sorted.
+func (q *StreamsQuery) labels(lbs loghttp.LabelSet) string {
+	if len(q.GroupBy) == 0 {
+		return lbs.String()
+	}
+	pairs := make([]string, 0, len(q.GroupBy))
+	for _, name := range q.GroupBy {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, lbs[name]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func lastPush(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return humanize.Time(t)
+}
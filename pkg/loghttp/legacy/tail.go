@@ -3,6 +3,8 @@ package loghttp
 import (
 	"time"
 
+	"github.com/prometheus/prometheus/promql"
+
 	"github.com/grafana/loki/v3/pkg/logproto"
 )
 
@@ -10,10 +12,17 @@ import (
 type DroppedEntry struct {
 	Timestamp time.Time
 	Labels    string
+	// Count is the number of lines of the stream dropped up to Timestamp,
+	// it is 0 for a single dropped entry.
+	Count uint64 `json:",omitempty"`
 }
 
 // TailResponse represents the http json response to a tail query
 type TailResponse struct {
 	Streams        []logproto.Stream `json:"streams"`
 	DroppedEntries []DroppedEntry    `json:"dropped_entries"`
+
+	// Vector is the result of a step of a metric tail, it is non-nil for
+	// metric tails only.
+	Vector promql.Vector `json:"-"`
 }
@@ -142,6 +142,49 @@ func tailDelay(r *http.Request) (uint32, error) {
 	return uint32(l), nil
 }
 
+// tailStep returns the step of metric tails, which defaults to and can't be
+// lower than one second.
+func tailStep(r *http.Request) (time.Duration, error) {
+	value := r.Form.Get("step")
+	if value == "" {
+		return time.Second, nil
+	}
+	d, err := parseSecondsOrDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, errors.New("step of metric tails must be at least 1s")
+	}
+	return d, nil
+}
+
+func tailSampleRatio(r *http.Request) (float64, error) {
+	value := r.Form.Get("sample_ratio")
+	if value == "" {
+		return 0, nil
+	}
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if ratio <= 0 || ratio > 1 {
+		return 0, errors.New("sample_ratio must be greater than 0 and less than or equal to 1")
+	}
+	return ratio, nil
+}
+
+func tailMaxLinesPerSecond(r *http.Request) (uint32, error) {
+	l, err := parseInt(r.Form.Get("max_lines_per_second"), 0)
+	if err != nil {
+		return 0, err
+	}
+	if l < 0 {
+		return 0, errors.New("max_lines_per_second must be a positive value")
+	}
+	return uint32(l), nil
+}
+
 // parseInt parses an int from a string
 // if the value is empty it returns a default value passed as second parameter
 func parseInt(value string, def int) (int, error) {
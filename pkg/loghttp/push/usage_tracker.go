@@ -15,3 +15,37 @@ type UsageTracker interface {
 	// DiscardedBytesAdd records discarded bytes by tenant and labels.
 	DiscardedBytesAdd(ctx context.Context, tenant, reason string, labels labels.Labels, value float64)
 }
+
+type multiUsageTracker []UsageTracker
+
+// NewMultiUsageTracker returns a UsageTracker that forwards usage to all the
+// given non-nil trackers. It returns nil if none of the trackers is set.
+func NewMultiUsageTracker(trackers ...UsageTracker) UsageTracker {
+	var m multiUsageTracker
+	for _, t := range trackers {
+		if t != nil {
+			m = append(m, t)
+		}
+	}
+
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+func (m multiUsageTracker) ReceivedBytesAdd(ctx context.Context, tenant string, retentionPeriod time.Duration, labels labels.Labels, value float64) {
+	for _, t := range m {
+		t.ReceivedBytesAdd(ctx, tenant, retentionPeriod, labels, value)
+	}
+}
+
+func (m multiUsageTracker) DiscardedBytesAdd(ctx context.Context, tenant, reason string, labels labels.Labels, value float64) {
+	for _, t := range m {
+		t.DiscardedBytesAdd(ctx, tenant, reason, labels, value)
+	}
+}
@@ -77,6 +77,21 @@ func (q *QueryResponse) UnmarshalJSON(data []byte) error {
 	})
 }
 
+// NDJSONContentType is the content type of streamed query responses. Clients
+// request it with the Accept header of a range query.
+const NDJSONContentType = "application/x-ndjson"
+
+// QueryResponseFrame is a line of a streamed log query response. Frames with
+// streams hold the entries in the direction of the query and are followed by
+// a single frame with the status, the warnings and the statistics of the query.
+type QueryResponseFrame struct {
+	Status   string       `json:"status,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+	Streams  Streams      `json:"streams,omitempty"`
+	Stats    stats.Result `json:"stats"`
+}
+
 func unescapeJSONString(b []byte) string {
 	var stackbuf [unescapeStackBufSize]byte // stack-allocated array for allocation-free unescaping of small strings
 	bU, err := jsonparser.Unescape(b, stackbuf[:])
@@ -0,0 +1,46 @@
+package loghttp
+
+import "time"
+
+// QueryJobState is the state of an asynchronous query job.
+type QueryJobState string
+
+const (
+	QueryJobQueued    QueryJobState = "queued"
+	QueryJobRunning   QueryJobState = "running"
+	QueryJobSucceeded QueryJobState = "succeeded"
+	QueryJobFailed    QueryJobState = "failed"
+	QueryJobCancelled QueryJobState = "cancelled"
+)
+
+// Done returns whether the job has finished, successfully or not.
+func (s QueryJobState) Done() bool {
+	return s == QueryJobSucceeded || s == QueryJobFailed || s == QueryJobCancelled
+}
+
+// QueryJob is the status of an asynchronous query job. The results of the job
+// are stored in pages, each holding the response of a split of the query.
+type QueryJob struct {
+	ID        string        `json:"id"`
+	Query     string        `json:"query"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"`
+	Step      time.Duration `json:"step,omitempty"`
+	Interval  time.Duration `json:"interval,omitempty"`
+	Limit     uint32        `json:"limit"`
+	Direction string        `json:"direction"`
+
+	State           QueryJobState `json:"state"`
+	Error           string        `json:"error,omitempty"`
+	Splits          int           `json:"splits"`
+	CompletedSplits int           `json:"completed_splits"`
+	Pages           int           `json:"pages"`
+	Entries         int64         `json:"entries"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// QueryJobsResponse lists the query jobs of a tenant.
+type QueryJobsResponse struct {
+	Jobs []QueryJob `json:"jobs"`
+}
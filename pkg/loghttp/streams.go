@@ -0,0 +1,157 @@
+package loghttp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// Fields the streams can be sorted by, in descending order.
+const (
+	StreamsSortByStreams  = "streams"
+	StreamsSortByEntries  = "entries"
+	StreamsSortByBytes    = "bytes"
+	StreamsSortByChunks   = "chunks"
+	StreamsSortByRate     = "rate"
+	StreamsSortByLastPush = "last_push"
+)
+
+// StreamsResponse represents the http json response to a streams inspection
+// query.
+type StreamsResponse struct {
+	Streams []StreamStats `json:"streams"`
+}
+
+// StreamStats are the stats of a stream held in memory by ingesters, or of a
+// group of streams when grouping by labels.
+type StreamStats struct {
+	// Labels are the labels of the stream, or the grouping labels of the group.
+	Labels   LabelSet  `json:"labels"`
+	Streams  int       `json:"streams"`
+	Entries  uint64    `json:"entries"`
+	Bytes    uint64    `json:"bytes"`
+	Chunks   uint32    `json:"chunks"`
+	LastPush time.Time `json:"last_push"`
+	// Rate is the number of bytes pushed per second.
+	Rate int64 `json:"rate"`
+}
+
+// StreamsQuery lists the streams held in memory by ingesters.
+type StreamsQuery struct {
+	Selector string
+	SortBy   string
+	GroupBy  []string
+	Limit    int
+}
+
+// ParseStreamsQuery parses a streams inspection query.
+func ParseStreamsQuery(r *http.Request) (*StreamsQuery, error) {
+	q := &StreamsQuery{
+		Selector: r.Form.Get("selector"),
+		SortBy:   r.Form.Get("sort_by"),
+	}
+	if q.Selector != "" {
+		if _, err := syntax.ParseMatchers(q.Selector, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, group := range r.Form["group_by"] {
+		for _, name := range strings.Split(group, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				q.GroupBy = append(q.GroupBy, name)
+			}
+		}
+	}
+
+	switch q.SortBy {
+	case "":
+		q.SortBy = StreamsSortByBytes
+		if len(q.GroupBy) > 0 {
+			q.SortBy = StreamsSortByStreams
+		}
+	case StreamsSortByStreams, StreamsSortByEntries, StreamsSortByBytes, StreamsSortByChunks, StreamsSortByRate, StreamsSortByLastPush:
+	default:
+		return nil, fmt.Errorf("invalid sort_by %q", q.SortBy)
+	}
+
+	limit, err := parseInt(r.Form.Get("limit"), defaultQueryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be a positive value")
+	}
+	q.Limit = limit
+	return q, nil
+}
+
+// Aggregate groups the streams by the grouping labels of the query, and
+// returns the top groups according to its sort order.
+func (q *StreamsQuery) Aggregate(streams []*logproto.StreamStats) ([]StreamStats, error) {
+	groups := map[string]*StreamStats{}
+	for _, s := range streams {
+		lbs, err := syntax.ParseLabels(s.Labels)
+		if err != nil {
+			return nil, err
+		}
+
+		key := s.Labels
+		if len(q.GroupBy) > 0 {
+			lbs = labels.NewBuilder(lbs).Keep(q.GroupBy...).Labels()
+			key = lbs.String()
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &StreamStats{Labels: LabelSet(lbs.Map())}
+			groups[key] = g
+		}
+		g.Streams++
+		g.Entries += s.Entries
+		g.Bytes += s.Bytes
+		g.Chunks += s.Chunks
+		g.Rate += s.Rate
+		if s.LastPush.After(g.LastPush) {
+			g.LastPush = s.LastPush
+		}
+	}
+
+	result := make([]StreamStats, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		var less, greater bool
+		switch q.SortBy {
+		case StreamsSortByStreams:
+			less, greater = a.Streams < b.Streams, a.Streams > b.Streams
+		case StreamsSortByEntries:
+			less, greater = a.Entries < b.Entries, a.Entries > b.Entries
+		case StreamsSortByChunks:
+			less, greater = a.Chunks < b.Chunks, a.Chunks > b.Chunks
+		case StreamsSortByRate:
+			less, greater = a.Rate < b.Rate, a.Rate > b.Rate
+		case StreamsSortByLastPush:
+			less, greater = a.LastPush.Before(b.LastPush), a.LastPush.After(b.LastPush)
+		default:
+			less, greater = a.Bytes < b.Bytes, a.Bytes > b.Bytes
+		}
+		if less || greater {
+			return greater
+		}
+		return a.Labels.String() < b.Labels.String()
+	})
+	if len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package loghttp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestParseStreamsQuery(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		form      url.Values
+		shouldErr bool
+		expected  *StreamsQuery
+	}{
+		{
+			"defaults",
+			url.Values{},
+			false,
+			&StreamsQuery{SortBy: StreamsSortByBytes, Limit: 100},
+		},
+		{
+			"grouped",
+			url.Values{
+				"selector": []string{`{cluster="prod"}`},
+				"group_by": []string{"namespace, app", "pod"},
+				"limit":    []string{"10"},
+			},
+			false,
+			&StreamsQuery{Selector: `{cluster="prod"}`, SortBy: StreamsSortByStreams, GroupBy: []string{"namespace", "app", "pod"}, Limit: 10},
+		},
+		{
+			"sorted",
+			url.Values{"sort_by": []string{"rate"}},
+			false,
+			&StreamsQuery{SortBy: StreamsSortByRate, Limit: 100},
+		},
+		{
+			"invalid sort",
+			url.Values{"sort_by": []string{"labels"}},
+			true,
+			nil,
+		},
+		{
+			"invalid selector",
+			url.Values{"selector": []string{`{cluster=}`}},
+			true,
+			nil,
+		},
+		{
+			"invalid limit",
+			url.Values{"limit": []string{"0"}},
+			true,
+			nil,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			out, err := ParseStreamsQuery(withForm(tc.form))
+			if tc.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestStreamsQuery_Aggregate(t *testing.T) {
+	lastPush := time.Unix(100, 0).UTC()
+	streams := []*logproto.StreamStats{
+		{Labels: `{app="a", pod="1"}`, Entries: 1, Bytes: 10, Chunks: 1, LastPush: lastPush, Rate: 1},
+		{Labels: `{app="a", pod="2"}`, Entries: 2, Bytes: 20, Chunks: 1, LastPush: lastPush.Add(time.Second), Rate: 2},
+		{Labels: `{app="b", pod="3"}`, Entries: 5, Bytes: 50, Chunks: 2, LastPush: lastPush, Rate: 5},
+		{Labels: `{pod="4"}`, Entries: 1, Bytes: 1, Chunks: 1, LastPush: lastPush, Rate: 0},
+	}
+
+	t.Run("streams", func(t *testing.T) {
+		out, err := (&StreamsQuery{SortBy: StreamsSortByBytes, Limit: 2}).Aggregate(streams)
+		require.NoError(t, err)
+		require.Equal(t, []StreamStats{
+			{Labels: LabelSet{"app": "b", "pod": "3"}, Streams: 1, Entries: 5, Bytes: 50, Chunks: 2, LastPush: lastPush, Rate: 5},
+			{Labels: LabelSet{"app": "a", "pod": "2"}, Streams: 1, Entries: 2, Bytes: 20, Chunks: 1, LastPush: lastPush.Add(time.Second), Rate: 2},
+		}, out)
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		out, err := (&StreamsQuery{SortBy: StreamsSortByStreams, GroupBy: []string{"app"}, Limit: 10}).Aggregate(streams)
+		require.NoError(t, err)
+		require.Equal(t, []StreamStats{
+			{Labels: LabelSet{"app": "a"}, Streams: 2, Entries: 3, Bytes: 30, Chunks: 2, LastPush: lastPush.Add(time.Second), Rate: 3},
+			{Labels: LabelSet{"app": "b"}, Streams: 1, Entries: 5, Bytes: 50, Chunks: 2, LastPush: lastPush, Rate: 5},
+			{Labels: LabelSet{}, Streams: 1, Entries: 1, Bytes: 1, Chunks: 1, LastPush: lastPush, Rate: 0},
+		}, out)
+	})
+}
@@ -23,12 +23,20 @@ const (
 type TailResponse struct {
 	Streams        []Stream        `json:"streams,omitempty"`
 	DroppedStreams []DroppedStream `json:"dropped_entries,omitempty"`
+
+	// ResultType is set to ResultTypeVector by metric tails, which send the
+	// vector of each step as Result instead of streams.
+	ResultType ResultType `json:"resultType,omitempty"`
+	Result     Vector     `json:"result,omitempty"`
 }
 
 // DroppedStream represents a dropped stream in tail call
 type DroppedStream struct {
 	Timestamp time.Time
 	Labels    LabelSet
+	// Count is the number of lines of the stream dropped up to Timestamp,
+	// when the dropped lines are counted rather than reported one by one.
+	Count uint64
 }
 
 // MarshalJSON implements json.Marshaller
@@ -36,9 +44,11 @@ func (s *DroppedStream) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Timestamp string   `json:"timestamp"`
 		Labels    LabelSet `json:"labels,omitempty"`
+		Count     uint64   `json:"count,omitempty"`
 	}{
 		Timestamp: fmt.Sprintf("%d", s.Timestamp.UnixNano()),
 		Labels:    s.Labels,
+		Count:     s.Count,
 	})
 }
 
@@ -47,6 +57,7 @@ func (s *DroppedStream) UnmarshalJSON(data []byte) error {
 	unmarshal := struct {
 		Timestamp string   `json:"timestamp"`
 		Labels    LabelSet `json:"labels,omitempty"`
+		Count     uint64   `json:"count,omitempty"`
 	}{}
 
 	err := json.Unmarshal(data, &unmarshal)
@@ -62,6 +73,7 @@ func (s *DroppedStream) UnmarshalJSON(data []byte) error {
 
 	s.Timestamp = time.Unix(0, t)
 	s.Labels = unmarshal.Labels
+	s.Count = unmarshal.Count
 
 	return nil
 }
@@ -102,5 +114,20 @@ func ParseTailQuery(r *http.Request) (*logproto.TailRequest, error) {
 	if req.DelayFor > maxDelayForInTailing {
 		return nil, fmt.Errorf("delay_for can't be greater than %d", maxDelayForInTailing)
 	}
+	req.SampleRatio, err = tailSampleRatio(r)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	req.MaxLinesPerSecond, err = tailMaxLinesPerSecond(r)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	if _, ok := parsed.(syntax.SampleExpr); ok {
+		step, err := tailStep(r)
+		if err != nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+		}
+		req.Step = step.Milliseconds()
+	}
 	return &req, nil
 }
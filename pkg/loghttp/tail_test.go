@@ -44,6 +44,27 @@ func TestParseTailQuery(t *testing.T) {
 					AST: syntax.MustParseExpr(`{foo="bar"}`),
 				},
 			}, false},
+		{"bad sample ratio",
+			&http.Request{
+				URL: mustParseURL(`?query={foo="bar"}&start=2017-06-10T21:42:24.760738998Z&sample_ratio=2`),
+			}, nil, true},
+		{"bad max lines per second",
+			&http.Request{
+				URL: mustParseURL(`?query={foo="bar"}&start=2017-06-10T21:42:24.760738998Z&max_lines_per_second=-1`),
+			}, nil, true},
+		{"sampled",
+			&http.Request{
+				URL: mustParseURL(`?query={foo="bar"} | logfmt | level="error"&start=2017-06-10T21:42:24.760738998Z&limit=1000&sample_ratio=0.1&max_lines_per_second=100`),
+			}, &logproto.TailRequest{
+				Query:             `{foo="bar"} | logfmt | level="error"`,
+				Start:             time.Date(2017, 06, 10, 21, 42, 24, 760738998, time.UTC),
+				Limit:             1000,
+				SampleRatio:       0.1,
+				MaxLinesPerSecond: 100,
+				Plan: &plan.QueryPlan{
+					AST: syntax.MustParseExpr(`{foo="bar"} | logfmt | level="error"`),
+				},
+			}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -303,6 +303,148 @@ func (m *StreamRate) GetPushes() uint32 {
 	return 0
 }
 
+type StreamChunksRequest struct {
+	Labels  string                                  `protobuf:"bytes,1,opt,name=labels,proto3" json:"labels,omitempty"`
+	From    github_com_prometheus_common_model.Time `protobuf:"varint,2,opt,name=from,proto3,customtype=github.com/prometheus/common/model.Time" json:"from"`
+	Through github_com_prometheus_common_model.Time `protobuf:"varint,3,opt,name=through,proto3,customtype=github.com/prometheus/common/model.Time" json:"through"`
+}
+
+func (m *StreamChunksRequest) Reset()      { *m = StreamChunksRequest{} }
+func (*StreamChunksRequest) ProtoMessage() {}
+func (*StreamChunksRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{5}
+}
+func (m *StreamChunksRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamChunksRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamChunksRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamChunksRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamChunksRequest.Merge(m, src)
+}
+func (m *StreamChunksRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamChunksRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamChunksRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamChunksRequest proto.InternalMessageInfo
+
+func (m *StreamChunksRequest) GetLabels() string {
+	if m != nil {
+		return m.Labels
+	}
+	return ""
+}
+
+type StreamChunksResponse struct {
+	Chunks []StreamChunk `protobuf:"bytes,1,rep,name=chunks,proto3" json:"chunks"`
+}
+
+func (m *StreamChunksResponse) Reset()      { *m = StreamChunksResponse{} }
+func (*StreamChunksResponse) ProtoMessage() {}
+func (*StreamChunksResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{6}
+}
+func (m *StreamChunksResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamChunksResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamChunksResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamChunksResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamChunksResponse.Merge(m, src)
+}
+func (m *StreamChunksResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamChunksResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamChunksResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamChunksResponse proto.InternalMessageInfo
+
+func (m *StreamChunksResponse) GetChunks() []StreamChunk {
+	if m != nil {
+		return m.Chunks
+	}
+	return nil
+}
+
+type StreamChunk struct {
+	From    github_com_prometheus_common_model.Time `protobuf:"varint,1,opt,name=from,proto3,customtype=github.com/prometheus/common/model.Time" json:"from"`
+	Through github_com_prometheus_common_model.Time `protobuf:"varint,2,opt,name=through,proto3,customtype=github.com/prometheus/common/model.Time" json:"through"`
+	// checksum of the stored chunk, only set once it's flushed.
+	Checksum uint32 `protobuf:"varint,3,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	Flushed  bool   `protobuf:"varint,4,opt,name=flushed,proto3" json:"flushed,omitempty"`
+}
+
+func (m *StreamChunk) Reset()      { *m = StreamChunk{} }
+func (*StreamChunk) ProtoMessage() {}
+func (*StreamChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{7}
+}
+func (m *StreamChunk) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamChunk.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamChunk.Merge(m, src)
+}
+func (m *StreamChunk) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamChunk proto.InternalMessageInfo
+
+func (m *StreamChunk) GetChecksum() uint32 {
+	if m != nil {
+		return m.Checksum
+	}
+	return 0
+}
+
+func (m *StreamChunk) GetFlushed() bool {
+	if m != nil {
+		return m.Flushed
+	}
+	return false
+}
+
 type QueryRequest struct {
 	Selector  string                                                 `protobuf:"bytes,1,opt,name=selector,proto3" json:"selector,omitempty"` // Deprecated: Do not use.
 	Limit     uint32                                                 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
@@ -317,7 +459,7 @@ type QueryRequest struct {
 func (m *QueryRequest) Reset()      { *m = QueryRequest{} }
 func (*QueryRequest) ProtoMessage() {}
 func (*QueryRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{5}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{8}
 }
 func (m *QueryRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -408,7 +550,7 @@ type SampleQueryRequest struct {
 func (m *SampleQueryRequest) Reset()      { *m = SampleQueryRequest{} }
 func (*SampleQueryRequest) ProtoMessage() {}
 func (*SampleQueryRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{6}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{9}
 }
 func (m *SampleQueryRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -481,7 +623,7 @@ type Plan struct {
 func (m *Plan) Reset()      { *m = Plan{} }
 func (*Plan) ProtoMessage() {}
 func (*Plan) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{7}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{10}
 }
 func (m *Plan) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -526,7 +668,7 @@ type Delete struct {
 func (m *Delete) Reset()      { *m = Delete{} }
 func (*Delete) ProtoMessage() {}
 func (*Delete) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{8}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{11}
 }
 func (m *Delete) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -585,7 +727,7 @@ type QueryResponse struct {
 func (m *QueryResponse) Reset()      { *m = QueryResponse{} }
 func (*QueryResponse) ProtoMessage() {}
 func (*QueryResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{9}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{12}
 }
 func (m *QueryResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -637,7 +779,7 @@ type SampleQueryResponse struct {
 func (m *SampleQueryResponse) Reset()      { *m = SampleQueryResponse{} }
 func (*SampleQueryResponse) ProtoMessage() {}
 func (*SampleQueryResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{10}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{13}
 }
 func (m *SampleQueryResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -691,7 +833,7 @@ type LabelRequest struct {
 func (m *LabelRequest) Reset()      { *m = LabelRequest{} }
 func (*LabelRequest) ProtoMessage() {}
 func (*LabelRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{11}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{14}
 }
 func (m *LabelRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -762,7 +904,7 @@ type LabelResponse struct {
 func (m *LabelResponse) Reset()      { *m = LabelResponse{} }
 func (*LabelResponse) ProtoMessage() {}
 func (*LabelResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{12}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{15}
 }
 func (m *LabelResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -807,7 +949,7 @@ type Sample struct {
 func (m *Sample) Reset()      { *m = Sample{} }
 func (*Sample) ProtoMessage() {}
 func (*Sample) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{13}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{16}
 }
 func (m *Sample) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -866,7 +1008,7 @@ type LegacySample struct {
 func (m *LegacySample) Reset()      { *m = LegacySample{} }
 func (*LegacySample) ProtoMessage() {}
 func (*LegacySample) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{14}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{17}
 }
 func (m *LegacySample) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -918,7 +1060,7 @@ type Series struct {
 func (m *Series) Reset()      { *m = Series{} }
 func (*Series) ProtoMessage() {}
 func (*Series) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{15}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{18}
 }
 func (m *Series) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -974,12 +1116,19 @@ type TailRequest struct {
 	Limit    uint32                                                 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
 	Start    time.Time                                              `protobuf:"bytes,5,opt,name=start,proto3,stdtime" json:"start"`
 	Plan     *github_com_grafana_loki_v3_pkg_querier_plan.QueryPlan `protobuf:"bytes,6,opt,name=plan,proto3,customtype=github.com/grafana/loki/v3/pkg/querier/plan.QueryPlan" json:"plan,omitempty"`
+	// sampleRatio is the share of the entries to keep, 0 keeps all of them.
+	SampleRatio float64 `protobuf:"fixed64,7,opt,name=sampleRatio,proto3" json:"sampleRatio,omitempty"`
+	// maxLinesPerSecond is the budget of lines sent per second, 0 is unlimited.
+	// It is enforced by the querier, the ingesters ignore it.
+	MaxLinesPerSecond uint32 `protobuf:"varint,8,opt,name=maxLinesPerSecond,proto3" json:"maxLinesPerSecond,omitempty"`
+	// step is the interval between the vectors of metric tails, in milliseconds.
+	Step int64 `protobuf:"varint,9,opt,name=step,proto3" json:"step,omitempty"`
 }
 
 func (m *TailRequest) Reset()      { *m = TailRequest{} }
 func (*TailRequest) ProtoMessage() {}
 func (*TailRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{16}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{19}
 }
 func (m *TailRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1037,15 +1186,39 @@ func (m *TailRequest) GetStart() time.Time {
 	return time.Time{}
 }
 
+func (m *TailRequest) GetSampleRatio() float64 {
+	if m != nil {
+		return m.SampleRatio
+	}
+	return 0
+}
+
+func (m *TailRequest) GetMaxLinesPerSecond() uint32 {
+	if m != nil {
+		return m.MaxLinesPerSecond
+	}
+	return 0
+}
+
+func (m *TailRequest) GetStep() int64 {
+	if m != nil {
+		return m.Step
+	}
+	return 0
+}
+
 type TailResponse struct {
 	Stream         *github_com_grafana_loki_pkg_push.Stream `protobuf:"bytes,1,opt,name=stream,proto3,customtype=github.com/grafana/loki/pkg/push.Stream" json:"stream,omitempty"`
 	DroppedStreams []*DroppedStream                         `protobuf:"bytes,2,rep,name=droppedStreams,proto3" json:"droppedStreams,omitempty"`
+	// series is the vector of the range aggregation of metric tails, with one
+	// sample per series.
+	Series []Series `protobuf:"bytes,3,rep,name=series,proto3" json:"series"`
 }
 
 func (m *TailResponse) Reset()      { *m = TailResponse{} }
 func (*TailResponse) ProtoMessage() {}
 func (*TailResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{17}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{20}
 }
 func (m *TailResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1081,6 +1254,13 @@ func (m *TailResponse) GetDroppedStreams() []*DroppedStream {
 	return nil
 }
 
+func (m *TailResponse) GetSeries() []Series {
+	if m != nil {
+		return m.Series
+	}
+	return nil
+}
+
 type SeriesRequest struct {
 	Start  time.Time `protobuf:"bytes,1,opt,name=start,proto3,stdtime" json:"start"`
 	End    time.Time `protobuf:"bytes,2,opt,name=end,proto3,stdtime" json:"end"`
@@ -1091,7 +1271,7 @@ type SeriesRequest struct {
 func (m *SeriesRequest) Reset()      { *m = SeriesRequest{} }
 func (*SeriesRequest) ProtoMessage() {}
 func (*SeriesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{18}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{21}
 }
 func (m *SeriesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1155,7 +1335,7 @@ type SeriesResponse struct {
 func (m *SeriesResponse) Reset()      { *m = SeriesResponse{} }
 func (*SeriesResponse) ProtoMessage() {}
 func (*SeriesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{19}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{22}
 }
 func (m *SeriesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1198,7 +1378,7 @@ type SeriesIdentifier struct {
 func (m *SeriesIdentifier) Reset()      { *m = SeriesIdentifier{} }
 func (*SeriesIdentifier) ProtoMessage() {}
 func (*SeriesIdentifier) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{20}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{23}
 }
 func (m *SeriesIdentifier) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1242,7 +1422,7 @@ type SeriesIdentifier_LabelsEntry struct {
 func (m *SeriesIdentifier_LabelsEntry) Reset()      { *m = SeriesIdentifier_LabelsEntry{} }
 func (*SeriesIdentifier_LabelsEntry) ProtoMessage() {}
 func (*SeriesIdentifier_LabelsEntry) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{20, 0}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{23, 0}
 }
 func (m *SeriesIdentifier_LabelsEntry) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1286,15 +1466,16 @@ func (m *SeriesIdentifier_LabelsEntry) GetValue() string {
 }
 
 type DroppedStream struct {
-	From   time.Time `protobuf:"bytes,1,opt,name=from,proto3,stdtime" json:"from"`
-	To     time.Time `protobuf:"bytes,2,opt,name=to,proto3,stdtime" json:"to"`
-	Labels string    `protobuf:"bytes,3,opt,name=labels,proto3" json:"labels,omitempty"`
+	From         time.Time `protobuf:"bytes,1,opt,name=from,proto3,stdtime" json:"from"`
+	To           time.Time `protobuf:"bytes,2,opt,name=to,proto3,stdtime" json:"to"`
+	Labels       string    `protobuf:"bytes,3,opt,name=labels,proto3" json:"labels,omitempty"`
+	DroppedLines uint64    `protobuf:"varint,4,opt,name=droppedLines,proto3" json:"droppedLines,omitempty"`
 }
 
 func (m *DroppedStream) Reset()      { *m = DroppedStream{} }
 func (*DroppedStream) ProtoMessage() {}
 func (*DroppedStream) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{21}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{24}
 }
 func (m *DroppedStream) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1344,6 +1525,13 @@ func (m *DroppedStream) GetLabels() string {
 	return ""
 }
 
+func (m *DroppedStream) GetDroppedLines() uint64 {
+	if m != nil {
+		return m.DroppedLines
+	}
+	return 0
+}
+
 type LabelPair struct {
 	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
@@ -1352,7 +1540,7 @@ type LabelPair struct {
 func (m *LabelPair) Reset()      { *m = LabelPair{} }
 func (*LabelPair) ProtoMessage() {}
 func (*LabelPair) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{22}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{25}
 }
 func (m *LabelPair) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1405,7 +1593,7 @@ type LegacyLabelPair struct {
 func (m *LegacyLabelPair) Reset()      { *m = LegacyLabelPair{} }
 func (*LegacyLabelPair) ProtoMessage() {}
 func (*LegacyLabelPair) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{23}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{26}
 }
 func (m *LegacyLabelPair) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1455,7 +1643,7 @@ type Chunk struct {
 func (m *Chunk) Reset()      { *m = Chunk{} }
 func (*Chunk) ProtoMessage() {}
 func (*Chunk) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{24}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{27}
 }
 func (m *Chunk) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1497,7 +1685,7 @@ type TailersCountRequest struct {
 func (m *TailersCountRequest) Reset()      { *m = TailersCountRequest{} }
 func (*TailersCountRequest) ProtoMessage() {}
 func (*TailersCountRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{25}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{28}
 }
 func (m *TailersCountRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1533,7 +1721,7 @@ type TailersCountResponse struct {
 func (m *TailersCountResponse) Reset()      { *m = TailersCountResponse{} }
 func (*TailersCountResponse) ProtoMessage() {}
 func (*TailersCountResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{26}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{29}
 }
 func (m *TailersCountResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1578,7 +1766,7 @@ type GetChunkIDsRequest struct {
 func (m *GetChunkIDsRequest) Reset()      { *m = GetChunkIDsRequest{} }
 func (*GetChunkIDsRequest) ProtoMessage() {}
 func (*GetChunkIDsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{27}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{30}
 }
 func (m *GetChunkIDsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1635,7 +1823,7 @@ type GetChunkIDsResponse struct {
 func (m *GetChunkIDsResponse) Reset()      { *m = GetChunkIDsResponse{} }
 func (*GetChunkIDsResponse) ProtoMessage() {}
 func (*GetChunkIDsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{28}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{31}
 }
 func (m *GetChunkIDsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1688,7 +1876,7 @@ type ChunkRef struct {
 func (m *ChunkRef) Reset()      { *m = ChunkRef{} }
 func (*ChunkRef) ProtoMessage() {}
 func (*ChunkRef) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{29}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{32}
 }
 func (m *ChunkRef) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1749,7 +1937,7 @@ type LabelValuesForMetricNameRequest struct {
 func (m *LabelValuesForMetricNameRequest) Reset()      { *m = LabelValuesForMetricNameRequest{} }
 func (*LabelValuesForMetricNameRequest) ProtoMessage() {}
 func (*LabelValuesForMetricNameRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{30}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{33}
 }
 func (m *LabelValuesForMetricNameRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1808,7 +1996,7 @@ type LabelNamesForMetricNameRequest struct {
 func (m *LabelNamesForMetricNameRequest) Reset()      { *m = LabelNamesForMetricNameRequest{} }
 func (*LabelNamesForMetricNameRequest) ProtoMessage() {}
 func (*LabelNamesForMetricNameRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{31}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{34}
 }
 func (m *LabelNamesForMetricNameRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1852,7 +2040,7 @@ type LineFilter struct {
 func (m *LineFilter) Reset()      { *m = LineFilter{} }
 func (*LineFilter) ProtoMessage() {}
 func (*LineFilter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{32}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{35}
 }
 func (m *LineFilter) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1900,7 +2088,7 @@ type GetChunkRefRequest struct {
 func (m *GetChunkRefRequest) Reset()      { *m = GetChunkRefRequest{} }
 func (*GetChunkRefRequest) ProtoMessage() {}
 func (*GetChunkRefRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{33}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{36}
 }
 func (m *GetChunkRefRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1943,7 +2131,7 @@ type GetChunkRefResponse struct {
 func (m *GetChunkRefResponse) Reset()      { *m = GetChunkRefResponse{} }
 func (*GetChunkRefResponse) ProtoMessage() {}
 func (*GetChunkRefResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{34}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{37}
 }
 func (m *GetChunkRefResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1988,7 +2176,7 @@ type GetSeriesRequest struct {
 func (m *GetSeriesRequest) Reset()      { *m = GetSeriesRequest{} }
 func (*GetSeriesRequest) ProtoMessage() {}
 func (*GetSeriesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{35}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{38}
 }
 func (m *GetSeriesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2031,7 +2219,7 @@ type GetSeriesResponse struct {
 func (m *GetSeriesResponse) Reset()      { *m = GetSeriesResponse{} }
 func (*GetSeriesResponse) ProtoMessage() {}
 func (*GetSeriesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{36}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{39}
 }
 func (m *GetSeriesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2075,7 +2263,7 @@ type IndexSeries struct {
 func (m *IndexSeries) Reset()      { *m = IndexSeries{} }
 func (*IndexSeries) ProtoMessage() {}
 func (*IndexSeries) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{37}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{40}
 }
 func (m *IndexSeries) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2112,7 +2300,7 @@ type QueryIndexResponse struct {
 func (m *QueryIndexResponse) Reset()      { *m = QueryIndexResponse{} }
 func (*QueryIndexResponse) ProtoMessage() {}
 func (*QueryIndexResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{38}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{41}
 }
 func (m *QueryIndexResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2163,7 +2351,7 @@ type Row struct {
 func (m *Row) Reset()      { *m = Row{} }
 func (*Row) ProtoMessage() {}
 func (*Row) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{39}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{42}
 }
 func (m *Row) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2213,7 +2401,7 @@ type QueryIndexRequest struct {
 func (m *QueryIndexRequest) Reset()      { *m = QueryIndexRequest{} }
 func (*QueryIndexRequest) ProtoMessage() {}
 func (*QueryIndexRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{40}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{43}
 }
 func (m *QueryIndexRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2260,7 +2448,7 @@ type IndexQuery struct {
 func (m *IndexQuery) Reset()      { *m = IndexQuery{} }
 func (*IndexQuery) ProtoMessage() {}
 func (*IndexQuery) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{41}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{44}
 }
 func (m *IndexQuery) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2333,7 +2521,7 @@ type IndexStatsRequest struct {
 func (m *IndexStatsRequest) Reset()      { *m = IndexStatsRequest{} }
 func (*IndexStatsRequest) ProtoMessage() {}
 func (*IndexStatsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{42}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{45}
 }
 func (m *IndexStatsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2379,7 +2567,7 @@ type IndexStatsResponse struct {
 func (m *IndexStatsResponse) Reset()      { *m = IndexStatsResponse{} }
 func (*IndexStatsResponse) ProtoMessage() {}
 func (*IndexStatsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{43}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{46}
 }
 func (m *IndexStatsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2449,7 +2637,7 @@ type VolumeRequest struct {
 func (m *VolumeRequest) Reset()      { *m = VolumeRequest{} }
 func (*VolumeRequest) ProtoMessage() {}
 func (*VolumeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{44}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{47}
 }
 func (m *VolumeRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2521,7 +2709,7 @@ type VolumeResponse struct {
 func (m *VolumeResponse) Reset()      { *m = VolumeResponse{} }
 func (*VolumeResponse) ProtoMessage() {}
 func (*VolumeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{45}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{48}
 }
 func (m *VolumeResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2572,7 +2760,7 @@ type Volume struct {
 func (m *Volume) Reset()      { *m = Volume{} }
 func (*Volume) ProtoMessage() {}
 func (*Volume) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{46}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{49}
 }
 func (m *Volume) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2627,7 +2815,7 @@ type DetectedFieldsRequest struct {
 func (m *DetectedFieldsRequest) Reset()      { *m = DetectedFieldsRequest{} }
 func (*DetectedFieldsRequest) ProtoMessage() {}
 func (*DetectedFieldsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{47}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{50}
 }
 func (m *DetectedFieldsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2706,7 +2894,7 @@ type DetectedFieldsResponse struct {
 func (m *DetectedFieldsResponse) Reset()      { *m = DetectedFieldsResponse{} }
 func (*DetectedFieldsResponse) ProtoMessage() {}
 func (*DetectedFieldsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{48}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{51}
 }
 func (m *DetectedFieldsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2761,7 +2949,7 @@ type DetectedField struct {
 func (m *DetectedField) Reset()      { *m = DetectedField{} }
 func (*DetectedField) ProtoMessage() {}
 func (*DetectedField) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{49}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{52}
 }
 func (m *DetectedField) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2827,7 +3015,7 @@ type DetectedLabelsRequest struct {
 func (m *DetectedLabelsRequest) Reset()      { *m = DetectedLabelsRequest{} }
 func (*DetectedLabelsRequest) ProtoMessage() {}
 func (*DetectedLabelsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{50}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{53}
 }
 func (m *DetectedLabelsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2884,7 +3072,7 @@ type DetectedLabelsResponse struct {
 func (m *DetectedLabelsResponse) Reset()      { *m = DetectedLabelsResponse{} }
 func (*DetectedLabelsResponse) ProtoMessage() {}
 func (*DetectedLabelsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{51}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{54}
 }
 func (m *DetectedLabelsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2928,7 +3116,7 @@ type DetectedLabel struct {
 func (m *DetectedLabel) Reset()      { *m = DetectedLabel{} }
 func (*DetectedLabel) ProtoMessage() {}
 func (*DetectedLabel) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{52}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{55}
 }
 func (m *DetectedLabel) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2971,53 +3159,230 @@ func (m *DetectedLabel) GetCardinality() uint64 {
 	return 0
 }
 
-func init() {
-	proto.RegisterEnum("logproto.Direction", Direction_name, Direction_value)
-	proto.RegisterType((*LabelToValuesResponse)(nil), "logproto.LabelToValuesResponse")
-	proto.RegisterMapType((map[string]*UniqueLabelValues)(nil), "logproto.LabelToValuesResponse.LabelsEntry")
-	proto.RegisterType((*UniqueLabelValues)(nil), "logproto.UniqueLabelValues")
-	proto.RegisterType((*StreamRatesRequest)(nil), "logproto.StreamRatesRequest")
-	proto.RegisterType((*StreamRatesResponse)(nil), "logproto.StreamRatesResponse")
-	proto.RegisterType((*StreamRate)(nil), "logproto.StreamRate")
-	proto.RegisterType((*QueryRequest)(nil), "logproto.QueryRequest")
-	proto.RegisterType((*SampleQueryRequest)(nil), "logproto.SampleQueryRequest")
-	proto.RegisterType((*Plan)(nil), "logproto.Plan")
-	proto.RegisterType((*Delete)(nil), "logproto.Delete")
-	proto.RegisterType((*QueryResponse)(nil), "logproto.QueryResponse")
-	proto.RegisterType((*SampleQueryResponse)(nil), "logproto.SampleQueryResponse")
-	proto.RegisterType((*LabelRequest)(nil), "logproto.LabelRequest")
-	proto.RegisterType((*LabelResponse)(nil), "logproto.LabelResponse")
-	proto.RegisterType((*Sample)(nil), "logproto.Sample")
-	proto.RegisterType((*LegacySample)(nil), "logproto.LegacySample")
-	proto.RegisterType((*Series)(nil), "logproto.Series")
-	proto.RegisterType((*TailRequest)(nil), "logproto.TailRequest")
-	proto.RegisterType((*TailResponse)(nil), "logproto.TailResponse")
-	proto.RegisterType((*SeriesRequest)(nil), "logproto.SeriesRequest")
-	proto.RegisterType((*SeriesResponse)(nil), "logproto.SeriesResponse")
-	proto.RegisterType((*SeriesIdentifier)(nil), "logproto.SeriesIdentifier")
-	proto.RegisterType((*SeriesIdentifier_LabelsEntry)(nil), "logproto.SeriesIdentifier.LabelsEntry")
-	proto.RegisterType((*DroppedStream)(nil), "logproto.DroppedStream")
-	proto.RegisterType((*LabelPair)(nil), "logproto.LabelPair")
-	proto.RegisterType((*LegacyLabelPair)(nil), "logproto.LegacyLabelPair")
-	proto.RegisterType((*Chunk)(nil), "logproto.Chunk")
-	proto.RegisterType((*TailersCountRequest)(nil), "logproto.TailersCountRequest")
-	proto.RegisterType((*TailersCountResponse)(nil), "logproto.TailersCountResponse")
-	proto.RegisterType((*GetChunkIDsRequest)(nil), "logproto.GetChunkIDsRequest")
-	proto.RegisterType((*GetChunkIDsResponse)(nil), "logproto.GetChunkIDsResponse")
-	proto.RegisterType((*ChunkRef)(nil), "logproto.ChunkRef")
-	proto.RegisterType((*LabelValuesForMetricNameRequest)(nil), "logproto.LabelValuesForMetricNameRequest")
-	proto.RegisterType((*LabelNamesForMetricNameRequest)(nil), "logproto.LabelNamesForMetricNameRequest")
-	proto.RegisterType((*LineFilter)(nil), "logproto.LineFilter")
-	proto.RegisterType((*GetChunkRefRequest)(nil), "logproto.GetChunkRefRequest")
-	proto.RegisterType((*GetChunkRefResponse)(nil), "logproto.GetChunkRefResponse")
-	proto.RegisterType((*GetSeriesRequest)(nil), "logproto.GetSeriesRequest")
-	proto.RegisterType((*GetSeriesResponse)(nil), "logproto.GetSeriesResponse")
-	proto.RegisterType((*IndexSeries)(nil), "logproto.IndexSeries")
-	proto.RegisterType((*QueryIndexResponse)(nil), "logproto.QueryIndexResponse")
-	proto.RegisterType((*Row)(nil), "logproto.Row")
-	proto.RegisterType((*QueryIndexRequest)(nil), "logproto.QueryIndexRequest")
-	proto.RegisterType((*IndexQuery)(nil), "logproto.IndexQuery")
-	proto.RegisterType((*IndexStatsRequest)(nil), "logproto.IndexStatsRequest")
+type StreamStatsRequest struct {
+	// matchers restricts the streams returned to the ones matching the
+	// selector, if set.
+	Matchers string `protobuf:"bytes,1,opt,name=matchers,proto3" json:"matchers,omitempty"`
+}
+
+func (m *StreamStatsRequest) Reset()      { *m = StreamStatsRequest{} }
+func (*StreamStatsRequest) ProtoMessage() {}
+func (*StreamStatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{56}
+}
+func (m *StreamStatsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamStatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamStatsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamStatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamStatsRequest.Merge(m, src)
+}
+func (m *StreamStatsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamStatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamStatsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamStatsRequest proto.InternalMessageInfo
+
+func (m *StreamStatsRequest) GetMatchers() string {
+	if m != nil {
+		return m.Matchers
+	}
+	return ""
+}
+
+type StreamStatsResponse struct {
+	Streams []*StreamStats `protobuf:"bytes,1,rep,name=streams,proto3" json:"streams,omitempty"`
+}
+
+func (m *StreamStatsResponse) Reset()      { *m = StreamStatsResponse{} }
+func (*StreamStatsResponse) ProtoMessage() {}
+func (*StreamStatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{57}
+}
+func (m *StreamStatsResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamStatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamStatsResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamStatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamStatsResponse.Merge(m, src)
+}
+func (m *StreamStatsResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamStatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamStatsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamStatsResponse proto.InternalMessageInfo
+
+func (m *StreamStatsResponse) GetStreams() []*StreamStats {
+	if m != nil {
+		return m.Streams
+	}
+	return nil
+}
+
+type StreamStats struct {
+	Labels string `protobuf:"bytes,1,opt,name=labels,proto3" json:"labels,omitempty"`
+	// entries and bytes are the number of entries and uncompressed bytes held
+	// in memory.
+	Entries  uint64    `protobuf:"varint,2,opt,name=entries,proto3" json:"entries,omitempty"`
+	Bytes    uint64    `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Chunks   uint32    `protobuf:"varint,4,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	LastPush time.Time `protobuf:"bytes,5,opt,name=lastPush,proto3,stdtime" json:"lastPush"`
+	// rate is the number of bytes pushed per second.
+	Rate int64 `protobuf:"varint,6,opt,name=rate,proto3" json:"rate,omitempty"`
+}
+
+func (m *StreamStats) Reset()      { *m = StreamStats{} }
+func (*StreamStats) ProtoMessage() {}
+func (*StreamStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{58}
+}
+func (m *StreamStats) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamStats.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamStats.Merge(m, src)
+}
+func (m *StreamStats) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamStats proto.InternalMessageInfo
+
+func (m *StreamStats) GetLabels() string {
+	if m != nil {
+		return m.Labels
+	}
+	return ""
+}
+
+func (m *StreamStats) GetEntries() uint64 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *StreamStats) GetBytes() uint64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *StreamStats) GetChunks() uint32 {
+	if m != nil {
+		return m.Chunks
+	}
+	return 0
+}
+
+func (m *StreamStats) GetLastPush() time.Time {
+	if m != nil {
+		return m.LastPush
+	}
+	return time.Time{}
+}
+
+func (m *StreamStats) GetRate() int64 {
+	if m != nil {
+		return m.Rate
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterEnum("logproto.Direction", Direction_name, Direction_value)
+	proto.RegisterType((*LabelToValuesResponse)(nil), "logproto.LabelToValuesResponse")
+	proto.RegisterMapType((map[string]*UniqueLabelValues)(nil), "logproto.LabelToValuesResponse.LabelsEntry")
+	proto.RegisterType((*UniqueLabelValues)(nil), "logproto.UniqueLabelValues")
+	proto.RegisterType((*StreamRatesRequest)(nil), "logproto.StreamRatesRequest")
+	proto.RegisterType((*StreamRatesResponse)(nil), "logproto.StreamRatesResponse")
+	proto.RegisterType((*StreamRate)(nil), "logproto.StreamRate")
+	proto.RegisterType((*StreamChunksRequest)(nil), "logproto.StreamChunksRequest")
+	proto.RegisterType((*StreamChunksResponse)(nil), "logproto.StreamChunksResponse")
+	proto.RegisterType((*StreamChunk)(nil), "logproto.StreamChunk")
+	proto.RegisterType((*QueryRequest)(nil), "logproto.QueryRequest")
+	proto.RegisterType((*SampleQueryRequest)(nil), "logproto.SampleQueryRequest")
+	proto.RegisterType((*Plan)(nil), "logproto.Plan")
+	proto.RegisterType((*Delete)(nil), "logproto.Delete")
+	proto.RegisterType((*QueryResponse)(nil), "logproto.QueryResponse")
+	proto.RegisterType((*SampleQueryResponse)(nil), "logproto.SampleQueryResponse")
+	proto.RegisterType((*LabelRequest)(nil), "logproto.LabelRequest")
+	proto.RegisterType((*LabelResponse)(nil), "logproto.LabelResponse")
+	proto.RegisterType((*Sample)(nil), "logproto.Sample")
+	proto.RegisterType((*LegacySample)(nil), "logproto.LegacySample")
+	proto.RegisterType((*Series)(nil), "logproto.Series")
+	proto.RegisterType((*TailRequest)(nil), "logproto.TailRequest")
+	proto.RegisterType((*TailResponse)(nil), "logproto.TailResponse")
+	proto.RegisterType((*SeriesRequest)(nil), "logproto.SeriesRequest")
+	proto.RegisterType((*SeriesResponse)(nil), "logproto.SeriesResponse")
+	proto.RegisterType((*SeriesIdentifier)(nil), "logproto.SeriesIdentifier")
+	proto.RegisterType((*SeriesIdentifier_LabelsEntry)(nil), "logproto.SeriesIdentifier.LabelsEntry")
+	proto.RegisterType((*DroppedStream)(nil), "logproto.DroppedStream")
+	proto.RegisterType((*LabelPair)(nil), "logproto.LabelPair")
+	proto.RegisterType((*LegacyLabelPair)(nil), "logproto.LegacyLabelPair")
+	proto.RegisterType((*Chunk)(nil), "logproto.Chunk")
+	proto.RegisterType((*TailersCountRequest)(nil), "logproto.TailersCountRequest")
+	proto.RegisterType((*TailersCountResponse)(nil), "logproto.TailersCountResponse")
+	proto.RegisterType((*GetChunkIDsRequest)(nil), "logproto.GetChunkIDsRequest")
+	proto.RegisterType((*GetChunkIDsResponse)(nil), "logproto.GetChunkIDsResponse")
+	proto.RegisterType((*ChunkRef)(nil), "logproto.ChunkRef")
+	proto.RegisterType((*LabelValuesForMetricNameRequest)(nil), "logproto.LabelValuesForMetricNameRequest")
+	proto.RegisterType((*LabelNamesForMetricNameRequest)(nil), "logproto.LabelNamesForMetricNameRequest")
+	proto.RegisterType((*LineFilter)(nil), "logproto.LineFilter")
+	proto.RegisterType((*GetChunkRefRequest)(nil), "logproto.GetChunkRefRequest")
+	proto.RegisterType((*GetChunkRefResponse)(nil), "logproto.GetChunkRefResponse")
+	proto.RegisterType((*GetSeriesRequest)(nil), "logproto.GetSeriesRequest")
+	proto.RegisterType((*GetSeriesResponse)(nil), "logproto.GetSeriesResponse")
+	proto.RegisterType((*IndexSeries)(nil), "logproto.IndexSeries")
+	proto.RegisterType((*QueryIndexResponse)(nil), "logproto.QueryIndexResponse")
+	proto.RegisterType((*Row)(nil), "logproto.Row")
+	proto.RegisterType((*QueryIndexRequest)(nil), "logproto.QueryIndexRequest")
+	proto.RegisterType((*IndexQuery)(nil), "logproto.IndexQuery")
+	proto.RegisterType((*IndexStatsRequest)(nil), "logproto.IndexStatsRequest")
 	proto.RegisterType((*IndexStatsResponse)(nil), "logproto.IndexStatsResponse")
 	proto.RegisterType((*VolumeRequest)(nil), "logproto.VolumeRequest")
 	proto.RegisterType((*VolumeResponse)(nil), "logproto.VolumeResponse")
@@ -3028,174 +3393,193 @@ func init() {
 	proto.RegisterType((*DetectedLabelsRequest)(nil), "logproto.DetectedLabelsRequest")
 	proto.RegisterType((*DetectedLabelsResponse)(nil), "logproto.DetectedLabelsResponse")
 	proto.RegisterType((*DetectedLabel)(nil), "logproto.DetectedLabel")
+	proto.RegisterType((*StreamStatsRequest)(nil), "logproto.StreamStatsRequest")
+	proto.RegisterType((*StreamStatsResponse)(nil), "logproto.StreamStatsResponse")
+	proto.RegisterType((*StreamStats)(nil), "logproto.StreamStats")
 }
 
 func init() { proto.RegisterFile("pkg/logproto/logproto.proto", fileDescriptor_c28a5f14f1f4c79a) }
 
 var fileDescriptor_c28a5f14f1f4c79a = []byte{
-	// 2590 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x1a, 0x4d, 0x6f, 0x1b, 0xc7,
-	0x95, 0x4b, 0x2e, 0xbf, 0x1e, 0x29, 0x59, 0x1e, 0xd1, 0x36, 0x41, 0xdb, 0xa4, 0x32, 0x68, 0x13,
-	0x35, 0x76, 0xc4, 0x58, 0x69, 0xd2, 0xc4, 0x69, 0x9a, 0x9a, 0x52, 0xac, 0xc8, 0x51, 0x1c, 0x67,
-	0xa4, 0x38, 0x69, 0xd1, 0x20, 0x58, 0x91, 0x23, 0x6a, 0x21, 0x72, 0x97, 0xde, 0x1d, 0xc6, 0xe1,
-	0xad, 0x7f, 0xa0, 0x68, 0x8a, 0x1e, 0xda, 0x5e, 0x0a, 0x14, 0x28, 0xd0, 0x22, 0x45, 0x2f, 0x45,
-	0x8f, 0x45, 0x7b, 0xe9, 0x21, 0xbd, 0xa5, 0xb7, 0x20, 0x07, 0xb6, 0x56, 0x2e, 0x85, 0x4e, 0x01,
-	0x7a, 0xcb, 0xa9, 0x98, 0xaf, 0xfd, 0x12, 0x59, 0x87, 0x8a, 0x83, 0xc0, 0x17, 0x71, 0xe6, 0xcd,
-	0x9b, 0x37, 0xf3, 0x3e, 0xe6, 0x7d, 0xad, 0xe0, 0xfc, 0xe0, 0xa0, 0xdb, 0xec, 0xb9, 0xdd, 0x81,
-	0xe7, 0x32, 0x37, 0x18, 0xac, 0x88, 0xbf, 0xa8, 0xa0, 0xe7, 0xb5, 0x4a, 0xd7, 0xed, 0xba, 0x12,
-	0x87, 0x8f, 0xe4, 0x7a, 0xad, 0xd1, 0x75, 0xdd, 0x6e, 0x8f, 0x36, 0xc5, 0x6c, 0x77, 0xb8, 0xd7,
-	0x64, 0x76, 0x9f, 0xfa, 0xcc, 0xea, 0x0f, 0x14, 0xc2, 0x92, 0xa2, 0x7e, 0xa7, 0xd7, 0x77, 0x3b,
-	0xb4, 0xd7, 0xf4, 0x99, 0xc5, 0x7c, 0xf9, 0x57, 0x61, 0x2c, 0x72, 0x8c, 0xc1, 0xd0, 0xdf, 0x17,
-	0x7f, 0x24, 0x10, 0xff, 0xd9, 0x80, 0x33, 0x5b, 0xd6, 0x2e, 0xed, 0xed, 0xb8, 0xb7, 0xad, 0xde,
-	0x90, 0xfa, 0x84, 0xfa, 0x03, 0xd7, 0xf1, 0x29, 0x5a, 0x83, 0x5c, 0x8f, 0x2f, 0xf8, 0x55, 0x63,
-	0x29, 0xb3, 0x5c, 0x5a, 0xbd, 0xb4, 0x12, 0x5c, 0x79, 0xe2, 0x06, 0x09, 0xf5, 0x5f, 0x72, 0x98,
-	0x37, 0x22, 0x6a, 0x6b, 0xed, 0x36, 0x94, 0x22, 0x60, 0xb4, 0x00, 0x99, 0x03, 0x3a, 0xaa, 0x1a,
-	0x4b, 0xc6, 0x72, 0x91, 0xf0, 0x21, 0xba, 0x02, 0xd9, 0x77, 0x39, 0x99, 0x6a, 0x7a, 0xc9, 0x58,
-	0x2e, 0xad, 0x9e, 0x0f, 0x0f, 0x79, 0xc3, 0xb1, 0xef, 0x0c, 0xa9, 0xd8, 0xad, 0x0e, 0x92, 0x98,
-	0x57, 0xd3, 0xcf, 0x1a, 0xf8, 0x12, 0x9c, 0x3e, 0xb6, 0x8e, 0xce, 0x42, 0x4e, 0x60, 0xc8, 0x1b,
-	0x17, 0x89, 0x9a, 0xe1, 0x0a, 0xa0, 0x6d, 0xe6, 0x51, 0xab, 0x4f, 0x2c, 0xc6, 0xef, 0x7b, 0x67,
-	0x48, 0x7d, 0x86, 0x5f, 0x85, 0xc5, 0x18, 0x54, 0xb1, 0xfd, 0x0c, 0x94, 0xfc, 0x10, 0xac, 0x78,
-	0xaf, 0x84, 0xd7, 0x0a, 0xf7, 0x90, 0x28, 0x22, 0xfe, 0xb5, 0x01, 0x10, 0xae, 0xa1, 0x3a, 0x80,
-	0x5c, 0x7d, 0xd9, 0xf2, 0xf7, 0x05, 0xc3, 0x26, 0x89, 0x40, 0xd0, 0x65, 0x38, 0x1d, 0xce, 0x6e,
-	0xba, 0xdb, 0xfb, 0x96, 0xd7, 0x11, 0x32, 0x30, 0xc9, 0xf1, 0x05, 0x84, 0xc0, 0xf4, 0x2c, 0x46,
-	0xab, 0x99, 0x25, 0x63, 0x39, 0x43, 0xc4, 0x98, 0x73, 0xcb, 0xa8, 0x63, 0x39, 0xac, 0x6a, 0x0a,
-	0x71, 0xaa, 0x19, 0x87, 0x73, 0xfd, 0x52, 0xbf, 0x9a, 0x5d, 0x32, 0x96, 0xe7, 0x88, 0x9a, 0xe1,
-	0x0f, 0x32, 0x50, 0x7e, 0x7d, 0x48, 0xbd, 0x91, 0x12, 0x00, 0xaa, 0x43, 0xc1, 0xa7, 0x3d, 0xda,
-	0x66, 0xae, 0x27, 0x35, 0xd2, 0x4a, 0x57, 0x0d, 0x12, 0xc0, 0x50, 0x05, 0xb2, 0x3d, 0xbb, 0x6f,
-	0x33, 0x71, 0xad, 0x39, 0x22, 0x27, 0xe8, 0x2a, 0x64, 0x7d, 0x66, 0x79, 0x4c, 0xdc, 0xa5, 0xb4,
-	0x5a, 0x5b, 0x91, 0x86, 0xb9, 0xa2, 0x0d, 0x73, 0x65, 0x47, 0x1b, 0x66, 0xab, 0xf0, 0xe1, 0xb8,
-	0x91, 0x7a, 0xff, 0x5f, 0x0d, 0x83, 0xc8, 0x2d, 0xe8, 0x19, 0xc8, 0x50, 0xa7, 0x23, 0xee, 0xfb,
-	0x45, 0x77, 0xf2, 0x0d, 0xe8, 0x0a, 0x14, 0x3b, 0xb6, 0x47, 0xdb, 0xcc, 0x76, 0x1d, 0xc1, 0xd5,
-	0xfc, 0xea, 0x62, 0xa8, 0x91, 0x75, 0xbd, 0x44, 0x42, 0x2c, 0x74, 0x19, 0x72, 0x3e, 0x17, 0x9d,
-	0x5f, 0xcd, 0x73, 0x5b, 0x68, 0x55, 0x8e, 0xc6, 0x8d, 0x05, 0x09, 0xb9, 0xec, 0xf6, 0x6d, 0x46,
-	0xfb, 0x03, 0x36, 0x22, 0x0a, 0x07, 0x3d, 0x0e, 0xf9, 0x0e, 0xed, 0x51, 0xae, 0xf0, 0x82, 0x50,
-	0xf8, 0x42, 0x84, 0xbc, 0x58, 0x20, 0x1a, 0x01, 0xbd, 0x0d, 0xe6, 0xa0, 0x67, 0x39, 0xd5, 0xa2,
-	0xe0, 0x62, 0x3e, 0x44, 0xbc, 0xd5, 0xb3, 0x9c, 0xd6, 0x73, 0x9f, 0x8c, 0x1b, 0x4f, 0x77, 0x6d,
-	0xb6, 0x3f, 0xdc, 0x5d, 0x69, 0xbb, 0xfd, 0x66, 0xd7, 0xb3, 0xf6, 0x2c, 0xc7, 0x6a, 0xf6, 0xdc,
-	0x03, 0xbb, 0xf9, 0xee, 0x53, 0x4d, 0xfe, 0x06, 0xef, 0x0c, 0xa9, 0x67, 0x53, 0xaf, 0xc9, 0xc9,
-	0xac, 0x08, 0x95, 0xf0, 0xad, 0x44, 0x90, 0xbd, 0x61, 0x16, 0x72, 0x0b, 0x79, 0x7c, 0x2f, 0x0d,
-	0x68, 0xdb, 0xea, 0x0f, 0x7a, 0x74, 0x26, 0x95, 0x05, 0xca, 0x49, 0x9f, 0x58, 0x39, 0x99, 0x59,
-	0x95, 0x13, 0x4a, 0xda, 0x9c, 0x4d, 0xd2, 0xd9, 0x2f, 0x2a, 0xe9, 0xdc, 0x57, 0x22, 0x69, 0x5c,
-	0x05, 0x93, 0xcf, 0xb8, 0x53, 0xf2, 0xac, 0xbb, 0x42, 0x9e, 0x65, 0xc2, 0x87, 0x78, 0x0b, 0x72,
-	0xf2, 0x2e, 0xa8, 0x96, 0x14, 0x78, 0xfc, 0x7d, 0x84, 0xc2, 0xce, 0x68, 0x31, 0x2e, 0x84, 0x62,
-	0xcc, 0x08, 0x01, 0xe1, 0xbf, 0x18, 0x30, 0xa7, 0xb4, 0xa8, 0x7c, 0xcc, 0x2e, 0xe4, 0xe5, 0x1b,
-	0xd7, 0xfe, 0xe5, 0x5c, 0xd2, 0xbf, 0x5c, 0xeb, 0x58, 0x03, 0x46, 0xbd, 0x56, 0xf3, 0xc3, 0x71,
-	0xc3, 0xf8, 0x64, 0xdc, 0x78, 0x6c, 0x1a, 0xa3, 0xda, 0xa7, 0x6b, 0xbf, 0xa4, 0x09, 0xa3, 0x4b,
-	0xe2, 0x76, 0xcc, 0x57, 0xa6, 0x70, 0x6a, 0x45, 0x86, 0x82, 0x4d, 0xa7, 0x4b, 0x7d, 0x4e, 0xd9,
-	0xe4, 0x5a, 0x24, 0x12, 0x87, 0xb3, 0x79, 0xd7, 0xf2, 0x1c, 0xdb, 0xe9, 0xfa, 0xd5, 0x8c, 0xf0,
-	0x9d, 0xc1, 0x1c, 0xff, 0xd2, 0x80, 0xc5, 0x98, 0x29, 0x2a, 0x26, 0x9e, 0x85, 0x9c, 0xcf, 0xa5,
-	0xab, 0x79, 0x88, 0x28, 0x72, 0x5b, 0xc0, 0x5b, 0xf3, 0xea, 0xf2, 0x39, 0x39, 0x27, 0x0a, 0xff,
-	0xc1, 0x5d, 0xed, 0xef, 0x06, 0x94, 0x45, 0x00, 0xd0, 0xef, 0x03, 0x81, 0xe9, 0x58, 0x7d, 0xaa,
-	0x54, 0x25, 0xc6, 0x91, 0xa8, 0xc0, 0x8f, 0x2b, 0xe8, 0xa8, 0x30, 0xab, 0x23, 0x33, 0x4e, 0xec,
-	0xc8, 0x8c, 0xf0, 0xad, 0x54, 0x20, 0xcb, 0x4d, 0x72, 0x24, 0x9c, 0x58, 0x91, 0xc8, 0x09, 0x7e,
-	0x0c, 0xe6, 0x14, 0x17, 0x4a, 0xb4, 0xd3, 0x02, 0x59, 0x1f, 0x72, 0x52, 0x13, 0xe8, 0x1b, 0x50,
-	0x0c, 0x12, 0x00, 0xc1, 0x6d, 0xa6, 0x95, 0x3b, 0x1a, 0x37, 0xd2, 0xcc, 0x27, 0xe1, 0x02, 0x6a,
-	0x44, 0x83, 0xab, 0xd1, 0x2a, 0x1e, 0x8d, 0x1b, 0x12, 0xa0, 0x42, 0x29, 0xba, 0x00, 0xe6, 0x3e,
-	0x8f, 0x4f, 0x5c, 0x04, 0x66, 0xab, 0x70, 0x34, 0x6e, 0x88, 0x39, 0x11, 0x7f, 0xf1, 0x06, 0x94,
-	0xb7, 0x68, 0xd7, 0x6a, 0x8f, 0xd4, 0xa1, 0x15, 0x4d, 0x8e, 0x1f, 0x68, 0x68, 0x1a, 0x8f, 0x40,
-	0x39, 0x38, 0xf1, 0x9d, 0xbe, 0xaf, 0x5e, 0x43, 0x29, 0x80, 0xbd, 0xea, 0xe3, 0x5f, 0x19, 0xa0,
-	0x6c, 0x00, 0xe1, 0x48, 0x56, 0xc1, 0xfd, 0x17, 0x1c, 0x8d, 0x1b, 0x0a, 0xa2, 0x93, 0x06, 0xf4,
-	0x3c, 0xe4, 0x7d, 0x71, 0x22, 0x27, 0x96, 0x34, 0x2d, 0xb1, 0xd0, 0x3a, 0xc5, 0x4d, 0xe4, 0x68,
-	0xdc, 0xd0, 0x88, 0x44, 0x0f, 0xd0, 0x4a, 0x2c, 0xf0, 0x4a, 0xc6, 0xe6, 0x8f, 0xc6, 0x8d, 0x08,
-	0x34, 0x1a, 0x88, 0xf1, 0xe7, 0x06, 0x94, 0x76, 0x2c, 0x3b, 0x30, 0xa1, 0xaa, 0x56, 0x51, 0xe8,
-	0x5f, 0x25, 0x80, 0x5b, 0x62, 0x87, 0xf6, 0xac, 0xd1, 0x75, 0xd7, 0x13, 0x74, 0xe7, 0x48, 0x30,
-	0x0f, 0x63, 0xa5, 0x39, 0x31, 0x56, 0x66, 0x67, 0x77, 0xc7, 0x5f, 0xad, 0xf3, 0xbb, 0x61, 0x16,
-	0xd2, 0x0b, 0x19, 0xfc, 0x47, 0x03, 0xca, 0x92, 0x79, 0x65, 0x79, 0x3f, 0x82, 0x9c, 0x94, 0x8d,
-	0x60, 0xff, 0xff, 0x38, 0xa6, 0x4b, 0xb3, 0x38, 0x25, 0x45, 0x13, 0xbd, 0x08, 0xf3, 0x1d, 0xcf,
-	0x1d, 0x0c, 0x68, 0x67, 0x5b, 0xb9, 0xbf, 0x74, 0xd2, 0xfd, 0xad, 0x47, 0xd7, 0x49, 0x02, 0x1d,
-	0xff, 0xc3, 0x80, 0x39, 0xe5, 0x4c, 0x94, 0xba, 0x02, 0x11, 0x1b, 0x27, 0x8e, 0x78, 0xe9, 0x59,
-	0x23, 0xde, 0x59, 0xc8, 0x75, 0x3d, 0x77, 0x38, 0xd0, 0x0e, 0x49, 0xcd, 0x66, 0x8b, 0x84, 0xf8,
-	0x06, 0xcc, 0x6b, 0x56, 0xa6, 0x78, 0xd4, 0x5a, 0xd2, 0xa3, 0x6e, 0x76, 0xa8, 0xc3, 0xec, 0x3d,
-	0x3b, 0xf0, 0x91, 0x0a, 0x1f, 0xff, 0xd4, 0x80, 0x85, 0x24, 0x0a, 0x5a, 0x4f, 0x24, 0xf0, 0x8f,
-	0x4e, 0x27, 0x17, 0xcd, 0xdd, 0x35, 0x69, 0x95, 0xc1, 0x3f, 0x7d, 0xbf, 0x0c, 0xbe, 0x12, 0x75,
-	0x32, 0x45, 0xe5, 0x15, 0xf0, 0x2f, 0x0c, 0x98, 0x8b, 0xe9, 0x12, 0x3d, 0x0b, 0xe6, 0x9e, 0xe7,
-	0xf6, 0x67, 0x52, 0x94, 0xd8, 0x81, 0xbe, 0x0d, 0x69, 0xe6, 0xce, 0xa4, 0xa6, 0x34, 0x73, 0xb9,
-	0x96, 0x14, 0xfb, 0x19, 0x99, 0x1f, 0xcb, 0x19, 0x7e, 0x1a, 0x8a, 0x82, 0xa1, 0x5b, 0x96, 0xed,
-	0x4d, 0x0c, 0x18, 0x93, 0x19, 0x7a, 0x1e, 0x4e, 0x49, 0x67, 0x38, 0x79, 0x73, 0x79, 0xd2, 0xe6,
-	0xb2, 0xde, 0x7c, 0x1e, 0xb2, 0x6b, 0xfb, 0x43, 0xe7, 0x80, 0x6f, 0xe9, 0x58, 0xcc, 0xd2, 0x5b,
-	0xf8, 0x18, 0x9f, 0x81, 0x45, 0xfe, 0x06, 0xa9, 0xe7, 0xaf, 0xb9, 0x43, 0x87, 0xe9, 0xfa, 0xe4,
-	0x32, 0x54, 0xe2, 0x60, 0x65, 0x25, 0x15, 0xc8, 0xb6, 0x39, 0x40, 0xd0, 0x98, 0x23, 0x72, 0x82,
-	0x7f, 0x6b, 0x00, 0xda, 0xa0, 0x4c, 0x9c, 0xb2, 0xb9, 0x1e, 0x3c, 0x8f, 0x1a, 0x14, 0xfa, 0x16,
-	0x6b, 0xef, 0x53, 0xcf, 0xd7, 0xf9, 0x8b, 0x9e, 0x7f, 0x1d, 0xc9, 0x22, 0xbe, 0x02, 0x8b, 0xb1,
-	0x5b, 0x2a, 0x9e, 0x6a, 0x50, 0x68, 0x2b, 0x98, 0x0a, 0x79, 0xc1, 0x1c, 0xff, 0x29, 0x0d, 0x05,
-	0xb1, 0x81, 0xd0, 0x3d, 0x74, 0x05, 0x4a, 0x7b, 0xb6, 0xd3, 0xa5, 0xde, 0xc0, 0xb3, 0x95, 0x08,
-	0xcc, 0xd6, 0xa9, 0xa3, 0x71, 0x23, 0x0a, 0x26, 0xd1, 0x09, 0x7a, 0x02, 0xf2, 0x43, 0x9f, 0x7a,
-	0xef, 0xd8, 0xf2, 0xa5, 0x17, 0x5b, 0x95, 0xc3, 0x71, 0x23, 0xf7, 0x86, 0x4f, 0xbd, 0xcd, 0x75,
-	0x1e, 0x7c, 0x86, 0x62, 0x44, 0xe4, 0x6f, 0x07, 0xbd, 0xa2, 0xcc, 0x54, 0x24, 0x70, 0xad, 0xef,
-	0xf0, 0xeb, 0x27, 0x5c, 0xdd, 0xc0, 0x73, 0xfb, 0x94, 0xed, 0xd3, 0xa1, 0xdf, 0x6c, 0xbb, 0xfd,
-	0xbe, 0xeb, 0x34, 0x45, 0xc5, 0x2d, 0x98, 0xe6, 0x11, 0x94, 0x6f, 0x57, 0x96, 0xbb, 0x03, 0x79,
-	0xb6, 0xef, 0xb9, 0xc3, 0xee, 0xbe, 0x08, 0x0c, 0x99, 0xd6, 0xd5, 0xd9, 0xe9, 0x69, 0x0a, 0x44,
-	0x0f, 0xd0, 0x23, 0x5c, 0x5a, 0xb4, 0x7d, 0xe0, 0x0f, 0xfb, 0xb2, 0xc6, 0x6b, 0x65, 0x8f, 0xc6,
-	0x0d, 0xe3, 0x09, 0x12, 0x80, 0xf1, 0x4f, 0xd2, 0xd0, 0x88, 0x94, 0xc6, 0xd7, 0x5d, 0xef, 0x55,
-	0xca, 0x3c, 0xbb, 0x7d, 0xd3, 0xea, 0x53, 0x6d, 0x1b, 0x0d, 0x28, 0xf5, 0x05, 0xf0, 0x9d, 0xc8,
-	0x13, 0x80, 0x7e, 0x80, 0x87, 0x2e, 0x02, 0x88, 0x37, 0x23, 0xd7, 0xe5, 0x6b, 0x28, 0x0a, 0x88,
-	0x58, 0x5e, 0x8b, 0x49, 0xaa, 0x39, 0x23, 0x67, 0x4a, 0x42, 0x9b, 0x49, 0x09, 0xcd, 0x4c, 0x27,
-	0x10, 0x4b, 0xd4, 0xd6, 0xb3, 0x71, 0x5b, 0xc7, 0xff, 0x34, 0xa0, 0xbe, 0xa5, 0x6f, 0x7e, 0x42,
-	0x71, 0x68, 0x7e, 0xd3, 0x0f, 0x88, 0xdf, 0xcc, 0x97, 0xe3, 0x17, 0xd7, 0x01, 0xb6, 0x6c, 0x87,
-	0x5e, 0xb7, 0x7b, 0x8c, 0x7a, 0x13, 0xaa, 0x98, 0x9f, 0x67, 0x42, 0x97, 0x40, 0xe8, 0x9e, 0xe6,
-	0x73, 0x2d, 0xe2, 0x87, 0x1f, 0x04, 0x1b, 0xe9, 0x07, 0xa8, 0xb6, 0x4c, 0xc2, 0x45, 0x39, 0x90,
-	0xdf, 0x13, 0xec, 0xc9, 0x90, 0x1a, 0x6b, 0xc4, 0x84, 0xbc, 0xb7, 0xbe, 0xa7, 0x0e, 0x7f, 0xe6,
-	0x3e, 0x19, 0x91, 0x68, 0x8f, 0x35, 0xfd, 0x91, 0xc3, 0xac, 0xf7, 0x22, 0xfb, 0x89, 0x3e, 0x04,
-	0x59, 0x2a, 0xe9, 0xca, 0x4e, 0x4c, 0xba, 0x5e, 0x50, 0xc7, 0x7c, 0xa9, 0xaa, 0xf3, 0x85, 0xd0,
-	0x03, 0x0a, 0xa5, 0x28, 0x0f, 0xf8, 0x28, 0x98, 0x1e, 0xdd, 0xd3, 0xa1, 0x1a, 0x85, 0x27, 0x07,
-	0x98, 0x62, 0x1d, 0xff, 0xd5, 0x80, 0x85, 0x0d, 0xca, 0xe2, 0x49, 0xd0, 0x43, 0xa4, 0x52, 0xfc,
-	0x32, 0x9c, 0x8e, 0xdc, 0x5f, 0x71, 0xff, 0x54, 0x22, 0xf3, 0x39, 0x13, 0xf2, 0xbf, 0xe9, 0x74,
-	0xe8, 0x7b, 0xaa, 0xa0, 0x8c, 0x27, 0x3d, 0xb7, 0xa0, 0x14, 0x59, 0x44, 0xd7, 0x12, 0xe9, 0xce,
-	0x62, 0xa2, 0x5f, 0xc9, 0x43, 0x76, 0xab, 0xa2, 0x78, 0x92, 0x65, 0xa3, 0x4a, 0x66, 0x83, 0xd4,
-	0x60, 0x1b, 0x90, 0x50, 0x97, 0x20, 0x1b, 0x0d, 0x4e, 0x02, 0xfa, 0x4a, 0x90, 0xf7, 0x04, 0x73,
-	0xf4, 0x08, 0x98, 0x9e, 0x7b, 0x57, 0xe7, 0xb1, 0x73, 0xe1, 0x91, 0xc4, 0xbd, 0x4b, 0xc4, 0x12,
-	0x7e, 0x1e, 0x32, 0xc4, 0xbd, 0x8b, 0xea, 0x00, 0x9e, 0xe5, 0x74, 0xe9, 0xed, 0xa0, 0x82, 0x2a,
-	0x93, 0x08, 0x64, 0x4a, 0xe2, 0xb0, 0x06, 0xa7, 0xa3, 0x37, 0x92, 0xea, 0x5e, 0x81, 0xfc, 0xeb,
-	0xc3, 0xa8, 0xb8, 0x2a, 0x09, 0x71, 0xc9, 0x42, 0x5d, 0x23, 0x71, 0x9b, 0x81, 0x10, 0x8e, 0x2e,
-	0x40, 0x91, 0x59, 0xbb, 0x3d, 0x7a, 0x33, 0x74, 0x73, 0x21, 0x80, 0xaf, 0xf2, 0xe2, 0xef, 0x76,
-	0x24, 0x03, 0x0a, 0x01, 0xe8, 0x71, 0x58, 0x08, 0xef, 0x7c, 0xcb, 0xa3, 0x7b, 0xf6, 0x7b, 0x42,
-	0xc3, 0x65, 0x72, 0x0c, 0x8e, 0x96, 0xe1, 0x54, 0x08, 0xdb, 0x16, 0x99, 0x86, 0x29, 0x50, 0x93,
-	0x60, 0x2e, 0x1b, 0xc1, 0xee, 0x4b, 0x77, 0x86, 0x56, 0x4f, 0x3c, 0xbe, 0x32, 0x89, 0x40, 0xf0,
-	0xdf, 0x0c, 0x38, 0x2d, 0x55, 0xcd, 0x2c, 0xf6, 0x50, 0x5a, 0xfd, 0xef, 0x0c, 0x40, 0x51, 0x0e,
-	0x94, 0x69, 0x7d, 0x33, 0xda, 0x08, 0xe2, 0xa9, 0x4c, 0x49, 0xd4, 0xb4, 0x12, 0x14, 0xf6, 0x72,
-	0x30, 0xe4, 0x44, 0x3a, 0x24, 0x8b, 0x6b, 0x53, 0x16, 0xcd, 0x12, 0x42, 0xd4, 0x2f, 0xaf, 0xf5,
-	0x77, 0x47, 0x8c, 0xfa, 0xaa, 0xe4, 0x15, 0xb5, 0xbe, 0x00, 0x10, 0xf9, 0xc3, 0xcf, 0xa2, 0x0e,
-	0x13, 0x56, 0x63, 0x86, 0x67, 0x29, 0x10, 0xd1, 0x03, 0xfc, 0x87, 0x34, 0xcc, 0xdd, 0x76, 0x7b,
-	0xc3, 0x30, 0x30, 0x3e, 0x4c, 0x01, 0x23, 0x56, 0x87, 0x67, 0x75, 0x1d, 0x8e, 0xc0, 0xf4, 0x19,
-	0x1d, 0x08, 0xcb, 0xca, 0x10, 0x31, 0x46, 0x18, 0xca, 0xcc, 0xf2, 0xba, 0x94, 0xc9, 0xea, 0xa6,
-	0x9a, 0x13, 0x69, 0x67, 0x0c, 0x86, 0x96, 0xa0, 0x64, 0x75, 0xbb, 0x1e, 0xed, 0x5a, 0x8c, 0xb6,
-	0x46, 0xd5, 0xbc, 0x38, 0x2c, 0x0a, 0xc2, 0x6f, 0xc1, 0xbc, 0x16, 0x96, 0x52, 0xe9, 0x93, 0x90,
-	0x7f, 0x57, 0x40, 0x26, 0xf4, 0xc5, 0x24, 0xaa, 0x72, 0x63, 0x1a, 0x2d, 0xde, 0x67, 0xd7, 0x77,
-	0xc6, 0x37, 0x20, 0x27, 0xd1, 0xd1, 0x85, 0x68, 0x8d, 0x22, 0x9b, 0x34, 0x7c, 0xae, 0x0a, 0x0e,
-	0x0c, 0x39, 0x49, 0x48, 0x29, 0x5e, 0xd8, 0x86, 0x84, 0x10, 0xf5, 0x8b, 0xff, 0x6b, 0xc0, 0x99,
-	0x75, 0xca, 0x68, 0x9b, 0xd1, 0xce, 0x75, 0x9b, 0xf6, 0x3a, 0x5f, 0x6b, 0xf9, 0x1c, 0x34, 0xc1,
-	0x32, 0x91, 0x26, 0x18, 0xf7, 0x3b, 0x3d, 0xdb, 0xa1, 0x5b, 0x91, 0x2e, 0x4a, 0x08, 0xe0, 0x1e,
-	0x62, 0x8f, 0x5f, 0x5c, 0x2e, 0xcb, 0x0f, 0x1b, 0x11, 0x48, 0xa0, 0xe1, 0x5c, 0xa8, 0x61, 0x6c,
-	0xc3, 0xd9, 0x24, 0xd3, 0x4a, 0x47, 0x4d, 0xc8, 0x89, 0xbd, 0x13, 0xda, 0xaf, 0xb1, 0x1d, 0x44,
-	0xa1, 0x25, 0x8e, 0x4f, 0x27, 0x8f, 0xc7, 0x3f, 0xe3, 0xd5, 0x6e, 0x74, 0xa7, 0x50, 0x2a, 0x37,
-	0x22, 0xe5, 0x60, 0xe5, 0x04, 0x7d, 0x0b, 0x4c, 0x36, 0x1a, 0x28, 0xbf, 0xda, 0x3a, 0xf3, 0xf9,
-	0xb8, 0x71, 0x3a, 0xb6, 0x6d, 0x67, 0x34, 0xa0, 0x44, 0xa0, 0x70, 0xdb, 0x6b, 0x5b, 0x5e, 0xc7,
-	0x76, 0xac, 0x9e, 0xcd, 0xa4, 0xac, 0x4c, 0x12, 0x05, 0xa1, 0x8b, 0x90, 0xf3, 0x0f, 0x28, 0x6b,
-	0xcb, 0xcc, 0xb9, 0xac, 0x8b, 0x00, 0x05, 0xc4, 0xbf, 0x89, 0x28, 0x5d, 0xda, 0xf3, 0x09, 0x95,
-	0x6e, 0x9c, 0x58, 0xe9, 0xc6, 0x7d, 0x94, 0x8e, 0x7f, 0x10, 0xaa, 0x48, 0x5f, 0x51, 0xa9, 0xe8,
-	0x45, 0x98, 0xef, 0xc4, 0x56, 0xa6, 0xab, 0x4a, 0xf6, 0x4e, 0x13, 0xe8, 0x78, 0x23, 0xd4, 0x88,
-	0x80, 0x4c, 0xd1, 0x48, 0x42, 0xcc, 0xe9, 0x63, 0x62, 0x7e, 0xfc, 0x51, 0x28, 0x06, 0x5f, 0x98,
-	0x50, 0x09, 0xf2, 0xd7, 0x5f, 0x23, 0x6f, 0x5e, 0x23, 0xeb, 0x0b, 0x29, 0x54, 0x86, 0x42, 0xeb,
-	0xda, 0xda, 0x2b, 0x62, 0x66, 0xac, 0x7e, 0x90, 0xd3, 0x61, 0xd9, 0x43, 0xdf, 0x85, 0xac, 0x8c,
-	0xb5, 0x67, 0xc3, 0xeb, 0x46, 0x3f, 0xe4, 0xd4, 0xce, 0x1d, 0x83, 0x4b, 0xbe, 0x71, 0xea, 0x49,
-	0x03, 0xdd, 0x84, 0x92, 0x00, 0xaa, 0xb6, 0xeb, 0x85, 0x64, 0xf7, 0x33, 0x46, 0xe9, 0xe2, 0x94,
-	0xd5, 0x08, 0xbd, 0xab, 0x90, 0x95, 0x22, 0x38, 0x9b, 0x48, 0x89, 0x26, 0xdc, 0x26, 0xd6, 0x88,
-	0xc6, 0x29, 0xf4, 0x1c, 0x98, 0x3b, 0x96, 0xdd, 0x43, 0x91, 0x8c, 0x2c, 0xd2, 0x2d, 0xad, 0x9d,
-	0x4d, 0x82, 0x23, 0xc7, 0xbe, 0x10, 0x34, 0x7d, 0xcf, 0x25, 0x3b, 0x4f, 0x7a, 0x7b, 0xf5, 0xf8,
-	0x42, 0x70, 0xf2, 0x6b, 0xb2, 0x35, 0xa9, 0xfb, 0x1f, 0xe8, 0x62, 0xfc, 0xa8, 0x44, 0xbb, 0xa4,
-	0x56, 0x9f, 0xb6, 0x1c, 0x10, 0xdc, 0x82, 0x52, 0xa4, 0xf7, 0x10, 0x15, 0xeb, 0xf1, 0xc6, 0x49,
-	0x54, 0xac, 0x13, 0x1a, 0x16, 0x38, 0x85, 0x36, 0xa0, 0xc0, 0xf3, 0x58, 0xf1, 0x8d, 0xe2, 0x7c,
-	0x32, 0x5d, 0x8d, 0xa4, 0x29, 0xb5, 0x0b, 0x93, 0x17, 0x03, 0x42, 0xdf, 0x87, 0xe2, 0x06, 0x65,
-	0xca, 0xd7, 0x9f, 0x4b, 0x06, 0x8b, 0x09, 0x92, 0x8a, 0x07, 0x1c, 0x9c, 0x42, 0x6f, 0x89, 0x94,
-	0x3a, 0xee, 0xeb, 0x50, 0x63, 0x8a, 0x4f, 0x0b, 0xee, 0xb5, 0x34, 0x1d, 0x21, 0xa0, 0xfc, 0x66,
-	0x8c, 0xb2, 0x8a, 0x8a, 0x8d, 0x29, 0x4f, 0x30, 0xa0, 0xdc, 0xb8, 0xcf, 0x7f, 0x0a, 0xe0, 0xd4,
-	0xea, 0xdb, 0xfa, 0x63, 0xf9, 0xba, 0xc5, 0x2c, 0xf4, 0x1a, 0xcc, 0x0b, 0x59, 0x06, 0x5f, 0xd3,
-	0x63, 0x36, 0x7f, 0xec, 0xd3, 0x7d, 0xcc, 0xe6, 0x8f, 0x7f, 0xc2, 0xc7, 0xa9, 0xd6, 0xdb, 0x1f,
-	0xdd, 0xab, 0xa7, 0x3e, 0xbe, 0x57, 0x4f, 0x7d, 0x76, 0xaf, 0x6e, 0xfc, 0xf8, 0xb0, 0x6e, 0xfc,
-	0xfe, 0xb0, 0x6e, 0x7c, 0x78, 0x58, 0x37, 0x3e, 0x3a, 0xac, 0x1b, 0xff, 0x3e, 0xac, 0x1b, 0xff,
-	0x39, 0xac, 0xa7, 0x3e, 0x3b, 0xac, 0x1b, 0xef, 0x7f, 0x5a, 0x4f, 0x7d, 0xf4, 0x69, 0x3d, 0xf5,
-	0xf1, 0xa7, 0xf5, 0xd4, 0x0f, 0x1f, 0xbb, 0x7f, 0xf9, 0x28, 0x1d, 0x5d, 0x4e, 0xfc, 0x3c, 0xf5,
-	0xbf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x90, 0x3c, 0x9c, 0xf7, 0xd2, 0x21, 0x00, 0x00,
+	// 2848 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x3a, 0xcd, 0x6f, 0x1b, 0xc7,
+	0xf5, 0x5c, 0x72, 0x49, 0x91, 0x8f, 0x94, 0x2c, 0x8f, 0x69, 0x99, 0x90, 0x6d, 0x52, 0x19, 0xfc,
+	0x7e, 0x89, 0x1b, 0x3b, 0x62, 0xec, 0x34, 0x69, 0xe2, 0x34, 0x4d, 0x4c, 0x29, 0x76, 0x6c, 0x2b,
+	0x8e, 0x33, 0x72, 0x9c, 0xb4, 0x68, 0x10, 0xac, 0xc9, 0x11, 0xb5, 0xf0, 0x72, 0x97, 0xde, 0x1d,
+	0xc6, 0xd6, 0xad, 0xff, 0x40, 0xd1, 0x14, 0xbd, 0xb4, 0x97, 0x02, 0x05, 0x0a, 0xb4, 0x68, 0x4f,
+	0x2d, 0x7a, 0xeb, 0xd7, 0xa1, 0x3d, 0xa4, 0xb7, 0x14, 0xbd, 0x04, 0x01, 0xca, 0xd6, 0xca, 0xa5,
+	0xd0, 0x29, 0x40, 0x6f, 0xed, 0xa5, 0x98, 0xaf, 0xdd, 0xd9, 0x15, 0x19, 0x87, 0x76, 0x82, 0x24,
+	0x17, 0x72, 0xe7, 0xcd, 0x9b, 0x37, 0xf3, 0x3e, 0xe6, 0x7d, 0xed, 0xc2, 0xd1, 0xe1, 0xcd, 0x7e,
+	0xdb, 0x0b, 0xfa, 0xc3, 0x30, 0x60, 0x41, 0xfc, 0xb0, 0x2a, 0x7e, 0x51, 0x59, 0x8f, 0x97, 0xeb,
+	0xfd, 0xa0, 0x1f, 0x48, 0x1c, 0xfe, 0x24, 0xe7, 0x97, 0x5b, 0xfd, 0x20, 0xe8, 0x7b, 0xb4, 0x2d,
+	0x46, 0x37, 0x46, 0x5b, 0x6d, 0xe6, 0x0e, 0x68, 0xc4, 0x9c, 0xc1, 0x50, 0x21, 0xac, 0x28, 0xea,
+	0xb7, 0xbc, 0x41, 0xd0, 0xa3, 0x5e, 0x3b, 0x62, 0x0e, 0x8b, 0xe4, 0xaf, 0xc2, 0x38, 0xc4, 0x31,
+	0x86, 0xa3, 0x68, 0x5b, 0xfc, 0x48, 0x20, 0xfe, 0x8d, 0x05, 0x87, 0x37, 0x9c, 0x1b, 0xd4, 0xbb,
+	0x16, 0x5c, 0x77, 0xbc, 0x11, 0x8d, 0x08, 0x8d, 0x86, 0x81, 0x1f, 0x51, 0xb4, 0x06, 0x25, 0x8f,
+	0x4f, 0x44, 0x0d, 0x6b, 0xa5, 0x70, 0xa2, 0x7a, 0xe6, 0xe4, 0x6a, 0x7c, 0xe4, 0x89, 0x0b, 0x24,
+	0x34, 0x7a, 0xd1, 0x67, 0xe1, 0x0e, 0x51, 0x4b, 0x97, 0xaf, 0x43, 0xd5, 0x00, 0xa3, 0x45, 0x28,
+	0xdc, 0xa4, 0x3b, 0x0d, 0x6b, 0xc5, 0x3a, 0x51, 0x21, 0xfc, 0x11, 0x9d, 0x86, 0xe2, 0xdb, 0x9c,
+	0x4c, 0x23, 0xbf, 0x62, 0x9d, 0xa8, 0x9e, 0x39, 0x9a, 0x6c, 0xf2, 0x9a, 0xef, 0xde, 0x1a, 0x51,
+	0xb1, 0x5a, 0x6d, 0x24, 0x31, 0xcf, 0xe6, 0x9f, 0xb6, 0xf0, 0x49, 0x38, 0xb8, 0x6f, 0x1e, 0x2d,
+	0x41, 0x49, 0x60, 0xc8, 0x13, 0x57, 0x88, 0x1a, 0xe1, 0x3a, 0xa0, 0x4d, 0x16, 0x52, 0x67, 0x40,
+	0x1c, 0xc6, 0xcf, 0x7b, 0x6b, 0x44, 0x23, 0x86, 0x5f, 0x86, 0x43, 0x29, 0xa8, 0x62, 0xfb, 0x29,
+	0xa8, 0x46, 0x09, 0x58, 0xf1, 0x5e, 0x4f, 0x8e, 0x95, 0xac, 0x21, 0x26, 0x22, 0xfe, 0xb1, 0x05,
+	0x90, 0xcc, 0xa1, 0x26, 0x80, 0x9c, 0x7d, 0xc9, 0x89, 0xb6, 0x05, 0xc3, 0x36, 0x31, 0x20, 0xe8,
+	0x14, 0x1c, 0x4c, 0x46, 0x57, 0x82, 0xcd, 0x6d, 0x27, 0xec, 0x09, 0x19, 0xd8, 0x64, 0xff, 0x04,
+	0x42, 0x60, 0x87, 0x0e, 0xa3, 0x8d, 0xc2, 0x8a, 0x75, 0xa2, 0x40, 0xc4, 0x33, 0xe7, 0x96, 0x51,
+	0xdf, 0xf1, 0x59, 0xc3, 0x16, 0xe2, 0x54, 0x23, 0x0e, 0xe7, 0xfa, 0xa5, 0x51, 0xa3, 0xb8, 0x62,
+	0x9d, 0x98, 0x27, 0x6a, 0x84, 0x7f, 0x6f, 0x69, 0x86, 0xd7, 0xb6, 0x47, 0xfe, 0x4d, 0x2d, 0x07,
+	0x8e, 0x1f, 0xeb, 0x59, 0xd0, 0x91, 0x23, 0xb4, 0x06, 0xf6, 0x56, 0x18, 0x0c, 0xc4, 0xa1, 0x0a,
+	0x9d, 0xf6, 0xbb, 0xe3, 0x56, 0xee, 0x83, 0x71, 0xeb, 0x91, 0xbe, 0xcb, 0xb6, 0x47, 0x37, 0x56,
+	0xbb, 0xc1, 0x80, 0xdb, 0xe2, 0x80, 0xb2, 0x6d, 0x3a, 0x8a, 0xda, 0xdd, 0x60, 0x30, 0x08, 0xfc,
+	0xb6, 0x30, 0xbd, 0xd5, 0x6b, 0xee, 0x80, 0x12, 0xb1, 0x18, 0x5d, 0x84, 0x39, 0xb6, 0x1d, 0x06,
+	0xa3, 0xfe, 0xb6, 0x3c, 0xfb, 0xec, 0x74, 0xf4, 0x7a, 0x7c, 0x19, 0xea, 0xe9, 0xe3, 0x2b, 0x85,
+	0x3d, 0x01, 0xa5, 0xae, 0x80, 0x28, 0x5d, 0x1d, 0xce, 0xea, 0x4a, 0xe0, 0x77, 0x6c, 0xbe, 0x31,
+	0x51, 0xa8, 0xf8, 0x6f, 0x16, 0x54, 0x8d, 0xd9, 0x98, 0x59, 0xeb, 0x53, 0x62, 0x36, 0xff, 0x60,
+	0xcc, 0xa2, 0x65, 0x28, 0x77, 0xb7, 0x69, 0xf7, 0x66, 0x34, 0x1a, 0x08, 0xc1, 0xcd, 0x93, 0x78,
+	0x8c, 0x1a, 0x30, 0xb7, 0xe5, 0x71, 0x9d, 0xf6, 0x84, 0xe6, 0xcb, 0x44, 0x0f, 0xf1, 0x2f, 0x0a,
+	0x50, 0x7b, 0x75, 0x44, 0xc3, 0x1d, 0xad, 0xdb, 0x26, 0x94, 0x23, 0xea, 0xd1, 0x2e, 0x0b, 0x42,
+	0xa9, 0xdd, 0x4e, 0xbe, 0x61, 0x91, 0x18, 0x86, 0xea, 0x50, 0xf4, 0xdc, 0x81, 0xcb, 0xc4, 0x79,
+	0xe7, 0x89, 0x1c, 0xa0, 0xb3, 0x50, 0x8c, 0x98, 0x13, 0x32, 0xb1, 0x73, 0xf5, 0xcc, 0xf2, 0xaa,
+	0xf4, 0x3d, 0xab, 0xda, 0xf7, 0x88, 0xb3, 0x0a, 0xdf, 0xd3, 0x29, 0x73, 0x0e, 0xdf, 0xf9, 0x47,
+	0xcb, 0x22, 0x72, 0x09, 0x7a, 0x0a, 0x0a, 0xd4, 0x97, 0x07, 0xfb, 0xa4, 0x2b, 0xf9, 0x02, 0x74,
+	0x1a, 0x2a, 0x3d, 0x37, 0xa4, 0x5d, 0xe6, 0x06, 0xbe, 0x30, 0xdc, 0x85, 0x33, 0x87, 0x12, 0x45,
+	0xae, 0xeb, 0x29, 0x92, 0x60, 0xa1, 0x53, 0x50, 0x8a, 0xf8, 0xed, 0x88, 0x1a, 0x73, 0xfc, 0xba,
+	0x77, 0xea, 0x7b, 0xe3, 0xd6, 0xa2, 0x84, 0x9c, 0x0a, 0x06, 0x2e, 0xa3, 0x83, 0x21, 0xdb, 0x21,
+	0x0a, 0x07, 0x3d, 0x0a, 0x73, 0x3d, 0xea, 0x51, 0x7e, 0xa7, 0xcb, 0xc2, 0x4e, 0x16, 0x0d, 0xf2,
+	0x62, 0x82, 0x68, 0x04, 0xf4, 0x26, 0xd8, 0x43, 0xcf, 0xf1, 0x1b, 0x15, 0xc1, 0xc5, 0x42, 0x82,
+	0x78, 0xd5, 0x73, 0xfc, 0xce, 0x33, 0x1f, 0x8c, 0x5b, 0x4f, 0x1a, 0x1a, 0xed, 0x87, 0xce, 0x96,
+	0xe3, 0x3b, 0x6d, 0x2f, 0xb8, 0xe9, 0xb6, 0xdf, 0x7e, 0xa2, 0xcd, 0xdd, 0xec, 0xad, 0x11, 0x0d,
+	0x5d, 0x1a, 0xb6, 0x39, 0x99, 0x55, 0xa1, 0x12, 0xbe, 0x94, 0x08, 0xb2, 0x97, 0xec, 0x72, 0x69,
+	0x71, 0x0e, 0xdf, 0xcd, 0x03, 0xda, 0x74, 0x06, 0x43, 0x8f, 0xce, 0xa4, 0xb2, 0x58, 0x39, 0xf9,
+	0xfb, 0x56, 0x4e, 0x61, 0x56, 0xe5, 0x24, 0x92, 0xb6, 0x67, 0x93, 0x74, 0xf1, 0x93, 0x4a, 0xba,
+	0xf4, 0x99, 0x48, 0x1a, 0x37, 0xc0, 0xe6, 0x23, 0x1e, 0x77, 0x42, 0xe7, 0xb6, 0x90, 0x67, 0x8d,
+	0xf0, 0x47, 0xbc, 0x01, 0x25, 0x79, 0x16, 0x7e, 0xd5, 0xd2, 0x02, 0x4f, 0xdf, 0x8f, 0x44, 0xd8,
+	0x05, 0x2d, 0xc6, 0xc5, 0x44, 0x8c, 0x05, 0x21, 0x20, 0xfc, 0x3b, 0x0b, 0xe6, 0x95, 0x16, 0x95,
+	0x57, 0xba, 0x01, 0x73, 0xd2, 0x8d, 0x6b, 0xb7, 0x74, 0x24, 0xeb, 0x96, 0xce, 0xf5, 0x9c, 0x21,
+	0xa3, 0xa1, 0x70, 0x12, 0x56, 0xc6, 0x49, 0xa4, 0x18, 0xd5, 0x61, 0x5b, 0x87, 0x1e, 0x4d, 0x18,
+	0x9d, 0x14, 0xa7, 0x63, 0x91, 0x32, 0x85, 0x03, 0xab, 0x32, 0xda, 0x5f, 0xf4, 0xfb, 0x34, 0xe2,
+	0x94, 0xa5, 0xcb, 0x93, 0x38, 0x9c, 0xcd, 0xdb, 0x4e, 0xe8, 0xbb, 0x7e, 0x3f, 0x6a, 0x14, 0x44,
+	0x78, 0x8c, 0xc7, 0xf8, 0x87, 0x3c, 0x34, 0x98, 0xa6, 0xa8, 0x98, 0x78, 0x1a, 0x4a, 0x11, 0x97,
+	0xae, 0xe6, 0xc1, 0x50, 0xe4, 0xa6, 0x80, 0x77, 0x16, 0xd4, 0xe1, 0x4b, 0x72, 0x4c, 0x14, 0xfe,
+	0xa7, 0x77, 0xb4, 0x3f, 0x5b, 0x50, 0x13, 0x31, 0x5e, 0xdf, 0x0f, 0x04, 0xb6, 0xef, 0x0c, 0xa8,
+	0x52, 0x95, 0x78, 0x36, 0x02, 0x7f, 0x5e, 0x38, 0x44, 0x35, 0x9a, 0xd5, 0x91, 0x59, 0xf7, 0xed,
+	0xc8, 0xac, 0xe4, 0xae, 0xd4, 0xa1, 0xc8, 0x4d, 0x72, 0x47, 0x38, 0xb1, 0x0a, 0x91, 0x03, 0xfc,
+	0x08, 0xcc, 0x2b, 0x2e, 0x94, 0x68, 0xa7, 0xe5, 0x2a, 0x03, 0x28, 0x49, 0x4d, 0xa0, 0xff, 0x83,
+	0x4a, 0x9c, 0xe3, 0xa9, 0xb8, 0x54, 0xda, 0x1b, 0xb7, 0xf2, 0x2c, 0x22, 0xc9, 0x04, 0x6a, 0x99,
+	0xf9, 0x93, 0xd5, 0xa9, 0xec, 0x8d, 0x5b, 0x12, 0xa0, 0xb2, 0x25, 0x74, 0x0c, 0xec, 0x6d, 0x9e,
+	0x82, 0x70, 0x11, 0xd8, 0x9d, 0xf2, 0xde, 0xb8, 0x25, 0xc6, 0x44, 0xfc, 0xe2, 0x0b, 0x50, 0xdb,
+	0xa0, 0x7d, 0xa7, 0xbb, 0xa3, 0x36, 0xad, 0x6b, 0x72, 0x7c, 0x43, 0x4b, 0xd3, 0x78, 0x08, 0x6a,
+	0xf1, 0x8e, 0x6f, 0x0d, 0x22, 0x75, 0x1b, 0xaa, 0x31, 0xec, 0xe5, 0x08, 0xff, 0xc8, 0x02, 0x65,
+	0x03, 0x08, 0xa7, 0x13, 0x8a, 0x0e, 0xec, 0x8d, 0x5b, 0x0a, 0x12, 0x27, 0x17, 0xcf, 0xc2, 0x5c,
+	0x24, 0x76, 0xe4, 0xc4, 0xb2, 0xa6, 0x25, 0x26, 0x3a, 0x07, 0xb8, 0x89, 0xec, 0x8d, 0x5b, 0x1a,
+	0x91, 0xe8, 0x07, 0xb4, 0x9a, 0xca, 0xad, 0x24, 0x63, 0x0b, 0x7b, 0xe3, 0x96, 0x01, 0x35, 0x73,
+	0x2d, 0x3c, 0xce, 0x43, 0xf5, 0x9a, 0xe3, 0xc6, 0x26, 0xd4, 0xd0, 0x2a, 0x4a, 0xfc, 0xab, 0x04,
+	0x70, 0x4b, 0xec, 0x51, 0xcf, 0xd9, 0x39, 0x1f, 0x84, 0x3a, 0xec, 0xea, 0x71, 0x12, 0x2b, 0xed,
+	0x89, 0xb1, 0xb2, 0x38, 0xbb, 0x3b, 0xfe, 0x6c, 0x9d, 0x1f, 0x5a, 0x81, 0xaa, 0x94, 0x18, 0x71,
+	0x98, 0x1b, 0x34, 0xe6, 0x84, 0x46, 0x4d, 0x10, 0x4f, 0x42, 0x07, 0xce, 0x9d, 0x0d, 0xd7, 0xa7,
+	0xd1, 0x55, 0x1a, 0x6e, 0xd2, 0x6e, 0xe0, 0xf7, 0x1a, 0x65, 0xc1, 0xde, 0xfe, 0x09, 0x7e, 0xf3,
+	0x22, 0x46, 0x87, 0x22, 0x2a, 0x16, 0x88, 0x78, 0xbe, 0x64, 0x97, 0xf3, 0x8b, 0x05, 0xfc, 0x77,
+	0x0b, 0x6a, 0x52, 0xc0, 0xca, 0xba, 0xbf, 0x0d, 0x25, 0x29, 0x7f, 0x21, 0xe2, 0x8f, 0x71, 0x7e,
+	0x27, 0x67, 0x71, 0x7c, 0x8a, 0x26, 0x7a, 0x1e, 0x16, 0x7a, 0x61, 0x30, 0x1c, 0xd2, 0xde, 0xa6,
+	0x72, 0xb1, 0xf9, 0xac, 0x8b, 0x5d, 0x37, 0xe7, 0x49, 0x06, 0x1d, 0xad, 0xc6, 0x7e, 0xad, 0x30,
+	0xc5, 0xaf, 0xa9, 0x6c, 0x51, 0x62, 0xe1, 0xbf, 0x58, 0x30, 0xaf, 0x1c, 0x9c, 0x32, 0xa1, 0x58,
+	0xed, 0xd6, 0x7d, 0x47, 0xe1, 0xfc, 0xac, 0x51, 0x78, 0x09, 0x4a, 0xfd, 0x30, 0x18, 0x0d, 0xb5,
+	0x93, 0x54, 0xa3, 0xd9, 0xa2, 0x33, 0xbe, 0x04, 0x0b, 0x9a, 0x95, 0x29, 0x5e, 0x7e, 0x39, 0x2b,
+	0x8d, 0x8b, 0x3d, 0xea, 0x33, 0x77, 0xcb, 0x8d, 0xfd, 0xb6, 0x96, 0xcb, 0xf7, 0x2c, 0x58, 0xcc,
+	0xa2, 0xa0, 0xf5, 0x4c, 0xdd, 0xf8, 0xf0, 0x74, 0x72, 0x66, 0xc9, 0xa8, 0x49, 0xab, 0xc2, 0xf1,
+	0xc9, 0x7b, 0x15, 0x8e, 0x75, 0xd3, 0xf1, 0x55, 0x94, 0xa7, 0xc2, 0xbf, 0xb5, 0x60, 0x3e, 0xa5,
+	0x7b, 0xf4, 0xb4, 0x91, 0xd9, 0x7f, 0x52, 0x71, 0xcb, 0x74, 0xfe, 0xab, 0x90, 0x67, 0xc1, 0x4c,
+	0x6a, 0xca, 0xb3, 0xc0, 0x28, 0xa7, 0x0a, 0xa9, 0x72, 0x0a, 0x43, 0x4d, 0x59, 0xa1, 0xb8, 0x56,
+	0xc2, 0x8b, 0xd8, 0x24, 0x05, 0xc3, 0x4f, 0x42, 0x45, 0x30, 0x7d, 0xd5, 0x71, 0xc3, 0x89, 0x81,
+	0x6e, 0x32, 0xd3, 0xcf, 0xc2, 0x01, 0xe9, 0xc4, 0x27, 0x2f, 0xae, 0x4d, 0x5a, 0x5c, 0xd3, 0x8b,
+	0x8f, 0x42, 0x51, 0x96, 0x40, 0x08, 0xec, 0x9e, 0xc3, 0x1c, 0xbd, 0x84, 0x3f, 0xe3, 0xc3, 0x70,
+	0x88, 0xdf, 0x6b, 0x1a, 0x46, 0x6b, 0xc1, 0xc8, 0x67, 0xba, 0x74, 0x3e, 0x05, 0xf5, 0x34, 0x58,
+	0x59, 0x52, 0x1d, 0x8a, 0x5d, 0x0e, 0x10, 0x34, 0xe6, 0x89, 0x1c, 0xe0, 0x9f, 0x5a, 0x80, 0x2e,
+	0x50, 0x26, 0x76, 0xb9, 0xb8, 0x1e, 0x5f, 0xa1, 0x65, 0x28, 0x0f, 0x1c, 0xd6, 0xdd, 0xa6, 0xa1,
+	0xae, 0x3c, 0xe3, 0xf1, 0xe7, 0x91, 0xe4, 0xe2, 0xd3, 0x70, 0x28, 0x75, 0x4a, 0xc5, 0x93, 0xa8,
+	0xc4, 0x24, 0x4c, 0x85, 0xea, 0x78, 0x8c, 0x7f, 0x9d, 0x87, 0xb2, 0x58, 0x40, 0xe8, 0x16, 0x3a,
+	0x0d, 0xd5, 0x2d, 0xd7, 0xef, 0xd3, 0x70, 0x18, 0xba, 0x4a, 0x04, 0x76, 0xe7, 0xc0, 0xde, 0xb8,
+	0x65, 0x82, 0x89, 0x39, 0x40, 0x8f, 0xc1, 0xdc, 0x28, 0xa2, 0xe1, 0x5b, 0xae, 0xf4, 0x06, 0x95,
+	0x4e, 0x7d, 0x77, 0xdc, 0x2a, 0xbd, 0x16, 0xd1, 0xf0, 0xe2, 0x3a, 0x0f, 0x9a, 0x23, 0xf1, 0x44,
+	0xe4, 0x7f, 0x0f, 0x5d, 0x56, 0xa6, 0x2c, 0x2b, 0xe9, 0xaf, 0xcd, 0x58, 0x5c, 0xf2, 0xc8, 0xcf,
+	0x97, 0x2b, 0xeb, 0xbe, 0x96, 0x14, 0xab, 0xb6, 0xa0, 0x77, 0x76, 0x76, 0x7a, 0x9a, 0x42, 0x52,
+	0xb7, 0x3e, 0x64, 0xd4, 0xad, 0xa2, 0xfd, 0xd0, 0x29, 0xee, 0x8d, 0x5b, 0xd6, 0x63, 0x49, 0xf9,
+	0x8a, 0xbf, 0x9b, 0x87, 0x96, 0xd1, 0xb5, 0x39, 0x1f, 0x84, 0x2f, 0x53, 0x16, 0xba, 0xdd, 0x2b,
+	0xce, 0x80, 0x6a, 0xdb, 0x68, 0x41, 0x75, 0x20, 0x80, 0x6f, 0x19, 0x57, 0x00, 0x06, 0x31, 0x1e,
+	0x3a, 0x0e, 0x20, 0xee, 0x95, 0x9c, 0x97, 0xb7, 0xa1, 0x22, 0x20, 0x62, 0x7a, 0x2d, 0x25, 0xa9,
+	0x07, 0x2f, 0xe7, 0xed, 0x07, 0x2f, 0xe7, 0x63, 0x5b, 0x2f, 0xa6, 0x6d, 0x1d, 0xff, 0xd5, 0x82,
+	0xe6, 0x86, 0x3e, 0xf9, 0x7d, 0x8a, 0xe3, 0x8b, 0xd6, 0xab, 0x69, 0x02, 0x70, 0x8f, 0x76, 0xde,
+	0xf5, 0x18, 0x0d, 0x27, 0x54, 0x5f, 0x3f, 0x28, 0x24, 0x2e, 0x81, 0xd0, 0x2d, 0xcd, 0xe7, 0x17,
+	0xb0, 0x0b, 0x13, 0xab, 0xad, 0x90, 0x71, 0x51, 0x3e, 0xcc, 0x6d, 0x09, 0xf6, 0x64, 0xd8, 0x4d,
+	0xf5, 0x08, 0x13, 0xde, 0x3b, 0xdf, 0x50, 0x9b, 0x3f, 0x75, 0x8f, 0x4c, 0x4e, 0x74, 0x6e, 0xdb,
+	0xd1, 0x8e, 0xcf, 0x9c, 0x3b, 0xc6, 0x7a, 0xa2, 0x37, 0x41, 0x8e, 0x4a, 0x16, 0x8b, 0x13, 0x93,
+	0xc5, 0xe7, 0xd4, 0x36, 0x0f, 0x54, 0x2d, 0x3f, 0x97, 0x78, 0x40, 0xa1, 0x14, 0xe5, 0x01, 0x1f,
+	0x06, 0x3b, 0xa4, 0x5b, 0x3a, 0x9c, 0xa3, 0x64, 0xe7, 0x18, 0x53, 0xcc, 0xe3, 0x3f, 0x58, 0xb0,
+	0x78, 0x81, 0xb2, 0x74, 0xa2, 0xf4, 0x25, 0x52, 0x29, 0x7e, 0x09, 0x0e, 0x1a, 0xe7, 0x4f, 0xda,
+	0x8b, 0xa9, 0xec, 0xc8, 0x68, 0x2f, 0x5e, 0xf4, 0x7b, 0xf4, 0xce, 0xc4, 0x84, 0xf1, 0x2a, 0x54,
+	0x8d, 0x49, 0x74, 0x2e, 0x93, 0x12, 0x1d, 0xca, 0xb4, 0xd2, 0x79, 0xc8, 0xee, 0xd4, 0x15, 0x4f,
+	0xb2, 0xdc, 0x55, 0x09, 0xb2, 0x4e, 0x1f, 0xf0, 0x26, 0x20, 0xa1, 0x2e, 0x41, 0xd6, 0x0c, 0x4e,
+	0x02, 0x7a, 0x39, 0xce, 0x8d, 0xe2, 0x31, 0x7a, 0x08, 0xec, 0x30, 0xb8, 0xad, 0x73, 0xe3, 0xf9,
+	0x64, 0x4b, 0x12, 0xdc, 0x26, 0x62, 0x0a, 0x3f, 0x0b, 0x05, 0x12, 0xdc, 0x46, 0x4d, 0x80, 0xd0,
+	0xf1, 0xfb, 0xf4, 0x7a, 0x5c, 0xf9, 0xd5, 0x88, 0x01, 0x99, 0x92, 0x38, 0xac, 0xc1, 0x41, 0xf3,
+	0x44, 0x52, 0xdd, 0xab, 0x30, 0xf7, 0xea, 0xc8, 0x14, 0x57, 0x3d, 0x23, 0x2e, 0xd9, 0x60, 0xd0,
+	0x48, 0xdc, 0x66, 0x20, 0x81, 0xa3, 0x63, 0x50, 0x61, 0xce, 0x0d, 0x8f, 0x5e, 0x49, 0xdc, 0x5c,
+	0x02, 0xe0, 0xb3, 0xbc, 0x68, 0xbd, 0x6e, 0x64, 0x40, 0x09, 0x00, 0x3d, 0x0a, 0x8b, 0xc9, 0x99,
+	0xaf, 0x86, 0x74, 0xcb, 0xbd, 0x23, 0x34, 0x5c, 0x23, 0xfb, 0xe0, 0xe8, 0x04, 0x1c, 0x48, 0x60,
+	0x9b, 0x22, 0xd3, 0xb0, 0x05, 0x6a, 0x16, 0xcc, 0x65, 0x23, 0xd8, 0x7d, 0xf1, 0xd6, 0xc8, 0xf1,
+	0xc4, 0xe5, 0xab, 0x11, 0x03, 0x82, 0xff, 0x68, 0xc1, 0x41, 0xa9, 0x6a, 0xe6, 0xb0, 0x2f, 0xa5,
+	0xd5, 0xff, 0xcc, 0x02, 0x64, 0x72, 0xa0, 0x4c, 0xeb, 0xff, 0xcd, 0x06, 0x16, 0x4f, 0x65, 0xaa,
+	0xa2, 0x16, 0x97, 0xa0, 0xa4, 0x07, 0x85, 0xe3, 0xee, 0xbb, 0x78, 0x79, 0x21, 0x8b, 0x7d, 0x09,
+	0xd1, 0xcd, 0x76, 0xd4, 0x82, 0xe2, 0x8d, 0x1d, 0x46, 0x23, 0x55, 0xaa, 0x8b, 0x1e, 0x85, 0x00,
+	0x10, 0xf9, 0xc7, 0xf7, 0xa2, 0x3e, 0x13, 0x56, 0x63, 0x27, 0x7b, 0x29, 0x10, 0xd1, 0x0f, 0xf8,
+	0x97, 0x79, 0x98, 0xbf, 0x1e, 0x78, 0xa3, 0x24, 0x30, 0x7e, 0x99, 0x02, 0x46, 0xaa, 0x7f, 0x50,
+	0xd4, 0xfd, 0x03, 0x5d, 0x54, 0x17, 0x93, 0xa2, 0x9a, 0x97, 0x0a, 0xcc, 0x09, 0xfb, 0x94, 0xc9,
+	0x0a, 0xa8, 0x51, 0x12, 0x69, 0x67, 0x0a, 0xc6, 0x8b, 0x7b, 0xa7, 0xdf, 0x0f, 0x69, 0xdf, 0x61,
+	0xb4, 0xb3, 0x23, 0x8a, 0xfb, 0x0a, 0x31, 0x41, 0xf8, 0x0d, 0x58, 0xd0, 0xc2, 0x52, 0x2a, 0x7d,
+	0x1c, 0xe6, 0xde, 0x16, 0x90, 0x09, 0xfd, 0x3c, 0x89, 0xaa, 0xdc, 0x98, 0x46, 0x4b, 0xbf, 0x1f,
+	0xd0, 0x67, 0xc6, 0x97, 0xa0, 0x24, 0xd1, 0xd1, 0x31, 0xb3, 0x46, 0x91, 0xcd, 0x25, 0x3e, 0x56,
+	0x05, 0x07, 0x86, 0x92, 0x24, 0xa4, 0x14, 0x2f, 0x6c, 0x43, 0x42, 0x88, 0xfa, 0xc7, 0xff, 0xb6,
+	0xe0, 0xf0, 0x3a, 0x65, 0xb4, 0xcb, 0x68, 0xef, 0xbc, 0x4b, 0xbd, 0xde, 0xe7, 0x5a, 0x62, 0xc7,
+	0xcd, 0xbb, 0x82, 0xd1, 0xbc, 0xe3, 0x7e, 0xc7, 0x73, 0x7d, 0xba, 0x61, 0x74, 0x7f, 0x12, 0x00,
+	0xf7, 0x10, 0x5b, 0xfc, 0xe0, 0x72, 0x5a, 0xbe, 0x73, 0x33, 0x20, 0xb1, 0x86, 0x4b, 0x89, 0x86,
+	0xb1, 0x0b, 0x4b, 0x59, 0xa6, 0x95, 0x8e, 0xda, 0x50, 0x12, 0x6b, 0x27, 0xb4, 0x8d, 0x53, 0x2b,
+	0x88, 0x42, 0xcb, 0x6c, 0x9f, 0xcf, 0x6e, 0x8f, 0xbf, 0xcf, 0x2b, 0x62, 0x73, 0xa5, 0x50, 0x2a,
+	0x37, 0x22, 0xe5, 0x60, 0xe5, 0x00, 0x7d, 0x05, 0x6c, 0xb6, 0x33, 0x54, 0x7e, 0xb5, 0x73, 0xf8,
+	0x3f, 0xe3, 0xd6, 0xc1, 0xd4, 0xb2, 0x6b, 0x3b, 0x43, 0x4a, 0x04, 0x0a, 0xb7, 0xbd, 0xae, 0x13,
+	0xf6, 0x5c, 0xdf, 0xf1, 0x5c, 0x26, 0x65, 0x65, 0x13, 0x13, 0x84, 0x8e, 0x43, 0x29, 0xba, 0x49,
+	0x59, 0x57, 0x66, 0xce, 0x35, 0x5d, 0x04, 0x28, 0x20, 0xfe, 0x89, 0xa1, 0x74, 0x69, 0xcf, 0xf7,
+	0xa9, 0x74, 0xeb, 0xbe, 0x95, 0x6e, 0xdd, 0x43, 0xe9, 0xf8, 0x9b, 0x89, 0x8a, 0xf4, 0x11, 0x95,
+	0x8a, 0x9e, 0x87, 0x85, 0x5e, 0x6a, 0x66, 0xba, 0xaa, 0x64, 0xcf, 0x37, 0x83, 0x8e, 0x2f, 0x24,
+	0x1a, 0x11, 0x90, 0x29, 0x1a, 0xc9, 0x88, 0x39, 0xbf, 0x4f, 0xcc, 0xf8, 0x71, 0xfd, 0x62, 0x3b,
+	0x15, 0x7c, 0x3e, 0xa6, 0xb0, 0xc6, 0xe7, 0xf5, 0x3b, 0xe0, 0xb4, 0xb3, 0x6f, 0x67, 0xdf, 0x56,
+	0xec, 0x7b, 0x89, 0x2a, 0xf1, 0x35, 0x16, 0xfe, 0x53, 0xfc, 0xfe, 0x54, 0x4c, 0x4c, 0x7d, 0x89,
+	0xdc, 0x48, 0x3c, 0xbb, 0x3c, 0xbf, 0x1e, 0x72, 0x9e, 0x8d, 0xa0, 0xa0, 0x23, 0xc1, 0x52, 0x1c,
+	0x4e, 0xe4, 0x3d, 0xd3, 0x21, 0xe4, 0x05, 0x28, 0x7b, 0x4e, 0xc4, 0xae, 0x8e, 0xa2, 0xed, 0x99,
+	0x3a, 0xad, 0xf1, 0xaa, 0xf8, 0x15, 0x7a, 0x29, 0x79, 0x85, 0xfe, 0xe8, 0xc3, 0x50, 0x89, 0xdf,
+	0x2c, 0xa2, 0x2a, 0xcc, 0x9d, 0x7f, 0x85, 0xbc, 0x7e, 0x8e, 0xac, 0x2f, 0xe6, 0x50, 0x0d, 0xca,
+	0x9d, 0x73, 0x6b, 0x97, 0xc5, 0xc8, 0x3a, 0xf3, 0xdf, 0x92, 0x4e, 0x6b, 0x42, 0xf4, 0x75, 0x28,
+	0xca, 0x5c, 0x65, 0x29, 0x11, 0x91, 0xf9, 0x02, 0x6f, 0xf9, 0xc8, 0x3e, 0xb8, 0x14, 0x32, 0xce,
+	0x3d, 0x6e, 0xa1, 0x2b, 0x50, 0x15, 0x40, 0xd5, 0x6e, 0x3f, 0x96, 0xed, 0x7a, 0xa7, 0x28, 0x1d,
+	0x9f, 0x32, 0x6b, 0xd0, 0x3b, 0x0b, 0x45, 0x69, 0x42, 0x4b, 0x99, 0x94, 0x72, 0xc2, 0x69, 0x52,
+	0x2f, 0x20, 0x70, 0x0e, 0x3d, 0x03, 0xf6, 0x35, 0xc7, 0xf5, 0x90, 0xa1, 0x6b, 0xa3, 0x4b, 0xbe,
+	0xbc, 0x94, 0x05, 0x1b, 0xdb, 0x3e, 0x17, 0x37, 0xfb, 0x8f, 0x64, 0xbb, 0x7b, 0x7a, 0x79, 0x63,
+	0xff, 0x44, 0xbc, 0xf3, 0x2b, 0xb2, 0x5d, 0xac, 0xfb, 0x47, 0xe8, 0x78, 0x7a, 0xab, 0x4c, 0xbb,
+	0x69, 0xb9, 0x39, 0x6d, 0x3a, 0x26, 0xb8, 0x01, 0x55, 0xa3, 0x77, 0x63, 0x8a, 0x75, 0x7f, 0xe3,
+	0xc9, 0x14, 0xeb, 0x84, 0x86, 0x0f, 0xce, 0xa1, 0x0b, 0x50, 0xe6, 0x75, 0x80, 0x30, 0xec, 0xa3,
+	0xd9, 0x74, 0xdf, 0xb8, 0x69, 0xcb, 0xc7, 0x26, 0x4f, 0xc6, 0x84, 0x5e, 0x80, 0xca, 0x05, 0xca,
+	0x54, 0xac, 0x3c, 0x92, 0x0d, 0xb6, 0x13, 0x24, 0x95, 0x0e, 0xd8, 0x38, 0x87, 0xde, 0x10, 0x25,
+	0x49, 0x3a, 0x56, 0xa0, 0xd6, 0x94, 0x98, 0x10, 0x9f, 0x6b, 0x65, 0x3a, 0x42, 0x4c, 0xf9, 0xf5,
+	0x14, 0x65, 0x95, 0x55, 0xb4, 0xa6, 0xb8, 0xb0, 0x98, 0x72, 0xeb, 0x1e, 0x1f, 0x01, 0x09, 0xe5,
+	0x2e, 0x08, 0xe9, 0x25, 0xce, 0xe1, 0xd8, 0x64, 0x67, 0x32, 0xc1, 0xca, 0xf7, 0xbb, 0x26, 0x9c,
+	0x3b, 0xf3, 0xab, 0xf8, 0xcb, 0x9a, 0x75, 0x87, 0x39, 0x29, 0xfa, 0xe2, 0xd3, 0x9b, 0xfd, 0xf4,
+	0xcd, 0xef, 0x7c, 0xf6, 0xd3, 0x4f, 0x7d, 0xef, 0x83, 0x73, 0x88, 0xc0, 0x81, 0x98, 0xa0, 0xfc,
+	0xb6, 0x04, 0x1d, 0x9f, 0xf8, 0x0d, 0x49, 0x34, 0xc1, 0x20, 0x27, 0x7d, 0x92, 0x82, 0x73, 0x9d,
+	0x37, 0xdf, 0xbb, 0xdb, 0xcc, 0xbd, 0x7f, 0xb7, 0x99, 0xfb, 0xe8, 0x6e, 0xd3, 0xfa, 0xce, 0x6e,
+	0xd3, 0xfa, 0xf9, 0x6e, 0xd3, 0x7a, 0x77, 0xb7, 0x69, 0xbd, 0xb7, 0xdb, 0xb4, 0xfe, 0xb9, 0xdb,
+	0xb4, 0xfe, 0xb5, 0xdb, 0xcc, 0x7d, 0xb4, 0xdb, 0xb4, 0xde, 0xf9, 0xb0, 0x99, 0x7b, 0xef, 0xc3,
+	0x66, 0xee, 0xfd, 0x0f, 0x9b, 0xb9, 0x6f, 0x3d, 0x72, 0xef, 0x26, 0x81, 0xf4, 0x76, 0x25, 0xf1,
+	0xf7, 0xc4, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x5c, 0xff, 0xe0, 0x6b, 0x53, 0x26, 0x00, 0x00,
 }
 
 func (x Direction) String() string {
@@ -3349,6 +3733,98 @@ func (this *StreamRate) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *StreamChunksRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamChunksRequest)
+	if !ok {
+		that2, ok := that.(StreamChunksRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Labels != that1.Labels {
+		return false
+	}
+	if !this.From.Equal(that1.From) {
+		return false
+	}
+	if !this.Through.Equal(that1.Through) {
+		return false
+	}
+	return true
+}
+func (this *StreamChunksResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamChunksResponse)
+	if !ok {
+		that2, ok := that.(StreamChunksResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Chunks) != len(that1.Chunks) {
+		return false
+	}
+	for i := range this.Chunks {
+		if !this.Chunks[i].Equal(&that1.Chunks[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *StreamChunk) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamChunk)
+	if !ok {
+		that2, ok := that.(StreamChunk)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.From.Equal(that1.From) {
+		return false
+	}
+	if !this.Through.Equal(that1.Through) {
+		return false
+	}
+	if this.Checksum != that1.Checksum {
+		return false
+	}
+	if this.Flushed != that1.Flushed {
+		return false
+	}
+	return true
+}
 func (this *QueryRequest) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
@@ -3798,6 +4274,15 @@ func (this *TailRequest) Equal(that interface{}) bool {
 	} else if !this.Plan.Equal(*that1.Plan) {
 		return false
 	}
+	if this.SampleRatio != that1.SampleRatio {
+		return false
+	}
+	if this.MaxLinesPerSecond != that1.MaxLinesPerSecond {
+		return false
+	}
+	if this.Step != that1.Step {
+		return false
+	}
 	return true
 }
 func (this *TailResponse) Equal(that interface{}) bool {
@@ -3834,6 +4319,14 @@ func (this *TailResponse) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if len(this.Series) != len(that1.Series) {
+		return false
+	}
+	for i := range this.Series {
+		if !this.Series[i].Equal(&that1.Series[i]) {
+			return false
+		}
+	}
 	return true
 }
 func (this *SeriesRequest) Equal(that interface{}) bool {
@@ -3992,6 +4485,9 @@ func (this *DroppedStream) Equal(that interface{}) bool {
 	if this.Labels != that1.Labels {
 		return false
 	}
+	if this.DroppedLines != that1.DroppedLines {
+		return false
+	}
 	return true
 }
 func (this *LabelPair) Equal(that interface{}) bool {
@@ -4951,9 +5447,101 @@ func (this *DetectedLabel) Equal(that interface{}) bool {
 	}
 	return true
 }
-func (this *LabelToValuesResponse) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *StreamStatsRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamStatsRequest)
+	if !ok {
+		that2, ok := that.(StreamStatsRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Matchers != that1.Matchers {
+		return false
+	}
+	return true
+}
+func (this *StreamStatsResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamStatsResponse)
+	if !ok {
+		that2, ok := that.(StreamStatsResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Streams) != len(that1.Streams) {
+		return false
+	}
+	for i := range this.Streams {
+		if !this.Streams[i].Equal(that1.Streams[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *StreamStats) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamStats)
+	if !ok {
+		that2, ok := that.(StreamStats)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Labels != that1.Labels {
+		return false
+	}
+	if this.Entries != that1.Entries {
+		return false
+	}
+	if this.Bytes != that1.Bytes {
+		return false
+	}
+	if this.Chunks != that1.Chunks {
+		return false
+	}
+	if !this.LastPush.Equal(that1.LastPush) {
+		return false
+	}
+	if this.Rate != that1.Rate {
+		return false
+	}
+	return true
+}
+func (this *LabelToValuesResponse) GoString() string {
+	if this == nil {
+		return "nil"
 	}
 	s := make([]string, 0, 5)
 	s = append(s, "&logproto.LabelToValuesResponse{")
@@ -5018,6 +5606,47 @@ func (this *StreamRate) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *StreamChunksRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&logproto.StreamChunksRequest{")
+	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
+	s = append(s, "From: "+fmt.Sprintf("%#v", this.From)+",\n")
+	s = append(s, "Through: "+fmt.Sprintf("%#v", this.Through)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamChunksResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&logproto.StreamChunksResponse{")
+	if this.Chunks != nil {
+		vs := make([]*StreamChunk, len(this.Chunks))
+		for i := range vs {
+			vs[i] = &this.Chunks[i]
+		}
+		s = append(s, "Chunks: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamChunk) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&logproto.StreamChunk{")
+	s = append(s, "From: "+fmt.Sprintf("%#v", this.From)+",\n")
+	s = append(s, "Through: "+fmt.Sprintf("%#v", this.Through)+",\n")
+	s = append(s, "Checksum: "+fmt.Sprintf("%#v", this.Checksum)+",\n")
+	s = append(s, "Flushed: "+fmt.Sprintf("%#v", this.Flushed)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func (this *QueryRequest) GoString() string {
 	if this == nil {
 		return "nil"
@@ -5169,13 +5798,16 @@ func (this *TailRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 9)
+	s := make([]string, 0, 12)
 	s = append(s, "&logproto.TailRequest{")
 	s = append(s, "Query: "+fmt.Sprintf("%#v", this.Query)+",\n")
 	s = append(s, "DelayFor: "+fmt.Sprintf("%#v", this.DelayFor)+",\n")
 	s = append(s, "Limit: "+fmt.Sprintf("%#v", this.Limit)+",\n")
 	s = append(s, "Start: "+fmt.Sprintf("%#v", this.Start)+",\n")
 	s = append(s, "Plan: "+fmt.Sprintf("%#v", this.Plan)+",\n")
+	s = append(s, "SampleRatio: "+fmt.Sprintf("%#v", this.SampleRatio)+",\n")
+	s = append(s, "MaxLinesPerSecond: "+fmt.Sprintf("%#v", this.MaxLinesPerSecond)+",\n")
+	s = append(s, "Step: "+fmt.Sprintf("%#v", this.Step)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -5183,12 +5815,19 @@ func (this *TailResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
+	s := make([]string, 0, 7)
 	s = append(s, "&logproto.TailResponse{")
 	s = append(s, "Stream: "+fmt.Sprintf("%#v", this.Stream)+",\n")
 	if this.DroppedStreams != nil {
 		s = append(s, "DroppedStreams: "+fmt.Sprintf("%#v", this.DroppedStreams)+",\n")
 	}
+	if this.Series != nil {
+		vs := make([]*Series, len(this.Series))
+		for i := range vs {
+			vs[i] = &this.Series[i]
+		}
+		s = append(s, "Series: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -5252,11 +5891,12 @@ func (this *DroppedStream) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 8)
 	s = append(s, "&logproto.DroppedStream{")
 	s = append(s, "From: "+fmt.Sprintf("%#v", this.From)+",\n")
 	s = append(s, "To: "+fmt.Sprintf("%#v", this.To)+",\n")
 	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
+	s = append(s, "DroppedLines: "+fmt.Sprintf("%#v", this.DroppedLines)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -5642,6 +6282,43 @@ func (this *DetectedLabel) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *StreamStatsRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&logproto.StreamStatsRequest{")
+	s = append(s, "Matchers: "+fmt.Sprintf("%#v", this.Matchers)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamStatsResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&logproto.StreamStatsResponse{")
+	if this.Streams != nil {
+		s = append(s, "Streams: "+fmt.Sprintf("%#v", this.Streams)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamStats) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 10)
+	s = append(s, "&logproto.StreamStats{")
+	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
+	s = append(s, "Entries: "+fmt.Sprintf("%#v", this.Entries)+",\n")
+	s = append(s, "Bytes: "+fmt.Sprintf("%#v", this.Bytes)+",\n")
+	s = append(s, "Chunks: "+fmt.Sprintf("%#v", this.Chunks)+",\n")
+	s = append(s, "LastPush: "+fmt.Sprintf("%#v", this.LastPush)+",\n")
+	s = append(s, "Rate: "+fmt.Sprintf("%#v", this.Rate)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringLogproto(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -5678,6 +6355,9 @@ type QuerierClient interface {
 	GetVolume(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*VolumeResponse, error)
 	GetDetectedFields(ctx context.Context, in *DetectedFieldsRequest, opts ...grpc.CallOption) (*DetectedFieldsResponse, error)
 	GetDetectedLabels(ctx context.Context, in *DetectedLabelsRequest, opts ...grpc.CallOption) (*LabelToValuesResponse, error)
+	// GetStreamStats returns the streams of the tenant held in memory by the
+	// ingester.
+	GetStreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (*StreamStatsResponse, error)
 }
 
 type querierClient struct {
@@ -5856,6 +6536,15 @@ func (c *querierClient) GetDetectedLabels(ctx context.Context, in *DetectedLabel
 	return out, nil
 }
 
+func (c *querierClient) GetStreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (*StreamStatsResponse, error) {
+	out := new(StreamStatsResponse)
+	err := c.cc.Invoke(ctx, "/logproto.Querier/GetStreamStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // QuerierServer is the server API for Querier service.
 type QuerierServer interface {
 	Query(*QueryRequest, Querier_QueryServer) error
@@ -5873,6 +6562,9 @@ type QuerierServer interface {
 	GetVolume(context.Context, *VolumeRequest) (*VolumeResponse, error)
 	GetDetectedFields(context.Context, *DetectedFieldsRequest) (*DetectedFieldsResponse, error)
 	GetDetectedLabels(context.Context, *DetectedLabelsRequest) (*LabelToValuesResponse, error)
+	// GetStreamStats returns the streams of the tenant held in memory by the
+	// ingester.
+	GetStreamStats(context.Context, *StreamStatsRequest) (*StreamStatsResponse, error)
 }
 
 // UnimplementedQuerierServer can be embedded to have forward compatible implementations.
@@ -5912,6 +6604,9 @@ func (*UnimplementedQuerierServer) GetDetectedFields(ctx context.Context, req *D
 func (*UnimplementedQuerierServer) GetDetectedLabels(ctx context.Context, req *DetectedLabelsRequest) (*LabelToValuesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetDetectedLabels not implemented")
 }
+func (*UnimplementedQuerierServer) GetStreamStats(ctx context.Context, req *StreamStatsRequest) (*StreamStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStreamStats not implemented")
+}
 
 func RegisterQuerierServer(s *grpc.Server, srv QuerierServer) {
 	s.RegisterService(&_Querier_serviceDesc, srv)
@@ -6124,6 +6819,24 @@ func _Querier_GetDetectedLabels_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Querier_GetStreamStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StreamStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).GetStreamStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/logproto.Querier/GetStreamStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).GetStreamStats(ctx, req.(*StreamStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Querier_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "logproto.Querier",
 	HandlerType: (*QuerierServer)(nil),
@@ -6160,6 +6873,10 @@ var _Querier_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetDetectedLabels",
 			Handler:    _Querier_GetDetectedLabels_Handler,
 		},
+		{
+			MethodName: "GetStreamStats",
+			Handler:    _Querier_GetStreamStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -6186,6 +6903,9 @@ var _Querier_serviceDesc = grpc.ServiceDesc{
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type StreamDataClient interface {
 	GetStreamRates(ctx context.Context, in *StreamRatesRequest, opts ...grpc.CallOption) (*StreamRatesResponse, error)
+	// GetStreamChunks returns the chunks an ingester holds for a stream. It's
+	// used by the replicas of the stream to deduplicate their flushes.
+	GetStreamChunks(ctx context.Context, in *StreamChunksRequest, opts ...grpc.CallOption) (*StreamChunksResponse, error)
 }
 
 type streamDataClient struct {
@@ -6205,9 +6925,21 @@ func (c *streamDataClient) GetStreamRates(ctx context.Context, in *StreamRatesRe
 	return out, nil
 }
 
+func (c *streamDataClient) GetStreamChunks(ctx context.Context, in *StreamChunksRequest, opts ...grpc.CallOption) (*StreamChunksResponse, error) {
+	out := new(StreamChunksResponse)
+	err := c.cc.Invoke(ctx, "/logproto.StreamData/GetStreamChunks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StreamDataServer is the server API for StreamData service.
 type StreamDataServer interface {
 	GetStreamRates(context.Context, *StreamRatesRequest) (*StreamRatesResponse, error)
+	// GetStreamChunks returns the chunks an ingester holds for a stream. It's
+	// used by the replicas of the stream to deduplicate their flushes.
+	GetStreamChunks(context.Context, *StreamChunksRequest) (*StreamChunksResponse, error)
 }
 
 // UnimplementedStreamDataServer can be embedded to have forward compatible implementations.
@@ -6217,6 +6949,9 @@ type UnimplementedStreamDataServer struct {
 func (*UnimplementedStreamDataServer) GetStreamRates(ctx context.Context, req *StreamRatesRequest) (*StreamRatesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStreamRates not implemented")
 }
+func (*UnimplementedStreamDataServer) GetStreamChunks(ctx context.Context, req *StreamChunksRequest) (*StreamChunksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStreamChunks not implemented")
+}
 
 func RegisterStreamDataServer(s *grpc.Server, srv StreamDataServer) {
 	s.RegisterService(&_StreamData_serviceDesc, srv)
@@ -6240,6 +6975,24 @@ func _StreamData_GetStreamRates_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StreamData_GetStreamChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StreamChunksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamDataServer).GetStreamChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/logproto.StreamData/GetStreamChunks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamDataServer).GetStreamChunks(ctx, req.(*StreamChunksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _StreamData_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "logproto.StreamData",
 	HandlerType: (*StreamDataServer)(nil),
@@ -6248,6 +7001,10 @@ var _StreamData_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetStreamRates",
 			Handler:    _StreamData_GetStreamRates_Handler,
 		},
+		{
+			MethodName: "GetStreamChunks",
+			Handler:    _StreamData_GetStreamChunks_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/logproto/logproto.proto",
@@ -6444,7 +7201,7 @@ func (m *StreamRate) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *QueryRequest) Marshal() (dAtA []byte, err error) {
+func (m *StreamChunksRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -6454,32 +7211,60 @@ func (m *QueryRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *QueryRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *StreamChunksRequest) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *QueryRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *StreamChunksRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if m.Plan != nil {
-		{
-			size := m.Plan.Size()
-			i -= size
-			if _, err := m.Plan.MarshalTo(dAtA[i:]); err != nil {
-				return 0, err
-			}
-			i = encodeVarintLogproto(dAtA, i, uint64(size))
-		}
+	if m.Through != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Through))
 		i--
-		dAtA[i] = 0x4a
+		dAtA[i] = 0x18
 	}
-	if len(m.Deletes) > 0 {
-		for iNdEx := len(m.Deletes) - 1; iNdEx >= 0; iNdEx-- {
+	if m.From != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.From))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Labels) > 0 {
+		i -= len(m.Labels)
+		copy(dAtA[i:], m.Labels)
+		i = encodeVarintLogproto(dAtA, i, uint64(len(m.Labels)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamChunksResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamChunksResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamChunksResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Chunks) > 0 {
+		for iNdEx := len(m.Chunks) - 1; iNdEx >= 0; iNdEx-- {
 			{
-				size, err := m.Deletes[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				size, err := m.Chunks[iNdEx].MarshalToSizedBuffer(dAtA[:i])
 				if err != nil {
 					return 0, err
 				}
@@ -6487,12 +7272,109 @@ func (m *QueryRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 				i = encodeVarintLogproto(dAtA, i, uint64(size))
 			}
 			i--
-			dAtA[i] = 0x42
+			dAtA[i] = 0xa
 		}
 	}
-	if len(m.Shards) > 0 {
-		for iNdEx := len(m.Shards) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.Shards[iNdEx])
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamChunk) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamChunk) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamChunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Flushed {
+		i--
+		if m.Flushed {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Checksum != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Checksum))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Through != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Through))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.From != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.From))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *QueryRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Plan != nil {
+		{
+			size := m.Plan.Size()
+			i -= size
+			if _, err := m.Plan.MarshalTo(dAtA[i:]); err != nil {
+				return 0, err
+			}
+			i = encodeVarintLogproto(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x4a
+	}
+	if len(m.Deletes) > 0 {
+		for iNdEx := len(m.Deletes) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Deletes[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintLogproto(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x42
+		}
+	}
+	if len(m.Shards) > 0 {
+		for iNdEx := len(m.Shards) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Shards[iNdEx])
 			copy(dAtA[i:], m.Shards[iNdEx])
 			i = encodeVarintLogproto(dAtA, i, uint64(len(m.Shards[iNdEx])))
 			i--
@@ -7039,6 +7921,22 @@ func (m *TailRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Step != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Step))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.MaxLinesPerSecond != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.MaxLinesPerSecond))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.SampleRatio != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.SampleRatio))))
+		i--
+		dAtA[i] = 0x39
+	}
 	if m.Plan != nil {
 		{
 			size := m.Plan.Size()
@@ -7099,6 +7997,20 @@ func (m *TailResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Series) > 0 {
+		for iNdEx := len(m.Series) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Series[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintLogproto(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
 	if len(m.DroppedStreams) > 0 {
 		for iNdEx := len(m.DroppedStreams) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -7316,6 +8228,11 @@ func (m *DroppedStream) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.DroppedLines != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.DroppedLines))
+		i--
+		dAtA[i] = 0x20
+	}
 	if len(m.Labels) > 0 {
 		i -= len(m.Labels)
 		copy(dAtA[i:], m.Labels)
@@ -8640,6 +9557,131 @@ func (m *DetectedLabel) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *StreamStatsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamStatsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamStatsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Matchers) > 0 {
+		i -= len(m.Matchers)
+		copy(dAtA[i:], m.Matchers)
+		i = encodeVarintLogproto(dAtA, i, uint64(len(m.Matchers)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamStatsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamStatsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamStatsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Streams) > 0 {
+		for iNdEx := len(m.Streams) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Streams[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintLogproto(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Rate != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Rate))
+		i--
+		dAtA[i] = 0x30
+	}
+	n26, err26 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastPush, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastPush):])
+	if err26 != nil {
+		return 0, err26
+	}
+	i -= n26
+	i = encodeVarintLogproto(dAtA, i, uint64(n26))
+	i--
+	dAtA[i] = 0x2a
+	if m.Chunks != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Chunks))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Bytes != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Bytes))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Entries != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Entries))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Labels) > 0 {
+		i -= len(m.Labels)
+		copy(dAtA[i:], m.Labels)
+		i = encodeVarintLogproto(dAtA, i, uint64(len(m.Labels)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintLogproto(dAtA []byte, offset int, v uint64) int {
 	offset -= sovLogproto(v)
 	base := offset
@@ -8737,31 +9779,86 @@ func (m *StreamRate) Size() (n int) {
 	return n
 }
 
-func (m *QueryRequest) Size() (n int) {
+func (m *StreamChunksRequest) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	l = len(m.Selector)
+	l = len(m.Labels)
 	if l > 0 {
 		n += 1 + l + sovLogproto(uint64(l))
 	}
-	if m.Limit != 0 {
-		n += 1 + sovLogproto(uint64(m.Limit))
+	if m.From != 0 {
+		n += 1 + sovLogproto(uint64(m.From))
 	}
-	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.Start)
-	n += 1 + l + sovLogproto(uint64(l))
-	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.End)
-	n += 1 + l + sovLogproto(uint64(l))
-	if m.Direction != 0 {
-		n += 1 + sovLogproto(uint64(m.Direction))
+	if m.Through != 0 {
+		n += 1 + sovLogproto(uint64(m.Through))
 	}
-	if len(m.Shards) > 0 {
-		for _, s := range m.Shards {
-			l = len(s)
-			n += 1 + l + sovLogproto(uint64(l))
-		}
+	return n
+}
+
+func (m *StreamChunksResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Chunks) > 0 {
+		for _, e := range m.Chunks {
+			l = e.Size()
+			n += 1 + l + sovLogproto(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *StreamChunk) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.From != 0 {
+		n += 1 + sovLogproto(uint64(m.From))
+	}
+	if m.Through != 0 {
+		n += 1 + sovLogproto(uint64(m.Through))
+	}
+	if m.Checksum != 0 {
+		n += 1 + sovLogproto(uint64(m.Checksum))
+	}
+	if m.Flushed {
+		n += 2
+	}
+	return n
+}
+
+func (m *QueryRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Selector)
+	if l > 0 {
+		n += 1 + l + sovLogproto(uint64(l))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovLogproto(uint64(m.Limit))
+	}
+	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.Start)
+	n += 1 + l + sovLogproto(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.End)
+	n += 1 + l + sovLogproto(uint64(l))
+	if m.Direction != 0 {
+		n += 1 + sovLogproto(uint64(m.Direction))
+	}
+	if len(m.Shards) > 0 {
+		for _, s := range m.Shards {
+			l = len(s)
+			n += 1 + l + sovLogproto(uint64(l))
+		}
 	}
 	if len(m.Deletes) > 0 {
 		for _, e := range m.Deletes {
@@ -9007,6 +10104,15 @@ func (m *TailRequest) Size() (n int) {
 		l = m.Plan.Size()
 		n += 1 + l + sovLogproto(uint64(l))
 	}
+	if m.SampleRatio != 0 {
+		n += 9
+	}
+	if m.MaxLinesPerSecond != 0 {
+		n += 1 + sovLogproto(uint64(m.MaxLinesPerSecond))
+	}
+	if m.Step != 0 {
+		n += 1 + sovLogproto(uint64(m.Step))
+	}
 	return n
 }
 
@@ -9026,6 +10132,12 @@ func (m *TailResponse) Size() (n int) {
 			n += 1 + l + sovLogproto(uint64(l))
 		}
 	}
+	if len(m.Series) > 0 {
+		for _, e := range m.Series {
+			l = e.Size()
+			n += 1 + l + sovLogproto(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -9115,6 +10227,9 @@ func (m *DroppedStream) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovLogproto(uint64(l))
 	}
+	if m.DroppedLines != 0 {
+		n += 1 + sovLogproto(uint64(m.DroppedLines))
+	}
 	return n
 }
 
@@ -9702,6 +10817,61 @@ func (m *DetectedLabel) Size() (n int) {
 	return n
 }
 
+func (m *StreamStatsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Matchers)
+	if l > 0 {
+		n += 1 + l + sovLogproto(uint64(l))
+	}
+	return n
+}
+
+func (m *StreamStatsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Streams) > 0 {
+		for _, e := range m.Streams {
+			l = e.Size()
+			n += 1 + l + sovLogproto(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *StreamStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Labels)
+	if l > 0 {
+		n += 1 + l + sovLogproto(uint64(l))
+	}
+	if m.Entries != 0 {
+		n += 1 + sovLogproto(uint64(m.Entries))
+	}
+	if m.Bytes != 0 {
+		n += 1 + sovLogproto(uint64(m.Bytes))
+	}
+	if m.Chunks != 0 {
+		n += 1 + sovLogproto(uint64(m.Chunks))
+	}
+	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.LastPush)
+	n += 1 + l + sovLogproto(uint64(l))
+	if m.Rate != 0 {
+		n += 1 + sovLogproto(uint64(m.Rate))
+	}
+	return n
+}
+
 func sovLogproto(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -9776,6 +10946,46 @@ func (this *StreamRate) String() string {
 	}, "")
 	return s
 }
+func (this *StreamChunksRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&StreamChunksRequest{`,
+		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`From:` + fmt.Sprintf("%v", this.From) + `,`,
+		`Through:` + fmt.Sprintf("%v", this.Through) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamChunksResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForChunks := "[]StreamChunk{"
+	for _, f := range this.Chunks {
+		repeatedStringForChunks += strings.Replace(strings.Replace(f.String(), "StreamChunk", "StreamChunk", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForChunks += "}"
+	s := strings.Join([]string{`&StreamChunksResponse{`,
+		`Chunks:` + repeatedStringForChunks + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamChunk) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&StreamChunk{`,
+		`From:` + fmt.Sprintf("%v", this.From) + `,`,
+		`Through:` + fmt.Sprintf("%v", this.Through) + `,`,
+		`Checksum:` + fmt.Sprintf("%v", this.Checksum) + `,`,
+		`Flushed:` + fmt.Sprintf("%v", this.Flushed) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *QueryRequest) String() string {
 	if this == nil {
 		return "nil"
@@ -9938,6 +11148,9 @@ func (this *TailRequest) String() string {
 		`Limit:` + fmt.Sprintf("%v", this.Limit) + `,`,
 		`Start:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.Start), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
 		`Plan:` + fmt.Sprintf("%v", this.Plan) + `,`,
+		`SampleRatio:` + fmt.Sprintf("%v", this.SampleRatio) + `,`,
+		`MaxLinesPerSecond:` + fmt.Sprintf("%v", this.MaxLinesPerSecond) + `,`,
+		`Step:` + fmt.Sprintf("%v", this.Step) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -9951,9 +11164,15 @@ func (this *TailResponse) String() string {
 		repeatedStringForDroppedStreams += strings.Replace(f.String(), "DroppedStream", "DroppedStream", 1) + ","
 	}
 	repeatedStringForDroppedStreams += "}"
+	repeatedStringForSeries := "[]Series{"
+	for _, f := range this.Series {
+		repeatedStringForSeries += strings.Replace(strings.Replace(f.String(), "Series", "Series", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForSeries += "}"
 	s := strings.Join([]string{`&TailResponse{`,
 		`Stream:` + fmt.Sprintf("%v", this.Stream) + `,`,
 		`DroppedStreams:` + repeatedStringForDroppedStreams + `,`,
+		`Series:` + repeatedStringForSeries + `,`,
 		`}`,
 	}, "")
 	return s
@@ -10020,6 +11239,7 @@ func (this *DroppedStream) String() string {
 		`From:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.From), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
 		`To:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.To), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
 		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`DroppedLines:` + fmt.Sprintf("%v", this.DroppedLines) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -10419,6 +11639,46 @@ func (this *DetectedLabel) String() string {
 	}, "")
 	return s
 }
+func (this *StreamStatsRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&StreamStatsRequest{`,
+		`Matchers:` + fmt.Sprintf("%v", this.Matchers) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamStatsResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForStreams := "[]*StreamStats{"
+	for _, f := range this.Streams {
+		repeatedStringForStreams += strings.Replace(f.String(), "StreamStats", "StreamStats", 1) + ","
+	}
+	repeatedStringForStreams += "}"
+	s := strings.Join([]string{`&StreamStatsResponse{`,
+		`Streams:` + repeatedStringForStreams + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamStats) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&StreamStats{`,
+		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`Entries:` + fmt.Sprintf("%v", this.Entries) + `,`,
+		`Bytes:` + fmt.Sprintf("%v", this.Bytes) + `,`,
+		`Chunks:` + fmt.Sprintf("%v", this.Chunks) + `,`,
+		`LastPush:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.LastPush), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
+		`Rate:` + fmt.Sprintf("%v", this.Rate) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringLogproto(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -10995,7 +12255,7 @@ func (m *StreamRate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *QueryRequest) Unmarshal(dAtA []byte) error {
+func (m *StreamChunksRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -11018,15 +12278,15 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: QueryRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: StreamChunksRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: QueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StreamChunksRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -11054,13 +12314,13 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Selector = string(dAtA[iNdEx:postIndex])
+			m.Labels = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field From", wireType)
 			}
-			m.Limit = 0
+			m.From = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11070,16 +12330,16 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Limit |= uint32(b&0x7F) << shift
+				m.From |= github_com_prometheus_common_model.Time(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Through", wireType)
 			}
-			var msglen int
+			m.Through = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11089,28 +12349,67 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.Through |= github_com_prometheus_common_model.Time(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (iNdEx + skippy) < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.Start, dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamChunksResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
 			}
-			iNdEx = postIndex
-		case 4:
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamChunksResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamChunksResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Chunks", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -11137,15 +12436,69 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.End, dAtA[iNdEx:postIndex]); err != nil {
+			m.Chunks = append(m.Chunks, StreamChunk{})
+			if err := m.Chunks[len(m.Chunks)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 5:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamChunk) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamChunk: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamChunk: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Direction", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field From", wireType)
 			}
-			m.Direction = 0
+			m.From = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11155,16 +12508,16 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Direction |= Direction(b&0x7F) << shift
+				m.From |= github_com_prometheus_common_model.Time(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 7:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Shards", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Through", wireType)
 			}
-			var stringLen uint64
+			m.Through = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11174,29 +12527,16 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.Through |= github_com_prometheus_common_model.Time(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Shards = append(m.Shards, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
-		case 8:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Deletes", wireType)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Checksum", wireType)
 			}
-			var msglen int
+			m.Checksum = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11206,31 +12546,16 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.Checksum |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Deletes = append(m.Deletes, &Delete{})
-			if err := m.Deletes[len(m.Deletes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 9:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Plan", wireType)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Flushed", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11240,28 +12565,12 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Plan == nil {
-				m.Plan = &github_com_grafana_loki_v3_pkg_querier_plan.QueryPlan{}
-			}
-			if err := m.Plan.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
+			m.Flushed = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -11286,7 +12595,7 @@ func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
+func (m *QueryRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -11309,10 +12618,10 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SampleQueryRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: QueryRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SampleQueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: QueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -11348,6 +12657,25 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 			m.Selector = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
 			}
@@ -11380,7 +12708,7 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		case 3:
+		case 4:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
 			}
@@ -11413,7 +12741,26 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		case 4:
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Direction", wireType)
+			}
+			m.Direction = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Direction |= Direction(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Shards", wireType)
 			}
@@ -11445,7 +12792,7 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 			}
 			m.Shards = append(m.Shards, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 5:
+		case 8:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Deletes", wireType)
 			}
@@ -11479,7 +12826,7 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		case 6:
+		case 9:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Plan", wireType)
 			}
@@ -11539,7 +12886,7 @@ func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Plan) Unmarshal(dAtA []byte) error {
+func (m *SampleQueryRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -11562,17 +12909,17 @@ func (m *Plan) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Plan: wiretype end group for non-group")
+			return fmt.Errorf("proto: SampleQueryRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Plan: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SampleQueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Raw", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11582,82 +12929,93 @@ func (m *Plan) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthLogproto
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Raw = append(m.Raw[:0], dAtA[iNdEx:postIndex]...)
-			if m.Raw == nil {
-				m.Raw = []byte{}
-			}
+			m.Selector = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipLogproto(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
 			}
-			if skippy < 0 {
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			if (iNdEx + skippy) < 0 {
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			if (iNdEx + skippy) > l {
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *Delete) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowLogproto
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.Start, dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-			if iNdEx >= l {
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.End, dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Delete: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Delete: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			iNdEx = postIndex
+		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Shards", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -11685,13 +13043,13 @@ func (m *Delete) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Selector = string(dAtA[iNdEx:postIndex])
+			m.Shards = append(m.Shards, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Deletes", wireType)
 			}
-			m.Start = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11701,16 +13059,31 @@ func (m *Delete) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Start |= int64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
 			}
-			m.End = 0
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Deletes = append(m.Deletes, &Delete{})
+			if err := m.Deletes[len(m.Deletes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Plan", wireType)
+			}
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11720,11 +13093,28 @@ func (m *Delete) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.End |= int64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Plan == nil {
+				m.Plan = &github_com_grafana_loki_v3_pkg_querier_plan.QueryPlan{}
+			}
+			if err := m.Plan.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -11749,7 +13139,7 @@ func (m *Delete) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *QueryResponse) Unmarshal(dAtA []byte) error {
+func (m *Plan) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -11772,17 +13162,17 @@ func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: QueryResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: Plan: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: QueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Plan: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Raw", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11792,31 +13182,84 @@ func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthLogproto
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Streams = append(m.Streams, github_com_grafana_loki_pkg_push.Stream{})
-			if err := m.Streams[len(m.Streams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Raw = append(m.Raw[:0], dAtA[iNdEx:postIndex]...)
+			if m.Raw == nil {
+				m.Raw = []byte{}
 			}
 			iNdEx = postIndex
-		case 2:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Delete) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Delete: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Delete: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stats", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11826,30 +13269,29 @@ func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthLogproto
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthLogproto
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.Stats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Selector = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Warnings", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
 			}
-			var stringLen uint64
+			m.Start = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -11859,24 +13301,30 @@ func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.Start |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthLogproto
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthLogproto
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.End = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.End |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			m.Warnings = append(m.Warnings, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -11901,7 +13349,7 @@ func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SampleQueryResponse) Unmarshal(dAtA []byte) error {
+func (m *QueryResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -11924,15 +13372,15 @@ func (m *SampleQueryResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SampleQueryResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: QueryResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SampleQueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: QueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -11959,8 +13407,8 @@ func (m *SampleQueryResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Series = append(m.Series, Series{})
-			if err := m.Series[len(m.Series)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Streams = append(m.Streams, github_com_grafana_loki_pkg_push.Stream{})
+			if err := m.Streams[len(m.Streams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -12053,7 +13501,7 @@ func (m *SampleQueryResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *LabelRequest) Unmarshal(dAtA []byte) error {
+func (m *SampleQueryResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -12076,17 +13524,169 @@ func (m *LabelRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: LabelRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: SampleQueryResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LabelRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SampleQueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
 			}
-			var stringLen uint64
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Series = append(m.Series, Series{})
+			if err := m.Series[len(m.Series)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Stats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Warnings", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Warnings = append(m.Warnings, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowLogproto
@@ -12838,6 +14438,55 @@ func (m *TailRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 7:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SampleRatio", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.SampleRatio = float64(math.Float64frombits(v))
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxLinesPerSecond", wireType)
+			}
+			m.MaxLinesPerSecond = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxLinesPerSecond |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Step", wireType)
+			}
+			m.Step = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Step |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -12961,6 +14610,40 @@ func (m *TailResponse) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Series = append(m.Series, Series{})
+			if err := m.Series[len(m.Series)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -13586,6 +15269,25 @@ func (m *DroppedStream) Unmarshal(dAtA []byte) error {
 			}
 			m.Labels = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DroppedLines", wireType)
+			}
+			m.DroppedLines = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DroppedLines |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLogproto(dAtA[iNdEx:])
@@ -17493,6 +19195,372 @@ func (m *DetectedLabel) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *StreamStatsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamStatsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamStatsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamStatsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamStatsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamStatsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Streams = append(m.Streams, &StreamStats{})
+			if err := m.Streams[len(m.Streams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamStats) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamStats: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamStats: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Labels = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
+			}
+			m.Entries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Entries |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Bytes", wireType)
+			}
+			m.Bytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Bytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chunks", wireType)
+			}
+			m.Chunks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Chunks |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LastPush", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.LastPush, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rate", wireType)
+			}
+			m.Rate = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Rate |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipLogproto(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -350,6 +350,9 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 	if err != nil {
 		return nil, err
 	}
+	if n, ok := explainTreeFromContext(ctx); ok {
+		stepEvaluator.Explain(n)
+	}
 	defer util.LogErrorWithContext(ctx, "closing SampleExpr", stepEvaluator.Close)
 
 	next, ts, r := stepEvaluator.Next()
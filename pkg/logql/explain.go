@@ -1,9 +1,24 @@
 package logql
 
+import "context"
+
 // MaxChildrenDisplay defines the maximum number of children that should be
 // shown by explain.
 const MaxChildrenDisplay = 3
 
+type explainTreeKey struct{}
+
+// WithExplainTree returns a context making the engine print the step
+// evaluator tree of the metric queries it executes to the given node.
+func WithExplainTree(ctx context.Context, n Node) context.Context {
+	return context.WithValue(ctx, explainTreeKey{}, n)
+}
+
+func explainTreeFromContext(ctx context.Context) (Node, bool) {
+	n, ok := ctx.Value(explainTreeKey{}).(Node)
+	return n, ok
+}
+
 func (e *LiteralStepEvaluator) Explain(parent Node) {
 	b := parent.Child("Literal")
 	e.nextEv.Explain(b)
@@ -0,0 +1,73 @@
+package logql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// MetricTailRangeAggregation returns the range aggregation of a metric tail
+// query, which the ingesters compute from the entries they receive. Metric
+// tails support vector aggregations and label_replace around a single range
+// aggregation, which must not have a grouping or an offset so that every
+// series comes from a single stream.
+func MetricTailRangeAggregation(expr syntax.SampleExpr) (*syntax.RangeAggregationExpr, error) {
+	switch e := expr.(type) {
+	case *syntax.VectorAggregationExpr:
+		return MetricTailRangeAggregation(e.Left)
+	case *syntax.LabelReplaceExpr:
+		return MetricTailRangeAggregation(e.Left)
+	case *syntax.RangeAggregationExpr:
+		if e.Grouping != nil {
+			return nil, fmt.Errorf("metric tail doesn't support grouping in range aggregations: %s", e.String())
+		}
+		if e.Left.Offset != 0 {
+			return nil, fmt.Errorf("metric tail doesn't support offset: %s", e.String())
+		}
+		if e.Operation == syntax.OpRangeTypeAbsent {
+			return nil, fmt.Errorf(syntax.UnsupportedErr, e.Operation)
+		}
+		if _, err := aggregator(e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("metric tail doesn't support %T expressions: %s", expr, expr.String())
+	}
+}
+
+// EvaluateMetricTail evaluates the expressions around the range aggregation of
+// a metric tail query, given the vector of the range aggregation at ts.
+func EvaluateMetricTail(ctx context.Context, expr syntax.SampleExpr, ts time.Time, vec promql.Vector) (promql.Vector, error) {
+	factory := SampleEvaluatorFunc(func(ctx context.Context, next SampleEvaluatorFactory, expr syntax.SampleExpr, q Params) (StepEvaluator, error) {
+		switch e := expr.(type) {
+		case *syntax.VectorAggregationExpr:
+			return newVectorAggEvaluator(ctx, next, e, q)
+		case *syntax.LabelReplaceExpr:
+			return newLabelReplaceEvaluator(ctx, next, e, q)
+		case *syntax.RangeAggregationExpr:
+			return NewVectorStepEvaluator(ts, vec), nil
+		default:
+			return nil, fmt.Errorf("metric tail doesn't support %T expressions", expr)
+		}
+	})
+
+	ev, err := factory.NewStepEvaluator(ctx, factory, expr, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ev.Close()
+
+	ok, _, r := ev.Next()
+	if err := ev.Error(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return promql.Vector{}, nil
+	}
+	return r.SampleVector(), nil
+}
@@ -0,0 +1,75 @@
+package logql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+func TestMetricTailRangeAggregation(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		err   bool
+	}{
+		{query: `rate({app="api"}[10s])`},
+		{query: `sum by (status) (rate({app="api"} | json [10s]))`},
+		{query: `topk(2, label_replace(sum_over_time({app="api"} | unwrap latency [1m]), "a", "$1", "b", "(.*)"))`},
+		{query: `sum(count_over_time({app="api"}[1m])) / 2`, err: true},
+		{query: `avg_over_time({app="api"} | unwrap latency [1m]) by (status)`, err: true},
+		{query: `rate({app="api"}[1m] offset 1m)`, err: true},
+		{query: `absent_over_time({app="api"}[1m])`, err: true},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := syntax.ParseSampleExpr(tc.query)
+			require.NoError(t, err)
+			_, err = MetricTailRangeAggregation(expr)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLiveRangeVector(t *testing.T) {
+	expr, err := syntax.ParseSampleExpr(`sum by (status) (count_over_time({app="api"} | logfmt [10s]))`)
+	require.NoError(t, err)
+	rangeExpr, err := MetricTailRangeAggregation(expr)
+	require.NoError(t, err)
+	v, err := NewLiveRangeVector(rangeExpr)
+	require.NoError(t, err)
+
+	ok := labels.FromStrings("app", "api", "instance", "a", "status", "200")
+	ko := labels.FromStrings("app", "api", "instance", "a", "status", "500")
+	okOther := labels.FromStrings("app", "api", "instance", "b", "status", "200")
+	start := time.Unix(100, 0)
+	for i := 0; i < 20; i++ {
+		ts := start.Add(time.Duration(i) * time.Second).UnixNano()
+		v.Append(ok, ts, 1)
+		v.Append(okOther, ts, 1)
+		if i%2 == 0 {
+			v.Append(ko, ts, 1)
+		}
+	}
+	// Entries received out of order.
+	v.Append(ko, start.Add(15*time.Second+time.Millisecond).UnixNano(), 1)
+
+	end := start.Add(19 * time.Second)
+	vec, err := EvaluateMetricTail(context.Background(), expr, end, v.At(end.UnixNano()))
+	require.NoError(t, err)
+	require.ElementsMatch(t, promql.Vector{
+		{T: end.UnixMilli(), F: 20, Metric: labels.FromStrings("status", "200")},
+		{T: end.UnixMilli(), F: 6, Metric: labels.FromStrings("status", "500")},
+	}, vec)
+
+	// The samples before the range are dropped.
+	end = start.Add(40 * time.Second)
+	require.Empty(t, v.At(end.UnixNano()))
+}
@@ -149,6 +149,7 @@ func RecordRangeAndInstantQueryMetrics(
 		"duration", logql_stats.ConvertSecondsToNanoseconds(stats.Summary.ExecTime),
 		"status", status,
 		"limit", p.Limit(),
+		"direction", strings.ToLower(p.Direction().String()),
 		"returned_lines", returnedLines,
 		"throughput", humanizeBytes(uint64(stats.Summary.BytesProcessedPerSecond)),
 		"total_bytes", humanizeBytes(uint64(stats.Summary.TotalBytesProcessed)),
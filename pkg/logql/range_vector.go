@@ -851,3 +851,64 @@ func (a *OneOverTime) agg(_ promql.FPoint) {
 func (a *OneOverTime) at() float64 {
 	return 1.0
 }
+
+// LiveRangeVector computes a range aggregation incrementally from the samples
+// appended as entries are received, as metric tails do. It keeps the samples
+// within the range and aggregates them on demand.
+type LiveRangeVector struct {
+	mtx sync.Mutex
+	it  *batchRangeVectorIterator
+}
+
+// NewLiveRangeVector returns a LiveRangeVector for the given range
+// aggregation.
+func NewLiveRangeVector(expr *syntax.RangeAggregationExpr) (*LiveRangeVector, error) {
+	agg, err := aggregator(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &LiveRangeVector{
+		it: &batchRangeVectorIterator{
+			selRange: expr.Left.Interval.Nanoseconds(),
+			window:   map[string]*promql.Series{},
+			metrics:  map[string]labels.Labels{},
+			agg:      agg,
+		},
+	}, nil
+}
+
+// Append adds a sample of the series with the given labels, at the timestamp
+// in nanoseconds.
+func (v *LiveRangeVector) Append(lbs labels.Labels, ts int64, value float64) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	key := lbs.String()
+	series, ok := v.it.window[key]
+	if !ok {
+		series = getSeries()
+		series.Metric = lbs
+		v.it.window[key] = series
+	}
+	// Entries may be received out of order, the points are kept sorted for
+	// the window to slide.
+	i := len(series.Floats)
+	for i > 0 && series.Floats[i-1].T > ts {
+		i--
+	}
+	series.Floats = append(series.Floats, promql.FPoint{})
+	copy(series.Floats[i+1:], series.Floats[i:])
+	series.Floats[i] = promql.FPoint{T: ts, F: value}
+}
+
+// At returns the aggregation of the range ending at the timestamp in
+// nanoseconds, and forgets about the samples before the range.
+func (v *LiveRangeVector) At(ts int64) promql.Vector {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	v.it.current = ts
+	v.it.popBack(ts - v.it.selRange)
+	_, vec := v.it.At()
+	return append(promql.Vector(nil), vec.SampleVector()...)
+}
@@ -44,6 +44,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/compactor/client/grpc"
 	"github.com/grafana/loki/v3/pkg/compactor/deletion"
 	"github.com/grafana/loki/v3/pkg/compactor/generationnumber"
+	"github.com/grafana/loki/v3/pkg/compactor/rollup"
 	"github.com/grafana/loki/v3/pkg/distributor"
 	"github.com/grafana/loki/v3/pkg/indexgateway"
 	"github.com/grafana/loki/v3/pkg/ingester"
@@ -325,6 +326,7 @@ func (t *Loki) initDistributor() (services.Service, error) {
 
 	var err error
 	logger := log.With(util_log.Logger, "component", "distributor")
+	t.Cfg.Distributor.KafkaConfig = t.Cfg.KafkaConfig
 	t.distributor, err = distributor.New(
 		t.Cfg.Distributor,
 		t.Cfg.IngesterClient,
@@ -368,6 +370,8 @@ func (t *Loki) initDistributor() (services.Service, error) {
 	t.Server.HTTP.Path("/api/prom/push").Methods("POST").Handler(lokiPushHandler)
 	t.Server.HTTP.Path("/loki/api/v1/push").Methods("POST").Handler(lokiPushHandler)
 	t.Server.HTTP.Path("/otlp/v1/logs").Methods("POST").Handler(otlpPushHandler)
+	t.Server.HTTP.Path("/distributor/attribution").Methods("GET").Handler(httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.AttributionHandler)))
+	t.Server.HTTP.Path("/distributor/shard_map").Methods("GET").Handler(httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.ShardMapHandler)))
 	return t.distributor, nil
 }
 
@@ -542,6 +546,10 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	t.Server.HTTP.Path("/loki/api/v1/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
 	t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
 
+	// The streams held by ingesters aren't queries either, they are fanned out
+	// to the ingesters by the querier receiving the request.
+	t.Server.HTTP.Path("/loki/api/v1/ingester/streams").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.StreamsHandler)))
+
 	internalMiddlewares := []queryrangebase.Middleware{
 		serverutil.RecoveryMiddleware,
 		queryrange.Instrument{Metrics: t.Metrics},
@@ -575,6 +583,14 @@ func (t *Loki) initQuerier() (services.Service, error) {
 func (t *Loki) initIngester() (_ services.Service, err error) {
 	logger := log.With(util_log.Logger, "component", "ingester")
 	t.Cfg.Ingester.LifecyclerConfig.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Ingester.KafkaConfig = t.Cfg.KafkaConfig
+
+	if store := t.Cfg.Ingester.WAL.RemoteStore; t.Cfg.Ingester.WAL.Enabled && store != "" {
+		t.Cfg.Ingester.WAL.RemoteClient, err = storage.NewObjectClient(store, t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create WAL remote store client: %w", err)
+		}
+	}
 
 	if t.Cfg.Ingester.ShutdownMarkerPath == "" && t.Cfg.Common.PathPrefix != "" {
 		t.Cfg.Ingester.ShutdownMarkerPath = t.Cfg.Common.PathPrefix
@@ -595,6 +611,7 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	logproto.RegisterPusherServer(t.Server.GRPC, t.Ingester)
 	logproto.RegisterQuerierServer(t.Server.GRPC, t.Ingester)
 	logproto.RegisterStreamDataServer(t.Server.GRPC, t.Ingester)
+	ingester.RegisterTransferServer(t.Server.GRPC, t.Ingester)
 
 	httpMiddleware := middleware.Merge(
 		serverutil.RecoveryHTTPMiddleware,
@@ -608,6 +625,12 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	t.Server.HTTP.Methods("POST", "GET").Path("/ingester/shutdown").Handler(
 		httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.ShutdownHandler)),
 	)
+	t.Server.HTTP.Methods("GET").Path("/ingester/tenant_memory").Handler(
+		httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.TenantMemoryHandler)),
+	)
+	t.Server.HTTP.Methods("GET", "POST").Path("/ingester/streams").Handler(
+		middleware.Merge(httpMiddleware, t.HTTPAuthMiddleware).Wrap(http.HandlerFunc(t.Ingester.StreamsHandler)),
+	)
 	return t.Ingester, nil
 }
 
@@ -914,6 +937,13 @@ func (i ingesterQueryOptions) QueryIngestersWithin() time.Duration {
 func (t *Loki) initQueryFrontendMiddleware() (_ services.Service, err error) {
 	level.Debug(util_log.Logger).Log("msg", "initializing query frontend tripperware")
 
+	if t.Cfg.QueryRange.QueryAcceleration.Enabled {
+		t.Cfg.QueryRange.QueryAcceleration.StoreClient, err = storage.NewObjectClient(t.Cfg.QueryRange.QueryAcceleration.Store, t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create query acceleration object client: %w", err)
+		}
+	}
+
 	middleware, stopper, err := queryrange.NewMiddleware(
 		t.Cfg.QueryRange,
 		t.Cfg.Querier.Engine,
@@ -929,6 +959,15 @@ func (t *Loki) initQueryFrontendMiddleware() (_ services.Service, err error) {
 		return
 	}
 	t.stopper = stopper
+
+	if t.Cfg.CompactorConfig.RetentionEnabled && t.Cfg.CompactorConfig.RetentionRollupsEnabled {
+		objectClient, err := storage.NewObjectClient(t.Cfg.CompactorConfig.DeleteRequestStore, t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create retention rollups object client: %w", err)
+		}
+		rollups := rollup.NewStore(objectClient, t.Cfg.CompactorConfig.RetentionRollupsKeyPrefix)
+		middleware = queryrangebase.MergeMiddlewares(queryrange.NewRetentionRollupsMiddleware(rollups, t.Overrides, util_log.Logger), middleware)
+	}
 	t.QueryFrontEndMiddleware = middleware
 
 	return services.NewIdleService(nil, nil), nil
@@ -1022,16 +1061,18 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		level.Debug(util_log.Logger).Log("msg", "no query frontend configured")
 	}
 
-	roundTripper := queryrange.NewSerializeRoundTripper(t.QueryFrontEndMiddleware.Wrap(frontendTripper), queryrange.DefaultCodec)
+	frontendStack := t.QueryFrontEndMiddleware.Wrap(frontendTripper)
+	roundTripper := queryrange.NewSerializeRoundTripper(frontendStack, queryrange.DefaultCodec)
 
-	frontendHandler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
+	transportHandler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
+	var frontendHandler http.Handler = transportHandler
 	if t.Cfg.Frontend.CompressResponses {
 		frontendHandler = gziphandler.GzipHandler(frontendHandler)
 	}
 
 	toMerge := []middleware.Interface{
 		httpreq.ExtractQueryTagsMiddleware(),
-		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader),
+		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiQueryPriorityHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader),
 		serverutil.RecoveryHTTPMiddleware,
 		t.HTTPAuthMiddleware,
 		queryrange.StatsHTTPMiddleware,
@@ -1044,7 +1085,20 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		toMerge = append(toMerge, querylimits.NewQueryLimitsMiddleware(logger))
 	}
 
+	queryRangeHandler := middleware.Merge(toMerge...).Wrap(queryrange.NewStreamingQueryHandler(frontendStack, frontendHandler, transportHandler))
 	frontendHandler = middleware.Merge(toMerge...).Wrap(frontendHandler)
+	explainHandler := middleware.Merge(toMerge...).Wrap(queryrange.NewExplainHandler(frontendStack, t.Overrides, t.Cfg.Querier.Engine.MaxLookBackPeriod))
+	queryPolicyHandler := middleware.Merge(toMerge...).Wrap(queryrange.NewQueryPolicyHandler(frontendStack, t.Overrides, t.Cfg.Querier.Engine.MaxLookBackPeriod))
+	accelerateHandler := middleware.Merge(toMerge...).Wrap(queryrange.NewAccelerateHandler(frontendStack))
+
+	var queryJobs *queryrange.QueryJobs
+	if t.Cfg.QueryRange.QueryJobs.Enabled {
+		objectClient, err := storage.NewObjectClient(t.Cfg.QueryRange.QueryJobs.Store, t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create query jobs object client: %w", err)
+		}
+		queryJobs = queryrange.NewQueryJobs(t.Cfg.QueryRange.QueryJobs, objectClient, frontendStack, util_log.Logger, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
+	}
 
 	var defaultHandler http.Handler
 	// If this process also acts as a Querier we don't do any proxying of tail requests
@@ -1079,8 +1133,18 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	} else {
 		defaultHandler = frontendHandler
 	}
-	t.Server.HTTP.Path("/loki/api/v1/query_range").Methods("GET", "POST").Handler(frontendHandler)
+	t.Server.HTTP.Path("/loki/api/v1/query_range").Methods("GET", "POST").Handler(queryRangeHandler)
 	t.Server.HTTP.Path("/loki/api/v1/query").Methods("GET", "POST").Handler(frontendHandler)
+	t.Server.HTTP.Path("/loki/api/v1/explain").Methods("GET", "POST").Handler(explainHandler)
+	t.Server.HTTP.Path("/loki/api/v1/accelerate").Methods("GET", "POST", "DELETE").Handler(accelerateHandler)
+	t.Server.HTTP.Path("/loki/api/v1/query_policies/test").Methods("GET", "POST").Handler(queryPolicyHandler)
+	if queryJobs != nil {
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs").Methods("POST").Handler(middleware.Merge(toMerge...).Wrap(http.HandlerFunc(queryJobs.CreateJobHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs").Methods("GET").Handler(middleware.Merge(toMerge...).Wrap(http.HandlerFunc(queryJobs.ListJobsHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}").Methods("GET").Handler(middleware.Merge(toMerge...).Wrap(http.HandlerFunc(queryJobs.GetJobHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}").Methods("DELETE").Handler(middleware.Merge(toMerge...).Wrap(http.HandlerFunc(queryJobs.CancelJobHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}/results").Methods("GET").Handler(middleware.Merge(toMerge...).Wrap(http.HandlerFunc(queryJobs.GetJobResultsHandler)))
+	}
 	t.Server.HTTP.Path("/loki/api/v1/label").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/loki/api/v1/labels").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/loki/api/v1/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
@@ -1110,8 +1174,24 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		t.Server.HTTP.Path("/loki/api/v1/detected_fields").Methods("GET", "POST").Handler(frontendHandler)
 	}
 
+	startQueryJobs := func(ctx context.Context) error {
+		if queryJobs == nil {
+			return nil
+		}
+		return services.StartAndAwaitRunning(ctx, queryJobs)
+	}
+	stopQueryJobs := func() {
+		if queryJobs == nil {
+			return
+		}
+		if err := services.StopAndAwaitTerminated(context.Background(), queryJobs); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to stop query jobs service", "err", err)
+		}
+	}
+
 	if t.frontend == nil {
-		return services.NewIdleService(nil, func(_ error) error {
+		return services.NewIdleService(startQueryJobs, func(_ error) error {
+			stopQueryJobs()
 			if t.stopper != nil {
 				t.stopper.Stop()
 				t.stopper = nil
@@ -1121,10 +1201,14 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	}
 
 	return services.NewIdleService(func(ctx context.Context) error {
-		return services.StartAndAwaitRunning(ctx, t.frontend)
+		if err := services.StartAndAwaitRunning(ctx, t.frontend); err != nil {
+			return err
+		}
+		return startQueryJobs(ctx)
 	}, func(_ error) error {
 		// Log but not return in case of error, so that other following dependencies
 		// are stopped too.
+		stopQueryJobs()
 		if err := services.StopAndAwaitTerminated(context.Background(), t.frontend); err != nil {
 			level.Warn(util_log.Logger).Log("msg", "failed to stop frontend service", "err", err)
 		}
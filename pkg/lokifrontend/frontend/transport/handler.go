@@ -72,7 +72,7 @@ type Handler struct {
 }
 
 // NewHandler creates a new frontend handler.
-func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer, metricsNamespace string) http.Handler {
+func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer, metricsNamespace string) *Handler {
 	h := &Handler{
 		cfg:          cfg,
 		log:          log,
@@ -111,6 +111,48 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 }
 
 func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.serve(w, r, func(r *http.Request, stats *querier_stats.Stats) (time.Duration, error) {
+		startTime := time.Now()
+		resp, err := f.roundTripper.RoundTrip(r)
+		queryResponseTime := time.Since(startTime)
+
+		if err != nil {
+			return queryResponseTime, err
+		}
+
+		hs := w.Header()
+		for h, vs := range resp.Header {
+			hs[h] = vs
+		}
+
+		if f.cfg.QueryStatsEnabled {
+			writeServiceTimingHeader(queryResponseTime, hs, stats)
+		}
+
+		w.WriteHeader(resp.StatusCode)
+		// we don't check for copy error as there is no much we can do at this point
+		_, _ = io.Copy(w, resp.Body)
+		return queryResponseTime, nil
+	})
+}
+
+// Wrap returns a handler serving the requests with next rather than the round
+// tripper, for the responses written as they are computed. Slow queries and
+// query stats are reported like for the requests served by the Handler, the
+// response time including the time taken to write the response.
+func (f *Handler) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.serve(w, r, func(r *http.Request, _ *querier_stats.Stats) (time.Duration, error) {
+			startTime := time.Now()
+			next.ServeHTTP(w, r)
+			return time.Since(startTime), nil
+		})
+	})
+}
+
+// serve answers the request with do, which returns the time taken to answer
+// it, and reports slow queries and query stats.
+func (f *Handler) serve(w http.ResponseWriter, r *http.Request, do func(r *http.Request, stats *querier_stats.Stats) (time.Duration, error)) {
 	var (
 		stats       *querier_stats.Stats
 		queryString url.Values
@@ -133,28 +175,12 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, f.cfg.MaxBodySize)
 	r.Body = io.NopCloser(io.TeeReader(r.Body, &buf))
 
-	startTime := time.Now()
-	resp, err := f.roundTripper.RoundTrip(r)
-	queryResponseTime := time.Since(startTime)
-
+	queryResponseTime, err := do(r, stats)
 	if err != nil {
 		server.WriteError(err, w)
 		return
 	}
 
-	hs := w.Header()
-	for h, vs := range resp.Header {
-		hs[h] = vs
-	}
-
-	if f.cfg.QueryStatsEnabled {
-		writeServiceTimingHeader(queryResponseTime, hs, stats)
-	}
-
-	w.WriteHeader(resp.StatusCode)
-	// we don't check for copy error as there is no much we can do at this point
-	_, _ = io.Copy(w, resp.Body)
-
 	// Check whether we should parse the query string.
 	shouldReportSlowQuery := f.cfg.LogQueriesLongerThan > 0 && queryResponseTime > f.cfg.LogQueriesLongerThan
 	if shouldReportSlowQuery || f.cfg.QueryStatsEnabled {
@@ -1,10 +1,20 @@
 package transport
 
 import (
+	"bytes"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+
+	querier_stats "github.com/grafana/loki/v3/pkg/querier/stats"
 )
 
 func TestFormatRequestHeaders(t *testing.T) {
@@ -21,3 +31,27 @@ func TestFormatRequestHeaders(t *testing.T) {
 
 	require.Equal(t, expected, fields)
 }
+
+func TestHandler_Wrap(t *testing.T) {
+	var logs bytes.Buffer
+	reg := prometheus.NewRegistry()
+	h := NewHandler(HandlerConfig{QueryStatsEnabled: true, LogQueriesLongerThan: time.Nanosecond, MaxBodySize: 1024}, nil, log.NewLogfmtLogger(&logs), reg, "loki")
+
+	// The requests served directly by the wrapped handler are reported like
+	// the ones going through the round tripper.
+	wrapped := h.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		querier_stats.FromContext(r.Context()).AddWallTime(time.Second)
+		time.Sleep(time.Millisecond)
+		_, _ = io.WriteString(w, "streamed")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range?query=%7Bfoo%3D%22bar%22%7D", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "tenant"))
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, req)
+
+	require.Equal(t, "streamed", rec.Body.String())
+	require.Equal(t, 1.0, testutil.ToFloat64(h.querySeconds.WithLabelValues("tenant")))
+	require.Contains(t, logs.String(), "slow query detected")
+	require.Contains(t, logs.String(), "query stats")
+	require.Contains(t, logs.String(), `param_query="{foo=\"bar\"}"`)
+}
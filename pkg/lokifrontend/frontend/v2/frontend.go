@@ -110,6 +110,7 @@ type frontendRequest struct {
 	queryRequest *queryrange.QueryRequest
 	tenantID     string
 	actor        []string
+	priority     string
 	statsEnabled bool
 
 	cancel context.CancelFunc
@@ -238,6 +239,7 @@ func (f *Frontend) RoundTripGRPC(ctx context.Context, req *httpgrpc.HTTPRequest)
 		request:      req,
 		tenantID:     tenantID,
 		actor:        httpreq.ExtractActorPath(ctx),
+		priority:     httpreq.ExtractHeader(ctx, httpreq.LokiQueryPriorityHeader),
 		statsEnabled: stats.IsEnabled(ctx),
 
 		cancel: cancel,
@@ -297,6 +299,7 @@ func (f *Frontend) Do(ctx context.Context, req queryrangebase.Request) (queryran
 		queryID:      f.lastQueryID.Inc(),
 		tenantID:     tenantID,
 		actor:        httpreq.ExtractActorPath(ctx),
+		priority:     httpreq.ExtractHeader(ctx, httpreq.LokiQueryPriorityHeader),
 		statsEnabled: stats.IsEnabled(ctx),
 
 		cancel: cancel,
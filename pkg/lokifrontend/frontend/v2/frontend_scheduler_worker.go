@@ -287,6 +287,7 @@ func (w *frontendSchedulerWorker) schedulerLoop(loop schedulerpb.SchedulerForFro
 				QueryID:   req.queryID,
 				UserID:    req.tenantID,
 				QueuePath: req.actor,
+				Priority:  req.priority,
 				Request: &schedulerpb.FrontendToScheduler_HttpRequest{
 					HttpRequest: req.request,
 				},
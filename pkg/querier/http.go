@@ -26,6 +26,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange"
 	index_stats "github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/util"
 	"github.com/grafana/loki/v3/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	"github.com/grafana/loki/v3/pkg/util/marshal"
@@ -148,6 +149,11 @@ func (q *QuerierAPI) TailHandler(w http.ResponseWriter, r *http.Request) {
 	encodingFlags := httpreq.ExtractEncodingFlags(r)
 	version := loghttp.GetVersion(r.RequestURI)
 
+	if _, ok := req.Plan.AST.(syntax.SampleExpr); ok && version != loghttp.VersionV1 {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "metric tails are only supported by the v1 API"), w)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		level.Error(logger).Log("msg", "Error in upgrading websocket", "err", err)
@@ -279,6 +285,28 @@ func (q *QuerierAPI) SeriesHandler(ctx context.Context, req *logproto.SeriesRequ
 	return resp, statResult, err
 }
 
+// StreamsHandler is a http.HandlerFunc listing the streams of the tenant held
+// in memory by the ingesters, optionally grouped by labels.
+func (q *QuerierAPI) StreamsHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := loghttp.ParseStreamsQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	resp, err := q.querier.StreamStats(r.Context(), &logproto.StreamStatsRequest{Matchers: query.Selector})
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	streams, err := query.Aggregate(resp.Streams)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, loghttp.StreamsResponse{Streams: streams})
+}
+
 // IndexStatsHandler queries the index for the data statistics related to a query
 func (q *QuerierAPI) IndexStatsHandler(ctx context.Context, req *loghttp.RangeQuery) (*logproto.IndexStatsResponse, error) {
 	timer := prometheus.NewTimer(logql.QueryTime.WithLabelValues(logql.QueryTypeStats))
@@ -397,6 +397,43 @@ func (q *IngesterQuerier) DetectedLabel(ctx context.Context, req *logproto.Detec
 	return &logproto.LabelToValuesResponse{Labels: mergedResult}, nil
 }
 
+// StreamStats returns the streams of the tenant held in memory by the
+// ingesters. The stats of the replicas of a stream are deduplicated by keeping
+// the highest value of each.
+func (q *IngesterQuerier) StreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error) {
+	resps, err := q.forAllIngesters(ctx, func(ctx context.Context, client logproto.QuerierClient) (interface{}, error) {
+		return client.GetStreamStats(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]*logproto.StreamStats{}
+	for _, resp := range resps {
+		for _, s := range resp.response.(*logproto.StreamStatsResponse).Streams {
+			m, ok := merged[s.Labels]
+			if !ok {
+				m := *s
+				merged[s.Labels] = &m
+				continue
+			}
+			m.Entries = max(m.Entries, s.Entries)
+			m.Bytes = max(m.Bytes, s.Bytes)
+			m.Chunks = max(m.Chunks, s.Chunks)
+			m.Rate = max(m.Rate, s.Rate)
+			if s.LastPush.After(m.LastPush) {
+				m.LastPush = s.LastPush
+			}
+		}
+	}
+
+	result := &logproto.StreamStatsResponse{Streams: make([]*logproto.StreamStats, 0, len(merged))}
+	for _, s := range merged {
+		result.Streams = append(result.Streams, s)
+	}
+	return result, nil
+}
+
 func convertMatchersToString(matchers []*labels.Matcher) string {
 	out := strings.Builder{}
 	out.WriteRune('{')
@@ -3,6 +3,7 @@ package querier
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -401,3 +402,38 @@ func TestIngesterQuerier_Volume(t *testing.T) {
 		require.Equal(t, []logproto.Volume(nil), volumes.Volumes)
 	})
 }
+
+func TestIngesterQuerier_StreamStats(t *testing.T) {
+	lastPush := time.Unix(100, 0).UTC()
+	ingesterClient := newQuerierClientMock()
+	ingesterClient.On("GetStreamStats", mock.Anything, mock.Anything, mock.Anything).Return(&logproto.StreamStatsResponse{
+		Streams: []*logproto.StreamStats{
+			{Labels: `{foo="bar"}`, Entries: 10, Bytes: 100, Chunks: 1, LastPush: lastPush, Rate: 5},
+			{Labels: `{foo="baz"}`, Entries: 1, Bytes: 10, Chunks: 1, LastPush: lastPush, Rate: 1},
+		},
+	}, nil).Once()
+	ingesterClient.On("GetStreamStats", mock.Anything, mock.Anything, mock.Anything).Return(&logproto.StreamStatsResponse{
+		Streams: []*logproto.StreamStats{
+			{Labels: `{foo="bar"}`, Entries: 12, Bytes: 90, Chunks: 2, LastPush: lastPush.Add(time.Second), Rate: 4},
+		},
+	}, nil).Once()
+
+	ingesterQuerier, err := newIngesterQuerier(
+		mockIngesterClientConfig(),
+		newReadRingMock([]ring.InstanceDesc{mockInstanceDesc("1.1.1.1", ring.ACTIVE), mockInstanceDesc("3.3.3.3", ring.ACTIVE)}, 0),
+		mockQuerierConfig().ExtraQueryDelay,
+		newIngesterClientMockFactory(ingesterClient),
+		constants.Loki,
+	)
+	require.NoError(t, err)
+
+	resp, err := ingesterQuerier.StreamStats(context.Background(), &logproto.StreamStatsRequest{})
+	require.NoError(t, err)
+
+	// The replicas of a stream are deduplicated.
+	sort.Slice(resp.Streams, func(i, j int) bool { return resp.Streams[i].Labels < resp.Streams[j].Labels })
+	require.Equal(t, []*logproto.StreamStats{
+		{Labels: `{foo="bar"}`, Entries: 12, Bytes: 100, Chunks: 2, LastPush: lastPush.Add(time.Second), Rate: 5},
+		{Labels: `{foo="baz"}`, Entries: 1, Bytes: 10, Chunks: 1, LastPush: lastPush, Rate: 1},
+	}, resp.Streams)
+}
@@ -0,0 +1,171 @@
+package querier
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/iter"
+	loghttp "github.com/grafana/loki/v3/pkg/loghttp/legacy"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// ingesterVector is the latest vector of the range aggregation of a metric
+// tail received from an ingester.
+type ingesterVector struct {
+	received time.Time
+	vector   promql.Vector
+}
+
+// newMetricTailer returns a Tailer of a metric query. The ingesters compute
+// the range aggregation of the query, the Tailer merges their vectors and
+// evaluates the rest of the query every step.
+func newMetricTailer(
+	expr syntax.SampleExpr,
+	step time.Duration,
+	querierTailClients map[string]logproto.Querier_TailClient,
+	tailDisconnectedIngesters func([]string) (map[string]logproto.Querier_TailClient, error),
+	tailMaxDuration time.Duration,
+	m *Metrics,
+	logger log.Logger,
+) *Tailer {
+	if step <= 0 {
+		step = time.Second
+	}
+
+	t := Tailer{
+		openStreamIterator:        iter.NewMergeEntryIterator(context.Background(), nil, logproto.FORWARD),
+		querierTailClients:        querierTailClients,
+		responseChan:              make(chan *loghttp.TailResponse, maxBufferedTailResponses),
+		closeErrChan:              make(chan error),
+		seenStreams:               make(map[uint64]struct{}),
+		tailDisconnectedIngesters: tailDisconnectedIngesters,
+		tailMaxDuration:           tailMaxDuration,
+		metricExpr:                expr,
+		step:                      step,
+		vectors:                   map[string]ingesterVector{},
+		metrics:                   m,
+		logger:                    logger,
+	}
+
+	t.metrics.tailsActive.Inc()
+	t.readTailClients()
+	go t.metricLoop()
+	return &t
+}
+
+// metricLoop sends the vector of the query every step. If the response
+// channel is blocked the vector is dropped, the next one supersedes it.
+func (t *Tailer) metricLoop() {
+	checkConnectionTicker := time.NewTicker(checkConnectionsWithIngestersPeriod)
+	defer checkConnectionTicker.Stop()
+
+	tailMaxDurationTicker := time.NewTicker(t.tailMaxDuration)
+	defer tailMaxDurationTicker.Stop()
+
+	stepTicker := time.NewTicker(t.step)
+	defer stepTicker.Stop()
+
+	for !t.stopped.Load() {
+		select {
+		case <-checkConnectionTicker.C:
+			// Try to reconnect dropped ingesters and connect to new ingesters
+			if err := t.checkIngesterConnections(); err != nil {
+				level.Error(t.logger).Log("msg", "Error reconnecting to disconnected ingesters", "err", err)
+			}
+		case <-tailMaxDurationTicker.C:
+			t.closeWithError(errors.New("reached tail max duration limit"))
+			return
+		case now := <-stepTicker.C:
+			t.querierTailClientsMtx.RLock()
+			numClients := len(t.querierTailClients)
+			t.querierTailClientsMtx.RUnlock()
+
+			if numClients == 0 {
+				// All the connections to ingesters are dropped, try reconnecting or return error
+				if err := t.checkIngesterConnections(); err != nil {
+					level.Error(t.logger).Log("msg", "Error reconnecting to ingesters", "err", err)
+					t.closeWithError(errors.New("all ingesters closed the connection"))
+					return
+				}
+			}
+
+			vec, err := logql.EvaluateMetricTail(context.Background(), t.metricExpr, now, t.mergeVectors(now))
+			if err != nil {
+				t.closeWithError(err)
+				return
+			}
+			if vec == nil {
+				// A nil vector would make the response a log tail one.
+				vec = promql.Vector{}
+			}
+			select {
+			case t.responseChan <- &loghttp.TailResponse{Vector: vec}:
+			default:
+			}
+		}
+	}
+}
+
+func (t *Tailer) closeWithError(err error) {
+	if closeErr := t.close(); closeErr != nil {
+		level.Error(t.logger).Log("msg", "Error closing Tailer", "err", closeErr)
+	}
+	t.closeErrChan <- err
+}
+
+// pushVectorFromIngester replaces the vector received from an ingester.
+func (t *Tailer) pushVectorFromIngester(addr string, resp *logproto.TailResponse) {
+	vec := make(promql.Vector, 0, len(resp.Series))
+	for _, s := range resp.Series {
+		if len(s.Samples) == 0 {
+			continue
+		}
+		lbs, err := syntax.ParseLabels(s.Labels)
+		if err != nil {
+			level.Error(t.logger).Log("msg", "Error parsing series labels of metric tail", "labels", s.Labels, "err", err)
+			continue
+		}
+		vec = append(vec, promql.Sample{F: s.Samples[0].Value, Metric: lbs})
+	}
+
+	t.vectorsMtx.Lock()
+	defer t.vectorsMtx.Unlock()
+	t.vectors[addr] = ingesterVector{received: time.Now(), vector: vec}
+}
+
+// mergeVectors merges the latest vectors of the ingesters. Every series comes
+// from a single stream, whose replicas compute the same value. The highest
+// value is kept in case an ingester missed some entries.
+func (t *Tailer) mergeVectors(now time.Time) promql.Vector {
+	t.vectorsMtx.Lock()
+	defer t.vectorsMtx.Unlock()
+
+	merged := map[uint64]int{}
+	var vec promql.Vector
+	for addr, v := range t.vectors {
+		// The vectors of the ingesters which stopped sending them are stale.
+		if now.Sub(v.received) > 2*t.step {
+			delete(t.vectors, addr)
+			continue
+		}
+		for _, s := range v.vector {
+			h := s.Metric.Hash()
+			if i, ok := merged[h]; ok {
+				if s.F > vec[i].F {
+					vec[i].F = s.F
+				}
+				continue
+			}
+			merged[h] = len(vec)
+			vec = append(vec, promql.Sample{T: now.UnixMilli(), F: s.F, Metric: s.Metric})
+		}
+	}
+	return vec
+}
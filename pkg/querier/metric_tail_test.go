@@ -0,0 +1,73 @@
+package querier
+
+import (
+	"testing"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+func TestMetricTailer(t *testing.T) {
+	t.Parallel()
+
+	series := func(lbs string, value float64) logproto.Series {
+		return logproto.Series{Labels: lbs, Samples: []logproto.Sample{{Timestamp: time.Now().UnixNano(), Value: value}}}
+	}
+	// The second ingester holds a replica of the first stream, and missed
+	// some of its entries.
+	replica1 := newTailClientMock().mockRecvWithTrigger(&logproto.TailResponse{Series: []logproto.Series{
+		series(`{app="api", pod="a", status="200"}`, 3),
+		series(`{app="api", pod="a", status="500"}`, 1),
+	}})
+	replica2 := newTailClientMock().mockRecvWithTrigger(&logproto.TailResponse{Series: []logproto.Series{
+		series(`{app="api", pod="a", status="200"}`, 2),
+		series(`{app="api", pod="b", status="200"}`, 1),
+	}})
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+
+	expr, err := syntax.ParseSampleExpr(`sum by (status) (rate({app="api"}[10s]))`)
+	require.NoError(t, err)
+	tailer := newMetricTailer(expr, 10*time.Millisecond, map[string]logproto.Querier_TailClient{
+		"ingester-1": replica1,
+		"ingester-2": replica2,
+	}, tailDisconnectedIngesters, time.Minute, NewMetrics(nil), gokitlog.NewNopLogger())
+	defer tailer.close()
+
+	want := map[string]float64{
+		labels.FromStrings("status", "200").String(): 4,
+		labels.FromStrings("status", "500").String(): 1,
+	}
+	require.Eventually(t, func() bool {
+		// The ingesters send their vector every step.
+		replica1.triggerRecv()
+		replica2.triggerRecv()
+		select {
+		case resp := <-tailer.getResponseChan():
+			require.NotNil(t, resp.Vector)
+			require.Empty(t, resp.Streams)
+			return equalVector(want, resp.Vector)
+		default:
+			return false
+		}
+	}, timeout, throttle)
+}
+
+func equalVector(want map[string]float64, vec promql.Vector) bool {
+	if len(want) != len(vec) {
+		return false
+	}
+	for _, s := range vec {
+		if v, ok := want[s.Metric.String()]; !ok || v != s.F {
+			return false
+		}
+	}
+	return true
+}
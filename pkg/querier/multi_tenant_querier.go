@@ -446,3 +446,38 @@ func NewTenantSampleIterator(iter iter.SampleIterator, id string) *TenantSampleI
 func (i *TenantSampleIterator) Labels() string {
 	return i.relabel.relabel(i.SampleIterator.Labels())
 }
+
+// StreamStats returns the streams of every tenant held in memory by the
+// ingesters, with the tenant ID added to their labels.
+func (q *MultiTenantQuerier) StreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		return q.Querier.StreamStats(ctx, req)
+	}
+
+	merged := &logproto.StreamStatsResponse{}
+	for _, id := range tenantIDs {
+		resp, err := q.Querier.StreamStats(user.InjectOrgID(ctx, id), req)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range resp.Streams {
+			lbs, err := syntax.ParseLabels(s.Labels)
+			if err != nil {
+				return nil, err
+			}
+			builder := labels.NewBuilder(lbs)
+			if v := lbs.Get(defaultTenantLabel); v != "" {
+				builder.Set(retainExistingPrefix+defaultTenantLabel, v)
+			}
+			builder.Set(defaultTenantLabel, id)
+			s.Labels = builder.Labels().String()
+			merged.Streams = append(merged.Streams, s)
+		}
+	}
+	return merged, nil
+}
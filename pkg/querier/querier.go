@@ -105,6 +105,7 @@ type Querier interface {
 	DetectedFields(ctx context.Context, req *logproto.DetectedFieldsRequest) (*logproto.DetectedFieldsResponse, error)
 	Patterns(ctx context.Context, req *logproto.QueryPatternsRequest) (*logproto.QueryPatternsResponse, error)
 	DetectedLabels(ctx context.Context, req *logproto.DetectedLabelsRequest) (*logproto.DetectedLabelsResponse, error)
+	StreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error)
 }
 
 type Limits querier_limits.Limits
@@ -467,6 +468,10 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 		}
 	}
 
+	if expr, ok := req.Plan.AST.(syntax.SampleExpr); ok {
+		return q.tailMetric(ctx, req, expr)
+	}
+
 	deletes, err := q.deletesForUser(ctx, req.Start, time.Now())
 	if err != nil {
 		level.Error(spanlogger.FromContext(ctx)).Log("msg", "failed loading deletes for user", "err", err)
@@ -517,6 +522,7 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 
 	return newTailer(
 		time.Duration(req.DelayFor)*time.Second,
+		req.MaxLinesPerSecond,
 		tailClients,
 		reversedIterator,
 		func(connectedIngestersAddr []string) (map[string]logproto.Querier_TailClient, error) {
@@ -530,6 +536,31 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 	), nil
 }
 
+// tailMetric tails a metric query, whose range aggregation is computed by the
+// ingesters from the entries they receive.
+func (q *SingleTenantQuerier) tailMetric(ctx context.Context, req *logproto.TailRequest, expr syntax.SampleExpr) (*Tailer, error) {
+	if _, err := logql.MetricTailRangeAggregation(expr); err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+
+	tailClients, err := q.ingesterQuerier.Tail(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMetricTailer(
+		expr,
+		time.Duration(req.Step)*time.Millisecond,
+		tailClients,
+		func(connectedIngestersAddr []string) (map[string]logproto.Querier_TailClient, error) {
+			return q.ingesterQuerier.TailDisconnectedIngesters(ctx, req, connectedIngestersAddr)
+		},
+		q.cfg.TailMaxDuration,
+		q.metrics,
+		q.logger,
+	), nil
+}
+
 // Series fetches any matching series for a list of matcher sets
 func (q *SingleTenantQuerier) Series(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, error) {
 	userID, err := tenant.TenantID(ctx)
@@ -909,6 +940,15 @@ func (q *SingleTenantQuerier) Volume(ctx context.Context, req *logproto.VolumeRe
 	return seriesvolume.Merge(responses, req.Limit), nil
 }
 
+// StreamStats returns the streams of the tenant held in memory by the
+// ingesters.
+func (q *SingleTenantQuerier) StreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error) {
+	if q.cfg.QueryStoreOnly {
+		return &logproto.StreamStatsResponse{}, nil
+	}
+	return q.ingesterQuerier.StreamStats(ctx, req)
+}
+
 func (q *SingleTenantQuerier) DetectedLabels(ctx context.Context, req *logproto.DetectedLabelsRequest) (*logproto.DetectedLabelsResponse, error) {
 	var ingesterLabels *logproto.LabelToValuesResponse
 	var detectedLabels []*logproto.DetectedLabel
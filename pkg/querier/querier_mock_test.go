@@ -120,6 +120,15 @@ func (c *querierClientMock) GetVolume(ctx context.Context, in *logproto.VolumeRe
 	return res.(*logproto.VolumeResponse), args.Error(1)
 }
 
+func (c *querierClientMock) GetStreamStats(ctx context.Context, in *logproto.StreamStatsRequest, opts ...grpc.CallOption) (*logproto.StreamStatsResponse, error) {
+	args := c.Called(ctx, in, opts)
+	res := args.Get(0)
+	if res == nil {
+		return (*logproto.StreamStatsResponse)(nil), args.Error(1)
+	}
+	return res.(*logproto.StreamStatsResponse), args.Error(1)
+}
+
 func (c *querierClientMock) Context() context.Context {
 	return context.Background()
 }
@@ -616,6 +625,18 @@ func (q *querierMock) DetectedLabels(ctx context.Context, req *logproto.Detected
 	return resp.(*logproto.DetectedLabelsResponse), err
 }
 
+func (q *querierMock) StreamStats(ctx context.Context, req *logproto.StreamStatsRequest) (*logproto.StreamStatsResponse, error) {
+	args := q.MethodCalled("StreamStats", ctx, req)
+
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp == nil {
+		return nil, err
+	}
+
+	return resp.(*logproto.StreamStatsResponse), err
+}
+
 type engineMock struct {
 	util.ExtendedMock
 }
@@ -1351,6 +1351,7 @@ func encodeResponseJSON(ctx context.Context, version loghttp.Version, res queryr
 		Body:       io.NopCloser(&buf),
 		StatusCode: http.StatusOK,
 	}
+	setQueryCostHeaders(resp.Header, res)
 	return &resp, nil
 }
 
@@ -1450,6 +1451,7 @@ func encodeResponseProtobuf(ctx context.Context, res queryrangebase.Response) (*
 		Body:       io.NopCloser(bytes.NewBuffer(buf)),
 		StatusCode: http.StatusOK,
 	}
+	setQueryCostHeaders(resp.Header, res)
 	return &resp, nil
 }
 
@@ -144,7 +144,7 @@ func (in instance) Downstream(ctx context.Context, queries []logql.DownstreamQue
 		defer logger.Finish()
 		level.Debug(logger).Log("shards", fmt.Sprintf("%+v", qry.Params.Shards()), "query", req.GetQuery(), "step", req.GetStep(), "handler", reflect.TypeOf(in.handler), "engine", "downstream")
 
-		res, err := in.handler.Do(ctx, req)
+		res, err := explainDo(ctx, ExplainOpDownstream, req, in.handler)
 		if err != nil {
 			return logqlmodel.Result{}, err
 		}
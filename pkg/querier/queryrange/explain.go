@@ -0,0 +1,380 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	indexStats "github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/util"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// Operations of the nodes of an explained query plan.
+const (
+	ExplainOpQuery        = "query"
+	ExplainOpSplit        = "split"
+	ExplainOpRangeMapping = "range_mapping"
+	ExplainOpShardMapping = "shard_mapping"
+	ExplainOpDownstream   = "downstream"
+)
+
+const (
+	rangeQueryPath   = "/loki/api/v1/query_range"
+	instantQueryPath = "/loki/api/v1/query"
+)
+
+// ExplainLimits are the limits used by the explain handler.
+type ExplainLimits interface {
+	BloomGatewayEnabled(userID string) bool
+}
+
+// ExplainResponse is the response of the explain endpoint.
+type ExplainResponse struct {
+	Query   string `json:"query"`
+	Analyze bool   `json:"analyze"`
+	// Stats are the index stats of the streams selected by the query.
+	Stats        logproto.IndexStatsResponse `json:"stats"`
+	BloomFilters ExplainBloomFilters         `json:"bloom_filters"`
+	Plan         *ExplainNode                `json:"plan"`
+}
+
+// ExplainBloomFilters describes the line filters of the query the bloom
+// gateway can use to filter chunks.
+type ExplainBloomFilters struct {
+	Enabled bool     `json:"enabled"`
+	Filters []string `json:"filters,omitempty"`
+}
+
+// ExplainNode is a step of the execution of a query by the query frontend.
+type ExplainNode struct {
+	Operation string    `json:"operation"`
+	Query     string    `json:"query,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Shards    []string  `json:"shards,omitempty"`
+	// MappedQuery is the query rewritten by a range or shard mapping, empty
+	// when the query can't be mapped.
+	MappedQuery  string               `json:"mapped_query,omitempty"`
+	ShardFactors []ExplainShardFactor `json:"shard_factors,omitempty"`
+	// Evaluator is the tree of step evaluators the mapped query is
+	// executed with.
+	Evaluator []string         `json:"evaluator,omitempty"`
+	Analysis  *ExplainAnalysis `json:"analysis,omitempty"`
+	Children  []*ExplainNode   `json:"children,omitempty"`
+
+	mtx sync.Mutex
+}
+
+// ExplainShardFactor is the number of shards an expression is split into,
+// guessed from the bytes it's estimated to read.
+type ExplainShardFactor struct {
+	Expression    string `json:"expression"`
+	Factor        int    `json:"factor"`
+	Bytes         uint64 `json:"bytes"`
+	BytesPerShard uint64 `json:"bytes_per_shard"`
+}
+
+// ExplainAnalysis is what happened when executing a node of the plan.
+type ExplainAnalysis struct {
+	Duration        string `json:"duration"`
+	BytesProcessed  int64  `json:"bytes_processed"`
+	LinesProcessed  int64  `json:"lines_processed"`
+	EntriesReturned int64  `json:"entries_returned"`
+	CacheRequests   int32  `json:"cache_requests"`
+	CacheHits       int32  `json:"cache_hits"`
+	Error           string `json:"error,omitempty"`
+}
+
+func newExplainNode(op string, r queryrangebase.Request) *ExplainNode {
+	n := &ExplainNode{
+		Operation: op,
+		Query:     r.GetQuery(),
+		Start:     r.GetStart().UTC(),
+		End:       r.GetEnd().UTC(),
+	}
+	switch r := r.(type) {
+	case *LokiRequest:
+		n.Shards = r.GetShards()
+	case *LokiInstantRequest:
+		n.Shards = r.GetShards()
+	}
+	return n
+}
+
+func (n *ExplainNode) child(op string, r queryrangebase.Request) *ExplainNode {
+	c := newExplainNode(op, r)
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (n *ExplainNode) setMappedQuery(query string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.MappedQuery = query
+}
+
+func (n *ExplainNode) addShardFactor(f ExplainShardFactor) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.ShardFactors = append(n.ShardFactors, f)
+}
+
+func (n *ExplainNode) setEvaluator(rows []string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.Evaluator = rows
+}
+
+func (n *ExplainNode) analyze(d time.Duration, resp queryrangebase.Response, err error) {
+	a := &ExplainAnalysis{Duration: d.String()}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	if r, ok := resp.(interface{ GetStatistics() stats.Result }); ok {
+		s := r.GetStatistics()
+		a.BytesProcessed = s.Summary.TotalBytesProcessed
+		a.LinesProcessed = s.Summary.TotalLinesProcessed
+		a.EntriesReturned = s.Summary.TotalEntriesReturned
+		for _, c := range []stats.Cache{
+			s.Caches.Chunk, s.Caches.Index, s.Caches.Result, s.Caches.StatsResult, s.Caches.VolumeResult,
+			s.Caches.SeriesResult, s.Caches.LabelResult, s.Caches.InstantMetricResult,
+		} {
+			a.CacheRequests += c.EntriesRequested
+			a.CacheHits += c.EntriesFound
+		}
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.Analysis = a
+}
+
+// sort orders the children of the node, which are added as they are
+// executed, by time range and query.
+func (n *ExplainNode) sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.Query != b.Query {
+			return a.Query < b.Query
+		}
+		return strings.Join(a.Shards, ",") < strings.Join(b.Shards, ",")
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+type explainContextKey struct{}
+
+type explainContext struct {
+	node    *ExplainNode
+	analyze bool
+}
+
+func withExplain(ctx context.Context, node *ExplainNode, analyze bool) context.Context {
+	return context.WithValue(ctx, explainContextKey{}, explainContext{node: node, analyze: analyze})
+}
+
+func explainFromContext(ctx context.Context) (explainContext, bool) {
+	ec, ok := ctx.Value(explainContextKey{}).(explainContext)
+	return ec, ok
+}
+
+// explainNodeFromContext returns the node of the plan being executed, if the
+// query is explained.
+func explainNodeFromContext(ctx context.Context) (*ExplainNode, bool) {
+	ec, ok := explainFromContext(ctx)
+	return ec.node, ok
+}
+
+// isExplainDryRun returns true if the query is explained without being
+// executed, in which case the queries sent to the queriers are answered with
+// empty responses.
+func isExplainDryRun(ctx context.Context) bool {
+	ec, ok := explainFromContext(ctx)
+	return ok && !ec.analyze
+}
+
+// explainDo executes the request as a new step of the plan of the explained
+// query, if any.
+func explainDo(ctx context.Context, op string, r queryrangebase.Request, next queryrangebase.Handler) (queryrangebase.Response, error) {
+	ec, ok := explainFromContext(ctx)
+	if !ok {
+		return next.Do(ctx, r)
+	}
+
+	node := ec.node.child(op, r)
+	ctx = withExplain(ctx, node, ec.analyze)
+	if !ec.analyze {
+		return next.Do(ctx, r)
+	}
+
+	start := time.Now()
+	resp, err := next.Do(ctx, r)
+	node.analyze(time.Since(start), resp, err)
+	return resp, err
+}
+
+// explainEvaluator returns a context making the engine record the step
+// evaluators of the query to the node of the plan being executed, and a
+// function to call once the query is executed.
+func explainEvaluator(ctx context.Context) (context.Context, func()) {
+	node, ok := explainNodeFromContext(ctx)
+	if !ok {
+		return ctx, func() {}
+	}
+	tree := logql.NewTree()
+	return logql.WithExplainTree(ctx, tree), func() {
+		if rows := tree.FormattedRows(); len(rows) > 0 {
+			node.setEvaluator(rows)
+		}
+	}
+}
+
+// explainDryRunMiddleware answers the queries of explained queries which are
+// not analyzed with empty responses, instead of sending them to the queriers.
+func explainDryRunMiddleware() queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+			if isExplainDryRun(ctx) {
+				switch r.(type) {
+				case *LokiRequest, *LokiInstantRequest:
+					return NewEmptyResponse(r)
+				}
+			}
+			return next.Do(ctx, r)
+		})
+	})
+}
+
+// shouldCacheRequest returns true if the response to the request can be
+// cached. The empty responses of explained queries are never cached, nor the
+// queries which may include logs deleted by retention since.
+func shouldCacheRequest(ctx context.Context, r queryrangebase.Request) bool {
+	return !r.GetCachingOptions().Disabled && !isExplainDryRun(ctx) && !resultsCacheSkipped(ctx)
+}
+
+type explainHandler struct {
+	next            queryrangebase.Handler
+	limits          ExplainLimits
+	defaultLookback time.Duration
+}
+
+// NewExplainHandler returns a handler explaining how the query frontend
+// executes a query: how it is split, sharded and sent to the queriers. The
+// query is only executed when the analyze parameter is set, otherwise the
+// queriers only answer the index stats requests of the query.
+func NewExplainHandler(next queryrangebase.Handler, limits ExplainLimits, defaultLookback time.Duration) http.Handler {
+	return &explainHandler{
+		next:            next,
+		limits:          limits,
+		defaultLookback: defaultLookback,
+	}
+}
+
+func (h *explainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	var analyze bool
+	if v := r.Form.Get("analyze"); v != "" {
+		var err error
+		if analyze, err = strconv.ParseBool(v); err != nil {
+			serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid analyze parameter: %s", err.Error()), w)
+			return
+		}
+	}
+
+	req, err := decodeQueryRequest(ctx, r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	expr, err := syntax.ParseExpr(req.GetQuery())
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	tenants, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	resp := &ExplainResponse{
+		Query:   req.GetQuery(),
+		Analyze: analyze,
+		Plan:    newExplainNode(ExplainOpQuery, req),
+	}
+	for _, id := range tenants {
+		resp.BloomFilters.Enabled = resp.BloomFilters.Enabled || h.limits.BloomGatewayEnabled(id)
+	}
+	for _, f := range syntax.ExtractLineFilters(expr) {
+		resp.BloomFilters.Filters = append(resp.BloomFilters.Filters, f.String())
+	}
+
+	groups, err := syntax.MatcherGroups(expr)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	if len(groups) > 0 {
+		results, err := getStatsForMatchers(ctx, util_log.WithContext(ctx, util_log.Logger), h.next,
+			model.Time(req.GetStart().UnixMilli()), model.Time(req.GetEnd().UnixMilli()), groups, len(groups), h.defaultLookback)
+		if err != nil {
+			serverutil.WriteError(err, w)
+			return
+		}
+		resp.Stats = indexStats.MergeStats(results...)
+	}
+
+	start := time.Now()
+	res, err := h.next.Do(withExplain(ctx, resp.Plan, analyze), req)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if analyze {
+		resp.Plan.analyze(time.Since(start), res, nil)
+	}
+	resp.Plan.sort()
+
+	util.WriteJSONResponse(w, resp)
+}
+
+// decodeQueryRequest decodes the query of the form as a range query, or as an
+// instant query when only its time is given.
+func decodeQueryRequest(ctx context.Context, r *http.Request) (queryrangebase.Request, error) {
+	path := rangeQueryPath
+	if r.Form.Get("time") != "" && r.Form.Get("start") == "" && r.Form.Get("end") == "" {
+		path = instantQueryPath
+	}
+
+	clone := r.Clone(ctx)
+	clone.URL.Path = path
+	clone.RequestURI = path
+	return DefaultCodec.DecodeRequest(ctx, clone, nil)
+}
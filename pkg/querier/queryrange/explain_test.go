@@ -0,0 +1,137 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	valid "github.com/grafana/loki/v3/pkg/validation"
+)
+
+type fakeExplainLimits struct {
+	bloomGatewayEnabled bool
+}
+
+func (f fakeExplainLimits) BloomGatewayEnabled(string) bool {
+	return f.bloomGatewayEnabled
+}
+
+func TestExplainHandler(t *testing.T) {
+	var l Limits = fakeLimits{
+		maxSeries:               math.MaxInt32,
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		queryTimeout:            time.Minute,
+	}
+	l = WithSplitByLimits(l, 4*time.Hour)
+	cfg := testConfig
+	cfg.CacheResults = false
+	cfg.CacheIndexStatsResults = false
+	cfg.ShardedQueries = true
+	tpw, stopper, err := NewMiddleware(cfg, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{
+		Configs: testSchemasTSDB,
+	}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+
+	query := `sum by (app) (rate({app="foo"} |= "foo" [1m]))`
+	explain := func(t *testing.T, analyze bool) (*ExplainResponse, int) {
+		statsCount, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 3 * uint64(valid.DefaultTSDBMaxBytesPerShard)})
+		queryCount, queryHandler := promqlResult(matrix)
+		h := NewExplainHandler(tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)), fakeExplainLimits{bloomGatewayEnabled: true}, testEngineOpts.MaxLookBackPeriod)
+
+		form := url.Values{
+			"query":   []string{query},
+			"start":   []string{fmt.Sprint(testTime.Add(-6 * time.Hour).UnixNano())},
+			"end":     []string{fmt.Sprint(testTime.UnixNano())},
+			"step":    []string{"30"},
+			"analyze": []string{fmt.Sprint(analyze)},
+		}
+		req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/explain?"+form.Encode(), nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Greater(t, *statsCount, 0)
+
+		var resp ExplainResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return &resp, *queryCount
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		resp, queries := explain(t, false)
+		require.Equal(t, 0, queries)
+
+		require.Equal(t, query, resp.Query)
+		require.False(t, resp.Analyze)
+		require.Equal(t, 3*uint64(valid.DefaultTSDBMaxBytesPerShard), resp.Stats.Bytes)
+		require.Equal(t, ExplainBloomFilters{Enabled: true, Filters: []string{`|= "foo"`}}, resp.BloomFilters)
+
+		plan := resp.Plan
+		require.Equal(t, ExplainOpQuery, plan.Operation)
+		require.Nil(t, plan.Analysis)
+		// The query is split in 4h intervals.
+		require.Len(t, plan.Children, 2)
+		for _, split := range plan.Children {
+			require.Equal(t, ExplainOpSplit, split.Operation)
+			require.Len(t, split.Children, 1)
+
+			mapping := split.Children[0]
+			require.Equal(t, ExplainOpShardMapping, mapping.Operation)
+			require.NotEmpty(t, mapping.MappedQuery)
+			require.NotEmpty(t, mapping.ShardFactors)
+			require.Equal(t, 4, mapping.ShardFactors[0].Factor)
+			require.NotEmpty(t, mapping.Evaluator)
+
+			require.Len(t, mapping.Children, 4)
+			for _, downstream := range mapping.Children {
+				require.Equal(t, ExplainOpDownstream, downstream.Operation)
+				require.Len(t, downstream.Shards, 1)
+				require.Nil(t, downstream.Analysis)
+			}
+		}
+		require.True(t, plan.Children[0].Start.Before(plan.Children[1].Start))
+	})
+
+	t.Run("analyze", func(t *testing.T) {
+		resp, queries := explain(t, true)
+		require.Equal(t, 8, queries)
+
+		plan := resp.Plan
+		require.True(t, resp.Analyze)
+		require.NotNil(t, plan.Analysis)
+		require.Len(t, plan.Children, 2)
+		for _, split := range plan.Children {
+			require.NotNil(t, split.Analysis)
+			require.Empty(t, split.Analysis.Error)
+			for _, downstream := range split.Children[0].Children {
+				require.NotNil(t, downstream.Analysis)
+			}
+		}
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		h := NewExplainHandler(tpw.Wrap(getQueryAndStatsHandler(nil, nil)), fakeExplainLimits{}, testEngineOpts.MaxLookBackPeriod)
+		req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/explain?query=rate(", nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/validation"
 )
 
 // Limits extends the cortex limits interface with support for per tenant splitby parameters
@@ -30,9 +31,14 @@ type Limits interface {
 	TSDBShardingStrategy(userID string) string
 
 	RequiredLabels(context.Context, string) []string
+	QueryPolicies(context.Context, string) []*validation.QueryPolicy
+	TenantFederation(context.Context, string) []*validation.TenantFederationMember
 	RequiredNumberLabels(context.Context, string) int
 	MaxQueryBytesRead(context.Context, string) int
 	MaxQuerierBytesRead(context.Context, string) int
+	QueryCostBudget(string) float64
+	QueryCostBudgetWindow(string) time.Duration
+	QueryCostBudgetAction(string) string
 	MaxStatsCacheFreshness(context.Context, string) time.Duration
 	MaxMetadataCacheFreshness(context.Context, string) time.Duration
 	VolumeEnabled(string) bool
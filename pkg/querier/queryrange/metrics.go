@@ -19,6 +19,9 @@ type Metrics struct {
 	*LogResultCacheMetrics
 	*QueryMetrics
 	*queryrangebase.ResultsCacheMetrics
+	*QueryCostMetrics
+	*QueryPolicyMetrics
+	*QueryAccelerationMetrics
 }
 
 type MiddlewareMapperMetrics struct {
@@ -42,6 +45,9 @@ func NewMetrics(registerer prometheus.Registerer, metricsNamespace string) *Metr
 		LogResultCacheMetrics:       NewLogResultCacheMetrics(registerer),
 		QueryMetrics:                NewMiddlewareQueryMetrics(registerer, metricsNamespace),
 		ResultsCacheMetrics:         queryrangebase.NewResultsCacheMetrics(registerer),
+		QueryCostMetrics:            NewQueryCostMetrics(registerer, metricsNamespace),
+		QueryPolicyMetrics:          NewQueryPolicyMetrics(registerer, metricsNamespace),
+		QueryAccelerationMetrics:    NewQueryAccelerationMetrics(registerer, metricsNamespace),
 	}
 }
 
@@ -0,0 +1,932 @@
+package queryrange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/value"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// absentPoint marks the steps at which an accelerated series has no value.
+var absentPoint = math.Float64frombits(value.StaleNaN)
+
+const acceleratedQueryObjectSuffix = ".json.gz"
+
+// QueryAccelerationConfig configures the acceleration of frequently executed
+// metric queries.
+type QueryAccelerationConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	MinExecutions   int           `yaml:"min_executions"`
+	DetectionWindow time.Duration `yaml:"detection_window"`
+	Resolution      time.Duration `yaml:"resolution"`
+	Backfill        time.Duration `yaml:"backfill"`
+	LateDataWindow  time.Duration `yaml:"late_data_window"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	MaxQueries      int           `yaml:"max_queries"`
+	Store           string        `yaml:"store"`
+	StoreKeyPrefix  string        `yaml:"store_key_prefix"`
+
+	// StoreClient is the client of the store, set when Store is.
+	StoreClient client.ObjectClient `yaml:"-"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *QueryAccelerationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "querier.query-acceleration.enabled", false, "Pre-compute the results of frequently executed range metric queries in the query frontend, and answer the matching queries from them.")
+	f.IntVar(&cfg.MinExecutions, "querier.query-acceleration.min-executions", 10, "Number of executions of a range metric query within the detection window after which the query is accelerated. 0 to only accelerate the queries requested with the accelerate API.")
+	f.DurationVar(&cfg.DetectionWindow, "querier.query-acceleration.detection-window", 10*time.Minute, "Window in which the executions of a query are counted.")
+	f.DurationVar(&cfg.Resolution, "querier.query-acceleration.resolution", time.Minute, "Resolution at which the results of accelerated queries are pre-computed. Only queries whose step is a multiple of the resolution and whose start is aligned to it are answered from pre-computed results.")
+	f.DurationVar(&cfg.Backfill, "querier.query-acceleration.backfill", 7*24*time.Hour, "Period before now for which the results of accelerated queries are pre-computed.")
+	f.DurationVar(&cfg.LateDataWindow, "querier.query-acceleration.late-data-window", time.Hour, "Results newer than this, or than the max_cache_freshness_per_query of the tenant if greater, are never pre-computed, so that late data is taken into account. This part of the queries is always executed.")
+	f.DurationVar(&cfg.RefreshInterval, "querier.query-acceleration.refresh-interval", time.Minute, "Interval at which the results of accelerated queries are extended to the newest results outside of the late data window.")
+	f.DurationVar(&cfg.IdleTimeout, "querier.query-acceleration.idle-timeout", 24*time.Hour, "Queries accelerated because they were executed frequently stop being accelerated when they are not executed for this long.")
+	f.IntVar(&cfg.MaxQueries, "querier.query-acceleration.max-queries", 100, "Maximum number of accelerated queries.")
+	f.StringVar(&cfg.Store, "querier.query-acceleration.store", "", "Store the accelerated queries and their pre-computed results are persisted in, e.g. s3 or filesystem, so that they are shared by the query frontends and survive their restarts. Required when query acceleration is enabled.")
+	f.StringVar(&cfg.StoreKeyPrefix, "querier.query-acceleration.store-key-prefix", "query-acceleration/", "Path prefix of the accelerated queries in the store.")
+}
+
+// Validate validates the config.
+func (cfg *QueryAccelerationConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Resolution <= 0 {
+		return errors.New("query acceleration resolution must be greater than 0")
+	}
+	if cfg.Backfill < cfg.Resolution {
+		return errors.New("query acceleration backfill must be at least the resolution")
+	}
+	if cfg.RefreshInterval <= 0 {
+		return errors.New("query acceleration refresh interval must be greater than 0")
+	}
+	if cfg.Store == "" {
+		return errors.New("querier.query-acceleration.store must be set when query acceleration is enabled")
+	}
+	if err := config.ValidatePathPrefix(cfg.StoreKeyPrefix); err != nil {
+		return fmt.Errorf("validate query acceleration store path prefix: %w", err)
+	}
+	return nil
+}
+
+type QueryAccelerationMetrics struct {
+	queries         prometheus.Gauge
+	requests        *prometheus.CounterVec
+	refreshFailures prometheus.Counter
+}
+
+func NewQueryAccelerationMetrics(registerer prometheus.Registerer, metricsNamespace string) *QueryAccelerationMetrics {
+	return &QueryAccelerationMetrics{
+		queries: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_accelerated_queries",
+			Help:      "Number of accelerated queries.",
+		}),
+		requests: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_accelerated_requests_total",
+			Help:      "Number of executions of accelerated queries, by whether they were answered from pre-computed results.",
+		}, []string{"result"}),
+		refreshFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_accelerated_query_refresh_failures_total",
+			Help:      "Number of failed refreshes of the pre-computed results of accelerated queries.",
+		}),
+	}
+}
+
+// AcceleratedQuery describes an accelerated query and its pre-computed results.
+type AcceleratedQuery struct {
+	Tenant     string         `json:"tenant"`
+	Query      string         `json:"query"`
+	Manual     bool           `json:"manual"`
+	Resolution model.Duration `json:"resolution"`
+	// From and Through are the first and last steps of the pre-computed
+	// results, unset until the results are first computed.
+	From      *time.Time `json:"from,omitempty"`
+	Through   *time.Time `json:"through,omitempty"`
+	Series    int        `json:"series"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type acceleratedSeries struct {
+	labels []logproto.LabelAdapter
+	// values holds the value of each step from the first step of the query,
+	// absentPoint if the series has no value at the step.
+	values []float64
+}
+
+type acceleratedQuery struct {
+	tenant string
+	query  string
+	expr   syntax.SampleExpr
+	manual bool
+
+	// from and through are the first and last pre-computed steps, both unset
+	// until the results are first computed.
+	from, through time.Time
+	series        map[uint64]*acceleratedSeries
+	cacheGen      string
+	lastUsed      time.Time
+	lastErr       error
+
+	// stored is the modification time of the object of the query in the
+	// store when it was last loaded or saved by this query frontend, unset
+	// until then, and storedLastUsed the last use recorded in the object.
+	stored         time.Time
+	storedLastUsed time.Time
+}
+
+func (q *acceleratedQuery) computed() bool {
+	return !q.through.IsZero()
+}
+
+func (q *acceleratedQuery) describe(resolution time.Duration) AcceleratedQuery {
+	res := AcceleratedQuery{
+		Tenant:     q.tenant,
+		Query:      q.query,
+		Manual:     q.manual,
+		Resolution: model.Duration(resolution),
+		Series:     len(q.series),
+	}
+	if q.computed() {
+		from, through := q.from, q.through
+		res.From, res.Through = &from, &through
+	}
+	if q.lastErr != nil {
+		res.LastError = q.lastErr.Error()
+	}
+	return res
+}
+
+type acceleratedQueryKey struct {
+	tenant, query string
+}
+
+// storedAcceleratedQuery is an accelerated query persisted in the store.
+type storedAcceleratedQuery struct {
+	Tenant   string                    `json:"tenant"`
+	Query    string                    `json:"query"`
+	Manual   bool                      `json:"manual"`
+	LastUsed time.Time                 `json:"last_used"`
+	CacheGen string                    `json:"cache_gen,omitempty"`
+	From     time.Time                 `json:"from"`
+	Through  time.Time                 `json:"through"`
+	Series   []storedAcceleratedSeries `json:"series,omitempty"`
+}
+
+type storedAcceleratedSeries struct {
+	Labels []logproto.LabelAdapter `json:"labels"`
+	// Values holds the bits of the value of each step, as JSON can't
+	// represent the NaN marking absent steps.
+	Values []uint64 `json:"values"`
+}
+
+// QueryAccelerator pre-computes the results of frequently executed range
+// metric queries at a fixed resolution, and answers the older steps of the
+// matching queries from them. The steps within the late data window are
+// never pre-computed, so late data is taken into account by the part of the
+// query which is still executed. The accelerated queries and their results
+// are persisted in the store, from which each query frontend loads those
+// accelerated or refreshed by the others.
+type QueryAccelerator struct {
+	cfg         QueryAccelerationConfig
+	client      client.ObjectClient
+	limits      Limits
+	merger      queryrangebase.Merger
+	cacheGenNum queryrangebase.CacheGenNumberLoader
+	logger      log.Logger
+	metrics     *QueryAccelerationMetrics
+	now         func() time.Time
+
+	mtx        sync.RWMutex
+	queries    map[acceleratedQueryKey]*acceleratedQuery
+	executions map[acceleratedQueryKey][]time.Time
+
+	// next executes the queries computing the results, once the accelerator
+	// is part of a handler.
+	next     queryrangebase.Handler
+	start    sync.Once
+	stop     context.CancelFunc
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewQueryAccelerator creates a new QueryAccelerator. Its results are
+// computed in the background once it wraps a handler, until it is stopped,
+// and persisted with the client.
+func NewQueryAccelerator(cfg QueryAccelerationConfig, objectClient client.ObjectClient, limits Limits, merger queryrangebase.Merger, cacheGenNum queryrangebase.CacheGenNumberLoader, logger log.Logger, metrics *QueryAccelerationMetrics) *QueryAccelerator {
+	return &QueryAccelerator{
+		cfg:         cfg,
+		client:      objectClient,
+		limits:      limits,
+		merger:      merger,
+		cacheGenNum: cacheGenNum,
+		logger:      logger,
+		metrics:     metrics,
+		now:         time.Now,
+		queries:     map[acceleratedQueryKey]*acceleratedQuery{},
+		executions:  map[acceleratedQueryKey][]time.Time{},
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Wrap implements queryrangebase.Middleware.
+func (a *QueryAccelerator) Wrap(next queryrangebase.Handler) queryrangebase.Handler {
+	a.start.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		a.next, a.stop = next, cancel
+		go a.run(ctx)
+	})
+	return queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		return a.do(ctx, r, next)
+	})
+}
+
+// Stop stops computing the results of the accelerated queries.
+func (a *QueryAccelerator) Stop() {
+	a.stopOnce.Do(func() {
+		if a.stop == nil {
+			close(a.stopped)
+			return
+		}
+		a.stop()
+		<-a.stopped
+	})
+}
+
+func (a *QueryAccelerator) run(ctx context.Context) {
+	defer close(a.stopped)
+
+	// The queries are loaded right away, and computed at the first refresh.
+	if err := a.sync(ctx); err != nil {
+		level.Warn(a.logger).Log("msg", "failed to load accelerated queries", "err", err)
+	}
+
+	ticker := time.NewTicker(a.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.refreshAll(ctx)
+		}
+	}
+}
+
+// acceptedQuery returns the query of the request and its key if it can be
+// accelerated.
+func acceptedQuery(ctx context.Context, r queryrangebase.Request) (*LokiRequest, syntax.SampleExpr, acceleratedQueryKey, bool) {
+	req, ok := r.(*LokiRequest)
+	if !ok || req.Plan == nil {
+		return nil, nil, acceleratedQueryKey{}, false
+	}
+	expr, ok := req.Plan.AST.(syntax.SampleExpr)
+	if !ok {
+		return nil, nil, acceleratedQueryKey{}, false
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil || len(tenantIDs) != 1 {
+		return nil, nil, acceleratedQueryKey{}, false
+	}
+	return req, expr, acceleratedQueryKey{tenant: tenantIDs[0], query: expr.String()}, true
+}
+
+func (a *QueryAccelerator) do(ctx context.Context, r queryrangebase.Request, next queryrangebase.Handler) (queryrangebase.Response, error) {
+	req, expr, key, ok := acceptedQuery(ctx, r)
+	if action, isAction := accelerationActionFromContext(ctx); isAction {
+		if !ok {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "only range metric queries of a single tenant can be accelerated")
+		}
+		desc, err := a.apply(ctx, action.method, key, expr)
+		if err != nil {
+			return nil, err
+		}
+		action.add(desc)
+		return NewEmptyResponse(r)
+	}
+	if !ok {
+		return next.Do(ctx, r)
+	}
+
+	now := a.now()
+	a.mtx.Lock()
+	q, ok := a.queries[key]
+	if ok {
+		q.lastUsed = now
+	} else {
+		a.detect(key, expr, now)
+	}
+	a.mtx.Unlock()
+	if !ok {
+		return next.Do(ctx, r)
+	}
+
+	stored, from, through, ok := a.storedResponse(key, req)
+	if !ok {
+		a.metrics.requests.WithLabelValues("miss").Inc()
+		return next.Do(ctx, r)
+	}
+
+	// The steps before and after the pre-computed ones are executed.
+	step := time.Duration(req.Step) * time.Millisecond
+	var head, tail queryrangebase.Response
+	g, gctx := errgroup.WithContext(ctx)
+	if from.After(req.StartTs) {
+		g.Go(func() (err error) {
+			head, err = next.Do(gctx, r.WithStartEnd(req.StartTs, from.Add(-step)))
+			return err
+		})
+	}
+	if nextStep := through.Add(step); !nextStep.After(req.EndTs) {
+		g.Go(func() (err error) {
+			tail, err = next.Do(gctx, r.WithStartEnd(nextStep, req.EndTs))
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if head == nil && tail == nil {
+		a.metrics.requests.WithLabelValues("hit").Inc()
+		return stored, nil
+	}
+
+	a.metrics.requests.WithLabelValues("partial").Inc()
+	resps := make([]queryrangebase.Response, 0, 3)
+	for _, resp := range []queryrangebase.Response{head, stored, tail} {
+		if resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+	return a.merger.MergeResponse(resps...)
+}
+
+// detect counts the execution of a query which isn't accelerated yet, and
+// accelerates it once it is executed frequently.
+func (a *QueryAccelerator) detect(key acceleratedQueryKey, expr syntax.SampleExpr, now time.Time) {
+	if a.cfg.MinExecutions <= 0 {
+		return
+	}
+	executions := a.executions[key]
+	for len(executions) > 0 && now.Sub(executions[0]) > a.cfg.DetectionWindow {
+		executions = executions[1:]
+	}
+	executions = append(executions, now)
+	if len(executions) < a.cfg.MinExecutions || len(a.queries) >= a.cfg.MaxQueries {
+		a.executions[key] = executions
+		return
+	}
+	delete(a.executions, key)
+	a.add(key, expr, false, now)
+	level.Info(a.logger).Log("msg", "accelerating frequently executed query", "tenant", key.tenant, "query", key.query)
+}
+
+func (a *QueryAccelerator) add(key acceleratedQueryKey, expr syntax.SampleExpr, manual bool, now time.Time) *acceleratedQuery {
+	q := &acceleratedQuery{
+		tenant:   key.tenant,
+		query:    key.query,
+		expr:     expr,
+		manual:   manual,
+		series:   map[uint64]*acceleratedSeries{},
+		lastUsed: now,
+	}
+	a.queries[key] = q
+	a.metrics.queries.Set(float64(len(a.queries)))
+	return q
+}
+
+// apply executes a request of the accelerate API on the query, and persists
+// its result.
+func (a *QueryAccelerator) apply(ctx context.Context, method string, key acceleratedQueryKey, expr syntax.SampleExpr) (AcceleratedQuery, error) {
+	// The query may be accelerated by another query frontend.
+	if err := a.sync(ctx); err != nil {
+		return AcceleratedQuery{}, err
+	}
+
+	q, err := a.applyLocked(method, key, expr)
+	if err != nil {
+		return AcceleratedQuery{}, err
+	}
+	switch method {
+	case http.MethodPost:
+		err = a.save(ctx, q)
+	case http.MethodDelete:
+		err = a.deleteStored(ctx, key)
+	}
+	if err != nil {
+		return AcceleratedQuery{}, err
+	}
+
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return q.describe(a.cfg.Resolution), nil
+}
+
+func (a *QueryAccelerator) applyLocked(method string, key acceleratedQueryKey, expr syntax.SampleExpr) (*acceleratedQuery, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	q, ok := a.queries[key]
+	switch method {
+	case http.MethodPost:
+		if !ok {
+			if len(a.queries) >= a.cfg.MaxQueries {
+				return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "the maximum number of accelerated queries (%d) is reached", a.cfg.MaxQueries)
+			}
+			q = a.add(key, expr, true, a.now())
+			delete(a.executions, key)
+		}
+		q.manual = true
+	case http.MethodDelete:
+		if !ok {
+			return nil, httpgrpc.Errorf(http.StatusNotFound, "query is not accelerated")
+		}
+		delete(a.queries, key)
+		a.metrics.queries.Set(float64(len(a.queries)))
+	default:
+		if !ok {
+			return nil, httpgrpc.Errorf(http.StatusNotFound, "query is not accelerated")
+		}
+	}
+	return q, nil
+}
+
+// storedResponse returns the response to the request for the steps which are
+// pre-computed, and the first and last of these steps. The steps before and
+// after them are left to be executed.
+func (a *QueryAccelerator) storedResponse(key acceleratedQueryKey, req *LokiRequest) (queryrangebase.Response, time.Time, time.Time, bool) {
+	resolution := a.cfg.Resolution.Milliseconds()
+	if req.Step <= 0 || req.Step%resolution != 0 || req.StartTs.UnixMilli()%resolution != 0 {
+		return nil, time.Time{}, time.Time{}, false
+	}
+
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	q, ok := a.queries[key]
+	if !ok || !q.computed() {
+		return nil, time.Time{}, time.Time{}, false
+	}
+
+	step := time.Duration(req.Step) * time.Millisecond
+	start := req.StartTs
+	if start.Before(q.from) {
+		start = start.Add((q.from.Sub(start) + step - 1) / step * step)
+	}
+	end := req.EndTs
+	if end.After(q.through) {
+		end = q.through
+	}
+	if start.After(end) {
+		return nil, time.Time{}, time.Time{}, false
+	}
+	steps := int(end.Sub(start)/step) + 1
+	through := start.Add(time.Duration(steps-1) * step)
+
+	result := make([]queryrangebase.SampleStream, 0, len(q.series))
+	for _, s := range q.series {
+		var samples []logproto.LegacySample
+		for i := 0; i < steps; i++ {
+			ts := start.Add(time.Duration(i) * step)
+			idx := int(ts.Sub(q.from) / a.cfg.Resolution)
+			if idx >= len(s.values) || value.IsStaleNaN(s.values[idx]) {
+				continue
+			}
+			samples = append(samples, logproto.LegacySample{TimestampMs: ts.UnixMilli(), Value: s.values[idx]})
+		}
+		if len(samples) > 0 {
+			result = append(result, queryrangebase.SampleStream{Labels: s.labels, Samples: samples})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return logproto.FromLabelAdaptersToLabels(result[i].Labels).String() < logproto.FromLabelAdaptersToLabels(result[j].Labels).String()
+	})
+
+	return &LokiPromResponse{
+		Response: &queryrangebase.PrometheusResponse{
+			Status: loghttp.QueryStatusSuccess,
+			Data: queryrangebase.PrometheusData{
+				ResultType: loghttp.ResultTypeMatrix,
+				Result:     result,
+			},
+		},
+	}, start, through, true
+}
+
+// refreshAll extends the results of the accelerated queries to the newest
+// steps outside of the late data window, and stops accelerating the queries
+// which are not used anymore.
+func (a *QueryAccelerator) refreshAll(ctx context.Context) {
+	if err := a.sync(ctx); err != nil {
+		level.Warn(a.logger).Log("msg", "failed to load accelerated queries", "err", err)
+	}
+
+	now := a.now()
+	a.mtx.Lock()
+	queries := make([]*acceleratedQuery, 0, len(a.queries))
+	var idle []acceleratedQueryKey
+	for key, q := range a.queries {
+		if !q.manual && now.Sub(q.lastUsed) > a.cfg.IdleTimeout {
+			delete(a.queries, key)
+			idle = append(idle, key)
+			continue
+		}
+		queries = append(queries, q)
+	}
+	for key, executions := range a.executions {
+		if now.Sub(executions[len(executions)-1]) > a.cfg.DetectionWindow {
+			delete(a.executions, key)
+		}
+	}
+	a.metrics.queries.Set(float64(len(a.queries)))
+	a.mtx.Unlock()
+
+	for _, key := range idle {
+		if err := a.deleteStored(ctx, key); err != nil {
+			level.Warn(a.logger).Log("msg", "failed to delete idle accelerated query", "tenant", key.tenant, "query", key.query, "err", err)
+		}
+	}
+	for _, q := range queries {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := a.refresh(ctx, q, now); err != nil {
+			a.metrics.refreshFailures.Inc()
+			level.Warn(a.logger).Log("msg", "failed to refresh accelerated query", "tenant", q.tenant, "query", q.query, "err", err)
+		}
+	}
+}
+
+func (a *QueryAccelerator) refresh(ctx context.Context, q *acceleratedQuery, now time.Time) error {
+	err := a.compute(ctx, q, now)
+
+	a.mtx.Lock()
+	q.lastErr = err
+	// The last use is persisted often enough for the other query frontends
+	// not to consider the query idle.
+	save := err == nil && (q.stored.IsZero() || q.lastUsed.Sub(q.storedLastUsed) > a.cfg.IdleTimeout/2)
+	a.mtx.Unlock()
+	if save {
+		err = a.save(ctx, q)
+	}
+	return err
+}
+
+// compute computes the results of the query up to the newest step outside of
+// the late data window, and persists them.
+func (a *QueryAccelerator) compute(ctx context.Context, q *acceleratedQuery, now time.Time) error {
+	ctx = user.InjectOrgID(ctx, q.tenant)
+
+	lateDataWindow := a.cfg.LateDataWindow
+	if freshness := a.limits.MaxCacheFreshness(ctx, q.tenant); freshness > lateDataWindow {
+		lateDataWindow = freshness
+	}
+	from := now.Add(-a.cfg.Backfill).Truncate(a.cfg.Resolution)
+	through := now.Add(-lateDataWindow).Truncate(a.cfg.Resolution)
+
+	var cacheGen string
+	if a.cacheGenNum != nil {
+		cacheGen = a.cacheGenNum.GetResultsCacheGenNumber([]string{q.tenant})
+	}
+
+	a.mtx.RLock()
+	start := from
+	// The results are computed again when logs of the tenant are deleted.
+	if q.computed() && q.cacheGen == cacheGen && !q.through.Before(from) {
+		start = q.through.Add(a.cfg.Resolution)
+	}
+	a.mtx.RUnlock()
+	if start.After(through) {
+		return nil
+	}
+
+	resp, err := a.next.Do(ctx, &LokiRequest{
+		Query:     q.query,
+		StartTs:   start,
+		EndTs:     through,
+		Step:      a.cfg.Resolution.Milliseconds(),
+		Direction: logproto.FORWARD,
+		Path:      "/loki/api/v1/query_range",
+		Plan:      &plan.QueryPlan{AST: q.expr},
+	})
+	if err != nil {
+		return err
+	}
+	promResp, ok := resp.(*LokiPromResponse)
+	if !ok || promResp.Response == nil {
+		return fmt.Errorf("unexpected response type %T", resp)
+	}
+
+	a.mtx.Lock()
+	a.store(q, from, start, through, promResp.Response.Data.Result)
+	q.cacheGen = cacheGen
+	a.mtx.Unlock()
+	return a.save(ctx, q)
+}
+
+func (a *QueryAccelerator) objectKey(key acceleratedQueryKey) string {
+	return fmt.Sprintf("%s%s/%016x%s", a.cfg.StoreKeyPrefix, key.tenant, xxhash.Sum64String(key.query), acceleratedQueryObjectSuffix)
+}
+
+// sync loads the queries accelerated or refreshed by other query frontends
+// from the store, and stops accelerating those removed from it.
+func (a *QueryAccelerator) sync(ctx context.Context) error {
+	objects, _, err := a.client.List(ctx, a.cfg.StoreKeyPrefix, "")
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]time.Time, len(objects))
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, acceleratedQueryObjectSuffix) {
+			listed[object.Key] = object.ModifiedAt
+		}
+	}
+
+	a.mtx.Lock()
+	for key, q := range a.queries {
+		objectKey := a.objectKey(key)
+		modified, ok := listed[objectKey]
+		switch {
+		case !ok && !q.stored.IsZero():
+			delete(a.queries, key)
+		case ok && !modified.After(q.stored):
+			delete(listed, objectKey)
+		}
+	}
+	a.metrics.queries.Set(float64(len(a.queries)))
+	a.mtx.Unlock()
+
+	for objectKey, modified := range listed {
+		stored, err := a.load(ctx, objectKey)
+		if a.client.IsObjectNotFoundErr(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.adopt(stored, modified); err != nil {
+			level.Warn(a.logger).Log("msg", "failed to load accelerated query", "key", objectKey, "err", err)
+		}
+	}
+	return nil
+}
+
+// adopt updates the query with its state in the store, and accelerates it if
+// it isn't yet. The stored results are kept if they are newer.
+func (a *QueryAccelerator) adopt(stored *storedAcceleratedQuery, modified time.Time) error {
+	key := acceleratedQueryKey{tenant: stored.Tenant, query: stored.Query}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	q, ok := a.queries[key]
+	if !ok {
+		expr, err := syntax.ParseSampleExpr(stored.Query)
+		if err != nil {
+			return err
+		}
+		q = a.add(key, expr, stored.Manual, stored.LastUsed)
+		delete(a.executions, key)
+	}
+	q.manual = stored.Manual
+	if stored.LastUsed.After(q.lastUsed) {
+		q.lastUsed = stored.LastUsed
+	}
+	q.stored, q.storedLastUsed = modified, stored.LastUsed
+
+	if stored.Through.IsZero() || (q.computed() && !stored.Through.After(q.through)) {
+		return nil
+	}
+	q.from, q.through, q.cacheGen = stored.From, stored.Through, stored.CacheGen
+	q.series = make(map[uint64]*acceleratedSeries, len(stored.Series))
+	for _, s := range stored.Series {
+		values := make([]float64, len(s.Values))
+		for i, bits := range s.Values {
+			values[i] = math.Float64frombits(bits)
+		}
+		q.series[logproto.FromLabelAdaptersToLabels(s.Labels).Hash()] = &acceleratedSeries{labels: s.Labels, values: values}
+	}
+	return nil
+}
+
+func (a *QueryAccelerator) load(ctx context.Context, objectKey string) (*storedAcceleratedQuery, error) {
+	r, _, err := a.client.GetObject(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var stored storedAcceleratedQuery
+	if err := json.NewDecoder(gz).Decode(&stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// save persists the query and its results in the store.
+func (a *QueryAccelerator) save(ctx context.Context, q *acceleratedQuery) error {
+	a.mtx.RLock()
+	stored := storedAcceleratedQuery{
+		Tenant:   q.tenant,
+		Query:    q.query,
+		Manual:   q.manual,
+		LastUsed: q.lastUsed,
+		CacheGen: q.cacheGen,
+		From:     q.from,
+		Through:  q.through,
+		Series:   make([]storedAcceleratedSeries, 0, len(q.series)),
+	}
+	for _, s := range q.series {
+		values := make([]uint64, len(s.values))
+		for i, v := range s.values {
+			values[i] = math.Float64bits(v)
+		}
+		stored.Series = append(stored.Series, storedAcceleratedSeries{Labels: s.labels, Values: values})
+	}
+	a.mtx.RUnlock()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(stored); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := a.client.PutObject(ctx, a.objectKey(acceleratedQueryKey{tenant: q.tenant, query: q.query}), bytes.NewReader(buf.Bytes())); err != nil {
+		return err
+	}
+
+	a.mtx.Lock()
+	q.stored, q.storedLastUsed = a.now(), stored.LastUsed
+	a.mtx.Unlock()
+	return nil
+}
+
+func (a *QueryAccelerator) deleteStored(ctx context.Context, key acceleratedQueryKey) error {
+	if err := a.client.DeleteObject(ctx, a.objectKey(key)); err != nil && !a.client.IsObjectNotFoundErr(err) {
+		return err
+	}
+	return nil
+}
+
+// store adds the results of the steps from start to through to the query,
+// and drops the results before from.
+func (a *QueryAccelerator) store(q *acceleratedQuery, from, start, through time.Time, result []queryrangebase.SampleStream) {
+	if !q.computed() || start.Equal(from) {
+		q.from, q.series = from, map[uint64]*acceleratedSeries{}
+	} else if from.After(q.from) {
+		drop := int(from.Sub(q.from) / a.cfg.Resolution)
+		for hash, s := range q.series {
+			if drop >= len(s.values) {
+				delete(q.series, hash)
+				continue
+			}
+			s.values = s.values[drop:]
+		}
+		q.from = from
+	}
+	q.through = through
+
+	size := int(through.Sub(q.from)/a.cfg.Resolution) + 1
+	for _, s := range result {
+		hash := logproto.FromLabelAdaptersToLabels(s.Labels).Hash()
+		series, ok := q.series[hash]
+		if !ok {
+			series = &acceleratedSeries{labels: s.Labels}
+			q.series[hash] = series
+		}
+		series.values = padAbsent(series.values, size)
+		for _, sample := range s.Samples {
+			idx := int(time.UnixMilli(sample.TimestampMs).Sub(q.from) / a.cfg.Resolution)
+			if idx >= 0 && idx < size {
+				series.values[idx] = sample.Value
+			}
+		}
+	}
+	for _, s := range q.series {
+		s.values = padAbsent(s.values, size)
+	}
+}
+
+func padAbsent(values []float64, size int) []float64 {
+	for len(values) < size {
+		values = append(values, absentPoint)
+	}
+	return values
+}
+
+type accelerationActionContextKey struct{}
+
+// accelerationAction is a request of the accelerate API, executed on the
+// queries reaching the accelerator instead of answering them.
+type accelerationAction struct {
+	method string
+
+	mtx     sync.Mutex
+	queries []AcceleratedQuery
+}
+
+func (a *accelerationAction) add(q AcceleratedQuery) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.queries = append(a.queries, q)
+}
+
+func accelerationActionFromContext(ctx context.Context) (*accelerationAction, bool) {
+	action, ok := ctx.Value(accelerationActionContextKey{}).(*accelerationAction)
+	return action, ok
+}
+
+// AccelerateResponse is the response of the accelerate API.
+type AccelerateResponse struct {
+	Queries []AcceleratedQuery `json:"queries"`
+}
+
+type accelerateHandler struct {
+	next queryrangebase.Handler
+}
+
+// NewAccelerateHandler returns a handler accelerating a range metric query
+// (POST), returning the state of its acceleration (GET) or stopping its
+// acceleration (DELETE). The query is handled by next as if it were executed,
+// so it is accelerated for the tenants and with the rewrites it would be
+// executed with.
+func NewAccelerateHandler(next queryrangebase.Handler) http.Handler {
+	return &accelerateHandler{next: next}
+}
+
+func (h *accelerateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	req, err := decodeQueryRequest(ctx, r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if _, ok := req.(*LokiRequest); !ok {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "only range queries can be accelerated"), w)
+		return
+	}
+	if _, ok := req.(*LokiRequest).Plan.AST.(syntax.SampleExpr); !ok {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "only metric queries can be accelerated"), w)
+		return
+	}
+
+	action := &accelerationAction{method: r.Method}
+	if _, err := h.next.Do(context.WithValue(ctx, accelerationActionContextKey{}, action), req); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if len(action.queries) == 0 {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "query acceleration is disabled"), w)
+		return
+	}
+	sort.Slice(action.queries, func(i, j int) bool {
+		return action.queries[i].Tenant < action.queries[j].Tenant
+	})
+
+	util.WriteJSONResponse(w, AccelerateResponse{Queries: action.queries})
+}
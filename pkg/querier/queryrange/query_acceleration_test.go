@@ -0,0 +1,265 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+type acceleratedRange struct {
+	start, end time.Time
+	step       time.Duration
+}
+
+func newTestAcceleratorStore(t *testing.T) client.ObjectClient {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	return objectClient
+}
+
+// newTestAccelerator returns an accelerator persisting its queries with the
+// client and wrapping a handler answering each step of the queries with its
+// unix time, and the ranges the handler executed.
+func newTestAccelerator(t *testing.T, now time.Time, objectClient client.ObjectClient) (*QueryAccelerator, base.Handler, func() []acceleratedRange) {
+	var (
+		mtx    sync.Mutex
+		ranges []acceleratedRange
+	)
+	next := base.HandlerFunc(func(_ context.Context, r base.Request) (base.Response, error) {
+		req := r.(*LokiRequest)
+		step := time.Duration(req.Step) * time.Millisecond
+		mtx.Lock()
+		ranges = append(ranges, acceleratedRange{req.StartTs, req.EndTs, step})
+		mtx.Unlock()
+
+		var samples []logproto.LegacySample
+		for ts := req.StartTs; !ts.After(req.EndTs); ts = ts.Add(step) {
+			samples = append(samples, logproto.LegacySample{TimestampMs: ts.UnixMilli(), Value: float64(ts.Unix())})
+		}
+		return &LokiPromResponse{
+			Response: &base.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: base.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+					Result:     []base.SampleStream{{Labels: []logproto.LabelAdapter{{Name: "app", Value: "foo"}}, Samples: samples}},
+				},
+			},
+		}, nil
+	})
+
+	a := NewQueryAccelerator(QueryAccelerationConfig{
+		Enabled:         true,
+		MinExecutions:   2,
+		DetectionWindow: 10 * time.Minute,
+		Resolution:      time.Minute,
+		Backfill:        2 * time.Hour,
+		LateDataWindow:  10 * time.Minute,
+		RefreshInterval: time.Hour,
+		IdleTimeout:     time.Hour,
+		MaxQueries:      10,
+		StoreKeyPrefix:  "query-acceleration/",
+	}, objectClient, fakeLimits{}, DefaultCodec, nil, util_log.Logger, NewQueryAccelerationMetrics(prometheus.NewRegistry(), "loki"))
+	a.now = func() time.Time { return now }
+	h := a.Wrap(next)
+	t.Cleanup(a.Stop)
+
+	return a, h, func() []acceleratedRange {
+		mtx.Lock()
+		defer mtx.Unlock()
+		res := ranges
+		ranges = nil
+		return res
+	}
+}
+
+func acceleratedQueryRequest(start, end time.Time, step time.Duration) *LokiRequest {
+	query := `sum by (app) (rate({app="foo"}[1m]))`
+	return &LokiRequest{
+		Query:   query,
+		StartTs: start,
+		EndTs:   end,
+		Step:    step.Milliseconds(),
+		Path:    "/loki/api/v1/query_range",
+		Plan:    &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+	}
+}
+
+func TestQueryAccelerator(t *testing.T) {
+	now := testTime.Truncate(time.Minute)
+	a, h, executed := newTestAccelerator(t, now, newTestAcceleratorStore(t))
+	ctx := user.InjectOrgID(context.Background(), "1")
+	req := acceleratedQueryRequest(now.Add(-time.Hour), now, 2*time.Minute)
+
+	// The query is accelerated once executed frequently.
+	for i := 0; i < 2; i++ {
+		_, err := h.Do(ctx, req)
+		require.NoError(t, err)
+	}
+	require.Len(t, executed(), 2)
+	require.Len(t, a.queries, 1)
+
+	a.refreshAll(ctx)
+	require.Equal(t, []acceleratedRange{{now.Add(-2 * time.Hour), now.Add(-10 * time.Minute), time.Minute}}, executed())
+
+	t.Run("partially pre-computed", func(t *testing.T) {
+		resp, err := h.Do(ctx, req)
+		require.NoError(t, err)
+		// Only the steps within the late data window are executed.
+		require.Equal(t, []acceleratedRange{{now.Add(-8 * time.Minute), now, 2 * time.Minute}}, executed())
+
+		result := resp.(*LokiPromResponse).Response.Data.Result
+		require.Len(t, result, 1)
+		require.Len(t, result[0].Samples, 31)
+		for i, s := range result[0].Samples {
+			ts := now.Add(-time.Hour).Add(time.Duration(i) * 2 * time.Minute)
+			require.Equal(t, logproto.LegacySample{TimestampMs: ts.UnixMilli(), Value: float64(ts.Unix())}, s)
+		}
+	})
+
+	t.Run("fully pre-computed", func(t *testing.T) {
+		resp, err := h.Do(ctx, acceleratedQueryRequest(now.Add(-time.Hour), now.Add(-30*time.Minute), time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, executed())
+		require.Len(t, resp.(*LokiPromResponse).Response.Data.Result[0].Samples, 31)
+	})
+
+	t.Run("starting before the pre-computed steps", func(t *testing.T) {
+		resp, err := h.Do(ctx, acceleratedQueryRequest(now.Add(-3*time.Hour), now, 2*time.Minute))
+		require.NoError(t, err)
+		// Only the steps before and after the pre-computed ones are executed.
+		require.ElementsMatch(t, []acceleratedRange{
+			{now.Add(-3 * time.Hour), now.Add(-2*time.Hour - 2*time.Minute), 2 * time.Minute},
+			{now.Add(-8 * time.Minute), now, 2 * time.Minute},
+		}, executed())
+
+		result := resp.(*LokiPromResponse).Response.Data.Result
+		require.Len(t, result, 1)
+		require.Len(t, result[0].Samples, 91)
+		for i, s := range result[0].Samples {
+			ts := now.Add(-3 * time.Hour).Add(time.Duration(i) * 2 * time.Minute)
+			require.Equal(t, logproto.LegacySample{TimestampMs: ts.UnixMilli(), Value: float64(ts.Unix())}, s)
+		}
+	})
+
+	t.Run("unaligned start", func(t *testing.T) {
+		_, err := h.Do(ctx, acceleratedQueryRequest(now.Add(-time.Hour+time.Second), now, time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, []acceleratedRange{{now.Add(-time.Hour + time.Second), now, time.Minute}}, executed())
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		later := now.Add(5 * time.Minute)
+		a.now = func() time.Time { return later }
+		a.refreshAll(ctx)
+		require.Equal(t, []acceleratedRange{{now.Add(-9 * time.Minute), now.Add(-5 * time.Minute), time.Minute}}, executed())
+
+		q := a.queries[acceleratedQueryKey{tenant: "1", query: req.Plan.AST.String()}]
+		require.Equal(t, later.Add(-2*time.Hour), q.from)
+		require.Equal(t, later.Add(-10*time.Minute), q.through)
+		require.Len(t, q.series[logproto.FromLabelAdaptersToLabels([]logproto.LabelAdapter{{Name: "app", Value: "foo"}}).Hash()].values, 111)
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		a.now = func() time.Time { return now.Add(2 * time.Hour) }
+		a.refreshAll(ctx)
+		require.Empty(t, a.queries)
+	})
+}
+
+func TestQueryAccelerator_Store(t *testing.T) {
+	now := testTime.Truncate(time.Minute)
+	store := newTestAcceleratorStore(t)
+	a1, h1, executed1 := newTestAccelerator(t, now, store)
+	a2, h2, executed2 := newTestAccelerator(t, now, store)
+	ctx := user.InjectOrgID(context.Background(), "1")
+	req := acceleratedQueryRequest(now.Add(-time.Hour), now.Add(-30*time.Minute), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := h1.Do(ctx, req)
+		require.NoError(t, err)
+	}
+	a1.refreshAll(ctx)
+	require.Len(t, executed1(), 3)
+
+	// The query accelerated and computed by a query frontend is answered from
+	// its results by the others.
+	a2.refreshAll(ctx)
+	require.Empty(t, executed2())
+	resp, err := h2.Do(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, executed2())
+	require.Len(t, resp.(*LokiPromResponse).Response.Data.Result[0].Samples, 31)
+
+	// The query stops being accelerated by all the query frontends once idle.
+	later := now.Add(2 * time.Hour)
+	a1.now = func() time.Time { return later }
+	a1.refreshAll(ctx)
+	require.Empty(t, a1.queries)
+	a2.refreshAll(ctx)
+	require.Empty(t, a2.queries)
+}
+
+func TestAccelerateHandler(t *testing.T) {
+	now := testTime.Truncate(time.Minute)
+	_, h, executed := newTestAccelerator(t, now, newTestAcceleratorStore(t))
+	handler := NewAccelerateHandler(h)
+
+	do := func(method string) (int, AccelerateResponse) {
+		params := url.Values{
+			"query": []string{`sum by (app) (rate({app="foo"}[1m]))`},
+			"start": []string{strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10)},
+			"end":   []string{strconv.FormatInt(now.UnixNano(), 10)},
+		}
+		req := httptest.NewRequest(method, "/loki/api/v1/accelerate?"+params.Encode(), nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp AccelerateResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	code, _ := do(http.MethodGet)
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, resp := do(http.MethodPost)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Queries, 1)
+	require.Equal(t, "1", resp.Queries[0].Tenant)
+	require.Equal(t, `sum by (app)(rate({app="foo"}[1m]))`, resp.Queries[0].Query)
+	require.True(t, resp.Queries[0].Manual)
+	require.Nil(t, resp.Queries[0].Through)
+
+	code, _ = do(http.MethodGet)
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = do(http.MethodDelete)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do(http.MethodGet)
+	require.Equal(t, http.StatusNotFound, code)
+
+	// The query is never executed by the API.
+	require.Empty(t, executed())
+}
@@ -0,0 +1,378 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	logqllog "github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/sharding"
+	"github.com/grafana/loki/v3/pkg/storage/types"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	"github.com/grafana/loki/v3/pkg/util/spanlogger"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+	valid "github.com/grafana/loki/v3/pkg/validation"
+)
+
+// Response headers holding the estimated cost of a query.
+const (
+	QueryCostBytesHeader      = "X-Loki-Query-Cost-Bytes"
+	QueryCostCPUSecondsHeader = "X-Loki-Query-Cost-Cpu-Seconds"
+	QueryCostShardsHeader     = "X-Loki-Query-Cost-Shards"
+	// QueryCostBudgetUsedHeader is the ratio of the budget of the tenant used
+	// over the budget window, including the query.
+	QueryCostBudgetUsedHeader = "X-Loki-Query-Cost-Budget-Used"
+)
+
+var queryCostHeaders = []string{QueryCostBytesHeader, QueryCostCPUSecondsHeader, QueryCostShardsHeader, QueryCostBudgetUsedHeader}
+
+const (
+	// queryCostBytesPerCPUSecond is the number of bytes a querier core scans
+	// per second when the query has no pipeline.
+	queryCostBytesPerCPUSecond = 1 << 30
+
+	// queryCostOverBudgetActor is the sub-queue over budget queries are
+	// scheduled in when they are deprioritized.
+	queryCostOverBudgetActor = "over-cost-budget"
+
+	queryCostBudgetErrTmpl = "query cost budget exceeded (spent: %.1f CPU seconds, query: %.1f CPU seconds, budget: %.1f CPU seconds over %s); reduce the time range of the queries or the number of queries run"
+
+	queryCostBuckets = 60
+)
+
+// setQueryCostHeaders copies the estimated cost of the query from the
+// response to the headers of the HTTP response.
+func setQueryCostHeaders(h http.Header, res queryrangebase.Response) {
+	for _, header := range res.GetHeaders() {
+		if slices.Contains(queryCostHeaders, header.Name) {
+			h[header.Name] = header.Values
+		}
+	}
+}
+
+// QueryCost is the estimated cost of executing a query.
+type QueryCost struct {
+	// Bytes is the number of bytes the query reads, from the index stats of
+	// its selectors.
+	Bytes uint64
+	// Shards is the number of shards the query is split into.
+	Shards int
+	// CPUSeconds is the CPU time needed to process the bytes with the
+	// pipelines of the query.
+	CPUSeconds float64
+}
+
+// EstimateQueryCost estimates the cost of a query reading the given bytes.
+func EstimateQueryCost(expr syntax.Expr, bytes uint64, maxBytesPerShard int) QueryCost {
+	shards := sharding.GuessShardFactor(bytes, uint64(maxBytesPerShard), 0)
+	if shards == 0 {
+		shards = 1
+	}
+	return QueryCost{
+		Bytes:      bytes,
+		Shards:     shards,
+		CPUSeconds: float64(bytes) / queryCostBytesPerCPUSecond * pipelineComplexity(expr),
+	}
+}
+
+// pipelineComplexity returns how much more expensive processing the lines
+// read by the query is than scanning them, according to the most expensive
+// of its pipelines.
+func pipelineComplexity(expr syntax.Expr) float64 {
+	complexity := map[*syntax.PipelineExpr]float64{}
+	var current *syntax.PipelineExpr
+	expr.Walk(func(e syntax.Expr) {
+		var cost float64
+		switch e := e.(type) {
+		case *syntax.PipelineExpr:
+			current = e
+			return
+		case *syntax.LineFilterExpr:
+			switch e.Ty {
+			case logqllog.LineMatchRegexp, logqllog.LineMatchNotRegexp:
+				cost = 1
+			case logqllog.LineMatchPattern, logqllog.LineMatchNotPattern:
+				cost = 0.3
+			default:
+				cost = 0.1
+			}
+			if e.Or != nil {
+				cost *= 2
+			}
+		case *syntax.LogfmtParserExpr, *syntax.LogfmtExpressionParser:
+			cost = 1.5
+		case *syntax.JSONExpressionParser:
+			cost = 2
+		case *syntax.LabelParserExpr:
+			switch e.Op {
+			case syntax.OpParserTypeJSON:
+				cost = 2
+			case syntax.OpParserTypeRegexp:
+				cost = 2.5
+			case syntax.OpParserTypePattern:
+				cost = 0.5
+			default:
+				cost = 1.5
+			}
+		case *syntax.LabelFilterExpr:
+			cost = 0.1
+		case *syntax.LineFmtExpr, *syntax.LabelFmtExpr:
+			cost = 1
+		}
+		if current != nil {
+			complexity[current] += cost
+		}
+	})
+
+	highest := 0.0
+	for _, c := range complexity {
+		highest = max(highest, c)
+	}
+	return 1 + highest
+}
+
+// QueryCostBudgets tracks the estimated cost of the queries of each tenant
+// over a rolling window.
+type QueryCostBudgets struct {
+	mtx     sync.Mutex
+	tenants map[string]*queryCostWindow
+	now     func() time.Time
+}
+
+// NewQueryCostBudgets returns an empty tracker of the query cost budgets.
+func NewQueryCostBudgets() *QueryCostBudgets {
+	return &QueryCostBudgets{
+		tenants: map[string]*queryCostWindow{},
+		now:     time.Now,
+	}
+}
+
+type queryCostWindow struct {
+	width   time.Duration
+	buckets [queryCostBuckets]queryCostBucket
+}
+
+type queryCostBucket struct {
+	start time.Time
+	cost  float64
+}
+
+// window returns the buckets of the tenant, reset when the window changes.
+func (b *QueryCostBudgets) window(tenant string, window time.Duration) *queryCostWindow {
+	width := window / queryCostBuckets
+	w, ok := b.tenants[tenant]
+	if !ok || w.width != width {
+		w = &queryCostWindow{width: width}
+		b.tenants[tenant] = w
+	}
+	return w
+}
+
+// Spent returns the cost of the queries of the tenant over the window.
+func (b *QueryCostBudgets) Spent(tenant string, window time.Duration) float64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	w := b.window(tenant, window)
+	var spent float64
+	for _, bucket := range w.buckets {
+		if now.Sub(bucket.start) < window {
+			spent += bucket.cost
+		}
+	}
+	return spent
+}
+
+// Add charges the tenant with the cost of a query.
+func (b *QueryCostBudgets) Add(tenant string, window time.Duration, cost float64) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	w := b.window(tenant, window)
+	start := now.Truncate(w.width)
+	bucket := &w.buckets[int(start.UnixNano()/int64(w.width))%queryCostBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = queryCostBucket{start: start}
+	}
+	bucket.cost += cost
+}
+
+// QueryCostMetrics are the metrics of the query cost middleware.
+type QueryCostMetrics struct {
+	estimatedCPUSeconds prometheus.Histogram
+	overBudget          *prometheus.CounterVec
+}
+
+func NewQueryCostMetrics(registerer prometheus.Registerer, metricsNamespace string) *QueryCostMetrics {
+	return &QueryCostMetrics{
+		estimatedCPUSeconds: promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_cost_estimated_cpu_seconds",
+			Help:      "Estimated CPU seconds of the queries of the tenants with a query cost budget.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}),
+		overBudget: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_cost_over_budget_total",
+			Help:      "Number of queries of tenants above their query cost budget, by action taken.",
+		}, []string{"tenant", "action"}),
+	}
+}
+
+type queryCostLimiter struct {
+	logger            log.Logger
+	next              queryrangebase.Handler
+	statsHandler      queryrangebase.Handler
+	cfg               []config.PeriodConfig
+	maxLookBackPeriod time.Duration
+	limits            Limits
+	budgets           *QueryCostBudgets
+	metrics           *QueryCostMetrics
+}
+
+// NewQueryCostMiddleware creates a new Middleware estimating the cost of the
+// queries of the tenants with a query cost budget, and rejecting or
+// deprioritizing their queries once the budget is spent.
+func NewQueryCostMiddleware(
+	cfg []config.PeriodConfig,
+	engineOpts logql.EngineOpts,
+	logger log.Logger,
+	limits Limits,
+	budgets *QueryCostBudgets,
+	metrics *QueryCostMetrics,
+	statsHandler queryrangebase.Handler,
+) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &queryCostLimiter{
+			logger:            logger,
+			next:              next,
+			statsHandler:      statsHandler,
+			cfg:               cfg,
+			maxLookBackPeriod: engineOpts.MaxLookBackPeriod,
+			limits:            limits,
+			budgets:           budgets,
+			metrics:           metrics,
+		}
+	})
+}
+
+func (q *queryCostLimiter) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "query_cost_limits")
+	defer span.Finish()
+	log := spanlogger.FromContext(ctx)
+	defer log.Finish()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+
+	var budgeted []string
+	for _, id := range tenantIDs {
+		if q.limits.QueryCostBudget(id) > 0 {
+			budgeted = append(budgeted, id)
+		}
+	}
+	if len(budgeted) == 0 {
+		return q.next.Do(ctx, r)
+	}
+
+	// The index stats are only available with TSDB.
+	schemaCfg, err := (&querySizeLimiter{cfg: q.cfg}).getSchemaCfg(r)
+	if err != nil {
+		level.Error(log).Log("msg", "failed to get schema config, not estimating query cost", "err", err)
+		return q.next.Do(ctx, r)
+	}
+	if schemaCfg.IndexType != types.TSDBType {
+		return q.next.Do(ctx, r)
+	}
+
+	cost, err := q.estimate(ctx, r, tenantIDs)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "Failed to estimate query cost: %s", err.Error())
+	}
+	q.metrics.estimatedCPUSeconds.Observe(cost.CPUSeconds)
+
+	// Queries which are only explained aren't charged.
+	charge := !isExplainDryRun(ctx)
+
+	var used float64
+	for _, id := range budgeted {
+		budget, window := q.limits.QueryCostBudget(id), q.limits.QueryCostBudgetWindow(id)
+		spent := q.budgets.Spent(id, window)
+		used = max(used, (spent+cost.CPUSeconds)/budget)
+		if !charge || spent+cost.CPUSeconds <= budget {
+			continue
+		}
+
+		action := q.limits.QueryCostBudgetAction(id)
+		if action == valid.QueryCostBudgetActionReject {
+			q.metrics.overBudget.WithLabelValues(id, action).Inc()
+			level.Warn(log).Log("msg", "query exceeds cost budget", "status", "rejected", "tenant", id, "spent", spent, "cost", cost.CPUSeconds, "budget", budget)
+			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, queryCostBudgetErrTmpl, spent, cost.CPUSeconds, budget, model.Duration(window))
+		}
+		if actor := httpreq.ExtractActorPath(ctx); len(actor) == 0 || actor[0] != queryCostOverBudgetActor {
+			q.metrics.overBudget.WithLabelValues(id, valid.QueryCostBudgetActionDeprioritize).Inc()
+			level.Debug(log).Log("msg", "query exceeds cost budget", "status", "deprioritized", "tenant", id, "spent", spent, "cost", cost.CPUSeconds, "budget", budget)
+			ctx = httpreq.InjectActorPath(ctx, strings.Join(append([]string{queryCostOverBudgetActor}, actor...), httpreq.LokiActorPathDelimiter))
+		}
+	}
+	if charge {
+		for _, id := range budgeted {
+			q.budgets.Add(id, q.limits.QueryCostBudgetWindow(id), cost.CPUSeconds)
+		}
+	}
+
+	resp, err := q.next.Do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := resp.(interface{ SetHeader(name, value string) }); ok {
+		h.SetHeader(QueryCostBytesHeader, strconv.FormatUint(cost.Bytes, 10))
+		h.SetHeader(QueryCostCPUSecondsHeader, strconv.FormatFloat(cost.CPUSeconds, 'f', 3, 64))
+		h.SetHeader(QueryCostShardsHeader, strconv.Itoa(cost.Shards))
+		h.SetHeader(QueryCostBudgetUsedHeader, strconv.FormatFloat(used, 'f', 3, 64))
+	}
+	return resp, nil
+}
+
+func (q *queryCostLimiter) estimate(ctx context.Context, r queryrangebase.Request, tenantIDs []string) (QueryCost, error) {
+	expr, err := syntax.ParseExpr(r.GetQuery())
+	if err != nil {
+		return QueryCost{}, err
+	}
+	matcherGroups, err := syntax.MatcherGroups(expr)
+	if err != nil {
+		return QueryCost{}, err
+	}
+
+	const maxConcurrentIndexReq = 10
+	matcherStats, err := getStatsForMatchers(ctx, q.logger, q.statsHandler, model.Time(r.GetStart().UnixMilli()), model.Time(r.GetEnd().UnixMilli()), matcherGroups, maxConcurrentIndexReq, q.maxLookBackPeriod)
+	if err != nil {
+		return QueryCost{}, err
+	}
+	combined := stats.MergeStats(matcherStats...)
+
+	maxBytesPerShard := validation.SmallestPositiveIntPerTenant(tenantIDs, q.limits.TSDBMaxBytesPerShard)
+	return EstimateQueryCost(expr, combined.Bytes, maxBytesPerShard), nil
+}
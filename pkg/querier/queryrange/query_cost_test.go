@@ -0,0 +1,147 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	valid "github.com/grafana/loki/v3/pkg/validation"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	const gb = 1 << 30
+	for _, tc := range []struct {
+		query      string
+		cpuSeconds float64
+	}{
+		{`{app="foo"}`, 1},
+		{`{app="foo"} |= "bar"`, 1.1},
+		{`{app="foo"} |~ "ba.+"`, 2},
+		{`{app="foo"} |= "bar" or "baz"`, 1.2},
+		{`{app="foo"} | json | level="error"`, 3.1},
+		{`{app="foo"} | regexp "(?P<level>\\w+)" | line_format "{{.level}}"`, 4.5},
+		{`sum by (level) (count_over_time({app="foo"} | logfmt [5m]))`, 2.5},
+		// The most expensive pipeline is used.
+		{`count_over_time({app="foo"} | json [5m]) / count_over_time({app="foo"} |= "bar" [5m])`, 3},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			cost := EstimateQueryCost(syntax.MustParseExpr(tc.query), gb, valid.DefaultTSDBMaxBytesPerShard)
+			require.Equal(t, uint64(gb), cost.Bytes)
+			require.InDelta(t, tc.cpuSeconds, cost.CPUSeconds, 1e-9)
+			require.Equal(t, 2, cost.Shards)
+		})
+	}
+
+	require.Equal(t, 1, EstimateQueryCost(syntax.MustParseExpr(`{app="foo"}`), 0, valid.DefaultTSDBMaxBytesPerShard).Shards)
+}
+
+func TestQueryCostBudgets(t *testing.T) {
+	now := time.Unix(0, 0)
+	budgets := NewQueryCostBudgets()
+	budgets.now = func() time.Time { return now }
+
+	budgets.Add("a", time.Hour, 10)
+	now = now.Add(30 * time.Minute)
+	budgets.Add("a", time.Hour, 5)
+	budgets.Add("b", time.Hour, 1)
+	require.Equal(t, 15.0, budgets.Spent("a", time.Hour))
+	require.Equal(t, 1.0, budgets.Spent("b", time.Hour))
+
+	// The cost of the first query leaves the window.
+	now = now.Add(45 * time.Minute)
+	require.Equal(t, 5.0, budgets.Spent("a", time.Hour))
+	budgets.Add("a", time.Hour, 2)
+	require.Equal(t, 7.0, budgets.Spent("a", time.Hour))
+
+	now = now.Add(2 * time.Hour)
+	require.Equal(t, 0.0, budgets.Spent("a", time.Hour))
+}
+
+func TestQueryCostMiddleware(t *testing.T) {
+	const gb = 1 << 30
+	query := `sum(count_over_time({app="foo"} |= "bar" [1m]))`
+	req := &LokiRequest{
+		Query:   query,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+		Step:    60000,
+		Path:    "/loki/api/v1/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(query),
+		},
+	}
+	ctx := user.InjectOrgID(context.Background(), "1")
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 10 * gb})
+	metrics := NewMetrics(nil, "loki")
+
+	var actors [][]string
+	next := base.HandlerFunc(func(ctx context.Context, r base.Request) (base.Response, error) {
+		actors = append(actors, httpreq.ExtractActorPath(ctx))
+		return NewEmptyResponse(r)
+	})
+	newHandler := func(limits fakeLimits) base.Handler {
+		return NewQueryCostMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, NewQueryCostBudgets(), metrics.QueryCostMetrics, statsHandler).Wrap(next)
+	}
+
+	t.Run("no budget", func(t *testing.T) {
+		resp, err := newHandler(fakeLimits{}).Do(ctx, req)
+		require.NoError(t, err)
+		require.Empty(t, resp.GetHeaders())
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		h := newHandler(fakeLimits{queryCostBudget: 25, queryCostBudgetAction: valid.QueryCostBudgetActionReject})
+
+		resp, err := h.Do(ctx, req)
+		require.NoError(t, err)
+		encoded, err := DefaultCodec.EncodeResponse(ctx, &http.Request{Header: http.Header{}}, resp)
+		require.NoError(t, err)
+		require.Equal(t, "10737418240", encoded.Header.Get(QueryCostBytesHeader))
+		require.Equal(t, "11.000", encoded.Header.Get(QueryCostCPUSecondsHeader))
+		require.Equal(t, "32", encoded.Header.Get(QueryCostShardsHeader))
+		require.Equal(t, "0.440", encoded.Header.Get(QueryCostBudgetUsedHeader))
+
+		_, err = h.Do(ctx, req)
+		require.NoError(t, err)
+
+		_, err = h.Do(ctx, req)
+		require.Error(t, err)
+		res, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, http.StatusTooManyRequests, int(res.Code))
+		require.Contains(t, string(res.Body), "query cost budget exceeded")
+	})
+
+	t.Run("deprioritize", func(t *testing.T) {
+		actors = nil
+		h := newHandler(fakeLimits{queryCostBudget: 15, queryCostBudgetAction: valid.QueryCostBudgetActionDeprioritize})
+		for i := 0; i < 2; i++ {
+			_, err := h.Do(httpreq.InjectActorPath(ctx, "dashboard"), req)
+			require.NoError(t, err)
+		}
+		require.Equal(t, [][]string{{"dashboard"}, {queryCostOverBudgetActor, "dashboard"}}, actors)
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		h := newHandler(fakeLimits{queryCostBudget: 15, queryCostBudgetAction: valid.QueryCostBudgetActionReject})
+		// Explained queries aren't charged.
+		explainCtx := withExplain(ctx, &ExplainNode{}, false)
+		for i := 0; i < 3; i++ {
+			_, err := h.Do(explainCtx, req)
+			require.NoError(t, err)
+		}
+		_, err := h.Do(ctx, req)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,703 @@
+package queryrange
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+const (
+	queryJobStateObject   = "job.json"
+	queryJobResultsPrefix = "results/"
+	queryJobsScanInterval = time.Minute
+
+	// queryJobCancelObject marks a cancelled job. It is never written by the
+	// query frontend executing the job, so a cancellation is never lost.
+	queryJobCancelObject = "cancelled"
+)
+
+// QueryJobsConfig configures the asynchronous query jobs of the query frontend.
+type QueryJobsConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Store             string        `yaml:"store"`
+	StoreKeyPrefix    string        `yaml:"store_key_prefix"`
+	SplitInterval     time.Duration `yaml:"split_interval"`
+	MaxConcurrentJobs int           `yaml:"max_concurrent_jobs"`
+	AbandonTimeout    time.Duration `yaml:"abandon_timeout"`
+	Retention         time.Duration `yaml:"retention"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *QueryJobsConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "querier.query-jobs.enabled", false, "Enable the asynchronous query jobs API of the query frontend. Query jobs are executed in splits whose progress and results are stored in the store, so they survive restarts of the query frontend.")
+	f.StringVar(&cfg.Store, "querier.query-jobs.store", "", "Store used for the state and the results of the query jobs, e.g. s3 or filesystem. Required when query jobs are enabled.")
+	f.StringVar(&cfg.StoreKeyPrefix, "querier.query-jobs.store-key-prefix", "query-jobs/", "Path prefix of the query jobs in the store.")
+	f.DurationVar(&cfg.SplitInterval, "querier.query-jobs.split-interval", time.Hour, "Length of the splits the time range of a query job is executed in. The progress of a job is checkpointed after each split, and each split is subject to the query timeout.")
+	f.IntVar(&cfg.MaxConcurrentJobs, "querier.query-jobs.max-concurrent-jobs", 2, "Maximum number of query jobs executed concurrently by each query frontend.")
+	f.DurationVar(&cfg.AbandonTimeout, "querier.query-jobs.abandon-timeout", 15*time.Minute, "Unfinished query jobs whose progress hasn't been checkpointed for this long, e.g. because their query frontend stopped, are resumed by any query frontend.")
+	f.DurationVar(&cfg.Retention, "querier.query-jobs.retention", 7*24*time.Hour, "How long finished query jobs and their results are kept.")
+}
+
+// Validate validates the config.
+func (cfg *QueryJobsConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Store == "" {
+		return errors.New("querier.query-jobs.store must be set when query jobs are enabled")
+	}
+	if err := config.ValidatePathPrefix(cfg.StoreKeyPrefix); err != nil {
+		return fmt.Errorf("validate query jobs store path prefix: %w", err)
+	}
+	if cfg.SplitInterval <= 0 {
+		return errors.New("query jobs split interval must be greater than 0")
+	}
+	if cfg.MaxConcurrentJobs <= 0 {
+		return errors.New("query jobs max concurrent jobs must be greater than 0")
+	}
+	return nil
+}
+
+type queryJobsMetrics struct {
+	jobs    *prometheus.CounterVec
+	running prometheus.Gauge
+	splits  prometheus.Counter
+}
+
+func newQueryJobsMetrics(registerer prometheus.Registerer, metricsNamespace string) *queryJobsMetrics {
+	return &queryJobsMetrics{
+		jobs: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_jobs_total",
+			Help:      "Number of query jobs finished by the query frontend, by final state.",
+		}, []string{"state"}),
+		running: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_jobs_running",
+			Help:      "Number of query jobs being executed by the query frontend.",
+		}),
+		splits: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_job_splits_total",
+			Help:      "Number of splits of query jobs executed by the query frontend.",
+		}),
+	}
+}
+
+// queryJob is the state of a query job persisted in the store.
+type queryJob struct {
+	loghttp.QueryJob
+	Tenant string `json:"tenant"`
+	// Owner is the query frontend executing the job.
+	Owner         string        `json:"owner,omitempty"`
+	SplitInterval time.Duration `json:"split_interval"`
+	Metric        bool          `json:"metric"`
+}
+
+type queryJobKey struct {
+	tenant, id string
+}
+
+type queryJobSplit struct {
+	start, end time.Time
+}
+
+// splits returns the time ranges the job is executed in, in order of
+// execution. The splits of metric queries hold whole steps, and those of log
+// queries are executed in the direction of the query.
+func (j *queryJob) splits() []queryJobSplit {
+	var splits []queryJobSplit
+	if j.Metric && j.Step > 0 {
+		length := ((j.SplitInterval + j.Step - 1) / j.Step) * j.Step
+		for start := j.Start; !start.After(j.End); start = start.Add(length) {
+			end := start.Add(length - j.Step)
+			if end.After(j.End) {
+				end = j.End
+			}
+			splits = append(splits, queryJobSplit{start, end})
+		}
+		return splits
+	}
+
+	for start := j.Start; start.Before(j.End); start = start.Add(j.SplitInterval) {
+		end := start.Add(j.SplitInterval)
+		if end.After(j.End) {
+			end = j.End
+		}
+		splits = append(splits, queryJobSplit{start, end})
+	}
+	if j.Direction == logproto.BACKWARD.String() {
+		for i, k := 0, len(splits)-1; i < k; i, k = i+1, k-1 {
+			splits[i], splits[k] = splits[k], splits[i]
+		}
+	}
+	return splits
+}
+
+// QueryJobs executes asynchronous query jobs through the query frontend. The
+// time range of a job is executed in splits, and the state of the job is
+// checkpointed in the store with the results of each split, so that jobs are
+// resumed where they left off when their query frontend is restarted.
+type QueryJobs struct {
+	services.Service
+
+	cfg     QueryJobsConfig
+	client  client.ObjectClient
+	next    queryrangebase.Handler
+	owner   string
+	logger  log.Logger
+	metrics *queryJobsMetrics
+	now     func() time.Time
+
+	mtx sync.Mutex
+	// pending are the jobs to execute, and local the jobs pending or being
+	// executed by this query frontend.
+	pending []queryJobKey
+	local   map[queryJobKey]context.CancelFunc
+	wake    chan struct{}
+}
+
+// NewQueryJobs returns the query jobs executed by the handler and stored with
+// the client.
+func NewQueryJobs(cfg QueryJobsConfig, objectClient client.ObjectClient, next queryrangebase.Handler, logger log.Logger, registerer prometheus.Registerer, metricsNamespace string) *QueryJobs {
+	owner, err := os.Hostname()
+	if err != nil {
+		owner = ulid.MustNew(ulid.Now(), rand.Reader).String()
+	}
+
+	q := &QueryJobs{
+		cfg:     cfg,
+		client:  objectClient,
+		next:    next,
+		owner:   owner,
+		logger:  log.With(logger, "component", "query-jobs"),
+		metrics: newQueryJobsMetrics(registerer, metricsNamespace),
+		now:     time.Now,
+		local:   map[queryJobKey]context.CancelFunc{},
+		wake:    make(chan struct{}, 1),
+	}
+	q.Service = services.NewBasicService(nil, q.running, q.stopping)
+	return q
+}
+
+func (q *QueryJobs) running(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.MaxConcurrentJobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.worker(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(queryJobsScanInterval)
+	defer ticker.Stop()
+	for {
+		q.scan(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		}
+	}
+}
+
+func (q *QueryJobs) stopping(_ error) error {
+	q.client.Stop()
+	return nil
+}
+
+func (q *QueryJobs) worker(ctx context.Context) {
+	for {
+		key, ok := q.dequeue()
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		q.run(ctx, key)
+	}
+}
+
+func (q *QueryJobs) enqueue(key queryJobKey) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if _, ok := q.local[key]; ok {
+		return
+	}
+	q.local[key] = nil
+	q.pending = append(q.pending, key)
+	q.signal()
+}
+
+func (q *QueryJobs) dequeue() (queryJobKey, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.pending) == 0 {
+		return queryJobKey{}, false
+	}
+	key := q.pending[0]
+	q.pending = q.pending[1:]
+	if len(q.pending) > 0 {
+		q.signal()
+	}
+	return key, true
+}
+
+func (q *QueryJobs) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scan resumes the unfinished jobs of this query frontend and the abandoned
+// jobs of other query frontends, and deletes the expired jobs.
+func (q *QueryJobs) scan(ctx context.Context) {
+	_, tenants, err := q.client.List(ctx, q.cfg.StoreKeyPrefix, "/")
+	if err != nil {
+		level.Warn(q.logger).Log("msg", "failed to list query jobs", "err", err)
+		return
+	}
+	for _, tenant := range tenants {
+		tenantID := strings.TrimSuffix(strings.TrimPrefix(string(tenant), q.cfg.StoreKeyPrefix), "/")
+		jobs, err := q.list(ctx, tenantID)
+		if err != nil {
+			level.Warn(q.logger).Log("msg", "failed to list query jobs", "tenant", tenantID, "err", err)
+			continue
+		}
+		for _, job := range jobs {
+			key := queryJobKey{tenant: tenantID, id: job.ID}
+			switch {
+			case job.State.Done() && q.now().Sub(job.UpdatedAt) > q.cfg.Retention:
+				if err := q.delete(ctx, key); err != nil {
+					level.Warn(q.logger).Log("msg", "failed to delete expired query job", "tenant", tenantID, "id", job.ID, "err", err)
+				}
+			case !job.State.Done() && q.claimable(job):
+				q.enqueue(key)
+			}
+		}
+	}
+}
+
+// claimable returns whether the job can be executed by this query frontend.
+func (q *QueryJobs) claimable(job *queryJob) bool {
+	return job.Owner == "" || job.Owner == q.owner || q.now().Sub(job.UpdatedAt) > q.cfg.AbandonTimeout
+}
+
+// run executes the remaining splits of the job.
+func (q *QueryJobs) run(ctx context.Context, key queryJobKey) {
+	ctx, cancel := context.WithCancel(ctx)
+	q.mtx.Lock()
+	q.local[key] = cancel
+	q.mtx.Unlock()
+	defer func() {
+		cancel()
+		q.mtx.Lock()
+		delete(q.local, key)
+		q.mtx.Unlock()
+	}()
+
+	logger := log.With(q.logger, "tenant", key.tenant, "id", key.id)
+	job, err := q.load(ctx, key)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to load query job", "err", err)
+		return
+	}
+	if job.State.Done() || !q.claimable(job) {
+		return
+	}
+
+	q.metrics.running.Inc()
+	defer q.metrics.running.Dec()
+
+	job.State = loghttp.QueryJobRunning
+	job.Owner = q.owner
+	if err := q.save(ctx, job); err != nil {
+		level.Warn(logger).Log("msg", "failed to save query job", "err", err)
+		return
+	}
+
+	expr, err := syntax.ParseExpr(job.Query)
+	if err != nil {
+		q.finish(ctx, job, err)
+		return
+	}
+	splits := job.splits()
+	for i := job.CompletedSplits; i < len(splits); i++ {
+		if !job.Metric && job.Limit > 0 && job.Entries >= int64(job.Limit) {
+			break
+		}
+
+		resp, err := q.next.Do(user.InjectOrgID(ctx, key.tenant), q.splitRequest(job, expr, splits[i]))
+		if ctx.Err() != nil {
+			// The job was cancelled, or is resumed once the query frontend restarts.
+			return
+		}
+		if err != nil {
+			q.finish(ctx, job, err)
+			return
+		}
+		q.metrics.splits.Inc()
+
+		entries, empty := queryJobResponseSize(resp)
+		if !empty {
+			var buf bytes.Buffer
+			if err := encodeResponseJSONTo(loghttp.VersionV1, resp, &buf, nil); err != nil {
+				q.finish(ctx, job, err)
+				return
+			}
+			if err := q.client.PutObject(ctx, q.pageKey(key, job.Pages), bytes.NewReader(buf.Bytes())); err != nil {
+				level.Warn(logger).Log("msg", "failed to store query job results", "err", err)
+				return
+			}
+			job.Pages++
+		}
+		job.CompletedSplits = i + 1
+		job.Entries += entries
+
+		if !q.owned(ctx, job) {
+			return
+		}
+		if err := q.save(ctx, job); err != nil {
+			level.Warn(logger).Log("msg", "failed to save query job", "err", err)
+			return
+		}
+	}
+	q.finish(ctx, job, nil)
+}
+
+func (q *QueryJobs) splitRequest(job *queryJob, expr syntax.Expr, split queryJobSplit) *LokiRequest {
+	limit := job.Limit
+	if !job.Metric && limit > 0 {
+		limit -= uint32(job.Entries)
+	}
+	return &LokiRequest{
+		Query:     job.Query,
+		Limit:     limit,
+		Step:      job.Step.Milliseconds(),
+		Interval:  job.Interval.Milliseconds(),
+		StartTs:   split.start,
+		EndTs:     split.end,
+		Direction: logproto.Direction(logproto.Direction_value[job.Direction]),
+		Path:      rangeQueryPath,
+		Plan:      &plan.QueryPlan{AST: expr},
+	}
+}
+
+// queryJobResponseSize returns the number of entries of the response, and
+// whether it has no result.
+func queryJobResponseSize(resp queryrangebase.Response) (int64, bool) {
+	switch r := resp.(type) {
+	case *LokiResponse:
+		return r.Count(), r.Count() == 0
+	case *LokiPromResponse:
+		return 0, r.Response == nil || len(r.Response.Data.Result) == 0
+	}
+	return 0, false
+}
+
+// finish saves the final state of the job.
+func (q *QueryJobs) finish(ctx context.Context, job *queryJob, err error) {
+	if !q.owned(ctx, job) {
+		return
+	}
+	job.State = loghttp.QueryJobSucceeded
+	if err != nil {
+		job.State = loghttp.QueryJobFailed
+		_, cerr := serverutil.ClientHTTPStatusAndError(err)
+		job.Error = cerr.Error()
+	}
+	if err := q.save(ctx, job); err != nil {
+		level.Warn(q.logger).Log("msg", "failed to save query job", "tenant", job.Tenant, "id", job.ID, "err", err)
+		return
+	}
+	q.metrics.jobs.WithLabelValues(string(job.State)).Inc()
+}
+
+func (q *QueryJobs) jobPrefix(key queryJobKey) string {
+	return q.cfg.StoreKeyPrefix + key.tenant + "/" + key.id + "/"
+}
+
+func (q *QueryJobs) pageKey(key queryJobKey, page int) string {
+	return fmt.Sprintf("%s%s%06d.json", q.jobPrefix(key), queryJobResultsPrefix, page)
+}
+
+func (q *QueryJobs) save(ctx context.Context, job *queryJob) error {
+	job.UpdatedAt = q.now()
+	buf, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.client.PutObject(ctx, q.jobPrefix(queryJobKey{job.Tenant, job.ID})+queryJobStateObject, bytes.NewReader(buf))
+}
+
+// owned returns whether the job is still executed by this query frontend, and
+// neither cancelled nor claimed by another query frontend.
+func (q *QueryJobs) owned(ctx context.Context, job *queryJob) bool {
+	stored, err := q.load(ctx, queryJobKey{tenant: job.Tenant, id: job.ID})
+	if err != nil {
+		level.Warn(q.logger).Log("msg", "failed to load query job", "tenant", job.Tenant, "id", job.ID, "err", err)
+		return false
+	}
+	return !stored.State.Done() && stored.Owner == q.owner
+}
+
+// load loads the job. A job marked as cancelled is cancelled whatever its
+// stored state.
+func (q *QueryJobs) load(ctx context.Context, key queryJobKey) (*queryJob, error) {
+	r, _, err := q.client.GetObject(ctx, q.jobPrefix(key)+queryJobStateObject)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var job queryJob
+	if err := json.NewDecoder(r).Decode(&job); err != nil {
+		return nil, err
+	}
+
+	cancelled, err := q.client.ObjectExists(ctx, q.jobPrefix(key)+queryJobCancelObject)
+	if err != nil && !q.client.IsObjectNotFoundErr(err) {
+		return nil, err
+	}
+	if cancelled {
+		job.State = loghttp.QueryJobCancelled
+	}
+	return &job, nil
+}
+
+// list returns the jobs of the tenant, oldest first.
+func (q *QueryJobs) list(ctx context.Context, tenantID string) ([]*queryJob, error) {
+	_, prefixes, err := q.client.List(ctx, q.cfg.StoreKeyPrefix+tenantID+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*queryJob, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		id := strings.TrimSuffix(strings.TrimPrefix(string(prefix), q.cfg.StoreKeyPrefix+tenantID+"/"), "/")
+		job, err := q.load(ctx, queryJobKey{tenant: tenantID, id: id})
+		if err != nil {
+			if q.client.IsObjectNotFoundErr(err) {
+				continue
+			}
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// delete deletes the job and its results.
+func (q *QueryJobs) delete(ctx context.Context, key queryJobKey) error {
+	objects, _, err := q.client.List(ctx, q.jobPrefix(key), "")
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := q.client.DeleteObject(ctx, object.Key); err != nil && !q.client.IsObjectNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestedJob loads the job of the request.
+func (q *QueryJobs) requestedJob(r *http.Request) (*queryJob, error) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	id := mux.Vars(r)["id"]
+	if _, err := ulid.Parse(id); err != nil {
+		return nil, httpgrpc.Errorf(http.StatusNotFound, "query job %s not found", id)
+	}
+
+	job, err := q.load(r.Context(), queryJobKey{tenant: tenantID, id: id})
+	if err != nil {
+		if q.client.IsObjectNotFoundErr(err) {
+			return nil, httpgrpc.Errorf(http.StatusNotFound, "query job %s not found", id)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// CreateJobHandler creates a query job executing the range query of the request.
+func (q *QueryJobs) CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	req, err := decodeQueryRequest(ctx, r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	lokiReq, ok := req.(*LokiRequest)
+	if !ok {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "only range queries can be executed as query jobs"), w)
+		return
+	}
+
+	now := q.now()
+	_, metric := lokiReq.Plan.AST.(syntax.SampleExpr)
+	job := &queryJob{
+		QueryJob: loghttp.QueryJob{
+			ID:        ulid.MustNew(ulid.Timestamp(now), rand.Reader).String(),
+			Query:     lokiReq.Query,
+			Start:     lokiReq.StartTs,
+			End:       lokiReq.EndTs,
+			Step:      time.Duration(lokiReq.Step) * time.Millisecond,
+			Interval:  time.Duration(lokiReq.Interval) * time.Millisecond,
+			Limit:     lokiReq.Limit,
+			Direction: lokiReq.Direction.String(),
+			State:     loghttp.QueryJobQueued,
+			CreatedAt: now,
+		},
+		Tenant:        tenantID,
+		SplitInterval: q.cfg.SplitInterval,
+		Metric:        metric,
+	}
+	job.Splits = len(job.splits())
+
+	if err := q.save(ctx, job); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	q.enqueue(queryJobKey{tenant: tenantID, id: job.ID})
+
+	util.WriteJSONResponse(w, job.QueryJob)
+}
+
+// ListJobsHandler lists the query jobs of the tenant.
+func (q *QueryJobs) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	jobs, err := q.list(r.Context(), tenantID)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	resp := loghttp.QueryJobsResponse{Jobs: make([]loghttp.QueryJob, 0, len(jobs))}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, job.QueryJob)
+	}
+	util.WriteJSONResponse(w, resp)
+}
+
+// GetJobHandler returns the status of a query job.
+func (q *QueryJobs) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := q.requestedJob(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, job.QueryJob)
+}
+
+// CancelJobHandler cancels an unfinished query job. Its results are kept.
+func (q *QueryJobs) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := q.requestedJob(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	if !job.State.Done() {
+		key := queryJobKey{tenant: job.Tenant, id: job.ID}
+		if err := q.client.PutObject(r.Context(), q.jobPrefix(key)+queryJobCancelObject, bytes.NewReader(nil)); err != nil {
+			serverutil.WriteError(err, w)
+			return
+		}
+		job.State = loghttp.QueryJobCancelled
+		q.metrics.jobs.WithLabelValues(string(job.State)).Inc()
+
+		// A job executed by another query frontend stops at its next checkpoint.
+		q.mtx.Lock()
+		if cancel := q.local[key]; cancel != nil {
+			cancel()
+		}
+		q.mtx.Unlock()
+	}
+	util.WriteJSONResponse(w, job.QueryJob)
+}
+
+// GetJobResultsHandler returns a page of the results of a query job, in the
+// format of the query range API.
+func (q *QueryJobs) GetJobResultsHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := q.requestedJob(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	page := 0
+	if value := r.URL.Query().Get("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil {
+			serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid page %s", value), w)
+			return
+		}
+	}
+	if page < 0 || page >= job.Pages {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusNotFound, "page %d of query job %s not found, the job has %d pages", page, job.ID, job.Pages), w)
+		return
+	}
+
+	body, _, err := q.client.GetObject(r.Context(), q.pageKey(queryJobKey{tenant: job.Tenant, id: job.ID}, page))
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", JSONType)
+	_, _ = io.Copy(w, body)
+}
@@ -0,0 +1,327 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+)
+
+type queryJobSplitRequest struct {
+	start, end time.Time
+	limit      uint32
+}
+
+// newTestQueryJobs returns query jobs executed by a handler answering each
+// split with an entry at its start, and the splits the handler executed.
+func newTestQueryJobs(t *testing.T) (*QueryJobs, func() []queryJobSplitRequest) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	var (
+		mtx    sync.Mutex
+		splits []queryJobSplitRequest
+	)
+	next := base.HandlerFunc(func(ctx context.Context, r base.Request) (base.Response, error) {
+		tenantID, err := user.ExtractOrgID(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "tenant-a", tenantID)
+
+		req := r.(*LokiRequest)
+		mtx.Lock()
+		splits = append(splits, queryJobSplitRequest{req.StartTs, req.EndTs, req.Limit})
+		mtx.Unlock()
+
+		return &LokiResponse{
+			Status:    loghttp.QueryStatusSuccess,
+			Direction: req.Direction,
+			Limit:     req.Limit,
+			Version:   uint32(loghttp.VersionV1),
+			Data: LokiData{
+				ResultType: loghttp.ResultTypeStream,
+				Result: []logproto.Stream{{
+					Labels:  `{app="foo"}`,
+					Entries: []logproto.Entry{{Timestamp: req.StartTs, Line: req.StartTs.String()}},
+				}},
+			},
+		}, nil
+	})
+
+	q := NewQueryJobs(QueryJobsConfig{
+		Enabled:           true,
+		StoreKeyPrefix:    "query-jobs/",
+		SplitInterval:     time.Hour,
+		MaxConcurrentJobs: 1,
+		AbandonTimeout:    time.Hour,
+		Retention:         24 * time.Hour,
+	}, objectClient, next, log.NewNopLogger(), prometheus.NewRegistry(), "loki")
+	q.now = func() time.Time { return testTime }
+
+	return q, func() []queryJobSplitRequest {
+		mtx.Lock()
+		defer mtx.Unlock()
+		res := splits
+		splits = nil
+		return res
+	}
+}
+
+func createTestQueryJob(t *testing.T, q *QueryJobs, limit int) loghttp.QueryJob {
+	form := url.Values{
+		"query":     []string{`{app="foo"}`},
+		"start":     []string{strconv.FormatInt(testTime.Add(-3*time.Hour).UnixNano(), 10)},
+		"end":       []string{strconv.FormatInt(testTime.UnixNano(), 10)},
+		"limit":     []string{strconv.Itoa(limit)},
+		"direction": []string{"backward"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/query_jobs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(user.InjectOrgID(req.Context(), "tenant-a"))
+
+	w := httptest.NewRecorder()
+	q.CreateJobHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var job loghttp.QueryJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	return job
+}
+
+func doQueryJobRequest(q *QueryJobs, handler http.HandlerFunc, method, id, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/loki/api/v1/query_jobs/"+id+"?"+query, nil)
+	req = mux.SetURLVars(req.WithContext(user.InjectOrgID(req.Context(), "tenant-a")), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func getTestQueryJob(t *testing.T, q *QueryJobs, id string) loghttp.QueryJob {
+	w := doQueryJobRequest(q, q.GetJobHandler, http.MethodGet, id, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var job loghttp.QueryJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	return job
+}
+
+func TestQueryJobs(t *testing.T) {
+	q, executed := newTestQueryJobs(t)
+	ctx := context.Background()
+
+	job := createTestQueryJob(t, q, 2)
+	require.Equal(t, loghttp.QueryJobQueued, job.State)
+	require.Equal(t, 3, job.Splits)
+
+	key, ok := q.dequeue()
+	require.True(t, ok)
+	q.run(ctx, key)
+
+	// The splits are executed newest first until the limit is reached.
+	require.Equal(t, []queryJobSplitRequest{
+		{testTime.Add(-time.Hour), testTime, 2},
+		{testTime.Add(-2 * time.Hour), testTime.Add(-time.Hour), 1},
+	}, executed())
+
+	job = getTestQueryJob(t, q, job.ID)
+	require.Equal(t, loghttp.QueryJobSucceeded, job.State)
+	require.Equal(t, 2, job.CompletedSplits)
+	require.Equal(t, 2, job.Pages)
+	require.Equal(t, int64(2), job.Entries)
+
+	w := doQueryJobRequest(q, q.GetJobResultsHandler, http.MethodGet, job.ID, "page=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp loghttp.QueryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	streams := resp.Data.Result.(loghttp.Streams)
+	require.Len(t, streams, 1)
+	require.Equal(t, testTime.Add(-2*time.Hour).UnixNano(), streams[0].Entries[0].Timestamp.UnixNano())
+
+	w = doQueryJobRequest(q, q.GetJobResultsHandler, http.MethodGet, job.ID, "page=2")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// The jobs of a tenant are listed.
+	req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_jobs", nil)
+	w = httptest.NewRecorder()
+	q.ListJobsHandler(w, req.WithContext(user.InjectOrgID(req.Context(), "tenant-a")))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list loghttp.QueryJobsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Jobs, 1)
+	require.Equal(t, job.ID, list.Jobs[0].ID)
+
+	// Finished jobs are deleted once expired.
+	q.now = func() time.Time { return testTime.Add(48 * time.Hour) }
+	q.scan(ctx)
+	w = doQueryJobRequest(q, q.GetJobHandler, http.MethodGet, job.ID, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestQueryJobs_Resume(t *testing.T) {
+	q, executed := newTestQueryJobs(t)
+	ctx := context.Background()
+
+	created := createTestQueryJob(t, q, 100)
+	key, ok := q.dequeue()
+	require.True(t, ok)
+	q.mtx.Lock()
+	delete(q.local, key)
+	q.mtx.Unlock()
+
+	// The job was checkpointed after its first split by another query frontend.
+	job, err := q.load(ctx, key)
+	require.NoError(t, err)
+	job.State = loghttp.QueryJobRunning
+	job.Owner = "other"
+	job.CompletedSplits = 1
+	require.NoError(t, q.save(ctx, job))
+
+	q.scan(ctx)
+	_, ok = q.dequeue()
+	require.False(t, ok, "the job is not abandoned yet")
+
+	q.now = func() time.Time { return testTime.Add(2 * time.Hour) }
+	q.scan(ctx)
+	key, ok = q.dequeue()
+	require.True(t, ok)
+	q.run(ctx, key)
+
+	require.Equal(t, []queryJobSplitRequest{
+		{testTime.Add(-2 * time.Hour), testTime.Add(-time.Hour), 100},
+		{testTime.Add(-3 * time.Hour), testTime.Add(-2 * time.Hour), 99},
+	}, executed())
+
+	resumed := getTestQueryJob(t, q, created.ID)
+	require.Equal(t, loghttp.QueryJobSucceeded, resumed.State)
+	require.Equal(t, 3, resumed.CompletedSplits)
+}
+
+func TestQueryJobs_Cancel(t *testing.T) {
+	q, executed := newTestQueryJobs(t)
+
+	job := createTestQueryJob(t, q, 100)
+	w := doQueryJobRequest(q, q.CancelJobHandler, http.MethodDelete, job.ID, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	key, ok := q.dequeue()
+	require.True(t, ok)
+	q.run(context.Background(), key)
+	require.Empty(t, executed())
+	require.Equal(t, loghttp.QueryJobCancelled, getTestQueryJob(t, q, job.ID).State)
+
+	w = doQueryJobRequest(q, q.GetJobHandler, http.MethodGet, "unknown", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestQueryJobs_CancelWhileRunning(t *testing.T) {
+	q, executed := newTestQueryJobs(t)
+	job := createTestQueryJob(t, q, 100)
+	key := queryJobKey{tenant: "tenant-a", id: job.ID}
+
+	// The job is cancelled through another query frontend while its last
+	// split is executed.
+	next := q.next
+	q.next = base.HandlerFunc(func(ctx context.Context, r base.Request) (base.Response, error) {
+		if r.GetStart().Equal(testTime.Add(-3 * time.Hour)) {
+			require.NoError(t, q.client.PutObject(ctx, q.jobPrefix(key)+queryJobCancelObject, strings.NewReader("")))
+		}
+		return next.Do(ctx, r)
+	})
+
+	dequeued, ok := q.dequeue()
+	require.True(t, ok)
+	q.run(context.Background(), dequeued)
+	require.Len(t, executed(), 3)
+
+	cancelled := getTestQueryJob(t, q, job.ID)
+	require.Equal(t, loghttp.QueryJobCancelled, cancelled.State)
+	require.Equal(t, 2, cancelled.CompletedSplits)
+}
+
+func TestQueryJobSplits(t *testing.T) {
+	start := testTime.Truncate(time.Hour)
+	job := &queryJob{
+		QueryJob: loghttp.QueryJob{
+			Start:     start,
+			End:       start.Add(150 * time.Minute),
+			Step:      7 * time.Minute,
+			Direction: logproto.BACKWARD.String(),
+		},
+		SplitInterval: time.Hour,
+		Metric:        true,
+	}
+
+	// The splits of metric queries hold whole steps, in chronological order.
+	require.Equal(t, []queryJobSplit{
+		{start, start.Add(56 * time.Minute)},
+		{start.Add(63 * time.Minute), start.Add(119 * time.Minute)},
+		{start.Add(126 * time.Minute), start.Add(150 * time.Minute)},
+	}, job.splits())
+
+	job.Metric = false
+	require.Equal(t, []queryJobSplit{
+		{start.Add(120 * time.Minute), start.Add(150 * time.Minute)},
+		{start.Add(60 * time.Minute), start.Add(120 * time.Minute)},
+		{start, start.Add(60 * time.Minute)},
+	}, job.splits())
+}
+
+func TestQueryJobResponseSize(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		resp    base.Response
+		size    int64
+		isEmpty bool
+	}{
+		{
+			name: "streams",
+			resp: &LokiResponse{Data: LokiData{Result: []logproto.Stream{
+				{Labels: `{foo="bar"}`, Entries: []logproto.Entry{{Line: "a"}, {Line: "b"}}},
+			}}},
+			size: 2,
+		},
+		{
+			name:    "no streams",
+			resp:    &LokiResponse{},
+			isEmpty: true,
+		},
+		{
+			name: "matrix",
+			resp: &LokiPromResponse{Response: &base.PrometheusResponse{Data: base.PrometheusData{
+				Result: []base.SampleStream{{Samples: []logproto.LegacySample{{Value: 1}}}},
+			}}},
+		},
+		{
+			name:    "empty matrix",
+			resp:    &LokiPromResponse{Response: &base.PrometheusResponse{}},
+			isEmpty: true,
+		},
+		{
+			name:    "no prometheus response",
+			resp:    &LokiPromResponse{},
+			isEmpty: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			size, isEmpty := queryJobResponseSize(tc.resp)
+			require.Equal(t, tc.size, size)
+			require.Equal(t, tc.isEmpty, isEmpty)
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package queryrange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	logqllog "github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	indexStats "github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/util"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+// QueryPolicyLimits are the limits needed to evaluate query policies.
+type QueryPolicyLimits interface {
+	QueryPolicies(context.Context, string) []*validation.QueryPolicy
+}
+
+// MatchedQueryPolicy is a policy of a tenant matching a query.
+type MatchedQueryPolicy struct {
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// QueryPolicyResult is the outcome of applying the query policies of the tenants to a query.
+type QueryPolicyResult struct {
+	// Query is the query after all rewrites.
+	Query     string               `json:"query"`
+	Matched   []MatchedQueryPolicy `json:"matched"`
+	Rejected  bool                 `json:"rejected"`
+	Rewritten bool                 `json:"rewritten"`
+	// Message is the reason the query is rejected.
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type QueryPolicyMetrics struct {
+	matches *prometheus.CounterVec
+}
+
+func NewQueryPolicyMetrics(registerer prometheus.Registerer, metricsNamespace string) *QueryPolicyMetrics {
+	return &QueryPolicyMetrics{
+		matches: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_policy_matches_total",
+			Help:      "Number of queries matching a query policy, by tenant, policy and action.",
+		}, []string{"tenant", "policy", "action"}),
+	}
+}
+
+type queryPolicyEvaluator struct {
+	logger            log.Logger
+	limits            QueryPolicyLimits
+	statsHandler      queryrangebase.Handler
+	maxLookBackPeriod time.Duration
+}
+
+// evaluate applies the policies of the tenants in order to the query. The
+// policies following a rewrite match on the rewritten query, and the first
+// policy rejecting the query ends the evaluation.
+func (e *queryPolicyEvaluator) evaluate(ctx context.Context, tenantIDs []string, r queryrangebase.Request) (*QueryPolicyResult, syntax.Expr, error) {
+	expr, err := syntax.ParseExpr(r.GetQuery())
+	if err != nil {
+		return nil, nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	result := &QueryPolicyResult{Query: r.GetQuery()}
+
+	// The number of streams is only looked up once a policy depends on it.
+	var streams *uint64
+	streamCount := func() (uint64, error) {
+		if streams != nil {
+			return *streams, nil
+		}
+		groups, err := syntax.MatcherGroups(expr)
+		if err != nil {
+			return 0, err
+		}
+		var n uint64
+		if len(groups) > 0 {
+			const maxConcurrentIndexReq = 10
+			results, err := getStatsForMatchers(ctx, e.logger, e.statsHandler, model.Time(r.GetStart().UnixMilli()), model.Time(r.GetEnd().UnixMilli()), groups, maxConcurrentIndexReq, e.maxLookBackPeriod)
+			if err != nil {
+				return 0, err
+			}
+			n = indexStats.MergeStats(results...).Streams
+		}
+		streams = &n
+		return n, nil
+	}
+
+	for _, id := range tenantIDs {
+		for _, p := range e.limits.QueryPolicies(ctx, id) {
+			matched, err := matchesQueryPolicy(&p.Match, expr, streamCount)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !matched {
+				continue
+			}
+			result.Matched = append(result.Matched, MatchedQueryPolicy{Tenant: id, Name: p.Name, Action: p.Action})
+
+			switch p.Action {
+			case validation.QueryPolicyActionReject:
+				result.Rejected = true
+				result.Message = p.Message
+				if result.Message == "" {
+					result.Message = fmt.Sprintf("query blocked by policy %q", p.Name)
+				}
+				return result, expr, nil
+			case validation.QueryPolicyActionRewrite:
+				if expr, err = rewriteQuery(p, expr); err != nil {
+					return nil, nil, err
+				}
+				result.Rewritten = true
+				result.Query = expr.String()
+				streams = nil
+			case validation.QueryPolicyActionWarn:
+				msg := p.Message
+				if msg == "" {
+					msg = fmt.Sprintf("query matches policy %q", p.Name)
+				}
+				result.Warnings = append(result.Warnings, msg)
+			}
+		}
+	}
+	return result, expr, nil
+}
+
+// matchesQueryPolicy returns whether the query meets all conditions of the policy.
+func matchesQueryPolicy(m *validation.QueryPolicyMatch, expr syntax.Expr, streamCount func() (uint64, error)) (bool, error) {
+	if len(m.Types) > 0 {
+		typ, err := logql.QueryType(expr)
+		if err != nil || !slices.Contains(m.Types, typ) {
+			return false, nil
+		}
+	}
+
+	if len(m.SelectorWithoutLabels) > 0 {
+		missing := false
+		expr.Walk(func(e syntax.Expr) {
+			if s, ok := e.(*syntax.MatchersExpr); ok {
+				for _, name := range m.SelectorWithoutLabels {
+					if !slices.ContainsFunc(s.Mts, func(m *labels.Matcher) bool { return m.Name == name }) {
+						missing = true
+					}
+				}
+			}
+		})
+		if !missing {
+			return false, nil
+		}
+	}
+
+	if m.RangeLongerThan > 0 {
+		longer := false
+		expr.Walk(func(e syntax.Expr) {
+			if r, ok := e.(*syntax.LogRange); ok && r.Interval > time.Duration(m.RangeLongerThan) {
+				longer = true
+			}
+		})
+		if !longer {
+			return false, nil
+		}
+	}
+
+	if m.LeadingWildcardRegex && !hasLeadingWildcardRegex(expr) {
+		return false, nil
+	}
+
+	if m.ParserStreamsOver > 0 {
+		if !hasParser(expr) {
+			return false, nil
+		}
+		n, err := streamCount()
+		if err != nil {
+			return false, err
+		}
+		if n <= m.ParserStreamsOver {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func isLeadingWildcard(re string) bool {
+	return strings.HasPrefix(re, ".*") || strings.HasPrefix(re, ".+")
+}
+
+func hasLeadingWildcardRegex(expr syntax.Expr) bool {
+	found := false
+	var visitLineFilter func(f *syntax.LineFilterExpr)
+	visitLineFilter = func(f *syntax.LineFilterExpr) {
+		for ; f != nil; f = f.Or {
+			if (f.Ty == logqllog.LineMatchRegexp || f.Ty == logqllog.LineMatchNotRegexp) && isLeadingWildcard(f.Match) {
+				found = true
+			}
+		}
+	}
+	expr.Walk(func(e syntax.Expr) {
+		switch e := e.(type) {
+		case *syntax.MatchersExpr:
+			for _, m := range e.Mts {
+				if (m.Type == labels.MatchRegexp || m.Type == labels.MatchNotRegexp) && isLeadingWildcard(m.Value) {
+					found = true
+				}
+			}
+		case *syntax.LineFilterExpr:
+			visitLineFilter(e)
+		}
+	})
+	return found
+}
+
+func hasParser(expr syntax.Expr) bool {
+	found := false
+	expr.Walk(func(e syntax.Expr) {
+		switch e.(type) {
+		case *syntax.LabelParserExpr, *syntax.LogfmtParserExpr, *syntax.JSONExpressionParser, *syntax.LogfmtExpressionParser:
+			found = true
+		}
+	})
+	return found
+}
+
+// rewriteQuery caps the ranges of the query and adds the matchers of the
+// policy to the stream selectors without a matcher for their label.
+func rewriteQuery(p *validation.QueryPolicy, expr syntax.Expr) (syntax.Expr, error) {
+	var add []*labels.Matcher
+	if p.AddMatchers != "" {
+		var err error
+		if add, err = syntax.ParseMatchers(p.AddMatchers, true); err != nil {
+			return nil, fmt.Errorf("query policy %q: invalid add_matchers: %w", p.Name, err)
+		}
+	}
+
+	expr.Walk(func(e syntax.Expr) {
+		switch e := e.(type) {
+		case *syntax.LogRange:
+			if p.MaxRange > 0 && e.Interval > time.Duration(p.MaxRange) {
+				e.Interval = time.Duration(p.MaxRange)
+			}
+		case *syntax.MatchersExpr:
+			for _, m := range add {
+				if !slices.ContainsFunc(e.Mts, func(existing *labels.Matcher) bool { return existing.Name == m.Name }) {
+					e.Mts = append(e.Mts, m)
+				}
+			}
+		}
+	})
+
+	// Parse the rewritten query again so that no state of the previous query is kept.
+	return syntax.ParseExpr(expr.String())
+}
+
+func withQueryExpr(r queryrangebase.Request, expr syntax.Expr) queryrangebase.Request {
+	switch req := r.(type) {
+	case *LokiRequest:
+		clone := *req
+		clone.Query = expr.String()
+		clone.Plan = &plan.QueryPlan{AST: expr}
+		return &clone
+	case *LokiInstantRequest:
+		clone := *req
+		clone.Query = expr.String()
+		clone.Plan = &plan.QueryPlan{AST: expr}
+		return &clone
+	}
+	return r
+}
+
+type queryPolicyMiddleware struct {
+	evaluator queryPolicyEvaluator
+	next      queryrangebase.Handler
+	metrics   *QueryPolicyMetrics
+}
+
+// NewQueryPolicyMiddleware creates a new Middleware applying the query
+// policies of the tenants to log and metric queries. It rejects or rewrites
+// the matching queries, or attaches a warning to their response.
+func NewQueryPolicyMiddleware(
+	logger log.Logger,
+	limits QueryPolicyLimits,
+	statsHandler queryrangebase.Handler,
+	maxLookBackPeriod time.Duration,
+	metrics *QueryPolicyMetrics,
+) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &queryPolicyMiddleware{
+			evaluator: queryPolicyEvaluator{
+				logger:            logger,
+				limits:            limits,
+				statsHandler:      statsHandler,
+				maxLookBackPeriod: maxLookBackPeriod,
+			},
+			next:    next,
+			metrics: metrics,
+		}
+	})
+}
+
+func (q *queryPolicyMiddleware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	switch r.(type) {
+	case *LokiRequest, *LokiInstantRequest:
+	default:
+		return q.next.Do(ctx, r)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	if !slices.ContainsFunc(tenantIDs, func(id string) bool { return len(q.evaluator.limits.QueryPolicies(ctx, id)) > 0 }) {
+		return q.next.Do(ctx, r)
+	}
+
+	result, expr, err := q.evaluator.evaluate(ctx, tenantIDs, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range result.Matched {
+		q.metrics.matches.WithLabelValues(m.Tenant, m.Name, m.Action).Inc()
+	}
+
+	logger := util_log.WithContext(ctx, q.evaluator.logger)
+	if result.Rejected {
+		level.Warn(logger).Log("msg", "query rejected by policy", "query", r.GetQuery(), "query_hash", util.HashedQuery(r.GetQuery()), "message", result.Message)
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", result.Message)
+	}
+	if result.Rewritten {
+		level.Info(logger).Log("msg", "query rewritten by policy", "query", r.GetQuery(), "rewritten", result.Query)
+		r = withQueryExpr(r, expr)
+	}
+
+	resp, err := q.next.Do(ctx, r)
+	if err != nil || len(result.Warnings) == 0 {
+		return resp, err
+	}
+	switch res := resp.(type) {
+	case *LokiResponse:
+		res.Warnings = append(res.Warnings, result.Warnings...)
+	case *LokiPromResponse:
+		if res.Response != nil {
+			res.Response.Warnings = append(res.Response.Warnings, result.Warnings...)
+		}
+	}
+	return resp, nil
+}
+
+type queryPolicyHandler struct {
+	evaluator queryPolicyEvaluator
+}
+
+// NewQueryPolicyHandler returns a handler evaluating the query policies of the
+// tenant on a query without executing it. The next handler serves the index
+// stats requests of the policies depending on the number of streams.
+func NewQueryPolicyHandler(next queryrangebase.Handler, limits QueryPolicyLimits, defaultLookback time.Duration) http.Handler {
+	return &queryPolicyHandler{
+		evaluator: queryPolicyEvaluator{
+			logger:            util_log.Logger,
+			limits:            limits,
+			statsHandler:      next,
+			maxLookBackPeriod: defaultLookback,
+		},
+	}
+}
+
+func (h *queryPolicyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	req, err := decodeQueryRequest(ctx, r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	result, _, err := h.evaluator.evaluate(ctx, tenantIDs, req)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if result.Matched == nil {
+		result.Matched = []MatchedQueryPolicy{}
+	}
+	util.WriteJSONResponse(w, result)
+}
@@ -0,0 +1,185 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+func TestMatchesQueryPolicy(t *testing.T) {
+	streams := func(n uint64) func() (uint64, error) {
+		return func() (uint64, error) { return n, nil }
+	}
+
+	for _, tc := range []struct {
+		name    string
+		match   validation.QueryPolicyMatch
+		query   string
+		streams uint64
+		matches bool
+	}{
+		{"no conditions", validation.QueryPolicyMatch{}, `{app="foo"}`, 0, true},
+		{"type", validation.QueryPolicyMatch{Types: []string{"metric"}}, `{app="foo"}`, 0, false},
+		{"type metric", validation.QueryPolicyMatch{Types: []string{"metric"}}, `rate({app="foo"}[1m])`, 0, true},
+		{"selector with label", validation.QueryPolicyMatch{SelectorWithoutLabels: []string{"namespace"}}, `{app="foo", namespace="bar"}`, 0, false},
+		{"selector without label", validation.QueryPolicyMatch{SelectorWithoutLabels: []string{"namespace"}}, `{app="foo"}`, 0, true},
+		{"one selector without label", validation.QueryPolicyMatch{SelectorWithoutLabels: []string{"namespace"}}, `rate({namespace="bar"}[1m]) / rate({app="foo"}[1m])`, 0, true},
+		{"short range", validation.QueryPolicyMatch{RangeLongerThan: model.Duration(time.Hour)}, `rate({app="foo"}[1h])`, 0, false},
+		{"long range", validation.QueryPolicyMatch{RangeLongerThan: model.Duration(time.Hour)}, `rate({app="foo"}[2h])`, 0, true},
+		{"regex", validation.QueryPolicyMatch{LeadingWildcardRegex: true}, `{app=~"foo.*"} |~ "bar.*"`, 0, false},
+		{"leading wildcard matcher", validation.QueryPolicyMatch{LeadingWildcardRegex: true}, `{app=~".*foo"}`, 0, true},
+		{"leading wildcard line filter", validation.QueryPolicyMatch{LeadingWildcardRegex: true}, `{app="foo"} |= "bar" or ".+baz"`, 0, false},
+		{"leading wildcard regex line filter", validation.QueryPolicyMatch{LeadingWildcardRegex: true}, `{app="foo"} |~ "bar" or ".+baz"`, 0, true},
+		{"no parser", validation.QueryPolicyMatch{ParserStreamsOver: 10}, `{app="foo"} |= "bar"`, 100, false},
+		{"parser over few streams", validation.QueryPolicyMatch{ParserStreamsOver: 10}, `{app="foo"} | json`, 10, false},
+		{"parser over many streams", validation.QueryPolicyMatch{ParserStreamsOver: 10}, `{app="foo"} | logfmt`, 11, true},
+		{"all conditions", validation.QueryPolicyMatch{SelectorWithoutLabels: []string{"namespace"}, RangeLongerThan: model.Duration(time.Hour)}, `rate({app="foo"}[1m])`, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			matches, err := matchesQueryPolicy(&tc.match, syntax.MustParseExpr(tc.query), streams(tc.streams))
+			require.NoError(t, err)
+			require.Equal(t, tc.matches, matches)
+		})
+	}
+}
+
+func TestRewriteQuery(t *testing.T) {
+	policy := &validation.QueryPolicy{
+		Name:        "rewrite",
+		Action:      validation.QueryPolicyActionRewrite,
+		MaxRange:    model.Duration(time.Hour),
+		AddMatchers: `{namespace="default", app="bar"}`,
+	}
+	expr, err := rewriteQuery(policy, syntax.MustParseExpr(`sum(rate({app="foo"} |= "x" [1d])) / sum(rate({app="foo"}[5m]))`))
+	require.NoError(t, err)
+	require.Equal(t, `(sum(rate({app="foo", namespace="default"} |= "x"[1h])) / sum(rate({app="foo", namespace="default"}[5m])))`, expr.String())
+}
+
+type fakeQueryPolicyLimits map[string][]*validation.QueryPolicy
+
+func (f fakeQueryPolicyLimits) QueryPolicies(_ context.Context, tenant string) []*validation.QueryPolicy {
+	return f[tenant]
+}
+
+func TestQueryPolicyMiddleware(t *testing.T) {
+	newRequest := func(query string) *LokiRequest {
+		return &LokiRequest{
+			Query:   query,
+			StartTs: testTime.Add(-time.Hour),
+			EndTs:   testTime,
+			Limit:   100,
+			Path:    "/loki/api/v1/query_range",
+			Plan:    &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+		}
+	}
+	ctx := user.InjectOrgID(context.Background(), "1")
+	statsCount, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Streams: 100})
+
+	var received []string
+	next := base.HandlerFunc(func(_ context.Context, r base.Request) (base.Response, error) {
+		received = append(received, r.GetQuery())
+		require.Equal(t, r.GetQuery(), r.(*LokiRequest).Plan.AST.String())
+		return &LokiResponse{Status: "success"}, nil
+	})
+	limits := fakeQueryPolicyLimits{"1": {
+		{Name: "namespace", Match: validation.QueryPolicyMatch{SelectorWithoutLabels: []string{"namespace"}}, Action: validation.QueryPolicyActionRewrite, AddMatchers: `{namespace="default"}`},
+		{Name: "wildcard", Match: validation.QueryPolicyMatch{LeadingWildcardRegex: true}, Action: validation.QueryPolicyActionReject, Message: "no leading wildcards"},
+		{Name: "parser", Match: validation.QueryPolicyMatch{ParserStreamsOver: 10}, Action: validation.QueryPolicyActionWarn},
+	}}
+	h := NewQueryPolicyMiddleware(util_log.Logger, limits, statsHandler, 0, NewMetrics(nil, "loki").QueryPolicyMetrics).Wrap(next)
+
+	t.Run("rewrite", func(t *testing.T) {
+		received = nil
+		resp, err := h.Do(ctx, newRequest(`{app="foo"}`))
+		require.NoError(t, err)
+		require.Equal(t, []string{`{app="foo", namespace="default"}`}, received)
+		require.Empty(t, resp.(*LokiResponse).Warnings)
+		require.Equal(t, 0, *statsCount)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		received = nil
+		_, err := h.Do(ctx, newRequest(`{namespace="default", app=~".*foo"}`))
+		require.Error(t, err)
+		res, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, http.StatusBadRequest, int(res.Code))
+		require.Equal(t, "no leading wildcards", string(res.Body))
+		require.Empty(t, received)
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		received = nil
+		resp, err := h.Do(ctx, newRequest(`{namespace="default"} | json`))
+		require.NoError(t, err)
+		require.Equal(t, []string{`{namespace="default"} | json`}, received)
+		require.Equal(t, []string{`query matches policy "parser"`}, resp.(*LokiResponse).Warnings)
+		require.Equal(t, 1, *statsCount)
+	})
+
+	t.Run("tenant without policies", func(t *testing.T) {
+		received = nil
+		_, err := h.Do(user.InjectOrgID(context.Background(), "2"), newRequest(`{app=~".*foo"}`))
+		require.NoError(t, err)
+		require.Equal(t, []string{`{app=~".*foo"}`}, received)
+	})
+
+	t.Run("stats error", func(t *testing.T) {
+		failing := base.HandlerFunc(func(context.Context, base.Request) (base.Response, error) {
+			return nil, errors.New("index unavailable")
+		})
+		h := NewQueryPolicyMiddleware(util_log.Logger, limits, failing, 0, NewMetrics(nil, "loki").QueryPolicyMetrics).Wrap(next)
+		_, err := h.Do(ctx, newRequest(`{namespace="default"} | json`))
+		require.ErrorContains(t, err, "index unavailable")
+	})
+}
+
+func TestQueryPolicyHandler(t *testing.T) {
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Streams: 100})
+	limits := fakeQueryPolicyLimits{"1": {
+		{Name: "range", Match: validation.QueryPolicyMatch{RangeLongerThan: model.Duration(time.Hour)}, Action: validation.QueryPolicyActionRewrite, MaxRange: model.Duration(time.Hour)},
+		{Name: "parser", Match: validation.QueryPolicyMatch{ParserStreamsOver: 10}, Action: validation.QueryPolicyActionWarn, Message: "too many streams"},
+	}}
+	h := NewQueryPolicyHandler(statsHandler, limits, 0)
+
+	form := url.Values{
+		"query": []string{`sum(rate({app="foo"} | json [1d]))`},
+		"start": []string{fmt.Sprint(testTime.Add(-6 * time.Hour).UnixNano())},
+		"end":   []string{fmt.Sprint(testTime.UnixNano())},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_policies/test?"+form.Encode(), nil)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result QueryPolicyResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, QueryPolicyResult{
+		Query: `sum(rate({app="foo"} | json[1h]))`,
+		Matched: []MatchedQueryPolicy{
+			{Tenant: "1", Name: "range", Action: validation.QueryPolicyActionRewrite},
+			{Tenant: "1", Name: "parser", Action: validation.QueryPolicyActionWarn},
+		},
+		Rewritten: true,
+		Warnings:  []string{"too many streams"},
+	}, result)
+}
@@ -0,0 +1,361 @@
+package queryrange
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/compactor/rollup"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// RollupReader reads the retention rollups of the rules of a tenant.
+type RollupReader interface {
+	Series(ctx context.Context, tenant, rule string, from, through model.Time) ([]rollup.Series, error)
+}
+
+// RollupLimits are the limits the retention rollups are configured with.
+type RollupLimits interface {
+	RetentionRollups(userID string) []validation.RetentionRollup
+	RetentionPeriod(userID string) time.Duration
+	StreamRetention(userID string) []validation.StreamRetention
+}
+
+type resultsCacheSkippedKey struct{}
+
+// withoutResultsCache returns a context in which the queries skip the results
+// cache.
+func withoutResultsCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resultsCacheSkippedKey{}, true)
+}
+
+// resultsCacheSkipped returns true if the queries skip the results cache.
+func resultsCacheSkipped(ctx context.Context) bool {
+	skipped, _ := ctx.Value(resultsCacheSkippedKey{}).(bool)
+	return skipped
+}
+
+// NewRetentionRollupsMiddleware returns a middleware which adds the retention
+// rollups of the logs deleted by retention to the results of the range metric
+// queries they can answer. Only the steps whose range starts before the
+// shortest retention period of the tenant can include deleted logs. They are
+// queried without the results cache, which may hold results computed before
+// the logs were rolled up and deleted, and the rollups are added to them. The
+// other steps are queried as usual.
+func NewRetentionRollupsMiddleware(reader RollupReader, limits RollupLimits, logger log.Logger) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+			req, ok := r.(*LokiRequest)
+			if !ok || req.Plan == nil {
+				return next.Do(ctx, r)
+			}
+			expr, ok := req.Plan.AST.(syntax.SampleExpr)
+			if !ok {
+				return next.Do(ctx, r)
+			}
+			tenantIDs, err := tenant.TenantIDs(ctx)
+			if err != nil || len(tenantIDs) != 1 {
+				return next.Do(ctx, r)
+			}
+			q, ok := matchRollupQuery(expr, limits.RetentionRollups(tenantIDs[0]), req)
+			if !ok {
+				return next.Do(ctx, r)
+			}
+			cutoff, ok := retentionCutoff(limits, tenantIDs[0], time.Now())
+			if !ok {
+				return next.Do(ctx, r)
+			}
+			old, recent, ok := q.splitAtRetention(req, cutoff)
+			if !ok {
+				return next.Do(ctx, r)
+			}
+
+			resp, err := next.Do(withoutResultsCache(ctx), old)
+			if err != nil {
+				return nil, err
+			}
+			promResp, ok := resp.(*LokiPromResponse)
+			if !ok || promResp.Response == nil {
+				return resp, nil
+			}
+			result := promResp.Response.Data.Result
+			if recent != nil {
+				resp, err := next.Do(ctx, recent)
+				if err != nil {
+					return nil, err
+				}
+				recentResp, ok := resp.(*LokiPromResponse)
+				if !ok || recentResp.Response == nil {
+					return nil, fmt.Errorf("unexpected response type %T", resp)
+				}
+				result = append(result, recentResp.Response.Data.Result...)
+				promResp.Statistics.Merge(recentResp.Statistics)
+			}
+
+			series, err := reader.Series(ctx, tenantIDs[0], q.rule.Name, model.TimeFromUnixNano(old.StartTs.UnixNano()).Add(-q.interval), model.TimeFromUnixNano(old.EndTs.UnixNano()))
+			if err != nil {
+				level.Warn(logger).Log("msg", "failed to read retention rollups, returning the results of the retained logs only", "rule", q.rule.Name, "err", err)
+				series = nil
+			}
+			// The steps of both parts are merged even without rollups.
+			promResp.Response.Data.Result = q.merge(result, series, old)
+			return promResp, nil
+		})
+	})
+}
+
+// retentionCutoff returns the time before which the logs of the tenant may
+// have been deleted by retention, using its shortest retention period. It
+// returns false if the retention of the tenant is disabled.
+func retentionCutoff(limits RollupLimits, userID string, now time.Time) (time.Time, bool) {
+	period := limits.RetentionPeriod(userID)
+	for _, r := range limits.StreamRetention(userID) {
+		if p := time.Duration(r.Period); p > 0 && (period <= 0 || p < period) {
+			period = p
+		}
+	}
+	if period <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-period), true
+}
+
+// splitAtRetention splits the request into the steps whose range starts
+// before the cutoff and the following ones, if any. It returns false if no
+// step starts before the cutoff.
+func (q *rollupQuery) splitAtRetention(req *LokiRequest, cutoff time.Time) (*LokiRequest, *LokiRequest, bool) {
+	start, end := req.StartTs.UnixMilli(), req.EndTs.UnixMilli()
+	// The range of the step at ts starts before the cutoff if ts-interval < cutoff.
+	last := cutoff.UnixMilli() + q.interval.Milliseconds() - 1
+	if last < start {
+		return nil, nil, false
+	}
+	last = start + (min(last, end)-start)/req.Step*req.Step
+
+	old := req.WithStartEnd(req.StartTs, time.UnixMilli(last)).(*LokiRequest)
+	if last+req.Step > end {
+		return old, nil, true
+	}
+	return old, req.WithStartEnd(time.UnixMilli(last+req.Step), req.EndTs).(*LokiRequest), true
+}
+
+// rollupQuery is a range metric query which can be answered from the rollups
+// of a rule.
+type rollupQuery struct {
+	rule validation.RetentionRollup
+	// value returns the value of a point for the range aggregation.
+	value func(p *rollup.Point) (float64, bool)
+	// combine combines two values for the vector aggregation.
+	combine  func(a, b float64) float64
+	grouping []string
+	matchers []*labels.Matcher
+	interval time.Duration
+}
+
+// matchRollupQuery returns the rollup query of the expression if the rollups of
+// one of the rules can answer it. The query must aggregate a range aggregation
+// of the lines of a stream selector, optionally unwrapping a label, with
+// matchers on the by labels of the rule in addition to those of its selector,
+// and be aligned to its resolution.
+func matchRollupQuery(expr syntax.SampleExpr, rules []validation.RetentionRollup, req *LokiRequest) (*rollupQuery, bool) {
+	vec, ok := expr.(*syntax.VectorAggregationExpr)
+	if !ok || vec.Params != 0 || (vec.Grouping != nil && vec.Grouping.Without) {
+		return nil, false
+	}
+	rng, ok := vec.Left.(*syntax.RangeAggregationExpr)
+	if !ok || rng.Params != nil || rng.Grouping != nil || rng.Left.Offset != 0 {
+		return nil, false
+	}
+	selector, ok := rng.Left.Left.(*syntax.MatchersExpr)
+	if !ok {
+		return nil, false
+	}
+	var unwrap string
+	if u := rng.Left.Unwrap; u != nil {
+		if u.Operation != "" || len(u.PostFilters) > 0 {
+			return nil, false
+		}
+		unwrap = u.Identifier
+	}
+
+	q := &rollupQuery{interval: rng.Left.Interval}
+	if vec.Grouping != nil {
+		q.grouping = vec.Grouping.Groups
+	}
+	seconds := q.interval.Seconds()
+	unwrapped := func(f func(s *rollup.UnwrapStats) float64) func(p *rollup.Point) (float64, bool) {
+		return func(p *rollup.Point) (float64, bool) {
+			s, ok := p.Unwrapped[unwrap]
+			if !ok || s.Count == 0 {
+				return 0, false
+			}
+			return f(s), true
+		}
+	}
+	switch {
+	case vec.Operation == syntax.OpTypeSum && unwrap == "" && rng.Operation == syntax.OpRangeTypeCount:
+		q.value = func(p *rollup.Point) (float64, bool) { return float64(p.Count), p.Count > 0 }
+	case vec.Operation == syntax.OpTypeSum && unwrap == "" && rng.Operation == syntax.OpRangeTypeRate:
+		q.value = func(p *rollup.Point) (float64, bool) { return float64(p.Count) / seconds, p.Count > 0 }
+	case vec.Operation == syntax.OpTypeSum && unwrap == "" && rng.Operation == syntax.OpRangeTypeBytes:
+		q.value = func(p *rollup.Point) (float64, bool) { return float64(p.Bytes), p.Count > 0 }
+	case vec.Operation == syntax.OpTypeSum && unwrap == "" && rng.Operation == syntax.OpRangeTypeBytesRate:
+		q.value = func(p *rollup.Point) (float64, bool) { return float64(p.Bytes) / seconds, p.Count > 0 }
+	case vec.Operation == syntax.OpTypeSum && unwrap != "" && rng.Operation == syntax.OpRangeTypeSum:
+		q.value = unwrapped(func(s *rollup.UnwrapStats) float64 { return s.Sum })
+	case vec.Operation == syntax.OpTypeSum && unwrap != "" && rng.Operation == syntax.OpRangeTypeRate:
+		q.value = unwrapped(func(s *rollup.UnwrapStats) float64 { return s.Sum / seconds })
+	case vec.Operation == syntax.OpTypeMin && unwrap != "" && rng.Operation == syntax.OpRangeTypeMin:
+		q.value = unwrapped(func(s *rollup.UnwrapStats) float64 { return s.Min })
+	case vec.Operation == syntax.OpTypeMax && unwrap != "" && rng.Operation == syntax.OpRangeTypeMax:
+		q.value = unwrapped(func(s *rollup.UnwrapStats) float64 { return s.Max })
+	default:
+		return nil, false
+	}
+	switch vec.Operation {
+	case syntax.OpTypeSum:
+		q.combine = func(a, b float64) float64 { return a + b }
+	case syntax.OpTypeMin:
+		q.combine = math.Min
+	case syntax.OpTypeMax:
+		q.combine = math.Max
+	}
+
+	for _, rule := range rules {
+		if matchers, ok := rollupRuleMatches(rule, selector.Mts, q.grouping, unwrap, q.interval, req); ok {
+			q.rule, q.matchers = rule, matchers
+			return q, true
+		}
+	}
+	return nil, false
+}
+
+// rollupRuleMatches returns whether the rollups of the rule can answer the
+// query, and the matchers of the query to apply to the rollups.
+func rollupRuleMatches(rule validation.RetentionRollup, matchers []*labels.Matcher, grouping []string, unwrap string, interval time.Duration, req *LokiRequest) ([]*labels.Matcher, bool) {
+	resolution := time.Duration(rule.Resolution).Milliseconds()
+	if resolution <= 0 || interval.Milliseconds()%resolution != 0 || req.Step <= 0 || req.Step%resolution != 0 || req.StartTs.UnixMilli()%resolution != 0 {
+		return nil, false
+	}
+	if unwrap != "" && !slices.Contains(rule.Unwrap, unwrap) {
+		return nil, false
+	}
+	for _, name := range grouping {
+		if !slices.Contains(rule.By, name) {
+			return nil, false
+		}
+	}
+
+	ruleMatchers := make(map[string]struct{}, len(rule.Matchers))
+	for _, m := range rule.Matchers {
+		ruleMatchers[m.String()] = struct{}{}
+	}
+	var extra []*labels.Matcher
+	for _, m := range matchers {
+		if _, ok := ruleMatchers[m.String()]; ok {
+			delete(ruleMatchers, m.String())
+			continue
+		}
+		if !slices.Contains(rule.By, m.Name) {
+			return nil, false
+		}
+		extra = append(extra, m)
+	}
+	// The query must select all the streams of the rule.
+	if len(ruleMatchers) > 0 {
+		return nil, false
+	}
+	return extra, true
+}
+
+// merge adds the values of the rollups at each step of the request to the
+// result of the retained logs.
+func (q *rollupQuery) merge(result []queryrangebase.SampleStream, series []rollup.Series, req *LokiRequest) []queryrangebase.SampleStream {
+	type group struct {
+		labels labels.Labels
+		values map[int64]float64
+	}
+	groups := map[string]*group{}
+	add := func(lbls labels.Labels, ts int64, v float64) {
+		key := lbls.String()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: lbls, values: map[int64]float64{}}
+			groups[key] = g
+		}
+		if existing, ok := g.values[ts]; ok {
+			v = q.combine(existing, v)
+		}
+		g.values[ts] = v
+	}
+
+	for _, s := range result {
+		lbls := logproto.FromLabelAdaptersToLabels(s.Labels)
+		for _, sample := range s.Samples {
+			add(lbls, sample.TimestampMs, sample.Value)
+		}
+	}
+
+	start, end := req.StartTs.UnixMilli(), req.EndTs.UnixMilli()
+	interval := q.interval.Milliseconds()
+Outer:
+	for _, s := range series {
+		lbls, err := syntax.ParseLabels(s.Labels)
+		if err != nil {
+			continue
+		}
+		for _, m := range q.matchers {
+			if !m.Matches(lbls.Get(m.Name)) {
+				continue Outer
+			}
+		}
+		lb := labels.NewBuilder(lbls)
+		lb.Keep(q.grouping...)
+		lbls = lb.Labels()
+
+		for i := range s.Points {
+			p := &s.Points[i]
+			v, ok := q.value(p)
+			if !ok {
+				continue
+			}
+			// A point is in the range of the steps ts with ts-interval <= p < ts.
+			ts := start
+			if p.Timestamp >= start {
+				ts = start + ((p.Timestamp-start)/req.Step+1)*req.Step
+			}
+			for ; ts <= end && ts <= p.Timestamp+interval; ts += req.Step {
+				add(lbls, ts, v)
+			}
+		}
+	}
+
+	merged := make([]queryrangebase.SampleStream, 0, len(groups))
+	for _, g := range groups {
+		s := queryrangebase.SampleStream{
+			Labels:  logproto.FromLabelsToLabelAdapters(g.labels),
+			Samples: make([]logproto.LegacySample, 0, len(g.values)),
+		}
+		for ts, v := range g.values {
+			s.Samples = append(s.Samples, logproto.LegacySample{TimestampMs: ts, Value: v})
+		}
+		sort.Slice(s.Samples, func(i, j int) bool { return s.Samples[i].TimestampMs < s.Samples[j].TimestampMs })
+		merged = append(merged, s)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return logproto.FromLabelAdaptersToLabels(merged[i].Labels).String() < logproto.FromLabelAdaptersToLabels(merged[j].Labels).String()
+	})
+	return merged
+}
@@ -0,0 +1,249 @@
+package queryrange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/compactor/rollup"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/cache"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type fakeRollupReader []rollup.Series
+
+func (f fakeRollupReader) Series(_ context.Context, _, _ string, _, _ model.Time) ([]rollup.Series, error) {
+	return f, nil
+}
+
+type fakeRollupLimits []validation.RetentionRollup
+
+func (f fakeRollupLimits) RetentionRollups(_ string) []validation.RetentionRollup {
+	return f
+}
+
+func (f fakeRollupLimits) RetentionPeriod(_ string) time.Duration {
+	return 24 * time.Hour
+}
+
+func (f fakeRollupLimits) StreamRetention(_ string) []validation.StreamRetention {
+	return nil
+}
+
+func testRollupRule() validation.RetentionRollup {
+	return validation.RetentionRollup{
+		Name:       "access",
+		Selector:   `{job="nginx"}`,
+		By:         []string{"status", "method"},
+		Unwrap:     []string{"duration"},
+		Resolution: model.Duration(time.Minute),
+		Period:     model.Duration(365 * 24 * time.Hour),
+		Matchers:   []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "nginx")},
+	}
+}
+
+func rollupTestRequest(t *testing.T, query string, start time.Time, step time.Duration) *LokiRequest {
+	expr, err := syntax.ParseExpr(query)
+	require.NoError(t, err)
+	return &LokiRequest{
+		Query:   query,
+		StartTs: start,
+		EndTs:   start.Add(2 * step),
+		Step:    step.Milliseconds(),
+		Plan:    &plan.QueryPlan{AST: expr},
+	}
+}
+
+func TestMatchRollupQuery(t *testing.T) {
+	rules := []validation.RetentionRollup{testRollupRule()}
+	start := time.Unix(3600, 0)
+
+	for _, tc := range []struct {
+		query string
+		start time.Time
+		match bool
+	}{
+		{query: `sum(count_over_time({job="nginx"}[5m]))`, match: true},
+		{query: `sum by (status) (rate({job="nginx", method="GET"}[5m]))`, match: true},
+		{query: `sum by (status) (bytes_over_time({job="nginx"}[5m]))`, match: true},
+		{query: `sum(sum_over_time({job="nginx"} | unwrap duration [5m]))`, match: true},
+		{query: `max by (method) (max_over_time({job="nginx"} | unwrap duration [5m]))`, match: true},
+		// Grouping by a label which is not rolled up.
+		{query: `sum by (pod) (count_over_time({job="nginx"}[5m]))`},
+		// Selecting a subset of the streams of the rule on a label which is not rolled up.
+		{query: `sum(count_over_time({job="nginx", pod="a"}[5m]))`},
+		// Selecting other streams.
+		{query: `sum(count_over_time({job="api"}[5m]))`},
+		// Filtering the lines.
+		{query: `sum(count_over_time({job="nginx"} |= "error" [5m]))`},
+		// Unwrapping a label which is not rolled up.
+		{query: `sum(sum_over_time({job="nginx"} | unwrap bytes [5m]))`},
+		// Aggregations which can't be computed from the rollups.
+		{query: `max(count_over_time({job="nginx"}[5m]))`},
+		{query: `sum(avg_over_time({job="nginx"} | unwrap duration [5m]))`},
+		{query: `sum without (status) (count_over_time({job="nginx"}[5m]))`},
+		// Ranges or steps not aligned to the resolution.
+		{query: `sum(count_over_time({job="nginx"}[90s]))`},
+		{query: `sum(count_over_time({job="nginx"}[5m]))`, start: start.Add(time.Second)},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			if tc.start.IsZero() {
+				tc.start = start
+			}
+			req := rollupTestRequest(t, tc.query, tc.start, time.Minute)
+			_, ok := matchRollupQuery(req.Plan.AST.(syntax.SampleExpr), rules, req)
+			require.Equal(t, tc.match, ok)
+		})
+	}
+}
+
+func TestRetentionRollupsMiddleware(t *testing.T) {
+	start := time.Unix(3600, 0)
+	step := time.Minute
+
+	next := base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+		return &LokiPromResponse{
+			Response: &base.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: base.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+					Result: []base.SampleStream{{
+						Labels:  []logproto.LabelAdapter{{Name: "status", Value: "200"}},
+						Samples: []logproto.LegacySample{{TimestampMs: start.Add(2 * step).UnixMilli(), Value: 1}},
+					}},
+				},
+			},
+		}, nil
+	})
+	reader := fakeRollupReader{
+		{Labels: `{method="GET", status="200"}`, Points: []rollup.Point{
+			{Timestamp: start.Add(-step).UnixMilli(), Count: 2},
+			{Timestamp: start.Add(step).UnixMilli(), Count: 3},
+		}},
+		{Labels: `{method="POST", status="200"}`, Points: []rollup.Point{
+			{Timestamp: start.UnixMilli(), Count: 4},
+		}},
+		{Labels: `{method="GET", status="500"}`, Points: []rollup.Point{
+			{Timestamp: start.UnixMilli(), Count: 5},
+		}},
+	}
+	h := NewRetentionRollupsMiddleware(reader, fakeRollupLimits{testRollupRule()}, util_log.Logger).Wrap(next)
+
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	resp, err := h.Do(ctx, rollupTestRequest(t, `sum by (status) (count_over_time({job="nginx", method="GET"}[2m]))`, start, step))
+	require.NoError(t, err)
+	require.Equal(t, []base.SampleStream{
+		{
+			Labels: []logproto.LabelAdapter{{Name: "status", Value: "200"}},
+			Samples: []logproto.LegacySample{
+				{TimestampMs: start.UnixMilli(), Value: 2},
+				{TimestampMs: start.Add(step).UnixMilli(), Value: 2},
+				{TimestampMs: start.Add(2 * step).UnixMilli(), Value: 4},
+			},
+		},
+		{
+			Labels: []logproto.LabelAdapter{{Name: "status", Value: "500"}},
+			Samples: []logproto.LegacySample{
+				{TimestampMs: start.Add(step).UnixMilli(), Value: 5},
+				{TimestampMs: start.Add(2 * step).UnixMilli(), Value: 5},
+			},
+		},
+	}, resp.(*LokiPromResponse).Response.Data.Result)
+
+	// Queries which can't be answered from the rollups are passed through.
+	resp, err = h.Do(ctx, rollupTestRequest(t, `sum by (status) (count_over_time({job="nginx"} |= "error" [2m]))`, start, step))
+	require.NoError(t, err)
+	require.Len(t, resp.(*LokiPromResponse).Response.Data.Result[0].Samples, 1)
+}
+
+func TestRetentionRollupsMiddleware_ResultsCache(t *testing.T) {
+	step := time.Minute
+	start := time.Now().Add(-24*time.Hour - 10*step).Truncate(step)
+	query := `sum by (status) (count_over_time({job="nginx"}[2m]))`
+	req := rollupTestRequest(t, query, start, step)
+	req.EndTs = start.Add(20 * step)
+
+	// A line is logged every minute, so the queriers count two lines at each
+	// step until the logs before the retention cutoff are deleted.
+	deleted := false
+	var queried []*LokiRequest
+	next := base.HandlerFunc(func(_ context.Context, r base.Request) (base.Response, error) {
+		lr := r.(*LokiRequest)
+		queried = append(queried, lr)
+		var samples []logproto.LegacySample
+		for ts := lr.StartTs; !ts.After(lr.EndTs); ts = ts.Add(step) {
+			if !deleted || ts.After(time.Now().Add(-24*time.Hour+2*step)) {
+				samples = append(samples, logproto.LegacySample{TimestampMs: ts.UnixMilli(), Value: 2})
+			}
+		}
+		return &LokiPromResponse{Response: &base.PrometheusResponse{
+			Status: loghttp.QueryStatusSuccess,
+			Data: base.PrometheusData{
+				ResultType: loghttp.ResultTypeMatrix,
+				Result:     []base.SampleStream{{Labels: []logproto.LabelAdapter{{Name: "status", Value: "200"}}, Samples: samples}},
+			},
+		}}, nil
+	})
+
+	cacheMiddleware, err := base.NewResultsCacheMiddleware(
+		util_log.Logger,
+		cache.NewMockCache(),
+		cacheKeyLimits{fakeLimits{}, nil, nil},
+		fakeLimits{},
+		DefaultCodec,
+		PrometheusExtractor{},
+		nil,
+		shouldCacheRequest,
+		func(_ context.Context, _ []string, _ base.Request) int { return 1 },
+		false,
+		false,
+		base.NewResultsCacheMetrics(nil),
+	)
+	require.NoError(t, err)
+	cached := cacheMiddleware.Wrap(next)
+
+	// The results of all the steps are cached before the logs are deleted.
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	_, err = cached.Do(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, queried, 1)
+
+	// Once deleted, the logs are rolled up at each minute.
+	deleted = true
+	var points []rollup.Point
+	for ts := start.Add(-2 * step); ts.Before(req.EndTs); ts = ts.Add(step) {
+		points = append(points, rollup.Point{Timestamp: ts.UnixMilli(), Count: 1})
+	}
+	reader := fakeRollupReader{{Labels: `{method="GET", status="200"}`, Points: points}}
+	h := NewRetentionRollupsMiddleware(reader, fakeRollupLimits{testRollupRule()}, util_log.Logger).Wrap(cached)
+
+	queried = nil
+	resp, err := h.Do(ctx, req)
+	require.NoError(t, err)
+
+	// The steps which may include deleted logs skip the cache, the others are
+	// answered from it.
+	require.Len(t, queried, 1)
+	require.Equal(t, start, queried[0].StartTs)
+	require.True(t, queried[0].EndTs.Before(req.EndTs))
+
+	result := resp.(*LokiPromResponse).Response.Data.Result
+	require.Len(t, result, 1)
+	require.Len(t, result[0].Samples, 21)
+	for _, sample := range result[0].Samples {
+		// Each step counts the lines of its range once, either from the rollups
+		// or from the cache.
+		require.Equal(t, 2.0, sample.Value, "step %s", time.UnixMilli(sample.TimestampMs))
+	}
+}
@@ -146,6 +146,10 @@ func (ast *astMapperware) checkQuerySizeLimit(ctx context.Context, bytesPerShard
 }
 
 func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	return explainDo(ctx, ExplainOpShardMapping, r, queryrangebase.HandlerFunc(ast.do))
+}
+
+func (ast *astMapperware) do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
 	logger := spanlogger.FromContextWithFallback(
 		ctx,
 		util_log.WithContext(ctx, ast.logger),
@@ -229,6 +233,9 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (que
 	if noop {
 		return ast.next.Do(ctx, r)
 	}
+	if node, ok := explainNodeFromContext(ctx); ok {
+		node.setMappedQuery(parsed.String())
+	}
 
 	var path string
 	switch r := r.(type) {
@@ -241,10 +248,12 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (que
 	}
 	query := ast.ng.Query(ctx, logql.ParamsWithExpressionOverride{Params: params, ExpressionOverride: parsed})
 
+	ctx, explained := explainEvaluator(ctx)
 	res, err := query.Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
+	explained()
 
 	// Merge index and volume stats result cache stats from shard resolver into the query stats.
 	res.Statistics.Merge(resolverStats.Result(0, 0, 0))
@@ -57,6 +57,8 @@ type Config struct {
 	SeriesCacheConfig            SeriesCacheConfig        `yaml:"series_results_cache" doc:"description=If series_results_cache is not configured and cache_series_results is true, the config for the results cache is used."`
 	CacheLabelResults            bool                     `yaml:"cache_label_results"`
 	LabelsCacheConfig            LabelsCacheConfig        `yaml:"label_results_cache" doc:"description=If label_results_cache is not configured and cache_label_results is true, the config for the results cache is used."`
+	QueryAcceleration            QueryAccelerationConfig  `yaml:"query_acceleration"`
+	QueryJobs                    QueryJobsConfig          `yaml:"query_jobs"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
@@ -73,6 +75,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.SeriesCacheConfig.RegisterFlags(f)
 	f.BoolVar(&cfg.CacheLabelResults, "querier.cache-label-results", true, "Cache label query results.")
 	cfg.LabelsCacheConfig.RegisterFlags(f)
+	cfg.QueryAcceleration.RegisterFlags(f)
+	cfg.QueryJobs.RegisterFlags(f)
 }
 
 // Validate validates the config.
@@ -86,6 +90,12 @@ func (cfg *Config) Validate() error {
 			return errors.Wrap(err, "invalid index_stats_results_cache config")
 		}
 	}
+	if err := cfg.QueryAcceleration.Validate(); err != nil {
+		return errors.Wrap(err, "invalid query_acceleration config")
+	}
+	if err := cfg.QueryJobs.Validate(); err != nil {
+		return errors.Wrap(err, "invalid query_jobs config")
+	}
 	return nil
 }
 
@@ -197,20 +207,33 @@ func NewMiddleware(
 		return nil, nil, err
 	}
 
+	costBudgets := NewQueryCostBudgets()
+
+	stoppers := StopperWrapper{resultsCache, statsCache, volumeCache}
+
+	var accelerator *QueryAccelerator
+	if cfg.QueryAcceleration.Enabled {
+		if cfg.QueryAcceleration.StoreClient == nil {
+			return nil, nil, errors.New("query acceleration store client is not set")
+		}
+		accelerator = NewQueryAccelerator(cfg.QueryAcceleration, cfg.QueryAcceleration.StoreClient, limits, codec, cacheGenNumLoader, log, metrics.QueryAccelerationMetrics)
+		stoppers = append(stoppers, accelerator)
+	}
+
 	metricsTripperware, err := NewMetricTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache,
-		cacheGenNumLoader, retentionEnabled, PrometheusExtractor{}, metrics, indexStatsTripperware, metricsNamespace)
+		cacheGenNumLoader, retentionEnabled, PrometheusExtractor{}, metrics, indexStatsTripperware, costBudgets, accelerator, metricsNamespace)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	limitedTripperware, err := NewLimitedTripperware(cfg, engineOpts, log, limits, schema, metrics, indexStatsTripperware, codec, iqo)
+	limitedTripperware, err := NewLimitedTripperware(cfg, engineOpts, log, limits, schema, metrics, indexStatsTripperware, costBudgets, codec, iqo)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// NOTE: When we would start caching response from non-metric queries we would have to consider cache gen headers as well in
 	// MergeResponse implementation for Loki codecs same as it is done in Cortex at https://github.com/cortexproject/cortex/blob/21bad57b346c730d684d6d0205efef133422ab28/pkg/querier/queryrange/query_range.go#L170
-	logFilterTripperware, err := NewLogFilterTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache, metrics, indexStatsTripperware, metricsNamespace)
+	logFilterTripperware, err := NewLogFilterTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache, metrics, indexStatsTripperware, costBudgets, metricsNamespace)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -225,7 +248,7 @@ func NewMiddleware(
 		return nil, nil, err
 	}
 
-	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, engineOpts, log, limits, schema, metrics, codec, instantMetricCache, cacheGenNumLoader, retentionEnabled, indexStatsTripperware, metricsNamespace)
+	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, engineOpts, log, limits, schema, metrics, codec, instantMetricCache, cacheGenNumLoader, retentionEnabled, indexStatsTripperware, costBudgets, metricsNamespace)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -251,6 +274,8 @@ func NewMiddleware(
 	}
 
 	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
+		next = explainDryRunMiddleware().Wrap(next)
+
 		var (
 			metricRT         = metricsTripperware.Wrap(next)
 			limitedRT        = limitedTripperware.Wrap(next)
@@ -264,8 +289,12 @@ func NewMiddleware(
 			detectedLabelsRT = next // TODO(shantanu): add middlewares
 		)
 
-		return newRoundTripper(log, next, limitedRT, logFilterRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
-	}), StopperWrapper{resultsCache, statsCache, volumeCache}, nil
+		rt := newRoundTripper(log, next, limitedRT, logFilterRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
+		return base.MergeMiddlewares(
+			NewTenantFederationMiddleware(limits, DefaultCodec),
+			NewQueryPolicyMiddleware(log, limits, statsRT, engineOpts.MaxLookBackPeriod, metrics.QueryPolicyMetrics),
+		).Wrap(rt)
+	}), stoppers, nil
 }
 
 type roundTripper struct {
@@ -467,7 +496,7 @@ func getOperation(path string) string {
 }
 
 // NewLogFilterTripperware creates a new frontend tripperware responsible for handling log requests.
-func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, metrics *Metrics, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
+func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, metrics *Metrics, indexStatsTripperware base.Middleware, costBudgets *QueryCostBudgets, metricsNamespace string) (base.Middleware, error) {
 	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
 		statsHandler := indexStatsTripperware.Wrap(next)
 
@@ -476,6 +505,7 @@ func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Lo
 			StatsCollectorMiddleware(),
 			NewLimitsMiddleware(limits),
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryCostMiddleware(schema.Configs, engineOpts, log, limits, costBudgets, metrics.QueryCostMetrics, statsHandler),
 			base.InstrumentMiddleware("split_by_interval", metrics.InstrumentMiddlewareMetrics),
 			SplitByIntervalMiddleware(schema.Configs, limits, merger, newDefaultSplitter(limits, iqo), metrics.SplitByMetrics),
 		}
@@ -485,9 +515,7 @@ func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Lo
 				log,
 				limits,
 				c,
-				func(_ context.Context, r base.Request) bool {
-					return !r.GetCachingOptions().Disabled
-				},
+				shouldCacheRequest,
 				cfg.Transformer,
 				metrics.LogResultCacheMetrics,
 			)
@@ -532,7 +560,7 @@ func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Lo
 }
 
 // NewLimitedTripperware creates a new frontend tripperware responsible for handling log requests which are label matcher only, no filter expression.
-func NewLimitedTripperware(_ Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, metrics *Metrics, indexStatsTripperware base.Middleware, merger base.Merger, iqo util.IngesterQueryOptions) (base.Middleware, error) {
+func NewLimitedTripperware(_ Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, metrics *Metrics, indexStatsTripperware base.Middleware, costBudgets *QueryCostBudgets, merger base.Merger, iqo util.IngesterQueryOptions) (base.Middleware, error) {
 	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
 		statsHandler := indexStatsTripperware.Wrap(next)
 
@@ -540,6 +568,7 @@ func NewLimitedTripperware(_ Config, engineOpts logql.EngineOpts, log log.Logger
 			StatsCollectorMiddleware(),
 			NewLimitsMiddleware(limits),
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryCostMiddleware(schema.Configs, engineOpts, log, limits, costBudgets, metrics.QueryCostMetrics, statsHandler),
 			base.InstrumentMiddleware("split_by_interval", metrics.InstrumentMiddlewareMetrics),
 			SplitByIntervalMiddleware(schema.Configs, WithMaxParallelism(limits, limitedQuerySplits), merger, newDefaultSplitter(limits, iqo), metrics.SplitByMetrics),
 			NewQuerierSizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
@@ -575,9 +604,7 @@ func NewSeriesTripperware(
 			merger,
 			c,
 			cacheGenNumLoader,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -660,9 +687,7 @@ func NewLabelsTripperware(
 			merger,
 			c,
 			cacheGenNumLoader,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -711,7 +736,7 @@ func NewLabelsTripperware(
 }
 
 // NewMetricTripperware creates a new frontend tripperware responsible for handling metric queries
-func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, cacheGenNumLoader base.CacheGenNumberLoader, retentionEnabled bool, extractor base.Extractor, metrics *Metrics, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
+func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, cacheGenNumLoader base.CacheGenNumberLoader, retentionEnabled bool, extractor base.Extractor, metrics *Metrics, indexStatsTripperware base.Middleware, costBudgets *QueryCostBudgets, accelerator *QueryAccelerator, metricsNamespace string) (base.Middleware, error) {
 	cacheKey := cacheKeyLimits{limits, cfg.Transformer, iqo}
 	var queryCacheMiddleware base.Middleware
 	if cfg.CacheResults {
@@ -724,9 +749,7 @@ func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logge
 			merger,
 			extractor,
 			cacheGenNumLoader,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -763,9 +786,18 @@ func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logge
 			)
 		}
 
+		if accelerator != nil {
+			queryRangeMiddleware = append(
+				queryRangeMiddleware,
+				base.InstrumentMiddleware("query_acceleration", metrics.InstrumentMiddlewareMetrics),
+				accelerator,
+			)
+		}
+
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryCostMiddleware(schema.Configs, engineOpts, log, limits, costBudgets, metrics.QueryCostMetrics, statsHandler),
 			base.InstrumentMiddleware("split_by_interval", metrics.InstrumentMiddlewareMetrics),
 			SplitByIntervalMiddleware(schema.Configs, limits, merger, newMetricQuerySplitter(limits, iqo), metrics.SplitByMetrics),
 		)
@@ -835,6 +867,7 @@ func NewInstantMetricTripperware(
 	cacheGenNumLoader base.CacheGenNumberLoader,
 	retentionEnabled bool,
 	indexStatsTripperware base.Middleware,
+	costBudgets *QueryCostBudgets,
 	metricsNamespace string,
 ) (base.Middleware, error) {
 	var cacheMiddleware base.Middleware
@@ -846,9 +879,7 @@ func NewInstantMetricTripperware(
 			merger,
 			c,
 			cacheGenNumLoader,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -875,6 +906,7 @@ func NewInstantMetricTripperware(
 			StatsCollectorMiddleware(),
 			NewLimitsMiddleware(limits),
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryCostMiddleware(schema.Configs, engineOpts, log, limits, costBudgets, metrics.QueryCostMetrics, statsHandler),
 			NewSplitByRangeMiddleware(log, engineOpts, limits, cfg.InstantMetricQuerySplitAlign, metrics.MiddlewareMapperMetrics.rangeMapper),
 		}
 
@@ -931,9 +963,7 @@ func NewVolumeTripperware(cfg Config, log log.Logger, limits Limits, schema conf
 			c,
 			cacheGenNumLoader,
 			iqo,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -1038,9 +1068,7 @@ func NewIndexStatsTripperware(cfg Config, log log.Logger, limits Limits, schema
 			c,
 			cacheGenNumLoader,
 			iqo,
-			func(_ context.Context, r base.Request) bool {
-				return !r.GetCachingOptions().Disabled
-			},
+			shouldCacheRequest,
 			func(ctx context.Context, tenantIDs []string, r base.Request) int {
 				return MinWeightedParallelism(
 					ctx,
@@ -1398,6 +1398,10 @@ type fakeLimits struct {
 	requiredNumberLabels        int
 	maxQueryBytesRead           int
 	maxQuerierBytesRead         int
+	queryCostBudget             float64
+	queryCostBudgetAction       string
+	queryPolicies               []*validation.QueryPolicy
+	tenantFederations           map[string][]*validation.TenantFederationMember
 	maxStatsCacheFreshness      time.Duration
 	maxMetadataCacheFreshness   time.Duration
 	volumeEnabled               bool
@@ -1492,6 +1496,18 @@ func (f fakeLimits) MaxQuerierBytesRead(context.Context, string) int {
 	return f.maxQuerierBytesRead
 }
 
+func (f fakeLimits) QueryCostBudget(string) float64 {
+	return f.queryCostBudget
+}
+
+func (f fakeLimits) QueryCostBudgetWindow(string) time.Duration {
+	return time.Hour
+}
+
+func (f fakeLimits) QueryCostBudgetAction(string) string {
+	return f.queryCostBudgetAction
+}
+
 func (f fakeLimits) QueryTimeout(context.Context, string) time.Duration {
 	return f.queryTimeout
 }
@@ -1500,6 +1516,14 @@ func (f fakeLimits) BlockedQueries(context.Context, string) []*validation.Blocke
 	return []*validation.BlockedQuery{}
 }
 
+func (f fakeLimits) QueryPolicies(context.Context, string) []*validation.QueryPolicy {
+	return f.queryPolicies
+}
+
+func (f fakeLimits) TenantFederation(_ context.Context, userID string) []*validation.TenantFederationMember {
+	return f.tenantFederations[userID]
+}
+
 func (f fakeLimits) RequiredLabels(context.Context, string) []string {
 	return f.requiredLabels
 }
@@ -215,6 +215,14 @@ func (r *dynamicShardResolver) Shards(e syntax.Expr) (int, uint64, error) {
 			"bytes_per_shard", strings.Replace(humanize.Bytes(bytesPerShard), " ", "", 1),
 		)...,
 	)
+	if node, ok := explainNodeFromContext(ctx); ok {
+		node.addShardFactor(ExplainShardFactor{
+			Expression:    e.String(),
+			Factor:        factor,
+			Bytes:         combined.Bytes,
+			BytesPerShard: bytesPerShard,
+		})
+	}
 	return factor, bytesPerShard, nil
 }
 
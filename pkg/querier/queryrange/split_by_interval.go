@@ -120,6 +120,10 @@ func (h *splitByInterval) Process(
 		p = len(input)
 	}
 
+	// The entries of streamed log queries are passed on in order as soon as
+	// they are received, instead of being merged with the other responses.
+	sink, streaming := streamSinkFromContext(ctx)
+
 	// per request wrapped handler for limiting the amount of series.
 	next := newSeriesLimiter(maxSeries).Wrap(h.next)
 	for i := 0; i < p; i++ {
@@ -135,10 +139,18 @@ func (h *splitByInterval) Process(
 				return nil, data.err
 			}
 
-			responses = append(responses, data.resp)
+			resp := data.resp
+			casted, ok := data.resp.(*LokiResponse)
+			if ok && streaming {
+				var err error
+				if resp, err = streamLokiResponse(casted, threshold, sink); err != nil {
+					return nil, err
+				}
+			}
+			responses = append(responses, resp)
 
 			// see if we can exit early if a limit has been reached
-			if casted, ok := data.resp.(*LokiResponse); !unlimited && ok {
+			if !unlimited && ok {
 				threshold -= casted.Count()
 
 				if threshold <= 0 {
@@ -159,7 +171,7 @@ func (h *splitByInterval) loop(ctx context.Context, ch <-chan *lokiResult, next
 		sp, ctx := opentracing.StartSpanFromContext(ctx, "interval")
 		data.req.LogToSpan(sp)
 
-		resp, err := next.Do(ctx, data.req)
+		resp, err := explainDo(ctx, ExplainOpSplit, data.req, next)
 		sp.Finish()
 
 		select {
@@ -50,6 +50,10 @@ func NewSplitByRangeMiddleware(logger log.Logger, engineOpts logql.EngineOpts, l
 }
 
 func (s *splitByRange) Do(ctx context.Context, request queryrangebase.Request) (queryrangebase.Response, error) {
+	return explainDo(ctx, ExplainOpRangeMapping, request, queryrangebase.HandlerFunc(s.do))
+}
+
+func (s *splitByRange) do(ctx context.Context, request queryrangebase.Request) (queryrangebase.Response, error) {
 	logger := util_log.WithContext(ctx, s.logger)
 
 	params, err := ParamsFromRequest(request)
@@ -97,6 +101,9 @@ func (s *splitByRange) Do(ctx context.Context, request queryrangebase.Request) (
 		// the query cannot be split, so continue
 		return s.next.Do(ctx, request)
 	}
+	if node, ok := explainNodeFromContext(ctx); ok {
+		node.setMappedQuery(parsed.String())
+	}
 
 	// Update middleware stats
 	queryStatsCtx := stats.FromContext(ctx)
@@ -104,10 +111,12 @@ func (s *splitByRange) Do(ctx context.Context, request queryrangebase.Request) (
 
 	query := s.ng.Query(ctx, logql.ParamsWithExpressionOverride{Params: params, ExpressionOverride: parsed})
 
+	ctx, explained := explainEvaluator(ctx)
 	res, err := query.Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
+	explained()
 
 	value, err := marshal.NewResultValue(res.Data)
 	if err != nil {
@@ -0,0 +1,198 @@
+package queryrange
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	"github.com/grafana/loki/v3/pkg/util/marshal"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// streamSink receives the entries of a streamed log query, in the direction
+// of the query.
+type streamSink func(streams []logproto.Stream) error
+
+type streamSinkContextKey struct{}
+
+func withStreamSink(ctx context.Context, sink streamSink) context.Context {
+	return context.WithValue(ctx, streamSinkContextKey{}, sink)
+}
+
+// withoutStreamSink returns a context in which the responses are not streamed.
+func withoutStreamSink(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamSinkContextKey{}, nil)
+}
+
+func streamSinkFromContext(ctx context.Context) (streamSink, bool) {
+	sink, ok := ctx.Value(streamSinkContextKey{}).(streamSink)
+	return sink, ok
+}
+
+// streamLokiResponse passes the entries of the response to the sink, at most
+// limit of them unless the limit is 0, and returns the response without its
+// entries so they are not held until all responses are merged.
+func streamLokiResponse(resp *LokiResponse, limit int64, sink streamSink) (*LokiResponse, error) {
+	streams := resp.Data.Result
+	if limit > 0 && resp.Count() > limit {
+		streams = mergeOrderedNonOverlappingStreams([]*LokiResponse{resp}, uint32(limit), resp.Direction)
+	}
+	if len(streams) > 0 {
+		if err := sink(streams); err != nil {
+			return nil, err
+		}
+	}
+
+	streamed := *resp
+	streamed.Data.Result = nil
+	return &streamed, nil
+}
+
+type streamingQueryHandler struct {
+	next      queryrangebase.Handler
+	fallback  http.Handler
+	streaming http.Handler
+}
+
+// NewStreamingQueryHandler returns a handler streaming the entries of log
+// range queries to clients accepting NDJSON responses. The entries of a split
+// query are written as soon as the splits before it are written, instead of
+// once all splits are merged. All other requests are handled by fallback.
+// The streamed requests are served through instrument, which reports them like
+// fallback does.
+func NewStreamingQueryHandler(next queryrangebase.Handler, fallback http.Handler, instrument middleware.Interface) http.Handler {
+	h := &streamingQueryHandler{
+		next:     next,
+		fallback: fallback,
+	}
+	h.streaming = instrument.Wrap(http.HandlerFunc(h.serveStreaming))
+	return h
+}
+
+func (h *streamingQueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !acceptsNDJSON(r.Header.Values("Accept")) {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+	h.streaming.ServeHTTP(w, r)
+}
+
+// acceptsNDJSON returns true if the Accept header of the request lists NDJSON,
+// with a quality not lower than JSON's.
+func acceptsNDJSON(accept []string) bool {
+	var ndjsonQ, jsonQ float64
+	for _, values := range accept {
+		for _, value := range strings.Split(values, ",") {
+			mediaType, params, err := mime.ParseMediaType(value)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case loghttp.NDJSONContentType:
+				ndjsonQ = max(ndjsonQ, q)
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			}
+		}
+	}
+	return ndjsonQ > 0 && ndjsonQ >= jsonQ
+}
+
+func (h *streamingQueryHandler) serveStreaming(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req, err := DefaultCodec.DecodeRequest(ctx, r, nil)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	encodeFlags := httpreq.ExtractEncodingFlags(r)
+
+	// Only log queries are streamed, metric queries are answered as usual.
+	lokiReq, ok := req.(*LokiRequest)
+	if !ok || lokiReq.Plan == nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid request type %T", req), w)
+		return
+	}
+	if _, ok := lokiReq.Plan.AST.(syntax.LogSelectorExpr); !ok {
+		resp, err := h.next.Do(ctx, req)
+		if err != nil {
+			serverutil.WriteError(err, w)
+			return
+		}
+		w.Header().Set("Content-Type", JSONType)
+		setQueryCostHeaders(w.Header(), resp)
+		if err := encodeResponseJSONTo(loghttp.GetVersion(r.RequestURI), resp, w, encodeFlags); err != nil {
+			serverutil.WriteError(err, w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", loghttp.NDJSONContentType)
+	flusher, _ := w.(http.Flusher)
+	var written bool
+	write := func(streams []logproto.Stream) error {
+		if err := marshal.WriteStreamsFrameJSON(logqlmodel.Streams(streams), w, encodeFlags); err != nil {
+			return err
+		}
+		written = true
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	resp, err := h.next.Do(withStreamSink(ctx, write), req)
+	if err == nil {
+		err = h.writeResponse(resp, write, w)
+	}
+	if err == nil {
+		return
+	}
+	if !written {
+		serverutil.WriteError(err, w)
+		return
+	}
+	// The status code has been sent with the first frame, so the error is
+	// sent as the final frame.
+	_, cerr := serverutil.ClientHTTPStatusAndError(err)
+	_ = marshal.WriteQueryResponseFrameJSON(loghttp.QueryResponseFrame{
+		Status: "error",
+		Error:  cerr.Error(),
+	}, w)
+}
+
+// writeResponse writes the entries of the response which have not been
+// streamed yet, followed by the final frame.
+func (h *streamingQueryHandler) writeResponse(resp queryrangebase.Response, write streamSink, w http.ResponseWriter) error {
+	lokiResp, ok := resp.(*LokiResponse)
+	if !ok {
+		return httpgrpc.Errorf(http.StatusInternalServerError, "invalid response type %T", resp)
+	}
+	if len(lokiResp.Data.Result) > 0 {
+		if err := write(lokiResp.Data.Result); err != nil {
+			return err
+		}
+	}
+	return marshal.WriteQueryResponseFrameJSON(loghttp.QueryResponseFrame{
+		Status:   loghttp.QueryStatusSuccess,
+		Warnings: lokiResp.Warnings,
+		Stats:    lokiResp.Statistics,
+	}, w)
+}
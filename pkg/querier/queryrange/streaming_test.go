@@ -0,0 +1,183 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/middleware"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+func TestStreamingQueryHandler(t *testing.T) {
+	// Every split returns an entry at its start for one stream and a second
+	// later for another one.
+	var failAt time.Time
+	next := queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		req := r.(*LokiRequest)
+		if req.StartTs.Equal(failAt) {
+			return nil, errors.New("querier unavailable")
+		}
+		entry := func(ts time.Time) []logproto.Entry {
+			return []logproto.Entry{{Timestamp: ts, Line: fmt.Sprint(ts.Unix())}}
+		}
+		return &LokiResponse{
+			Status:    loghttp.QueryStatusSuccess,
+			Direction: req.Direction,
+			Limit:     req.Limit,
+			Version:   uint32(loghttp.VersionV1),
+			Data: LokiData{
+				ResultType: loghttp.ResultTypeStream,
+				Result: []logproto.Stream{
+					{Labels: `{foo="bar", level="debug"}`, Entries: entry(req.StartTs)},
+					{Labels: `{foo="bar", level="info"}`, Entries: entry(req.StartTs.Add(time.Second))},
+				},
+			},
+		}, nil
+	})
+	split := SplitByIntervalMiddleware(
+		testSchemas,
+		WithSplitByLimits(fakeLimits{maxQueryParallelism: 1}, time.Hour),
+		DefaultCodec,
+		newDefaultSplitter(fakeLimits{}, nil),
+		nilMetrics,
+	).Wrap(next)
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "fallback")
+	})
+	var instrumented int
+	instrument := middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			instrumented++
+			next.ServeHTTP(w, r)
+		})
+	})
+	h := NewStreamingQueryHandler(split, fallback, instrument)
+
+	do := func(query string, accept string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"query":     []string{query},
+			"start":     []string{"0"},
+			"end":       []string{fmt.Sprint((4 * time.Hour).Nanoseconds())},
+			"limit":     []string{"5"},
+			"direction": []string{"backward"},
+		}
+		req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range?"+form.Encode(), nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	decodeFrames := func(t *testing.T, body io.Reader) []loghttp.QueryResponseFrame {
+		var frames []loghttp.QueryResponseFrame
+		dec := json.NewDecoder(body)
+		for dec.More() {
+			var frame loghttp.QueryResponseFrame
+			require.NoError(t, dec.Decode(&frame))
+			frames = append(frames, frame)
+		}
+		return frames
+	}
+	lines := func(frame loghttp.QueryResponseFrame) []string {
+		var lines []string
+		for _, s := range frame.Streams {
+			for _, e := range s.Entries {
+				lines = append(lines, e.Line)
+			}
+		}
+		return lines
+	}
+
+	t.Run("splits are streamed in order", func(t *testing.T) {
+		rec := do(`{foo="bar"}`, loghttp.NDJSONContentType)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, loghttp.NDJSONContentType, rec.Header().Get("Content-Type"))
+
+		frames := decodeFrames(t, rec.Body)
+		require.Len(t, frames, 4)
+		require.ElementsMatch(t, []string{"10800", "10801"}, lines(frames[0]))
+		require.ElementsMatch(t, []string{"7200", "7201"}, lines(frames[1]))
+		// The limit is reached within the third split, only its latest entry is kept.
+		require.Equal(t, []string{"3601"}, lines(frames[2]))
+		require.Equal(t, loghttp.QueryStatusSuccess, frames[3].Status)
+		require.Empty(t, frames[3].Streams)
+		require.Equal(t, int64(3), frames[3].Stats.Summary.Splits)
+		require.Equal(t, 1, instrumented)
+	})
+
+	t.Run("accept header lists media types", func(t *testing.T) {
+		rec := do(`{foo="bar"}`, "application/json;q=0.5, application/x-ndjson")
+		require.Equal(t, loghttp.NDJSONContentType, rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("errors after the first frame", func(t *testing.T) {
+		failAt = time.Unix(0, 0).Add(2 * time.Hour).UTC()
+		defer func() { failAt = time.Time{} }()
+
+		rec := do(`{foo="bar"}`, loghttp.NDJSONContentType)
+		require.Equal(t, http.StatusOK, rec.Code)
+		frames := decodeFrames(t, rec.Body)
+		require.Len(t, frames, 2)
+		require.ElementsMatch(t, []string{"10800", "10801"}, lines(frames[0]))
+		require.Equal(t, loghttp.QueryResponseFrame{Status: "error", Error: "querier unavailable"}, frames[1])
+	})
+
+	t.Run("errors before the first frame", func(t *testing.T) {
+		failAt = time.Unix(0, 0).Add(3 * time.Hour).UTC()
+		defer func() { failAt = time.Time{} }()
+
+		rec := do(`{foo="bar"}`, loghttp.NDJSONContentType)
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Equal(t, "querier unavailable", rec.Body.String())
+	})
+
+	t.Run("metric queries are not streamed", func(t *testing.T) {
+		rec := do(`rate({foo="bar"}[1m])`, loghttp.NDJSONContentType)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, JSONType, rec.Header().Get("Content-Type"))
+
+		var resp loghttp.QueryResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Equal(t, loghttp.QueryStatusSuccess, resp.Status)
+	})
+
+	t.Run("other clients are handled by the fallback", func(t *testing.T) {
+		rec := do(`{foo="bar"}`, "")
+		require.Equal(t, "fallback", rec.Body.String())
+	})
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	for _, tc := range []struct {
+		accept []string
+		want   bool
+	}{
+		{accept: nil},
+		{accept: []string{"*/*"}},
+		{accept: []string{"application/json"}},
+		{accept: []string{"application/x-ndjson"}, want: true},
+		{accept: []string{"application/x-ndjson; charset=utf-8"}, want: true},
+		{accept: []string{"application/json, application/x-ndjson"}, want: true},
+		{accept: []string{"application/json", "application/x-ndjson;q=0.9"}},
+		{accept: []string{"application/x-ndjson;q=0.9, application/json;q=0.8"}, want: true},
+		{accept: []string{"application/x-ndjson;q=0"}},
+		{accept: []string{"application/x-ndjson;q=invalid"}},
+	} {
+		require.Equal(t, tc.want, acceptsNDJSON(tc.accept), tc.accept)
+	}
+}
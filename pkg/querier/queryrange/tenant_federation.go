@@ -0,0 +1,483 @@
+package queryrange
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+// tenantLabel is the label identifying the member of a tenant federation in
+// the results of its queries, as added by the multi-tenant querier.
+const tenantLabel = "__tenant_id__"
+
+// TenantFederationLimits are the limits needed to resolve tenant federations.
+type TenantFederationLimits interface {
+	TenantFederation(context.Context, string) []*validation.TenantFederationMember
+}
+
+type tenantFederationMiddleware struct {
+	limits TenantFederationLimits
+	merger queryrangebase.Merger
+	next   queryrangebase.Handler
+}
+
+// NewTenantFederationMiddleware creates a new Middleware executing the
+// requests of the virtual tenant of a tenant federation for each of its
+// members. The request of a member is handled by the next handler with the
+// member as tenant, so that the limits, cache keys and shard counts of the
+// member apply to it, and the responses of the members are merged.
+func NewTenantFederationMiddleware(limits TenantFederationLimits, merger queryrangebase.Merger) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &tenantFederationMiddleware{
+			limits: limits,
+			merger: merger,
+			next:   next,
+		}
+	})
+}
+
+func (f *tenantFederationMiddleware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil || len(tenantIDs) != 1 {
+		return f.next.Do(ctx, r)
+	}
+	federation := tenantIDs[0]
+	members := f.limits.TenantFederation(ctx, federation)
+	if len(members) == 0 {
+		return f.next.Do(ctx, r)
+	}
+
+	permission, err := federatedPermission(r)
+	if err != nil {
+		return nil, err
+	}
+	var aggregation *syntax.VectorAggregationExpr
+	if permission == validation.TenantFederationPermissionMetrics {
+		if aggregation, err = federatedAggregation(syntax.MustParseExpr(r.GetQuery()).(syntax.SampleExpr)); err != nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "tenant federation %q: %s", federation, err.Error())
+		}
+	}
+
+	var (
+		allowed  []*validation.TenantFederationMember
+		warnings []string
+	)
+	for _, m := range members {
+		if !m.Allows(permission) {
+			warnings = append(warnings, fmt.Sprintf("tenant federation %q: member %q does not allow %s queries", federation, m.Tenant, permission))
+			continue
+		}
+		allowed = append(allowed, m)
+	}
+	if len(allowed) == 0 {
+		return nil, httpgrpc.Errorf(http.StatusForbidden, "no member of tenant federation %q allows %s queries", federation, permission)
+	}
+
+	// The values of the tenant label are the members themselves.
+	if req, ok := r.(*LabelRequest); ok && req.Values && req.Name == tenantLabel {
+		values := make([]string, 0, len(allowed))
+		for _, m := range allowed {
+			values = append(values, m.Tenant)
+		}
+		return &LokiLabelNamesResponse{
+			Status:  loghttp.QueryStatusSuccess,
+			Version: uint32(loghttp.GetVersion(req.Path())),
+			Data:    values,
+		}, nil
+	}
+
+	// The members are queried concurrently, so their entries can't be
+	// streamed in order.
+	ctx = withoutStreamSink(ctx)
+
+	resps := make([]queryrangebase.Response, len(allowed))
+	err = concurrency.ForEachJob(ctx, len(allowed), len(allowed), func(ctx context.Context, i int) error {
+		m := allowed[i]
+		req, err := withLabelFilter(r, m.LabelFilter)
+		if err != nil {
+			return err
+		}
+		resp, err := f.next.Do(user.InjectOrgID(ctx, m.Tenant), req)
+		if err != nil {
+			return err
+		}
+		resps[i], err = withTenantLabel(resp, m.Tenant)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var merged queryrangebase.Response
+	if _, ok := resps[0].(*LokiResponse); ok {
+		merged = mergeFederatedStreams(resps)
+	} else if merged, err = f.merger.MergeResponse(resps...); err != nil {
+		return nil, err
+	}
+	switch res := merged.(type) {
+	case *LokiResponse:
+		res.Warnings = append(res.Warnings, warnings...)
+	case *LokiPromResponse:
+		if res.Response != nil {
+			if aggregation != nil {
+				res.Response.Data.Result = reaggregateFederatedSeries(aggregation, res.Response.Data.Result)
+			}
+			res.Response.Warnings = append(res.Response.Warnings, warnings...)
+		}
+	case *LokiLabelNamesResponse:
+		if req, ok := r.(*LabelRequest); ok && !req.Values {
+			res.Data = append(res.Data, tenantLabel)
+		}
+	}
+	return merged, nil
+}
+
+// mergeFederatedStreams merges the log responses of the members. Unlike the
+// responses of the splits of a query, the entries of the members overlap in
+// time: all of them are merged by timestamp in the direction of the query
+// before the limit is applied.
+func mergeFederatedStreams(resps []queryrangebase.Response) *LokiResponse {
+	var (
+		all     LokiResponse
+		without = make([]queryrangebase.Response, 0, len(resps))
+	)
+	for _, resp := range resps {
+		res := *resp.(*LokiResponse)
+		all.Data.Result = append(all.Data.Result, res.Data.Result...)
+		res.Data.Result = nil
+		without = append(without, &res)
+	}
+	merged := mergeLokiResponse(without...)
+	// The streams of different members don't share labels, they are only
+	// merged by the heap picking the entries up to the limit.
+	merged.Data.Result = mergeOrderedNonOverlappingStreams([]*LokiResponse{&all}, merged.Limit, merged.Direction)
+	return merged
+}
+
+// federatedAggregation returns the vector aggregation whose results have to be
+// aggregated again across the members of a tenant federation, nil if the
+// series of the members are distinct. An error is returned if the results of
+// the members can't be merged.
+func federatedAggregation(expr syntax.SampleExpr) (*syntax.VectorAggregationExpr, error) {
+	switch e := expr.(type) {
+	case *syntax.RangeAggregationExpr:
+		if keepsTenantLabel(e.Grouping) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s grouped without the %s label can't be merged across members", e.Operation, tenantLabel)
+	case *syntax.VectorAggregationExpr:
+		inner, err := federatedAggregation(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		if inner != nil {
+			return nil, fmt.Errorf("nested aggregations grouped without the %s label can't be merged across members", tenantLabel)
+		}
+		if keepsTenantLabel(e.Grouping) {
+			return nil, nil
+		}
+		switch e.Operation {
+		case syntax.OpTypeSum, syntax.OpTypeCount, syntax.OpTypeMin, syntax.OpTypeMax, syntax.OpTypeTopK, syntax.OpTypeBottomK:
+			return e, nil
+		}
+		return nil, fmt.Errorf("%s grouped without the %s label can't be merged across members", e.Operation, tenantLabel)
+	default:
+		return nil, fmt.Errorf("only range and vector aggregations can be merged across members")
+	}
+}
+
+// keepsTenantLabel returns true if the series grouped by the grouping keep
+// the tenant label.
+func keepsTenantLabel(g *syntax.Grouping) bool {
+	if g == nil {
+		return true
+	}
+	for _, l := range g.Groups {
+		if l == tenantLabel {
+			return !g.Without
+		}
+	}
+	return g.Without
+}
+
+// reaggregateFederatedSeries aggregates the series of the members like the
+// aggregation aggregated the series of each member, so that the result is the
+// one of the aggregation across all of them.
+func reaggregateFederatedSeries(aggregation *syntax.VectorAggregationExpr, series []queryrangebase.SampleStream) []queryrangebase.SampleStream {
+	switch aggregation.Operation {
+	case syntax.OpTypeTopK, syntax.OpTypeBottomK:
+		return federatedTopK(aggregation, series)
+	}
+
+	// The series of the members only differ by their tenant label.
+	type aggregated struct {
+		labels  labels.Labels
+		samples map[int64]float64
+	}
+	groups := map[string]*aggregated{}
+	for _, s := range series {
+		lbls := labels.NewBuilder(logproto.FromLabelAdaptersToLabels(s.Labels)).Del(tenantLabel).Labels()
+		g, ok := groups[lbls.String()]
+		if !ok {
+			g = &aggregated{labels: lbls, samples: map[int64]float64{}}
+			groups[lbls.String()] = g
+		}
+		for _, sample := range s.Samples {
+			v, ok := g.samples[sample.TimestampMs]
+			switch {
+			case !ok:
+				v = sample.Value
+			case aggregation.Operation == syntax.OpTypeMin:
+				v = math.Min(v, sample.Value)
+			case aggregation.Operation == syntax.OpTypeMax:
+				v = math.Max(v, sample.Value)
+			default:
+				// The counts of the members are added up like the sums.
+				v += sample.Value
+			}
+			g.samples[sample.TimestampMs] = v
+		}
+	}
+
+	result := make([]queryrangebase.SampleStream, 0, len(groups))
+	for _, g := range groups {
+		s := queryrangebase.SampleStream{
+			Labels:  logproto.FromLabelsToLabelAdapters(g.labels),
+			Samples: make([]logproto.LegacySample, 0, len(g.samples)),
+		}
+		for ts, v := range g.samples {
+			s.Samples = append(s.Samples, logproto.LegacySample{TimestampMs: ts, Value: v})
+		}
+		sort.Slice(s.Samples, func(i, j int) bool { return s.Samples[i].TimestampMs < s.Samples[j].TimestampMs })
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return labels.Compare(logproto.FromLabelAdaptersToLabels(result[i].Labels), logproto.FromLabelAdaptersToLabels(result[j].Labels)) < 0
+	})
+	return result
+}
+
+// federatedTopK keeps, at every timestamp, the top or bottom k samples of each
+// group across the members. The k samples of every group are among the ones
+// selected for each member.
+func federatedTopK(aggregation *syntax.VectorAggregationExpr, series []queryrangebase.SampleStream) []queryrangebase.SampleStream {
+	type candidate struct {
+		series int
+		value  float64
+	}
+	byGroup := map[int64]map[string][]candidate{}
+	for i, s := range series {
+		lbls := logproto.FromLabelAdaptersToLabels(s.Labels)
+		group := ""
+		if aggregation.Grouping != nil {
+			group = lbls.MatchLabels(!aggregation.Grouping.Without, aggregation.Grouping.Groups...).String()
+		}
+		for _, sample := range s.Samples {
+			groups, ok := byGroup[sample.TimestampMs]
+			if !ok {
+				groups = map[string][]candidate{}
+				byGroup[sample.TimestampMs] = groups
+			}
+			groups[group] = append(groups[group], candidate{series: i, value: sample.Value})
+		}
+	}
+
+	kept := make([][]logproto.LegacySample, len(series))
+	for ts, groups := range byGroup {
+		for _, candidates := range groups {
+			sort.SliceStable(candidates, func(i, j int) bool {
+				if aggregation.Operation == syntax.OpTypeBottomK {
+					return candidates[i].value < candidates[j].value
+				}
+				return candidates[i].value > candidates[j].value
+			})
+			if len(candidates) > aggregation.Params {
+				candidates = candidates[:aggregation.Params]
+			}
+			for _, c := range candidates {
+				kept[c.series] = append(kept[c.series], logproto.LegacySample{TimestampMs: ts, Value: c.value})
+			}
+		}
+	}
+
+	result := make([]queryrangebase.SampleStream, 0, len(series))
+	for i, samples := range kept {
+		if len(samples) == 0 {
+			continue
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].TimestampMs < samples[j].TimestampMs })
+		result = append(result, queryrangebase.SampleStream{Labels: series[i].Labels, Samples: samples})
+	}
+	return result
+}
+
+// federatedPermission returns the permission a member of a tenant federation
+// needs to be read by the request.
+func federatedPermission(r queryrangebase.Request) (string, error) {
+	switch r.(type) {
+	case *LokiRequest, *LokiInstantRequest:
+		expr, err := syntax.ParseExpr(r.GetQuery())
+		if err != nil {
+			return "", httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+		}
+		if _, ok := expr.(syntax.SampleExpr); ok {
+			return validation.TenantFederationPermissionMetrics, nil
+		}
+		return validation.TenantFederationPermissionLogs, nil
+	case *LokiSeriesRequest, *LabelRequest, *logproto.IndexStatsRequest, *logproto.VolumeRequest:
+		return validation.TenantFederationPermissionMetadata, nil
+	default:
+		return "", httpgrpc.Errorf(http.StatusBadRequest, "%T requests are not supported for tenant federations", r)
+	}
+}
+
+// withLabelFilter returns the request with the matchers of the label filter
+// added to all its stream selectors.
+func withLabelFilter(r queryrangebase.Request, filter string) (queryrangebase.Request, error) {
+	if filter == "" {
+		return r, nil
+	}
+	matchers, err := syntax.ParseMatchers(filter, true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req := r.(type) {
+	case *LokiRequest, *LokiInstantRequest:
+		query, err := addSelectorMatchers(r.GetQuery(), matchers)
+		if err != nil {
+			return nil, err
+		}
+		expr, err := syntax.ParseExpr(query)
+		if err != nil {
+			return nil, err
+		}
+		return withQueryExpr(r, expr), nil
+	case *LokiSeriesRequest:
+		clone := *req
+		if len(req.Match) == 0 {
+			clone.Match = []string{(&syntax.MatchersExpr{Mts: matchers}).String()}
+			return &clone, nil
+		}
+		clone.Match = make([]string, 0, len(req.Match))
+		for _, m := range req.Match {
+			selector, err := addSelectorMatchers(m, matchers)
+			if err != nil {
+				return nil, err
+			}
+			clone.Match = append(clone.Match, selector)
+		}
+		return &clone, nil
+	case *LabelRequest:
+		clone := *req
+		if clone.Query, err = addSelectorMatchers(req.Query, matchers); err != nil {
+			return nil, err
+		}
+		return &clone, nil
+	case *logproto.IndexStatsRequest:
+		clone := *req
+		if clone.Matchers, err = addSelectorMatchers(req.Matchers, matchers); err != nil {
+			return nil, err
+		}
+		return &clone, nil
+	case *logproto.VolumeRequest:
+		clone := *req
+		if clone.Matchers, err = addSelectorMatchers(req.Matchers, matchers); err != nil {
+			return nil, err
+		}
+		return &clone, nil
+	}
+	return r, nil
+}
+
+// addSelectorMatchers adds the matchers to all stream selectors of the query.
+// An empty query is the selector of the matchers.
+func addSelectorMatchers(query string, matchers []*labels.Matcher) (string, error) {
+	if query == "" {
+		return (&syntax.MatchersExpr{Mts: matchers}).String(), nil
+	}
+	expr, err := syntax.ParseExpr(query)
+	if err != nil {
+		return "", httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	expr.Walk(func(e syntax.Expr) {
+		if e, ok := e.(*syntax.MatchersExpr); ok {
+			e.Mts = append(e.Mts, matchers...)
+		}
+	})
+	return expr.String(), nil
+}
+
+// withTenantLabel adds the tenant label of the member to the streams and
+// series of its response.
+func withTenantLabel(resp queryrangebase.Response, tenantID string) (queryrangebase.Response, error) {
+	switch res := resp.(type) {
+	case *LokiResponse:
+		for i, s := range res.Data.Result {
+			lbls, err := syntax.ParseLabels(s.Labels)
+			if err != nil {
+				return nil, err
+			}
+			lbls = addTenantLabel(lbls, tenantID)
+			res.Data.Result[i].Labels = lbls.String()
+			res.Data.Result[i].Hash = lbls.Hash()
+		}
+	case *LokiPromResponse:
+		if res.Response == nil {
+			return res, nil
+		}
+		for i, s := range res.Response.Data.Result {
+			lbls := addTenantLabel(logproto.FromLabelAdaptersToLabels(s.Labels), tenantID)
+			res.Response.Data.Result[i].Labels = logproto.FromLabelsToLabelAdapters(lbls)
+		}
+	case *LokiSeriesResponseView:
+		materialized, err := (&MergedSeriesResponseView{responses: []*LokiSeriesResponseView{res}}).Materialize()
+		if err != nil {
+			return nil, err
+		}
+		return withTenantLabel(materialized, tenantID)
+	case *MergedSeriesResponseView:
+		materialized, err := res.Materialize()
+		if err != nil {
+			return nil, err
+		}
+		return withTenantLabel(materialized, tenantID)
+	case *LokiSeriesResponse:
+		for i, s := range res.Data {
+			b := labels.NewScratchBuilder(len(s.Labels) + 1)
+			for _, l := range s.Labels {
+				b.Add(l.Key, l.Value)
+			}
+			b.Sort()
+			res.Data[i].Labels = res.Data[i].Labels[:0]
+			addTenantLabel(b.Labels(), tenantID).Range(func(l labels.Label) {
+				res.Data[i].Labels = append(res.Data[i].Labels, logproto.SeriesIdentifier_LabelsEntry{Key: l.Name, Value: l.Value})
+			})
+		}
+	}
+	return resp, nil
+}
+
+// addTenantLabel adds the tenant label to the labels. A tenant label that is
+// already present is kept with the original_ prefix.
+func addTenantLabel(lbls labels.Labels, tenantID string) labels.Labels {
+	b := labels.NewBuilder(lbls)
+	if original := lbls.Get(tenantLabel); original != "" {
+		b.Set("original_"+tenantLabel, original)
+	}
+	return b.Set(tenantLabel, tenantID).Labels()
+}
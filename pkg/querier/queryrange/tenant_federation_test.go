@@ -0,0 +1,295 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+func TestTenantFederationMiddleware(t *testing.T) {
+	limits := fakeLimits{tenantFederations: map[string][]*validation.TenantFederationMember{
+		"engineering": {
+			{Tenant: "team-a"},
+			{Tenant: "shared", LabelFilter: `{department="engineering"}`},
+			{Tenant: "security", Permissions: []string{validation.TenantFederationPermissionMetrics}},
+		},
+	}}
+
+	var (
+		mtx      sync.Mutex
+		received map[string]string
+	)
+	next := base.HandlerFunc(func(ctx context.Context, r base.Request) (base.Response, error) {
+		id, err := tenant.TenantID(ctx)
+		require.NoError(t, err)
+		query := r.GetQuery()
+		if req, ok := r.(*LokiSeriesRequest); ok {
+			query = req.Match[0]
+		}
+		mtx.Lock()
+		received[id] = query
+		mtx.Unlock()
+
+		switch req := r.(type) {
+		case *LokiRequest:
+			return &LokiResponse{
+				Status:    loghttp.QueryStatusSuccess,
+				Direction: req.Direction,
+				Limit:     req.Limit,
+				Version:   uint32(loghttp.VersionV1),
+				Data: LokiData{
+					ResultType: loghttp.ResultTypeStream,
+					Result: []logproto.Stream{
+						{Labels: `{app="foo"}`, Entries: []logproto.Entry{{Timestamp: testTime, Line: id}}},
+					},
+				},
+			}, nil
+		case *LokiSeriesRequest:
+			return &LokiSeriesResponse{
+				Status:  loghttp.QueryStatusSuccess,
+				Version: uint32(loghttp.VersionV1),
+				Data:    []logproto.SeriesIdentifier{{Labels: []logproto.SeriesIdentifier_LabelsEntry{{Key: "app", Value: "foo"}, {Key: tenantLabel, Value: "x"}}}},
+			}, nil
+		case *LabelRequest:
+			return &LokiLabelNamesResponse{
+				Status:  loghttp.QueryStatusSuccess,
+				Version: uint32(loghttp.VersionV1),
+				Data:    []string{"app"},
+			}, nil
+		}
+		return nil, nil
+	})
+	h := NewTenantFederationMiddleware(limits, DefaultCodec).Wrap(next)
+	ctx := user.InjectOrgID(context.Background(), "engineering")
+	logRequest := func(query string) *LokiRequest {
+		return &LokiRequest{
+			Query:     query,
+			StartTs:   testTime.Add(-time.Hour),
+			EndTs:     testTime,
+			Limit:     100,
+			Direction: logproto.BACKWARD,
+			Path:      "/loki/api/v1/query_range",
+			Plan:      &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+		}
+	}
+
+	t.Run("log query", func(t *testing.T) {
+		received = map[string]string{}
+		resp, err := h.Do(ctx, logRequest(`{app="foo"} |= "error"`))
+		require.NoError(t, err)
+
+		require.Equal(t, map[string]string{
+			"team-a": `{app="foo"} |= "error"`,
+			"shared": `{app="foo", department="engineering"} |= "error"`,
+		}, received)
+
+		res := resp.(*LokiResponse)
+		var streams []string
+		for _, s := range res.Data.Result {
+			streams = append(streams, s.Labels)
+			require.Len(t, s.Entries, 1)
+		}
+		sort.Strings(streams)
+		require.Equal(t, []string{`{__tenant_id__="shared", app="foo"}`, `{__tenant_id__="team-a", app="foo"}`}, streams)
+		require.Equal(t, []string{`tenant federation "engineering": member "security" does not allow logs queries`}, res.Warnings)
+	})
+
+	t.Run("series", func(t *testing.T) {
+		received = map[string]string{}
+		resp, err := h.Do(ctx, &LokiSeriesRequest{Match: []string{`{app="foo"}`}, StartTs: testTime.Add(-time.Hour), EndTs: testTime, Path: "/loki/api/v1/series"})
+		require.NoError(t, err)
+		require.Equal(t, `{app="foo", department="engineering"}`, received["shared"])
+
+		var series []string
+		for _, s := range resp.(*LokiSeriesResponse).Data {
+			b := labels.NewScratchBuilder(len(s.Labels))
+			for _, l := range s.Labels {
+				b.Add(l.Key, l.Value)
+			}
+			series = append(series, b.Labels().String())
+		}
+		sort.Strings(series)
+		require.Equal(t, []string{
+			`{__tenant_id__="shared", app="foo", original___tenant_id__="x"}`,
+			`{__tenant_id__="team-a", app="foo", original___tenant_id__="x"}`,
+		}, series)
+	})
+
+	t.Run("label names", func(t *testing.T) {
+		received = map[string]string{}
+		resp, err := h.Do(ctx, NewLabelRequest(testTime.Add(-time.Hour), testTime, "", "", "/loki/api/v1/labels"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"app", tenantLabel}, resp.(*LokiLabelNamesResponse).Data)
+		require.Equal(t, `{department="engineering"}`, received["shared"])
+	})
+
+	t.Run("tenant label values", func(t *testing.T) {
+		received = map[string]string{}
+		resp, err := h.Do(ctx, NewLabelRequest(testTime.Add(-time.Hour), testTime, "", tenantLabel, "/loki/api/v1/label/__tenant_id__/values"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"team-a", "shared"}, resp.(*LokiLabelNamesResponse).Data)
+		require.Empty(t, received)
+	})
+
+	t.Run("no member allows the query", func(t *testing.T) {
+		limits := fakeLimits{tenantFederations: map[string][]*validation.TenantFederationMember{
+			"engineering": {{Tenant: "security", Permissions: []string{validation.TenantFederationPermissionMetrics}}},
+		}}
+		_, err := NewTenantFederationMiddleware(limits, DefaultCodec).Wrap(next).Do(ctx, logRequest(`{app="foo"}`))
+		res, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, http.StatusForbidden, int(res.Code))
+	})
+
+	t.Run("other tenants", func(t *testing.T) {
+		received = map[string]string{}
+		_, err := h.Do(user.InjectOrgID(context.Background(), "team-a"), logRequest(`{app="foo"}`))
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"team-a": `{app="foo"}`}, received)
+	})
+}
+
+func TestTenantFederationMiddleware_Merge(t *testing.T) {
+	limits := fakeLimits{tenantFederations: map[string][]*validation.TenantFederationMember{
+		"engineering": {{Tenant: "team-a"}, {Tenant: "team-b"}},
+	}}
+	// The entries of the members interleave: team-a logs at even seconds and
+	// team-b at odd seconds.
+	offsets := map[string]int{"team-a": 0, "team-b": 1}
+	values := map[string]float64{"team-a": 1, "team-b": 2}
+	next := base.HandlerFunc(func(ctx context.Context, r base.Request) (base.Response, error) {
+		id, err := tenant.TenantID(ctx)
+		require.NoError(t, err)
+		req := r.(*LokiRequest)
+		if _, ok := req.Plan.AST.(syntax.SampleExpr); ok {
+			return &LokiPromResponse{Response: &base.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: base.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+					Result: []base.SampleStream{
+						{
+							Labels:  []logproto.LabelAdapter{{Name: "level", Value: "error"}},
+							Samples: []logproto.LegacySample{{TimestampMs: 1000, Value: values[id]}, {TimestampMs: 2000, Value: values[id]}},
+						},
+						{
+							Labels:  []logproto.LabelAdapter{{Name: "level", Value: "info"}},
+							Samples: []logproto.LegacySample{{TimestampMs: 1000, Value: 10 * values[id]}},
+						},
+					},
+				},
+			}}, nil
+		}
+		stream := logproto.Stream{Labels: `{app="foo"}`}
+		for i := 4; i >= 0; i-- {
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: testTime.Add(time.Duration(2*i+offsets[id]) * time.Second),
+				Line:      id,
+			})
+		}
+		return &LokiResponse{
+			Status:    loghttp.QueryStatusSuccess,
+			Direction: req.Direction,
+			Limit:     req.Limit,
+			Version:   uint32(loghttp.VersionV1),
+			Data:      LokiData{ResultType: loghttp.ResultTypeStream, Result: []logproto.Stream{stream}},
+		}, nil
+	})
+	h := NewTenantFederationMiddleware(limits, DefaultCodec).Wrap(next)
+	ctx := user.InjectOrgID(context.Background(), "engineering")
+	request := func(query string, limit uint32) *LokiRequest {
+		return &LokiRequest{
+			Query:     query,
+			StartTs:   testTime,
+			EndTs:     testTime.Add(time.Minute),
+			Limit:     limit,
+			Direction: logproto.BACKWARD,
+			Path:      "/loki/api/v1/query_range",
+			Plan:      &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+		}
+	}
+
+	t.Run("log entries are merged by timestamp before the limit", func(t *testing.T) {
+		resp, err := h.Do(ctx, request(`{app="foo"}`, 4))
+		require.NoError(t, err)
+
+		var got []time.Time
+		for _, s := range resp.(*LokiResponse).Data.Result {
+			for _, e := range s.Entries {
+				require.Equal(t, s.Labels, `{__tenant_id__="`+e.Line+`", app="foo"}`)
+				got = append(got, e.Timestamp)
+			}
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].After(got[j]) })
+		require.Equal(t, []time.Time{
+			testTime.Add(9 * time.Second),
+			testTime.Add(8 * time.Second),
+			testTime.Add(7 * time.Second),
+			testTime.Add(6 * time.Second),
+		}, got)
+	})
+
+	t.Run("aggregations are merged across members", func(t *testing.T) {
+		resp, err := h.Do(ctx, request(`sum by (level) (count_over_time({app="foo"}[1m]))`, 100))
+		require.NoError(t, err)
+		require.Equal(t, []base.SampleStream{
+			{
+				Labels:  []logproto.LabelAdapter{{Name: "level", Value: "error"}},
+				Samples: []logproto.LegacySample{{TimestampMs: 1000, Value: 3}, {TimestampMs: 2000, Value: 3}},
+			},
+			{
+				Labels:  []logproto.LabelAdapter{{Name: "level", Value: "info"}},
+				Samples: []logproto.LegacySample{{TimestampMs: 1000, Value: 30}},
+			},
+		}, resp.(*LokiPromResponse).Response.Data.Result)
+	})
+
+	t.Run("topk is applied across members", func(t *testing.T) {
+		resp, err := h.Do(ctx, request(`topk(1, count_over_time({app="foo"}[1m]))`, 100))
+		require.NoError(t, err)
+		require.Equal(t, []base.SampleStream{
+			{
+				Labels:  []logproto.LabelAdapter{{Name: tenantLabel, Value: "team-b"}, {Name: "level", Value: "error"}},
+				Samples: []logproto.LegacySample{{TimestampMs: 2000, Value: 2}},
+			},
+			{
+				Labels:  []logproto.LabelAdapter{{Name: tenantLabel, Value: "team-b"}, {Name: "level", Value: "info"}},
+				Samples: []logproto.LegacySample{{TimestampMs: 1000, Value: 20}},
+			},
+		}, resp.(*LokiPromResponse).Response.Data.Result)
+	})
+
+	t.Run("series grouped by tenant are kept", func(t *testing.T) {
+		resp, err := h.Do(ctx, request(`sum by (__tenant_id__, level) (count_over_time({app="foo"}[1m]))`, 100))
+		require.NoError(t, err)
+		require.Len(t, resp.(*LokiPromResponse).Response.Data.Result, 4)
+	})
+
+	t.Run("aggregations which can't be merged are rejected", func(t *testing.T) {
+		for _, query := range []string{
+			`avg(count_over_time({app="foo"}[1m]))`,
+			`sum(count_over_time({app="foo"}[1m])) / 2`,
+			`max(sum by (level) (count_over_time({app="foo"}[1m])))`,
+		} {
+			_, err := h.Do(ctx, request(query, 100))
+			res, ok := httpgrpc.HTTPResponseFromError(err)
+			require.True(t, ok, query)
+			require.Equal(t, http.StatusBadRequest, int(res.Code), query)
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,6 +16,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/iter"
 	loghttp "github.com/grafana/loki/v3/pkg/loghttp/legacy"
 	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
 
@@ -53,6 +55,23 @@ type Tailer struct {
 	querierTailClients    map[string]logproto.Querier_TailClient // addr -> grpc clients for tailing logs from ingesters
 	querierTailClientsMtx sync.RWMutex
 
+	// limiter enforces the lines per second budget of the tail across all
+	// the ingesters, nil if unlimited.
+	limiter *rate.Limiter
+
+	// countedDrops are the lines dropped over the budget, counted by stream
+	// and sent along with the next response. The ingesters don't enforce the
+	// budget, so every replica of a line is either sent or dropped here once.
+	countedDrops    []loghttp.DroppedEntry
+	countedDropsMtx sync.Mutex
+
+	// For metric tails, the latest vector received from each ingester, by
+	// address, which are merged every step.
+	metricExpr syntax.SampleExpr
+	step       time.Duration
+	vectors    map[string]ingesterVector
+	vectorsMtx sync.Mutex
+
 	stopped          atomic.Bool
 	delayFor         time.Duration
 	responseChan     chan *loghttp.TailResponse
@@ -113,6 +132,14 @@ func (t *Tailer) loop() {
 		)
 
 		for ; entriesCount < maxEntriesPerTailResponse && t.next(); entriesCount++ {
+			// The entries over the budget are counted rather than sent. Entries
+			// replicated on several ingesters were merged already, so they
+			// only count once.
+			if t.limiter != nil && !t.limiter.Allow() {
+				t.countDroppedLines(t.currLabels, t.currEntry.Timestamp, 1)
+				continue
+			}
+
 			// If the response channel channel is blocked, we drop the current entry directly
 			// to save the effort
 			if t.isResponseChanBlocked() {
@@ -160,8 +187,9 @@ func (t *Tailer) loop() {
 
 		// Send the tail response through the response channel without blocking.
 		// Drop the entry if the response channel buffer is full.
-		if len(droppedEntries) > 0 {
-			tailResponse.DroppedEntries = droppedEntries
+		countedDrops := t.popCountedDrops()
+		if len(droppedEntries) > 0 || len(countedDrops) > 0 {
+			tailResponse.DroppedEntries = append(droppedEntries[:len(droppedEntries):len(droppedEntries)], countedDrops...)
 		}
 
 		select {
@@ -172,6 +200,9 @@ func (t *Tailer) loop() {
 			}
 		default:
 			droppedEntries = dropEntries(droppedEntries, tailResponse.Streams)
+			for _, e := range countedDrops {
+				t.countDroppedLines(e.Labels, e.Timestamp, e.Count)
+			}
 		}
 	}
 }
@@ -232,6 +263,10 @@ func (t *Tailer) readTailClient(addr string, querierTailClient logproto.Querier_
 			}
 			break
 		}
+		if t.metricExpr != nil {
+			t.pushVectorFromIngester(addr, resp)
+			continue
+		}
 		t.pushTailResponseFromIngester(resp)
 	}
 }
@@ -292,6 +327,41 @@ func (t *Tailer) getCloseErrorChan() <-chan error {
 	return t.closeErrChan
 }
 
+// countDroppedLines adds dropped lines to the count of their stream.
+func (t *Tailer) countDroppedLines(labels string, timestamp time.Time, lines uint64) {
+	t.countedDropsMtx.Lock()
+	defer t.countedDropsMtx.Unlock()
+
+	t.countedDrops = addDroppedLines(t.countedDrops, labels, timestamp, lines)
+}
+
+func (t *Tailer) popCountedDrops() []loghttp.DroppedEntry {
+	t.countedDropsMtx.Lock()
+	defer t.countedDropsMtx.Unlock()
+
+	countedDrops := t.countedDrops
+	t.countedDrops = nil
+	return countedDrops
+}
+
+func addDroppedLines(drops []loghttp.DroppedEntry, labels string, timestamp time.Time, lines uint64) []loghttp.DroppedEntry {
+	for i := range drops {
+		e := &drops[i]
+		if e.Labels != labels {
+			continue
+		}
+		if timestamp.After(e.Timestamp) {
+			e.Timestamp = timestamp
+		}
+		e.Count += lines
+		return drops
+	}
+	if len(drops) >= maxDroppedEntriesPerTailResponse {
+		return drops
+	}
+	return append(drops, loghttp.DroppedEntry{Timestamp: timestamp, Labels: labels, Count: lines})
+}
+
 func (t *Tailer) recordStream(id uint64) {
 	t.seenStreamsMtx.Lock()
 	defer t.seenStreamsMtx.Unlock()
@@ -313,6 +383,7 @@ func (t *Tailer) activeStreamCount() float64 {
 
 func newTailer(
 	delayFor time.Duration,
+	maxLinesPerSecond uint32,
 	querierTailClients map[string]logproto.Querier_TailClient,
 	historicEntries iter.EntryIterator,
 	tailDisconnectedIngesters func([]string) (map[string]logproto.Querier_TailClient, error),
@@ -327,10 +398,16 @@ func newTailer(
 		historicEntriesIter = iter.NewCategorizeLabelsIterator(historicEntries)
 	}
 
+	var limiter *rate.Limiter
+	if maxLinesPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(maxLinesPerSecond), int(maxLinesPerSecond))
+	}
+
 	t := Tailer{
 		openStreamIterator:        iter.NewMergeEntryIterator(context.Background(), []iter.EntryIterator{historicEntriesIter}, logproto.FORWARD),
 		querierTailClients:        querierTailClients,
 		delayFor:                  delayFor,
+		limiter:                   limiter,
 		responseChan:              make(chan *loghttp.TailResponse, maxBufferedTailResponses),
 		closeErrChan:              make(chan error),
 		seenStreams:               make(map[uint64]struct{}),
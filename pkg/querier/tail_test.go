@@ -2,6 +2,7 @@ package querier
 
 import (
 	"errors"
+	"sort"
 	"testing"
 	"time"
 
@@ -165,7 +166,7 @@ func TestTailer(t *testing.T) {
 				tailClients["test"] = test.tailClient
 			}
 
-			tailer := newTailer(0, tailClients, test.historicEntries, tailDisconnectedIngesters, timeout, throttle, false, NewMetrics(nil), gokitlog.NewNopLogger())
+			tailer := newTailer(0, 0, tailClients, test.historicEntries, tailDisconnectedIngesters, timeout, throttle, false, NewMetrics(nil), gokitlog.NewNopLogger())
 			defer tailer.close()
 
 			test.tester(t, tailer, test.tailClient)
@@ -173,6 +174,65 @@ func TestTailer(t *testing.T) {
 	}
 }
 
+func TestTailer_MaxLinesPerSecond(t *testing.T) {
+	t.Parallel()
+
+	response := mockTailResponse(mockStream(11, 1))
+	response.DroppedStreams = []*logproto.DroppedStream{{
+		From:         time.Unix(11, 0),
+		To:           time.Unix(11, 0),
+		Labels:       `{type="test"}`,
+		DroppedLines: 3,
+	}}
+	tailClient := newTailClientMock().mockRecvWithTrigger(response)
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+
+	tailer := newTailer(0, 5, map[string]logproto.Querier_TailClient{"test": tailClient}, mockStreamIterator(1, 10), tailDisconnectedIngesters, timeout, throttle, false, NewMetrics(nil), gokitlog.NewNopLogger())
+	defer tailer.close()
+
+	require.NoError(t, waitUntilTailerOpenStreamsHaveBeenConsumed(tailer))
+	responses, err := readFromTailer(tailer, 5)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	require.Equal(t, 5, countEntriesInStreams(responses[0].Streams))
+
+	// The lines over the budget are counted by stream.
+	assert.Equal(t, []loghttp.DroppedEntry{{
+		Timestamp: time.Unix(10, 0),
+		Labels:    `{type="test"}`,
+		Count:     5,
+	}}, responses[0].DroppedEntries)
+
+	// The drops reported by the ingesters differ between the replicas of a
+	// stream, they aren't counted.
+	time.Sleep(250 * time.Millisecond)
+	tailClient.triggerRecv()
+	responses, err = readFromTailer(tailer, 1)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	require.Equal(t, 1, countEntriesInStreams(responses[0].Streams))
+	assert.Empty(t, responses[0].DroppedEntries)
+}
+
+func TestTailer_CountedDrops(t *testing.T) {
+	t.Parallel()
+
+	tailer := &Tailer{}
+	tailer.countDroppedLines(`{type="test"}`, time.Unix(10, 0), 3)
+	tailer.countDroppedLines(`{type="test"}`, time.Unix(12, 0), 2)
+	tailer.countDroppedLines(`{type="other"}`, time.Unix(11, 0), 1)
+
+	drops := tailer.popCountedDrops()
+	sort.Slice(drops, func(i, j int) bool { return drops[i].Labels < drops[j].Labels })
+	require.Equal(t, []loghttp.DroppedEntry{
+		{Timestamp: time.Unix(11, 0), Labels: `{type="other"}`, Count: 1},
+		{Timestamp: time.Unix(12, 0), Labels: `{type="test"}`, Count: 5},
+	}, drops)
+	require.Empty(t, tailer.popCountedDrops())
+}
+
 func TestCategorizedLabels(t *testing.T) {
 	t.Parallel()
 
@@ -360,7 +420,7 @@ func TestCategorizedLabels(t *testing.T) {
 				tailClients[k] = v
 			}
 
-			tailer := newTailer(0, tailClients, tc.historicEntries, tailDisconnectedIngesters, timeout, throttle, tc.categorizeLabels, NewMetrics(nil), log.NewNopLogger())
+			tailer := newTailer(0, 0, tailClients, tc.historicEntries, tailDisconnectedIngesters, timeout, throttle, tc.categorizeLabels, NewMetrics(nil), log.NewNopLogger())
 			defer tailer.close()
 
 			// Make tail clients receive their responses
@@ -9,6 +9,11 @@ type Metrics struct {
 	queueLength       *prometheus.GaugeVec   // Per tenant
 	discardedRequests *prometheus.CounterVec // Per tenant
 	enqueueCount      *prometheus.CounterVec // Per tenant and level
+
+	priorityQueueLength        *prometheus.GaugeVec   // Per priority class
+	priorityEnqueueCount       *prometheus.CounterVec // Per priority class
+	priorityInflightRequests   *prometheus.GaugeVec   // Per priority class
+	priorityStarvationDequeues *prometheus.CounterVec // Per priority class
 }
 
 func NewMetrics(registerer prometheus.Registerer, metricsNamespace, subsystem string) *Metrics {
@@ -31,6 +36,30 @@ func NewMetrics(registerer prometheus.Registerer, metricsNamespace, subsystem st
 			Name:      "enqueue_count",
 			Help:      "Total number of enqueued (sub-)queries.",
 		}, []string{"user", "level"}),
+		priorityQueueLength: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: subsystem,
+			Name:      "priority_queue_length",
+			Help:      "Number of queries in the queue per priority class.",
+		}, []string{"priority"}),
+		priorityEnqueueCount: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: subsystem,
+			Name:      "priority_enqueue_count",
+			Help:      "Total number of enqueued (sub-)queries per priority class.",
+		}, []string{"priority"}),
+		priorityInflightRequests: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: subsystem,
+			Name:      "priority_inflight_requests",
+			Help:      "Number of dequeued queries that are processed per priority class with a max concurrency.",
+		}, []string{"priority"}),
+		priorityStarvationDequeues: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: subsystem,
+			Name:      "priority_starvation_dequeues_total",
+			Help:      "Total number of (sub-)queries dequeued ahead of higher priority classes to prevent starvation.",
+		}, []string{"priority"}),
 	}
 }
 
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Priority is the class of a request in the queue. Requests of a higher
+// class are dequeued before requests of a lower class.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+var priorityNames = [numPriorities]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+func (p Priority) String() string {
+	if p < 0 || int(p) >= numPriorities {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority returns the priority class with the given name.
+// An empty name is the normal priority.
+func ParsePriority(name string) (Priority, error) {
+	if name == "" {
+		return PriorityNormal, nil
+	}
+	for p, n := range priorityNames {
+		if n == name {
+			return Priority(p), nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("invalid priority %q, must be one of low, normal or high", name)
+}
+
+// PriorityConfig configures how requests of the different priority classes are dequeued.
+type PriorityConfig struct {
+	StarvationThreshold  int `yaml:"starvation_threshold"`
+	MaxConcurrencyHigh   int `yaml:"max_concurrency_high"`
+	MaxConcurrencyNormal int `yaml:"max_concurrency_normal"`
+	MaxConcurrencyLow    int `yaml:"max_concurrency_low"`
+}
+
+func (cfg *PriorityConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.StarvationThreshold, prefix+"priority.starvation-threshold", 10, "Number of requests of higher priority classes that can be dequeued while requests of a lower priority class are waiting, before a request of the lower class is dequeued. 0 means that lower priority classes are only dequeued once all higher classes are empty.")
+	f.IntVar(&cfg.MaxConcurrencyHigh, prefix+"priority.max-concurrency-high", 0, "Maximum number of requests of the high priority class that are processed at the same time. 0 means no limit.")
+	f.IntVar(&cfg.MaxConcurrencyNormal, prefix+"priority.max-concurrency-normal", 0, "Maximum number of requests of the normal priority class that are processed at the same time. 0 means no limit.")
+	f.IntVar(&cfg.MaxConcurrencyLow, prefix+"priority.max-concurrency-low", 0, "Maximum number of requests of the low priority class that are processed at the same time. 0 means no limit.")
+}
+
+func (cfg *PriorityConfig) maxConcurrency(p Priority) int {
+	switch p {
+	case PriorityHigh:
+		return cfg.MaxConcurrencyHigh
+	case PriorityLow:
+		return cfg.MaxConcurrencyLow
+	default:
+		return cfg.MaxConcurrencyNormal
+	}
+}
+
+// priorityClass holds the tenant queues of a single priority class.
+type priorityClass struct {
+	*tenantQueues
+
+	priority Priority
+
+	// maxConcurrency is the maximum number of dequeued requests of this
+	// class that have not been released yet. 0 means no limit.
+	maxConcurrency int
+	inflight       int
+
+	// skipped is the number of requests dequeued from higher classes since
+	// the last request of this class while it had pending requests.
+	skipped int
+
+	// last is the position of the tenant this class was last dequeued from.
+	// Only the normal class is iterated with the index that is handed out to
+	// consumers, all other classes share this position between consumers.
+	last QueueIndex
+}
+
+func (c *priorityClass) atMaxConcurrency() bool {
+	return c.maxConcurrency > 0 && c.inflight >= c.maxConcurrency
+}
+
+// getNextQueueForConsumer finds the next queue of the class for the consumer.
+// If reuse is set, the iteration starts at the last returned tenant. The
+// returned index is only advanced by the normal class.
+func (c *priorityClass) getNextQueueForConsumer(last QueueIndex, reuse bool, consumerID string) (Queue, string, QueueIndex) {
+	idx := last
+	if c.priority != PriorityNormal {
+		idx = c.last
+	}
+	if reuse {
+		idx = idx.ReuseLastIndex()
+	}
+
+	queue, tenant, idx := c.tenantQueues.getNextQueueForConsumer(idx, consumerID)
+	if c.priority == PriorityNormal {
+		return queue, tenant, idx
+	}
+	c.last = idx
+	return queue, tenant, last
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+func TestParsePriority(t *testing.T) {
+	for name, expected := range map[string]Priority{
+		"":       PriorityNormal,
+		"low":    PriorityLow,
+		"normal": PriorityNormal,
+		"high":   PriorityHigh,
+	} {
+		p, err := ParsePriority(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, p)
+	}
+
+	_, err := ParsePriority("urgent")
+	require.Error(t, err)
+}
+
+func dequeueAll(t *testing.T, q *RequestQueue, n int) []Request {
+	t.Helper()
+	var (
+		items []Request
+		idx   = StartIndexWithLocalQueue
+	)
+	for i := 0; i < n; i++ {
+		item, newIdx, err := q.Dequeue(context.Background(), idx, "querier")
+		require.NoError(t, err)
+		items = append(items, item)
+		idx = newIdx
+	}
+	return items
+}
+
+func TestRequestQueue_Priorities(t *testing.T) {
+	t.Run("higher classes are dequeued first", func(t *testing.T) {
+		q := NewRequestQueueWithPriorities(10, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		q.RegisterConsumerConnection("querier")
+
+		require.NoError(t, q.EnqueueWithPriority("tenant-a", nil, PriorityLow, "low-a", nil))
+		require.NoError(t, q.Enqueue("tenant-a", nil, "normal-a", nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant-b", []string{"user"}, PriorityHigh, "high-b", nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant-a", nil, PriorityHigh, "high-a", nil))
+
+		require.Equal(t, []Request{"high-b", "high-a", "normal-a", "low-a"}, dequeueAll(t, q, 4))
+	})
+
+	t.Run("outstanding requests are limited across classes", func(t *testing.T) {
+		q := NewRequestQueueWithPriorities(2, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityLow, 1, nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, 2, nil))
+		require.Equal(t, ErrTooManyRequests, q.Enqueue("tenant", nil, 3, nil))
+	})
+
+	t.Run("starving classes are dequeued", func(t *testing.T) {
+		q := NewRequestQueueWithPriorities(10, 0, noQueueLimits, PriorityConfig{StarvationThreshold: 2}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		q.RegisterConsumerConnection("querier")
+
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityLow, "low", nil))
+		require.NoError(t, q.Enqueue("tenant", nil, "normal", nil))
+		for _, r := range []string{"high-1", "high-2", "high-3", "high-4"} {
+			require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, r, nil))
+		}
+
+		require.Equal(t, []Request{"high-1", "high-2", "normal", "low", "high-3", "high-4"}, dequeueAll(t, q, 6))
+	})
+
+	t.Run("max concurrency per class", func(t *testing.T) {
+		q := NewRequestQueueWithPriorities(10, 0, noQueueLimits, PriorityConfig{MaxConcurrencyHigh: 1}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		q.RegisterConsumerConnection("querier")
+
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, "high-1", nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, "high-2", nil))
+		require.NoError(t, q.Enqueue("tenant", nil, "normal", nil))
+
+		require.Equal(t, []Request{"high-1", "normal"}, dequeueAll(t, q, 2))
+
+		// The remaining request of the high class waits for the first one to be released.
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, _, err := q.Dequeue(ctx, StartIndexWithLocalQueue, "querier")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		done := make(chan Request)
+		go func() {
+			item, _, _ := q.Dequeue(context.Background(), StartIndexWithLocalQueue, "querier")
+			done <- item
+		}()
+		q.Release(PriorityHigh)
+		select {
+		case item := <-done:
+			require.Equal(t, "high-2", item)
+		case <-time.After(time.Second):
+			t.Fatal("request was not dequeued after release")
+		}
+	})
+
+	t.Run("dequeue many stays within the class", func(t *testing.T) {
+		q := NewRequestQueueWithPriorities(10, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		q.RegisterConsumerConnection("querier")
+
+		require.NoError(t, q.Enqueue("tenant", nil, "normal", nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, "high-1", nil))
+		require.NoError(t, q.EnqueueWithPriority("tenant", nil, PriorityHigh, "high-2", nil))
+
+		items, _, err := q.DequeueMany(context.Background(), StartIndexWithLocalQueue, "querier", 3)
+		require.NoError(t, err)
+		require.Equal(t, []Request{"high-1", "high-2"}, items)
+
+		items, _, err = q.DequeueMany(context.Background(), StartIndexWithLocalQueue, "querier", 3)
+		require.NoError(t, err)
+		require.Equal(t, []Request{"normal"}, items)
+	})
+}
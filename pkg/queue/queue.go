@@ -60,22 +60,45 @@ type RequestQueue struct {
 
 	connectedConsumers *atomic.Int32
 
-	mtx     sync.Mutex
-	cond    contextCond // Notified when request is enqueued or dequeued, or querier is disconnected.
+	mtx  sync.Mutex
+	cond contextCond // Notified when request is enqueued or dequeued, or querier is disconnected.
+	// queues are the tenant queues of the normal priority class.
 	queues  *tenantQueues
+	classes [numPriorities]*priorityClass
 	stopped bool
 
+	starvationThreshold int
+
 	metrics *Metrics
 	pool    *SlicePool[Request]
 }
 
 func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, limits Limits, metrics *Metrics) *RequestQueue {
+	return NewRequestQueueWithPriorities(maxOutstandingPerTenant, forgetDelay, limits, PriorityConfig{}, metrics)
+}
+
+// NewRequestQueueWithPriorities creates a request queue that dequeues requests of higher priority classes first.
+// The maximum number of outstanding requests per tenant applies to the requests of all classes.
+func NewRequestQueueWithPriorities(maxOutstandingPerTenant int, forgetDelay time.Duration, limits Limits, priorities PriorityConfig, metrics *Metrics) *RequestQueue {
 	q := &RequestQueue{
-		queues:             newTenantQueues(maxOutstandingPerTenant, forgetDelay, limits),
-		connectedConsumers: atomic.NewInt32(0),
-		metrics:            metrics,
-		pool:               NewSlicePool[Request](1<<6, 1<<10, 2), // Buckets are [64, 128, 256, 512, 1024].
+		connectedConsumers:  atomic.NewInt32(0),
+		starvationThreshold: priorities.StarvationThreshold,
+		metrics:             metrics,
+		pool:                NewSlicePool[Request](1<<6, 1<<10, 2), // Buckets are [64, 128, 256, 512, 1024].
+	}
+
+	perUserQueueLen := make(intPointerMap)
+	for p := range q.classes {
+		queues := newTenantQueues(maxOutstandingPerTenant, forgetDelay, limits)
+		queues.perUserQueueLen = perUserQueueLen
+		q.classes[p] = &priorityClass{
+			tenantQueues:   queues,
+			priority:       Priority(p),
+			maxConcurrency: priorities.maxConcurrency(Priority(p)),
+			last:           StartIndex,
+		}
 	}
+	q.queues = q.classes[PriorityNormal].tenantQueues
 
 	q.cond = contextCond{Cond: sync.NewCond(&q.mtx)}
 	q.Service = services.NewTimerService(forgetCheckPeriod, nil, q.forgetDisconnectedConsumers, q.stopping).WithName("request queue")
@@ -83,9 +106,19 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, lim
 	return q
 }
 
-// Enqueue puts the request into the queue.
+// Enqueue puts the request into the queue of the normal priority class.
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
 func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, successFn func()) error {
+	return q.EnqueueWithPriority(tenant, path, PriorityNormal, req, successFn)
+}
+
+// EnqueueWithPriority puts the request into the queue of the given priority class.
+// If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
+func (q *RequestQueue) EnqueueWithPriority(tenant string, path []string, priority Priority, req Request, successFn func()) error {
+	if priority < 0 || int(priority) >= numPriorities {
+		return fmt.Errorf("invalid priority %d", priority)
+	}
+
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -93,7 +126,8 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 		return ErrStopped
 	}
 
-	queue, err := q.queues.getOrAddQueue(tenant, path)
+	class := q.classes[priority]
+	queue, err := class.getOrAddQueue(tenant, path)
 	if err != nil {
 		return fmt.Errorf("no queue found: %w", err)
 	}
@@ -104,11 +138,11 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 	// We need to keep track of queue length separately because the size of the
 	// buffered channel is the same across all sub-queues which would allow
 	// enqueuing more items than there are allowed at tenant level.
-	queueLen := q.queues.perUserQueueLen.Inc(tenant)
-	if queueLen > q.queues.maxUserQueueSize {
+	queueLen := class.perUserQueueLen.Inc(tenant)
+	if queueLen > class.maxUserQueueSize {
 		q.metrics.discardedRequests.WithLabelValues(tenant).Inc()
 		// decrement, because we already optimistically increased the counter
-		q.queues.perUserQueueLen.Dec(tenant)
+		class.perUserQueueLen.Dec(tenant)
 		return ErrTooManyRequests
 	}
 
@@ -116,6 +150,8 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 	case queue.Chan() <- req:
 		q.metrics.queueLength.WithLabelValues(tenant).Inc()
 		q.metrics.enqueueCount.WithLabelValues(tenant, fmt.Sprint(len(path))).Inc()
+		q.metrics.priorityQueueLength.WithLabelValues(priority.String()).Inc()
+		q.metrics.priorityEnqueueCount.WithLabelValues(priority.String()).Inc()
 		q.cond.Broadcast()
 		// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
 		if successFn != nil {
@@ -125,7 +161,7 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 	default:
 		q.metrics.discardedRequests.WithLabelValues(tenant).Inc()
 		// decrement, because we already optimistically increased the counter
-		q.queues.perUserQueueLen.Dec(tenant)
+		class.perUserQueueLen.Dec(tenant)
 		return ErrTooManyRequests
 	}
 }
@@ -144,8 +180,9 @@ func (q *RequestQueue) ReleaseRequests(items []Request) {
 func (q *RequestQueue) DequeueMany(ctx context.Context, idx QueueIndex, consumerID string, maxItems int) ([]Request, QueueIndex, error) {
 	items := q.pool.Get(maxItems)
 	lastQueueName := anyQueue
+	lastPriority := PriorityNormal
 	for {
-		item, newIdx, newQueueName, isTenantQueueEmpty, err := q.dequeue(ctx, idx, lastQueueName, consumerID)
+		item, newIdx, newQueueName, newPriority, isTenantQueueEmpty, err := q.dequeue(ctx, idx, lastQueueName, lastPriority, consumerID)
 		if err != nil {
 			// the consumer must receive the items if tenants queue is removed,
 			// even if it has collected less than `maxItems` requests.
@@ -155,8 +192,9 @@ func (q *RequestQueue) DequeueMany(ctx context.Context, idx QueueIndex, consumer
 			return items, newIdx, err
 		}
 		lastQueueName = newQueueName
+		lastPriority = newPriority
 		items = append(items, item)
-		idx = newIdx.ReuseLastIndex()
+		idx = newIdx
 		if len(items) == maxItems || isTenantQueueEmpty {
 			return items, newIdx, nil
 		}
@@ -168,12 +206,13 @@ func (q *RequestQueue) DequeueMany(ctx context.Context, idx QueueIndex, consumer
 // Even if the consumer used UserIndex.ReuseLastUser to fetch the request from the same tenant's queue, it does not provide
 // any guaranties that the previously used queue is still at this position because another consumer could already read
 // the last request and the queue could be removed and another queue is already placed at this position.
+// Requests of higher priority classes are dequeued first.
 func (q *RequestQueue) Dequeue(ctx context.Context, last QueueIndex, consumerID string) (Request, QueueIndex, error) {
-	dequeue, queueIndex, _, _, err := q.dequeue(ctx, last, anyQueue, consumerID)
+	dequeue, queueIndex, _, _, _, err := q.dequeue(ctx, last, anyQueue, PriorityNormal, consumerID)
 	return dequeue, queueIndex, err
 }
 
-func (q *RequestQueue) dequeue(ctx context.Context, last QueueIndex, wantedQueueName string, consumerID string) (Request, QueueIndex, string, bool, error) {
+func (q *RequestQueue) dequeue(ctx context.Context, last QueueIndex, wantedQueueName string, wantedPriority Priority, consumerID string) (Request, QueueIndex, string, Priority, bool, error) {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -182,27 +221,39 @@ func (q *RequestQueue) dequeue(ctx context.Context, last QueueIndex, wantedQueue
 FindQueue:
 	// We need to wait if there are no tenants, or no pending requests for given querier.
 	// However, if `wantedQueueName` is not empty, the caller must not be blocked because it wants to read exactly from that queue, not others.
-	for (q.queues.hasNoTenantQueues() || querierWait) && ctx.Err() == nil && !q.stopped && wantedQueueName == anyQueue {
+	for (q.isEmpty() || querierWait) && ctx.Err() == nil && !q.stopped && wantedQueueName == anyQueue {
 		querierWait = false
 		q.cond.Wait(ctx)
 	}
 
 	// If the current consumer wants to read from specific queue, but he does not have any queues available for him,
 	// return an error to notify that queue has been already removed.
-	if q.queues.hasNoTenantQueues() && wantedQueueName != anyQueue {
-		return nil, last, wantedQueueName, false, ErrQueueWasRemoved
+	// The same applies if the class of the queue reached its max concurrency.
+	if wantedQueueName != anyQueue {
+		if class := q.classes[wantedPriority]; class.hasNoTenantQueues() || class.atMaxConcurrency() {
+			return nil, last, wantedQueueName, wantedPriority, false, ErrQueueWasRemoved
+		}
 	}
 
 	if q.stopped {
-		return nil, last, wantedQueueName, false, ErrStopped
+		return nil, last, wantedQueueName, wantedPriority, false, ErrStopped
 	}
 
 	if err := ctx.Err(); err != nil {
-		return nil, last, wantedQueueName, false, err
+		return nil, last, wantedQueueName, wantedPriority, false, err
 	}
 
-	queue, tenant, idx := q.queues.getNextQueueForConsumer(last, consumerID)
-	last = idx
+	var (
+		queue  Queue
+		tenant string
+		class  *priorityClass
+	)
+	if wantedQueueName != anyQueue {
+		class = q.classes[wantedPriority]
+		queue, tenant, last = class.getNextQueueForConsumer(last, true, consumerID)
+	} else {
+		queue, tenant, class, last = q.getNextQueueForConsumer(last, consumerID)
+	}
 	if queue == nil {
 		// it can be a case the consumer has other tenants queues available for him,
 		// it allows the consumer to pass the wait block,
@@ -211,7 +262,7 @@ FindQueue:
 		// and as long as this consumer wants to read from specific tenant queue,
 		// it's necessary to return `ErrQueueWasRemoved` error.
 		if wantedQueueName != anyQueue {
-			return nil, last, wantedQueueName, false, ErrQueueWasRemoved
+			return nil, last, wantedQueueName, wantedPriority, false, ErrQueueWasRemoved
 		}
 		// otherwise, if wantedQueueName is empty, then this consumer will go to the wait block again
 		// and as long as `last` index is updated, next time the consumer will request the queue
@@ -225,29 +276,114 @@ FindQueue:
 	if wantedQueueName != anyQueue && wantedQueueName != queue.Name() {
 		// it means that the consumer received another tenants queue because it was already removed
 		// or another queue is already at this index
-		return nil, last, queue.Name(), false, ErrQueueWasRemoved
+		return nil, last, queue.Name(), class.priority, false, ErrQueueWasRemoved
 	}
 	// Pick next request from the queue.
 	request := queue.Dequeue()
 	isTenantQueueEmpty := queue.Len() == 0
 	if isTenantQueueEmpty {
-		q.queues.deleteQueue(tenant)
+		class.deleteQueue(tenant)
 	}
 
-	q.queues.perUserQueueLen.Dec(tenant)
+	class.perUserQueueLen.Dec(tenant)
 	q.metrics.queueLength.WithLabelValues(tenant).Dec()
+	q.metrics.priorityQueueLength.WithLabelValues(class.priority.String()).Dec()
+	q.dequeuedFrom(class)
 
 	// Tell close() we've processed a request.
 	q.cond.Broadcast()
 
-	return request, last, queue.Name(), isTenantQueueEmpty, nil
+	return request, last, queue.Name(), class.priority, isTenantQueueEmpty, nil
+}
+
+// getNextQueueForConsumer finds the next queue for the consumer in the classes that did not reach their max concurrency.
+// Classes are tried in order of their priority, except for classes that starve, which are tried first.
+func (q *RequestQueue) getNextQueueForConsumer(last QueueIndex, consumerID string) (Queue, string, *priorityClass, QueueIndex) {
+	var starving, rest [numPriorities]*priorityClass
+	for p := numPriorities - 1; p >= 0; p-- {
+		class := q.classes[p]
+		if q.starvationThreshold > 0 && class.skipped >= q.starvationThreshold {
+			starving[p] = class
+		} else {
+			rest[p] = class
+		}
+	}
+
+	for _, classes := range [][numPriorities]*priorityClass{starving, rest} {
+		for p := numPriorities - 1; p >= 0; p-- {
+			class := classes[p]
+			if class == nil || class.hasNoTenantQueues() || class.atMaxConcurrency() {
+				continue
+			}
+			queue, tenant, idx := class.getNextQueueForConsumer(last, false, consumerID)
+			last = idx
+			if queue != nil {
+				return queue, tenant, class, last
+			}
+		}
+	}
+	return nil, "", nil, last
+}
+
+// dequeuedFrom records that a request was dequeued from the class.
+func (q *RequestQueue) dequeuedFrom(class *priorityClass) {
+	priority := class.priority.String()
+	if q.starvationThreshold > 0 && class.skipped >= q.starvationThreshold {
+		q.metrics.priorityStarvationDequeues.WithLabelValues(priority).Inc()
+	}
+	class.skipped = 0
+	for _, lower := range q.classes[:class.priority] {
+		if !lower.hasNoTenantQueues() {
+			lower.skipped++
+		}
+	}
+
+	if class.maxConcurrency > 0 {
+		class.inflight++
+		q.metrics.priorityInflightRequests.WithLabelValues(priority).Set(float64(class.inflight))
+	}
+}
+
+// Release marks a request of the given priority class as processed, which allows to dequeue another
+// request of a class with a max concurrency. Consumers of a queue with a max concurrency per class
+// must release every dequeued request.
+func (q *RequestQueue) Release(priority Priority) {
+	if priority < 0 || int(priority) >= numPriorities {
+		return
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	class := q.classes[priority]
+	if class.maxConcurrency == 0 || class.inflight == 0 {
+		return
+	}
+	class.inflight--
+	q.metrics.priorityInflightRequests.WithLabelValues(priority.String()).Set(float64(class.inflight))
+
+	// Consumers may wait for a slot of the class.
+	q.cond.Broadcast()
+}
+
+func (q *RequestQueue) isEmpty() bool {
+	for _, class := range q.classes {
+		if !class.hasNoTenantQueues() {
+			return false
+		}
+	}
+	return true
 }
 
 func (q *RequestQueue) forgetDisconnectedConsumers(_ context.Context) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
-	if q.queues.forgetDisconnectedConsumers(time.Now()) > 0 {
+	now, forgotten := time.Now(), 0
+	for _, class := range q.classes {
+		forgotten += class.forgetDisconnectedConsumers(now)
+	}
+	if forgotten > 0 {
 		// We need to notify goroutines cause having removed some queriers
 		// may have caused a resharding.
 		q.cond.Broadcast()
@@ -260,7 +396,7 @@ func (q *RequestQueue) stopping(_ error) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
-	for !q.queues.hasNoTenantQueues() && q.connectedConsumers.Load() > 0 {
+	for !q.isEmpty() && q.connectedConsumers.Load() > 0 {
 		q.cond.Wait(context.Background())
 	}
 
@@ -278,7 +414,9 @@ func (q *RequestQueue) RegisterConsumerConnection(querier string) {
 
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
-	q.queues.addConsumerToConnection(querier)
+	for _, class := range q.classes {
+		class.addConsumerToConnection(querier)
+	}
 }
 
 func (q *RequestQueue) UnregisterConsumerConnection(querier string) {
@@ -286,13 +424,18 @@ func (q *RequestQueue) UnregisterConsumerConnection(querier string) {
 
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
-	q.queues.removeConsumerConnection(querier, time.Now())
+	now := time.Now()
+	for _, class := range q.classes {
+		class.removeConsumerConnection(querier, now)
+	}
 }
 
 func (q *RequestQueue) NotifyConsumerShutdown(querierID string) {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
-	q.queues.notifyQuerierShutdown(querierID)
+	for _, class := range q.classes {
+		class.notifyQuerierShutdown(querierID)
+	}
 }
 
 func (q *RequestQueue) GetConnectedConsumersMetric() float64 {
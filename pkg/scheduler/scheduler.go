@@ -103,10 +103,11 @@ type connectedFrontend struct {
 }
 
 type Config struct {
-	MaxOutstandingPerTenant int               `yaml:"max_outstanding_requests_per_tenant"`
-	MaxQueueHierarchyLevels int               `yaml:"max_queue_hierarchy_levels"`
-	QuerierForgetDelay      time.Duration     `yaml:"querier_forget_delay"`
-	GRPCClientConfig        grpcclient.Config `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
+	MaxOutstandingPerTenant int                  `yaml:"max_outstanding_requests_per_tenant"`
+	MaxQueueHierarchyLevels int                  `yaml:"max_queue_hierarchy_levels"`
+	PriorityClasses         queue.PriorityConfig `yaml:"priority_classes" doc:"description=Configures how queries of the priority classes set by the X-Loki-Query-Priority header are dequeued."`
+	QuerierForgetDelay      time.Duration        `yaml:"querier_forget_delay"`
+	GRPCClientConfig        grpcclient.Config    `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	// Schedulers ring
 	UseSchedulerRing bool                `yaml:"use_scheduler_ring"`
 	SchedulerRing    lokiring.RingConfig `yaml:"scheduler_ring,omitempty" doc:"description=The hash ring configuration. This option is required only if use_scheduler_ring is true."`
@@ -115,6 +116,7 @@ type Config struct {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 32000, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.IntVar(&cfg.MaxQueueHierarchyLevels, "query-scheduler.max-queue-hierarchy-levels", 3, "Maximum number of levels of nesting of hierarchical queues. 0 means that hierarchical queues are disabled.")
+	cfg.PriorityClasses.RegisterFlagsWithPrefix("query-scheduler.", f)
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	f.BoolVar(&cfg.UseSchedulerRing, "query-scheduler.use-scheduler-ring", false, "Set to true to have the query schedulers create and place themselves in a ring. If no frontend_address or scheduler_address are present anywhere else in the configuration, Loki will toggle this value to true.")
@@ -159,7 +161,7 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		connectedFrontends: map[string]*connectedFrontend{},
 		queueMetrics:       queueMetrics,
 		ringManager:        ringManager,
-		requestQueue:       queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, limits.NewQueueLimits(schedulerLimits), queueMetrics),
+		requestQueue:       queue.NewRequestQueueWithPriorities(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, limits.NewQueueLimits(schedulerLimits), cfg.PriorityClasses, queueMetrics),
 	}
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
@@ -224,6 +226,7 @@ type schedulerRequest struct {
 	request         *httpgrpc.HTTPRequest
 	queryRequest    *queryrange.QueryRequest
 	statsEnabled    bool
+	priority        queue.Priority
 
 	queueTime time.Time
 
@@ -393,8 +396,13 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		}
 	}
 
+	req.priority, err = queue.ParsePriority(msg.Priority)
+	if err != nil {
+		return fmt.Errorf("%s header: %w", lokihttpreq.LokiQueryPriorityHeader, err)
+	}
+
 	s.activeUsers.UpdateUserTimestamp(req.tenantID, now)
-	return s.requestQueue.Enqueue(req.tenantID, queuePath, req, func() {
+	return s.requestQueue.EnqueueWithPriority(req.tenantID, queuePath, req.priority, req, func() {
 		shouldCancel = false
 
 		s.pendingRequestsMu.Lock()
@@ -475,12 +483,15 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 		if r.ctx.Err() != nil {
 			// Remove from pending requests.
 			s.cancelRequestAndRemoveFromPending(r.frontendAddress, r.queryID)
+			s.requestQueue.Release(r.priority)
 
 			lastIndex = lastIndex.ReuseLastIndex()
 			continue
 		}
 
-		if err := s.forwardRequestToQuerier(querier, r); err != nil {
+		err = s.forwardRequestToQuerier(querier, r)
+		s.requestQueue.Release(r.priority)
+		if err != nil {
 			return err
 		}
 	}
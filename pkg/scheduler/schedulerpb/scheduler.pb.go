@@ -268,6 +268,8 @@ type FrontendToScheduler struct {
 	StatsEnabled bool                          `protobuf:"varint,6,opt,name=statsEnabled,proto3" json:"statsEnabled,omitempty"`
 	// Path to queue to which the request will be enqueued.
 	QueuePath []string `protobuf:"bytes,7,rep,name=queuePath,proto3" json:"queuePath,omitempty"`
+	// Priority class of the queue to which the request will be enqueued.
+	Priority string `protobuf:"bytes,9,opt,name=priority,proto3" json:"priority,omitempty"`
 }
 
 func (m *FrontendToScheduler) Reset()      { *m = FrontendToScheduler{} }
@@ -383,6 +385,13 @@ func (m *FrontendToScheduler) GetQueuePath() []string {
 	return nil
 }
 
+func (m *FrontendToScheduler) GetPriority() string {
+	if m != nil {
+		return m.Priority
+	}
+	return ""
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*FrontendToScheduler) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -536,52 +545,53 @@ func init() {
 }
 
 var fileDescriptor_c3657184e8d38989 = []byte{
-	// 714 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0x3d, 0x53, 0xdb, 0x4a,
-	0x14, 0xd5, 0xca, 0x5f, 0xf8, 0x9a, 0xf7, 0x9e, 0xdf, 0x02, 0xef, 0x29, 0x1e, 0x22, 0x3c, 0x9e,
-	0x4c, 0x62, 0x28, 0x6c, 0xc6, 0x69, 0x52, 0x10, 0x66, 0x0c, 0x88, 0xd8, 0x13, 0x22, 0x63, 0x59,
-	0x9e, 0x7c, 0x34, 0x1e, 0x7f, 0x2c, 0xb6, 0x07, 0xa2, 0x15, 0x2b, 0x69, 0x32, 0xee, 0xf2, 0x13,
-	0xf2, 0x0b, 0x52, 0xe7, 0xa7, 0xa4, 0xa4, 0xa4, 0x48, 0x11, 0x44, 0x93, 0x92, 0x2e, 0x6d, 0xc6,
-	0xd2, 0xca, 0xc8, 0x60, 0x43, 0xaa, 0x54, 0xbe, 0x7b, 0xf7, 0x9c, 0xab, 0x3d, 0xe7, 0xde, 0x5d,
-	0xc3, 0xba, 0x79, 0xdc, 0x2f, 0x5a, 0xdd, 0x01, 0xe9, 0x39, 0x27, 0x84, 0x5d, 0x47, 0x66, 0xe7,
-	0x3a, 0x2e, 0x98, 0x8c, 0xda, 0x14, 0xa7, 0x42, 0x9b, 0x99, 0xcd, 0xfe, 0xd0, 0x1e, 0x38, 0x9d,
-	0x42, 0x97, 0xbe, 0x2f, 0xf6, 0x59, 0xfb, 0xa8, 0x6d, 0xb4, 0x8b, 0x3d, 0xeb, 0x78, 0x68, 0x17,
-	0x07, 0xb6, 0x6d, 0xf6, 0x99, 0xd9, 0x9d, 0x04, 0x3e, 0x3d, 0xb3, 0xdc, 0xa7, 0x7d, 0xea, 0x85,
-	0xc5, 0x71, 0xc4, 0xb3, 0x4f, 0xc6, 0xdf, 0x3f, 0x75, 0x08, 0x1b, 0x12, 0xe6, 0xfd, 0x8e, 0x58,
-	0xdb, 0xe8, 0x93, 0x50, 0xe8, 0x03, 0x73, 0x25, 0xc0, 0x75, 0x1f, 0xa6, 0xd3, 0x46, 0x70, 0x10,
-	0xbc, 0x0a, 0x49, 0x4e, 0xae, 0xee, 0x49, 0x28, 0x8b, 0xf2, 0x49, 0xed, 0x3a, 0x91, 0xfb, 0x2c,
-	0x02, 0x9e, 0x60, 0x75, 0xca, 0xf9, 0x58, 0x82, 0x84, 0x57, 0x9e, 0x53, 0xa2, 0x5a, 0xb0, 0xc4,
-	0xcf, 0x21, 0x35, 0x3e, 0xb5, 0x46, 0x4e, 0x1d, 0x62, 0xd9, 0x92, 0x98, 0x45, 0xf9, 0x54, 0x69,
-	0xa5, 0x30, 0x51, 0x52, 0xd1, 0xf5, 0x43, 0xbe, 0xb9, 0x23, 0x4a, 0xa8, 0x22, 0x68, 0x61, 0x3c,
-	0xde, 0x86, 0x45, 0xaf, 0x52, 0xc0, 0x5f, 0xf0, 0xf8, 0x52, 0x21, 0x24, 0xa6, 0x1e, 0xda, 0xaf,
-	0x08, 0xda, 0x14, 0x1e, 0xe7, 0xe1, 0x9f, 0x23, 0x46, 0x0d, 0x9b, 0x18, 0xbd, 0x72, 0xaf, 0xc7,
-	0x88, 0x65, 0x49, 0x11, 0x4f, 0xd3, 0xcd, 0x34, 0xfe, 0x0f, 0xe2, 0x8e, 0xe5, 0x89, 0x8e, 0x7a,
-	0x00, 0xbe, 0xc2, 0x39, 0x58, 0xb4, 0xec, 0xb6, 0x6d, 0x29, 0x46, 0xbb, 0x73, 0x42, 0x7a, 0x52,
-	0x2c, 0x8b, 0xf2, 0x0b, 0xda, 0x54, 0x6e, 0x27, 0x09, 0x09, 0xe6, 0x7f, 0x30, 0xf7, 0x53, 0x84,
-	0xa5, 0x7d, 0x5e, 0x3a, 0x6c, 0xeb, 0x33, 0x88, 0xda, 0x23, 0x93, 0x78, 0xf6, 0xfc, 0x5d, 0x7a,
-	0x54, 0x08, 0x75, 0xbe, 0x30, 0x03, 0xaf, 0x8f, 0x4c, 0xa2, 0x79, 0x8c, 0x59, 0x12, 0xc4, 0xd9,
-	0x12, 0x42, 0x5d, 0x88, 0x4c, 0x77, 0x61, 0x9e, 0xb8, 0x1b, 0xdd, 0x89, 0xfd, 0xe1, 0xee, 0xdc,
-	0xf4, 0x36, 0x7e, 0xdb, 0x5b, 0x3e, 0x8f, 0x0e, 0x39, 0x6c, 0xdb, 0x03, 0x29, 0x91, 0x8d, 0xf0,
-	0x79, 0xf4, 0x13, 0x61, 0xe7, 0x8f, 0x61, 0x29, 0x34, 0x99, 0x81, 0xa7, 0x78, 0x1b, 0xe2, 0xe3,
-	0x7a, 0x8e, 0xc5, 0xad, 0x7f, 0x3c, 0x65, 0xfd, 0x0c, 0x46, 0xc3, 0x43, 0x6b, 0x9c, 0x85, 0x97,
-	0x21, 0x46, 0x18, 0xa3, 0x8c, 0x9b, 0xee, 0x2f, 0x72, 0x5b, 0xb0, 0xaa, 0x52, 0x7b, 0x78, 0x34,
-	0xe2, 0x37, 0xa0, 0x31, 0x70, 0xec, 0x1e, 0xfd, 0x60, 0x04, 0xca, 0xee, 0xbe, 0x45, 0x6b, 0xf0,
-	0x70, 0x0e, 0xdb, 0x32, 0xa9, 0x61, 0x91, 0x8d, 0x2d, 0xf8, 0x7f, 0xce, 0x50, 0xe0, 0x05, 0x88,
-	0x56, 0xd5, 0xaa, 0x9e, 0x16, 0x70, 0x0a, 0x12, 0x8a, 0x5a, 0x6f, 0x2a, 0x4d, 0x25, 0x8d, 0x30,
-	0x40, 0x7c, 0xb7, 0xac, 0xee, 0x2a, 0x07, 0x69, 0x71, 0xa3, 0x0b, 0x0f, 0xe6, 0xea, 0xc2, 0x71,
-	0x10, 0x6b, 0x2f, 0xd3, 0x02, 0xce, 0xc2, 0xaa, 0x5e, 0xab, 0xb5, 0x5e, 0x95, 0xd5, 0xb7, 0x2d,
-	0x4d, 0xa9, 0x37, 0x95, 0x86, 0xde, 0x68, 0x1d, 0x2a, 0x5a, 0x4b, 0x57, 0xd4, 0xb2, 0xaa, 0xa7,
-	0x11, 0x4e, 0x42, 0x4c, 0xd1, 0xb4, 0x9a, 0x96, 0x16, 0xf1, 0xbf, 0xf0, 0x57, 0xa3, 0xd2, 0xd4,
-	0xf5, 0xaa, 0xfa, 0xa2, 0xb5, 0x57, 0x7b, 0xad, 0xa6, 0x23, 0xa5, 0x6f, 0x28, 0xe4, 0xf7, 0x3e,
-	0x65, 0xc1, 0x53, 0xd0, 0x84, 0x14, 0x0f, 0x0f, 0x28, 0x35, 0xf1, 0xda, 0x94, 0xdd, 0xb7, 0xdf,
-	0x9b, 0xcc, 0xda, 0xbc, 0x7e, 0x70, 0x6c, 0x4e, 0xc8, 0xa3, 0x4d, 0x84, 0x0d, 0x58, 0x99, 0x69,
-	0x19, 0x5e, 0x9f, 0xe2, 0xdf, 0xd5, 0x94, 0xcc, 0xc6, 0xef, 0x40, 0xfd, 0x0e, 0x94, 0x4c, 0x58,
-	0x0e, 0xab, 0x9b, 0x8c, 0xd3, 0x1b, 0x58, 0x0c, 0x62, 0x4f, 0x5f, 0xf6, 0xbe, 0x9b, 0x9c, 0xc9,
-	0xde, 0x37, 0x70, 0xbe, 0xc2, 0x9d, 0xf2, 0xd9, 0x85, 0x2c, 0x9c, 0x5f, 0xc8, 0xc2, 0xd5, 0x85,
-	0x8c, 0x3e, 0xba, 0x32, 0xfa, 0xe2, 0xca, 0xe8, 0xab, 0x2b, 0xa3, 0x33, 0x57, 0x46, 0xdf, 0x5d,
-	0x19, 0xfd, 0x70, 0x65, 0xe1, 0xca, 0x95, 0xd1, 0xa7, 0x4b, 0x59, 0x38, 0xbb, 0x94, 0x85, 0xf3,
-	0x4b, 0x59, 0x78, 0x17, 0xfe, 0x0b, 0xe9, 0xc4, 0xbd, 0x87, 0xfd, 0xe9, 0xaf, 0x00, 0x00, 0x00,
-	0xff, 0xff, 0x0a, 0x15, 0x06, 0xe2, 0x83, 0x06, 0x00, 0x00,
+	// 726 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0xcd, 0x52, 0x1a, 0x4d,
+	0x14, 0x9d, 0xe6, 0x9f, 0x8b, 0xdf, 0x17, 0xd2, 0x6a, 0x32, 0xa1, 0xcc, 0x48, 0x51, 0xa9, 0x04,
+	0x5d, 0x80, 0x45, 0x36, 0x59, 0x18, 0xab, 0x50, 0xc7, 0x40, 0xc5, 0x0c, 0x32, 0x0c, 0x95, 0x9f,
+	0x0d, 0xc5, 0x4f, 0x0b, 0x94, 0x66, 0x7a, 0xec, 0x99, 0xa9, 0x14, 0xbb, 0x3c, 0x42, 0xaa, 0xb2,
+	0xcf, 0x3a, 0x8f, 0x92, 0xa5, 0x4b, 0x17, 0x59, 0x44, 0xdc, 0x64, 0xe9, 0x23, 0xa4, 0xe8, 0x69,
+	0x70, 0x50, 0xd0, 0xac, 0xb2, 0xe2, 0xde, 0xdb, 0xe7, 0x74, 0xcf, 0xb9, 0xe7, 0x76, 0x03, 0x6b,
+	0xd6, 0x51, 0x37, 0x6f, 0xb7, 0x7b, 0xa4, 0xe3, 0x1e, 0x13, 0x76, 0x15, 0x59, 0xad, 0xab, 0x38,
+	0x67, 0x31, 0xea, 0x50, 0x9c, 0xf0, 0x2d, 0xa6, 0x36, 0xba, 0x7d, 0xa7, 0xe7, 0xb6, 0x72, 0x6d,
+	0xfa, 0x31, 0xdf, 0x65, 0xcd, 0xc3, 0xa6, 0xd9, 0xcc, 0x77, 0xec, 0xa3, 0xbe, 0x93, 0xef, 0x39,
+	0x8e, 0xd5, 0x65, 0x56, 0x7b, 0x12, 0x78, 0xf4, 0xd4, 0x52, 0x97, 0x76, 0x29, 0x0f, 0xf3, 0xa3,
+	0x48, 0x54, 0x9f, 0x8d, 0xce, 0x3f, 0x71, 0x09, 0xeb, 0x13, 0xc6, 0x7f, 0x07, 0xac, 0x69, 0x76,
+	0x89, 0x2f, 0xf4, 0x80, 0x99, 0x02, 0xe0, 0xaa, 0x07, 0x33, 0x68, 0x6d, 0xfc, 0x21, 0x78, 0x05,
+	0xe2, 0x82, 0x5c, 0xde, 0x95, 0x51, 0x1a, 0x65, 0xe3, 0xfa, 0x55, 0x21, 0xf3, 0x2d, 0x00, 0x78,
+	0x82, 0x35, 0xa8, 0xe0, 0x63, 0x19, 0xa2, 0x7c, 0x7b, 0x41, 0x09, 0xe9, 0xe3, 0x14, 0xbf, 0x84,
+	0xc4, 0xe8, 0xab, 0x75, 0x72, 0xe2, 0x12, 0xdb, 0x91, 0x03, 0x69, 0x94, 0x4d, 0x14, 0x96, 0x73,
+	0x13, 0x25, 0x25, 0xc3, 0x38, 0x10, 0x8b, 0xdb, 0x01, 0x19, 0x95, 0x24, 0xdd, 0x8f, 0xc7, 0x5b,
+	0xb0, 0xc0, 0x77, 0x1a, 0xf3, 0x63, 0x9c, 0x2f, 0xe7, 0x7c, 0x62, 0xaa, 0xbe, 0xf5, 0x92, 0xa4,
+	0x4f, 0xe1, 0x71, 0x16, 0xee, 0x1d, 0x32, 0x6a, 0x3a, 0xc4, 0xec, 0x14, 0x3b, 0x1d, 0x46, 0x6c,
+	0x5b, 0x0e, 0x72, 0x4d, 0xd7, 0xcb, 0xf8, 0x01, 0x44, 0x5c, 0x9b, 0x8b, 0x0e, 0x71, 0x80, 0xc8,
+	0x70, 0x06, 0x16, 0x6c, 0xa7, 0xe9, 0xd8, 0xaa, 0xd9, 0x6c, 0x1d, 0x93, 0x8e, 0x1c, 0x4e, 0xa3,
+	0x6c, 0x4c, 0x9f, 0xaa, 0x6d, 0xc7, 0x21, 0xca, 0xbc, 0x03, 0x33, 0x5f, 0x83, 0xb0, 0xb8, 0x27,
+	0xb6, 0xf6, 0xb7, 0xf5, 0x05, 0x84, 0x9c, 0x81, 0x45, 0x78, 0x7b, 0xfe, 0x2f, 0x3c, 0xc9, 0xf9,
+	0x9c, 0xcf, 0xcd, 0xc0, 0x1b, 0x03, 0x8b, 0xe8, 0x9c, 0x31, 0x4b, 0x42, 0x60, 0xb6, 0x04, 0x9f,
+	0x0b, 0xc1, 0x69, 0x17, 0xe6, 0x89, 0xbb, 0xe6, 0x4e, 0xf8, 0x1f, 0xbb, 0x73, 0xbd, 0xb7, 0x91,
+	0x9b, 0xbd, 0x15, 0xf3, 0xe8, 0x92, 0x83, 0xa6, 0xd3, 0x93, 0xa3, 0xe9, 0xa0, 0x98, 0x47, 0xaf,
+	0x80, 0x53, 0x10, 0xb3, 0x58, 0x9f, 0xb2, 0xbe, 0x33, 0x90, 0xe3, 0x5c, 0xda, 0x24, 0xf7, 0xbb,
+	0x72, 0x04, 0x8b, 0xbe, 0xa9, 0x1d, 0xf7, 0x1b, 0x6f, 0x41, 0x64, 0x74, 0x96, 0x6b, 0x0b, 0x5b,
+	0x9e, 0x4e, 0xd9, 0x32, 0x83, 0x51, 0xe3, 0x68, 0x5d, 0xb0, 0xf0, 0x12, 0x84, 0x09, 0x63, 0x94,
+	0x09, 0x43, 0xbc, 0x24, 0xb3, 0x09, 0x2b, 0x1a, 0x75, 0xfa, 0x87, 0x03, 0x71, 0x3b, 0x6a, 0x3d,
+	0xd7, 0xe9, 0xd0, 0x4f, 0xe6, 0x58, 0xf5, 0xed, 0x37, 0x6c, 0x15, 0x1e, 0xcf, 0x61, 0xdb, 0x16,
+	0x35, 0x6d, 0xb2, 0xbe, 0x09, 0x0f, 0xe7, 0x0c, 0x0c, 0x8e, 0x41, 0xa8, 0xac, 0x95, 0x8d, 0xa4,
+	0x84, 0x13, 0x10, 0x55, 0xb5, 0x6a, 0x5d, 0xad, 0xab, 0x49, 0x84, 0x01, 0x22, 0x3b, 0x45, 0x6d,
+	0x47, 0xdd, 0x4f, 0x06, 0xd6, 0xdb, 0xf0, 0x68, 0xae, 0x2e, 0x1c, 0x81, 0x40, 0xe5, 0x75, 0x52,
+	0xc2, 0x69, 0x58, 0x31, 0x2a, 0x95, 0xc6, 0x9b, 0xa2, 0xf6, 0xbe, 0xa1, 0xab, 0xd5, 0xba, 0x5a,
+	0x33, 0x6a, 0x8d, 0x03, 0x55, 0x6f, 0x18, 0xaa, 0x56, 0xd4, 0x8c, 0x24, 0xc2, 0x71, 0x08, 0xab,
+	0xba, 0x5e, 0xd1, 0x93, 0x01, 0x7c, 0x1f, 0xfe, 0xab, 0x95, 0xea, 0x86, 0x51, 0xd6, 0x5e, 0x35,
+	0x76, 0x2b, 0x6f, 0xb5, 0x64, 0xb0, 0xf0, 0x13, 0xf9, 0xfa, 0xbd, 0x47, 0xd9, 0xf8, 0x99, 0xa8,
+	0x43, 0x42, 0x84, 0xfb, 0x94, 0x5a, 0x78, 0x75, 0xaa, 0xdd, 0x37, 0xdf, 0xa2, 0xd4, 0xea, 0x3c,
+	0x3f, 0x04, 0x36, 0x23, 0x65, 0xd1, 0x06, 0xc2, 0x26, 0x2c, 0xcf, 0x6c, 0x19, 0x5e, 0x9b, 0xe2,
+	0xdf, 0x66, 0x4a, 0x6a, 0xfd, 0x6f, 0xa0, 0x9e, 0x03, 0x05, 0x0b, 0x96, 0xfc, 0xea, 0x26, 0xe3,
+	0xf4, 0x0e, 0x16, 0xc6, 0x31, 0xd7, 0x97, 0xbe, 0xeb, 0x96, 0xa7, 0xd2, 0x77, 0x0d, 0x9c, 0xa7,
+	0x70, 0xbb, 0x78, 0x7a, 0xae, 0x48, 0x67, 0xe7, 0x8a, 0x74, 0x79, 0xae, 0xa0, 0xcf, 0x43, 0x05,
+	0x7d, 0x1f, 0x2a, 0xe8, 0xc7, 0x50, 0x41, 0xa7, 0x43, 0x05, 0xfd, 0x1a, 0x2a, 0xe8, 0xf7, 0x50,
+	0x91, 0x2e, 0x87, 0x0a, 0xfa, 0x72, 0xa1, 0x48, 0xa7, 0x17, 0x8a, 0x74, 0x76, 0xa1, 0x48, 0x1f,
+	0xfc, 0x7f, 0x2f, 0xad, 0x08, 0x7f, 0xf4, 0x9f, 0xff, 0x09, 0x00, 0x00, 0xff, 0xff, 0x6e, 0xaa,
+	0xaa, 0x63, 0x9f, 0x06, 0x00, 0x00,
 }
 
 func (x FrontendToSchedulerType) String() string {
@@ -763,6 +773,9 @@ func (this *FrontendToScheduler) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if this.Priority != that1.Priority {
+		return false
+	}
 	return true
 }
 func (this *FrontendToScheduler_HttpRequest) Equal(that interface{}) bool {
@@ -931,7 +944,7 @@ func (this *FrontendToScheduler) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 12)
+	s := make([]string, 0, 13)
 	s = append(s, "&schedulerpb.FrontendToScheduler{")
 	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
 	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
@@ -942,6 +955,7 @@ func (this *FrontendToScheduler) GoString() string {
 	}
 	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
 	s = append(s, "QueuePath: "+fmt.Sprintf("%#v", this.QueuePath)+",\n")
+	s = append(s, "Priority: "+fmt.Sprintf("%#v", this.Priority)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1430,6 +1444,13 @@ func (m *FrontendToScheduler) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Priority) > 0 {
+		i -= len(m.Priority)
+		copy(dAtA[i:], m.Priority)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.Priority)))
+		i--
+		dAtA[i] = 0x4a
+	}
 	if m.Request != nil {
 		{
 			size := m.Request.Size()
@@ -1719,6 +1740,10 @@ func (m *FrontendToScheduler) Size() (n int) {
 			n += 1 + l + sovScheduler(uint64(l))
 		}
 	}
+	l = len(m.Priority)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
 	return n
 }
 
@@ -1846,6 +1871,7 @@ func (this *FrontendToScheduler) String() string {
 		`Request:` + fmt.Sprintf("%v", this.Request) + `,`,
 		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
 		`QueuePath:` + fmt.Sprintf("%v", this.QueuePath) + `,`,
+		`Priority:` + fmt.Sprintf("%v", this.Priority) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2472,6 +2498,38 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			}
 			m.Request = &FrontendToScheduler_QueryRequest{v}
 			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priority", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Priority = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
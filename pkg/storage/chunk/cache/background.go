@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/user"
 	opentracing "github.com/opentracing/opentracing-go"
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -51,8 +52,11 @@ type backgroundCache struct {
 }
 
 type backgroundWrite struct {
-	keys []string
-	bufs [][]byte
+	// orgID is the tenant the keys are written for, as caches such as the
+	// object store cache depend on it.
+	orgID string
+	keys  []string
+	bufs  [][]byte
 }
 
 func (b *backgroundWrite) size() int {
@@ -136,6 +140,7 @@ const keysPerBatch = 100
 
 // Store writes keys for the cache in the background.
 func (c *backgroundCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	orgID, _ := user.ExtractOrgID(ctx)
 	for len(keys) > 0 {
 		num := keysPerBatch
 		if num > len(keys) {
@@ -143,8 +148,9 @@ func (c *backgroundCache) Store(ctx context.Context, keys []string, bufs [][]byt
 		}
 
 		bgWrite := backgroundWrite{
-			keys: keys[:num],
-			bufs: bufs[:num],
+			orgID: orgID,
+			keys:  keys[:num],
+			bufs:  bufs[:num],
 		}
 
 		size := bgWrite.size()
@@ -195,7 +201,11 @@ func (c *backgroundCache) writeBackLoop() {
 			c.queueLength.Sub(float64(len(bgWrite.keys)))
 			c.queueBytes.Set(float64(c.size.Load()))
 			c.dequeuedBytes.Add(float64(bgWrite.size()))
-			err := c.Cache.Store(context.Background(), bgWrite.keys, bgWrite.bufs)
+			ctx := context.Background()
+			if bgWrite.orgID != "" {
+				ctx = user.InjectOrgID(ctx, bgWrite.orgID)
+			}
+			err := c.Cache.Store(ctx, bgWrite.keys, bgWrite.bufs)
 			if err != nil {
 				level.Warn(util_log.Logger).Log("msg", "backgroundCache writeBackLoop Cache.Store fail", "err", err)
 				continue
@@ -12,6 +12,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/storage/bucket"
 )
 
 // Cache byte arrays by key.
@@ -27,11 +28,12 @@ type Cache interface {
 type Config struct {
 	DefaultValidity time.Duration `yaml:"default_validity"`
 
-	Background     BackgroundConfig      `yaml:"background"`
-	Memcache       MemcachedConfig       `yaml:"memcached"`
-	MemcacheClient MemcachedClientConfig `yaml:"memcached_client"`
-	Redis          RedisConfig           `yaml:"redis"`
-	EmbeddedCache  EmbeddedCacheConfig   `yaml:"embedded_cache"`
+	Background     BackgroundConfig       `yaml:"background"`
+	Memcache       MemcachedConfig        `yaml:"memcached"`
+	MemcacheClient MemcachedClientConfig  `yaml:"memcached_client"`
+	Redis          RedisConfig            `yaml:"redis"`
+	EmbeddedCache  EmbeddedCacheConfig    `yaml:"embedded_cache"`
+	ObjectStore    ObjectStoreCacheConfig `yaml:"object_store" doc:"description=The object store is used as the lowest tier of the cache, below the other configured caches."`
 
 	// This is to name the cache metrics properly.
 	Prefix string `yaml:"prefix" doc:"hidden"`
@@ -47,6 +49,7 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, description string, f
 	cfg.MemcacheClient.RegisterFlagsWithPrefix(prefix, description, f)
 	cfg.Redis.RegisterFlagsWithPrefix(prefix, description, f)
 	cfg.EmbeddedCache.RegisterFlagsWithPrefix(prefix+"embedded-cache.", description, f)
+	cfg.ObjectStore.RegisterFlagsWithPrefix(prefix, description, f)
 	f.DurationVar(&cfg.DefaultValidity, prefix+"default-validity", time.Hour, description+"The default validity of entries for caches unless overridden.")
 
 	cfg.Prefix = prefix
@@ -71,6 +74,10 @@ func IsEmbeddedCacheSet(cfg Config) bool {
 	return cfg.EmbeddedCache.Enabled
 }
 
+func IsObjectStoreSet(cfg Config) bool {
+	return cfg.ObjectStore.Enabled
+}
+
 func IsSpecificImplementationSet(cfg Config) bool {
 	return cfg.Cache != nil
 }
@@ -79,9 +86,10 @@ func IsSpecificImplementationSet(cfg Config) bool {
 // - memcached
 // - redis
 // - embedded-cache
+// - object store
 // - specific cache implementation
 func IsCacheConfigured(cfg Config) bool {
-	return IsMemcacheSet(cfg) || IsRedisSet(cfg) || IsEmbeddedCacheSet(cfg) || IsSpecificImplementationSet(cfg)
+	return IsMemcacheSet(cfg) || IsRedisSet(cfg) || IsEmbeddedCacheSet(cfg) || IsObjectStoreSet(cfg) || IsSpecificImplementationSet(cfg)
 }
 
 // New creates a new Cache using Config.
@@ -133,6 +141,20 @@ func New(cfg Config, reg prometheus.Registerer, logger log.Logger, cacheType sta
 		caches = append(caches, CollectStats(NewBackground(cacheName, cfg.Background, Instrument(cacheName, cache, reg), reg)))
 	}
 
+	// The object store is the slowest cache, so it is the lowest tier.
+	if IsObjectStoreSet(cfg) {
+		if cfg.ObjectStore.TTL == 0 && cfg.DefaultValidity != 0 {
+			cfg.ObjectStore.TTL = cfg.DefaultValidity
+		}
+		cacheName := cfg.Prefix + "object-store"
+		client, err := bucket.NewClient(context.Background(), cfg.ObjectStore.Config, cacheName, logger, reg)
+		if err != nil {
+			return nil, fmt.Errorf("object store client setup failed: %w", err)
+		}
+		cache := NewObjectStoreCache(cacheName, client, cfg.ObjectStore.TTL, cfg.ObjectStore.SweepInterval, logger, cacheType)
+		caches = append(caches, CollectStats(NewBackground(cacheName, cfg.Background, Instrument(cacheName, cache, reg), reg)))
+	}
+
 	cache := NewTiered(caches)
 	if len(caches) > 1 {
 		cache = Instrument(cfg.Prefix+"tiered", cache, reg)
@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/user"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/storage/bucket"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+const (
+	// objectStoreSharedTenant is the tenant of the entries stored without a tenant.
+	objectStoreSharedTenant = "_shared"
+	// objectStoreMagic and objectStoreFormatV1 start the header of the entries,
+	// followed by their expiry, so that the sweep never deletes objects which
+	// were not written by the cache.
+	objectStoreMagic     = "LKOC"
+	objectStoreFormatV1  = byte(1)
+	objectStoreHeaderLen = len(objectStoreMagic) + 1 + 8
+
+	objectStoreFetchConcurrency = 16
+)
+
+// ObjectStoreCacheConfig defines how an ObjectStoreCache should be constructed.
+type ObjectStoreCacheConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	TTL           time.Duration `yaml:"ttl"`
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+
+	bucket.Config `yaml:",inline"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet
+func (cfg *ObjectStoreCacheConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"object-store.enabled", false, description+"Whether to use an object store as the lowest tier of the cache. Entries are stored under a directory named after the cache, with a directory per tenant. Only the entries written by the cache under this directory are deleted when they expire.")
+	f.DurationVar(&cfg.TTL, prefix+"object-store.ttl", 0, description+"How long entries are kept in the object store. Defaults to the default validity of the cache if 0.")
+	f.DurationVar(&cfg.SweepInterval, prefix+"object-store.sweep-interval", time.Hour, description+"How often expired entries are deleted from the object store.")
+	cfg.Config.RegisterFlagsWithPrefix(prefix+"object-store.", f)
+}
+
+// ObjectStoreCache caches entries as objects of an object store, under a
+// directory named after the cache with a directory per tenant. The entries
+// expire after their TTL, and the expired entries are deleted by a periodic
+// sweep of the directory of the cache.
+type ObjectStoreCache struct {
+	name      string
+	prefix    string
+	cacheType stats.CacheType
+	client    objstore.Bucket
+	ttl       time.Duration
+	logger    log.Logger
+	now       func() time.Time
+
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewObjectStoreCache creates a new ObjectStoreCache storing its entries in
+// the bucket. Expired entries are swept every sweepInterval, if greater than 0.
+func NewObjectStoreCache(name string, client objstore.Bucket, ttl, sweepInterval time.Duration, logger log.Logger, cacheType stats.CacheType) *ObjectStoreCache {
+	util_log.WarnExperimentalUse(fmt.Sprintf("Object store cache - %s", name), logger)
+	c := &ObjectStoreCache{
+		name:      name,
+		prefix:    name + objstore.DirDelim,
+		cacheType: cacheType,
+		client:    client,
+		ttl:       ttl,
+		logger:    logger,
+		now:       time.Now,
+		quit:      make(chan struct{}),
+	}
+	if sweepInterval > 0 {
+		c.wg.Add(1)
+		go c.sweepLoop(sweepInterval)
+	}
+	return c
+}
+
+// objectName returns the name of the object of the key for the tenant of the
+// context.
+func (c *ObjectStoreCache) objectName(ctx context.Context, key string) string {
+	tenantID, err := user.ExtractOrgID(ctx)
+	if err != nil || tenantID == "" {
+		tenantID = objectStoreSharedTenant
+	}
+	return c.prefix + tenantID + objstore.DirDelim + key
+}
+
+// Fetch gets keys from the cache. The keys that are found must be in the order of the keys requested.
+func (c *ObjectStoreCache) Fetch(ctx context.Context, keys []string) (found []string, bufs [][]byte, missed []string, err error) {
+	results := make([][]byte, len(keys))
+	err = concurrency.ForEachJob(ctx, len(keys), objectStoreFetchConcurrency, func(ctx context.Context, i int) error {
+		buf, err := c.fetch(ctx, c.objectName(ctx, keys[i]))
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to get from object store", "name", c.name, "key", keys[i], "err", err)
+			return nil
+		}
+		results[i] = buf
+		return nil
+	})
+	if err != nil {
+		missed = make([]string, len(keys))
+		copy(missed, keys)
+		return nil, nil, missed, err
+	}
+
+	for i, key := range keys {
+		if results[i] != nil {
+			found = append(found, key)
+			bufs = append(bufs, results[i])
+		} else {
+			missed = append(missed, key)
+		}
+	}
+	return
+}
+
+// fetch returns the entry of the object, nil if it doesn't exist or is expired.
+func (c *ObjectStoreCache) fetch(ctx context.Context, name string) ([]byte, error) {
+	r, err := c.client.Get(ctx, name)
+	if err != nil {
+		if c.client.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !validHeader(buf) {
+		return nil, errors.New("invalid cache entry header")
+	}
+	if c.expired(buf) {
+		return nil, nil
+	}
+	return buf[objectStoreHeaderLen:], nil
+}
+
+// validHeader returns whether the object starts with the header of the
+// entries written by the cache.
+func validHeader(header []byte) bool {
+	return len(header) >= objectStoreHeaderLen &&
+		string(header[:len(objectStoreMagic)]) == objectStoreMagic &&
+		header[len(objectStoreMagic)] == objectStoreFormatV1
+}
+
+func (c *ObjectStoreCache) expired(header []byte) bool {
+	expiry := time.Unix(0, int64(binary.BigEndian.Uint64(header[len(objectStoreMagic)+1:objectStoreHeaderLen])))
+	return !c.now().Before(expiry)
+}
+
+// Store stores the key in the cache.
+func (c *ObjectStoreCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	header := make([]byte, 0, objectStoreHeaderLen)
+	header = append(append(header, objectStoreMagic...), objectStoreFormatV1)
+	header = binary.BigEndian.AppendUint64(header, uint64(c.now().Add(c.ttl).UnixNano()))
+
+	var lastErr error
+	for i, key := range keys {
+		entry := make([]byte, 0, objectStoreHeaderLen+len(bufs[i]))
+		entry = append(append(entry, header...), bufs[i]...)
+		if err := c.client.Upload(ctx, c.objectName(ctx, key), bytes.NewReader(entry)); err != nil {
+			level.Error(c.logger).Log("msg", "failed to put to object store", "name", c.name, "key", key, "err", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Sweep deletes the expired entries. Objects under the directory of the cache
+// which were not written by it are left alone.
+func (c *ObjectStoreCache) Sweep(ctx context.Context) error {
+	return c.client.Iter(ctx, c.prefix, func(name string) error {
+		if strings.HasSuffix(name, objstore.DirDelim) {
+			return nil
+		}
+		r, err := c.client.GetRange(ctx, name, 0, int64(objectStoreHeaderLen))
+		if err != nil {
+			if c.client.IsObjNotFoundErr(err) {
+				return nil
+			}
+			return err
+		}
+		header, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if !validHeader(header) {
+			level.Warn(c.logger).Log("msg", "skipping object not written by the object store cache", "name", c.name, "object", name)
+			return nil
+		}
+		if !c.expired(header) {
+			return nil
+		}
+		return c.delete(ctx, name)
+	}, objstore.WithRecursiveIter)
+}
+
+func (c *ObjectStoreCache) delete(ctx context.Context, name string) error {
+	if err := c.client.Delete(ctx, name); err != nil && !c.client.IsObjNotFoundErr(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *ObjectStoreCache) sweepLoop(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Sweep(context.Background()); err != nil {
+				level.Warn(c.logger).Log("msg", "failed to sweep expired entries of object store cache", "name", c.name, "err", err)
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Stop stops the sweep and closes the object store client.
+func (c *ObjectStoreCache) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.quit)
+		c.wg.Wait()
+		_ = c.client.Close()
+	})
+}
+
+func (c *ObjectStoreCache) GetCacheType() stats.CacheType {
+	return c.cacheType
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/storage/bucket/filesystem"
+)
+
+func newTestObjectStoreCache(t *testing.T, ttl time.Duration) (*ObjectStoreCache, string) {
+	dir := t.TempDir()
+	client, err := filesystem.NewBucketClient(filesystem.Config{Directory: dir})
+	require.NoError(t, err)
+
+	c := NewObjectStoreCache("test", client, ttl, 0, log.NewNopLogger(), stats.ResultCache)
+	t.Cleanup(c.Stop)
+	return c, dir
+}
+
+func TestObjectStoreCache(t *testing.T) {
+	c, dir := newTestObjectStoreCache(t, time.Hour)
+	ctx := user.InjectOrgID(context.Background(), "tenant-a")
+
+	require.NoError(t, c.Store(ctx, []string{"key1", "key2"}, [][]byte{[]byte("hello"), []byte("world")}))
+	require.FileExists(t, filepath.Join(dir, "test", "tenant-a", "key1"))
+
+	found, bufs, missing, err := c.Fetch(ctx, []string{"key1", "key2", "key3"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1", "key2"}, found)
+	require.Equal(t, [][]byte{[]byte("hello"), []byte("world")}, bufs)
+	require.Equal(t, []string{"key3"}, missing)
+
+	// The entries of a tenant are not visible to other tenants.
+	found, _, missing, err = c.Fetch(user.InjectOrgID(context.Background(), "tenant-b"), []string{"key1"})
+	require.NoError(t, err)
+	require.Empty(t, found)
+	require.Equal(t, []string{"key1"}, missing)
+
+	// Entries stored without a tenant are shared.
+	require.NoError(t, c.Store(context.Background(), []string{"key1"}, [][]byte{[]byte("shared")}))
+	require.FileExists(t, filepath.Join(dir, "test", objectStoreSharedTenant, "key1"))
+}
+
+func TestObjectStoreCache_Expiry(t *testing.T) {
+	c, dir := newTestObjectStoreCache(t, time.Hour)
+	ctx := user.InjectOrgID(context.Background(), "tenant-a")
+	now := time.Now()
+
+	c.now = func() time.Time { return now.Add(-90 * time.Minute) }
+	require.NoError(t, c.Store(ctx, []string{"old"}, [][]byte{[]byte("old")}))
+	c.now = func() time.Time { return now }
+	require.NoError(t, c.Store(ctx, []string{"new"}, [][]byte{[]byte("new")}))
+
+	found, _, missing, err := c.Fetch(ctx, []string{"old", "new"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"new"}, found)
+	require.Equal(t, []string{"old"}, missing)
+
+	require.NoError(t, c.Sweep(context.Background()))
+	require.NoFileExists(t, filepath.Join(dir, "test", "tenant-a", "old"))
+	require.FileExists(t, filepath.Join(dir, "test", "tenant-a", "new"))
+}
+
+func TestObjectStoreCache_SweepForeignObjects(t *testing.T) {
+	c, dir := newTestObjectStoreCache(t, time.Hour)
+	foreign := []string{
+		filepath.Join(dir, "chunks", "object"),
+		filepath.Join(dir, "test", "tenant-a", "foreign"),
+	}
+	for _, name := range foreign {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte("not a cache entry"), 0o644))
+	}
+
+	// Only the expired entries of the cache are deleted.
+	c.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	require.NoError(t, c.Store(user.InjectOrgID(context.Background(), "tenant-a"), []string{"old"}, [][]byte{[]byte("old")}))
+	c.now = time.Now
+	require.NoError(t, c.Sweep(context.Background()))
+	require.NoFileExists(t, filepath.Join(dir, "test", "tenant-a", "old"))
+	for _, name := range foreign {
+		require.FileExists(t, name)
+	}
+
+	_, _, missing, err := c.Fetch(user.InjectOrgID(context.Background(), "tenant-a"), []string{"foreign"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"foreign"}, missing)
+}
+
+func TestObjectStoreCache_Tiered(t *testing.T) {
+	objectStore, _ := newTestObjectStoreCache(t, time.Hour)
+	embedded := NewEmbeddedCache("test", EmbeddedCacheConfig{Enabled: true, MaxSizeItems: 10, TTL: time.Hour}, nil, log.NewNopLogger(), stats.ResultCache)
+	t.Cleanup(embedded.Stop)
+	ctx := user.InjectOrgID(context.Background(), "tenant-a")
+
+	// An entry only left in the object store, e.g. after a restart, is
+	// found and written back to the upper tier.
+	require.NoError(t, objectStore.Store(ctx, []string{"key1"}, [][]byte{[]byte("hello")}))
+	tiered := NewTiered([]Cache{embedded, objectStore})
+
+	found, bufs, _, err := tiered.Fetch(ctx, []string{"key1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1"}, found)
+	require.Equal(t, [][]byte{[]byte("hello")}, bufs)
+
+	found, _, _, err = embedded.Fetch(ctx, []string{"key1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1"}, found)
+}
+
+func TestObjectStoreCache_Background(t *testing.T) {
+	objectStore, dir := newTestObjectStoreCache(t, time.Hour)
+	c := NewBackground("test", BackgroundConfig{WriteBackGoroutines: 1, WriteBackBuffer: 10, WriteBackSizeLimit: 1 << 20}, objectStore, nil)
+
+	// The entries written back in the background keep their tenant.
+	require.NoError(t, c.Store(user.InjectOrgID(context.Background(), "tenant-a"), []string{"key1"}, [][]byte{[]byte("hello")}))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "test", "tenant-a", "key1"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	c.Stop()
+}
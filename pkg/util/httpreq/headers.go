@@ -14,6 +14,8 @@ var (
 	// LokiActorPathHeader is the name of the header e.g. used to enqueue requests in hierarchical queues.
 	LokiActorPathHeader               = "X-Loki-Actor-Path"
 	LokiDisablePipelineWrappersHeader = "X-Loki-Disable-Pipeline-Wrappers"
+	// LokiQueryPriorityHeader is the name of the header used to set the priority class of a query in the scheduler queue.
+	LokiQueryPriorityHeader = "X-Loki-Query-Priority"
 
 	// LokiActorPathDelimiter is the delimiter used to serialise the hierarchy of the actor.
 	LokiActorPathDelimiter = "|"
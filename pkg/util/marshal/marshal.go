@@ -63,6 +63,31 @@ func WriteQueryResponseJSON(data parser.Value, warnings []string, statistics sta
 	return s.Flush()
 }
 
+// WriteStreamsFrameJSON marshals the streams to a frame of a streamed query
+// response and then writes it as a single line to the provided io.Writer.
+func WriteStreamsFrameJSON(streams logqlmodel.Streams, w io.Writer, encodeFlags httpreq.EncodingFlags) error {
+	s := jsoniter.ConfigFastest.BorrowStream(w)
+	defer jsoniter.ConfigFastest.ReturnStream(s)
+	s.WriteObjectStart()
+	s.WriteObjectField("streams")
+	if err := encodeStreams(streams, s, encodeFlags); err != nil {
+		return fmt.Errorf("could not write JSON response: %w", err)
+	}
+	s.WriteObjectEnd()
+	s.WriteRaw("\n")
+	return s.Flush()
+}
+
+// WriteQueryResponseFrameJSON marshals the final frame of a streamed query
+// response and then writes it as a single line to the provided io.Writer.
+func WriteQueryResponseFrameJSON(frame loghttp.QueryResponseFrame, w io.Writer) error {
+	s := jsoniter.ConfigFastest.BorrowStream(w)
+	defer jsoniter.ConfigFastest.ReturnStream(s)
+	s.WriteVal(frame)
+	s.WriteRaw("\n")
+	return s.Flush()
+}
+
 // WriteLabelResponseJSON marshals a logproto.LabelResponse to v1 loghttp JSON
 // and then writes it to the provided io.Writer.
 func WriteLabelResponseJSON(data []string, w io.Writer) error {
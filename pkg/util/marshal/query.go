@@ -207,6 +207,11 @@ func EncodeResult(data parser.Value, warnings []string, statistics stats.Result,
 }
 
 func EncodeTailResult(data legacy.TailResponse, s *jsoniter.Stream, encodeFlags httpreq.EncodingFlags) error {
+	if data.Vector != nil {
+		encodeMetricTailResult(data.Vector, s)
+		return nil
+	}
+
 	s.WriteObjectStart()
 	s.WriteObjectField("streams")
 	err := encodeStreams(data.Streams, s, encodeFlags)
@@ -235,6 +240,16 @@ func EncodeTailResult(data legacy.TailResponse, s *jsoniter.Stream, encodeFlags
 	return nil
 }
 
+func encodeMetricTailResult(v promql.Vector, s *jsoniter.Stream) {
+	s.WriteObjectStart()
+	s.WriteObjectField("resultType")
+	s.WriteString(string(loghttp.ResultTypeVector))
+	s.WriteMore()
+	s.WriteObjectField("result")
+	encodeVector(v, s)
+	s.WriteObjectEnd()
+}
+
 func encodeDroppedEntries(entries []legacy.DroppedEntry, s *jsoniter.Stream) error {
 	s.WriteArrayStart()
 	defer s.WriteArrayEnd()
@@ -15,5 +15,6 @@ func NewDroppedStream(s *legacy.DroppedEntry) (loghttp.DroppedStream, error) {
 	return loghttp.DroppedStream{
 		Timestamp: s.Timestamp,
 		Labels:    l,
+		Count:     s.Count,
 	}, nil
 }
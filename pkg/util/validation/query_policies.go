@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	QueryPolicyActionReject  = "reject"
+	QueryPolicyActionRewrite = "rewrite"
+	QueryPolicyActionWarn    = "warn"
+)
+
+// QueryPolicy matches queries on their parsed expression and rejects,
+// rewrites or attaches a warning to the matching queries.
+type QueryPolicy struct {
+	Name    string           `yaml:"name" json:"name"`
+	Match   QueryPolicyMatch `yaml:"match" json:"match"`
+	Action  string           `yaml:"action" json:"action"`
+	Message string           `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// Rewrites applied by the rewrite action.
+	MaxRange    model.Duration `yaml:"max_range,omitempty" json:"max_range,omitempty"`
+	AddMatchers string         `yaml:"add_matchers,omitempty" json:"add_matchers,omitempty"`
+}
+
+// QueryPolicyMatch holds the conditions of a query policy. A query matches if
+// it meets all conditions that are set.
+type QueryPolicyMatch struct {
+	// Types are the query types as used by blocked queries: metric, filter or limited.
+	Types flagext.StringSliceCSV `yaml:"types,omitempty" json:"types,omitempty"`
+	// SelectorWithoutLabels matches stream selectors without a matcher for any of these labels.
+	SelectorWithoutLabels []string `yaml:"selector_without_labels,omitempty" json:"selector_without_labels,omitempty"`
+	// RangeLongerThan matches range aggregations over ranges longer than this duration.
+	RangeLongerThan model.Duration `yaml:"range_longer_than,omitempty" json:"range_longer_than,omitempty"`
+	// LeadingWildcardRegex matches regex label matchers and line filters starting with a wildcard.
+	LeadingWildcardRegex bool `yaml:"leading_wildcard_regex,omitempty" json:"leading_wildcard_regex,omitempty"`
+	// ParserStreamsOver matches queries with a parser that select more than this number of streams.
+	ParserStreamsOver uint64 `yaml:"parser_streams_over,omitempty" json:"parser_streams_over,omitempty"`
+}
+
+func (p *QueryPolicy) Validate() error {
+	switch p.Action {
+	case QueryPolicyActionReject, QueryPolicyActionWarn:
+	case QueryPolicyActionRewrite:
+		if p.MaxRange <= 0 && p.AddMatchers == "" {
+			return fmt.Errorf("query policy %q: rewrite requires max_range or add_matchers", p.Name)
+		}
+	default:
+		return fmt.Errorf("query policy %q: invalid action %q, must be one of %s, %s or %s", p.Name, p.Action, QueryPolicyActionReject, QueryPolicyActionRewrite, QueryPolicyActionWarn)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/grafana/dskit/tenant"
+)
+
+// Kinds of queries the members of a tenant federation can allow.
+const (
+	TenantFederationPermissionLogs     = "logs"
+	TenantFederationPermissionMetrics  = "metrics"
+	TenantFederationPermissionMetadata = "metadata"
+)
+
+// TenantFederationMember is a tenant whose data is read by the queries of a
+// tenant federation.
+type TenantFederationMember struct {
+	Tenant string `yaml:"tenant" json:"tenant"`
+	// Permissions are the kinds of queries of the federation the member is
+	// read by: logs, metrics or metadata. All kinds are allowed if empty.
+	Permissions []string `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	// LabelFilter is a stream selector whose matchers are added to all stream
+	// selectors of the queries reading the member.
+	LabelFilter string `yaml:"label_filter,omitempty" json:"label_filter,omitempty"`
+}
+
+// Allows returns true if the member is read by queries of the given kind.
+func (m *TenantFederationMember) Allows(permission string) bool {
+	return len(m.Permissions) == 0 || slices.Contains(m.Permissions, permission)
+}
+
+func (m *TenantFederationMember) Validate() error {
+	if m.Tenant == "" {
+		return errors.New("tenant federation member without tenant")
+	}
+	if err := tenant.ValidTenantID(m.Tenant); err != nil {
+		return fmt.Errorf("tenant federation member %q: %w", m.Tenant, err)
+	}
+	for _, p := range m.Permissions {
+		switch p {
+		case TenantFederationPermissionLogs, TenantFederationPermissionMetrics, TenantFederationPermissionMetadata:
+		default:
+			return fmt.Errorf("tenant federation member %q: invalid permission %q, must be one of %s, %s or %s", m.Tenant, p, TenantFederationPermissionLogs, TenantFederationPermissionMetrics, TenantFederationPermissionMetadata)
+		}
+	}
+	return nil
+}
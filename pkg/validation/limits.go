@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -49,6 +50,16 @@ const (
 	// is used to keep track of the current number of healthy distributor replicas.
 	GlobalIngestionRateStrategy = "global"
 
+	// StructuredMetadataExtractionJSON and StructuredMetadataExtractionLogfmt are
+	// the formats of the log lines structured metadata can be extracted from.
+	StructuredMetadataExtractionJSON   = "json"
+	StructuredMetadataExtractionLogfmt = "logfmt"
+
+	// QueryCostBudgetActionReject and QueryCostBudgetActionDeprioritize are
+	// the actions taken on the queries of a tenant above its query cost budget.
+	QueryCostBudgetActionReject       = "reject"
+	QueryCostBudgetActionDeprioritize = "deprioritize"
+
 	bytesInMB = 1048576
 
 	defaultPerStreamRateLimit   = 3 << 20 // 3MB
@@ -82,6 +93,13 @@ type Limits struct {
 	IncrementDuplicateTimestamp bool             `yaml:"increment_duplicate_timestamp" json:"increment_duplicate_timestamp"`
 	DiscoverServiceName         []string         `yaml:"discover_service_name" json:"discover_service_name"`
 	DiscoverLogLevels           bool             `yaml:"discover_log_levels" json:"discover_log_levels"`
+	AttributionLabels           []string         `yaml:"attribution_labels" json:"attribution_labels"`
+	AttributionRateMB           float64          `yaml:"attribution_rate_mb" json:"attribution_rate_mb"`
+	AttributionBurstSizeMB      float64          `yaml:"attribution_burst_size_mb" json:"attribution_burst_size_mb"`
+
+	StructuredMetadataExtractionFormat string   `yaml:"structured_metadata_extraction_format" json:"structured_metadata_extraction_format"`
+	StructuredMetadataExtractionFields []string `yaml:"structured_metadata_extraction_fields" json:"structured_metadata_extraction_fields"`
+	StructuredMetadataExtractionStrip  bool     `yaml:"structured_metadata_extraction_strip" json:"structured_metadata_extraction_strip"`
 
 	// Ingester enforced limits.
 	MaxLocalStreamsPerUser  int              `yaml:"max_streams_per_user" json:"max_streams_per_user"`
@@ -89,6 +107,7 @@ type Limits struct {
 	UnorderedWrites         bool             `yaml:"unordered_writes" json:"unordered_writes"`
 	PerStreamRateLimit      flagext.ByteSize `yaml:"per_stream_rate_limit" json:"per_stream_rate_limit"`
 	PerStreamRateLimitBurst flagext.ByteSize `yaml:"per_stream_rate_limit_burst" json:"per_stream_rate_limit_burst"`
+	MaxMemoryBytesPerUser   flagext.ByteSize `yaml:"max_memory_bytes_per_user" json:"max_memory_bytes_per_user"`
 
 	// Querier enforced limits.
 	MaxChunksPerQuery          int              `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
@@ -122,6 +141,9 @@ type Limits struct {
 	MinShardingLookback              model.Duration   `yaml:"min_sharding_lookback" json:"min_sharding_lookback"`
 	MaxQueryBytesRead                flagext.ByteSize `yaml:"max_query_bytes_read" json:"max_query_bytes_read"`
 	MaxQuerierBytesRead              flagext.ByteSize `yaml:"max_querier_bytes_read" json:"max_querier_bytes_read"`
+	QueryCostBudget                  float64          `yaml:"query_cost_budget" json:"query_cost_budget"`
+	QueryCostBudgetWindow            model.Duration   `yaml:"query_cost_budget_window" json:"query_cost_budget_window"`
+	QueryCostBudgetAction            string           `yaml:"query_cost_budget_action" json:"query_cost_budget_action"`
 	VolumeEnabled                    bool             `yaml:"volume_enabled" json:"volume_enabled" doc:"description=Enable log-volume endpoints."`
 	VolumeMaxSeries                  int              `yaml:"volume_max_series" json:"volume_max_series" doc:"description=The maximum number of aggregated series in a log-volume response"`
 
@@ -175,8 +197,9 @@ type Limits struct {
 	DeletionMode string `yaml:"deletion_mode" json:"deletion_mode"`
 
 	// Global and per tenant retention
-	RetentionPeriod model.Duration    `yaml:"retention_period" json:"retention_period"`
-	StreamRetention []StreamRetention `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty" doc:"description=Per-stream retention to apply, if the retention is enable on the compactor side.\nExample:\n retention_stream:\n - selector: '{namespace=\"dev\"}'\n priority: 1\n period: 24h\n- selector: '{container=\"nginx\"}'\n priority: 1\n period: 744h\nSelector is a Prometheus labels matchers that will apply the 'period' retention only if the stream is matching. In case multiple stream are matching, the highest priority will be picked. If no rule is matched the 'retention_period' is used."`
+	RetentionPeriod  model.Duration    `yaml:"retention_period" json:"retention_period"`
+	StreamRetention  []StreamRetention `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty" doc:"description=Per-stream retention to apply, if the retention is enable on the compactor side.\nExample:\n retention_stream:\n - selector: '{namespace=\"dev\"}'\n priority: 1\n period: 24h\n- selector: '{container=\"nginx\"}'\n priority: 1\n period: 744h\nSelector is a Prometheus labels matchers that will apply the 'period' retention only if the stream is matching. In case multiple stream are matching, the highest priority will be picked. If no rule is matched the 'retention_period' is used. A selector followed by line filters or label filters, such as a level=\"debug\" label filter, applies the 'period' only to the matching lines of the matching streams, which are deleted from their chunks if it is shorter than the retention period of the stream. Its priority is ignored."`
+	RetentionRollups []RetentionRollup `yaml:"retention_rollups,omitempty" json:"retention_rollups,omitempty" doc:"description=Metric rollups computed by the compactor from the chunks of the matching streams before retention deletes them, if the retention is enabled on the compactor side and compactor.retention-rollups-enabled is true. Range metric queries matching a rollup are answered from it for the data deleted by retention.\nExample:\n retention_rollups:\n - name: access_logs\n selector: '{job=\"nginx\"}'\n by: [status, method]\n unwrap: [duration_ms]\n resolution: 1m\n period: 8760h\nThe rollups hold count_over_time and bytes_over_time, and sum_over_time, min_over_time and max_over_time of the unwrapped labels, by the 'by' labels of the streams or of the structured metadata of their lines, at the given resolution. They are kept for 'period'."`
 
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string         `yaml:"per_tenant_override_config" json:"per_tenant_override_config"`
@@ -188,6 +211,9 @@ type Limits struct {
 	ShardStreams *shardstreams.Config `yaml:"shard_streams" json:"shard_streams"`
 
 	BlockedQueries []*validation.BlockedQuery `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty"`
+	QueryPolicies  []*validation.QueryPolicy  `yaml:"query_policies,omitempty" json:"query_policies,omitempty" doc:"description=List of policies evaluated by the query frontend on the parsed query. A policy matches a query if the query meets all of its match conditions, and then rejects the query, rewrites it or attaches a warning to the response.\nExample:\n query_policies:\n - name: namespace-required\n match:\n selector_without_labels: [namespace]\n action: reject\n - name: max-range\n match:\n range_longer_than: 1d\n action: rewrite\n max_range: 1d\n - name: large-parse\n match:\n parser_streams_over: 1000\n action: warn\n message: add more label matchers to parse fewer streams"`
+
+	TenantFederation []*validation.TenantFederationMember `yaml:"tenant_federation,omitempty" json:"tenant_federation,omitempty" doc:"description=Members of the tenant federation this tenant is the virtual tenant of. The query frontend executes the queries of the virtual tenant for each member with the limits of the member, and adds the __tenant_id__ label to the results. Members can restrict the kinds of queries they are read by to logs, metrics or metadata, and add the matchers of a label filter to all stream selectors.\nExample:\n tenant_federation:\n - tenant: team-a\n - tenant: team-b\n permissions: [metrics]\n - tenant: shared\n label_filter: '{department=\"engineering\"}'"`
 
 	RequiredLabels       []string `yaml:"required_labels,omitempty" json:"required_labels,omitempty" doc:"description=Define a list of required selector labels."`
 	RequiredNumberLabels int      `yaml:"minimum_labels_number,omitempty" json:"minimum_labels_number,omitempty" doc:"description=Minimum number of label matchers a query should contain."`
@@ -214,11 +240,24 @@ type Limits struct {
 	GlobalOTLPConfig                  push.GlobalOTLPConfig `yaml:"-" json:"-"`
 }
 
+// RetentionRollup configures metric rollups computed from the chunks of the
+// streams matching Selector before they are deleted by retention.
+type RetentionRollup struct {
+	Name       string            `yaml:"name" json:"name" doc:"description:Name of the rollup, unique per tenant. Changing it discards the existing rollups."`
+	Selector   string            `yaml:"selector" json:"selector" doc:"description:Stream selector expression."`
+	By         []string          `yaml:"by" json:"by" doc:"description:Labels the rollups are grouped by, either stream labels or structured metadata."`
+	Unwrap     []string          `yaml:"unwrap" json:"unwrap" doc:"description:Labels whose numeric values are rolled up for unwrap range aggregations."`
+	Resolution model.Duration    `yaml:"resolution" json:"resolution" doc:"description:Resolution of the rollups."`
+	Period     model.Duration    `yaml:"period" json:"period" doc:"description:How long the rollups are kept."`
+	Matchers   []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+}
+
 type StreamRetention struct {
-	Period   model.Duration    `yaml:"period" json:"period" doc:"description:Retention period applied to the log lines matching the selector."`
-	Priority int               `yaml:"priority" json:"priority" doc:"description:The larger the value, the higher the priority."`
-	Selector string            `yaml:"selector" json:"selector" doc:"description:Stream selector expression."`
-	Matchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+	Period   model.Duration         `yaml:"period" json:"period" doc:"description:Retention period applied to the log lines matching the selector."`
+	Priority int                    `yaml:"priority" json:"priority" doc:"description:The larger the value, the higher the priority."`
+	Selector string                 `yaml:"selector" json:"selector" doc:"description:Log selector expression. Line filters and label filters select the lines the period applies to."`
+	Matchers []*labels.Matcher      `yaml:"-" json:"-"` // populated during validation.
+	Filter   syntax.LogSelectorExpr `yaml:"-" json:"-"` // populated during validation if the selector filters lines.
 }
 
 // LimitError are errors that do not comply with the limits specified.
@@ -257,6 +296,14 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var((*dskit_flagext.StringSlice)(&l.DiscoverServiceName), "validation.discover-service-name", "If no service_name label exists, Loki maps a single label from the configured list to service_name. If none of the configured labels exist in the stream, label is set to unknown_service. Empty list disables setting the label.")
 	f.BoolVar(&l.DiscoverLogLevels, "validation.discover-log-levels", true, "Discover and add log levels during ingestion, if not present already. Levels would be added to Structured Metadata with name 'level' and one of the values from 'debug', 'info', 'warn', 'error', 'critical', 'fatal'.")
 
+	f.Var((*dskit_flagext.StringSlice)(&l.AttributionLabels), "distributor.attribution-labels", "Stream labels used to attribute ingested bytes and lines to a cost owner (for example a team) within the tenant. Usage is tracked per combination of the configured label values. Empty list disables attribution.")
+	f.Float64Var(&l.AttributionRateMB, "distributor.attribution-rate-limit-mb", 0, "Per-attribution-value ingestion rate limit in sample size per second. Units in MB. Applied to each combination of the attribution labels separately. 0 to disable.")
+	f.Float64Var(&l.AttributionBurstSizeMB, "distributor.attribution-burst-size-mb", 0, "Per-attribution-value allowed ingestion burst size (in sample size). Units in MB. Only used when the attribution rate limit is enabled.")
+
+	f.StringVar(&l.StructuredMetadataExtractionFormat, "validation.structured-metadata-extraction-format", "", "Format of the log lines fields are extracted from into structured metadata during ingestion. Supported values: json, logfmt. Empty disables the extraction.")
+	f.Var((*dskit_flagext.StringSlice)(&l.StructuredMetadataExtractionFields), "validation.structured-metadata-extraction-fields", "Fields of the log lines promoted to structured metadata, for example trace_id. Nested JSON fields are named the same way as by the LogQL json parser, for example 'http_status' for {\"http\":{\"status\":200}}. Fields already present in the structured metadata of a line are left untouched.")
+	f.BoolVar(&l.StructuredMetadataExtractionStrip, "validation.structured-metadata-extraction-strip", false, "Remove the extracted fields from the log lines. Only top-level JSON fields are removed, and logfmt lines are re-encoded.")
+
 	_ = l.RejectOldSamplesMaxAge.Set("7d")
 	f.Var(&l.RejectOldSamplesMaxAge, "validation.reject-old-samples.max-age", "Maximum accepted sample age before rejecting.")
 	_ = l.CreationGracePeriod.Set("10m")
@@ -273,6 +320,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.PerStreamRateLimit, "ingester.per-stream-rate-limit", "Maximum byte rate per second per stream, also expressible in human readable forms (1MB, 256KB, etc).")
 	_ = l.PerStreamRateLimitBurst.Set(strconv.Itoa(defaultPerStreamBurstLimit))
 	f.Var(&l.PerStreamRateLimitBurst, "ingester.per-stream-rate-limit-burst", "Maximum burst bytes per stream, also expressible in human readable forms (1MB, 256KB, etc). This is how far above the rate limit a stream can 'burst' before the stream is limited.")
+	f.Var(&l.MaxMemoryBytesPerUser, "ingester.max-memory-bytes-per-user", "Maximum size of the chunks, head blocks and index an ingester holds in memory for a tenant, also expressible in human readable forms (1GB, 512MB, etc). The flushed chunks held for the chunk retain period are included. Above it, the ingester flushes the oldest chunks of the tenant and rejects its pushes until it is back under the limit. 0 to disable.")
 
 	f.IntVar(&l.MaxChunksPerQuery, "store.query-chunk-limit", 2e6, "Maximum number of chunks that can be fetched in a single query.")
 
@@ -311,6 +359,11 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxQuerierBytesRead.Set("150GB")
 	f.Var(&l.MaxQuerierBytesRead, "frontend.max-querier-bytes-read", "Max number of bytes a query can fetch after splitting and sharding. Enforced in log and metric queries only when TSDB is used. The default value of 0 disables this limit.")
 
+	f.Float64Var(&l.QueryCostBudget, "frontend.query-cost-budget", 0, "Estimated CPU seconds the log and metric queries of a tenant can cost over the query cost budget window, per query frontend. The cost of a query is estimated before executing it from the index stats of its selectors and the complexity of its pipeline, and returned in the X-Loki-Query-Cost-* response headers. Only estimated when TSDB is used. The default value of 0 disables the budget and the estimation.")
+	_ = l.QueryCostBudgetWindow.Set("1h")
+	f.Var(&l.QueryCostBudgetWindow, "frontend.query-cost-budget-window", "Rolling window the query cost budget applies to.")
+	f.StringVar(&l.QueryCostBudgetAction, "frontend.query-cost-budget-action", QueryCostBudgetActionDeprioritize, "What to do with the queries of a tenant above its query cost budget. Supported values: reject, to reject them with a 429 status code, and deprioritize, to schedule them in a separate queue the other queries of the tenant aren't waiting behind.")
+
 	_ = l.MaxCacheFreshness.Set("10m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 
@@ -426,7 +479,7 @@ func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 func (l *Limits) Validate() error {
 	if l.StreamRetention != nil {
 		for i, rule := range l.StreamRetention {
-			matchers, err := syntax.ParseMatchers(rule.Selector, true)
+			expr, err := syntax.ParseLogSelector(rule.Selector, true)
 			if err != nil {
 				return fmt.Errorf("invalid labels matchers: %w", err)
 			}
@@ -434,7 +487,64 @@ func (l *Limits) Validate() error {
 				return fmt.Errorf("retention period must be >= 24h was %s", rule.Period)
 			}
 			// populate matchers during validation
-			l.StreamRetention[i].Matchers = matchers
+			l.StreamRetention[i].Matchers = expr.Matchers()
+			l.StreamRetention[i].Filter = nil
+			if _, ok := expr.(*syntax.MatchersExpr); !ok {
+				if !expr.HasFilter() {
+					return fmt.Errorf("retention selector %s must only contain labels matchers, or filter lines", rule.Selector)
+				}
+				l.StreamRetention[i].Filter = expr
+			}
+		}
+	}
+
+	rollupNames := make(map[string]struct{}, len(l.RetentionRollups))
+	for i, rule := range l.RetentionRollups {
+		if rule.Name == "" || strings.Contains(rule.Name, "/") {
+			return fmt.Errorf("invalid retention rollup name %q", rule.Name)
+		}
+		if _, ok := rollupNames[rule.Name]; ok {
+			return fmt.Errorf("duplicate retention rollup name %q", rule.Name)
+		}
+		rollupNames[rule.Name] = struct{}{}
+
+		matchers, err := syntax.ParseMatchers(rule.Selector, true)
+		if err != nil {
+			return fmt.Errorf("invalid retention rollup %s labels matchers: %w", rule.Name, err)
+		}
+		if rule.Resolution <= 0 || (24*time.Hour)%time.Duration(rule.Resolution) != 0 {
+			return fmt.Errorf("retention rollup %s resolution must divide 24h, was %s", rule.Name, rule.Resolution)
+		}
+		if time.Duration(rule.Period) < 24*time.Hour {
+			return fmt.Errorf("retention rollup %s period must be >= 24h was %s", rule.Name, rule.Period)
+		}
+		l.RetentionRollups[i].Matchers = matchers
+	}
+
+	for _, p := range l.QueryPolicies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if p.AddMatchers != "" {
+			if _, err := syntax.ParseMatchers(p.AddMatchers, true); err != nil {
+				return fmt.Errorf("query policy %q: invalid add_matchers: %w", p.Name, err)
+			}
+		}
+	}
+
+	members := make(map[string]struct{}, len(l.TenantFederation))
+	for _, m := range l.TenantFederation {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if _, ok := members[m.Tenant]; ok {
+			return fmt.Errorf("tenant federation member %q is configured more than once", m.Tenant)
+		}
+		members[m.Tenant] = struct{}{}
+		if m.LabelFilter != "" {
+			if _, err := syntax.ParseMatchers(m.LabelFilter, true); err != nil {
+				return fmt.Errorf("tenant federation member %q: invalid label_filter: %w", m.Tenant, err)
+			}
 		}
 	}
 
@@ -468,6 +578,21 @@ func (l *Limits) Validate() error {
 		return err
 	}
 
+	switch l.StructuredMetadataExtractionFormat {
+	case "", StructuredMetadataExtractionJSON, StructuredMetadataExtractionLogfmt:
+	default:
+		return fmt.Errorf("invalid structured metadata extraction format %q, supported values: %s, %s", l.StructuredMetadataExtractionFormat, StructuredMetadataExtractionJSON, StructuredMetadataExtractionLogfmt)
+	}
+
+	switch l.QueryCostBudgetAction {
+	case "", QueryCostBudgetActionReject, QueryCostBudgetActionDeprioritize:
+	default:
+		return fmt.Errorf("invalid query cost budget action %q, supported values: %s, %s", l.QueryCostBudgetAction, QueryCostBudgetActionReject, QueryCostBudgetActionDeprioritize)
+	}
+	if l.QueryCostBudget > 0 && l.QueryCostBudgetWindow <= 0 {
+		return errors.New("query_cost_budget_window must be positive when query_cost_budget is set")
+	}
+
 	return nil
 }
 
@@ -571,6 +696,11 @@ func (o *Overrides) MaxLocalStreamsPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxLocalStreamsPerUser
 }
 
+// MaxMemoryBytesPerUser returns the maximum size of the data an ingester holds in memory for a user.
+func (o *Overrides) MaxMemoryBytesPerUser(userID string) int {
+	return o.getOverridesForUser(userID).MaxMemoryBytesPerUser.Val()
+}
+
 // MaxGlobalStreamsPerUser returns the maximum number of streams a user is allowed to store
 // across the cluster.
 func (o *Overrides) MaxGlobalStreamsPerUser(userID string) int {
@@ -694,6 +824,24 @@ func (o *Overrides) MaxQuerierBytesRead(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxQuerierBytesRead.Val()
 }
 
+// QueryCostBudget returns the estimated CPU seconds the queries of a tenant
+// can cost over the query cost budget window.
+func (o *Overrides) QueryCostBudget(userID string) float64 {
+	return o.getOverridesForUser(userID).QueryCostBudget
+}
+
+// QueryCostBudgetWindow returns the rolling window the query cost budget
+// applies to.
+func (o *Overrides) QueryCostBudgetWindow(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).QueryCostBudgetWindow)
+}
+
+// QueryCostBudgetAction returns what to do with the queries of a tenant above
+// its query cost budget.
+func (o *Overrides) QueryCostBudgetAction(userID string) string {
+	return o.getOverridesForUser(userID).QueryCostBudgetAction
+}
+
 // MaxConcurrentTailRequests returns the limit to number of concurrent tail requests.
 func (o *Overrides) MaxConcurrentTailRequests(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxConcurrentTailRequests
@@ -872,6 +1020,11 @@ func (o *Overrides) StreamRetention(userID string) []StreamRetention {
 	return o.getOverridesForUser(userID).StreamRetention
 }
 
+// RetentionRollups returns the retention rollups for a given user.
+func (o *Overrides) RetentionRollups(userID string) []RetentionRollup {
+	return o.getOverridesForUser(userID).RetentionRollups
+}
+
 func (o *Overrides) UnorderedWrites(userID string) bool {
 	return o.getOverridesForUser(userID).UnorderedWrites
 }
@@ -888,6 +1041,14 @@ func (o *Overrides) BlockedQueries(_ context.Context, userID string) []*validati
 	return o.getOverridesForUser(userID).BlockedQueries
 }
 
+func (o *Overrides) QueryPolicies(_ context.Context, userID string) []*validation.QueryPolicy {
+	return o.getOverridesForUser(userID).QueryPolicies
+}
+
+func (o *Overrides) TenantFederation(_ context.Context, userID string) []*validation.TenantFederationMember {
+	return o.getOverridesForUser(userID).TenantFederation
+}
+
 func (o *Overrides) RequiredLabels(_ context.Context, userID string) []string {
 	return o.getOverridesForUser(userID).RequiredLabels
 }
@@ -921,6 +1082,33 @@ func (o *Overrides) DiscoverLogLevels(userID string) bool {
 	return o.getOverridesForUser(userID).DiscoverLogLevels
 }
 
+// AttributionLabels returns the stream labels used to attribute usage within a tenant.
+func (o *Overrides) AttributionLabels(userID string) []string {
+	return o.getOverridesForUser(userID).AttributionLabels
+}
+
+// AttributionRateBytes returns the ingestion rate limit per attribution value (bytes per second).
+func (o *Overrides) AttributionRateBytes(userID string) float64 {
+	return o.getOverridesForUser(userID).AttributionRateMB * bytesInMB
+}
+
+// AttributionBurstSizeBytes returns the ingestion burst size per attribution value.
+func (o *Overrides) AttributionBurstSizeBytes(userID string) int {
+	return int(o.getOverridesForUser(userID).AttributionBurstSizeMB * bytesInMB)
+}
+
+func (o *Overrides) StructuredMetadataExtractionFormat(userID string) string {
+	return o.getOverridesForUser(userID).StructuredMetadataExtractionFormat
+}
+
+func (o *Overrides) StructuredMetadataExtractionFields(userID string) []string {
+	return o.getOverridesForUser(userID).StructuredMetadataExtractionFields
+}
+
+func (o *Overrides) StructuredMetadataExtractionStrip(userID string) bool {
+	return o.getOverridesForUser(userID).StructuredMetadataExtractionStrip
+}
+
 // VolumeEnabled returns whether volume endpoints are enabled for a user.
 func (o *Overrides) VolumeEnabled(userID string) bool {
 	return o.getOverridesForUser(userID).VolumeEnabled
@@ -7,7 +7,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -16,6 +18,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/util/validation"
 )
 
 func TestLimitsTagsYamlMatchJson(t *testing.T) {
@@ -214,8 +217,10 @@ ruler_remote_write_headers:
   foo: "bar"
 `,
 			exp: Limits{
-				RulerRemoteWriteHeaders: OverwriteMarshalingStringMap{map[string]string{"foo": "bar"}},
-				DiscoverServiceName:     []string{},
+				RulerRemoteWriteHeaders:            OverwriteMarshalingStringMap{map[string]string{"foo": "bar"}},
+				DiscoverServiceName:                []string{},
+				AttributionLabels:                  []string{},
+				StructuredMetadataExtractionFields: []string{},
 
 				// Rest from new defaults
 				StreamRetention: []StreamRetention{
@@ -233,7 +238,9 @@ ruler_remote_write_headers:
 ruler_remote_write_headers:
 `,
 			exp: Limits{
-				DiscoverServiceName: []string{},
+				DiscoverServiceName:                []string{},
+				AttributionLabels:                  []string{},
+				StructuredMetadataExtractionFields: []string{},
 
 				// Rest from new defaults
 				StreamRetention: []StreamRetention{
@@ -253,7 +260,9 @@ retention_stream:
     selector: '{foo="bar"}'
 `,
 			exp: Limits{
-				DiscoverServiceName: []string{},
+				DiscoverServiceName:                []string{},
+				AttributionLabels:                  []string{},
+				StructuredMetadataExtractionFields: []string{},
 				StreamRetention: []StreamRetention{
 					{
 						Period:   model.Duration(24 * time.Hour),
@@ -272,8 +281,10 @@ retention_stream:
 reject_old_samples: true
 `,
 			exp: Limits{
-				RejectOldSamples:    true,
-				DiscoverServiceName: []string{},
+				RejectOldSamples:                   true,
+				DiscoverServiceName:                []string{},
+				AttributionLabels:                  []string{},
+				StructuredMetadataExtractionFields: []string{},
 
 				// Rest from new defaults
 				RulerRemoteWriteHeaders: OverwriteMarshalingStringMap{map[string]string{"a": "b"}},
@@ -292,8 +303,10 @@ reject_old_samples: true
 query_timeout: 5m
 `,
 			exp: Limits{
-				DiscoverServiceName: []string{},
-				QueryTimeout:        model.Duration(5 * time.Minute),
+				DiscoverServiceName:                []string{},
+				AttributionLabels:                  []string{},
+				StructuredMetadataExtractionFields: []string{},
+				QueryTimeout:                       model.Duration(5 * time.Minute),
 
 				// Rest from new defaults.
 				RulerRemoteWriteHeaders: OverwriteMarshalingStringMap{map[string]string{"a": "b"}},
@@ -341,6 +354,26 @@ func TestLimitsValidation(t *testing.T) {
 			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "unknown"},
 			expected: fmt.Errorf("invalid encoding: unknown, supported: %s", chunkenc.SupportedEncoding()),
 		},
+		{
+			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "none", QueryPolicies: []*validation.QueryPolicy{{Name: "p", Action: "block"}}},
+			expected: fmt.Errorf(`query policy "p": invalid action "block"`),
+		},
+		{
+			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "none", QueryPolicies: []*validation.QueryPolicy{{Name: "p", Action: "rewrite", AddMatchers: "namespace"}}},
+			expected: fmt.Errorf(`query policy "p": invalid add_matchers`),
+		},
+		{
+			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "none", TenantFederation: []*validation.TenantFederationMember{{Tenant: "a", Permissions: []string{"write"}}}},
+			expected: fmt.Errorf(`tenant federation member "a": invalid permission "write"`),
+		},
+		{
+			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "none", TenantFederation: []*validation.TenantFederationMember{{Tenant: "a"}, {Tenant: "a"}}},
+			expected: fmt.Errorf(`tenant federation member "a" is configured more than once`),
+		},
+		{
+			limits:   Limits{DeletionMode: "disabled", BloomBlockEncoding: "none", TenantFederation: []*validation.TenantFederationMember{{Tenant: "a", LabelFilter: "department"}}},
+			expected: fmt.Errorf(`tenant federation member "a": invalid label_filter`),
+		},
 	} {
 		desc := fmt.Sprintf("%s/%s", tc.limits.DeletionMode, tc.limits.BloomBlockEncoding)
 		t.Run(desc, func(t *testing.T) {
@@ -353,3 +386,31 @@ func TestLimitsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamRetentionValidation(t *testing.T) {
+	for _, tc := range []struct {
+		selector   string
+		wantFilter bool
+		wantErr    bool
+	}{
+		{selector: `{app="foo"}`},
+		{selector: `{app="foo"} |= "debug"`, wantFilter: true},
+		{selector: `{app="foo"} | level="debug"`, wantFilter: true},
+		{selector: `{app="foo"} | json`, wantErr: true},
+		{selector: `{app="foo"`, wantErr: true},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			var limits Limits
+			flagext.DefaultValues(&limits)
+			limits.StreamRetention = []StreamRetention{{Selector: tc.selector, Period: model.Duration(72 * time.Hour)}}
+			err := limits.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "foo")}, limits.StreamRetention[0].Matchers)
+			require.Equal(t, tc.wantFilter, limits.StreamRetention[0].Filter != nil)
+		})
+	}
+}
@@ -22,6 +22,10 @@ const (
 	// Declared here to avoid duplication in ingester and distributor.
 	RateLimited         = "rate_limited"
 	RateLimitedErrorMsg = "Ingestion rate limit exceeded for user %s (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
+	// AttributionRateLimited is a reason for discarding lines when the rate limit of the
+	// attribution value (for example a team) within the tenant is hit.
+	AttributionRateLimited         = "attribution_rate_limited"
+	AttributionRateLimitedErrorMsg = "Ingestion rate limit exceeded for user %s and attribution %s (limit: %d bytes/sec) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
 	// LineTooLong is a reason for discarding too long log lines.
 	LineTooLong         = "line_too_long"
 	LineTooLongErrorMsg = "Max entry size '%d' bytes exceeded for stream '%s' while adding an entry with length '%d' bytes"
@@ -29,6 +33,10 @@ const (
 	// because the limit of active streams has been reached.
 	StreamLimit         = "stream_limit"
 	StreamLimitErrorMsg = "Maximum active stream limit exceeded when trying to create stream %s, reduce the number of active streams (reduce labels or reduce label values), or contact your Loki administrator to see if the limit can be increased, user: '%s'"
+	// TenantMemoryLimit is a reason for discarding lines when an ingester holds
+	// more data in memory for the tenant than allowed.
+	TenantMemoryLimit         = "tenant_memory_limit"
+	TenantMemoryLimitErrorMsg = "Maximum in-memory bytes per tenant exceeded in the ingester for user '%s' (%d bytes held, limit: %d bytes), its oldest chunks are being flushed, retry later or contact your Loki administrator to see if the limit can be increased"
 	// StreamRateLimit is a reason for discarding lines when the streams own rate limit is hit
 	// rather than the overall ingestion rate limit.
 	StreamRateLimit = "per_stream_rate_limit"
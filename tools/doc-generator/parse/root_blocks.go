@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/indexgateway"
 	"github.com/grafana/loki/v3/pkg/ingester"
 	ingester_client "github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/kafka"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/loki/common"
 	frontend "github.com/grafana/loki/v3/pkg/lokifrontend"
@@ -193,6 +194,16 @@ var (
 
 When a memberlist config with atleast 1 join_members is defined, kvstore of type memberlist is automatically selected for all the components that require a ring unless otherwise specified in the component's configuration section.`,
 		},
+		{
+			Name:       "syslog_listener",
+			StructType: []reflect.Type{reflect.TypeOf(distributor.SyslogListenerConfig{})},
+			Desc:       "The syslog_listener block configures a syslog listener of the distributor.",
+		},
+		{
+			Name:       "kafka_config",
+			StructType: []reflect.Type{reflect.TypeOf(kafka.Config{})},
+			Desc:       "Configuration for the Kafka write path. Distributors write validated streams to Kafka, and ingesters consume the partitions they own, committing offsets once the consumed data is flushed.",
+		},
 		// GRPC client
 		{
 			Name:       "grpc_client",
@@ -36,6 +36,7 @@ type ProxyConfig struct {
 	SkipRecentSamples              time.Duration
 	RequestURLFilter               *regexp.Regexp
 	InstrumentCompares             bool
+	IgnoreEntryOrder               bool
 }
 
 func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
@@ -55,6 +56,7 @@ func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
 		return err
 	})
 	f.BoolVar(&cfg.InstrumentCompares, "proxy.compare-instrument", false, "Reports metrics on comparisons of responses between preferred and non-preferred endpoints for supported routes.")
+	f.BoolVar(&cfg.IgnoreEntryOrder, "proxy.compare-ignore-entry-order", false, "Compare the entries of the log streams regardless of their order.")
 }
 
 type Route struct {
@@ -101,6 +103,37 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, readRoutes, writeRoutes []Rout
 		writeRoutes: writeRoutes,
 	}
 
+	backends, err := parseBackends(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.backends = backends
+
+	if cfg.CompareResponses && len(p.backends) < 2 {
+		return nil, fmt.Errorf("when enabling comparison of results number of backends should be at least 2")
+	}
+
+	// At least 2 backends are suggested
+	if len(p.backends) < 2 {
+		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
+	}
+
+	if cfg.DisableBackendReadProxy != "" {
+		readDisabledBackendHosts := strings.Split(p.cfg.DisableBackendReadProxy, ",")
+		for _, host := range readDisabledBackendHosts {
+			if host == cfg.PreferredBackend {
+				return nil, fmt.Errorf("the preferred backend cannot be disabled for reading")
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// parseBackends parses the comma separated backend endpoints of the config.
+func parseBackends(cfg ProxyConfig) ([]*ProxyBackend, error) {
+	var backends []*ProxyBackend
+
 	// Parse the backend endpoints (comma separated).
 	parts := strings.Split(cfg.BackendEndpoints, ",")
 
@@ -127,18 +160,18 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, readRoutes, writeRoutes []Rout
 			preferred = preferredIdx == idx
 		}
 
-		p.backends = append(p.backends, NewProxyBackend(name, u, cfg.BackendReadTimeout, preferred))
+		backends = append(backends, NewProxyBackend(name, u, cfg.BackendReadTimeout, preferred))
 	}
 
 	// At least 1 backend is required
-	if len(p.backends) < 1 {
+	if len(backends) < 1 {
 		return nil, errMinBackends
 	}
 
 	// If the preferred backend is configured, then it must exists among the actual backends.
 	if cfg.PreferredBackend != "" {
 		exists := false
-		for _, b := range p.backends {
+		for _, b := range backends {
 			if b.preferred {
 				exists = true
 				break
@@ -150,25 +183,7 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, readRoutes, writeRoutes []Rout
 		}
 	}
 
-	if cfg.CompareResponses && len(p.backends) < 2 {
-		return nil, fmt.Errorf("when enabling comparison of results number of backends should be at least 2")
-	}
-
-	// At least 2 backends are suggested
-	if len(p.backends) < 2 {
-		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
-	}
-
-	if cfg.DisableBackendReadProxy != "" {
-		readDisabledBackendHosts := strings.Split(p.cfg.DisableBackendReadProxy, ",")
-		for _, host := range readDisabledBackendHosts {
-			if host == cfg.PreferredBackend {
-				return nil, fmt.Errorf("the preferred backend cannot be disabled for reading")
-			}
-		}
-	}
-
-	return p, nil
+	return backends, nil
 }
 
 func (p *Proxy) Start() error {
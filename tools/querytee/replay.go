@@ -0,0 +1,421 @@
+package querytee
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/dskit/concurrency"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+type ReplayConfig struct {
+	QueryLog                string
+	Report                  string
+	ReportAll               bool
+	Concurrency             int
+	TolerateLimitTruncation bool
+}
+
+func (cfg *ReplayConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.QueryLog, "replay.query-log", "", "Path of a query log to replay against the backends instead of running the proxy. The query statistics lines of the query frontend (the lines with a latency field) are replayed at their recorded time range.")
+	f.StringVar(&cfg.Report, "replay.report", "", "Path of the report of the replay, with a JSON object per mismatching query. Defaults to the standard output.")
+	f.BoolVar(&cfg.ReportAll, "replay.report-all", false, "Report the matching queries as well, e.g. to compare the latencies of the backends.")
+	f.IntVar(&cfg.Concurrency, "replay.concurrency", 4, "How many queries are replayed concurrently.")
+	f.BoolVar(&cfg.TolerateLimitTruncation, "replay.compare-tolerate-limit-truncation", true, "Ignore the entries beyond the boundary of the responses truncated by the limit of the query, as the backends may break ties differently.")
+}
+
+// ReplayQuery is a query read from a query log.
+type ReplayQuery struct {
+	Tenant    string
+	Query     string
+	Instant   bool
+	Start     time.Time
+	End       time.Time
+	Step      time.Duration
+	Limit     uint32
+	Direction logproto.Direction
+}
+
+// ReplayBackendResult is the response of a backend to a replayed query.
+type ReplayBackendResult struct {
+	Backend        string  `json:"backend"`
+	Status         int     `json:"status"`
+	LatencySeconds float64 `json:"latency_seconds"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// ReplayResult is the comparison of the responses of two backends to a
+// replayed query.
+type ReplayResult struct {
+	Tenant   string              `json:"tenant,omitempty"`
+	Query    string              `json:"query"`
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+	Expected ReplayBackendResult `json:"expected"`
+	Actual   ReplayBackendResult `json:"actual"`
+	Match    bool                `json:"match"`
+	// Mismatch describes why the responses differ, if not by their streams.
+	Mismatch string       `json:"mismatch,omitempty"`
+	Streams  []StreamDiff `json:"streams,omitempty"`
+
+	expectedLatency, actualLatency time.Duration
+}
+
+// ReplaySummary summarizes a replay.
+type ReplaySummary struct {
+	Queries    int
+	Mismatches int
+	// Latencies of each backend, sorted.
+	Latencies map[string][]time.Duration
+}
+
+// Replayer replays a query log against the backends, and reports the queries
+// whose results differ between the preferred backend (or the first one) and
+// each other backend.
+type Replayer struct {
+	cfg              ReplayConfig
+	ignoreEntryOrder bool
+	expected         *ProxyBackend
+	others           []*ProxyBackend
+	comparator       *SamplesComparator
+	logger           log.Logger
+}
+
+func NewReplayer(cfg ReplayConfig, proxyCfg ProxyConfig, comparator *SamplesComparator, logger log.Logger) (*Replayer, error) {
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("the replay concurrency must be greater than 0")
+	}
+
+	backends, err := parseBackends(proxyCfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(backends) < 2 {
+		return nil, fmt.Errorf("when replaying a query log number of backends should be at least 2")
+	}
+
+	r := &Replayer{
+		cfg:              cfg,
+		ignoreEntryOrder: proxyCfg.IgnoreEntryOrder,
+		expected:         backends[0],
+		comparator:       comparator,
+		logger:           logger,
+	}
+	for _, b := range backends {
+		if b.preferred {
+			r.expected = b
+		}
+	}
+	for _, b := range backends {
+		if b != r.expected {
+			r.others = append(r.others, b)
+		}
+	}
+	return r, nil
+}
+
+// Run replays the queries of the query log and writes the report.
+func (r *Replayer) Run(ctx context.Context) (ReplaySummary, error) {
+	f, err := os.Open(r.cfg.QueryLog)
+	if err != nil {
+		return ReplaySummary{}, err
+	}
+	defer f.Close()
+
+	queries, err := ParseQueryLog(f)
+	if err != nil {
+		return ReplaySummary{}, errors.Wrapf(err, "reading query log %s", r.cfg.QueryLog)
+	}
+
+	var report io.Writer = os.Stdout
+	if r.cfg.Report != "" {
+		out, err := os.Create(r.cfg.Report)
+		if err != nil {
+			return ReplaySummary{}, err
+		}
+		defer out.Close()
+		report = out
+	}
+
+	return r.Replay(ctx, queries, report)
+}
+
+// Replay replays the queries and writes the results to the report.
+func (r *Replayer) Replay(ctx context.Context, queries []ReplayQuery, report io.Writer) (ReplaySummary, error) {
+	results := make([][]ReplayResult, len(queries))
+	err := concurrency.ForEachJob(ctx, len(queries), r.cfg.Concurrency, func(_ context.Context, i int) error {
+		results[i] = r.replay(queries[i])
+		return nil
+	})
+	if err != nil {
+		return ReplaySummary{}, err
+	}
+
+	summary := ReplaySummary{Latencies: map[string][]time.Duration{}}
+	enc := jsoniter.ConfigCompatibleWithStandardLibrary.NewEncoder(report)
+	for _, queryResults := range results {
+		summary.Queries++
+		summary.Latencies[r.expected.name] = append(summary.Latencies[r.expected.name], queryResults[0].expectedLatency)
+
+		mismatch := false
+		for _, res := range queryResults {
+			summary.Latencies[res.Actual.Backend] = append(summary.Latencies[res.Actual.Backend], res.actualLatency)
+			if !res.Match {
+				mismatch = true
+			}
+			if res.Match && !r.cfg.ReportAll {
+				continue
+			}
+			if err := enc.Encode(res); err != nil {
+				return summary, err
+			}
+		}
+		if mismatch {
+			summary.Mismatches++
+		}
+	}
+
+	for backend, latencies := range summary.Latencies {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		level.Info(r.logger).Log("msg", "replayed queries", "backend", backend, "queries", len(latencies), "latency_p50", quantile(latencies, 0.5), "latency_p99", quantile(latencies, 0.99))
+	}
+	level.Info(r.logger).Log("msg", "replay finished", "queries", summary.Queries, "mismatches", summary.Mismatches)
+	return summary, nil
+}
+
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+// replay executes the query against the backends and compares the response
+// of each other backend with the expected one.
+func (r *Replayer) replay(q ReplayQuery) []ReplayResult {
+	req := q.request()
+
+	expectedStatus, expectedBody, expectedLatency, expectedErr := r.forward(r.expected, req)
+	results := make([]ReplayResult, 0, len(r.others))
+	for _, b := range r.others {
+		status, body, latency, err := r.forward(b, req)
+
+		res := ReplayResult{
+			Tenant:          q.Tenant,
+			Query:           q.Query,
+			Start:           q.Start,
+			End:             q.End,
+			Expected:        newReplayBackendResult(r.expected, expectedStatus, expectedLatency, expectedErr),
+			Actual:          newReplayBackendResult(b, status, latency, err),
+			expectedLatency: expectedLatency,
+			actualLatency:   latency,
+		}
+		switch {
+		case expectedErr != nil || err != nil:
+			res.Mismatch = "failed to execute the query"
+		case expectedStatus != status:
+			res.Mismatch = fmt.Sprintf("expected status code %d but got %d", expectedStatus, status)
+		case status == http.StatusOK:
+			res.Streams, err = r.compare(q, expectedBody, body)
+			if err != nil {
+				res.Mismatch = err.Error()
+			}
+		}
+		res.Match = res.Mismatch == "" && len(res.Streams) == 0
+		if !res.Match {
+			level.Debug(r.logger).Log("msg", "results differ", "query", q.Query, "backend", b.name, "mismatch", res.Mismatch, "streams", len(res.Streams))
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (r *Replayer) forward(b *ProxyBackend, req *http.Request) (int, []byte, time.Duration, error) {
+	start := time.Now()
+	status, body, err := b.ForwardRequest(req, nil)
+	return status, body, time.Since(start), err
+}
+
+func newReplayBackendResult(b *ProxyBackend, status int, latency time.Duration, err error) ReplayBackendResult {
+	res := ReplayBackendResult{Backend: b.name, Status: status, LatencySeconds: latency.Seconds()}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// compare diffs the log streams of the responses, or compares their samples.
+func (r *Replayer) compare(q ReplayQuery, expectedBody, actualBody []byte) ([]StreamDiff, error) {
+	var expected, actual loghttp.QueryResponse
+	if err := jsoniter.Unmarshal(expectedBody, &expected); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+	if err := jsoniter.Unmarshal(actualBody, &actual); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+	if expected.Data.ResultType != loghttp.ResultTypeStream || actual.Data.ResultType != loghttp.ResultTypeStream {
+		_, err := r.comparator.Compare(expectedBody, actualBody)
+		return nil, err
+	}
+
+	opts := StreamDiffOptions{IgnoreEntryOrder: r.ignoreEntryOrder, Direction: q.Direction}
+	if r.cfg.TolerateLimitTruncation {
+		opts.Limit = q.Limit
+	}
+	return DiffStreams(expected.Data.Result.(loghttp.Streams), actual.Data.Result.(loghttp.Streams), opts), nil
+}
+
+// request returns the HTTP request executing the query.
+func (q ReplayQuery) request() *http.Request {
+	params := url.Values{}
+	params.Set("query", q.Query)
+	params.Set("direction", q.Direction.String())
+	if q.Limit > 0 {
+		params.Set("limit", strconv.FormatUint(uint64(q.Limit), 10))
+	}
+
+	path := "/loki/api/v1/query_range"
+	if q.Instant {
+		path = "/loki/api/v1/query"
+		params.Set("time", strconv.FormatInt(q.End.UnixNano(), 10))
+	} else {
+		params.Set("start", strconv.FormatInt(q.Start.UnixNano(), 10))
+		params.Set("end", strconv.FormatInt(q.End.UnixNano(), 10))
+		if q.Step > 0 {
+			params.Set("step", q.Step.String())
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: path, RawQuery: params.Encode()},
+		Header: http.Header{},
+	}
+	if q.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", q.Tenant)
+	}
+	return req
+}
+
+// ParseQueryLog reads the queries of a logfmt query log. Only the query
+// statistics lines of the query frontend are read, i.e. the lines with a
+// latency, query and range_type field. The time range of a query is read from
+// its start and end fields if any, else from its start_delta and end_delta
+// fields relative to the ts field.
+func ParseQueryLog(r io.Reader) ([]ReplayQuery, error) {
+	var (
+		queries []ReplayQuery
+		line    int
+	)
+	dec := logfmt.NewDecoder(r)
+	for dec.ScanRecord() {
+		line++
+		fields := map[string]string{}
+		for dec.ScanKeyval() {
+			fields[string(dec.Key())] = string(dec.Value())
+		}
+		if err := dec.Err(); err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+
+		if fields["latency"] == "" || fields["query"] == "" || fields["range_type"] == "" {
+			continue
+		}
+		if component, ok := fields["component"]; ok && component != "frontend" {
+			continue
+		}
+
+		q, err := parseReplayQuery(fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		queries = append(queries, q)
+	}
+	if err := dec.Err(); err != nil {
+		return nil, errors.Wrapf(err, "line %d", line)
+	}
+	return queries, nil
+}
+
+func parseReplayQuery(fields map[string]string) (ReplayQuery, error) {
+	q := ReplayQuery{
+		Tenant:    fields["org_id"],
+		Query:     fields["query"],
+		Instant:   fields["range_type"] == "instant",
+		Direction: logproto.BACKWARD,
+	}
+
+	var err error
+	if fields["start"] != "" || fields["end"] != "" {
+		if q.Start, err = parseReplayTime(fields["start"]); err != nil {
+			return q, errors.Wrap(err, "invalid start")
+		}
+		if q.End, err = parseReplayTime(fields["end"]); err != nil {
+			return q, errors.Wrap(err, "invalid end")
+		}
+	} else {
+		ts, err := time.Parse(time.RFC3339Nano, fields["ts"])
+		if err != nil {
+			return q, errors.Wrap(err, "invalid ts")
+		}
+		startDelta, err := time.ParseDuration(fields["start_delta"])
+		if err != nil {
+			return q, errors.Wrap(err, "invalid start_delta")
+		}
+		endDelta, err := time.ParseDuration(fields["end_delta"])
+		if err != nil {
+			return q, errors.Wrap(err, "invalid end_delta")
+		}
+		q.Start, q.End = ts.Add(-startDelta), ts.Add(-endDelta)
+	}
+
+	if step := fields["step"]; step != "" {
+		if q.Step, err = time.ParseDuration(step); err != nil {
+			// The step of the requests is logged in milliseconds.
+			ms, err := strconv.ParseInt(step, 10, 64)
+			if err != nil {
+				return q, errors.Wrap(err, "invalid step")
+			}
+			q.Step = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if limit := fields["limit"]; limit != "" {
+		l, err := strconv.ParseUint(limit, 10, 32)
+		if err != nil {
+			return q, errors.Wrap(err, "invalid limit")
+		}
+		q.Limit = uint32(l)
+	}
+	if direction := fields["direction"]; direction != "" {
+		d, ok := logproto.Direction_value[strings.ToUpper(direction)]
+		if !ok {
+			return q, fmt.Errorf("invalid direction %s", direction)
+		}
+		q.Direction = logproto.Direction(d)
+	}
+	return q, nil
+}
+
+// parseReplayTime parses a timestamp either in RFC3339 or in unix nanoseconds.
+func parseReplayTime(value string) (time.Time, error) {
+	if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(0, ns), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
@@ -0,0 +1,118 @@
+package querytee
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestParseQueryLog(t *testing.T) {
+	queryLog := strings.Join([]string{
+		`level=info ts=2024-01-01T12:00:00Z caller=metrics.go:160 component=frontend org_id=tenant-a latency=fast query="{app=\"foo\"} |= \"error\"" query_type=filter range_type=range length=1h0m0s start_delta=1h0m0s end_delta=0s step=14s duration=10ms status=200 limit=100 returned_lines=0`,
+		`level=info ts=2024-01-01T12:00:00Z caller=metrics.go:160 component=querier org_id=tenant-a latency=fast query="{app=\"foo\"}" query_type=limited range_type=range length=1h0m0s start_delta=1h0m0s end_delta=0s step=14s limit=100`,
+		`level=info ts=2024-01-01T12:00:00Z caller=roundtrip.go:320 org_id=tenant-a msg="executing query" type=range query="{app=\"foo\"}"`,
+		`level=info ts=2024-01-01T12:00:00Z caller=metrics.go:160 org_id=tenant-b latency=slow query="sum(rate({app=\"foo\"}[1m]))" query_type=metric range_type=instant start=1704110400000000000 end=1704110400000000000 step=0s limit=100 direction=forward`,
+		`level=info ts=2024-01-01T12:00:00Z caller=metrics.go:200 org_id=tenant-b latency=fast query="{app=\"foo\"}" query_type=series length=1h0m0s`,
+	}, "\n")
+
+	queries, err := ParseQueryLog(strings.NewReader(queryLog))
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, []ReplayQuery{
+		{
+			Tenant:    "tenant-a",
+			Query:     `{app="foo"} |= "error"`,
+			Start:     ts.Add(-time.Hour),
+			End:       ts,
+			Step:      14 * time.Second,
+			Limit:     100,
+			Direction: logproto.BACKWARD,
+		},
+		{
+			Tenant:    "tenant-b",
+			Query:     `sum(rate({app="foo"}[1m]))`,
+			Instant:   true,
+			Start:     time.Unix(0, ts.UnixNano()),
+			End:       time.Unix(0, ts.UnixNano()),
+			Limit:     100,
+			Direction: logproto.FORWARD,
+		},
+	}, queries)
+
+	_, err = ParseQueryLog(strings.NewReader(`latency=fast query="{app=\"foo\"}" range_type=range start_delta=foo`))
+	require.Error(t, err)
+}
+
+func TestReplayer(t *testing.T) {
+	newBackend := func(streams string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/loki/api/v1/query_range", r.URL.Path)
+			require.Equal(t, "tenant-a", r.Header.Get("X-Scope-OrgID"))
+			result := streams
+			if r.URL.Query().Get("query") == `{app="bar"}` {
+				result = `[]`
+			}
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":` + result + `}}`))
+		}))
+	}
+	expected := newBackend(`[{"stream":{"app":"foo"},"values":[["3","c"],["2","b"],["1","a"]]}]`)
+	defer expected.Close()
+	actual := newBackend(`[{"stream":{"app":"foo"},"values":[["3","c"],["2","x"],["1","a"]]}]`)
+	defer actual.Close()
+
+	r, err := NewReplayer(ReplayConfig{Concurrency: 2, TolerateLimitTruncation: true}, ProxyConfig{
+		BackendEndpoints:   expected.URL + "," + actual.URL,
+		PreferredBackend:   "0",
+		BackendReadTimeout: time.Second,
+	}, NewSamplesComparator(SampleComparisonOptions{}), log.NewNopLogger())
+	require.NoError(t, err)
+
+	queries := []ReplayQuery{
+		{Tenant: "tenant-a", Query: `{app="foo"}`, Start: time.Unix(0, 0), End: time.Unix(0, 10), Limit: 100, Direction: logproto.BACKWARD},
+		{Tenant: "tenant-a", Query: `{app="bar"}`, Start: time.Unix(0, 0), End: time.Unix(0, 10), Limit: 100, Direction: logproto.BACKWARD},
+	}
+
+	var report bytes.Buffer
+	summary, err := r.Replay(context.Background(), queries, &report)
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.Queries)
+	require.Equal(t, 1, summary.Mismatches)
+	// The backends are named after their hostname, shared in tests.
+	require.Len(t, summary.Latencies["127.0.0.1"], 4)
+
+	// Only the mismatching query is reported.
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var res struct {
+		Query    string `json:"query"`
+		Match    bool   `json:"match"`
+		Expected struct {
+			Status int `json:"status"`
+		} `json:"expected"`
+		Streams []struct {
+			Labels     string     `json:"labels"`
+			Missing    [][]string `json:"missing"`
+			Unexpected [][]string `json:"unexpected"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+	require.Equal(t, `{app="foo"}`, res.Query)
+	require.False(t, res.Match)
+	require.Equal(t, http.StatusOK, res.Expected.Status)
+	require.Len(t, res.Streams, 1)
+	require.Equal(t, `{app="foo"}`, res.Streams[0].Labels)
+	require.Equal(t, [][]string{{"2", "b"}}, res.Streams[0].Missing)
+	require.Equal(t, [][]string{{"2", "x"}}, res.Streams[0].Unexpected)
+}
@@ -31,6 +31,9 @@ type SampleComparisonOptions struct {
 	Tolerance         float64
 	UseRelativeError  bool
 	SkipRecentSamples time.Duration
+	// IgnoreEntryOrder compares the entries of the log streams regardless of
+	// their order, e.g. entries sharing a timestamp.
+	IgnoreEntryOrder bool
 }
 
 func NewSamplesComparator(opts SampleComparisonOptions) *SamplesComparator {
@@ -250,7 +253,7 @@ func compareSampleValue(first, second model.SampleValue, opts SampleComparisonOp
 	return math.Abs(f-s) <= opts.Tolerance
 }
 
-func compareStreams(expectedRaw, actualRaw json.RawMessage, _ SampleComparisonOptions) (*ComparisonSummary, error) {
+func compareStreams(expectedRaw, actualRaw json.RawMessage, opts SampleComparisonOptions) (*ComparisonSummary, error) {
 	var expected, actual loghttp.Streams
 
 	err := jsoniter.Unmarshal(expectedRaw, &expected)
@@ -262,6 +265,11 @@ func compareStreams(expectedRaw, actualRaw json.RawMessage, _ SampleComparisonOp
 		return nil, errors.Wrap(err, "unable to unmarshal actual streams")
 	}
 
+	if opts.IgnoreEntryOrder {
+		sortStreamEntries(expected)
+		sortStreamEntries(actual)
+	}
+
 	if len(expected) != len(actual) {
 		return nil, fmt.Errorf("expected %d streams but got %d", len(expected), len(actual))
 	}
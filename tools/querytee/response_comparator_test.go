@@ -511,3 +511,18 @@ func TestCompareStreams(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareStreams_IgnoreEntryOrder(t *testing.T) {
+	expected := json.RawMessage(`[
+					{"stream":{"foo":"bar"},"values":[["2","b"],["2","a"],["1","c"]]}
+				]`)
+	actual := json.RawMessage(`[
+					{"stream":{"foo":"bar"},"values":[["2","a"],["2","b"],["1","c"]]}
+				]`)
+
+	_, err := compareStreams(expected, actual, SampleComparisonOptions{})
+	require.Error(t, err)
+
+	_, err = compareStreams(expected, actual, SampleComparisonOptions{IgnoreEntryOrder: true})
+	require.NoError(t, err)
+}
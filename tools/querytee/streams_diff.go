@@ -0,0 +1,204 @@
+package querytee
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// StreamDiffOptions defines the tolerances applied when diffing log streams.
+type StreamDiffOptions struct {
+	// IgnoreEntryOrder compares the entries of the streams regardless of their
+	// order, e.g. entries sharing a timestamp.
+	IgnoreEntryOrder bool
+	// Limit of the query. If greater than 0, a response holding Limit entries is
+	// considered truncated, and the entries beyond the oldest (backward) or newest
+	// (forward) timestamp of the truncated responses are not compared.
+	Limit     uint32
+	Direction logproto.Direction
+}
+
+// StreamDiff is the difference of a log stream between two responses.
+type StreamDiff struct {
+	Labels string `json:"labels"`
+	// Missing are the entries of the expected stream missing from the actual one.
+	Missing []loghttp.Entry `json:"missing,omitempty"`
+	// Unexpected are the entries of the actual stream missing from the expected one.
+	Unexpected []loghttp.Entry `json:"unexpected,omitempty"`
+	// Reordered is set when the streams hold the same entries in a different order.
+	Reordered bool `json:"reordered,omitempty"`
+}
+
+// DiffStreams diffs the log streams of two responses entry by entry, and
+// returns the streams that differ.
+func DiffStreams(expected, actual loghttp.Streams, opts StreamDiffOptions) []StreamDiff {
+	if opts.Limit > 0 {
+		expected, actual = trimTruncatedStreams(expected, actual, opts.Limit, opts.Direction)
+	}
+
+	expectedByLabels := streamsByLabels(expected)
+	actualByLabels := streamsByLabels(actual)
+
+	names := make([]string, 0, len(expectedByLabels)+len(actualByLabels))
+	for name := range expectedByLabels {
+		names = append(names, name)
+	}
+	for name := range actualByLabels {
+		if _, ok := expectedByLabels[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []StreamDiff
+	for _, name := range names {
+		diff := diffEntries(expectedByLabels[name], actualByLabels[name])
+		if len(diff.Missing) == 0 && len(diff.Unexpected) == 0 {
+			diff.Reordered = !opts.IgnoreEntryOrder && !sameEntries(expectedByLabels[name], actualByLabels[name])
+			if !diff.Reordered {
+				continue
+			}
+		}
+		diff.Labels = name
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+func streamsByLabels(streams loghttp.Streams) map[string][]loghttp.Entry {
+	res := make(map[string][]loghttp.Entry, len(streams))
+	for _, s := range streams {
+		name := s.Labels.String()
+		res[name] = append(res[name], s.Entries...)
+	}
+	return res
+}
+
+// diffEntries returns the entries of each slice missing from the other one.
+func diffEntries(expected, actual []loghttp.Entry) StreamDiff {
+	expected, actual = sortedEntries(expected), sortedEntries(actual)
+
+	var diff StreamDiff
+	i, j := 0, 0
+	for i < len(expected) && j < len(actual) {
+		switch {
+		case entryLess(expected[i], actual[j]):
+			diff.Missing = append(diff.Missing, expected[i])
+			i++
+		case entryLess(actual[j], expected[i]):
+			diff.Unexpected = append(diff.Unexpected, actual[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	diff.Missing = append(diff.Missing, expected[i:]...)
+	diff.Unexpected = append(diff.Unexpected, actual[j:]...)
+	return diff
+}
+
+func sameEntries(a, b []loghttp.Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Timestamp.Equal(b[i].Timestamp) || a[i].Line != b[i].Line {
+			return false
+		}
+	}
+	return true
+}
+
+func entryLess(a, b loghttp.Entry) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.Line < b.Line
+}
+
+func sortedEntries(entries []loghttp.Entry) []loghttp.Entry {
+	res := make([]loghttp.Entry, len(entries))
+	copy(res, entries)
+	sort.SliceStable(res, func(i, j int) bool { return entryLess(res[i], res[j]) })
+	return res
+}
+
+// sortStreamEntries sorts the entries of each stream in place by timestamp and line.
+func sortStreamEntries(streams loghttp.Streams) {
+	for _, s := range streams {
+		sort.SliceStable(s.Entries, func(i, j int) bool { return entryLess(s.Entries[i], s.Entries[j]) })
+	}
+}
+
+// trimTruncatedStreams drops the entries of both responses that are beyond the
+// boundary of the responses truncated by the limit. The backends may return
+// different entries at the boundary, e.g. when breaking timestamp ties, so the
+// entries at the boundary are dropped as well.
+func trimTruncatedStreams(expected, actual loghttp.Streams, limit uint32, direction logproto.Direction) (loghttp.Streams, loghttp.Streams) {
+	var (
+		boundary  time.Time
+		truncated bool
+	)
+	for _, streams := range []loghttp.Streams{expected, actual} {
+		b, ok := limitBoundary(streams, limit, direction)
+		if !ok {
+			continue
+		}
+		if !truncated ||
+			(direction == logproto.BACKWARD && b.After(boundary)) ||
+			(direction == logproto.FORWARD && b.Before(boundary)) {
+			boundary = b
+		}
+		truncated = true
+	}
+	if !truncated {
+		return expected, actual
+	}
+
+	keep := func(ts time.Time) bool {
+		if direction == logproto.FORWARD {
+			return ts.Before(boundary)
+		}
+		return ts.After(boundary)
+	}
+	return filterEntries(expected, keep), filterEntries(actual, keep)
+}
+
+// limitBoundary returns the oldest (backward) or newest (forward) timestamp of
+// the streams if they hold at least limit entries.
+func limitBoundary(streams loghttp.Streams, limit uint32, direction logproto.Direction) (time.Time, bool) {
+	var (
+		boundary time.Time
+		count    uint32
+	)
+	for _, s := range streams {
+		for _, e := range s.Entries {
+			if count == 0 ||
+				(direction == logproto.BACKWARD && e.Timestamp.Before(boundary)) ||
+				(direction == logproto.FORWARD && e.Timestamp.After(boundary)) {
+				boundary = e.Timestamp
+			}
+			count++
+		}
+	}
+	return boundary, count > 0 && count >= limit
+}
+
+func filterEntries(streams loghttp.Streams, keep func(time.Time) bool) loghttp.Streams {
+	res := make(loghttp.Streams, 0, len(streams))
+	for _, s := range streams {
+		var entries []loghttp.Entry
+		for _, e := range s.Entries {
+			if keep(e.Timestamp) {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) > 0 {
+			res = append(res, loghttp.Stream{Labels: s.Labels, Entries: entries})
+		}
+	}
+	return res
+}
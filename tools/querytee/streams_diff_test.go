@@ -0,0 +1,104 @@
+package querytee
+
+import (
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func mustParseStreams(t *testing.T, raw string) loghttp.Streams {
+	var streams loghttp.Streams
+	require.NoError(t, jsoniter.Unmarshal([]byte(raw), &streams))
+	return streams
+}
+
+func entry(ts int64, line string) loghttp.Entry {
+	return loghttp.Entry{Timestamp: time.Unix(0, ts), Line: line}
+}
+
+func TestDiffStreams(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+		actual   string
+		opts     StreamDiffOptions
+		diffs    []StreamDiff
+	}{
+		{
+			name:     "same streams",
+			expected: `[{"stream":{"foo":"bar"},"values":[["2","b"],["1","a"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["2","b"],["1","a"]]}]`,
+		},
+		{
+			name:     "missing and unexpected entries",
+			expected: `[{"stream":{"foo":"bar"},"values":[["3","c"],["2","b"],["1","a"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["3","c"],["2","x"],["1","a"],["0","z"]]}]`,
+			diffs: []StreamDiff{{
+				Labels:     `{foo="bar"}`,
+				Missing:    []loghttp.Entry{entry(2, "b")},
+				Unexpected: []loghttp.Entry{entry(0, "z"), entry(2, "x")},
+			}},
+		},
+		{
+			name:     "missing and unexpected streams",
+			expected: `[{"stream":{"foo":"bar"},"values":[["1","a"]]}]`,
+			actual:   `[{"stream":{"foo":"baz"},"values":[["1","a"]]}]`,
+			diffs: []StreamDiff{
+				{Labels: `{foo="bar"}`, Missing: []loghttp.Entry{entry(1, "a")}},
+				{Labels: `{foo="baz"}`, Unexpected: []loghttp.Entry{entry(1, "a")}},
+			},
+		},
+		{
+			name:     "reordered entries",
+			expected: `[{"stream":{"foo":"bar"},"values":[["1","a"],["1","b"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["1","b"],["1","a"]]}]`,
+			diffs:    []StreamDiff{{Labels: `{foo="bar"}`, Reordered: true}},
+		},
+		{
+			name:     "reordered entries ignored",
+			expected: `[{"stream":{"foo":"bar"},"values":[["1","a"],["1","b"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["1","b"],["1","a"]]}]`,
+			opts:     StreamDiffOptions{IgnoreEntryOrder: true},
+		},
+		{
+			name:     "backward limit truncation",
+			expected: `[{"stream":{"foo":"bar"},"values":[["4","d"],["3","c"],["2","b"]]},{"stream":{"foo":"baz"},"values":[["2","x"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["4","d"],["3","c"],["2","b"],["1","a"]]}]`,
+			opts:     StreamDiffOptions{Limit: 4, Direction: logproto.BACKWARD},
+		},
+		{
+			name:     "forward limit truncation",
+			expected: `[{"stream":{"foo":"bar"},"values":[["1","a"],["2","b"],["3","c"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["1","a"],["2","b"]]},{"stream":{"foo":"baz"},"values":[["3","x"]]}]`,
+			opts:     StreamDiffOptions{Limit: 3, Direction: logproto.FORWARD},
+		},
+		{
+			name:     "difference within the limit",
+			expected: `[{"stream":{"foo":"bar"},"values":[["4","d"],["3","c"],["2","b"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["4","x"],["3","c"],["2","b"]]}]`,
+			opts:     StreamDiffOptions{Limit: 3, Direction: logproto.BACKWARD},
+			diffs: []StreamDiff{{
+				Labels:     `{foo="bar"}`,
+				Missing:    []loghttp.Entry{entry(4, "d")},
+				Unexpected: []loghttp.Entry{entry(4, "x")},
+			}},
+		},
+		{
+			name:     "responses not truncated",
+			expected: `[{"stream":{"foo":"bar"},"values":[["2","b"],["1","a"]]}]`,
+			actual:   `[{"stream":{"foo":"bar"},"values":[["2","b"]]}]`,
+			opts:     StreamDiffOptions{Limit: 3, Direction: logproto.BACKWARD},
+			diffs:    []StreamDiff{{Labels: `{foo="bar"}`, Missing: []loghttp.Entry{entry(1, "a")}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diffs := DiffStreams(mustParseStreams(t, tc.expected), mustParseStreams(t, tc.actual), tc.opts)
+			require.Equal(t, tc.diffs, diffs)
+		})
+	}
+}